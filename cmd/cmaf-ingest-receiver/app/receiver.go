@@ -12,6 +12,7 @@ import (
 	"strings"
 
 	"github.com/Dash-Industry-Forum/livesim2/pkg/chunkparser"
+	"github.com/Dash-Industry-Forum/livesim2/pkg/storage"
 	"github.com/Eyevinn/dash-mpd/mpd"
 	"github.com/Eyevinn/mp4ff/bits"
 	"github.com/Eyevinn/mp4ff/mp4"
@@ -25,6 +26,7 @@ type Receiver struct {
 	storage    string
 	streams    map[string]stream // mapped by stream.id()
 	channelMgr *ChannelMgr
+	archive    storage.Storage // copies of received segments, if set
 }
 
 func NewReceiver(ctx context.Context, opts *Options, cfg *Config) (*Receiver, error) {
@@ -35,9 +37,38 @@ func NewReceiver(ctx context.Context, opts *Options, cfg *Config) (*Receiver, er
 		streams:    make(map[string]stream),
 		channelMgr: NewChannelMgr(cfg, uint32(opts.timeShiftBufferDepthS), uint32(opts.receiveNrRawSegments)),
 	}
+	if opts.archive != "" {
+		st, err := storage.New(opts.archive)
+		if err != nil {
+			return nil, fmt.Errorf("archive: %w", err)
+		}
+		r.archive = st
+	}
 	return r, nil
 }
 
+// archiveFile copies a stored file to the archive in the background, using its path relative to the storage root as key.
+func (r *Receiver) archiveFile(log *slog.Logger, filePath string) {
+	if r.archive == nil || filePath == "" {
+		return
+	}
+	rel, err := filepath.Rel(r.storage, filePath)
+	if err != nil {
+		log.Warn("Failed to archive file", "path", filePath, "err", err)
+		return
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		log.Warn("Failed to archive file", "path", filePath, "err", err)
+		return
+	}
+	go func() {
+		if err := r.archive.Put(context.Background(), filepath.ToSlash(rel), data); err != nil {
+			log.Warn("Failed to archive file", "path", filePath, "err", err)
+		}
+	}()
+}
+
 // SegmentHandlerFunc is a handler for receiving segments, but will also accept MPDs (extension .mpd).
 func (r *Receiver) SegmentHandlerFunc(w http.ResponseWriter, req *http.Request) {
 	// Extract the path and filename from URL
@@ -303,6 +334,7 @@ func (r *Receiver) SegmentHandlerFunc(w http.ResponseWriter, req *http.Request)
 			rsd.dur = rsd.totDur
 			ch.addChunkData(*rsd)
 		}
+		r.archiveFile(log, filePath)
 
 		w.WriteHeader(http.StatusOK)
 		return
@@ -366,6 +398,7 @@ func (r *Receiver) SegmentHandlerFunc(w http.ResponseWriter, req *http.Request)
 		}
 	}
 	trD.nrSegsReceived++
+	r.archiveFile(log, filePath)
 }
 
 // DiscardUpload reads and discards the upload and returns the status code.
@@ -82,6 +82,7 @@ type Options struct {
 	certPath              string
 	keyPath               string
 	fileServerPath        string
+	archive               string
 	timeShiftBufferDepthS uint64
 	receiveNrRawSegments  uint64
 	version               bool
@@ -112,6 +113,7 @@ func ParseOptions() (*Options, error) {
 	flag.Uint64Var(&opts.timeShiftBufferDepthS, "tsbd", defaultTimeShiftBufferDepthS, "Default timeShiftBufferDepth in seconds")
 	flag.Uint64Var(&opts.receiveNrRawSegments, "recRawNr", 0, "Default number of raw segments to receive and store (turns off all parsing)")
 	flag.StringVar(&opts.configFile, "config", "", "Config file with channel-specific settings")
+	flag.StringVar(&opts.archive, "archive", "", "Storage for copies of received segments: directory, file:///dir, or s3://bucket/prefix")
 	flag.StringVar(&opts.fileServerPath, "fileserverpath", "", "HTTP path for generated segments and manifests (for testing)")
 	flag.BoolVar(&opts.version, "version", false, "Get version")
 
@@ -19,6 +19,7 @@ import (
 	"time"
 
 	"github.com/Dash-Industry-Forum/livesim2/internal"
+	"github.com/Dash-Industry-Forum/livesim2/pkg/segtemplate"
 	m "github.com/Eyevinn/dash-mpd/mpd"
 )
 
@@ -113,22 +114,15 @@ func start(ctx context.Context, o *Options) (counts, error) {
 				if segTmpl == nil {
 					return cnt, fmt.Errorf("no SegmentTemplate for representation: %s", rep.Id)
 				}
-				initStr, _ := rep.GetInit()
+				initStr, media := repTemplates(rep, segTmpl)
 				cnt = downloadInit(ctx, segTmpl, outDir, baseURL, initStr, cnt, o.Force)
-				media, _ := rep.GetMedia()
 				switch {
 				case segTmpl.SegmentTimeline != nil:
-					stl := segTmpl.SegmentTimeline
-					switch {
-					case strings.Contains(media, "$Time$"):
-						cnt = downloadSegmentTimeLineWithTime(ctx, stl, media, outDir, baseURL, cnt, o.Force)
-					case strings.Contains(media, "$Number$"):
-						slog.Warn("SegmentTimeline with $Number$ not yet supported")
-						// downloadSegmentTimeLineWithNumber
-					default:
+					if !segtemplate.Has(media, "Time") && !segtemplate.Has(media, "Number") {
 						return cnt, fmt.Errorf("strange media for SegmentTimeline")
 					}
-				case strings.Contains(segTmpl.Media, "$Number$"):
+					cnt = downloadSegmentTimeline(ctx, segTmpl, media, outDir, baseURL, cnt, o.Force)
+				case segtemplate.Has(media, "Number"):
 					periodDur, err := period.GetDuration()
 					if err != nil {
 						return cnt, fmt.Errorf("period duration issue: %w", err)
@@ -173,31 +167,27 @@ func downloadInit(ctx context.Context, segTmpl *m.SegmentTemplateType, outDir, b
 	return cnt
 }
 
-func downloadSegmentTimeLineWithTime(ctx context.Context, stl *m.SegmentTimelineType, mediaPattern, outDir, baseURL string, cnt counts, force bool) counts {
+func downloadSegmentTimeline(ctx context.Context, stpl *m.SegmentTemplateType, mediaPattern, outDir, baseURL string, cnt counts, force bool) counts {
+	nr := uint32(1)
+	if stpl.StartNumber != nil {
+		nr = *stpl.StartNumber
+	}
 	startTime := uint64(0)
 	var err error
-	for _, segItvl := range stl.S {
+	for _, segItvl := range stpl.SegmentTimeline.S {
 		if segItvl.T != nil {
 			startTime = *segItvl.T
 		}
-		mPart := replaceTime(mediaPattern, startTime)
-		u := baseURL + mPart
-		p := path.Join(outDir, mPart)
-		cnt, err = downloadAndCount(ctx, u, p, cnt, force)
-		if err != nil {
-			slog.Warn("download file", "error", err)
-		}
-		dur := segItvl.D
-		startTime += dur
-		for i := 0; i < segItvl.R; i++ {
-			mPart := replaceTime(mediaPattern, startTime)
+		for i := 0; i <= segItvl.R; i++ {
+			mPart := replaceTimeAndNumber(mediaPattern, startTime, nr)
 			u := baseURL + mPart
 			p := path.Join(outDir, mPart)
 			cnt, err = downloadAndCount(ctx, u, p, cnt, force)
 			if err != nil {
 				slog.Warn("download file", "error", err)
 			}
-			startTime += dur
+			startTime += segItvl.D
+			nr++
 		}
 	}
 	return cnt
@@ -220,7 +210,7 @@ func downloadSegmentNumber(ctx context.Context, stpl *m.SegmentTemplateType, tot
 	var err error
 	nrSegments := totDurMS * timeScale / (dur * 1000)
 	for i := startNr; i <= nrSegments+1; i++ { // Try one more to avoid rounding problems
-		mPart := replaceNumber(mediaPattern, i)
+		mPart := replaceTimeAndNumber(mediaPattern, 0, i)
 		u := baseURL + mPart
 		p := path.Join(outDir, mPart)
 		cnt, err = downloadAndCount(ctx, u, p, cnt, force)
@@ -255,12 +245,25 @@ func getBase(u string) string {
 	return u[:idx+1]
 }
 
-func replaceTime(media string, time uint64) string {
-	return strings.Replace(media, "$Time$", strconv.Itoa(int(time)), 1)
+// repTemplates returns the initialization and media templates of rep with $RepresentationID$ and
+// $Bandwidth$ replaced. The initialization is unescaped, while the media still has $Number$ or $Time$.
+func repTemplates(rep *m.RepresentationType, segTmpl *m.SegmentTemplateType) (initStr, media string) {
+	vals := map[string]string{
+		"RepresentationID": rep.Id,
+		"Bandwidth":        strconv.Itoa(int(rep.Bandwidth)),
+	}
+	return segtemplate.Substitute(segTmpl.Initialization, vals, true), segtemplate.Substitute(segTmpl.Media, vals, false)
 }
 
-func replaceNumber(media string, nr uint32) string {
-	return strings.Replace(media, "$Number$", strconv.Itoa(int(nr)), 1)
+// replaceTimeAndNumber replaces $Time$ and $Number$ including format tags, sets $SubNumber$ to 1
+// since segments are not split, and unescapes $$.
+func replaceTimeAndNumber(media string, time uint64, nr uint32) string {
+	vals := map[string]string{
+		"Time":      strconv.FormatUint(time, 10),
+		"Number":    strconv.FormatUint(uint64(nr), 10),
+		"SubNumber": "1",
+	}
+	return segtemplate.Substitute(media, vals, true)
 }
 
 // downloadToFile downloads content directly into a file given by outPath
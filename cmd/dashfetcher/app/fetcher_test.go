@@ -7,6 +7,7 @@ package app
 import (
 	"testing"
 
+	m "github.com/Eyevinn/dash-mpd/mpd"
 	"github.com/stretchr/testify/require"
 )
 
@@ -40,3 +41,16 @@ func TestAutoDir(t *testing.T) {
 	}
 
 }
+
+func TestSegmentTemplates(t *testing.T) {
+	rep := &m.RepresentationType{Id: "V300", Bandwidth: 300000}
+	segTmpl := &m.SegmentTemplateType{
+		Initialization: "$RepresentationID$/$$init.mp4",
+		Media:          "$RepresentationID$/$Bandwidth$/$Number%05d$_$SubNumber$$$.m4s",
+	}
+	initStr, media := repTemplates(rep, segTmpl)
+	require.Equal(t, "V300/$init.mp4", initStr)
+	require.Equal(t, "V300/300000/$Number%05d$_$SubNumber$$$.m4s", media)
+	require.Equal(t, "V300/300000/00042_1$.m4s", replaceTimeAndNumber(media, 0, 42))
+	require.Equal(t, "t/1234.m4s", replaceTimeAndNumber("t/$Time$.m4s", 1234, 1))
+}
@@ -0,0 +1,159 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// variantHeader names the experiment and variant of a response.
+const variantHeader = "X-Livesim-Variant"
+
+// ExperimentConfig is an A/B test assigning sessions to variants with different response configurations.
+type ExperimentConfig struct {
+	Name     string          `json:"name"`
+	Variants []VariantConfig `json:"variants"`
+}
+
+// VariantConfig is one variant of an experiment.
+type VariantConfig struct {
+	Name string `json:"name"`
+	// Weight is the relative share of sessions. 0 means 1.
+	Weight int `json:"weight,omitempty"`
+	// Config is a ResponseConfig overlay in the same JSON format as the X-Livesim-Config header.
+	Config map[string]any `json:"config,omitempty"`
+}
+
+// experiment is a validated ExperimentConfig.
+type experiment struct {
+	name        string
+	variants    []variant
+	totalWeight uint64
+}
+
+type variant struct {
+	name    string
+	weight  int
+	cfgJSON []byte
+}
+
+// Assignment is the variant of an experiment assigned to a session.
+type Assignment struct {
+	Experiment string         `json:"experiment"`
+	SessionID  string         `json:"sessionID"`
+	Variant    string         `json:"variant"`
+	Config     map[string]any `json:"config,omitempty" doc:"ResponseConfig overlay of the variant"`
+}
+
+// newExperiments validates the experiment configurations.
+func newExperiments(cfgs []ExperimentConfig) (map[string]*experiment, error) {
+	exps := make(map[string]*experiment, len(cfgs))
+	for _, ec := range cfgs {
+		if ec.Name == "" || strings.ContainsAny(ec.Name, "/_") {
+			return nil, fmt.Errorf("experiment name %q must be non-empty without / and _", ec.Name)
+		}
+		if _, ok := exps[ec.Name]; ok {
+			return nil, fmt.Errorf("experiment %q defined twice", ec.Name)
+		}
+		if len(ec.Variants) < 2 {
+			return nil, fmt.Errorf("experiment %q needs at least two variants", ec.Name)
+		}
+		exp := experiment{name: ec.Name}
+		names := make(map[string]bool)
+		for i, vc := range ec.Variants {
+			if vc.Name == "" || names[vc.Name] {
+				return nil, fmt.Errorf("experiment %q variant %d: name %q must be non-empty and unique", ec.Name, i, vc.Name)
+			}
+			names[vc.Name] = true
+			if vc.Weight < 0 {
+				return nil, fmt.Errorf("experiment %q variant %q: weight must be >= 0", ec.Name, vc.Name)
+			}
+			cfgJSON := []byte("{}")
+			if vc.Config != nil {
+				var err error
+				if cfgJSON, err = json.Marshal(vc.Config); err != nil {
+					return nil, fmt.Errorf("experiment %q variant %q: %w", ec.Name, vc.Name, err)
+				}
+			}
+			if err := applyConfigJSON(NewResponseConfig(), cfgJSON); err != nil {
+				return nil, fmt.Errorf("experiment %q variant %q: %w", ec.Name, vc.Name, err)
+			}
+			v := variant{name: vc.Name, weight: max(vc.Weight, 1), cfgJSON: cfgJSON}
+			exp.variants = append(exp.variants, v)
+			exp.totalWeight += uint64(v.weight)
+		}
+		exps[ec.Name] = &exp
+	}
+	return exps, nil
+}
+
+// assign deterministically picks a variant for sessionID, weighted by the variant weights.
+func (e *experiment) assign(sessionID string) variant {
+	h := fnv.New64a()
+	h.Write([]byte(e.name + "/" + sessionID))
+	pos := h.Sum64() % e.totalWeight
+	for _, v := range e.variants {
+		if pos < uint64(v.weight) {
+			return v
+		}
+		pos -= uint64(v.weight)
+	}
+	return e.variants[len(e.variants)-1]
+}
+
+// assignment returns the assignment of sessionID in the experiment.
+func (e *experiment) assignment(sessionID string) Assignment {
+	v := e.assign(sessionID)
+	a := Assignment{Experiment: e.name, SessionID: sessionID, Variant: v.name}
+	_ = json.Unmarshal(v.cfgJSON, &a.Config)
+	return a
+}
+
+// abSessionID returns the session ID used for variant assignment: the session_<id> URL parameter
+// or the sid query parameter.
+func abSessionID(r *http.Request, cfg *ResponseConfig) string {
+	if cfg.SessionID != "" {
+		return cfg.SessionID
+	}
+	return r.URL.Query().Get("sid")
+}
+
+// applyVariant overlays the configuration of the variant assigned to the request session
+// if the ab_<experiment> URL parameter is used. The variant is logged and set in the X-Livesim-Variant header.
+func (s *Server) applyVariant(w http.ResponseWriter, r *http.Request, log *slog.Logger, cfg *ResponseConfig,
+	nowMS int) (*slog.Logger, *errorWithHttpType) {
+	if cfg.Experiment == "" {
+		return log, nil
+	}
+	exp, ok := s.experiments[cfg.Experiment]
+	if !ok {
+		msg := fmt.Sprintf("unknown experiment %q", cfg.Experiment)
+		return log, generateAndLogHttpError(log, msg, http.StatusNotFound, reasonNotFound)
+	}
+	sessionID := abSessionID(r, cfg)
+	if sessionID == "" {
+		msg := fmt.Sprintf("experiment %q needs a session_<id> URL parameter or sid query parameter", exp.name)
+		return log, generateAndLogHttpError(log, msg, http.StatusBadRequest, reasonBadCombination)
+	}
+	v := exp.assign(sessionID)
+	log = log.With("experiment", exp.name, "variant", v.name)
+	log.Info("A/B variant", "sessionID", sessionID)
+	if err := applyConfigJSON(cfg, v.cfgJSON); err != nil {
+		return log, generateAndLogHttpError(log, err.Error(), http.StatusBadRequest, reasonBadValue)
+	}
+	if err := verifyAndFillConfig(cfg, nowMS); err != nil {
+		msg := fmt.Sprintf("variant %s: %s", v.name, err)
+		return log, generateAndLogHttpError(log, msg, http.StatusBadRequest, reasonFromError(err, reasonBadValue))
+	}
+	if w != nil {
+		w.Header().Set(variantHeader, exp.name+"/"+v.name)
+	}
+	return log, nil
+}
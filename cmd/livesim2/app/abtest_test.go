@@ -0,0 +1,92 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExperiments(t *testing.T) {
+	server, ts := newTestServer(t, func(cfg *ServerConfig) {
+		cfg.Experiments = []ExperimentConfig{
+			{Name: "tsbd", Variants: []VariantConfig{
+				{Name: "control"},
+				{Name: "short", Weight: 3, Config: map[string]any{"TimeShiftBufferDepthS": 10}},
+			}},
+		}
+	})
+
+	exp := server.experiments["tsbd"]
+	counts := make(map[string]int)
+	sids := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		sid := fmt.Sprintf("player-%d", i)
+		v := exp.assign(sid)
+		require.Equal(t, v, exp.assign(sid), "assignment should be deterministic")
+		counts[v.name]++
+		sids[v.name] = sid
+	}
+	require.InDelta(t, 250, counts["control"], 50)
+	require.InDelta(t, 750, counts["short"], 50)
+
+	for variant, wantedTSBD := range map[string]string{"control": "PT1M", "short": "PT10S"} {
+		sid := sids[variant]
+		resp, body := testFullRequest(t, ts, "GET", "/livesim2/ab_tsbd/testpic_2s/Manifest.mpd?nowMS=100000&sid="+sid, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "tsbd/"+variant, resp.Header.Get(variantHeader))
+		mpd, err := m.ReadFromString(string(body))
+		require.NoError(t, err)
+		require.Equal(t, wantedTSBD, mpd.TimeShiftBufferDepth.String())
+
+		resp, body = testFullRequest(t, ts, "GET", "/api/experiments/tsbd/assignment?sid="+sid, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var a Assignment
+		require.NoError(t, json.Unmarshal(body, &a))
+		require.Equal(t, variant, a.Variant)
+		require.Equal(t, sid, a.SessionID)
+	}
+
+	resp, _ := testFullRequest(t, ts, "GET", "/livesim2/ab_tsbd/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode, "no session id")
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/ab_other/testpic_2s/Manifest.mpd?nowMS=100000&sid=x", nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = testFullRequest(t, ts, "GET", "/api/experiments/other/assignment?sid=x", nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, body := testFullRequest(t, ts, "GET", "/api/experiments", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var list struct {
+		Experiments []ExperimentInfo `json:"experiments"`
+	}
+	require.NoError(t, json.Unmarshal(body, &list))
+	require.Len(t, list.Experiments, 1)
+	require.Equal(t, 1, list.Experiments[0].Variants[0].Weight)
+	require.Equal(t, 3, list.Experiments[0].Variants[1].Weight)
+}
+
+func TestExperimentValidation(t *testing.T) {
+	cases := []struct {
+		desc string
+		exps []ExperimentConfig
+	}{
+		{desc: "bad name", exps: []ExperimentConfig{{Name: "a_b", Variants: []VariantConfig{{Name: "x"}, {Name: "y"}}}}},
+		{desc: "one variant", exps: []ExperimentConfig{{Name: "a", Variants: []VariantConfig{{Name: "x"}}}}},
+		{desc: "same variant name", exps: []ExperimentConfig{{Name: "a", Variants: []VariantConfig{{Name: "x"}, {Name: "x"}}}}},
+		{desc: "unknown config field", exps: []ExperimentConfig{{Name: "a", Variants: []VariantConfig{{Name: "x"},
+			{Name: "y", Config: map[string]any{"NoSuchField": 1}}}}}},
+		{desc: "duplicate", exps: []ExperimentConfig{{Name: "a", Variants: []VariantConfig{{Name: "x"}, {Name: "y"}}},
+			{Name: "a", Variants: []VariantConfig{{Name: "x"}, {Name: "y"}}}}},
+	}
+	for _, c := range cases {
+		_, err := newExperiments(c.exps)
+		require.Error(t, err, c.desc)
+	}
+}
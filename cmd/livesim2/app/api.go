@@ -2,26 +2,59 @@ package app
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/danielgtaylor/huma/v2/adapters/humachi"
 	"github.com/go-chi/chi/v5"
+
+	"github.com/Dash-Industry-Forum/livesim2/pkg/mpddiff"
 )
 
 // CmafIngesterRequest represents the CMAF ingest start request.
 type CmafIngesterSetup struct {
-	User        string `json:"user,omitempty" doc:"User name for basic auth" example:""`
-	PassWord    string `json:"password,omitempty" doc:"Password for basic auth" example:""`
-	DestRoot    string `json:"destRoot" doc:"Destination URL root for assets" example:"https://server.com/upload"`
-	DestName    string `json:"destName" doc:"Destination name for asset" example:"testpic_ingest"`
-	URL         string `json:"livesimURL" doc:"Full livesimURL without scheme and host" example:"/livesim2/segtimeline_1/testpic_2s/Manifest.mpd"`
-	TestNowMS   *int   `json:"testNowMS,omitempty" doc:"Test: start time for step-wise sending"`
-	Duration    *int   `json:"duration,omitempty" doc:"Duration in seconds for the CMAF ingest session" example:"60"`
-	StreamsURLs bool   `json:"streamsURLs,omitempty" doc:"Use streams URLs likes Streams(video.cmfv) instead of individual segment URLs" example:"false"`
+	User        string            `json:"user,omitempty" doc:"User name for basic auth" example:""`
+	PassWord    string            `json:"password,omitempty" doc:"Password for basic auth" example:""`
+	DestRoot    string            `json:"destRoot" doc:"Destination URL root for assets" example:"https://server.com/upload"`
+	DestName    string            `json:"destName" doc:"Destination name for asset" example:"testpic_ingest"`
+	URL         string            `json:"livesimURL" doc:"Full livesimURL without scheme and host" example:"/livesim2/segtimeline_1/testpic_2s/Manifest.mpd"`
+	TestNowMS   *int              `json:"testNowMS,omitempty" doc:"Test: start time for step-wise sending"`
+	Duration    *int              `json:"duration,omitempty" doc:"Duration in seconds for the CMAF ingest session" example:"60"`
+	StreamsURLs bool              `json:"streamsURLs,omitempty" doc:"Use streams URLs likes Streams(video.cmfv) instead of individual segment URLs" example:"false"`
+	Profile     string            `json:"profile,omitempty" enum:"dashif,aws-elemental" doc:"Receiver conventions for paths, HTTP method, headers, chunk flushing, and SCTE-35 track" example:"dashif"`
+	StartAt     string            `json:"startAt,omitempty" doc:"Wait until this UTC time (RFC3339) or the next full minute (nextMinute) before pushing" example:"nextMinute"`
+	MaxKbps     int               `json:"maxKbps,omitempty" minimum:"0" doc:"Upload bandwidth cap in kbps shared by all representations" example:"5000"`
+	RepMaxKbps  map[string]int    `json:"repMaxKbps,omitempty" doc:"Upload bandwidth cap in kbps per representation ID"`
+	Webhook     *WebhookSetup     `json:"webhook,omitempty" doc:"Webhook for ingester started, segment_failed, fell_behind and stopped events"`
+	Headers     map[string]string `json:"headers,omitempty" doc:"Extra request headers. Values are Go templates with .NowS, .NowMS, .Path, .Expiry <s> and hmacSHA256 <key> <msg>"`
+	TraceParent string            `json:"traceparent,omitempty" doc:"W3C traceparent whose trace ID is used for all pushes (default from the creating request)"`
+}
+
+// CmafIngesterPairSetup represents a main and backup CMAF ingest pair.
+type CmafIngesterPairSetup struct {
+	Main           CmafIngesterSetup `json:"main" doc:"Setup for the main ingester. startAt applies to both"`
+	BackupDestRoot string            `json:"backupDestRoot" doc:"Destination URL root for the backup ingester" example:"https://backup.server.com/upload"`
+	BackupDestName string            `json:"backupDestName,omitempty" doc:"Destination name for the backup ingester (default same as main)"`
+	OffsetMS       int               `json:"offsetMS,omitempty" minimum:"0" doc:"Backup pushes every segment this many ms after the main ingester" example:"500"`
+}
+
+type CmafIngesterPairCreateRequest struct {
+	Body CmafIngesterPairSetup `json:"body"`
+}
+
+type CmafIngestPairCreateResponse struct {
+	Body struct {
+		MainID   string `json:"mainId" doc:"Unique ID for the main CMAF ingest"`
+		BackupID string `json:"backupId" doc:"Unique ID for the backup CMAF ingest"`
+		StartAt  string `json:"startAt,omitempty" doc:"Common start time of the pair"`
+	}
 }
 
 type CmafIngesterCreateRequest struct {
@@ -44,6 +77,7 @@ type CmafIngestInfoResponse struct {
 		URL      string `json:"livesim-url" doc:"livesim2 URL including /livesim2/ prefix"`
 		ID       string `json:"id" doc:"Unique ID for the CMAF ingest"`
 		Report   string `json:"report" doc:"Report for the CMAF ingest"`
+		TraceID  string `json:"traceId" doc:"W3C trace ID sent with all pushes"`
 	}
 }
 
@@ -66,9 +100,13 @@ type CmafIngestDeleteResponse struct {
 
 func createCmafIngesterHdlr(s *Server) func(ctx context.Context, cfi *CmafIngesterCreateRequest) (*CmafIngestCreateResponse, error) {
 	return func(ctx context.Context, cfi *CmafIngesterCreateRequest) (*CmafIngestCreateResponse, error) {
+		if tc, ok := traceFromContext(ctx); ok && cfi.Body.TraceParent == "" {
+			cfi.Body.TraceParent, _ = tc.child()
+		}
 		nr, err := s.cmafMgr.NewCmafIngester(cfi.Body)
 		if err == nil {
 			s.cmafMgr.startIngester(nr)
+			s.saveState()
 		}
 		resp := &CmafIngestCreateResponse{}
 		resp.Body.DestRoot = cfi.Body.DestRoot
@@ -79,6 +117,28 @@ func createCmafIngesterHdlr(s *Server) func(ctx context.Context, cfi *CmafIngest
 	}
 }
 
+func createCmafIngesterPairHdlr(s *Server) func(ctx context.Context, cfi *CmafIngesterPairCreateRequest) (*CmafIngestPairCreateResponse, error) {
+	return func(ctx context.Context, cfi *CmafIngesterPairCreateRequest) (*CmafIngestPairCreateResponse, error) {
+		if tc, ok := traceFromContext(ctx); ok && cfi.Body.Main.TraceParent == "" {
+			cfi.Body.Main.TraceParent, _ = tc.child()
+		}
+		mainNr, backupNr, err := s.cmafMgr.NewCmafIngesterPair(cfi.Body)
+		if err != nil {
+			return nil, huma.Error400BadRequest(err.Error())
+		}
+		s.cmafMgr.startIngester(mainNr)
+		s.cmafMgr.startIngester(backupNr)
+		s.saveState()
+		resp := &CmafIngestPairCreateResponse{}
+		resp.Body.MainID = fmt.Sprintf("%d", mainNr)
+		resp.Body.BackupID = fmt.Sprintf("%d", backupNr)
+		if ing, ok := s.cmafMgr.getIngester(mainNr); ok && !ing.startAt.IsZero() {
+			resp.Body.StartAt = ing.startAt.Format(time.RFC3339)
+		}
+		return resp, nil
+	}
+}
+
 type idInput struct {
 	Id string `path:"id" maxLength:"32" example:"1234" doc:"Unique ID for the CMAF ingest"`
 }
@@ -89,7 +149,7 @@ func createGetCmafIngesterInfoHdlr(s *Server) func(ctx context.Context, input *i
 		if err != nil {
 			return nil, huma.Error400BadRequest(fmt.Sprintf("Invalid ID: %s", input.Id))
 		}
-		ing, ok := s.cmafMgr.ingesters[uint64(id)]
+		ing, ok := s.cmafMgr.getIngester(uint64(id))
 		if !ok {
 			return nil, huma.Error404NotFound(fmt.Sprintf("CMAF ingest %s not found", input.Id))
 		}
@@ -98,7 +158,10 @@ func createGetCmafIngesterInfoHdlr(s *Server) func(ctx context.Context, input *i
 		resp.Body.DestName = ing.destName
 		resp.Body.URL = ing.url
 		resp.Body.ID = input.Id
+		ing.reportMu.Lock()
 		resp.Body.Report = strings.Join(ing.report, "\n")
+		ing.reportMu.Unlock()
+		resp.Body.TraceID = ing.trace.traceID
 		return resp, nil
 	}
 }
@@ -109,7 +172,7 @@ func createStepCmafIngesterHdlr(s *Server) func(ctx context.Context, input *idIn
 		if err != nil {
 			return nil, huma.Error400BadRequest(fmt.Sprintf("Invalid ID: %s", input.Id))
 		}
-		ci, ok := s.cmafMgr.ingesters[uint64(id)]
+		ci, ok := s.cmafMgr.getIngester(uint64(id))
 		if !ok {
 			return nil, huma.Error404NotFound(fmt.Sprintf("CMAF ingest %s not found", input.Id))
 		}
@@ -126,74 +189,1468 @@ func createDeleteCmafIngesterHdlr(s *Server) func(ctx context.Context, input *id
 		if err != nil {
 			return nil, huma.Error400BadRequest(fmt.Sprintf("Invalid ID: %s", input.Id))
 		}
-		ci, ok := s.cmafMgr.ingesters[uint64(id)]
-		if !ok {
+		if !s.cmafMgr.stopIngester(uint64(id)) {
 			return nil, huma.Error404NotFound(fmt.Sprintf("CMAF ingest %s not found", input.Id))
 		}
-		if ci.state == ingesterStateRunning {
-			ci.mgr.cancels[uint64(id)]()
-		}
-
-		s.cmafMgr.cancels[uint64(id)]()
+		s.saveState()
 		resp := &CmafIngestDeleteResponse{}
 		resp.Body.ID = fmt.Sprintf("Deleted %s!", input.Id)
 		return resp, nil
 	}
 }
 
-func createRouteAPI(s *Server) func(r chi.Router) {
-	return func(r chi.Router) {
-		config := huma.DefaultConfig("Livesim2 API for sessions", "1.0.0")
-		config.Servers = []*huma.Server{
-			{URL: "/api"},
+type inspectInput struct {
+	URL   string `query:"url" required:"true" example:"/livesim2/testpic_2s/V300/100.m4s" doc:"livesim2 URL (path and query) to inspect"`
+	NowMS int    `query:"nowMS" default:"-1" doc:"Wall-clock time in ms to inspect at. Negative value means now"`
+}
+
+type InspectResponse struct {
+	Body InspectReport
+}
+
+func createInspectHdlr(s *Server) func(ctx context.Context, input *inspectInput) (*InspectResponse, error) {
+	return func(ctx context.Context, input *inspectInput) (*InspectResponse, error) {
+		rep, err := s.inspectURL(input.URL, input.NowMS)
+		if err != nil {
+			return nil, huma.Error400BadRequest(err.Error())
 		}
-		config.Info.Description = `The first use case is for generating CMAF ingest streams which are
-		sent to a specified URL. These streams can be used to test CMAF ingest receivers.`
+		return &InspectResponse{Body: *rep}, nil
+	}
+}
 
-		api := humachi.New(r, config)
+// ExperimentInfo describes an A/B experiment.
+type ExperimentInfo struct {
+	Name     string        `json:"name"`
+	Variants []VariantInfo `json:"variants"`
+}
 
-		// Register POST /cmaf-ingests that creates a new CMAF-Ingest source
-		huma.Register(api, huma.Operation{
-			OperationID:   "create-cmaf-ingest",
-			Method:        http.MethodPost,
-			Path:          "/cmaf-ingests",
-			Summary:       "Create a CMAF ingest stream",
-			Tags:          []string{"CMAF-ingest"},
-			DefaultStatus: http.StatusCreated,
-			Errors:        []int{404, 409, 410},
-		}, createCmafIngesterHdlr(s))
+// VariantInfo describes a variant and its share of sessions.
+type VariantInfo struct {
+	Name   string         `json:"name"`
+	Weight int            `json:"weight"`
+	Config map[string]any `json:"config,omitempty"`
+}
 
-		// Register GET /cmaf-ingests/{id}
-		huma.Register(api, huma.Operation{
-			OperationID: "get-cmaf-ingest",
-			Method:      http.MethodGet,
-			Path:        "/cmaf-ingests/{id}",
-			Summary:     "Get information about a CMAF ingest stream",
-			Description: "Get information about CMAF ingest stream with the given ID.",
-			Tags:        []string{"CMAF-ingest"},
-			Errors:      []int{404, 410},
-		}, createGetCmafIngesterInfoHdlr(s))
+type ExperimentsResponse struct {
+	Body struct {
+		Experiments []ExperimentInfo `json:"experiments"`
+	}
+}
 
-		// Register GET /cmaf-ingests/{id}/step
-		huma.Register(api, huma.Operation{
-			OperationID: "step-cmaf-ingest",
-			Method:      http.MethodGet,
-			Path:        "/cmaf-ingests/{id}/step",
-			Summary:     "Step a CMAF ingest stream one step (for testing)",
-			Description: "In testing mode (triggered by setting timeNowMS in creation), send the next segment of all tracks for the given stream ID.",
-			Tags:        []string{"CMAF-ingest"},
-			Errors:      []int{404, 410},
-		}, createStepCmafIngesterHdlr(s))
+func createExperimentsHdlr(s *Server) func(ctx context.Context, input *struct{}) (*ExperimentsResponse, error) {
+	return func(ctx context.Context, input *struct{}) (*ExperimentsResponse, error) {
+		resp := ExperimentsResponse{}
+		resp.Body.Experiments = []ExperimentInfo{}
+		for _, ec := range s.Cfg.Experiments {
+			ei := ExperimentInfo{Name: ec.Name}
+			for _, vc := range ec.Variants {
+				ei.Variants = append(ei.Variants, VariantInfo{Name: vc.Name, Weight: max(vc.Weight, 1), Config: vc.Config})
+			}
+			resp.Body.Experiments = append(resp.Body.Experiments, ei)
+		}
+		return &resp, nil
+	}
+}
 
-		// Register DELETE /cmaf-ingests/{id}
-		huma.Register(api, huma.Operation{
-			OperationID: "delete-cmaf-ingest",
-			Method:      http.MethodDelete,
-			Path:        "/cmaf-ingests/{id}",
-			Summary:     "Stop and delete a CMAF ingest stream",
-			Description: "Stop a CMAF request and get back a report.",
-			Tags:        []string{"CMAF-ingest"},
-			Errors:      []int{404, 410},
-		}, createDeleteCmafIngesterHdlr(s))
+type assignmentInput struct {
+	Name      string `path:"name" maxLength:"64" example:"lowlatency" doc:"Experiment name"`
+	SessionID string `query:"sid" required:"true" example:"player-1" doc:"Session ID as in session_<id> or the sid query parameter"`
+}
+
+type AssignmentResponse struct {
+	Body Assignment
+}
+
+func createAssignmentHdlr(s *Server) func(ctx context.Context, input *assignmentInput) (*AssignmentResponse, error) {
+	return func(ctx context.Context, input *assignmentInput) (*AssignmentResponse, error) {
+		exp, ok := s.experiments[input.Name]
+		if !ok {
+			return nil, huma.Error404NotFound(fmt.Sprintf("experiment %s not found", input.Name))
+		}
+		return &AssignmentResponse{Body: exp.assignment(input.SessionID)}, nil
+	}
+}
+
+type integrityInput struct {
+	URL   string `query:"url" required:"true" example:"/livesim2/testpic_2s/Manifest.mpd" doc:"livesim2 MPD URL (path and query)"`
+	NowMS int    `query:"nowMS" default:"-1" doc:"Wall-clock time in ms of the requests. Negative value means now"`
+}
+
+type IntegrityResponse struct {
+	Body IntegrityListing
+}
+
+func createIntegrityHdlr(s *Server) func(ctx context.Context, input *integrityInput) (*IntegrityResponse, error) {
+	return func(ctx context.Context, input *integrityInput) (*IntegrityResponse, error) {
+		il, err := s.integrityListing(input.URL, input.NowMS)
+		if err != nil {
+			return nil, huma.Error400BadRequest(err.Error())
+		}
+		return &IntegrityResponse{Body: *il}, nil
+	}
+}
+
+type explainConfigInput struct {
+	URL   string `query:"url" required:"true" example:"/livesim2/segtimeline_1/tsbd_30/testpic_2s/Manifest.mpd" doc:"livesim2 URL (path and query) to explain"`
+	NowMS int    `query:"nowMS" default:"-1" doc:"Wall-clock time in ms for time-relative parameters. Negative value means now"`
+}
+
+type ExplainConfigResponse struct {
+	Body ConfigExplanation
+}
+
+func createExplainConfigHdlr(s *Server) func(ctx context.Context, input *explainConfigInput) (*ExplainConfigResponse, error) {
+	return func(ctx context.Context, input *explainConfigInput) (*ExplainConfigResponse, error) {
+		ce, err := s.explainConfig(input.URL, input.NowMS)
+		if err != nil {
+			return nil, huma.Error400BadRequest(err.Error())
+		}
+		return &ExplainConfigResponse{Body: *ce}, nil
+	}
+}
+
+type SessionCreateRequest struct {
+	Body struct {
+		Config  map[string]any `json:"config" doc:"ResponseConfig fields to set, e.g. {\"SegTimelineFlag\": true, \"TimeShiftBufferDepthS\": 30}"`
+		TTLS    int            `json:"ttlS,omitempty" minimum:"0" doc:"Session lifetime in seconds (default 24h, max 7 days)"`
+		Record  bool           `json:"record,omitempty" doc:"Record request metadata for export as HAR via /sessions/{id}/har"`
+		Webhook *WebhookSetup  `json:"webhook,omitempty" doc:"Webhook for session created, first_request, deleted and expired events"`
+	}
+}
+
+type SessionInfo struct {
+	ID        string         `json:"id" doc:"Session ID"`
+	URLPrefix string         `json:"urlPrefix" example:"/livesim2/session_0123456789abcdef" doc:"URL prefix to put in front of asset path"`
+	Config    map[string]any `json:"config" doc:"Stored ResponseConfig fields"`
+	Expires   time.Time      `json:"expires" doc:"Session expiry time"`
+	Recording bool           `json:"recording" doc:"Request metadata is recorded"`
+}
+
+type SessionResponse struct {
+	Body SessionInfo
+}
+
+type SessionDeleteResponse struct{}
+
+type sessionIDInput struct {
+	Id string `path:"id" maxLength:"32" example:"0123456789abcdef" doc:"Session ID"`
+}
+
+func newSessionInfo(sess *configSession) (SessionInfo, error) {
+	info := SessionInfo{
+		ID:        sess.id,
+		URLPrefix: "/livesim2/session_" + sess.id,
+		Expires:   sess.expires,
+		Recording: sess.recorder != nil,
+	}
+	err := json.Unmarshal(sess.configJSON(), &info.Config)
+	return info, err
+}
+
+func createSessionHdlr(s *Server) func(ctx context.Context, input *SessionCreateRequest) (*SessionResponse, error) {
+	return func(ctx context.Context, input *SessionCreateRequest) (*SessionResponse, error) {
+		if s.Cfg.Scaled {
+			return nil, huma.Error400BadRequest("sessions are not available in scaled mode")
+		}
+		ttl := defaultSessionTTL
+		if input.Body.TTLS > 0 {
+			ttl = time.Duration(input.Body.TTLS) * time.Second
+		}
+		if ttl > maxSessionTTL {
+			return nil, huma.Error400BadRequest(fmt.Sprintf("ttlS larger than %ds", int(maxSessionTTL.Seconds())))
+		}
+		cfgJSON, err := json.Marshal(input.Body.Config)
+		if err != nil {
+			return nil, huma.Error400BadRequest("bad config", err)
+		}
+		wh, err := newWebhook(input.Body.Webhook)
+		if err != nil {
+			return nil, huma.Error400BadRequest(err.Error())
+		}
+		sess, err := s.sessions.add(cfgJSON, ttl, input.Body.Record, wh, time.Now())
+		if err != nil {
+			return nil, huma.Error400BadRequest(err.Error())
+		}
+		s.saveState()
+		info, err := newSessionInfo(sess)
+		if err != nil {
+			return nil, huma.Error500InternalServerError("session info", err)
+		}
+		return &SessionResponse{Body: info}, nil
+	}
+}
+
+func createGetSessionHdlr(s *Server) func(ctx context.Context, input *sessionIDInput) (*SessionResponse, error) {
+	return func(ctx context.Context, input *sessionIDInput) (*SessionResponse, error) {
+		sess, ok := s.sessions.get(input.Id, time.Now())
+		if !ok {
+			return nil, huma.Error404NotFound(fmt.Sprintf("session %s not found", input.Id))
+		}
+		info, err := newSessionInfo(sess)
+		if err != nil {
+			return nil, huma.Error500InternalServerError("session info", err)
+		}
+		return &SessionResponse{Body: info}, nil
+	}
+}
+
+func createDeleteSessionHdlr(s *Server) func(ctx context.Context, input *sessionIDInput) (*SessionDeleteResponse, error) {
+	return func(ctx context.Context, input *sessionIDInput) (*SessionDeleteResponse, error) {
+		if !s.sessions.remove(input.Id) {
+			return nil, huma.Error404NotFound(fmt.Sprintf("session %s not found", input.Id))
+		}
+		s.saveState()
+		return &SessionDeleteResponse{}, nil
+	}
+}
+
+type SessionBlackoutRequest struct {
+	Id   string `path:"id" maxLength:"32" example:"0123456789abcdef" doc:"Session ID"`
+	Body struct {
+		StartS int      `json:"startS,omitempty" minimum:"0" doc:"Start as wall-clock time in seconds since the Epoch (default now)"`
+		DurS   int      `json:"durS,omitempty" minimum:"0" doc:"Duration in seconds (default until ended)"`
+		Reps   []string `json:"reps,omitempty" doc:"Representations to black out (default all video and audio)"`
+	}
+}
+
+type SessionBlackoutResponse struct {
+	Body Blackout
+}
+
+func createSessionBlackoutHdlr(s *Server) func(ctx context.Context, input *SessionBlackoutRequest) (*SessionBlackoutResponse, error) {
+	return func(ctx context.Context, input *SessionBlackoutRequest) (*SessionBlackoutResponse, error) {
+		now := time.Now()
+		bo := Blackout{StartS: input.Body.StartS, Reps: input.Body.Reps}
+		if bo.StartS == 0 {
+			bo.StartS = int(now.Unix())
+		}
+		if input.Body.DurS > 0 {
+			bo.EndS = bo.StartS + input.Body.DurS
+		}
+		found, err := s.sessions.setConfigField(input.Id, "Blackout", &bo, now)
+		if !found {
+			return nil, huma.Error404NotFound(fmt.Sprintf("session %s not found", input.Id))
+		}
+		if err != nil {
+			return nil, huma.Error400BadRequest(err.Error())
+		}
+		s.saveState()
+		return &SessionBlackoutResponse{Body: bo}, nil
+	}
+}
+
+func createEndSessionBlackoutHdlr(s *Server) func(ctx context.Context, input *sessionIDInput) (*SessionBlackoutResponse, error) {
+	return func(ctx context.Context, input *sessionIDInput) (*SessionBlackoutResponse, error) {
+		now := time.Now()
+		sess, ok := s.sessions.get(input.Id, now)
+		if !ok {
+			return nil, huma.Error404NotFound(fmt.Sprintf("session %s not found", input.Id))
+		}
+		var cfg struct {
+			Blackout *Blackout
+		}
+		if err := json.Unmarshal(sess.configJSON(), &cfg); err != nil || cfg.Blackout == nil {
+			return nil, huma.Error404NotFound(fmt.Sprintf("no blackout in session %s", input.Id))
+		}
+		bo := cfg.Blackout
+		nowS := int(now.Unix())
+		var value any // A blackout that has not started is removed
+		if bo.StartS < nowS && (bo.EndS == 0 || bo.EndS > nowS) {
+			bo.EndS = nowS
+			value = bo
+		} else if bo.StartS < nowS {
+			value = bo // Already ended
+		}
+		if _, err := s.sessions.setConfigField(input.Id, "Blackout", value, now); err != nil {
+			return nil, huma.Error400BadRequest(err.Error())
+		}
+		s.saveState()
+		return &SessionBlackoutResponse{Body: *bo}, nil
+	}
+}
+
+type SessionEventRequest struct {
+	Id   string `path:"id" maxLength:"32" example:"0123456789abcdef" doc:"Session ID"`
+	Body struct {
+		SchemeIdUri string `json:"schemeIdUri" minLength:"1" example:"urn:example:event:2024" doc:"Event scheme"`
+		Value       string `json:"value,omitempty" doc:"Event value"`
+		ID          uint32 `json:"id,omitempty" doc:"Event id (default the start time)"`
+		StartS      int    `json:"startS,omitempty" minimum:"0" doc:"Start as wall-clock time in seconds since the Epoch (default now)"`
+		DurS        int    `json:"durS,omitempty" minimum:"0" doc:"Duration in seconds"`
+		MessageData string `json:"messageData,omitempty" doc:"Event message data"`
+	}
+}
+
+type SessionEventsResponse struct {
+	Body []SessionEvent
+}
+
+// createSessionEventHdlr adds an event to the MPD EventStreams and the video emsg boxes of a session.
+func createSessionEventHdlr(s *Server) func(ctx context.Context, input *SessionEventRequest) (*SessionEventsResponse, error) {
+	return func(ctx context.Context, input *SessionEventRequest) (*SessionEventsResponse, error) {
+		now := time.Now()
+		sess, ok := s.sessions.get(input.Id, now)
+		if !ok {
+			return nil, huma.Error404NotFound(fmt.Sprintf("session %s not found", input.Id))
+		}
+		var cfg struct {
+			Events []SessionEvent
+		}
+		if err := json.Unmarshal(sess.configJSON(), &cfg); err != nil {
+			return nil, huma.Error500InternalServerError("session config", err)
+		}
+		ev := SessionEvent{
+			SchemeIdUri: input.Body.SchemeIdUri,
+			Value:       input.Body.Value,
+			ID:          input.Body.ID,
+			StartS:      input.Body.StartS,
+			DurS:        input.Body.DurS,
+			MessageData: input.Body.MessageData,
+		}
+		if ev.StartS == 0 {
+			ev.StartS = int(now.Unix())
+		}
+		if ev.ID == 0 {
+			ev.ID = uint32(ev.StartS)
+		}
+		events := append(cfg.Events, ev)
+		if err := validateSessionEvents(events); err != nil {
+			return nil, huma.Error400BadRequest(err.Error())
+		}
+		found, err := s.sessions.setConfigField(input.Id, "Events", events, now)
+		if !found {
+			return nil, huma.Error404NotFound(fmt.Sprintf("session %s not found", input.Id))
+		}
+		if err != nil {
+			return nil, huma.Error400BadRequest(err.Error())
+		}
+		s.saveState()
+		return &SessionEventsResponse{Body: events}, nil
+	}
+}
+
+type MPDDiffRequest struct {
+	Body struct {
+		URLA string `json:"urlA,omitempty" doc:"URL of first MPD. A path like /livesim2/... is served internally" example:"/livesim2/testpic_2s/Manifest.mpd?nowMS=100000"`
+		URLB string `json:"urlB,omitempty" doc:"URL of second MPD" example:"/livesim2/segtimeline_1/testpic_2s/Manifest.mpd?nowMS=100000"`
+		MPDA string `json:"mpdA,omitempty" doc:"First MPD as XML (instead of urlA)"`
+		MPDB string `json:"mpdB,omitempty" doc:"Second MPD as XML (instead of urlB)"`
+		All  bool   `json:"all,omitempty" doc:"Include publishTime and SegmentTimeline which normally change between requests"`
+	}
+}
+
+type MPDDiffResponse struct {
+	Body struct {
+		NrChanges int              `json:"nrChanges" doc:"Number of differences"`
+		Changes   []mpddiff.Change `json:"changes" doc:"Differences from first to second MPD"`
+	}
+}
+
+func createMPDDiffHdlr(s *Server) func(ctx context.Context, input *MPDDiffRequest) (*MPDDiffResponse, error) {
+	return func(ctx context.Context, input *MPDDiffRequest) (*MPDDiffResponse, error) {
+		mpdA, err := s.mpdDiffSource(ctx, input.Body.URLA, input.Body.MPDA)
+		if err != nil {
+			return nil, huma.Error400BadRequest(fmt.Sprintf("first MPD: %s", err))
+		}
+		mpdB, err := s.mpdDiffSource(ctx, input.Body.URLB, input.Body.MPDB)
+		if err != nil {
+			return nil, huma.Error400BadRequest(fmt.Sprintf("second MPD: %s", err))
+		}
+		opts := mpddiff.DefaultOptions
+		if input.Body.All {
+			opts = mpddiff.Options{}
+		}
+		changes, err := mpddiff.Diff(mpdA, mpdB, opts)
+		if err != nil {
+			return nil, huma.Error400BadRequest(err.Error())
+		}
+		resp := MPDDiffResponse{}
+		resp.Body.NrChanges = len(changes)
+		resp.Body.Changes = changes
+		if resp.Body.Changes == nil {
+			resp.Body.Changes = []mpddiff.Change{}
+		}
+		return &resp, nil
+	}
+}
+
+type MPDSigningKeysResponse struct {
+	Body struct {
+		Keys []JWK `json:"keys" doc:"Public keys for verifying MPD signatures"`
+	}
+}
+
+func createMPDSigningKeysHdlr(s *Server) func(ctx context.Context, input *struct{}) (*MPDSigningKeysResponse, error) {
+	return func(ctx context.Context, input *struct{}) (*MPDSigningKeysResponse, error) {
+		resp := MPDSigningKeysResponse{}
+		resp.Body.Keys = []JWK{s.mpdSigner.jwk}
+		return &resp, nil
+	}
+}
+
+type MPDVerifyRequest struct {
+	Body struct {
+		MPD       string `json:"mpd" doc:"MPD exactly as received"`
+		Signature string `json:"signature" doc:"Value of the X-MPD-Signature header"`
+	}
+}
+
+type MPDVerifyResponse struct {
+	Body struct {
+		Valid  bool   `json:"valid"`
+		Kid    string `json:"kid" doc:"Key ID of the signing key"`
+		Reason string `json:"reason,omitempty" doc:"Why the signature is not valid"`
+	}
+}
+
+func createMPDVerifyHdlr(s *Server) func(ctx context.Context, input *MPDVerifyRequest) (*MPDVerifyResponse, error) {
+	return func(ctx context.Context, input *MPDVerifyRequest) (*MPDVerifyResponse, error) {
+		resp := MPDVerifyResponse{}
+		resp.Body.Kid = s.mpdSigner.jwk.Kid
+		if err := s.mpdSigner.verify([]byte(input.Body.MPD), input.Body.Signature); err != nil {
+			resp.Body.Reason = err.Error()
+			return &resp, nil
+		}
+		resp.Body.Valid = true
+		return &resp, nil
+	}
+}
+
+type MPDHistoryListResponse struct {
+	Body struct {
+		Size int             `json:"size" doc:"Max number of MPDs kept per key"`
+		Keys []MPDHistoryKey `json:"keys" doc:"Sessions and MPD paths with recorded MPDs"`
+	}
+}
+
+type mpdHistoryKeyInput struct {
+	Key string `query:"key" required:"true" example:"/livesim2/segtimeline_1/testpic_2s/Manifest.mpd" doc:"Session (session_<id>) or MPD URL path"`
+}
+
+type MPDHistoryResponse struct {
+	Body struct {
+		Key       string        `json:"key"`
+		Snapshots []MPDSnapshot `json:"snapshots" doc:"Recorded MPDs, oldest first"`
+	}
+}
+
+type MPDHistoryDeleteResponse struct{}
+
+var errMPDHistoryDisabled = huma.Error404NotFound("MPD history not enabled (use --mpdhistory)")
+
+func createMPDHistoryListHdlr(s *Server) func(ctx context.Context, input *struct{}) (*MPDHistoryListResponse, error) {
+	return func(ctx context.Context, input *struct{}) (*MPDHistoryListResponse, error) {
+		if s.mpdHistory == nil {
+			return nil, errMPDHistoryDisabled
+		}
+		resp := MPDHistoryListResponse{}
+		resp.Body.Size = s.mpdHistory.size
+		resp.Body.Keys = s.mpdHistory.keys()
+		return &resp, nil
+	}
+}
+
+func createMPDHistoryHdlr(s *Server) func(ctx context.Context, input *mpdHistoryKeyInput) (*MPDHistoryResponse, error) {
+	return func(ctx context.Context, input *mpdHistoryKeyInput) (*MPDHistoryResponse, error) {
+		if s.mpdHistory == nil {
+			return nil, errMPDHistoryDisabled
+		}
+		snaps, ok := s.mpdHistory.get(input.Key)
+		if !ok {
+			return nil, huma.Error404NotFound(fmt.Sprintf("no MPD history for %q", input.Key))
+		}
+		resp := MPDHistoryResponse{}
+		resp.Body.Key = input.Key
+		resp.Body.Snapshots = snaps
+		return &resp, nil
+	}
+}
+
+func createDeleteMPDHistoryHdlr(s *Server) func(ctx context.Context, input *mpdHistoryKeyInput) (*MPDHistoryDeleteResponse, error) {
+	return func(ctx context.Context, input *mpdHistoryKeyInput) (*MPDHistoryDeleteResponse, error) {
+		if s.mpdHistory == nil {
+			return nil, errMPDHistoryDisabled
+		}
+		if !s.mpdHistory.remove(input.Key) {
+			return nil, huma.Error404NotFound(fmt.Sprintf("no MPD history for %q", input.Key))
+		}
+		return &MPDHistoryDeleteResponse{}, nil
+	}
+}
+
+type AssetStatsInput struct {
+	Asset       string `query:"asset" example:"testpic_2s" doc:"Asset path. All assets if empty"`
+	ResolutionS int    `query:"resolution" default:"60" minimum:"10" maximum:"3600" multipleOf:"10" doc:"Time series resolution (s)"`
+}
+
+type AssetStatsResponse struct {
+	Body struct {
+		ResolutionS int          `json:"resolution" doc:"Time series resolution (s)"`
+		Assets      []AssetStats `json:"assets"`
+	}
+}
+
+func createAssetStatsHdlr(s *Server) func(ctx context.Context, input *AssetStatsInput) (*AssetStatsResponse, error) {
+	return func(ctx context.Context, input *AssetStatsInput) (*AssetStatsResponse, error) {
+		resp := AssetStatsResponse{}
+		resp.Body.ResolutionS = input.ResolutionS
+		resp.Body.Assets = s.assetStats.stats(time.Now(), input.Asset, input.ResolutionS)
+		return &resp, nil
+	}
+}
+
+type LatencyStatsInput struct {
+	Asset string `query:"asset" example:"testpic_2s" doc:"Asset path. All assets if empty"`
+}
+
+type LatencyStatsResponse struct {
+	Body struct {
+		Assets []LatencyStats `json:"assets"`
+	}
+}
+
+func createLatencyStatsHdlr(s *Server) func(ctx context.Context, input *LatencyStatsInput) (*LatencyStatsResponse, error) {
+	return func(ctx context.Context, input *LatencyStatsInput) (*LatencyStatsResponse, error) {
+		resp := LatencyStatsResponse{}
+		resp.Body.Assets = s.latency.stats(input.Asset)
+		return &resp, nil
+	}
+}
+
+type AssetDetailsInput struct {
+	Name string `path:"name" example:"testpic_2s" doc:"Asset path, with slashes escaped as %2F"`
+}
+
+type AssetDetailsResponse struct {
+	Body AssetDetails
+}
+
+func createAssetDetailsHdlr(s *Server) func(ctx context.Context, input *AssetDetailsInput) (*AssetDetailsResponse, error) {
+	return func(ctx context.Context, input *AssetDetailsInput) (*AssetDetailsResponse, error) {
+		name, err := url.PathUnescape(input.Name)
+		if err != nil {
+			return nil, huma.Error400BadRequest(fmt.Sprintf("bad asset name %q", input.Name))
+		}
+		a, ok := s.assetMgr.getAsset(name)
+		if !ok {
+			return nil, huma.Error404NotFound(fmt.Sprintf("asset %s not found", name))
+		}
+		return &AssetDetailsResponse{Body: a.details()}, nil
+	}
+}
+
+type AssetUploadInput struct {
+	Name    string `path:"name" example:"uploads%2Fmytest" doc:"Asset path below vodroot, with slashes escaped as %2F"`
+	RawBody []byte `contentType:"application/zip" doc:"Zip archive with the MPDs, init segments, and media segments"`
+}
+
+type AssetUploadResponse struct {
+	Body struct {
+		Assets []AssetDetails `json:"assets" doc:"Loaded assets"`
+	}
+}
+
+var errAssetUploadDisabled = huma.Error404NotFound("asset upload not enabled (use --assetupload)")
+
+func createAssetUploadHdlr(s *Server) func(ctx context.Context, input *AssetUploadInput) (*AssetUploadResponse, error) {
+	return func(ctx context.Context, input *AssetUploadInput) (*AssetUploadResponse, error) {
+		if !s.Cfg.AssetUpload {
+			return nil, errAssetUploadDisabled
+		}
+		name, err := url.PathUnescape(input.Name)
+		if err != nil {
+			return nil, huma.Error400BadRequest(fmt.Sprintf("bad asset name %q", input.Name))
+		}
+		assets, err := s.assetMgr.uploadAsset(s.logger, s.Cfg.VodRoot, name, input.RawBody)
+		switch {
+		case errors.Is(err, errAssetExists):
+			return nil, huma.Error409Conflict(err.Error())
+		case errors.Is(err, errBadAssetUpload):
+			return nil, huma.Error400BadRequest(err.Error())
+		case err != nil:
+			return nil, huma.Error500InternalServerError(err.Error())
+		}
+		resp := AssetUploadResponse{}
+		for _, a := range assets {
+			resp.Body.Assets = append(resp.Body.Assets, a.details())
+		}
+		s.logger.Info("Asset uploaded", "name", name, "count", len(assets))
+		return &resp, nil
+	}
+}
+
+type QoEListResponse struct {
+	Body struct {
+		Size int      `json:"size" doc:"Max number of reports kept per MPD path"`
+		Keys []QoEKey `json:"keys" doc:"MPD paths with received reports"`
+	}
+}
+
+type qoeKeyInput struct {
+	Key string `query:"key" required:"true" example:"/livesim2/metrics_1000/testpic_2s/Manifest.mpd" doc:"MPD URL path"`
+}
+
+type QoEReportsResponse struct {
+	Body struct {
+		Key     string      `json:"key"`
+		Reports []QoEReport `json:"reports" doc:"Received reports, oldest first"`
+	}
+}
+
+type QoEDeleteResponse struct{}
+
+var errQoEDisabled = huma.Error404NotFound("QoE reports not enabled (use --qoereports)")
+
+func createQoEListHdlr(s *Server) func(ctx context.Context, input *struct{}) (*QoEListResponse, error) {
+	return func(ctx context.Context, input *struct{}) (*QoEListResponse, error) {
+		if s.qoe == nil {
+			return nil, errQoEDisabled
+		}
+		resp := QoEListResponse{}
+		resp.Body.Size = s.qoe.size
+		resp.Body.Keys = s.qoe.list()
+		return &resp, nil
+	}
+}
+
+func createQoEReportsHdlr(s *Server) func(ctx context.Context, input *qoeKeyInput) (*QoEReportsResponse, error) {
+	return func(ctx context.Context, input *qoeKeyInput) (*QoEReportsResponse, error) {
+		if s.qoe == nil {
+			return nil, errQoEDisabled
+		}
+		reports, ok := s.qoe.get(input.Key)
+		if !ok {
+			return nil, huma.Error404NotFound(fmt.Sprintf("no QoE reports for %q", input.Key))
+		}
+		resp := QoEReportsResponse{}
+		resp.Body.Key = input.Key
+		resp.Body.Reports = reports
+		return &resp, nil
+	}
+}
+
+func createDeleteQoEReportsHdlr(s *Server) func(ctx context.Context, input *qoeKeyInput) (*QoEDeleteResponse, error) {
+	return func(ctx context.Context, input *qoeKeyInput) (*QoEDeleteResponse, error) {
+		if s.qoe == nil {
+			return nil, errQoEDisabled
+		}
+		if !s.qoe.remove(input.Key) {
+			return nil, huma.Error404NotFound(fmt.Sprintf("no QoE reports for %q", input.Key))
+		}
+		return &QoEDeleteResponse{}, nil
+	}
+}
+
+type CMCDListResponse struct {
+	Body struct {
+		Size     int           `json:"size" doc:"Max number of sessions kept"`
+		Sessions []CMCDSession `json:"sessions" doc:"Sessions sorted by session ID and content ID"`
+	}
+}
+
+type cmcdSessionInput struct {
+	Sid string `path:"sid" doc:"CMCD session ID"`
+}
+
+type CMCDSessionResponse struct {
+	Body struct {
+		Sessions []CMCDSession `json:"sessions" doc:"Sessions with the session ID, one per content ID"`
+	}
+}
+
+type CMCDDeleteResponse struct{}
+
+var errCMCDDisabled = huma.Error404NotFound("CMCD sessions not enabled (use --cmcdsessions)")
+
+func createCMCDListHdlr(s *Server) func(ctx context.Context, input *struct{}) (*CMCDListResponse, error) {
+	return func(ctx context.Context, input *struct{}) (*CMCDListResponse, error) {
+		if s.cmcd == nil {
+			return nil, errCMCDDisabled
+		}
+		resp := CMCDListResponse{}
+		resp.Body.Size = s.cmcd.size
+		resp.Body.Sessions = s.cmcd.list("")
+		return &resp, nil
+	}
+}
+
+func createCMCDSessionHdlr(s *Server) func(ctx context.Context, input *cmcdSessionInput) (*CMCDSessionResponse, error) {
+	return func(ctx context.Context, input *cmcdSessionInput) (*CMCDSessionResponse, error) {
+		if s.cmcd == nil {
+			return nil, errCMCDDisabled
+		}
+		sessions := s.cmcd.list(input.Sid)
+		if len(sessions) == 0 {
+			return nil, huma.Error404NotFound(fmt.Sprintf("CMCD session %s not found", input.Sid))
+		}
+		resp := CMCDSessionResponse{}
+		resp.Body.Sessions = sessions
+		return &resp, nil
+	}
+}
+
+func createDeleteCMCDSessionHdlr(s *Server) func(ctx context.Context, input *cmcdSessionInput) (*CMCDDeleteResponse, error) {
+	return func(ctx context.Context, input *cmcdSessionInput) (*CMCDDeleteResponse, error) {
+		if s.cmcd == nil {
+			return nil, errCMCDDisabled
+		}
+		if !s.cmcd.remove(input.Sid) {
+			return nil, huma.Error404NotFound(fmt.Sprintf("CMCD session %s not found", input.Sid))
+		}
+		return &CMCDDeleteResponse{}, nil
+	}
+}
+
+type SANDListResponse struct {
+	Body struct {
+		Size    int          `json:"size" doc:"Max number of status messages kept per client"`
+		Clients []SANDClient `json:"clients" doc:"Clients that sent SAND status messages"`
+	}
+}
+
+func createSANDListHdlr(s *Server) func(ctx context.Context, input *struct{}) (*SANDListResponse, error) {
+	return func(ctx context.Context, input *struct{}) (*SANDListResponse, error) {
+		if s.sand == nil {
+			return nil, huma.Error404NotFound("SAND not enabled (use --sand)")
+		}
+		resp := SANDListResponse{}
+		resp.Body.Size = s.sand.size
+		resp.Body.Clients = s.sand.list()
+		return &resp, nil
+	}
+}
+
+type SessionHARResponse struct {
+	ContentDisposition string `header:"Content-Disposition"`
+	Body               HAR
+}
+
+func createSessionHARHdlr(s *Server) func(ctx context.Context, input *sessionIDInput) (*SessionHARResponse, error) {
+	return func(ctx context.Context, input *sessionIDInput) (*SessionHARResponse, error) {
+		sess, ok := s.sessions.get(input.Id, time.Now())
+		if !ok {
+			return nil, huma.Error404NotFound(fmt.Sprintf("session %s not found", input.Id))
+		}
+		if sess.recorder == nil {
+			return nil, huma.Error404NotFound(fmt.Sprintf("session %s is not recorded", input.Id))
+		}
+		return &SessionHARResponse{
+			ContentDisposition: fmt.Sprintf("attachment; filename=\"session_%s.har\"", sess.id),
+			Body:               sess.recorder.har(sess.configJSON()),
+		}, nil
+	}
+}
+
+type RecordingCreateRequest struct {
+	Body RecordingSetup
+}
+
+type RecordingResponse struct {
+	Body RecordingInfo
+}
+
+func createRecordingHdlr(s *Server) func(ctx context.Context, input *RecordingCreateRequest) (*RecordingResponse, error) {
+	return func(ctx context.Context, input *RecordingCreateRequest) (*RecordingResponse, error) {
+		info, err := s.recordLive(ctx, input.Body)
+		if err != nil {
+			return nil, huma.Error400BadRequest(err.Error())
+		}
+		return &RecordingResponse{Body: *info}, nil
+	}
+}
+
+type BookmarkCreateRequest struct {
+	Body BookmarkSetup
+}
+
+type BookmarkResponse struct {
+	Body Bookmark
+}
+
+type BookmarkListResponse struct {
+	Body []Bookmark
+}
+
+type BookmarkDeleteResponse struct{}
+
+type BookmarkMPDResponse struct {
+	Status   int
+	Location string `header:"Location"`
+}
+
+type bookmarkNameInput struct {
+	Name string `path:"name" maxLength:"64" example:"bug-1234" doc:"Bookmark name"`
+}
+
+func createBookmarkHdlr(s *Server) func(ctx context.Context, input *BookmarkCreateRequest) (*BookmarkResponse, error) {
+	return func(ctx context.Context, input *BookmarkCreateRequest) (*BookmarkResponse, error) {
+		b, err := s.newBookmark(input.Body)
+		if err != nil {
+			return nil, huma.Error400BadRequest(err.Error())
+		}
+		if err := s.bookmarks.add(b); err != nil {
+			return nil, huma.Error409Conflict(fmt.Sprintf("bookmark %s exists", b.Name))
+		}
+		s.saveState()
+		b.CatchupURL = b.catchupURL(int(time.Now().UnixMilli()))
+		return &BookmarkResponse{Body: b}, nil
+	}
+}
+
+func createListBookmarksHdlr(s *Server) func(ctx context.Context, input *struct{}) (*BookmarkListResponse, error) {
+	return func(ctx context.Context, input *struct{}) (*BookmarkListResponse, error) {
+		nowMS := int(time.Now().UnixMilli())
+		bms := s.bookmarks.list()
+		for i := range bms {
+			bms[i].CatchupURL = bms[i].catchupURL(nowMS)
+		}
+		return &BookmarkListResponse{Body: bms}, nil
+	}
+}
+
+func createGetBookmarkHdlr(s *Server) func(ctx context.Context, input *bookmarkNameInput) (*BookmarkResponse, error) {
+	return func(ctx context.Context, input *bookmarkNameInput) (*BookmarkResponse, error) {
+		b, ok := s.bookmarks.get(input.Name)
+		if !ok {
+			return nil, huma.Error404NotFound(fmt.Sprintf("bookmark %s not found", input.Name))
+		}
+		b.CatchupURL = b.catchupURL(int(time.Now().UnixMilli()))
+		return &BookmarkResponse{Body: b}, nil
+	}
+}
+
+func createBookmarkMPDHdlr(s *Server) func(ctx context.Context, input *bookmarkNameInput) (*BookmarkMPDResponse, error) {
+	return func(ctx context.Context, input *bookmarkNameInput) (*BookmarkMPDResponse, error) {
+		b, ok := s.bookmarks.get(input.Name)
+		if !ok {
+			return nil, huma.Error404NotFound(fmt.Sprintf("bookmark %s not found", input.Name))
+		}
+		catchup := b.catchupURL(int(time.Now().UnixMilli()))
+		if catchup == "" {
+			return nil, huma.Error404NotFound(fmt.Sprintf("bookmark %s is not within a time-shift buffer", input.Name))
+		}
+		return &BookmarkMPDResponse{Status: http.StatusFound, Location: catchup}, nil
+	}
+}
+
+func createDeleteBookmarkHdlr(s *Server) func(ctx context.Context, input *bookmarkNameInput) (*BookmarkDeleteResponse, error) {
+	return func(ctx context.Context, input *bookmarkNameInput) (*BookmarkDeleteResponse, error) {
+		if !s.bookmarks.delete(input.Name) {
+			return nil, huma.Error404NotFound(fmt.Sprintf("bookmark %s not found", input.Name))
+		}
+		s.saveState()
+		return &BookmarkDeleteResponse{}, nil
+	}
+}
+
+type epgInput struct {
+	NowMS   int    `query:"nowMS" default:"-1" doc:"Wall-clock time in ms. Negative value means now"`
+	PastS   int    `query:"pastS" default:"3600" minimum:"0" maximum:"604800" doc:"Seconds before now to include"`
+	FutureS int    `query:"futureS" default:"21600" minimum:"0" maximum:"604800" doc:"Seconds after now to include"`
+	StartS  int    `query:"startS" default:"0" minimum:"0" doc:"availabilityStartTime of the channels as set by the start URL parameter"`
+	Channel string `query:"channel" doc:"Only include this channel"`
+}
+
+type EPGResponse struct {
+	Body struct {
+		From     time.Time    `json:"from"`
+		To       time.Time    `json:"to"`
+		Channels []EPGChannel `json:"channels"`
+	}
+}
+
+type XMLTVResponse struct {
+	ContentType string `header:"Content-Type"`
+	Body        []byte
+}
+
+func (s *Server) epgForInput(input *epgInput) (fromMS, toMS int, channels []EPGChannel, err error) {
+	nowMS := input.NowMS
+	if nowMS < 0 {
+		nowMS = int(time.Now().UnixMilli())
+	}
+	fromMS, toMS = nowMS-input.PastS*1000, nowMS+input.FutureS*1000
+	channels, err = s.epg(fromMS, toMS, input.StartS*1000)
+	if err != nil {
+		return 0, 0, nil, huma.Error400BadRequest(err.Error())
+	}
+	if input.Channel != "" {
+		for _, ec := range channels {
+			if ec.Name == input.Channel {
+				return fromMS, toMS, []EPGChannel{ec}, nil
+			}
+		}
+		return 0, 0, nil, huma.Error404NotFound(fmt.Sprintf("channel %s not found", input.Channel))
+	}
+	return fromMS, toMS, channels, nil
+}
+
+func createEPGHdlr(s *Server) func(ctx context.Context, input *epgInput) (*EPGResponse, error) {
+	return func(ctx context.Context, input *epgInput) (*EPGResponse, error) {
+		fromMS, toMS, channels, err := s.epgForInput(input)
+		if err != nil {
+			return nil, err
+		}
+		resp := EPGResponse{}
+		resp.Body.From = time.UnixMilli(int64(fromMS)).UTC()
+		resp.Body.To = time.UnixMilli(int64(toMS)).UTC()
+		resp.Body.Channels = channels
+		return &resp, nil
+	}
+}
+
+func createXMLTVHdlr(s *Server) func(ctx context.Context, input *epgInput) (*XMLTVResponse, error) {
+	return func(ctx context.Context, input *epgInput) (*XMLTVResponse, error) {
+		_, _, channels, err := s.epgForInput(input)
+		if err != nil {
+			return nil, err
+		}
+		data, err := xmltv(channels)
+		if err != nil {
+			return nil, huma.Error500InternalServerError(err.Error())
+		}
+		return &XMLTVResponse{ContentType: "application/xml", Body: data}, nil
+	}
+}
+
+type proxyCacheInput struct {
+	Name string `path:"name" doc:"Name of the proxy"`
+}
+
+type ProxyCacheResponse struct {
+	Body []ProxyCacheEntry
+}
+
+type proxyCachePurgeInput struct {
+	Name   string `path:"name" doc:"Name of the proxy"`
+	Prefix string `query:"prefix" doc:"Only purge entries for paths starting with this prefix, relative to the proxy origin"`
+}
+
+type ProxyCachePurgeResponse struct {
+	Body struct {
+		Purged int `json:"purged" doc:"Number of purged entries"`
+	}
+}
+
+// cachingProxy returns the named proxy if it has a cache.
+func (s *Server) cachingProxy(name string) (*proxy, error) {
+	px, ok := s.proxies[name]
+	if !ok {
+		return nil, huma.Error404NotFound(fmt.Sprintf("proxy %s not found", name))
+	}
+	if px.cache == nil {
+		return nil, huma.Error404NotFound(fmt.Sprintf("proxy %s has no cache", name))
+	}
+	return px, nil
+}
+
+func createProxyCacheHdlr(s *Server) func(ctx context.Context, input *proxyCacheInput) (*ProxyCacheResponse, error) {
+	return func(ctx context.Context, input *proxyCacheInput) (*ProxyCacheResponse, error) {
+		px, err := s.cachingProxy(input.Name)
+		if err != nil {
+			return nil, err
+		}
+		return &ProxyCacheResponse{Body: px.cache.list()}, nil
+	}
+}
+
+func createPurgeProxyCacheHdlr(s *Server) func(ctx context.Context, input *proxyCachePurgeInput) (*ProxyCachePurgeResponse, error) {
+	return func(ctx context.Context, input *proxyCachePurgeInput) (*ProxyCachePurgeResponse, error) {
+		px, err := s.cachingProxy(input.Name)
+		if err != nil {
+			return nil, err
+		}
+		resp := ProxyCachePurgeResponse{}
+		resp.Body.Purged = px.cache.purge(px.upstreamURL(strings.TrimPrefix(input.Prefix, "/"), ""))
+		return &resp, nil
+	}
+}
+
+func createRouteAPI(s *Server) func(r chi.Router) {
+	return func(r chi.Router) {
+		config := huma.DefaultConfig("Livesim2 API for sessions", "1.0.0")
+		config.Servers = []*huma.Server{
+			{URL: "/api"},
+		}
+		config.Info.Description = `The first use case is for generating CMAF ingest streams which are
+		sent to a specified URL. These streams can be used to test CMAF ingest receivers.`
+
+		api := humachi.New(r, config)
+		documentRootRoutes(api)
+		r.Get("/swagger", swaggerUIHandlerFunc)
+
+		// Register POST /cmaf-ingests that creates a new CMAF-Ingest source
+		huma.Register(api, huma.Operation{
+			OperationID:   "create-cmaf-ingest",
+			Method:        http.MethodPost,
+			Path:          "/cmaf-ingests",
+			Summary:       "Create a CMAF ingest stream",
+			Tags:          []string{"CMAF-ingest"},
+			DefaultStatus: http.StatusCreated,
+			Errors:        []int{404, 409, 410},
+		}, createCmafIngesterHdlr(s))
+
+		// Register POST /cmaf-ingests/pair that creates a main and backup CMAF-Ingest source
+		huma.Register(api, huma.Operation{
+			OperationID:   "create-cmaf-ingest-pair",
+			Method:        http.MethodPost,
+			Path:          "/cmaf-ingests/pair",
+			Summary:       "Create a synchronized main and backup CMAF ingest stream pair",
+			Description:   "Both ingesters start at the same time, and the backup pushes every segment offsetMS later. Each gets its own ID.",
+			Tags:          []string{"CMAF-ingest"},
+			DefaultStatus: http.StatusCreated,
+			Errors:        []int{400},
+		}, createCmafIngesterPairHdlr(s))
+
+		// Register GET /cmaf-ingests/{id}
+		huma.Register(api, huma.Operation{
+			OperationID: "get-cmaf-ingest",
+			Method:      http.MethodGet,
+			Path:        "/cmaf-ingests/{id}",
+			Summary:     "Get information about a CMAF ingest stream",
+			Description: "Get information about CMAF ingest stream with the given ID.",
+			Tags:        []string{"CMAF-ingest"},
+			Errors:      []int{404, 410},
+		}, createGetCmafIngesterInfoHdlr(s))
+
+		// Register GET /cmaf-ingests/{id}/step
+		huma.Register(api, huma.Operation{
+			OperationID: "step-cmaf-ingest",
+			Method:      http.MethodGet,
+			Path:        "/cmaf-ingests/{id}/step",
+			Summary:     "Step a CMAF ingest stream one step (for testing)",
+			Description: "In testing mode (triggered by setting timeNowMS in creation), send the next segment of all tracks for the given stream ID.",
+			Tags:        []string{"CMAF-ingest"},
+			Errors:      []int{404, 410},
+		}, createStepCmafIngesterHdlr(s))
+
+		// Register DELETE /cmaf-ingests/{id}
+		huma.Register(api, huma.Operation{
+			OperationID: "delete-cmaf-ingest",
+			Method:      http.MethodDelete,
+			Path:        "/cmaf-ingests/{id}",
+			Summary:     "Stop and delete a CMAF ingest stream",
+			Description: "Stop a CMAF request and get back a report.",
+			Tags:        []string{"CMAF-ingest"},
+			Errors:      []int{404, 410},
+		}, createDeleteCmafIngesterHdlr(s))
+
+		// Register GET /inspect
+		huma.Register(api, huma.Operation{
+			OperationID: "inspect-url",
+			Method:      http.MethodGet,
+			Path:        "/inspect",
+			Summary:     "Explain the response to a livesim2 URL at a given time",
+			Description: "Resolve the URL configuration, asset, availability window and segment mapping for the URL at nowMS, and explain non-200 responses.",
+			Tags:        []string{"Debug"},
+			Errors:      []int{400},
+		}, createInspectHdlr(s))
+
+		// Register GET /integrity
+		huma.Register(api, huma.Operation{
+			OperationID: "integrity",
+			Method:      http.MethodGet,
+			Path:        "/integrity",
+			Summary:     "List SHA-256 digests of an MPD and the segments in its window",
+			Description: "Request the MPD at nowMS and all init and media segments in its time-shift window, and list status, size, and SHA-256 for each, so that caches and recordings can be verified.",
+			Tags:        []string{"Debug"},
+			Errors:      []int{400},
+		}, createIntegrityHdlr(s))
+
+		// Register GET /explain-config
+		huma.Register(api, huma.Operation{
+			OperationID: "explain-config",
+			Method:      http.MethodGet,
+			Path:        "/explain-config",
+			Summary:     "Show how the URL parameters of a livesim2 URL are interpreted",
+			Description: "Return the URL parameters and the fully resolved response configuration as JSON, including defaults and unset fields.",
+			Tags:        []string{"Debug"},
+			Errors:      []int{400},
+		}, createExplainConfigHdlr(s))
+
+		// Register GET /mpd-signing/keys
+		huma.Register(api, huma.Operation{
+			OperationID: "mpd-signing-keys",
+			Method:      http.MethodGet,
+			Path:        "/mpd-signing/keys",
+			Summary:     "Public keys for MPD signatures",
+			Description: "Return the public key used for mpdsign_jws MPD signatures as a JSON Web Key Set.",
+			Tags:        []string{"Debug"},
+		}, createMPDSigningKeysHdlr(s))
+
+		// Register POST /mpd-signing/verify
+		huma.Register(api, huma.Operation{
+			OperationID: "mpd-signing-verify",
+			Method:      http.MethodPost,
+			Path:        "/mpd-signing/verify",
+			Summary:     "Verify an MPD signature",
+			Description: "Check that a detached JWS from the X-MPD-Signature header matches the MPD bytes and the server key.",
+			Tags:        []string{"Debug"},
+		}, createMPDVerifyHdlr(s))
+
+		// Register POST /mpddiff
+		huma.Register(api, huma.Operation{
+			OperationID: "mpd-diff",
+			Method:      http.MethodPost,
+			Path:        "/mpddiff",
+			Summary:     "Semantic diff of two MPDs",
+			Description: "Compare two MPDs, given as URLs or XML, element by element and list added, removed, and changed elements and attributes.",
+			Tags:        []string{"Debug"},
+			Errors:      []int{400},
+		}, createMPDDiffHdlr(s))
+
+		// Register GET /mpd-history
+		huma.Register(api, huma.Operation{
+			OperationID: "list-mpd-history",
+			Method:      http.MethodGet,
+			Path:        "/mpd-history",
+			Summary:     "List sessions and MPD paths with recorded MPDs",
+			Tags:        []string{"Debug"},
+			Errors:      []int{404},
+		}, createMPDHistoryListHdlr(s))
+
+		// Register GET /mpd-history/snapshots
+		huma.Register(api, huma.Operation{
+			OperationID: "get-mpd-history",
+			Method:      http.MethodGet,
+			Path:        "/mpd-history/snapshots",
+			Summary:     "Get the recorded MPDs for a session or MPD path",
+			Description: "Return the last generated MPDs, oldest first, to inspect which sequence of manifests was served.",
+			Tags:        []string{"Debug"},
+			Errors:      []int{404},
+		}, createMPDHistoryHdlr(s))
+
+		// Register DELETE /mpd-history/snapshots
+		huma.Register(api, huma.Operation{
+			OperationID:   "delete-mpd-history",
+			Method:        http.MethodDelete,
+			Path:          "/mpd-history/snapshots",
+			Summary:       "Delete the recorded MPDs for a session or MPD path",
+			Tags:          []string{"Debug"},
+			DefaultStatus: http.StatusNoContent,
+			Errors:        []int{404},
+		}, createDeleteMPDHistoryHdlr(s))
+
+		// Register GET /stats/assets
+		huma.Register(api, huma.Operation{
+			OperationID: "get-asset-stats",
+			Method:      http.MethodGet,
+			Path:        "/stats/assets",
+			Summary:     "Get request statistics per asset and representation",
+			Description: "Request counts, error counts, and served bytes since start and as a time series for the last hour.",
+			Tags:        []string{"Debug"},
+		}, createAssetStatsHdlr(s))
+
+		// Register GET /stats/latency
+		huma.Register(api, huma.Operation{
+			OperationID: "get-latency-stats",
+			Method:      http.MethodGet,
+			Path:        "/stats/latency",
+			Summary:     "Get end-to-end latency distributions per asset",
+			Description: "Latencies computed from player reports to " + latencyProbePath + " for correlation IDs of the latencyprobe URL parameter.",
+			Tags:        []string{"Debug"},
+		}, createLatencyStatsHdlr(s))
+
+		// Register GET /assets/{name}
+		huma.Register(api, huma.Operation{
+			OperationID: "get-asset-details",
+			Method:      http.MethodGet,
+			Path:        "/assets/{name}",
+			Summary:     "Get the tracks and supported modes of an asset",
+			Description: "Codecs, resolutions, bitrates, segment duration statistics, languages, and supported livesim2 modes.",
+			Tags:        []string{"Assets"},
+			Errors:      []int{400, 404},
+		}, createAssetDetailsHdlr(s))
+
+		// Register PUT /assets/{name}
+		huma.Register(api, huma.Operation{
+			OperationID:   "upload-asset",
+			Method:        http.MethodPut,
+			Path:          "/assets/{name}",
+			Summary:       "Upload a zipped asset",
+			Description:   "Unpacks a zip archive to the directory name in vodroot and loads its MPDs as new assets. Requires --assetupload.",
+			Tags:          []string{"Assets"},
+			DefaultStatus: http.StatusCreated,
+			MaxBodyBytes:  maxAssetUploadBytes,
+			Errors:        []int{400, 404, 409, 413},
+		}, createAssetUploadHdlr(s))
+
+		// Register GET /qoe-reports
+		huma.Register(api, huma.Operation{
+			OperationID: "list-qoe-reports",
+			Method:      http.MethodGet,
+			Path:        "/qoe-reports",
+			Summary:     "List MPD paths with received DASH metrics reports",
+			Tags:        []string{"Debug"},
+			Errors:      []int{404},
+		}, createQoEListHdlr(s))
+
+		// Register GET /qoe-reports/reports
+		huma.Register(api, huma.Operation{
+			OperationID: "get-qoe-reports",
+			Method:      http.MethodGet,
+			Path:        "/qoe-reports/reports",
+			Summary:     "Get the DASH metrics reports for an MPD path",
+			Description: "Reports are sent by players to the reporting URL signaled in the MPD with the metrics URL parameter.",
+			Tags:        []string{"Debug"},
+			Errors:      []int{404},
+		}, createQoEReportsHdlr(s))
+
+		// Register DELETE /qoe-reports/reports
+		huma.Register(api, huma.Operation{
+			OperationID:   "delete-qoe-reports",
+			Method:        http.MethodDelete,
+			Path:          "/qoe-reports/reports",
+			Summary:       "Delete the DASH metrics reports for an MPD path",
+			Tags:          []string{"Debug"},
+			DefaultStatus: http.StatusNoContent,
+			Errors:        []int{404},
+		}, createDeleteQoEReportsHdlr(s))
+
+		// Register GET /cmcd-sessions
+		huma.Register(api, huma.Operation{
+			OperationID: "list-cmcd-sessions",
+			Method:      http.MethodGet,
+			Path:        "/cmcd-sessions",
+			Summary:     "List QoS summaries of CMCD sessions",
+			Description: "Request metrics of media requests are aggregated per CMCD session ID (sid) and content ID (cid), sent as CMCD query parameter or headers.",
+			Tags:        []string{"Debug"},
+			Errors:      []int{404},
+		}, createCMCDListHdlr(s))
+
+		// Register GET /cmcd-sessions/{sid}
+		huma.Register(api, huma.Operation{
+			OperationID: "get-cmcd-session",
+			Method:      http.MethodGet,
+			Path:        "/cmcd-sessions/{sid}",
+			Summary:     "Get the QoS summaries of a CMCD session",
+			Tags:        []string{"Debug"},
+			Errors:      []int{404},
+		}, createCMCDSessionHdlr(s))
+
+		// Register DELETE /cmcd-sessions/{sid}
+		huma.Register(api, huma.Operation{
+			OperationID:   "delete-cmcd-session",
+			Method:        http.MethodDelete,
+			Path:          "/cmcd-sessions/{sid}",
+			Summary:       "Delete the metrics of a CMCD session",
+			Tags:          []string{"Debug"},
+			DefaultStatus: http.StatusNoContent,
+			Errors:        []int{404},
+		}, createDeleteCMCDSessionHdlr(s))
+
+		// Register GET /sand
+		huma.Register(api, huma.Operation{
+			OperationID: "list-sand",
+			Method:      http.MethodGet,
+			Path:        "/sand",
+			Summary:     "List SAND status messages received by the /sand DANE endpoint",
+			Tags:        []string{"Debug"},
+			Errors:      []int{404},
+		}, createSANDListHdlr(s))
+
+		// Register POST /sessions
+		huma.Register(api, huma.Operation{
+			OperationID:   "create-session",
+			Method:        http.MethodPost,
+			Path:          "/sessions",
+			Summary:       "Create a configuration session",
+			Description:   "Store a ResponseConfig to be used by URLs starting with the returned urlPrefix, instead of long URL parameter paths.",
+			Tags:          []string{"Sessions"},
+			DefaultStatus: http.StatusCreated,
+			Errors:        []int{400},
+		}, createSessionHdlr(s))
+
+		// Register GET /sessions/{id}
+		huma.Register(api, huma.Operation{
+			OperationID: "get-session",
+			Method:      http.MethodGet,
+			Path:        "/sessions/{id}",
+			Summary:     "Get a configuration session",
+			Tags:        []string{"Sessions"},
+			Errors:      []int{404},
+		}, createGetSessionHdlr(s))
+
+		// Register GET /sessions/{id}/har
+		huma.Register(api, huma.Operation{
+			OperationID: "get-session-har",
+			Method:      http.MethodGet,
+			Path:        "/sessions/{id}/har",
+			Summary:     "Export recorded requests of a session as HAR",
+			Description: "Return timing, sizes, status, and key headers of all requests using the session, as an HTTP Archive (HAR 1.2). The session must be created with record set.",
+			Tags:        []string{"Sessions"},
+			Errors:      []int{404},
+		}, createSessionHARHdlr(s))
+
+		// Register POST /sessions/{id}/blackout
+		huma.Register(api, huma.Operation{
+			OperationID: "start-session-blackout",
+			Method:      http.MethodPost,
+			Path:        "/sessions/{id}/blackout",
+			Summary:     "Start or schedule a blackout in a session",
+			Description: "Replace the selected representations by a slate, with start and end signaled by an MPD EventStream and emsg boxes in the video segments.",
+			Tags:        []string{"Sessions"},
+			Errors:      []int{400, 404},
+		}, createSessionBlackoutHdlr(s))
+
+		// Register DELETE /sessions/{id}/blackout
+		huma.Register(api, huma.Operation{
+			OperationID: "end-session-blackout",
+			Method:      http.MethodDelete,
+			Path:        "/sessions/{id}/blackout",
+			Summary:     "End an ongoing blackout now, or cancel a scheduled one",
+			Tags:        []string{"Sessions"},
+			Errors:      []int{404},
+		}, createEndSessionBlackoutHdlr(s))
+
+		// Register POST /sessions/{id}/events
+		huma.Register(api, huma.Operation{
+			OperationID:   "inject-session-event",
+			Method:        http.MethodPost,
+			Path:          "/sessions/{id}/events",
+			Summary:       "Inject an event into a session",
+			Description:   "Add an event signaled by an MPD EventStream and emsg boxes in the video segments. Returns all events of the session.",
+			Tags:          []string{"Sessions"},
+			DefaultStatus: http.StatusCreated,
+			Errors:        []int{400, 404},
+		}, createSessionEventHdlr(s))
+
+		// Register DELETE /sessions/{id}
+		huma.Register(api, huma.Operation{
+			OperationID:   "delete-session",
+			Method:        http.MethodDelete,
+			Path:          "/sessions/{id}",
+			Summary:       "Delete a configuration session",
+			Tags:          []string{"Sessions"},
+			DefaultStatus: http.StatusNoContent,
+			Errors:        []int{404},
+		}, createDeleteSessionHdlr(s))
+
+		// Register POST /recordings
+		huma.Register(api, huma.Operation{
+			OperationID: "create-recording",
+			Method:      http.MethodPost,
+			Path:        "/recordings",
+			Summary:     "Record a window of a live stream as VoD",
+			Description: "Store the MPD and segments of a live window in the record directory as a static MPD with timestamps starting at 0.",
+			Tags:        []string{"Recordings"},
+			Errors:      []int{400},
+		}, createRecordingHdlr(s))
+
+		// Register POST /bookmarks
+		huma.Register(api, huma.Operation{
+			OperationID: "create-bookmark",
+			Method:      http.MethodPost,
+			Path:        "/bookmarks",
+			Summary:     "Bookmark a live moment",
+			Description: "Store the wall-clock time and the latest segment numbers of all representations of a live MPD URL.",
+			Tags:        []string{"Bookmarks"},
+			Errors:      []int{400, 409},
+		}, createBookmarkHdlr(s))
+
+		// Register GET /bookmarks
+		huma.Register(api, huma.Operation{
+			OperationID: "list-bookmarks",
+			Method:      http.MethodGet,
+			Path:        "/bookmarks",
+			Summary:     "List bookmarks",
+			Tags:        []string{"Bookmarks"},
+		}, createListBookmarksHdlr(s))
+
+		// Register GET /bookmarks/{name}
+		huma.Register(api, huma.Operation{
+			OperationID: "get-bookmark",
+			Method:      http.MethodGet,
+			Path:        "/bookmarks/{name}",
+			Summary:     "Get a bookmark with its current catch-up URL",
+			Tags:        []string{"Bookmarks"},
+			Errors:      []int{404},
+		}, createGetBookmarkHdlr(s))
+
+		// Register GET /bookmarks/{name}/mpd
+		huma.Register(api, huma.Operation{
+			OperationID:   "get-bookmark-mpd",
+			Method:        http.MethodGet,
+			Path:          "/bookmarks/{name}/mpd",
+			Summary:       "Redirect to a catch-up MPD anchored at a bookmark",
+			Tags:          []string{"Bookmarks"},
+			DefaultStatus: http.StatusFound,
+			Errors:        []int{404},
+		}, createBookmarkMPDHdlr(s))
+
+		// Register DELETE /bookmarks/{name}
+		huma.Register(api, huma.Operation{
+			OperationID:   "delete-bookmark",
+			Method:        http.MethodDelete,
+			Path:          "/bookmarks/{name}",
+			Summary:       "Delete a bookmark",
+			Tags:          []string{"Bookmarks"},
+			DefaultStatus: http.StatusNoContent,
+			Errors:        []int{404},
+		}, createDeleteBookmarkHdlr(s))
+
+		// Register GET /epg
+		huma.Register(api, huma.Operation{
+			OperationID: "get-epg",
+			Method:      http.MethodGet,
+			Path:        "/epg",
+			Summary:     "Get the schedule of the channels",
+			Description: "Return the programmes (item occurrences) of the configured channels overlapping an interval around now.",
+			Tags:        []string{"Channels"},
+			Errors:      []int{400, 404},
+		}, createEPGHdlr(s))
+
+		// Register GET /epg/xmltv
+		huma.Register(api, huma.Operation{
+			OperationID: "get-epg-xmltv",
+			Method:      http.MethodGet,
+			Path:        "/epg/xmltv",
+			Summary:     "Get the schedule of the channels as XMLTV",
+			Tags:        []string{"Channels"},
+			Errors:      []int{400, 404},
+		}, createXMLTVHdlr(s))
+
+		// Register GET /experiments
+		huma.Register(api, huma.Operation{
+			OperationID: "list-experiments",
+			Method:      http.MethodGet,
+			Path:        "/experiments",
+			Summary:     "List A/B experiments",
+			Description: "Return the configured experiments with their variants, weights, and configuration overlays.",
+			Tags:        []string{"Experiments"},
+		}, createExperimentsHdlr(s))
+
+		// Register GET /experiments/{name}/assignment
+		huma.Register(api, huma.Operation{
+			OperationID: "get-experiment-assignment",
+			Method:      http.MethodGet,
+			Path:        "/experiments/{name}/assignment",
+			Summary:     "Get the variant assigned to a session",
+			Description: "Return the variant of the experiment that the ab_<name> URL parameter selects for the session ID.",
+			Tags:        []string{"Experiments"},
+			Errors:      []int{404},
+		}, createAssignmentHdlr(s))
+
+		// Register GET /proxies/{name}/cache
+		huma.Register(api, huma.Operation{
+			OperationID: "list-proxy-cache",
+			Method:      http.MethodGet,
+			Path:        "/proxies/{name}/cache",
+			Summary:     "List the cache entries of a proxy",
+			Description: "Return the upstream responses in the origin shield cache of the proxy with their age, TTL, and state.",
+			Tags:        []string{"Proxies"},
+			Errors:      []int{404},
+		}, createProxyCacheHdlr(s))
+
+		// Register DELETE /proxies/{name}/cache
+		huma.Register(api, huma.Operation{
+			OperationID: "purge-proxy-cache",
+			Method:      http.MethodDelete,
+			Path:        "/proxies/{name}/cache",
+			Summary:     "Purge the cache entries of a proxy",
+			Description: "Remove all entries, or the entries with paths starting with a prefix, from the cache of the proxy.",
+			Tags:        []string{"Proxies"},
+			Errors:      []int{404},
+		}, createPurgeProxyCacheHdlr(s))
 	}
 }
@@ -0,0 +1,52 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// archiveArtifact stores data at key in the archive storage in the background, if configured.
+// Failures are logged.
+func (s *Server) archiveArtifact(key string, data []byte) {
+	if s.archive == nil {
+		return
+	}
+	go func() {
+		if err := s.archive.Put(context.Background(), key, data); err != nil {
+			slog.Warn("archive", "key", key, "err", err)
+		}
+	}()
+}
+
+// archiveSession stores the HAR recording of a session that has ended.
+func (s *Server) archiveSession(sess *configSession) {
+	if sess.recorder == nil {
+		return
+	}
+	data, err := json.Marshal(sess.recorder.har(sess.configJSON()))
+	if err != nil {
+		slog.Warn("archive session", "session", sess.id, "err", err)
+		return
+	}
+	s.archiveArtifact(fmt.Sprintf("sessions/%s.har", sess.id), data)
+}
+
+// archiveIngesterReport stores the report of a stopped ingester.
+func (s *Server) archiveIngesterReport(c *cmafIngester) {
+	c.reportMu.Lock()
+	report := fmt.Sprintf("Trace ID %s\n", c.trace.traceID) + strings.Join(c.report, "\n")
+	c.reportMu.Unlock()
+	s.archiveArtifact(fmt.Sprintf("ingesters/%d/report.txt", c.id), []byte(report+"\n"))
+}
+
+// archiveMPD stores a generated MPD recorded in the MPD history.
+func (s *Server) archiveMPD(key string, snap MPDSnapshot) {
+	s.archiveArtifact(fmt.Sprintf("mpd-history/%s/%d.mpd", strings.Trim(key, "/"), snap.NowMS), []byte(snap.MPD))
+}
@@ -0,0 +1,48 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchive(t *testing.T) {
+	archiveDir := t.TempDir()
+	_, ts := newTestServer(t, func(cfg *ServerConfig) {
+		cfg.MPDHistory = 5
+		cfg.Archive = archiveDir
+	})
+
+	resp, body := testFullRequest(t, ts, "POST", "/api/sessions", strings.NewReader(`{"config": {}, "record": true}`))
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+	var info SessionInfo
+	require.NoError(t, json.Unmarshal(body, &info))
+	resp, _ = testFullRequest(t, ts, "GET", info.URLPrefix+"/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = testFullRequest(t, ts, "DELETE", "/api/sessions/"+info.ID, nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	harPath := filepath.Join(archiveDir, "sessions", info.ID+".har")
+	mpdPath := filepath.Join(archiveDir, "mpd-history", "session_"+info.ID, "100000.mpd")
+	for _, p := range []string{harPath, mpdPath} {
+		require.Eventually(t, func() bool {
+			_, err := os.Stat(p)
+			return err == nil
+		}, 2*time.Second, 10*time.Millisecond, p)
+	}
+	data, err := os.ReadFile(harPath)
+	require.NoError(t, err)
+	var har HAR
+	require.NoError(t, json.Unmarshal(data, &har))
+	require.Len(t, har.Log.Entries, 1)
+}
@@ -19,8 +19,10 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/Dash-Industry-Forum/livesim2/internal"
+	"github.com/Dash-Industry-Forum/livesim2/pkg/segtemplate"
 	m "github.com/Eyevinn/dash-mpd/mpd"
 	"github.com/Eyevinn/mp4ff/bits"
 	"github.com/Eyevinn/mp4ff/mp4"
@@ -38,6 +40,7 @@ func newAssetMgr(vodFS fs.FS, repDataDir string, writeRepData bool) *assetMgr {
 
 type assetMgr struct {
 	vodFS        fs.FS
+	mu           sync.RWMutex      // protects assets after startup, when assets are uploaded
 	assets       map[string]*asset // the key is the asset path
 	repDataDir   string
 	writeRepData bool
@@ -45,6 +48,8 @@ type assetMgr struct {
 
 // findAsset finds the asset by matching the uri with all assets paths.
 func (am *assetMgr) findAsset(uri string) (*asset, bool) {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
 	for assetPath := range am.assets {
 		if uri == assetPath || strings.HasPrefix(uri, assetPath+"/") {
 			return am.assets[assetPath], true
@@ -53,6 +58,28 @@ func (am *assetMgr) findAsset(uri string) (*asset, bool) {
 	return nil, false
 }
 
+// getAsset returns the asset with exactly assetPath.
+func (am *assetMgr) getAsset(assetPath string) (*asset, bool) {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+	a, ok := am.assets[assetPath]
+	return a, ok
+}
+
+// listAssets returns all assets sorted by path.
+func (am *assetMgr) listAssets() []*asset {
+	am.mu.RLock()
+	assets := make([]*asset, 0, len(am.assets))
+	for _, a := range am.assets {
+		assets = append(assets, a)
+	}
+	am.mu.RUnlock()
+	sort.Slice(assets, func(i, j int) bool {
+		return assets[i].AssetPath < assets[j].AssetPath
+	})
+	return assets
+}
+
 // addAsset adds or retrieves an asset.
 func (am *assetMgr) addAsset(assetPath string) *asset {
 	if ast, ok := am.assets[assetPath]; ok {
@@ -183,6 +210,9 @@ func (am *assetMgr) loadRep(logger *slog.Logger, assetPath string, as *m.Adaptat
 		ok, err := rp.loadFromJSON(logger, am.vodFS, am.repDataDir, assetPath)
 		if ok {
 			logger.Debug("Loaded representation data from JSON")
+			if err == nil {
+				rp.avgBitrate = rp.measureBitrate(am.vodFS, assetPath)
+			}
 			return &rp, err
 		}
 	}
@@ -292,6 +322,7 @@ segLoop:
 	if commonSampleDur >= 0 {
 		rp.ConstantSampleDuration = Ptr(uint32(commonSampleDur))
 	}
+	rp.avgBitrate = rp.measureBitrate(am.vodFS, assetPath)
 	if !am.writeRepData {
 		return &rp, nil
 	}
@@ -349,16 +380,11 @@ func (rp *RepData) loadFromJSON(logger *slog.Logger, vodFS fs.FS, repDataDir, as
 }
 
 func (rp *RepData) addRegExpAndInit(logger *slog.Logger, vodFS fs.FS, assetPath string) error {
-	switch {
-	case strings.Contains(rp.MediaURI, "$Number$"):
-		rexStr := strings.ReplaceAll(rp.MediaURI, "$Number$", `(\d+)`)
-		rp.mediaRegexp = regexp.MustCompile(rexStr)
-	case strings.Contains(rp.MediaURI, "$Time$"):
-		rexStr := strings.ReplaceAll(rp.MediaURI, "$Time$", `(\d+)`)
-		rp.mediaRegexp = regexp.MustCompile(rexStr)
-	default:
-		return fmt.Errorf("neither $Number$, nor $Time$ found in media")
+	rexp, err := mediaTemplateRegexp(rp.MediaURI)
+	if err != nil {
+		return err
 	}
+	rp.mediaRegexp = rexp
 
 	if rp.ContentType != "image" {
 		err := rp.readInit(logger, vodFS, assetPath)
@@ -421,6 +447,8 @@ type asset struct {
 	LoopDurMS    int                         `json:"loopDurationMS"`
 	Reps         map[string]*RepData         `json:"representations"`
 	refRep       *RepData                    `json:"-"` // First video or audio representation
+	segDurAssets sync.Map                    `json:"-"` // Re-chunked versions of the asset keyed by segment duration (ms)
+	loopAssets   sync.Map                    `json:"-"` // Shortened versions of the asset keyed by loop duration (ms)
 }
 
 func (a *asset) getVodMPD(mpdName string) (*m.MPD, error) {
@@ -443,6 +471,18 @@ func (l lastSegInfo) availabilityTime(ato float64) float64 {
 	return math.Round(float64(l.startTime+l.dur)/float64(l.timescale)) - ato
 }
 
+// earlier returns info about the segment k segments before, assuming the same duration.
+// The nr is -1 if that segment is before the start.
+func (l lastSegInfo) earlier(k int) lastSegInfo {
+	back := uint64(k) * l.dur
+	if k > l.nr || back > l.startTime {
+		return lastSegInfo{timescale: l.timescale, nr: -1}
+	}
+	l.startTime -= back
+	l.nr -= k
+	return l
+}
+
 // generateTimelineEntries generates timeline entries for the given representation.
 // If no segments are available, startNr and lsi.nr are set to -1.
 func (a *asset) generateTimelineEntries(repID string, wt wrapTimes, atoMS int) segEntries {
@@ -669,10 +709,14 @@ type RepData struct {
 	DefaultSampleDuration  uint32           `json:"defaultSampleDuration"`            // Read from trex or tfhd
 	ConstantSampleDuration *uint32          `json:"constantSampleDuration,omitempty"` // Non-zero if all samples have the same duration
 	PreEncrypted           bool             `json:"preEncrypted"`
+	CEA608                 []string         `json:"cea608,omitempty"` // CEA-608 caption channels found in SEI
+	CEA708                 bool             `json:"cea708,omitempty"` // CEA-708 captions found in SEI
 	mediaRegexp            *regexp.Regexp   `json:"-"`
 	initSeg                *mp4.InitSegment `json:"-"`
 	initBytes              []byte           `json:"-"`
 	encData                *repEncData      `json:"-"`
+	sources                [][]segSource    `json:"-"` // Source segment parts of each segment if re-chunked
+	avgBitrate             uint64           `json:"-"` // Average bitrate (bps) from segment file sizes, 0 if unknown
 }
 
 type repEncData struct {
@@ -737,9 +781,9 @@ func (r RepData) SegmentType() string {
 
 func (r RepData) typeURI() mediaURIType {
 	switch {
-	case strings.Contains(r.MediaURI, "$Number$"):
+	case segtemplate.Has(r.MediaURI, "Number"):
 		return numberURI
-	case strings.Contains(r.MediaURI, "$Time$"):
+	case segtemplate.Has(r.MediaURI, "Time"):
 		return timeURI
 	default:
 		panic("unknown type of media URI")
@@ -925,10 +969,34 @@ func (r *RepData) readMP4Segment(vodFS fs.FS, assetPath string, time uint64, nr
 	if err == nil {
 		seg.CommonSampleDur = commonSampleDur
 	}
+	if r.ContentType == "video" {
+		r.detectCaptions(s)
+	}
 
 	return seg, nil
 }
 
+// measureBitrate returns the average bitrate (bps) given by the sizes of all segment files,
+// or 0 if some segment file cannot be found.
+func (r *RepData) measureBitrate(vodFS fs.FS, assetPath string) uint64 {
+	if r.MediaTimescale == 0 || len(r.Segments) == 0 {
+		return 0
+	}
+	var size, dur uint64
+	for _, seg := range r.Segments {
+		fi, err := fs.Stat(vodFS, path.Join(assetPath, replaceTimeAndNr(r.MediaURI, seg.StartTime, seg.Nr)))
+		if err != nil {
+			return 0
+		}
+		size += uint64(fi.Size())
+		dur += seg.dur()
+	}
+	if dur == 0 {
+		return 0
+	}
+	return size * 8 * uint64(r.MediaTimescale) / dur
+}
+
 // readThumbSegment reads a thumbnail segment, and returns an error if file does not exist.
 func (r *RepData) readThumbSegment(vodFS fs.FS, assetPath string, nr, startNr uint32, dur uint64) (Segment, error) {
 	var seg Segment
@@ -944,22 +1012,28 @@ func (r *RepData) readThumbSegment(vodFS fs.FS, assetPath string, nr, startNr ui
 	return Segment{StartTime: startTime, EndTime: startTime + dur, Nr: nr}, nil
 }
 
+// replaceIdentifiers replaces $RepresentationID$ and $Bandwidth$ with or without format tag.
+// Other identifiers and $$ escapes are kept.
 func replaceIdentifiers(r *m.RepresentationType, str string) string {
-	str = strings.ReplaceAll(str, "$RepresentationID$", r.Id)
-	str = strings.ReplaceAll(str, "$Bandwidth$", strconv.Itoa(int(r.Bandwidth)))
-	return str
+	return segtemplate.Substitute(str, map[string]string{
+		"RepresentationID": r.Id,
+		"Bandwidth":        strconv.Itoa(int(r.Bandwidth)),
+	}, false)
 }
 
+// replaceTimeAndNr replaces $Time$ and $Number$ with or without format tag, as well as $$.
+// Segments are not split into sub-segments, so $SubNumber$ is always 1.
 func replaceTimeAndNr(str string, time uint64, nr uint32) string {
-	str = strings.ReplaceAll(str, "$Time$", strconv.Itoa(int(time)))
-	str = strings.ReplaceAll(str, "$Number$", strconv.Itoa(int(nr)))
-	return str
+	return segtemplate.Substitute(str, map[string]string{
+		"Time":      strconv.FormatUint(time, 10),
+		"Number":    strconv.FormatUint(uint64(nr), 10),
+		"SubNumber": "1",
+	}, true)
 }
 
+// replaceTimeOrNr replaces both $Time$ and $Number$ with val like replaceTimeAndNr.
 func replaceTimeOrNr(str string, val int) string {
-	str = strings.ReplaceAll(str, "$Time$", strconv.Itoa(val))
-	str = strings.ReplaceAll(str, "$Number$", strconv.Itoa(val))
-	return str
+	return replaceTimeAndNr(str, uint64(val), uint32(val))
 }
 
 type Segment struct {
@@ -5,6 +5,7 @@
 package app
 
 import (
+	"fmt"
 	"log/slog"
 	"os"
 	"path"
@@ -12,6 +13,7 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	m "github.com/Eyevinn/dash-mpd/mpd"
 	"github.com/stretchr/testify/require"
@@ -196,3 +198,27 @@ func setSegmentEndNr(assetDir string, endNumber uint32) error {
 	}
 	return nil
 }
+
+func BenchmarkGenerateTimelineEntries(b *testing.B) {
+	vodFS := os.DirFS("testdata/assets")
+	am := newAssetMgr(vodFS, "", false)
+	err := am.discoverAssets(slog.Default())
+	require.NoError(b, err)
+	asset, ok := am.findAsset("testpic_2s")
+	require.True(b, ok)
+	cfg := NewResponseConfig()
+	cfg.SegTimelineFlag = true
+
+	for _, tsbdS := range []int{60, 300, 3600} {
+		b.Run(fmt.Sprintf("tsbd_%ds", tsbdS), func(b *testing.B) {
+			wt := calcWrapTimes(asset, cfg, 4_000_000, m.Duration(tsbdS)*m.Duration(time.Second))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				se := asset.generateTimelineEntries("V300", wt, 0)
+				if len(se.entries) == 0 {
+					b.Fatal("no timeline entries")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"slices"
+	"sort"
+)
+
+// AssetDetails describes an asset and the livesim2 modes it supports.
+type AssetDetails struct {
+	Path         string        `json:"path" doc:"Asset path"`
+	LoopDurMS    int           `json:"loopDurationMS" doc:"Duration after which the content loops (ms)"`
+	SegmentDurMS int           `json:"segmentDurMS" doc:"Nominal segment duration (ms)"`
+	MPDs         []AssetMPD    `json:"mpds"`
+	Tracks       []TrackDetail `json:"tracks" doc:"Representations sorted by content type and bandwidth"`
+	Languages    []string      `json:"languages,omitempty" doc:"Languages of the AdaptationSets"`
+	Modes        []string      `json:"modes" doc:"Supported livesim2 modes"`
+}
+
+// AssetMPD is an MPD of an asset.
+type AssetMPD struct {
+	Name  string `json:"name"`
+	Title string `json:"title,omitempty"`
+	Dur   string `json:"duration,omitempty"`
+}
+
+// TrackDetail describes a representation of an asset.
+type TrackDetail struct {
+	ID           string         `json:"id"`
+	ContentType  string         `json:"contentType"`
+	Codecs       string         `json:"codecs,omitempty"`
+	Bandwidth    uint32         `json:"bandwidth,omitempty" doc:"Bandwidth signaled in the VoD MPD (bps)"`
+	Width        uint32         `json:"width,omitempty"`
+	Height       uint32         `json:"height,omitempty"`
+	FrameRate    string         `json:"frameRate,omitempty"`
+	Lang         string         `json:"lang,omitempty"`
+	Timescale    int            `json:"timescale" doc:"Media timescale"`
+	PreEncrypted bool           `json:"preEncrypted,omitempty"`
+	CEA608       []string       `json:"cea608,omitempty" doc:"CEA-608 caption channels"`
+	SegmentDurMS SegmentDurStat `json:"segmentDurMS" doc:"Segment duration statistics (ms)"`
+}
+
+// SegmentDurStat has statistics of the segment durations of a representation.
+type SegmentDurStat struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Mean  float64 `json:"mean"`
+}
+
+// assetModes are the modes supported by all assets.
+var assetModes = []string{"number", "segtimeline", "segtimelinenr", "lowlatency", "timesubs", "segdur", "loop"}
+
+// details returns the details of the asset, with properties signaled in the VoD MPDs.
+func (a *asset) details() AssetDetails {
+	ad := AssetDetails{
+		Path:         a.AssetPath,
+		LoopDurMS:    a.LoopDurMS,
+		SegmentDurMS: a.SegmentDurMS,
+	}
+	tracks := make(map[string]*TrackDetail, len(a.Reps))
+	for id, rep := range a.Reps {
+		tracks[id] = &TrackDetail{
+			ID:           id,
+			ContentType:  rep.ContentType,
+			Codecs:       rep.Codecs,
+			Timescale:    rep.MediaTimescale,
+			PreEncrypted: rep.PreEncrypted,
+			CEA608:       rep.CEA608,
+			SegmentDurMS: segmentDurStat(rep),
+		}
+	}
+	for name, md := range a.MPDs {
+		ad.MPDs = append(ad.MPDs, AssetMPD{Name: name, Title: md.Title, Dur: md.Dur})
+		vodMPD, err := a.getVodMPD(name)
+		if err != nil {
+			continue
+		}
+		for _, p := range vodMPD.Periods {
+			for _, as := range p.AdaptationSets {
+				if as.Lang != "" && !slices.Contains(ad.Languages, as.Lang) {
+					ad.Languages = append(ad.Languages, as.Lang)
+				}
+				for _, rep := range as.Representations {
+					td, ok := tracks[rep.Id]
+					if !ok || td.Bandwidth != 0 {
+						continue
+					}
+					td.Bandwidth = rep.Bandwidth
+					td.Width = firstNonZero(rep.Width, as.Width)
+					td.Height = firstNonZero(rep.Height, as.Height)
+					td.FrameRate = string(rep.FrameRate)
+					if td.FrameRate == "" {
+						td.FrameRate = string(as.FrameRate)
+					}
+					td.Lang = as.Lang
+				}
+			}
+		}
+	}
+	sort.Slice(ad.MPDs, func(i, j int) bool { return ad.MPDs[i].Name < ad.MPDs[j].Name })
+	slices.Sort(ad.Languages)
+	for _, td := range tracks {
+		ad.Tracks = append(ad.Tracks, *td)
+	}
+	sort.Slice(ad.Tracks, func(i, j int) bool {
+		ti, tj := ad.Tracks[i], ad.Tracks[j]
+		if ti.ContentType != tj.ContentType {
+			return ti.ContentType < tj.ContentType
+		}
+		if ti.Bandwidth != tj.Bandwidth {
+			return ti.Bandwidth < tj.Bandwidth
+		}
+		return ti.ID < tj.ID
+	})
+	ad.Modes = a.modes(ad.Tracks)
+	return ad
+}
+
+// modes returns the livesim2 modes supported by the asset with tracks.
+func (a *asset) modes(tracks []TrackDetail) []string {
+	modes := slices.Clone(assetModes)
+	preEncrypted, captions := false, false
+	for _, td := range tracks {
+		preEncrypted = preEncrypted || td.PreEncrypted
+		captions = captions || len(td.CEA608) > 0
+	}
+	if !preEncrypted {
+		modes = append(modes, "drm")
+	}
+	if captions {
+		modes = append(modes, "ccstrip")
+	}
+	return modes
+}
+
+// segmentDurStat returns statistics of the segment durations of rep in milliseconds.
+func segmentDurStat(rep *RepData) SegmentDurStat {
+	st := SegmentDurStat{Count: len(rep.Segments)}
+	if st.Count == 0 || rep.MediaTimescale == 0 {
+		return st
+	}
+	var sum uint64
+	minDur, maxDur := rep.Segments[0].dur(), rep.Segments[0].dur()
+	for _, seg := range rep.Segments {
+		d := seg.dur()
+		sum += d
+		minDur = min(minDur, d)
+		maxDur = max(maxDur, d)
+	}
+	toMS := func(d float64) float64 { return d * 1000 / float64(rep.MediaTimescale) }
+	st.Min = toMS(float64(minDur))
+	st.Max = toMS(float64(maxDur))
+	st.Mean = toMS(float64(sum) / float64(st.Count))
+	return st
+}
+
+func firstNonZero(vals ...uint32) uint32 {
+	for _, v := range vals {
+		if v != 0 {
+			return v
+		}
+	}
+	return 0
+}
@@ -0,0 +1,48 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssetDetails(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	resp, body := testFullRequest(t, ts, "GET", "/api/assets/testpic_2s", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var ad AssetDetails
+	require.NoError(t, json.Unmarshal(body, &ad))
+	require.Equal(t, "testpic_2s", ad.Path)
+	require.Equal(t, 2000, ad.SegmentDurMS)
+	require.Contains(t, ad.Languages, "en")
+	require.Contains(t, ad.Modes, "drm")
+	var video *TrackDetail
+	for i := range ad.Tracks {
+		if ad.Tracks[i].ID == "V300" {
+			video = &ad.Tracks[i]
+		}
+	}
+	require.NotNil(t, video)
+	require.Equal(t, "video", video.ContentType)
+	require.Equal(t, "avc1.64001e", video.Codecs)
+	require.Equal(t, uint32(300000), video.Bandwidth)
+	require.Equal(t, uint32(640), video.Width)
+	require.Equal(t, uint32(360), video.Height)
+	require.Equal(t, 2000.0, video.SegmentDurMS.Mean)
+	require.Equal(t, 2000.0, video.SegmentDurMS.Max)
+
+	resp, body = testFullRequest(t, ts, "GET", "/api/assets/WAVE%2Fvectors%2Fcfhd_sets%2F14.985_29.97_59.94%2Ft1%2F2022-10-17", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.Unmarshal(body, &ad))
+	require.Equal(t, "WAVE/vectors/cfhd_sets/14.985_29.97_59.94/t1/2022-10-17", ad.Path)
+
+	resp, _ = testFullRequest(t, ts, "GET", "/api/assets/unknown", nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,181 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// statsBucketS is the base resolution of the asset statistics time series
+	statsBucketS = 10
+	// statsNrBuckets is the number of base buckets kept (one hour)
+	statsNrBuckets = 360
+	// statsMPDRep is the representation name used for MPD requests
+	statsMPDRep = "MPD"
+)
+
+// StatsCounts are request counts and served bytes.
+type StatsCounts struct {
+	Requests int   `json:"requests" doc:"Number of requests"`
+	Errors   int   `json:"errors" doc:"Number of requests with status code >= 400"`
+	Bytes    int64 `json:"bytes" doc:"Number of bytes served"`
+}
+
+func (c *StatsCounts) add(o StatsCounts) {
+	c.Requests += o.Requests
+	c.Errors += o.Errors
+	c.Bytes += o.Bytes
+}
+
+// StatsPoint is the counts for one time interval.
+type StatsPoint struct {
+	Start time.Time `json:"start" doc:"Start of interval"`
+	StatsCounts
+}
+
+type statsBucket struct {
+	idx int64 // Unix time divided by statsBucketS
+	StatsCounts
+}
+
+// statsSeries is a ring buffer of buckets and the total since start.
+type statsSeries struct {
+	total   StatsCounts
+	buckets [statsNrBuckets]statsBucket
+}
+
+func (ss *statsSeries) add(idx int64, c StatsCounts) {
+	ss.total.add(c)
+	b := &ss.buckets[idx%statsNrBuckets]
+	if b.idx > idx {
+		return // Too old for the ring buffer
+	}
+	if b.idx != idx {
+		*b = statsBucket{idx: idx}
+	}
+	b.add(c)
+}
+
+// series returns points with resolution of nrPerPoint buckets, ending with the point containing nowIdx.
+func (ss *statsSeries) series(nowIdx int64, nrPerPoint int) []StatsPoint {
+	nrPoints := statsNrBuckets / nrPerPoint
+	lastStart := nowIdx - nowIdx%int64(nrPerPoint)
+	firstStart := lastStart - int64((nrPoints-1)*nrPerPoint)
+	points := make([]StatsPoint, nrPoints)
+	for i := range points {
+		points[i].Start = time.Unix((firstStart+int64(i*nrPerPoint))*statsBucketS, 0).UTC()
+	}
+	for _, b := range ss.buckets {
+		if b.idx < firstStart || b.idx > nowIdx {
+			continue
+		}
+		points[(b.idx-firstStart)/int64(nrPerPoint)].add(b.StatsCounts)
+	}
+	return points
+}
+
+type assetSeries struct {
+	all  statsSeries
+	reps map[string]*statsSeries
+}
+
+// assetStats tracks requests per asset and representation.
+type assetStats struct {
+	mu     sync.Mutex
+	assets map[string]*assetSeries
+}
+
+func newAssetStats() *assetStats {
+	return &assetStats{assets: make(map[string]*assetSeries)}
+}
+
+// record adds a request with response status and served bytes.
+func (as *assetStats) record(now time.Time, assetPath, rep string, status, bytes int) {
+	c := StatsCounts{Requests: 1, Bytes: int64(bytes)}
+	if status >= http.StatusBadRequest {
+		c.Errors = 1
+	}
+	idx := now.Unix() / statsBucketS
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	a, ok := as.assets[assetPath]
+	if !ok {
+		a = &assetSeries{reps: make(map[string]*statsSeries)}
+		as.assets[assetPath] = a
+	}
+	a.all.add(idx, c)
+	if rep == "" {
+		return
+	}
+	rs, ok := a.reps[rep]
+	if !ok {
+		rs = &statsSeries{}
+		a.reps[rep] = rs
+	}
+	rs.add(idx, c)
+}
+
+// RepStats is the statistics for a representation.
+type RepStats struct {
+	Rep    string       `json:"rep" doc:"Representation ID, or MPD for manifest requests"`
+	Total  StatsCounts  `json:"total" doc:"Counts since server start"`
+	Series []StatsPoint `json:"series" doc:"Time series, oldest first"`
+}
+
+// AssetStats is the statistics for an asset.
+type AssetStats struct {
+	Asset  string       `json:"asset" doc:"Asset path"`
+	Total  StatsCounts  `json:"total" doc:"Counts since server start"`
+	Series []StatsPoint `json:"series" doc:"Time series, oldest first"`
+	Reps   []RepStats   `json:"reps" doc:"Per-representation statistics"`
+}
+
+// stats returns statistics for all assets, or only assetPath if not empty, sorted by asset and rep.
+// resolutionS must be a multiple of statsBucketS.
+func (as *assetStats) stats(now time.Time, assetPath string, resolutionS int) []AssetStats {
+	nowIdx := now.Unix() / statsBucketS
+	nrPerPoint := resolutionS / statsBucketS
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	out := make([]AssetStats, 0, len(as.assets))
+	for ap, a := range as.assets {
+		if assetPath != "" && ap != assetPath {
+			continue
+		}
+		s := AssetStats{Asset: ap, Total: a.all.total, Series: a.all.series(nowIdx, nrPerPoint)}
+		s.Reps = make([]RepStats, 0, len(a.reps))
+		for rep, rs := range a.reps {
+			s.Reps = append(s.Reps, RepStats{Rep: rep, Total: rs.total, Series: rs.series(nowIdx, nrPerPoint)})
+		}
+		sort.Slice(s.Reps, func(i, j int) bool { return s.Reps[i].Rep < s.Reps[j].Rep })
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Asset < out[j].Asset })
+	return out
+}
+
+// statsRepID returns the representation ID for a request of contentPart in asset a,
+// statsMPDRep for MPDs, and "" if not known.
+func statsRepID(a *asset, contentPart string) string {
+	if path.Ext(contentPart) == ".mpd" {
+		return statsMPDRep
+	}
+	segmentPart := strings.TrimPrefix(strings.TrimPrefix(contentPart, a.AssetPath), "/")
+	for id, rep := range a.Reps {
+		if segmentPart == rep.InitURI {
+			return id
+		}
+	}
+	if rep, _, err := findRepAndSegmentID(a, segmentPart); err == nil {
+		return rep.ID
+	}
+	return ""
+}
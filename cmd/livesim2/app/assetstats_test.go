@@ -0,0 +1,61 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsSeries(t *testing.T) {
+	var ss statsSeries
+	now := int64(1000) // bucket index
+	ss.add(now, StatsCounts{Requests: 1, Bytes: 100})
+	ss.add(now-1, StatsCounts{Requests: 1, Errors: 1})
+	ss.add(now-statsNrBuckets, StatsCounts{Requests: 1, Bytes: 1}) // Too old
+	ss.add(now-statsNrBuckets+1, StatsCounts{Requests: 1, Bytes: 1})
+	points := ss.series(now, 6)
+	require.Len(t, points, statsNrBuckets/6)
+	last := points[len(points)-1]
+	require.Equal(t, StatsCounts{Requests: 2, Errors: 1, Bytes: 100}, last.StatsCounts)
+	require.Equal(t, time.Unix(996*statsBucketS, 0).UTC(), last.Start)
+	require.Equal(t, StatsCounts{Requests: 4, Errors: 1, Bytes: 102}, ss.total)
+}
+
+func TestAssetStatsAPI(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	for _, p := range []string{"Manifest.mpd", "V300/init.mp4", "V300/45.m4s?nowMS=100000", "A48/1000.m4s?nowMS=100000"} {
+		_, _ = testFullRequest(t, ts, "GET", "/livesim2/testpic_2s/"+p, nil)
+	}
+	resp, body := testFullRequest(t, ts, "GET", "/api/stats/assets?asset=testpic_2s&resolution=600", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var stats struct {
+		ResolutionS int          `json:"resolution"`
+		Assets      []AssetStats `json:"assets"`
+	}
+	require.NoError(t, json.Unmarshal(body, &stats))
+	require.Equal(t, 600, stats.ResolutionS)
+	require.Len(t, stats.Assets, 1)
+	a := stats.Assets[0]
+	require.Equal(t, 4, a.Total.Requests)
+	require.Equal(t, 1, a.Total.Errors)
+	require.Len(t, a.Series, 6)
+	require.Equal(t, a.Total, a.Series[5].StatsCounts)
+	reps := make(map[string]StatsCounts)
+	for _, r := range a.Reps {
+		reps[r.Rep] = r.Total
+	}
+	require.Equal(t, 1, reps[statsMPDRep].Requests)
+	require.Equal(t, 2, reps["V300"].Requests)
+	require.Equal(t, 1, reps["A48"].Errors)
+
+	resp, _ = testFullRequest(t, ts, "GET", "/api/stats/assets?resolution=15", nil)
+	require.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+}
@@ -0,0 +1,152 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// maxAssetUploadBytes is the maximal size of an uploaded zip archive, and of its unpacked files
+const maxAssetUploadBytes = 1 << 30
+
+var (
+	errAssetExists    = errors.New("asset already exists")
+	errBadAssetUpload = errors.New("bad asset upload")
+)
+
+// uploadAsset unpacks the zip archive data to the directory name in the vodroot, and loads the
+// assets with MPDs in it. The files are unpacked to a temporary directory first, so that a failed
+// upload leaves nothing behind, and the assets are only made available when all are loaded.
+func (am *assetMgr) uploadAsset(logger *slog.Logger, vodRoot, name string, data []byte) ([]*asset, error) {
+	if name == "" || !filepath.IsLocal(name) || strings.Contains(name, "\\") || path.Clean(name) != name ||
+		strings.HasPrefix(path.Base(name), ".") {
+		return nil, fmt.Errorf("%w: asset name %q", errBadAssetUpload, name)
+	}
+	if _, ok := am.findAsset(name); ok {
+		return nil, fmt.Errorf("%w: %s", errAssetExists, name)
+	}
+	dst := filepath.Join(vodRoot, filepath.FromSlash(name))
+	if _, err := os.Stat(dst); err == nil {
+		return nil, fmt.Errorf("%w: directory %s", errAssetExists, name)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errBadAssetUpload, err)
+	}
+	tmpDir, err := os.MkdirTemp(vodRoot, ".upload-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := unzipFiles(zr, tmpDir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmpDir, dst); err != nil {
+		return nil, err
+	}
+	assets, err := am.loadUploadedAssets(logger, name)
+	if err != nil {
+		_ = os.RemoveAll(dst)
+		return nil, err
+	}
+	return assets, nil
+}
+
+// unzipFiles writes the regular files of zr below dir.
+func unzipFiles(zr *zip.Reader, dir string) error {
+	var total uint64
+	for _, f := range zr.File {
+		if !filepath.IsLocal(f.Name) || strings.Contains(f.Name, "\\") {
+			return fmt.Errorf("%w: file name %q", errBadAssetUpload, f.Name)
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			return fmt.Errorf("%w: %s is not a regular file", errBadAssetUpload, f.Name)
+		}
+		total += f.UncompressedSize64
+		if total > maxAssetUploadBytes {
+			return fmt.Errorf("%w: unpacked size exceeds %d bytes", errBadAssetUpload, maxAssetUploadBytes)
+		}
+		if err := unzipFile(f, filepath.Join(dir, filepath.FromSlash(f.Name))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unzipFile(f *zip.File, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("%w: %w", errBadAssetUpload, err)
+	}
+	defer rc.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	// The zip reader fails if the data does not match the declared size and checksum
+	if _, err := io.Copy(out, rc); err != nil {
+		out.Close()
+		return fmt.Errorf("%w: %s: %w", errBadAssetUpload, f.Name, err)
+	}
+	return out.Close()
+}
+
+// loadUploadedAssets loads and consolidates the assets below dir, and adds them if all are valid.
+func (am *assetMgr) loadUploadedAssets(logger *slog.Logger, dir string) ([]*asset, error) {
+	tmp := newAssetMgr(am.vodFS, am.repDataDir, am.writeRepData)
+	err := fs.WalkDir(am.vodFS, dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path.Ext(p) != ".mpd" {
+			return nil
+		}
+		if err := tmp.loadAsset(logger, p); err != nil {
+			return fmt.Errorf("%w: %s: %w", errBadAssetUpload, p, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(tmp.assets) == 0 {
+		return nil, fmt.Errorf("%w: no MPD found", errBadAssetUpload)
+	}
+	for _, a := range tmp.assets {
+		if err := a.consolidateAsset(logger); err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", errBadAssetUpload, a.AssetPath, err)
+		}
+	}
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	for assetPath := range tmp.assets {
+		if _, ok := am.assets[assetPath]; ok {
+			return nil, fmt.Errorf("%w: %s", errAssetExists, assetPath)
+		}
+	}
+	for assetPath, a := range tmp.assets {
+		am.assets[assetPath] = a
+	}
+	return tmp.listAssets(), nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// zipDir returns a zip archive of the files in dir, with the given files only if not empty.
+func zipDir(t *testing.T, dir string, files ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	err := fs.WalkDir(os.DirFS(dir), ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if len(files) > 0 && path.Ext(p) == ".mpd" && p != files[0] {
+			return nil
+		}
+		data, err := os.ReadFile(path.Join(dir, p))
+		if err != nil {
+			return err
+		}
+		w, err := zw.Create(p)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	})
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestAssetUpload(t *testing.T) {
+	// The server needs an asset at startup
+	vodRoot := t.TempDir()
+	data := zipDir(t, "testdata/assets/testpic_2s", "Manifest.mpd")
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	require.NoError(t, unzipFiles(zr, path.Join(vodRoot, "testpic_2s")))
+	server, ts := newTestServer(t, func(cfg *ServerConfig) {
+		cfg.VodRoot = vodRoot
+		cfg.AssetUpload = true
+	})
+
+	resp, body := testFullRequest(t, ts, "PUT", "/api/assets/uploads%2Ftestpic", bytes.NewReader(data))
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+	var uploaded AssetUploadResponse
+	require.NoError(t, json.Unmarshal(body, &uploaded.Body))
+	require.Len(t, uploaded.Body.Assets, 1)
+	require.Equal(t, "uploads/testpic", uploaded.Body.Assets[0].Path)
+	require.Equal(t, 2000, uploaded.Body.Assets[0].SegmentDurMS)
+
+	resp, body = testFullRequest(t, ts, "GET", "/livesim2/uploads/testpic/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/uploads/testpic/V300/40.m4s?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = testFullRequest(t, ts, "GET", "/api/assets/uploads%2Ftestpic", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, _ = testFullRequest(t, ts, "PUT", "/api/assets/uploads%2Ftestpic", bytes.NewReader(data))
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+	resp, _ = testFullRequest(t, ts, "PUT", "/api/assets/bad", bytes.NewReader([]byte("not a zip")))
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = testFullRequest(t, ts, "PUT", "/api/assets/..%2Fescape", bytes.NewReader(data))
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	_, err = os.Stat(path.Join(vodRoot, "bad"))
+	require.True(t, os.IsNotExist(err))
+
+	// A zip without an MPD is rejected and leaves nothing behind
+	resp, _ = testFullRequest(t, ts, "PUT", "/api/assets/nompd", bytes.NewReader(zipDir(t, "testdata/assets/testpic_2s/V300")))
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	_, err = os.Stat(path.Join(vodRoot, "nompd"))
+	require.True(t, os.IsNotExist(err))
+
+	server.Cfg.AssetUpload = false
+	resp, _ = testFullRequest(t, ts, "PUT", "/api/assets/other", bytes.NewReader(data))
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
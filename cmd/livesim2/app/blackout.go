@@ -0,0 +1,134 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/Eyevinn/mp4ff/mp4"
+)
+
+// blackoutSchemeIdUri is used for MPD and inband events signaling the start and end of a blackout.
+const blackoutSchemeIdUri = "urn:livesim2:blackout:2024"
+
+const (
+	blackoutStart = "start"
+	blackoutEnd   = "end"
+)
+
+// Blackout configures a rights blackout, where the selected representations are replaced by a slate
+// from wall-clock time StartS until EndS. The slate is the same as for Slate.
+// The blackout applies to segments starting in the interval.
+// The start and end are signaled by an MPD EventStream, and by emsg boxes in the video segments.
+type Blackout struct {
+	StartS int      `json:"StartS"`
+	EndS   int      `json:"EndS,omitempty"` // 0 means no scheduled end
+	Reps   []string `json:"Reps,omitempty"` // All video and audio representations if empty
+}
+
+func (bo *Blackout) validate() error {
+	if bo.StartS < 0 {
+		return fmt.Errorf("blackout start %ds must be >= 0", bo.StartS)
+	}
+	if bo.EndS != 0 && bo.EndS <= bo.StartS {
+		return fmt.Errorf("blackout end %ds must be after start %ds", bo.EndS, bo.StartS)
+	}
+	return nil
+}
+
+// applies returns true if the blackout replaces the content of rep.
+func (bo *Blackout) applies(rep *RepData) bool {
+	if len(bo.Reps) == 0 {
+		return rep.ContentType == "video" || rep.ContentType == "audio"
+	}
+	return slices.Contains(bo.Reps, rep.ID)
+}
+
+// active returns true if the segment starting at mediaTime is in the blackout.
+// startTimeS is the offset of media time to wall-clock time.
+func (bo *Blackout) active(mediaTime uint64, timescale uint32, startTimeS int) bool {
+	tMS := int(mediaTime*1000/uint64(timescale)) + startTimeS*1000
+	return tMS >= bo.StartS*1000 && (bo.EndS == 0 || tMS < bo.EndS*1000)
+}
+
+// blackoutEmsgs returns emsg boxes for the blackout start and end in the segment [segStart, segEnd).
+// The times are in timescale units with startTimeS as offset to wall-clock time.
+func blackoutEmsgs(bo *Blackout, segStart, segEnd, timescale uint64, startTimeS int) []*mp4.EmsgBox {
+	offset := int64(startTimeS) * int64(timescale)
+	inSeg := func(wallS int) bool {
+		t := int64(wallS)*int64(timescale) - offset
+		return t >= int64(segStart) && t < int64(segEnd)
+	}
+	var emsgs []*mp4.EmsgBox
+	if inSeg(bo.StartS) {
+		dur := uint32(0xffffffff) // unknown
+		if bo.EndS != 0 {
+			dur = uint32(uint64(bo.EndS-bo.StartS) * timescale)
+		}
+		emsgs = append(emsgs, blackoutEmsg(bo.StartS, dur, timescale, offset, blackoutStart))
+	}
+	if bo.EndS != 0 && inSeg(bo.EndS) {
+		emsgs = append(emsgs, blackoutEmsg(bo.EndS, 0, timescale, offset, blackoutEnd))
+	}
+	return emsgs
+}
+
+func blackoutEmsg(wallS int, dur uint32, timescale uint64, offset int64, data string) *mp4.EmsgBox {
+	return &mp4.EmsgBox{
+		Version:          1,
+		TimeScale:        uint32(timescale),
+		PresentationTime: uint64(int64(wallS)*int64(timescale) - offset),
+		EventDuration:    dur,
+		ID:               uint32(wallS),
+		SchemeIDURI:      blackoutSchemeIdUri,
+		MessageData:      []byte(data),
+	}
+}
+
+// addBlackoutEvents adds an EventStream with the blackout start and end events to the Periods where they occur.
+// The event times are relative to the Period start in milliseconds.
+func addBlackoutEvents(mpd *m.MPD, bo *Blackout, startTimeS int) {
+	type boEvent struct {
+		wallS int
+		data  string
+	}
+	events := []boEvent{{bo.StartS, blackoutStart}}
+	if bo.EndS != 0 {
+		events = append(events, boEvent{bo.EndS, blackoutEnd})
+	}
+	for i, p := range mpd.Periods {
+		pStartMS := periodStartMS(p)
+		pEndMS := -1
+		if i < len(mpd.Periods)-1 {
+			pEndMS = periodStartMS(mpd.Periods[i+1])
+		}
+		var es *m.EventStreamType
+		for _, ev := range events {
+			tMS := (ev.wallS - startTimeS) * 1000
+			if tMS < pStartMS || (pEndMS >= 0 && tMS >= pEndMS) {
+				continue
+			}
+			if es == nil {
+				es = &m.EventStreamType{SchemeIdUri: blackoutSchemeIdUri, Timescale: Ptr(uint32(1000))}
+				p.EventStreams = append(p.EventStreams, es)
+			}
+			e := &m.EventType{PresentationTime: uint64(tMS - pStartMS), Id: uint32(ev.wallS), MessageData: ev.data}
+			if ev.data == blackoutStart && bo.EndS != 0 {
+				e.Duration = uint64(bo.EndS-bo.StartS) * 1000
+			}
+			es.Events = append(es.Events, e)
+		}
+	}
+}
+
+func periodStartMS(p *m.Period) int {
+	if p.Start == nil {
+		return 0
+	}
+	return int(time.Duration(*p.Start).Milliseconds())
+}
@@ -0,0 +1,112 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Eyevinn/mp4ff/mp4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlackout(t *testing.T) {
+	server, ts := newTestServer(t, nil)
+
+	a, ok := server.assetMgr.findAsset("testpic_2s")
+	require.True(t, ok)
+	timescale := a.Reps["V300"].MediaTimescale
+
+	getSeg := func(prefix, rep string, startS int) *mp4.MediaSegment {
+		url := fmt.Sprintf("%s/segtimeline_1/testpic_2s/%s/%d.m4s?nowMS=100000", prefix, rep, startS*timescale)
+		resp, body := testFullRequest(t, ts, "GET", url, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		f, err := mp4.DecodeFile(bytes.NewBuffer(body))
+		require.NoError(t, err)
+		return f.Segments[0]
+	}
+
+	// Blackout of V300 during [40, 50)
+	prefix := "/livesim2/blackout_40_50_V300"
+	bo1, bo2, after := getSeg(prefix, "V300", 40), getSeg(prefix, "V300", 42), getSeg(prefix, "V300", 50)
+	require.Equal(t, bo1.Fragments[0].Mdat.Data, bo2.Fragments[0].Mdat.Data)
+	require.NotEqual(t, bo1.Fragments[0].Mdat.Data, after.Fragments[0].Mdat.Data)
+	require.Equal(t, getSeg("/livesim2", "V300", 50).Fragments[0].Mdat.Data, after.Fragments[0].Mdat.Data)
+
+	require.Len(t, bo1.Fragments[0].Emsgs, 1)
+	emsg := bo1.Fragments[0].Emsgs[0]
+	require.Equal(t, blackoutSchemeIdUri, emsg.SchemeIDURI)
+	require.Equal(t, "start", string(emsg.MessageData))
+	require.Equal(t, uint64(40*timescale), emsg.PresentationTime)
+	require.Equal(t, uint32(10*timescale), emsg.EventDuration)
+	require.Len(t, bo2.Fragments[0].Emsgs, 0)
+	require.Len(t, after.Fragments[0].Emsgs, 1)
+	require.Equal(t, "end", string(after.Fragments[0].Emsgs[0].MessageData))
+
+	// Audio is not selected
+	resp, audio1 := testFullRequest(t, ts, "GET", prefix+"/testpic_2s/A48/21.m4s?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, audio2 := testFullRequest(t, ts, "GET", "/livesim2/testpic_2s/A48/21.m4s?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, audio1, audio2)
+
+	resp, body := testFullRequest(t, ts, "GET", prefix+"/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	mpd := string(body)
+	require.Contains(t, mpd, `<InbandEventStream schemeIdUri="urn:livesim2:blackout:2024"`)
+	require.Contains(t, mpd, `<Event presentationTime="40000" duration="10000" id="40" messageData="start"`)
+	require.Contains(t, mpd, `<Event presentationTime="50000" id="50" messageData="end"`)
+
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/blackout_40_30/testpic_2s/Manifest.mpd", nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// Blackouts started and ended via the session API
+	resp, body = testFullRequest(t, ts, "POST", "/api/sessions", strings.NewReader(`{"config": {}}`))
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+	var info SessionInfo
+	require.NoError(t, json.Unmarshal(body, &info))
+	blackoutPath := "/api/sessions/" + info.ID + "/blackout"
+	resp, body = testFullRequest(t, ts, "POST", blackoutPath, strings.NewReader(`{"startS": 40, "durS": 10}`))
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	bo1 = getSeg(info.URLPrefix, "V300", 40)
+	require.Len(t, bo1.Fragments[0].Emsgs, 1)
+	resp, audio1 = testFullRequest(t, ts, "GET", info.URLPrefix+"/testpic_2s/A48/21.m4s?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NotEqual(t, audio1, audio2)
+
+	// Ending a blackout that has already ended does not change it
+	resp, body = testFullRequest(t, ts, "DELETE", blackoutPath, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	var bo Blackout
+	require.NoError(t, json.Unmarshal(body, &bo))
+	require.Equal(t, Blackout{StartS: 40, EndS: 50}, bo)
+
+	// A scheduled blackout is canceled
+	future := time.Now().Unix() + 3600
+	resp, _ = testFullRequest(t, ts, "POST", blackoutPath, strings.NewReader(fmt.Sprintf(`{"startS": %d}`, future)))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = testFullRequest(t, ts, "DELETE", blackoutPath, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, body = testFullRequest(t, ts, "GET", "/api/sessions/"+info.ID, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NotContains(t, string(body), "Blackout")
+	resp, _ = testFullRequest(t, ts, "DELETE", blackoutPath, nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// An ongoing blackout ends now
+	body = []byte(fmt.Sprintf(`{"startS": %d, "reps": ["A48"]}`, time.Now().Unix()-10))
+	resp, _ = testFullRequest(t, ts, "POST", blackoutPath, bytes.NewReader(body))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, body = testFullRequest(t, ts, "DELETE", blackoutPath, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.Unmarshal(body, &bo))
+	require.Greater(t, bo.EndS, bo.StartS)
+	require.Equal(t, []string{"A48"}, bo.Reps)
+}
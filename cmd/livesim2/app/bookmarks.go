@@ -0,0 +1,217 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bookmarkMarginS is added to the time-shift buffer of catch-up URLs, so that the bookmark
+// stays in the window while the player starts.
+const bookmarkMarginS = 30
+
+var errBookmarkExists = errors.New("bookmark exists")
+
+// BookmarkSetup names a moment of a live stream.
+type BookmarkSetup struct {
+	Name  string `json:"name" pattern:"^[A-Za-z0-9_.-]+$" maxLength:"64" doc:"Unique name of the bookmark" example:"bug-1234"`
+	URL   string `json:"livesimURL" doc:"livesim2 MPD URL (path and query)" example:"/livesim2/segtimeline_1/testpic_2s/Manifest.mpd"`
+	NowMS *int   `json:"nowMS,omitempty" minimum:"0" doc:"Wall-clock time (ms) of the bookmark (default now)"`
+	Note  string `json:"note,omitempty" doc:"Free text, e.g. a bug report reference"`
+}
+
+// Bookmark is a live moment with the latest available segment of every representation.
+// It can be shared in bug reports, and played from later via its catch-up URL.
+type Bookmark struct {
+	Name        string            `json:"name"`
+	URL         string            `json:"livesimURL"`
+	Note        string            `json:"note,omitempty"`
+	AssetPath   string            `json:"assetPath"`
+	MPDName     string            `json:"mpdName"`
+	NowMS       int               `json:"nowMS" doc:"Wall-clock time (ms) of the bookmark"`
+	WallClock   time.Time         `json:"wallClock"`
+	Segments    []BookmarkSegment `json:"segments" doc:"Latest available segment per representation"`
+	SnapshotURL string            `json:"snapshotURL" doc:"URL giving the MPD exactly as at the bookmark"`
+	CatchupURL  string            `json:"catchupURL,omitempty" doc:"URL of the live MPD now, with the bookmark in the window and an MPD anchor at it"`
+}
+
+// BookmarkSegment is the latest available segment of a representation at the bookmark.
+type BookmarkSegment struct {
+	RepID       string `json:"repId"`
+	ContentType string `json:"contentType"`
+	Number      uint64 `json:"number"`
+	Time        uint64 `json:"time"`
+	Timescale   uint32 `json:"timescale"`
+	URL         string `json:"url"`
+}
+
+type bookmarkStore struct {
+	mu        sync.Mutex
+	bookmarks map[string]Bookmark
+}
+
+func newBookmarkStore() *bookmarkStore {
+	return &bookmarkStore{bookmarks: make(map[string]Bookmark)}
+}
+
+func (bs *bookmarkStore) add(b Bookmark) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if _, ok := bs.bookmarks[b.Name]; ok {
+		return errBookmarkExists
+	}
+	bs.bookmarks[b.Name] = b
+	return nil
+}
+
+func (bs *bookmarkStore) get(name string) (Bookmark, bool) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	b, ok := bs.bookmarks[name]
+	return b, ok
+}
+
+func (bs *bookmarkStore) delete(name string) bool {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	_, ok := bs.bookmarks[name]
+	delete(bs.bookmarks, name)
+	return ok
+}
+
+// list returns all bookmarks sorted by time.
+func (bs *bookmarkStore) list() []Bookmark {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	list := make([]Bookmark, 0, len(bs.bookmarks))
+	for _, b := range bs.bookmarks {
+		list = append(list, b)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].NowMS != list[j].NowMS {
+			return list[i].NowMS < list[j].NowMS
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// newBookmark resolves the live MPD URL at the bookmark time and finds the latest segments.
+func (s *Server) newBookmark(setup BookmarkSetup) (Bookmark, error) {
+	b := Bookmark{Name: setup.Name, Note: setup.Note}
+	u, err := url.Parse(setup.URL)
+	if err != nil {
+		return b, fmt.Errorf("parse url: %w", err)
+	}
+	if !strings.HasPrefix(u.Path, "/livesim2/") || path.Ext(u.Path) != ".mpd" {
+		return b, fmt.Errorf("url must be a /livesim2/ MPD URL")
+	}
+	b.NowMS = int(time.Now().UnixMilli())
+	if setup.NowMS != nil {
+		b.NowMS = *setup.NowMS
+	}
+	q := u.Query()
+	q.Del("nowMS")
+	u.RawQuery = q.Encode()
+	b.URL = u.RequestURI()
+	q.Set("nowMS", strconv.Itoa(b.NowMS))
+	u.RawQuery = q.Encode()
+	b.SnapshotURL = u.RequestURI()
+	b.WallClock = time.UnixMilli(int64(b.NowMS)).UTC()
+
+	log := s.logger.With("bookmark", setup.Name)
+	req := httptest.NewRequest("GET", b.SnapshotURL, nil)
+	nowMS, cfg, errHT := cfgFromRequest(req, log, s.liveSessions())
+	if errHT == nil {
+		errHT = s.validateURLParams(log, cfg)
+	}
+	if errHT != nil {
+		return b, errHT
+	}
+	contentPart := cfg.URLContentPart()
+	a, ok := s.assetMgr.findAsset(contentPart)
+	if !ok {
+		return b, fmt.Errorf("unknown asset %q", contentPart)
+	}
+	if a, err = configuredAsset(s.assetMgr.vodFS, a, cfg); err != nil {
+		return b, err
+	}
+	b.AssetPath = a.AssetPath
+	_, b.MPDName = path.Split(contentPart)
+	lMPD, err := LiveMPD(a, b.MPDName, cfg, s.Cfg.DrmCfg, nowMS)
+	if err != nil {
+		return b, fmt.Errorf("live MPD: %w", err)
+	}
+	removeMetricsReporting(lMPD)
+	cl, err := cmafListingFromMPD(lMPD, b.MPDName, nowMS)
+	if err != nil {
+		return b, err
+	}
+	for _, tr := range cl.Tracks {
+		if len(tr.Segments) == 0 {
+			continue
+		}
+		seg := tr.Segments[len(tr.Segments)-1]
+		b.Segments = append(b.Segments, BookmarkSegment{
+			RepID:       tr.RepID,
+			ContentType: tr.ContentType,
+			Number:      seg.Number,
+			Time:        seg.Time,
+			Timescale:   tr.Timescale,
+			URL:         seg.URL,
+		})
+	}
+	return b, nil
+}
+
+// catchupURL returns the live MPD URL with a time-shift buffer that reaches back to the bookmark
+// at nowMS, and an MPD anchor (#t=posix:) at the bookmark, so that players start there.
+// It returns an empty string if the bookmark is in the future or too old.
+func (b *Bookmark) catchupURL(nowMS int) string {
+	if nowMS < b.NowMS {
+		return ""
+	}
+	tsbdS := int(math.Ceil(float64(nowMS-b.NowMS)/1000)) + bookmarkMarginS
+	if tsbdS > MAX_TIME_SHIFT_BUFFER_DEPTH_S {
+		return ""
+	}
+	u, err := url.Parse(b.URL)
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(strings.TrimPrefix(u.Path, "/livesim2/"), "/")
+	// Only the URL parameters before the asset path and MPD name may be changed
+	nrCfgParts := len(parts) - len(strings.Split(b.AssetPath, "/")) - 1
+	if nrCfgParts < 0 {
+		return ""
+	}
+	tsbdPart := fmt.Sprintf("tsbd_%d", tsbdS)
+	replaced := false
+	for i, p := range parts[:nrCfgParts] {
+		if val, ok := strings.CutPrefix(p, "tsbd_"); ok {
+			if cur, err := strconv.Atoi(val); err == nil && cur >= tsbdS {
+				tsbdPart = p
+			}
+			parts[i] = tsbdPart
+			replaced = true
+		}
+	}
+	if !replaced {
+		parts = append([]string{tsbdPart}, parts...)
+	}
+	u.Path = "/livesim2/" + strings.Join(parts, "/")
+	u.Fragment = fmt.Sprintf("t=posix:%.3f", float64(b.NowMS)/1000)
+	return u.String()
+}
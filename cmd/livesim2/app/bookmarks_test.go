@@ -0,0 +1,80 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBookmarks(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	body := `{"name": "bug-1234", "livesimURL": "/livesim2/testpic_2s/Manifest.mpd", "nowMS": 100000, "note": "stall"}`
+	resp, respBody := testFullRequest(t, ts, "POST", "/api/bookmarks", strings.NewReader(body))
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(respBody))
+	var b Bookmark
+	require.NoError(t, json.Unmarshal(respBody, &b))
+	require.Equal(t, "testpic_2s", b.AssetPath)
+	require.Equal(t, "Manifest.mpd", b.MPDName)
+	require.Equal(t, "/livesim2/testpic_2s/Manifest.mpd?nowMS=100000", b.SnapshotURL)
+	var found bool
+	for _, seg := range b.Segments {
+		if seg.RepID == "V300" {
+			found = true
+			require.Equal(t, uint64(49), seg.Number)
+		}
+	}
+	require.True(t, found)
+	// The bookmark is more than 48h ago, so it is out of reach for a catch-up MPD
+	require.Equal(t, "", b.CatchupURL)
+
+	resp, _ = testFullRequest(t, ts, "POST", "/api/bookmarks", strings.NewReader(body))
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	resp, respBody = testFullRequest(t, ts, "GET", "/api/bookmarks", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var list []Bookmark
+	require.NoError(t, json.Unmarshal(respBody, &list))
+	require.Len(t, list, 1)
+
+	resp, _ = testFullRequest(t, ts, "DELETE", "/api/bookmarks/bug-1234", nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, _ = testFullRequest(t, ts, "GET", "/api/bookmarks/bug-1234", nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// A bookmark now can be played from via a redirect to the catch-up MPD
+	body = `{"name": "now", "livesimURL": "/livesim2/segtimeline_1/tsbd_10/testpic_2s/Manifest.mpd"}`
+	resp, respBody = testFullRequest(t, ts, "POST", "/api/bookmarks", strings.NewReader(body))
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(respBody))
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(ts.URL + "/api/bookmarks/now/mpd")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	loc := resp.Header.Get("Location")
+	require.True(t, strings.HasPrefix(loc, "/livesim2/segtimeline_1/tsbd_"), loc)
+	require.NotContains(t, loc, "tsbd_10/")
+	require.Contains(t, loc, "/testpic_2s/Manifest.mpd#t=posix:")
+
+	resp, _ = testFullRequest(t, ts, "POST", "/api/bookmarks",
+		strings.NewReader(`{"name": "bad", "livesimURL": "/livesim2/testpic_2s/V300/1.m4s"}`))
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestBookmarkCatchupURL(t *testing.T) {
+	b := Bookmark{URL: "/livesim2/tsbd_3600/testpic_2s/Manifest.mpd?foo=1", AssetPath: "testpic_2s", NowMS: 100_000}
+	require.Equal(t, "/livesim2/tsbd_3600/testpic_2s/Manifest.mpd?foo=1#t=posix:100.000", b.catchupURL(200_000))
+	require.Equal(t, "/livesim2/tsbd_3631/testpic_2s/Manifest.mpd?foo=1#t=posix:100.000", b.catchupURL(3_700_500))
+	require.Equal(t, "", b.catchupURL(50_000))
+	b = Bookmark{URL: "/livesim2/tsbd_1/Manifest.mpd", AssetPath: "tsbd_1", NowMS: 0}
+	require.Equal(t, "/livesim2/tsbd_40/tsbd_1/Manifest.mpd#t=posix:0.000", b.catchupURL(10_000))
+}
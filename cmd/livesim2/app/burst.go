@@ -0,0 +1,36 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"math"
+)
+
+// burstAvailTimeS returns the availability time of a segment with regular availability time availTimeS
+// when segments are published in bursts every cfg.BurstS seconds after availabilityStartTime.
+// The segment becomes available at the first burst not earlier than availTimeS.
+func (rc *ResponseConfig) burstAvailTimeS(availTimeS float64) float64 {
+	if rc.BurstS == nil {
+		return availTimeS
+	}
+	periodS := float64(*rc.BurstS)
+	startS := float64(rc.StartTimeS)
+	nrBursts := math.Ceil((availTimeS-startS)/periodS - 1e-9)
+	return startS + nrBursts*periodS
+}
+
+// burstNowMS returns the time of the latest burst at or before nowMS, so that the MPD only
+// describes segments that are published.
+func (rc *ResponseConfig) burstNowMS(nowMS int) int {
+	if rc.BurstS == nil {
+		return nowMS
+	}
+	periodMS := *rc.BurstS * 1000
+	relMS := nowMS - rc.StartTimeS*1000
+	if relMS < 0 {
+		return nowMS
+	}
+	return nowMS - relMS%periodMS
+}
@@ -0,0 +1,61 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBurstTimes(t *testing.T) {
+	cfg := ResponseConfig{BurstS: Ptr(6), StartTimeS: 10}
+	cases := []struct {
+		availTimeS, wantedS float64
+	}{
+		{availTimeS: 12, wantedS: 16},
+		{availTimeS: 14, wantedS: 16},
+		{availTimeS: 16, wantedS: 16},
+		{availTimeS: 16.5, wantedS: 22},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.wantedS, cfg.burstAvailTimeS(c.availTimeS), c.availTimeS)
+	}
+	require.Equal(t, 16_000, cfg.burstNowMS(16_000))
+	require.Equal(t, 16_000, cfg.burstNowMS(21_999))
+	require.Equal(t, 5_000, cfg.burstNowMS(5_000))
+	require.Equal(t, 21_999, (&ResponseConfig{}).burstNowMS(21_999))
+}
+
+func TestBurstPublication(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	// The MPD is generated at the latest burst
+	resp, burstMPD := testFullRequest(t, ts, "GET", "/livesim2/segtimeline_1/burst_6/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, refMPD := testFullRequest(t, ts, "GET", "/livesim2/segtimeline_1/testpic_2s/Manifest.mpd?nowMS=96000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, string(refMPD), string(burstMPD))
+
+	// Segments 45-47 end at 92, 94, and 96s and are published together at 96s
+	cases := []struct {
+		url          string
+		wantedStatus int
+	}{
+		{url: "/livesim2/burst_6/testpic_2s/V300/47.m4s?nowMS=96000", wantedStatus: http.StatusOK},
+		{url: "/livesim2/burst_6/testpic_2s/V300/45.m4s?nowMS=95999", wantedStatus: http.StatusTooEarly},
+		{url: "/livesim2/burst_6/testpic_2s/V300/48.m4s?nowMS=100000", wantedStatus: http.StatusTooEarly},
+		{url: "/livesim2/burst_6/testpic_2s/V300/48.m4s?nowMS=102000", wantedStatus: http.StatusOK},
+		{url: "/livesim2/segtimeline_1/burst_6/testpic_2s/V300/8460000.m4s?nowMS=100000", wantedStatus: http.StatusOK},
+		{url: "/livesim2/segtimeline_1/burst_6/testpic_2s/V300/8640000.m4s?nowMS=100000", wantedStatus: http.StatusTooEarly},
+		{url: "/livesim2/burst_6/chunkdur_0.5/testpic_2s/Manifest.mpd", wantedStatus: http.StatusBadRequest},
+		{url: "/livesim2/burst_0/testpic_2s/Manifest.mpd", wantedStatus: http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		resp, _ := testFullRequest(t, ts, "GET", c.url, nil)
+		require.Equal(t, c.wantedStatus, resp.StatusCode, c.url)
+	}
+}
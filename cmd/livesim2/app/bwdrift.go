@@ -0,0 +1,64 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"math"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+)
+
+// maxBandwidthDriftPct is the maximal declared @bandwidth in percent of the actual one
+const maxBandwidthDriftPct = 1000
+
+// BandwidthDrift makes the declared @bandwidth of Representations differ from the actual bitrate.
+// The actual bitrate is the average given by the segment sizes of the asset, and is scaled by a percentage.
+type BandwidthDrift struct {
+	// DefaultPct applies to Representations not in RepPct. 0 means unchanged.
+	DefaultPct int `json:"DefaultPct,omitempty"`
+	// RepPct is the percentage per Representation id.
+	RepPct map[string]int `json:"RepPct,omitempty"`
+}
+
+// validate checks that the percentages are in range and that the repIDs are not empty.
+func (bd *BandwidthDrift) validate() error {
+	if bd.DefaultPct < 0 || bd.DefaultPct > maxBandwidthDriftPct {
+		return fmt.Errorf("bwdrift pct=%d must be in range 1-%d", bd.DefaultPct, maxBandwidthDriftPct)
+	}
+	for repID, pct := range bd.RepPct {
+		if repID == "" {
+			return fmt.Errorf("bwdrift has empty repID")
+		}
+		if pct <= 0 || pct > maxBandwidthDriftPct {
+			return fmt.Errorf("bwdrift pct=%d for %s must be in range 1-%d", pct, repID, maxBandwidthDriftPct)
+		}
+	}
+	return nil
+}
+
+// applyBandwidthDrift sets the @bandwidth values of all Representations to a percentage of the actual bitrate.
+// Representations without a measured bitrate, like synthesized ladder rungs, scale the declared value instead.
+// Values beyond the range of @bandwidth are clamped.
+func applyBandwidthDrift(mpd *m.MPD, a *asset, bd *BandwidthDrift) {
+	for _, p := range mpd.Periods {
+		for _, as := range p.AdaptationSets {
+			for _, rep := range as.Representations {
+				pct, ok := bd.RepPct[rep.Id]
+				if !ok {
+					pct = bd.DefaultPct
+				}
+				if pct == 0 {
+					continue
+				}
+				actual := uint64(rep.Bandwidth)
+				if rd, ok := a.Reps[rep.Id]; ok && rd.avgBitrate > 0 {
+					actual = rd.avgBitrate
+				}
+				rep.Bandwidth = uint32(min(actual*uint64(pct)/100, math.MaxUint32))
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"math"
+	"net/http"
+	"testing"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBandwidthDrift(t *testing.T) {
+	server, ts := newTestServer(t, nil)
+
+	a, ok := server.assetMgr.findAsset("testpic_2s")
+	require.True(t, ok)
+	v300, a48 := a.Reps["V300"].avgBitrate, a.Reps["A48"].avgBitrate
+	require.Greater(t, v300, uint64(0))
+	require.Greater(t, a48, uint64(0))
+	pct := func(bitrate, pct uint64) uint32 { return uint32(bitrate * pct / 100) }
+
+	// Without bwdrift, the VoD MPD values are kept. Otherwise, the drift is relative to the segment sizes.
+	cases := []struct {
+		drift                 string
+		wantedV300, wantedA48 uint32
+	}{
+		{drift: "", wantedV300: 300000, wantedA48: 48000},
+		{drift: "bwdrift_50/", wantedV300: pct(v300, 50), wantedA48: pct(a48, 50)},
+		{drift: "bwdrift_V300:300/", wantedV300: pct(v300, 300), wantedA48: 48000},
+		{drift: "bwdrift_200,V300:75/", wantedV300: pct(v300, 75), wantedA48: pct(a48, 200)},
+	}
+	for _, c := range cases {
+		resp, body := testFullRequest(t, ts, "GET", "/livesim2/"+c.drift+"testpic_2s/Manifest.mpd?nowMS=100000", nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		mpd, err := m.ReadFromString(string(body))
+		require.NoError(t, err)
+		bw := make(map[string]uint32)
+		for _, as := range mpd.Periods[0].AdaptationSets {
+			for _, rep := range as.Representations {
+				bw[rep.Id] = rep.Bandwidth
+			}
+		}
+		require.Equal(t, c.wantedV300, bw["V300"], c.drift)
+		require.Equal(t, c.wantedA48, bw["A48"], c.drift)
+	}
+}
+
+func TestBandwidthDriftClamp(t *testing.T) {
+	mpd := m.NewMPD("dynamic")
+	p := m.NewPeriod()
+	mpd.AppendPeriod(p)
+	as := m.NewAdaptationSet()
+	p.AppendAdaptationSet(as)
+	rep := m.NewRepresentation()
+	rep.Id = "rung"
+	rep.Bandwidth = math.MaxUint32 / 2
+	as.AppendRepresentation(rep)
+	applyBandwidthDrift(mpd, &asset{}, &BandwidthDrift{DefaultPct: maxBandwidthDriftPct})
+	require.Equal(t, uint32(math.MaxUint32), rep.Bandwidth)
+}
@@ -0,0 +1,159 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+)
+
+// CapabilityFilter describes client capabilities. Representations the client cannot play are removed from the MPD.
+// Zero values mean no limit.
+type CapabilityFilter struct {
+	// Codecs are supported codecs, matching the sample entry (like avc1 or mp4a) or a prefix of the full codecs string.
+	Codecs       []string `json:"Codecs,omitempty"`
+	MaxWidth     int      `json:"MaxWidth,omitempty"`
+	MaxHeight    int      `json:"MaxHeight,omitempty"`
+	MaxBandwidth int      `json:"MaxBandwidth,omitempty"`
+}
+
+// capabilityQueryKeys are the query parameters parsed by parseCapabilityQuery.
+var capabilityQueryKeys = []string{"codecs", "maxwidth", "maxheight", "maxbandwidth"}
+
+// parseCapabilityQuery returns the capabilities declared in the query, or nil if there are none.
+func parseCapabilityQuery(q url.Values) (*CapabilityFilter, error) {
+	found := false
+	for _, key := range capabilityQueryKeys {
+		if q.Has(key) {
+			found = true
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+	cf := CapabilityFilter{}
+	if codecs := q.Get("codecs"); codecs != "" {
+		for _, c := range strings.Split(codecs, ",") {
+			if c = strings.TrimSpace(c); c != "" {
+				cf.Codecs = append(cf.Codecs, c)
+			}
+		}
+	}
+	for key, dst := range map[string]*int{"maxwidth": &cf.MaxWidth, "maxheight": &cf.MaxHeight,
+		"maxbandwidth": &cf.MaxBandwidth} {
+		val := q.Get(key)
+		if val == "" {
+			continue
+		}
+		n, err := strconv.Atoi(val)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("%s=%q is not a positive integer", key, val)
+		}
+		*dst = n
+	}
+	return &cf, nil
+}
+
+// overriddenBy returns cf with the limits set in o replacing those of cf. Either may be nil.
+func (cf *CapabilityFilter) overriddenBy(o *CapabilityFilter) *CapabilityFilter {
+	switch {
+	case o == nil:
+		return cf
+	case cf == nil:
+		return o
+	}
+	r := *cf
+	if len(o.Codecs) > 0 {
+		r.Codecs = o.Codecs
+	}
+	if o.MaxWidth > 0 {
+		r.MaxWidth = o.MaxWidth
+	}
+	if o.MaxHeight > 0 {
+		r.MaxHeight = o.MaxHeight
+	}
+	if o.MaxBandwidth > 0 {
+		r.MaxBandwidth = o.MaxBandwidth
+	}
+	return &r
+}
+
+// supportsCodecs returns true if all codecs in the comma-separated codecs string are supported.
+func (cf *CapabilityFilter) supportsCodecs(codecs string) bool {
+	if len(cf.Codecs) == 0 || codecs == "" {
+		return true
+	}
+	for _, c := range strings.Split(codecs, ",") {
+		c = strings.TrimSpace(c)
+		supported := false
+		for _, s := range cf.Codecs {
+			if strings.HasPrefix(c, s) {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return false
+		}
+	}
+	return true
+}
+
+// supports returns true if the client can play rep in as.
+func (cf *CapabilityFilter) supports(as *m.AdaptationSetType, rep *m.RepresentationType) bool {
+	codecs := rep.Codecs
+	if codecs == "" {
+		codecs = as.Codecs
+	}
+	if !cf.supportsCodecs(codecs) {
+		return false
+	}
+	width, height := rep.Width, rep.Height
+	if width == 0 {
+		width = as.Width
+	}
+	if height == 0 {
+		height = as.Height
+	}
+	switch {
+	case cf.MaxWidth > 0 && int(width) > cf.MaxWidth:
+		return false
+	case cf.MaxHeight > 0 && int(height) > cf.MaxHeight:
+		return false
+	case cf.MaxBandwidth > 0 && int(rep.Bandwidth) > cf.MaxBandwidth:
+		return false
+	}
+	return true
+}
+
+// filterByCapabilities removes Representations the client cannot play, and AdaptationSets that become empty.
+// Returns errNoCapableRep if a Period has no AdaptationSet left.
+func filterByCapabilities(mpd *m.MPD, cf *CapabilityFilter) error {
+	for _, p := range mpd.Periods {
+		ass := p.AdaptationSets[:0]
+		for _, as := range p.AdaptationSets {
+			reps := as.Representations[:0]
+			for _, rep := range as.Representations {
+				if cf.supports(as, rep) {
+					reps = append(reps, rep)
+				}
+			}
+			as.Representations = reps
+			if len(reps) > 0 {
+				ass = append(ass, as)
+			}
+		}
+		p.AdaptationSets = ass
+		if len(ass) == 0 {
+			return newReasonError(reasonBadCombination,
+				fmt.Errorf("period %s: %w", p.Id, errNoCapableRep))
+		}
+	}
+	return nil
+}
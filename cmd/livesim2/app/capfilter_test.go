@@ -0,0 +1,53 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"net/http"
+	"testing"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapabilityFilter(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	cases := []struct {
+		query            string
+		wantedRepIDs     []string
+		wantedStatusCode int
+	}{
+		{query: "", wantedRepIDs: []string{"V300", "A48", "imsc1_img_en", "imsc1_txt_sv"},
+			wantedStatusCode: http.StatusOK},
+		{query: "&codecs=avc1,mp4a", wantedRepIDs: []string{"V300", "A48"}, wantedStatusCode: http.StatusOK},
+		{query: "&codecs=avc1.64,mp4a.40.2,stpp.ttml", wantedRepIDs: []string{"V300", "A48", "imsc1_img_en"},
+			wantedStatusCode: http.StatusOK},
+		{query: "&maxheight=240", wantedRepIDs: []string{"A48", "imsc1_img_en", "imsc1_txt_sv"},
+			wantedStatusCode: http.StatusOK},
+		{query: "&maxwidth=640&maxbandwidth=300000", wantedRepIDs: []string{"V300", "A48", "imsc1_img_en", "imsc1_txt_sv"},
+			wantedStatusCode: http.StatusOK},
+		{query: "&maxbandwidth=299999&codecs=avc1,mp4a", wantedRepIDs: []string{"A48"}, wantedStatusCode: http.StatusOK},
+		{query: "&codecs=hvc1", wantedStatusCode: http.StatusBadRequest},
+		{query: "&maxheight=abc", wantedStatusCode: http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		resp, body := testFullRequest(t, ts, "GET", "/livesim2/testpic_2s/Manifest_imsc1.mpd?nowMS=100000"+c.query, nil)
+		require.Equal(t, c.wantedStatusCode, resp.StatusCode, c.query)
+		if c.wantedStatusCode != http.StatusOK {
+			continue
+		}
+		mpd, err := m.ReadFromString(string(body))
+		require.NoError(t, err)
+		var repIDs []string
+		for _, as := range mpd.Periods[0].AdaptationSets {
+			require.NotEmpty(t, as.Representations)
+			for _, rep := range as.Representations {
+				repIDs = append(repIDs, rep.Id)
+			}
+		}
+		require.ElementsMatch(t, c.wantedRepIDs, repIDs, c.query)
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/Eyevinn/mp4ff/avc"
+	"github.com/Eyevinn/mp4ff/mp4"
+	"github.com/Eyevinn/mp4ff/sei"
+)
+
+// CEA-608/708 closed captions are carried in video SEI NAL units as ATSC A/53 user data
+// (registered ITU-T T.35 SEI with the "GA94" identifier). The sample data is copied unchanged
+// to the live segments, so the captions pass through. Their presence is signaled with
+// Accessibility descriptors, and they can be removed with the ccstrip URL parameter.
+
+const (
+	cea608Scheme = "urn:scte:dash:cc:cea-608:2015"
+	cea708Scheme = "urn:scte:dash:cc:cea-708:2015"
+
+	seiTypeUserDataRegistered = 4
+	ccUndefinedLang           = "und"
+)
+
+// captionSEICodec returns the NAL unit header length and a check for SEI NAL units for a video codec.
+func captionSEICodec(codecs string) (hdrLen int, isSEI func(hdr byte) bool, ok bool) {
+	switch {
+	case strings.HasPrefix(codecs, "avc"):
+		return 1, func(hdr byte) bool { return hdr&0x1f == 6 }, true
+	case strings.HasPrefix(codecs, "hev"), strings.HasPrefix(codecs, "hvc"):
+		return 2, func(hdr byte) bool { return (hdr>>1)&0x3f == 39 }, true // prefix SEI
+	default:
+		return 0, nil, false
+	}
+}
+
+// ccTriplets returns the cc_data triplets of an ATSC A/53 user data SEI payload,
+// or nil if the payload does not carry captions.
+func ccTriplets(sd *sei.SEIData) []byte {
+	if sd.Type() != seiTypeUserDataRegistered {
+		return nil
+	}
+	pl := sd.Payload()
+	// itu_t_t35_country_code (USA), provider_code (ATSC), user_identifier, user_data_type_code
+	if len(pl) < 10 || pl[0] != 0xb5 || binary.BigEndian.Uint16(pl[1:3]) != 0x0031 ||
+		string(pl[3:7]) != "GA94" || pl[7] != 0x03 {
+		return nil
+	}
+	ccCount := int(pl[8] & 0x1f)
+	data := pl[10:]
+	if len(data) > 3*ccCount {
+		data = data[:3*ccCount]
+	}
+	return data
+}
+
+// captionsInSample updates rep with the caption services found in a video sample.
+func (r *RepData) captionsInSample(sample []byte, hdrLen int, isSEI func(byte) bool) {
+	nalus, err := avc.GetNalusFromSample(sample)
+	if err != nil {
+		return
+	}
+	for _, nalu := range nalus {
+		if len(nalu) <= hdrLen || !isSEI(nalu[0]) {
+			continue
+		}
+		msgs, err := sei.ExtractSEIData(bytes.NewReader(nalu[hdrLen:]))
+		if err != nil && len(msgs) == 0 {
+			continue
+		}
+		for i := range msgs {
+			cc := ccTriplets(&msgs[i])
+			for j := 0; j+3 <= len(cc); j += 3 {
+				if cc[j]&0x04 == 0 { // cc_valid
+					continue
+				}
+				switch cc[j] & 0x03 {
+				case 0, 1:
+					if cc[j+1]&0x7f == 0 && cc[j+2]&0x7f == 0 { // Null padding
+						continue
+					}
+					r.addCEA608Channel(fmt.Sprintf("CC%d", 1+2*int(cc[j]&0x03)))
+				default:
+					r.CEA708 = true
+				}
+			}
+		}
+	}
+}
+
+func (r *RepData) addCEA608Channel(ch string) {
+	for _, c := range r.CEA608 {
+		if c == ch {
+			return
+		}
+	}
+	r.CEA608 = append(r.CEA608, ch)
+}
+
+// detectCaptions looks for CEA-608/708 captions in the samples of a video segment.
+// CEA-608 is reported as the first channel of each field (CC1 and CC3).
+func (r *RepData) detectCaptions(seg *mp4.MediaSegment) {
+	hdrLen, isSEI, ok := captionSEICodec(r.Codecs)
+	if !ok || r.PreEncrypted {
+		return
+	}
+	trex := getTrex(r.initSeg)
+	for _, frag := range seg.Fragments {
+		samples, err := frag.GetFullSamples(trex)
+		if err != nil {
+			return
+		}
+		for _, s := range samples {
+			r.captionsInSample(s.Data, hdrLen, isSEI)
+		}
+	}
+}
+
+// stripCaptionSEI returns the sample with all caption SEI messages removed.
+// SEI NAL units with other messages are rewritten, and NAL units with only captions are dropped.
+func stripCaptionSEI(sample []byte, hdrLen int, isSEI func(byte) bool) ([]byte, error) {
+	nalus, err := avc.GetNalusFromSample(sample)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(sample))
+	for _, nalu := range nalus {
+		if len(nalu) > hdrLen && isSEI(nalu[0]) {
+			msgs, err := sei.ExtractSEIData(bytes.NewReader(nalu[hdrLen:]))
+			if err != nil && len(msgs) == 0 {
+				return nil, fmt.Errorf("extract SEI: %w", err)
+			}
+			var kept []sei.SEIMessage
+			for i := range msgs {
+				if ccTriplets(&msgs[i]) == nil {
+					kept = append(kept, sei.DecodeGeneralSEI(&msgs[i]))
+				}
+			}
+			if len(kept) == 0 {
+				continue
+			}
+			if len(kept) < len(msgs) {
+				buf := bytes.Buffer{}
+				buf.Write(nalu[:hdrLen])
+				if err := sei.WriteSEIMessages(&buf, kept); err != nil {
+					return nil, fmt.Errorf("write SEI: %w", err)
+				}
+				nalu = buf.Bytes()
+			}
+		}
+		out = binary.BigEndian.AppendUint32(out, uint32(len(nalu)))
+		out = append(out, nalu...)
+	}
+	return out, nil
+}
+
+// stripCaptions removes CEA-608/708 caption SEI messages from all samples of a video segment.
+// Encrypted fragments are left unchanged.
+func stripCaptions(seg *mp4.MediaSegment, trex *mp4.TrexBox, codecs string) error {
+	hdrLen, isSEI, ok := captionSEICodec(codecs)
+	if !ok {
+		return nil
+	}
+	for _, frag := range seg.Fragments {
+		traf := frag.Moof.Traf
+		if traf.Senc != nil {
+			continue
+		}
+		samples, err := frag.GetFullSamples(trex)
+		if err != nil {
+			return err
+		}
+		mdatData := make([]byte, 0, len(frag.Mdat.Data))
+		sampleNr := 0
+		for _, trun := range traf.Truns {
+			for i := range trun.Samples {
+				data, err := stripCaptionSEI(samples[sampleNr].Data, hdrLen, isSEI)
+				if err != nil {
+					return fmt.Errorf("sample %d: %w", sampleNr+1, err)
+				}
+				trun.Samples[i].Size = uint32(len(data))
+				mdatData = append(mdatData, data...)
+				sampleNr++
+			}
+			trun.Flags |= mp4.TrunSampleSizePresentFlag
+		}
+		frag.Mdat.SetData(mdatData)
+	}
+	return nil
+}
+
+// signalCaptions adds CEA-608/708 Accessibility descriptors to video AdaptationSets with captions,
+// or removes them if the captions are stripped.
+func signalCaptions(mpd *m.MPD, a *asset, strip bool) {
+	for _, p := range mpd.Periods {
+		for _, as := range p.AdaptationSets {
+			var rep *RepData
+			for _, mRep := range as.Representations {
+				if r, ok := a.Reps[mRep.Id]; ok && (len(r.CEA608) > 0 || r.CEA708) {
+					rep = r
+					break
+				}
+			}
+			var accs []*m.DescriptorType
+			has608, has708 := false, false
+			for _, d := range as.Accessibilities {
+				scheme := string(d.SchemeIdUri)
+				if strip && (scheme == cea608Scheme || scheme == cea708Scheme) {
+					continue
+				}
+				has608 = has608 || scheme == cea608Scheme
+				has708 = has708 || scheme == cea708Scheme
+				accs = append(accs, d)
+			}
+			if !strip && rep != nil {
+				lang := as.Lang
+				if len(lang) != 3 {
+					lang = ccUndefinedLang
+				}
+				if len(rep.CEA608) > 0 && !has608 {
+					vals := make([]string, 0, len(rep.CEA608))
+					for _, ch := range rep.CEA608 {
+						vals = append(vals, ch+"="+lang)
+					}
+					accs = append(accs, m.NewDescriptor(cea608Scheme, strings.Join(vals, ";"), ""))
+				}
+				if rep.CEA708 && !has708 {
+					accs = append(accs, m.NewDescriptor(cea708Scheme, "1=lang:"+lang, ""))
+				}
+			}
+			as.Accessibilities = accs
+		}
+	}
+}
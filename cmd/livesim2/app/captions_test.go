@@ -0,0 +1,132 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/Eyevinn/mp4ff/avc"
+	"github.com/Eyevinn/mp4ff/bits"
+	"github.com/Eyevinn/mp4ff/mp4"
+	"github.com/Eyevinn/mp4ff/sei"
+	"github.com/stretchr/testify/require"
+)
+
+// ccSEIPayload returns an ATSC A/53 user data payload with the given cc_data triplets.
+func ccSEIPayload(triplets ...byte) []byte {
+	pl := []byte{0xb5, 0x00, 0x31, 'G', 'A', '9', '4', 0x03, 0x40 | byte(len(triplets)/3), 0xff}
+	return append(append(pl, triplets...), 0xff)
+}
+
+func avcSample(t *testing.T, seiMsgs ...sei.SEIMessage) []byte {
+	t.Helper()
+	buf := bytes.Buffer{}
+	buf.WriteByte(6)
+	require.NoError(t, sei.WriteSEIMessages(&buf, seiMsgs))
+	seiNalu := buf.Bytes()
+	slice := []byte{0x65, 0x88, 0x84, 0x00, 0x33}
+	var sample []byte
+	for _, nalu := range [][]byte{seiNalu, slice} {
+		sample = binary.BigEndian.AppendUint32(sample, uint32(len(nalu)))
+		sample = append(sample, nalu...)
+	}
+	return sample
+}
+
+func TestCaptionsDetectAndStrip(t *testing.T) {
+	hdrLen, isSEI, ok := captionSEICodec("avc1.64001e")
+	require.True(t, ok)
+	cc := sei.NewSEIData(seiTypeUserDataRegistered, ccSEIPayload(
+		0xfc, 0x94, 0x2c, // CEA-608 field 1
+		0xf9, 0x80, 0x80, // CEA-608 field 2, null padding
+		0xff, 0x02, 0x21, // DTVCC packet start
+	))
+	other := sei.NewSEIData(5, bytes.Repeat([]byte{0x11}, 17))
+
+	r := RepData{}
+	r.captionsInSample(avcSample(t, cc, other), hdrLen, isSEI)
+	require.Equal(t, []string{"CC1"}, r.CEA608)
+	require.True(t, r.CEA708)
+
+	stripped, err := stripCaptionSEI(avcSample(t, cc, other), hdrLen, isSEI)
+	require.NoError(t, err)
+	require.Equal(t, avcSample(t, other), stripped)
+
+	stripped, err = stripCaptionSEI(avcSample(t, cc), hdrLen, isSEI)
+	require.NoError(t, err)
+	nalus, err := avc.GetNalusFromSample(stripped)
+	require.NoError(t, err)
+	require.Len(t, nalus, 1)
+	require.Equal(t, avc.NALU_IDR, avc.GetNaluType(nalus[0][0]))
+
+	// Segment round trip with sample sizes and mdat rewritten
+	frag, err := mp4.CreateFragment(1, 1)
+	require.NoError(t, err)
+	samples := [][]byte{avcSample(t, cc, other), avcSample(t, other)}
+	samples = append(samples, samples[0])
+	for i, data := range samples {
+		frag.AddFullSample(mp4.FullSample{Sample: mp4.Sample{Dur: 512, Size: uint32(len(data))},
+			DecodeTime: uint64(i * 512), Data: data})
+	}
+	seg := mp4.NewMediaSegment()
+	seg.AddFragment(frag)
+	sw := bits.NewFixedSliceWriter(int(seg.Size()))
+	require.NoError(t, seg.EncodeSW(sw))
+	decoded, err := mp4.DecodeFileSR(bits.NewFixedSliceReader(sw.Bytes()))
+	require.NoError(t, err)
+	seg = decoded.Segments[0]
+	trex := &mp4.TrexBox{TrackID: 1}
+	require.NoError(t, stripCaptions(seg, trex, "avc1.64001e"))
+	sw = bits.NewFixedSliceWriter(int(seg.Size()))
+	require.NoError(t, seg.EncodeSW(sw))
+	decoded, err = mp4.DecodeFileSR(bits.NewFixedSliceReader(sw.Bytes()))
+	require.NoError(t, err)
+	fullSamples, err := decoded.Segments[0].Fragments[0].GetFullSamples(trex)
+	require.NoError(t, err)
+	require.Len(t, fullSamples, 3)
+	for _, s := range fullSamples {
+		require.Equal(t, avcSample(t, other), s.Data)
+	}
+}
+
+func TestSignalCaptions(t *testing.T) {
+	a := &asset{Reps: map[string]*RepData{
+		"V300": {ID: "V300", CEA608: []string{"CC1", "CC3"}, CEA708: true},
+		"A48":  {ID: "A48"},
+	}}
+	newMPD := func() *m.MPD {
+		mpd := m.NewMPD("dynamic")
+		p := &m.Period{}
+		video := m.NewAdaptationSet()
+		video.Representations = []*m.RepresentationType{{Id: "V300"}}
+		audio := m.NewAdaptationSet()
+		audio.Representations = []*m.RepresentationType{{Id: "A48"}}
+		p.AdaptationSets = []*m.AdaptationSetType{video, audio}
+		mpd.Periods = []*m.Period{p}
+		return mpd
+	}
+	mpd := newMPD()
+	signalCaptions(mpd, a, false)
+	video := mpd.Periods[0].AdaptationSets[0]
+	require.Len(t, video.Accessibilities, 2)
+	require.Equal(t, "CC1=und;CC3=und", video.Accessibilities[0].Value)
+	require.Equal(t, cea708Scheme, string(video.Accessibilities[1].SchemeIdUri))
+	require.Equal(t, "1=lang:und", video.Accessibilities[1].Value)
+	require.Len(t, mpd.Periods[0].AdaptationSets[1].Accessibilities, 0)
+
+	// Descriptors from the VoD MPD are kept, and not duplicated
+	mpd = newMPD()
+	video = mpd.Periods[0].AdaptationSets[0]
+	video.Accessibilities = []*m.DescriptorType{m.NewDescriptor(cea608Scheme, "CC1=eng", "")}
+	signalCaptions(mpd, a, false)
+	require.Len(t, video.Accessibilities, 2)
+	require.Equal(t, "CC1=eng", video.Accessibilities[0].Value)
+
+	signalCaptions(mpd, a, true)
+	require.Len(t, video.Accessibilities, 0)
+}
@@ -0,0 +1,431 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/Eyevinn/mp4ff/bits"
+	"github.com/Eyevinn/mp4ff/mp4"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// channelPathPrefix starts the content part of livesim2 URLs for channels.
+const channelPathPrefix = "channels/"
+
+// ChannelConfig defines a linear channel that plays a sequence of VoD assets, one Period per item.
+// The sequence starts at availabilityStartTime and is repeated forever.
+type ChannelConfig struct {
+	Name  string        `json:"name"`
+	Items []ChannelItem `json:"items"`
+}
+
+// ChannelItem is one entry in the sequence of a channel.
+type ChannelItem struct {
+	// Asset is the asset path
+	Asset string `json:"asset"`
+	// Title is the programme title in the EPG. Empty means the asset path.
+	Title string `json:"title,omitempty"`
+	// DurS limits the item to the first DurS seconds of the asset. 0 means the full asset.
+	DurS int `json:"durS,omitempty"`
+	// Count is the number of times the asset, or its first DurS seconds, is looped in the item. 0 means 1.
+	Count int `json:"count,omitempty"`
+	// Discontinuity restarts media time and segment numbers at the start of the item.
+	// Otherwise, they continue from the previous item.
+	Discontinuity bool `json:"discontinuity,omitempty"`
+}
+
+// channel is a validated channel with the item assets resolved.
+type channel struct {
+	name      string
+	items     []channelItem
+	loopDurMS int // Duration of one pass through all items
+	segDurMS  int // Common segment duration of all items
+}
+
+type channelItem struct {
+	a             *asset
+	title         string
+	offsetMS      int // Start relative to the start of the sequence
+	durMS         int
+	discontinuity bool
+}
+
+// channelPeriod is one occurrence of a channel item, and corresponds to one Period.
+type channelPeriod struct {
+	nr           int // Period number counted from availabilityStartTime
+	item         *channelItem
+	startMS      int // Wall-clock start time
+	mediaStartMS int // Media time at the start of the Period
+}
+
+func (p channelPeriod) endMS() int {
+	return p.startMS + p.item.durMS
+}
+
+// newChannels validates the channel configurations and resolves their assets.
+func newChannels(cfgs []ChannelConfig, am *assetMgr) (map[string]*channel, error) {
+	channels := make(map[string]*channel, len(cfgs))
+	for _, cc := range cfgs {
+		if cc.Name == "" || strings.Contains(cc.Name, "/") {
+			return nil, fmt.Errorf("channel name %q must be non-empty without /", cc.Name)
+		}
+		if _, ok := channels[cc.Name]; ok {
+			return nil, fmt.Errorf("channel %q defined twice", cc.Name)
+		}
+		if len(cc.Items) == 0 {
+			return nil, fmt.Errorf("channel %q has no items", cc.Name)
+		}
+		ch := channel{name: cc.Name}
+		for i, ci := range cc.Items {
+			a, ok := am.assets[ci.Asset]
+			if !ok {
+				return nil, fmt.Errorf("channel %q item %d: unknown asset %q", cc.Name, i, ci.Asset)
+			}
+			if ci.DurS < 0 || ci.Count < 0 {
+				return nil, fmt.Errorf("channel %q item %d: durS and count must be >= 0", cc.Name, i)
+			}
+			if ci.DurS > 0 {
+				var err error
+				if a, err = a.withLoopDur(ci.DurS * 1000); err != nil {
+					return nil, fmt.Errorf("channel %q item %d: %w", cc.Name, i, err)
+				}
+			}
+			if a.LoopDurMS%1000 != 0 {
+				return nil, fmt.Errorf("channel %q item %d: duration %dms is not whole seconds", cc.Name, i, a.LoopDurMS)
+			}
+			switch {
+			case ch.segDurMS == 0:
+				ch.segDurMS = a.SegmentDurMS
+			case a.SegmentDurMS != ch.segDurMS:
+				return nil, fmt.Errorf("channel %q item %d: segment duration %dms differs from %dms",
+					cc.Name, i, a.SegmentDurMS, ch.segDurMS)
+			}
+			count := max(ci.Count, 1)
+			title := ci.Title
+			if title == "" {
+				title = ci.Asset
+			}
+			ch.items = append(ch.items, channelItem{
+				a:             a,
+				title:         title,
+				offsetMS:      ch.loopDurMS,
+				durMS:         count * a.LoopDurMS,
+				discontinuity: ci.Discontinuity,
+			})
+			ch.loopDurMS += count * a.LoopDurMS
+		}
+		channels[cc.Name] = &ch
+	}
+	return channels, nil
+}
+
+// period returns Period number nr for a channel starting at astMS.
+func (ch *channel) period(nr, astMS int) channelPeriod {
+	loopNr, idx := nr/len(ch.items), nr%len(ch.items)
+	item := &ch.items[idx]
+	return channelPeriod{
+		nr:           nr,
+		item:         item,
+		startMS:      astMS + loopNr*ch.loopDurMS + item.offsetMS,
+		mediaStartMS: ch.mediaStartMS(loopNr, idx),
+	}
+}
+
+// periodAt returns the Period at wall-clock time tMS (>= astMS).
+func (ch *channel) periodAt(tMS, astMS int) channelPeriod {
+	loopNr, relMS := (tMS-astMS)/ch.loopDurMS, (tMS-astMS)%ch.loopDurMS
+	idx := len(ch.items) - 1
+	for idx > 0 && ch.items[idx].offsetMS > relMS {
+		idx--
+	}
+	return ch.period(loopNr*len(ch.items)+idx, astMS)
+}
+
+// mediaStartMS returns the media time at the start of item idx in loop loopNr.
+// It is the time since the latest discontinuity, or since availabilityStartTime if there is none.
+func (ch *channel) mediaStartMS(loopNr, idx int) int {
+	offsetMS := ch.items[idx].offsetMS
+	for j := idx; j >= 0; j-- {
+		if ch.items[j].discontinuity {
+			return offsetMS - ch.items[j].offsetMS
+		}
+	}
+	if loopNr > 0 {
+		for j := len(ch.items) - 1; j > idx; j-- {
+			if ch.items[j].discontinuity {
+				return ch.loopDurMS - ch.items[j].offsetMS + offsetMS
+			}
+		}
+	}
+	return loopNr*ch.loopDurMS + offsetMS
+}
+
+// findChannel returns the channel and the rest of the content part, if the content part is for a channel.
+func (s *Server) findChannel(contentPart string) (*channel, string, bool) {
+	name, rest, ok := strings.Cut(strings.TrimPrefix(contentPart, channelPathPrefix), "/")
+	if !ok || !strings.HasPrefix(contentPart, channelPathPrefix) {
+		return nil, "", false
+	}
+	ch, ok := s.channels[name]
+	return ch, rest, ok
+}
+
+// checkChannelCfg returns an error for URL parameters that are not supported for channels.
+func checkChannelCfg(cfg *ResponseConfig) error {
+	switch {
+	case cfg.PeriodsPerHour != nil:
+		return fmt.Errorf("periods cannot be combined with channels")
+	case cfg.StopTimeS != nil:
+		return fmt.Errorf("stop cannot be combined with channels")
+	case !cfg.AvailabilityTimeCompleteFlag:
+		return fmt.Errorf("chunked low-latency mode cannot be combined with channels")
+	case len(cfg.Traffic) > 0:
+		return fmt.Errorf("traffic cannot be combined with channels")
+	case cfg.LoopS != nil || cfg.SegDurS != nil:
+		return fmt.Errorf("loop and segdur cannot be combined with channels")
+	}
+	return nil
+}
+
+// periodCfg returns the configuration to generate the Period p, which starts as a separate live stream.
+func periodCfg(cfg *ResponseConfig, p channelPeriod) *ResponseConfig {
+	pCfg := *cfg
+	pCfg.StartTimeS = p.startMS / 1000
+	return &pCfg
+}
+
+// writeChannel handles MPD and segment requests for a channel.
+// The requests are counted in the asset statistics with the channel path as asset,
+// and the representations of the item assets.
+func (s *Server) writeChannel(w http.ResponseWriter, r *http.Request, log *slog.Logger, cfg *ResponseConfig,
+	ch *channel, rest string, start time.Time, nowMS int) {
+	if err := checkChannelCfg(cfg); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, reasonBadCombination, err.Error())
+		return
+	}
+	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+	w = ww
+	repID := ""
+	defer func() {
+		s.recordStats(r, ww, start, channelPathPrefix+ch.name, repID)
+	}()
+	var done bool
+	if w, nowMS, done = s.applyFaults(w, r, log, cfg, cfg.URLContentPart(), nowMS); done {
+		return
+	}
+	if filepath.Ext(rest) == ".mpd" {
+		repID = statsMPDRep
+		lMPD, err := s.channelMPD(ch, rest, cfg, nowMS)
+		if err != nil {
+			log.Error("channelMPD", "err", err)
+			writeProblem(w, r, mpdErrorStatus(err), reasonFromError(err, reasonInternal), err.Error())
+			return
+		}
+		mpd, err := writeMPD(log, w, cfg, lMPD, func(lMPD *m.MPD) error { return s.hooks.rewriteMPD(r, lMPD) },
+			s.mpdSigner)
+		if err != nil {
+			log.Error("writeMPD", "err", err)
+			return
+		}
+		s.recordMPD(r, cfg, nowMS, mpd)
+		return
+	}
+	periodPart, segmentPart, ok := strings.Cut(rest, "/")
+	nr, err := strconv.Atoi(strings.TrimPrefix(periodPart, "p"))
+	if !ok || !strings.HasPrefix(periodPart, "p") || err != nil || nr < 0 {
+		writeProblem(w, r, http.StatusNotFound, reasonNotFound, "Not Found")
+		return
+	}
+	p := ch.period(nr, cfg.StartTimeS*1000)
+	repID = statsRepID(p.item.a, segmentPart)
+	if cfg.Redirect != nil {
+		cfg.Redirect.apply(w, r, log, cfg)
+		return
+	}
+	s.writeChannelSegment(w, r, log, cfg, p, segmentPart, nowMS)
+}
+
+// channelMPD generates a multi-period MPD with one Period per item occurrence in the time-shift window.
+// Each Period is generated as a live stream of the item asset starting at the Period start,
+// with media times and segment numbers offset to continue since the latest discontinuity.
+func (s *Server) channelMPD(ch *channel, mpdName string, cfg *ResponseConfig, nowMS int) (*m.MPD, error) {
+	astMS := cfg.StartTimeS * 1000
+	windowStartMS := max(astMS, nowMS-*cfg.TimeShiftBufferDepthS*1000)
+	first := ch.periodAt(windowStartMS, astMS)
+	last := ch.periodAt(nowMS, astMS)
+	var out *m.MPD
+	periods := make([]*m.Period, 0, last.nr-first.nr+1)
+	for nr := first.nr; nr <= last.nr; nr++ {
+		p := ch.period(nr, astMS)
+		pNowMS := min(nowMS, p.endMS())
+		pCfg := periodCfg(cfg, p)
+		pCfg.TimeShiftBufferDepthS = Ptr((pNowMS - max(windowStartMS, p.startMS) + 999) / 1000)
+		pMPD, err := LiveMPD(p.item.a, mpdName, pCfg, s.Cfg.DrmCfg, pNowMS)
+		if err != nil {
+			return nil, fmt.Errorf("channel %s period %d: %w", ch.name, nr, err)
+		}
+		if len(pMPD.Periods) != 1 {
+			return nil, fmt.Errorf("channel %s period %d: %d periods", ch.name, nr, len(pMPD.Periods))
+		}
+		period := pMPD.Periods[0]
+		period.Id = fmt.Sprintf("P%d", nr)
+		period.Start = Ptr(m.Duration(int64(p.startMS-astMS) * 1_000_000))
+		if p.endMS() <= nowMS {
+			period.Duration = Ptr(m.Duration(int64(p.item.durMS) * 1_000_000))
+		}
+		period.BaseURLs = append(period.BaseURLs, m.NewBaseURL(fmt.Sprintf("p%d/", nr)))
+		offsetChannelPeriod(period, p, ch.segDurMS)
+		periods = append(periods, period)
+		out = pMPD
+	}
+	out.AvailabilityStartTime = m.ConvertToDateTime(float64(cfg.StartTimeS))
+	out.TimeShiftBufferDepth = m.Seconds2DurPtr(*cfg.TimeShiftBufferDepthS)
+	out.Periods = nil
+	for _, period := range periods {
+		out.AppendPeriod(period)
+	}
+	return out, nil
+}
+
+// offsetChannelPeriod moves the media times and segment numbers of the Period
+// from starting at zero to starting at the media time of the channel Period.
+func offsetChannelPeriod(period *m.Period, p channelPeriod, segDurMS int) {
+	if p.mediaStartMS == 0 {
+		return
+	}
+	for _, as := range period.AdaptationSets {
+		st := as.SegmentTemplate
+		if st == nil {
+			continue
+		}
+		timescale := uint64(st.GetTimescale())
+		offset := uint64(p.mediaStartMS) * timescale / 1000
+		var pto uint64
+		if st.PresentationTimeOffset != nil {
+			pto = *st.PresentationTimeOffset
+		}
+		st.PresentationTimeOffset = Ptr(pto + offset)
+		if st.SegmentTimeline != nil {
+			for _, s := range st.SegmentTimeline.S {
+				if s.T != nil {
+					s.T = Ptr(*s.T + offset)
+				}
+			}
+		}
+		if st.StartNumber != nil {
+			nrOffset := uint32(p.mediaStartMS / segDurMS)
+			if st.Duration != nil {
+				nrOffset = uint32(offset / uint64(*st.Duration))
+			}
+			st.StartNumber = Ptr(*st.StartNumber + nrOffset)
+		}
+	}
+}
+
+// writeChannelSegment writes an init or media segment of Period p.
+// The segment time or number is converted to the Period's own live stream, and the
+// media times of the generated segment are moved back to the channel media timeline.
+func (s *Server) writeChannelSegment(w http.ResponseWriter, r *http.Request, log *slog.Logger, cfg *ResponseConfig,
+	p channelPeriod, segmentPart string, nowMS int) {
+	a := p.item.a
+	pCfg := periodCfg(cfg, p)
+	fullPart := "/" + segmentPart
+	rep, segID, err := findRepAndSegmentID(a, fullPart)
+	if err != nil { // Possibly an init segment
+		code, err := writeSegment(r.Context(), w, log, pCfg, s.Cfg.DrmCfg, s.assetMgr.vodFS, a, segmentPart,
+			nowMS, s.textTemplates, false)
+		s.finishChannelSegment(w, r, log, code, err)
+		return
+	}
+	timescale := outTimescale(pCfg, rep)
+	offset := uint64(p.mediaStartMS) * timescale / 1000
+	var localID, nrOffset int
+	refRep := rep
+	if rep.ContentType == "audio" && !rep.PreEncrypted {
+		refRep = a.refRep
+	}
+	nrOffset = p.mediaStartMS * len(refRep.Segments) / a.LoopDurMS
+	if pCfg.getRepType(segmentPart) == timeLineTime {
+		localID = segID - int(offset)
+		if localID < 0 || uint64(localID) >= uint64(p.item.durMS)*timescale/1000 {
+			writeProblem(w, r, http.StatusNotFound, reasonNotFound, "Not Found")
+			return
+		}
+	} else {
+		localID = segID - nrOffset
+		if localID < pCfg.getStartNr() || localID-pCfg.getStartNr() >= p.item.durMS*len(refRep.Segments)/a.LoopDurMS {
+			writeProblem(w, r, http.StatusNotFound, reasonNotFound, "Not Found")
+			return
+		}
+	}
+	idx := rep.mediaRegexp.FindStringSubmatchIndex(fullPart)
+	g := 2 * rep.mediaRegexp.SubexpIndex(tmplSegIDGroup)
+	localPart := fullPart[1:idx[g]] + strconv.Itoa(localID) + fullPart[idx[g+1]:]
+	if offset == 0 {
+		code, err := writeSegment(r.Context(), w, log, pCfg, s.Cfg.DrmCfg, s.assetMgr.vodFS, a, localPart,
+			nowMS, s.textTemplates, false)
+		s.finishChannelSegment(w, r, log, code, err)
+		return
+	}
+	rec := httptest.NewRecorder()
+	code, err := writeSegment(r.Context(), rec, log, pCfg, s.Cfg.DrmCfg, s.assetMgr.vodFS, a, localPart,
+		nowMS, s.textTemplates, false)
+	if err != nil || code != 0 {
+		s.finishChannelSegment(w, r, log, code, err)
+		return
+	}
+	data, err := offsetSegmentData(rec.Body.Bytes(), offset, timescale, uint32(nrOffset))
+	if err != nil {
+		s.finishChannelSegment(w, r, log, 0, err)
+		return
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Content-Type", rec.Header().Get("Content-Type"))
+	if _, err := w.Write(data); err != nil {
+		log.Error("write channel segment", "err", err)
+	}
+}
+
+// finishChannelSegment writes a problem response if writing the segment failed or gave a special code.
+func (s *Server) finishChannelSegment(w http.ResponseWriter, r *http.Request, log *slog.Logger, code int, err error) {
+	switch {
+	case err != nil:
+		log.Error("writeSegment", "code", code, "err", err)
+		writeSegmentProblem(w, r, err)
+	case code != 0:
+		writeProblem(w, r, code, reasonTriggeredStatus, "triggered code")
+	}
+}
+
+// offsetSegmentData moves the media times of a media segment by offset, and its sequence numbers by nrOffset.
+func offsetSegmentData(data []byte, offset, timescale uint64, nrOffset uint32) ([]byte, error) {
+	f, err := mp4.DecodeFile(bytes.NewBuffer(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode segment: %w", err)
+	}
+	if len(f.Segments) != 1 {
+		return nil, fmt.Errorf("not 1 but %d segments", len(f.Segments))
+	}
+	seg := f.Segments[0]
+	offsetSegmentTimes(seg, offset, timescale)
+	for _, frag := range seg.Fragments {
+		frag.Moof.Mfhd.SequenceNumber += nrOffset
+	}
+	sw := bits.NewFixedSliceWriter(int(seg.Size()))
+	if err := seg.EncodeSW(sw); err != nil {
+		return nil, fmt.Errorf("encode segment: %w", err)
+	}
+	return sw.Bytes(), nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/Eyevinn/mp4ff/mp4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannels(t *testing.T) {
+	server, ts := newTestServer(t, func(cfg *ServerConfig) {
+		cfg.MPDHistory = 10
+		cfg.Channels = []ChannelConfig{
+			{Name: "linear", Items: []ChannelItem{
+				{Asset: "testpic_2s", Count: 2},
+				{Asset: "testpic_2s", DurS: 4},
+				{Asset: "testpic_2s", DurS: 4, Discontinuity: true},
+			}},
+		}
+	})
+
+	// The sequence is 16s + 4s + 4s = 24s long, and media time restarts at the third item.
+	// At 100s, the window starts at 40s in the second item of the second pass (Period 4),
+	// and the current Period 12 is the first item of the fifth pass with media time 4s.
+	resp, body := testFullRequest(t, ts, "GET", "/livesim2/segtimeline_1/channels/linear/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	mpd, err := m.ReadFromString(string(body))
+	require.NoError(t, err)
+	require.Len(t, mpd.Periods, 9)
+	wantedStarts := []int{40, 44, 48, 64, 68, 72, 88, 92, 96}
+	wantedMediaStarts := []uint64{20, 0, 4, 20, 0, 4, 20, 0, 4}
+	for i, p := range mpd.Periods {
+		require.Equal(t, "P"+strconv.Itoa(i+4), p.Id)
+		require.Equal(t, m.Duration(int64(wantedStarts[i])*1_000_000_000), *p.Start)
+		require.Equal(t, m.AnyURI("p"+strconv.Itoa(i+4)+"/"), p.BaseURLs[0].Value)
+		st := p.AdaptationSets[1].SegmentTemplate
+		var pto uint64
+		if st.PresentationTimeOffset != nil {
+			pto = *st.PresentationTimeOffset
+		}
+		require.Equal(t, wantedMediaStarts[i]*90000, pto, p.Id)
+		require.Equal(t, wantedMediaStarts[i]*90000, *st.SegmentTimeline.S[0].T, p.Id)
+		if i < len(mpd.Periods)-1 {
+			require.NotNil(t, p.Duration)
+		} else {
+			require.Nil(t, p.Duration)
+		}
+	}
+
+	getSeg := func(url string) *mp4.MediaSegment {
+		resp, body := testFullRequest(t, ts, "GET", url, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode, url)
+		f, err := mp4.DecodeFile(bytes.NewBuffer(body))
+		require.NoError(t, err)
+		require.Len(t, f.Segments, 1)
+		return f.Segments[0]
+	}
+
+	// Numbers continue from the media time at the start of the Period
+	seg := getSeg("/livesim2/channels/linear/p12/V300/2.m4s?nowMS=100000")
+	require.Equal(t, uint64(4*90000), seg.Fragments[0].Moof.Traf.Tfdt.BaseMediaDecodeTime())
+	require.Equal(t, uint32(2), seg.Fragments[0].Moof.Mfhd.SequenceNumber)
+	seg = getSeg("/livesim2/segtimeline_1/channels/linear/p9/V300/1080000.m4s?nowMS=100000")
+	require.Equal(t, uint64(12*90000), seg.Fragments[0].Moof.Traf.Tfdt.BaseMediaDecodeTime())
+	seg = getSeg("/livesim2/channels/linear/p11/A48/0.m4s?nowMS=100000")
+	require.Equal(t, uint64(0), seg.Fragments[0].Moof.Traf.Tfdt.BaseMediaDecodeTime())
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/channels/linear/p12/V300/init.mp4?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Channel requests are in the MPD history and asset statistics
+	snaps, ok := server.mpdHistory.get("/livesim2/segtimeline_1/channels/linear/Manifest.mpd")
+	require.True(t, ok)
+	require.Len(t, snaps, 1)
+	stats := server.assetStats.stats(time.Now(), "channels/linear", 60)
+	require.Len(t, stats, 1)
+	require.Equal(t, 5, stats[0].Total.Requests)
+	var reps []string
+	for _, rs := range stats[0].Reps {
+		reps = append(reps, rs.Rep)
+	}
+	require.Equal(t, []string{"A48", statsMPDRep, "V300"}, reps)
+
+	// Segment redirects
+	noRedirect := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err = noRedirect.Get(ts.URL + "/livesim2/redirect_1/channels/linear/p12/V300/2.m4s?nowMS=100000")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	require.Equal(t, "/livesim2/channels/linear/p12/V300/2.m4s?nowMS=100000", resp.Header.Get("Location"))
+
+	testCases := []struct {
+		desc             string
+		url              string
+		wantedStatusCode int
+	}{
+		{"before period", "/livesim2/channels/linear/p12/V300/1.m4s?nowMS=100000", http.StatusNotFound},
+		{"after period", "/livesim2/channels/linear/p11/A48/2.m4s?nowMS=100000", http.StatusNotFound},
+		{"bad period", "/livesim2/channels/linear/x11/A48/0.m4s?nowMS=100000", http.StatusNotFound},
+		{"unknown channel", "/livesim2/channels/other/Manifest.mpd?nowMS=100000", http.StatusNotFound},
+		{"unsupported param", "/livesim2/periods_60/channels/linear/Manifest.mpd?nowMS=100000", http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			resp, _ := testFullRequest(t, ts, "GET", tc.url, nil)
+			require.Equal(t, tc.wantedStatusCode, resp.StatusCode)
+		})
+	}
+}
+
+func TestChannelValidation(t *testing.T) {
+	am := newAssetMgr(nil, "", false)
+	am.assets["a"] = &asset{AssetPath: "a", LoopDurMS: 8000, SegmentDurMS: 2000}
+	am.assets["b"] = &asset{AssetPath: "b", LoopDurMS: 8000, SegmentDurMS: 4000}
+	am.assets["c"] = &asset{AssetPath: "c", LoopDurMS: 8500, SegmentDurMS: 2000}
+	testCases := []struct {
+		desc      string
+		cfgs      []ChannelConfig
+		wantedErr string
+	}{
+		{"no name", []ChannelConfig{{Items: []ChannelItem{{Asset: "a"}}}}, "non-empty"},
+		{"twice", []ChannelConfig{{Name: "x", Items: []ChannelItem{{Asset: "a"}}}, {Name: "x", Items: []ChannelItem{{Asset: "a"}}}}, "twice"},
+		{"no items", []ChannelConfig{{Name: "x"}}, "no items"},
+		{"unknown asset", []ChannelConfig{{Name: "x", Items: []ChannelItem{{Asset: "d"}}}}, "unknown asset"},
+		{"segment durations", []ChannelConfig{{Name: "x", Items: []ChannelItem{{Asset: "a"}, {Asset: "b"}}}}, "differs"},
+		{"fractional seconds", []ChannelConfig{{Name: "x", Items: []ChannelItem{{Asset: "c"}}}}, "whole seconds"},
+		{"negative count", []ChannelConfig{{Name: "x", Items: []ChannelItem{{Asset: "a", Count: -1}}}}, ">= 0"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := newChannels(tc.cfgs, am)
+			require.Error(t, err)
+			require.True(t, strings.Contains(err.Error(), tc.wantedErr), err.Error())
+		})
+	}
+	channels, err := newChannels([]ChannelConfig{{Name: "x", Items: []ChannelItem{{Asset: "a", Count: 3}}}}, am)
+	require.NoError(t, err)
+	require.Equal(t, 24000, channels["x"].loopDurMS)
+}
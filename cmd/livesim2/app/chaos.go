@@ -0,0 +1,145 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	chaosMaxLevel = 10
+	// chaosHeader is set on responses to which a fault has been applied.
+	chaosHeader = "X-Livesim-Chaos"
+	// chaosTruncateDefaultBytes is used if no Content-Length is known when truncating.
+	chaosTruncateDefaultBytes = 1024
+)
+
+// ChaosConfig configures the chaos_<seed>_<level> URL parameter.
+// Level 1-10 gives a fault probability of 5-50% per request.
+type ChaosConfig struct {
+	Seed  uint64 `json:"Seed"`
+	Level int    `json:"Level"`
+}
+
+// validate checks that Level is in range.
+func (cc *ChaosConfig) validate() error {
+	if cc.Level < 1 || cc.Level > chaosMaxLevel {
+		return fmt.Errorf("chaos level %d not in range 1-%d", cc.Level, chaosMaxLevel)
+	}
+	return nil
+}
+
+type chaosFault string
+
+const (
+	chaosNone        chaosFault = ""
+	chaosLatency     chaosFault = "latency"
+	chaosServerError chaosFault = "servererror"
+	chaosTruncate    chaosFault = "truncate"
+	chaosStaleMPD    chaosFault = "stalempd"
+)
+
+// chaosAction is the fault to apply to one request.
+type chaosAction struct {
+	fault    chaosFault
+	delay    time.Duration
+	status   int
+	fraction float64
+	staleMS  int
+}
+
+// action returns the fault for a request. It only depends on the seed, the level, the content part,
+// and the second of nowMS, so the same request at the same time gives the same fault.
+func (cc *ChaosConfig) action(contentPart string, isMPD bool, nowMS int) chaosAction {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(contentPart))
+	rnd := rand.New(rand.NewPCG(cc.Seed, h.Sum64()^uint64(nowMS/1000)))
+	if rnd.IntN(20) >= cc.Level {
+		return chaosAction{fault: chaosNone}
+	}
+	faults := []chaosFault{chaosLatency, chaosServerError, chaosTruncate}
+	if isMPD {
+		faults[2] = chaosStaleMPD
+	}
+	ca := chaosAction{fault: faults[rnd.IntN(len(faults))]}
+	switch ca.fault {
+	case chaosLatency:
+		ca.delay = 500*time.Millisecond + time.Duration(rnd.IntN(cc.Level*300))*time.Millisecond
+	case chaosServerError:
+		ca.status = []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable}[rnd.IntN(3)]
+	case chaosTruncate:
+		ca.fraction = 0.1 + 0.8*rnd.Float64()
+	case chaosStaleMPD:
+		ca.staleMS = (2 + rnd.IntN(cc.Level+1)) * 1000
+	}
+	return ca
+}
+
+// applyChaos applies the chaos fault for the request.
+// It returns the response writer and nowMS to continue with, or done if the response has been written.
+func applyChaos(w http.ResponseWriter, r *http.Request, log *slog.Logger, cc *ChaosConfig, contentPart string,
+	isMPD bool, nowMS int) (http.ResponseWriter, int, bool) {
+	ca := cc.action(contentPart, isMPD, nowMS)
+	if ca.fault == chaosNone {
+		return w, nowMS, false
+	}
+	log.Debug("chaos", "fault", ca.fault, "url", contentPart)
+	w.Header().Set(chaosHeader, string(ca.fault))
+	switch ca.fault {
+	case chaosLatency:
+		addServerTimingWait(r.Context(), ca.delay)
+		select {
+		case <-time.After(ca.delay):
+		case <-r.Context().Done():
+			return w, nowMS, true
+		}
+	case chaosServerError:
+		writeProblem(w, r, ca.status, reasonTriggeredStatus, fmt.Sprintf("chaos %d", ca.status))
+		return w, nowMS, true
+	case chaosTruncate:
+		return &truncatingWriter{ResponseWriter: w, fraction: ca.fraction, remaining: -1}, nowMS, false
+	case chaosStaleMPD:
+		nowMS -= ca.staleMS
+	}
+	return w, nowMS, false
+}
+
+// truncatingWriter silently drops everything after a fraction of the Content-Length.
+// The HTTP server then closes the connection since fewer bytes than announced are sent.
+type truncatingWriter struct {
+	http.ResponseWriter
+	fraction  float64
+	remaining int
+}
+
+func (tw *truncatingWriter) Write(p []byte) (int, error) {
+	if tw.remaining < 0 {
+		tw.remaining = chaosTruncateDefaultBytes
+		if cl, err := strconv.Atoi(tw.Header().Get("Content-Length")); err == nil {
+			tw.remaining = int(float64(cl) * tw.fraction)
+		}
+	}
+	n := min(len(p), tw.remaining)
+	if n > 0 {
+		if _, err := tw.ResponseWriter.Write(p[:n]); err != nil {
+			return 0, err
+		}
+		tw.remaining -= n
+	}
+	return len(p), nil
+}
+
+// Flush makes chunked low-latency segments work with truncation.
+func (tw *truncatingWriter) Flush() {
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
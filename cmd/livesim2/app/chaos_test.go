@@ -0,0 +1,79 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChaosAction(t *testing.T) {
+	cc := ChaosConfig{Seed: 42, Level: 4}
+	counts := make(map[chaosFault]int)
+	for nowMS := 0; nowMS < 1_000_000; nowMS += 1000 {
+		ca := cc.action("testpic_2s/V300/10.m4s", false, nowMS)
+		require.Equal(t, ca, cc.action("testpic_2s/V300/10.m4s", false, nowMS), "not reproducible")
+		counts[ca.fault]++
+	}
+	require.Equal(t, 0, counts[chaosStaleMPD])
+	require.InDelta(t, 800, counts[chaosNone], 60, "level 4 should give 20% faults")
+	for _, f := range []chaosFault{chaosLatency, chaosServerError, chaosTruncate} {
+		require.Greater(t, counts[f], 30, f)
+	}
+	other := ChaosConfig{Seed: 43, Level: 4}
+	nrDiff := 0
+	for nowMS := 0; nowMS < 100_000; nowMS += 1000 {
+		if cc.action("a.m4s", false, nowMS).fault != other.action("a.m4s", false, nowMS).fault {
+			nrDiff++
+		}
+	}
+	require.Greater(t, nrDiff, 0, "seed should matter")
+}
+
+func TestChaosResponses(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	cc := ChaosConfig{Seed: 7, Level: 10}
+	seen := make(map[chaosFault]bool)
+	for nowMS := 100_000; nowMS < 200_000; nowMS += 1000 {
+		segPart := fmt.Sprintf("testpic_2s/V300/%d.m4s", nowMS/2000-5)
+		for _, cp := range []string{"testpic_2s/Manifest.mpd", segPart} {
+			ca := cc.action(cp, cp == "testpic_2s/Manifest.mpd", nowMS)
+			if ca.fault == chaosLatency {
+				continue
+			}
+			seen[ca.fault] = true
+			url := fmt.Sprintf("%s/livesim2/chaos_7_10/%s?nowMS=%d", ts.URL, cp, nowMS)
+			resp, err := http.Get(url)
+			require.NoError(t, err)
+			require.Equal(t, string(ca.fault), resp.Header.Get(chaosHeader))
+			_, bodyErr := readAll(resp)
+			switch ca.fault {
+			case chaosServerError:
+				require.Equal(t, ca.status, resp.StatusCode)
+			case chaosTruncate:
+				require.Error(t, bodyErr, "truncated body should give unexpected EOF")
+			default:
+				require.Equal(t, http.StatusOK, resp.StatusCode)
+				require.NoError(t, bodyErr)
+			}
+		}
+	}
+	for _, f := range []chaosFault{chaosNone, chaosServerError, chaosTruncate, chaosStaleMPD} {
+		require.True(t, seen[f], f)
+	}
+
+	resp, _ := testFullRequest(t, ts, "GET", "/livesim2/chaos_7_11/testpic_2s/Manifest.mpd", nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func readAll(resp *http.Response) ([]byte, error) {
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,106 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	chunkCadenceFront  = "front"
+	chunkCadenceBack   = "back"
+	chunkCadenceJitter = "jitter"
+	// defaultChunkCadencePct is the strength of the irregularity if not set
+	defaultChunkCadencePct = 50
+	// maxLookaheadMS is the maximal delay of chunk emission
+	maxLookaheadMS = 10_000
+)
+
+var chunkCadenceModes = []string{chunkCadenceFront, chunkCadenceBack, chunkCadenceJitter}
+
+// ChunkCadence varies the emission times of low-latency chunks within a segment, like real encoders do.
+// The last chunk is emitted at the end of the segment, so the segment duration is unchanged.
+// Front-loaded chunks are emitted early and back-loaded late, with Pct (1-100) setting the strength.
+// Jittered chunks are shifted by up to Pct percent of the mean chunk duration,
+// chosen deterministically per Representation and segment number.
+type ChunkCadence struct {
+	Mode string `json:"Mode"`
+	Pct  int    `json:"Pct"`
+}
+
+// validate checks the mode and that Pct is in range.
+func (cc *ChunkCadence) validate() error {
+	if !slices.Contains(chunkCadenceModes, cc.Mode) {
+		return fmt.Errorf("chunkcadence unknown mode %q, allowed: %s", cc.Mode, strings.Join(chunkCadenceModes, ", "))
+	}
+	if cc.Pct <= 0 || cc.Pct > 100 {
+		return fmt.Errorf("chunkcadence pct=%d must be in range 1-100", cc.Pct)
+	}
+	return nil
+}
+
+// chunkEmitEnds returns the emission times of the chunks of segment nr relative to the segment start,
+// in the media timescale of rep. Without chunkcadence and lookahead, each chunk is emitted when it ends.
+// The lookahead delay emulates encoder lookahead and overhead, and delays all chunks of the segment.
+func chunkEmitEnds(cfg *ResponseConfig, rep *RepData, nr uint32, chunks []chunk) []int {
+	ends := make([]int, len(chunks))
+	end := 0
+	for i, chk := range chunks {
+		end += int(chk.dur)
+		ends[i] = end
+	}
+	if cfg.ChunkCadence != nil {
+		ends = cfg.ChunkCadence.emitEnds(rep.ID, nr, ends)
+	}
+	if cfg.LookaheadMS != nil {
+		delay := *cfg.LookaheadMS * rep.MediaTimescale / 1000
+		for i := range ends {
+			ends[i] += delay
+		}
+	}
+	return ends
+}
+
+// emitEnds returns the emission times of chunks ending at ends relative to the segment start.
+// The times are non-decreasing and the last one is the segment end.
+func (cc *ChunkCadence) emitEnds(repID string, nr uint32, ends []int) []int {
+	n := len(ends)
+	if n < 2 {
+		return ends
+	}
+	total := float64(ends[n-1])
+	p := float64(cc.Pct) / 100
+	var rnd *rand.Rand
+	if cc.Mode == chunkCadenceJitter {
+		h := fnv.New64a()
+		h.Write([]byte(repID + "/" + strconv.FormatUint(uint64(nr), 10)))
+		rnd = rand.New(rand.NewPCG(h.Sum64(), uint64(nr)))
+	}
+	out := make([]int, n)
+	prev := 0.0
+	for i, end := range ends[:n-1] {
+		x := float64(end) / total
+		var t float64
+		switch cc.Mode {
+		case chunkCadenceFront:
+			t = x + p*(math.Sqrt(x)-x)
+		case chunkCadenceBack:
+			t = x + p*(x*x-x)
+		case chunkCadenceJitter:
+			t = x + p*(2*rnd.Float64()-1)/float64(n)
+		}
+		t = min(max(t*total, prev), total)
+		out[i] = int(math.Round(t))
+		prev = t
+	}
+	out[n-1] = ends[n-1]
+	return out
+}
@@ -0,0 +1,57 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkCadenceEmitEnds(t *testing.T) {
+	ends := []int{1000, 2000, 3000, 4000}
+	cc := ChunkCadence{Mode: chunkCadenceFront, Pct: 100}
+	require.Equal(t, []int{2000, 2828, 3464, 4000}, cc.emitEnds("V300", 1, ends))
+	cc = ChunkCadence{Mode: chunkCadenceBack, Pct: 100}
+	require.Equal(t, []int{250, 1000, 2250, 4000}, cc.emitEnds("V300", 1, ends))
+	cc = ChunkCadence{Mode: chunkCadenceBack, Pct: 50}
+	require.Equal(t, []int{625, 1500, 2625, 4000}, cc.emitEnds("V300", 1, ends))
+
+	cc = ChunkCadence{Mode: chunkCadenceJitter, Pct: 100}
+	jittered := cc.emitEnds("V300", 1, ends)
+	require.Equal(t, jittered, cc.emitEnds("V300", 1, ends), "deterministic")
+	require.NotEqual(t, ends, jittered)
+	require.Equal(t, 4000, jittered[3])
+	for i := range ends[:3] {
+		require.InDelta(t, ends[i], jittered[i], 1000)
+		if i > 0 {
+			require.GreaterOrEqual(t, jittered[i], jittered[i-1])
+		}
+	}
+	require.Equal(t, []int{2000}, cc.emitEnds("V300", 1, []int{2000}))
+}
+
+func TestChunkEmitEnds(t *testing.T) {
+	rep := &RepData{ID: "V300", MediaTimescale: 1000}
+	chunks := []chunk{{dur: 500}, {dur: 500}, {dur: 1000}}
+	cfg := &ResponseConfig{}
+	require.Equal(t, []int{500, 1000, 2000}, chunkEmitEnds(cfg, rep, 1, chunks))
+	cfg.LookaheadMS = Ptr(300)
+	require.Equal(t, []int{800, 1300, 2300}, chunkEmitEnds(cfg, rep, 1, chunks))
+	cfg.ChunkCadence = &ChunkCadence{Mode: chunkCadenceBack, Pct: 100}
+	require.Equal(t, []int{425, 800, 2300}, chunkEmitEnds(cfg, rep, 1, chunks))
+}
+
+func TestParseChunkCadence(t *testing.T) {
+	sc := newStringConverter()
+	require.Equal(t, &ChunkCadence{Mode: chunkCadenceJitter, Pct: defaultChunkCadencePct}, sc.ParseChunkCadence("chunkcadence", "jitter"))
+	require.Equal(t, &ChunkCadence{Mode: chunkCadenceFront, Pct: 20}, sc.ParseChunkCadence("chunkcadence", "front_20"))
+	require.NoError(t, sc.err)
+	for _, val := range []string{"middle", "back_0", "back_101", "back_x"} {
+		sc = newStringConverter()
+		require.Nil(t, sc.ParseChunkCadence("chunkcadence", val))
+		require.Error(t, sc.err, val)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ingestHeaderData is the data available in ingest header templates.
+type ingestHeaderData struct {
+	// NowS is the wall-clock time of the request in seconds.
+	NowS int64
+	// NowMS is the wall-clock time of the request in milliseconds.
+	NowMS int64
+	// Path is the URL path of the request.
+	Path string
+}
+
+// Expiry returns the wall-clock time in seconds durS seconds after the request.
+func (d ingestHeaderData) Expiry(durS int64) int64 {
+	return d.NowS + durS
+}
+
+var ingestHeaderFuncs = template.FuncMap{
+	"hmacSHA256": func(key, msg string) string {
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(msg))
+		return hex.EncodeToString(mac.Sum(nil))
+	},
+}
+
+// ingestHeader is an extra header that is added to all ingest requests.
+// The value is a text/template that is executed for every request.
+type ingestHeader struct {
+	name string
+	tmpl *template.Template
+}
+
+// parseIngestHeaders parses header value templates. The headers are sorted by name.
+func parseIngestHeaders(headers map[string]string) ([]ingestHeader, error) {
+	ihs := make([]ingestHeader, 0, len(headers))
+	for name, value := range headers {
+		if name == "" || strings.ContainsAny(name, " :\r\n") {
+			return nil, fmt.Errorf("bad header name %q", name)
+		}
+		tmpl, err := template.New(name).Funcs(ingestHeaderFuncs).Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("header %s: %w", name, err)
+		}
+		ihs = append(ihs, ingestHeader{name: http.CanonicalHeaderKey(name), tmpl: tmpl})
+	}
+	sort.Slice(ihs, func(i, j int) bool { return ihs[i].name < ihs[j].name })
+	return ihs, nil
+}
+
+// setIngestHeaders executes the header templates for req and sets the resulting headers.
+// Headers that fail to execute are logged and skipped.
+func setIngestHeaders(log *slog.Logger, req *http.Request, ihs []ingestHeader) {
+	if len(ihs) == 0 {
+		return
+	}
+	now := time.Now()
+	data := ingestHeaderData{
+		NowS:  now.Unix(),
+		NowMS: now.UnixMilli(),
+		Path:  req.URL.Path,
+	}
+	var sb strings.Builder
+	for _, ih := range ihs {
+		sb.Reset()
+		if err := ih.tmpl.Execute(&sb, data); err != nil {
+			log.Warn("ingest header template", "header", ih.name, "err", err)
+			continue
+		}
+		req.Header.Set(ih.name, sb.String())
+	}
+}
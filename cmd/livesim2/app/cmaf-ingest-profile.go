@@ -0,0 +1,68 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// ingestProfile describes the conventions of a CMAF ingest receiver.
+type ingestProfile struct {
+	// method is the HTTP method used for uploading init and media segments.
+	method string
+	// streamsURLs sends all data for a track to Streams(<repID>.<ext>) instead of individual segment URLs.
+	streamsURLs bool
+	// headers are added to all requests. Headers of the ingest setup with the same name take precedence.
+	headers map[string]string
+	// segmentFlush sends media segments when complete, instead of sending every chunk as soon as it is generated.
+	segmentFlush bool
+	// scte35Track is the name of a separate SCTE-35 event message track that is sent when the scte35
+	// URL parameter is used. If empty, SCTE-35 is only sent in-band as emsg in the video track.
+	scte35Track string
+}
+
+const defaultIngestProfile = "dashif"
+
+// ingestProfiles are the supported receiver conventions.
+// dashif follows DASH-IF CMAF Ingest Interface-1 with one PUT per segment, where low-latency chunks
+// are sent as soon as they are generated, and SCTE-35 is sent in-band as emsg in the video track.
+// aws-elemental mimics AWS Elemental MediaLive pushing to MediaPackage, with long-running
+// POST requests to Streams() URLs, complete segments, the MediaLive User-Agent, and SCTE-35 in
+// a separate event message track sent to Streams(scte.cmfm).
+var ingestProfiles = map[string]ingestProfile{
+	"dashif": {method: http.MethodPut},
+	"aws-elemental": {
+		method:       http.MethodPost,
+		streamsURLs:  true,
+		headers:      map[string]string{"User-Agent": "AWS Elemental MediaLive"},
+		segmentFlush: true,
+		scte35Track:  "scte",
+	},
+}
+
+// getIngestProfile returns the profile with name, or the default profile if name is empty.
+func getIngestProfile(name string) (ingestProfile, error) {
+	if name == "" {
+		name = defaultIngestProfile
+	}
+	p, ok := ingestProfiles[name]
+	if !ok {
+		return ingestProfile{}, fmt.Errorf("unknown ingest profile %q", name)
+	}
+	return p, nil
+}
+
+// ingestHeaderValues returns the profile headers overridden by the setup headers.
+func (p ingestProfile) ingestHeaderValues(setupHeaders map[string]string) map[string]string {
+	headers := make(map[string]string, len(p.headers)+len(setupHeaders))
+	for name, value := range p.headers {
+		headers[http.CanonicalHeaderKey(name)] = value
+	}
+	for name, value := range setupHeaders {
+		headers[http.CanonicalHeaderKey(name)] = value
+	}
+	return headers
+}
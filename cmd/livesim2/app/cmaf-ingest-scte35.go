@@ -0,0 +1,82 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"github.com/Dash-Industry-Forum/livesim2/pkg/scte35"
+	"github.com/Eyevinn/mp4ff/bits"
+	"github.com/Eyevinn/mp4ff/mp4"
+)
+
+// encodeBox returns the bytes of box b.
+func encodeBox(b mp4.Box) ([]byte, error) {
+	sw := bits.NewFixedSliceWriter(int(b.Size()))
+	if err := b.EncodeSW(sw); err != nil {
+		return nil, err
+	}
+	return sw.Bytes(), nil
+}
+
+// scte35TrackInit returns the init segment of a SCTE-35 event message track with timescale.
+func scte35TrackInit(timescale uint32) ([]byte, error) {
+	init := mp4.CreateEmptyInit()
+	init.AddEmptyTrack(timescale, "meta", "und")
+	init.Moov.Trak.Mdia.Minf.Stbl.Stsd.AddChild(&mp4.EvteBox{DataReferenceIndex: 1})
+	return getInitBytes(init)
+}
+
+// scte35TrackSegment returns a media segment of the SCTE-35 event message track for the video segment meta.
+// The segment has a single sample covering the video segment with an emib box for each SCTE-35 message
+// that is sent in-band in the video segment, or an emeb box if there is no message.
+func scte35TrackSegment(cfg *ResponseConfig, meta segMeta) ([]byte, error) {
+	st, err := cfg.scte35Type()
+	if err != nil {
+		return nil, err
+	}
+	startTime := meta.newTime
+	emsgs, err := scte35.CreateEmsgsAhead(startTime, startTime+uint64(meta.newDur), uint64(meta.timescale),
+		*cfg.SCTE35PerMinute, st)
+	if err != nil {
+		return nil, err
+	}
+	var boxes []mp4.Box
+	for _, emsg := range emsgs {
+		boxes = append(boxes, &mp4.EmibBox{
+			PresentationTimeDelta: int64(emsg.PresentationTime) - int64(startTime),
+			EventDuration:         emsg.EventDuration,
+			Id:                    emsg.ID,
+			SchemeIdURI:           emsg.SchemeIDURI,
+			Value:                 emsg.Value,
+			MessageData:           emsg.MessageData,
+		})
+	}
+	if len(boxes) == 0 {
+		boxes = append(boxes, &mp4.EmebBox{})
+	}
+	var data []byte
+	for _, b := range boxes {
+		bData, err := encodeBox(b)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, bData...)
+	}
+	seg := mp4.NewMediaSegmentWithStyp(mp4.CreateStyp())
+	frag, err := mp4.CreateFragment(meta.newNr, 1)
+	if err != nil {
+		return nil, err
+	}
+	seg.AddFragment(frag)
+	frag.AddFullSample(mp4.FullSample{
+		Sample:     mp4.NewSample(mp4.SyncSampleFlags, meta.newDur, uint32(len(data)), 0),
+		DecodeTime: startTime,
+		Data:       data,
+	})
+	sw := bits.NewFixedSliceWriter(int(seg.Size()))
+	if err := seg.EncodeSW(sw); err != nil {
+		return nil, err
+	}
+	return sw.Bytes(), nil
+}
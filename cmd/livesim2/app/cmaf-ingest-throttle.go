@@ -0,0 +1,92 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// bwLimiter paces data to a maximum bitrate. It can be shared between concurrent uploads.
+type bwLimiter struct {
+	mu   sync.Mutex
+	bps  float64
+	next time.Time // time when all data so far has been sent at the maximum bitrate
+}
+
+func newBwLimiter(kbps int) *bwLimiter {
+	return &bwLimiter{bps: float64(kbps) * 1000}
+}
+
+// wait blocks until n more bytes have been sent at the maximum bitrate.
+func (l *bwLimiter) wait(ctx context.Context, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n*8) / l.bps * float64(time.Second)))
+	until := l.next
+	l.mu.Unlock()
+	d := time.Until(until)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// throttledReader limits the rate of reading from r by all limiters.
+type throttledReader struct {
+	ctx      context.Context
+	r        io.Reader
+	limiters []*bwLimiter
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	for _, l := range t.limiters {
+		if werr := l.wait(t.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// throttle returns a reader limited by the ingester and representation bandwidth caps, or r if there are none.
+func (c *cmafIngester) throttle(ctx context.Context, r io.Reader, repID string) io.Reader {
+	var limiters []*bwLimiter
+	if c.bwLimiter != nil {
+		limiters = append(limiters, c.bwLimiter)
+	}
+	if l, ok := c.repBwLimiters[repID]; ok {
+		limiters = append(limiters, l)
+	}
+	if len(limiters) == 0 {
+		return r
+	}
+	return &throttledReader{ctx: ctx, r: r, limiters: limiters}
+}
+
+// lateMS returns how many milliseconds after the availability of the next segment
+// an upload finished, or 0 if it finished in time.
+func lateMS(finishMS, availMS, segDurMS int) int {
+	late := finishMS - (availMS + segDurMS)
+	if late < 0 {
+		return 0
+	}
+	return late
+}
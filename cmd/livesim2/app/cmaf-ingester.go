@@ -10,6 +10,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"path"
+	"slices"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -31,10 +33,13 @@ const (
 )
 
 type cmafIngesterMgr struct {
-	nr        atomic.Uint64
+	nr atomic.Uint64
+	s  *Server
+	// mu protects the maps, the manager state, and the ingester states,
+	// since ingesters stop and save state in their own goroutines.
+	mu        sync.Mutex
 	ingesters map[uint64]*cmafIngester
 	state     ingesterState
-	s         *Server
 	cancels   map[uint64]context.CancelFunc
 }
 
@@ -50,6 +55,17 @@ type cmafIngester struct {
 	dur            *int
 	nrSegsToSend   *int // calculate from dur and segDur
 	streamsURLs    bool
+	profile        ingestProfile
+	headers        []ingestHeader
+	startAt        time.Time
+	delay          time.Duration // extra delay after segment availability, used for backup ingesters
+	bwLimiter      *bwLimiter
+	repBwLimiters  map[string]*bwLimiter
+	reportMu       sync.Mutex
+	id             uint64
+	webhook        *webhook
+	trace          traceContext      // shared by all pushes
+	setup          CmafIngesterSetup // kept for persisting the ingester
 	cfg            *ResponseConfig
 	asset          *asset
 	repsData       []cmafRepData
@@ -68,10 +84,15 @@ func NewCmafIngesterMgr(s *Server) *cmafIngesterMgr {
 }
 
 func (cm *cmafIngesterMgr) Start() {
+	cm.mu.Lock()
 	cm.state = ingesterStateRunning
+	cm.mu.Unlock()
 }
 
 func (cm *cmafIngesterMgr) Close() {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.state = ingesterStateStopped
 	for i, cancel := range cm.cancels {
 		if cm.ingesters[i].state == ingesterStateRunning {
 			cancel()
@@ -79,8 +100,44 @@ func (cm *cmafIngesterMgr) Close() {
 	}
 }
 
+// running returns true if the manager has been started and not closed.
+func (cm *cmafIngesterMgr) running() bool {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	return cm.state == ingesterStateRunning
+}
+
+// getIngester returns the ingester with number nr.
+func (cm *cmafIngesterMgr) getIngester(nr uint64) (*cmafIngester, bool) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	c, ok := cm.ingesters[nr]
+	return c, ok
+}
+
+// stopIngester cancels the ingester with number nr if it has been started.
+// It returns false if there is no such ingester.
+func (cm *cmafIngesterMgr) stopIngester(nr uint64) bool {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if _, ok := cm.ingesters[nr]; !ok {
+		return false
+	}
+	if cancel, ok := cm.cancels[nr]; ok {
+		cancel()
+	}
+	return true
+}
+
+// setState sets the state of the ingester.
+func (c *cmafIngester) setState(state ingesterState) {
+	c.mgr.mu.Lock()
+	c.state = state
+	c.mgr.mu.Unlock()
+}
+
 func (cm *cmafIngesterMgr) NewCmafIngester(req CmafIngesterSetup) (nr uint64, err error) {
-	if cm.state != ingesterStateRunning {
+	if !cm.running() {
 		return 0, fmt.Errorf("CMAF ingester manager not running")
 	}
 	for { // Get unique atomic number
@@ -90,28 +147,62 @@ func (cm *cmafIngesterMgr) NewCmafIngester(req CmafIngesterSetup) (nr uint64, er
 			break
 		}
 	}
+	if err := cm.newCmafIngester(nr, req); err != nil {
+		return 0, err
+	}
+	return nr, nil
+}
+
+// newCmafIngester creates an ingester with number nr from req.
+func (cm *cmafIngesterMgr) newCmafIngester(nr uint64, req CmafIngesterSetup) error {
 
-	log := slog.Default().With(slog.Uint64("ingester", nr))
+	profile, err := getIngestProfile(req.Profile)
+	if err != nil {
+		return err
+	}
+	headers, err := parseIngestHeaders(profile.ingestHeaderValues(req.Headers))
+	if err != nil {
+		return fmt.Errorf("ingest headers: %w", err)
+	}
+	startAt, err := ingestStartTime(req.StartAt, time.Now())
+	if err != nil {
+		return err
+	}
+	wh, err := newWebhook(req.Webhook)
+	if err != nil {
+		return err
+	}
+	trace := newTraceContext()
+	if req.TraceParent != "" {
+		var ok bool
+		if trace, ok = parseTraceparent(req.TraceParent); !ok {
+			return fmt.Errorf("invalid traceparent %q", req.TraceParent)
+		}
+	}
+	if req.TraceParent == "" {
+		req.TraceParent, _ = trace.child() // Persisted with the setup
+	}
+	log := cm.s.logger.With(slog.Uint64("ingester", nr), slog.String("trace_id", trace.traceID))
 
 	mpdReq := httptest.NewRequest("GET", req.URL, nil)
 	if req.TestNowMS != nil {
 		mpdReq.URL.RawQuery = fmt.Sprintf("nowMS=%d", *req.TestNowMS)
 	}
-	nowMS, cfg, errHT := cfgFromRequest(mpdReq, log)
+	nowMS, cfg, errHT := cfgFromRequest(mpdReq, log, cm.s.liveSessions())
 	if errHT != nil {
-		return 0, fmt.Errorf("failed to get config from request: %w", errHT)
+		return fmt.Errorf("failed to get config from request: %w", errHT)
 	}
 
 	contentPart := cfg.URLContentPart()
 	log.Debug("CMAF ingest content", "url", contentPart)
 	asset, ok := cm.s.assetMgr.findAsset(contentPart)
 	if !ok {
-		return 0, fmt.Errorf("unknown asset %q", contentPart)
+		return fmt.Errorf("unknown asset %q", contentPart)
 	}
 	_, mpdName := path.Split(contentPart)
 	liveMPD, err := LiveMPD(asset, mpdName, cfg, nil, nowMS)
 	if err != nil {
-		return 0, fmt.Errorf("failed to generate live MPD: %w", err)
+		return fmt.Errorf("failed to generate live MPD: %w", err)
 	}
 
 	// Extract list of all representations with their information
@@ -137,13 +228,13 @@ func (cm *cmafIngesterMgr) NewCmafIngester(req CmafIngesterSetup) (nr uint64, er
 		case "text":
 			mimeType = "application/mp4"
 		default:
-			return 0, fmt.Errorf("unknown content type: %s", contentType)
+			return fmt.Errorf("unknown content type: %s", contentType)
 		}
 		for _, r := range a.Representations {
 			segTmpl := r.GetSegmentTemplate()
 			ext, err := cmaf.CMAFExtensionFromContentType(string(contentType))
 			if err != nil {
-				return 0, fmt.Errorf("error getting CMAF extension: %w", err)
+				return fmt.Errorf("error getting CMAF extension: %w", err)
 			}
 			rd := cmafRepData{
 				repID:        r.Id,
@@ -159,8 +250,23 @@ func (cm *cmafIngesterMgr) NewCmafIngester(req CmafIngesterSetup) (nr uint64, er
 		}
 	}
 
+	if req.MaxKbps < 0 {
+		return fmt.Errorf("maxKbps must be >= 0")
+	}
+	repBwLimiters := make(map[string]*bwLimiter, len(req.RepMaxKbps))
+	for repID, kbps := range req.RepMaxKbps {
+		if kbps <= 0 {
+			return fmt.Errorf("repMaxKbps for %s must be > 0", repID)
+		}
+		if !slices.ContainsFunc(repsData, func(rd cmafRepData) bool { return rd.repID == repID }) {
+			return fmt.Errorf("repMaxKbps: unknown representation %q", repID)
+		}
+		repBwLimiters[repID] = newBwLimiter(kbps)
+	}
+
 	c := cmafIngester{
 		mgr:            cm,
+		setup:          req,
 		user:           req.User,
 		passWord:       req.PassWord,
 		destRoot:       req.DestRoot,
@@ -168,7 +274,14 @@ func (cm *cmafIngesterMgr) NewCmafIngester(req CmafIngesterSetup) (nr uint64, er
 		url:            req.URL,
 		testNowMS:      req.TestNowMS,
 		dur:            req.Duration,
-		streamsURLs:    req.StreamsURLs,
+		streamsURLs:    req.StreamsURLs || profile.streamsURLs,
+		profile:        profile,
+		headers:        headers,
+		startAt:        startAt,
+		repBwLimiters:  repBwLimiters,
+		id:             nr,
+		webhook:        wh,
+		trace:          trace,
 		log:            log,
 		cfg:            cfg,
 		asset:          asset,
@@ -176,15 +289,53 @@ func (cm *cmafIngesterMgr) NewCmafIngester(req CmafIngesterSetup) (nr uint64, er
 		state:          ingesterStateNotStarted,
 		nextSegTrigger: make(chan struct{}),
 	}
+	if req.MaxKbps > 0 {
+		c.bwLimiter = newBwLimiter(req.MaxKbps)
+	}
 	if c.dur != nil {
 		c.nrSegsToSend = m.Ptr(*c.dur * 1000 / asset.SegmentDurMS)
 	}
+	cm.mu.Lock()
 	cm.ingesters[nr] = &c
+	cm.mu.Unlock()
 
-	return nr, nil
+	return nil
+}
+
+// NewCmafIngesterPair creates a main and a backup ingester that start at the same time.
+// The backup pushes every segment offsetMS later than the main ingester.
+// If no start time is given, both start at the next full second.
+func (cm *cmafIngesterMgr) NewCmafIngesterPair(req CmafIngesterPairSetup) (mainNr, backupNr uint64, err error) {
+	if req.OffsetMS < 0 {
+		return 0, 0, fmt.Errorf("offsetMS must be >= 0")
+	}
+	mainSetup := req.Main
+	if mainSetup.StartAt == "" && mainSetup.TestNowMS == nil {
+		mainSetup.StartAt = time.Now().UTC().Truncate(time.Second).Add(time.Second).Format(time.RFC3339)
+	}
+	backupSetup := mainSetup
+	backupSetup.DestRoot = req.BackupDestRoot
+	if req.BackupDestName != "" {
+		backupSetup.DestName = req.BackupDestName
+	}
+	mainNr, err = cm.NewCmafIngester(mainSetup)
+	if err != nil {
+		return 0, 0, fmt.Errorf("main: %w", err)
+	}
+	backupNr, err = cm.NewCmafIngester(backupSetup)
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if err != nil {
+		delete(cm.ingesters, mainNr)
+		return 0, 0, fmt.Errorf("backup: %w", err)
+	}
+	cm.ingesters[backupNr].delay = time.Duration(req.OffsetMS) * time.Millisecond
+	return mainNr, backupNr, nil
 }
 
 func (cm *cmafIngesterMgr) startIngester(nr uint64) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
 	c, ok := cm.ingesters[nr]
 	if !ok {
 		return
@@ -219,9 +370,30 @@ type cmafRepData struct {
 func (c *cmafIngester) start(ctx context.Context) {
 
 	defer func() {
-		c.state = ingesterStateStopped
+		c.setState(ingesterStateStopped)
+		var msg string
+		if len(c.report) > 0 {
+			msg = c.report[len(c.report)-1]
+		}
+		c.notify(eventIngesterStopped, msg)
+		if c.mgr.s != nil {
+			c.mgr.s.archiveIngesterReport(c)
+			if c.mgr.running() {
+				c.mgr.s.saveState()
+			}
+		}
 	}()
 
+	if !c.startAt.IsZero() {
+		c.log.Info("Waiting for start time", "startAt", c.startAt)
+		select {
+		case <-time.After(time.Until(c.startAt)):
+		case <-ctx.Done():
+			c.log.Info("Context done before start time")
+			return
+		}
+	}
+
 	// Finally we should send off the init segments
 	// and then start the loop for sending the media segments
 
@@ -234,7 +406,7 @@ func (c *cmafIngester) start(ctx context.Context) {
 		if ok {
 			if err != nil {
 				msg := fmt.Sprintf("error matching time subs init lang: %v", err)
-				c.report = append(c.report, msg)
+				c.addReport(msg)
 				c.log.Error(msg)
 				return
 			}
@@ -244,7 +416,7 @@ func (c *cmafIngester) start(ctx context.Context) {
 			err := init.EncodeSW(sw)
 			if err != nil {
 				msg := fmt.Sprintf("Error encoding init segment: %v", err)
-				c.report = append(c.report, msg)
+				c.addReport(msg)
 				c.log.Error(msg)
 				return
 			}
@@ -253,19 +425,19 @@ func (c *cmafIngester) start(ctx context.Context) {
 			match, err := matchInit(rd.initPath, c.cfg, c.mgr.s.Cfg.DrmCfg, c.asset)
 			if err != nil {
 				msg := fmt.Sprintf("Error matching init segment: %v", err)
-				c.report = append(c.report, msg)
+				c.addReport(msg)
 				c.log.Error(msg)
 			}
 			if !match.isInit {
 				msg := fmt.Sprintf("Error matching init segment: %v", err)
-				c.report = append(c.report, msg)
+				c.addReport(msg)
 				c.log.Error(msg)
 			}
 			contentType = match.rep.SegmentType()
 			initBin, err = setRawInitProps(match.init, rd, startTimeS)
 			if err != nil {
 				msg := fmt.Sprintf("Error setting init times: %v", err)
-				c.report = append(c.report, msg)
+				c.addReport(msg)
 				c.log.Error(msg)
 			}
 		}
@@ -273,17 +445,32 @@ func (c *cmafIngester) start(ctx context.Context) {
 		err = c.sendInitSegment(ctx, rd, initBin)
 		if err != nil {
 			msg := fmt.Sprintf("error uploading init segment: %v", err)
-			c.report = append(c.report, msg)
+			c.addReport(msg)
 			c.log.Error(msg)
 			nrInitErrors++
 		} else {
 			c.log.Info("Sent init segment", "path", rd.initPath, "contentType", contentType, "size", len(initBin))
-			c.report = append(c.report, fmt.Sprintf("Sent init segment %s", rd.initPath))
+			c.addReport(fmt.Sprintf("Sent init segment %s", rd.initPath))
+		}
+	}
+	if rd, ok := c.scte35Rep(); ok {
+		initBin, err := scte35TrackInit(uint32(c.asset.refRep.MediaTimescale))
+		if err == nil {
+			err = c.sendInitSegment(ctx, rd, initBin)
+		}
+		if err != nil {
+			msg := fmt.Sprintf("error uploading SCTE-35 track init segment: %v", err)
+			c.addReport(msg)
+			c.log.Error(msg)
+			nrInitErrors++
+		} else {
+			c.log.Info("Sent SCTE-35 track init segment", "track", rd.repID, "size", len(initBin))
+			c.addReport(fmt.Sprintf("Sent SCTE-35 track init segment %s", rd.repID))
 		}
 	}
 	if nrInitErrors > 0 {
 		msg := fmt.Sprintf("Number of init errors: %d", nrInitErrors)
-		c.report = append(c.report, msg)
+		c.addReport(msg)
 		c.log.Error("could not upload init segments", "nrErrors", nrInitErrors)
 		return
 	}
@@ -295,7 +482,8 @@ func (c *cmafIngester) start(ctx context.Context) {
 	} else {
 		nowMS = int(time.Now().UnixNano() / 1e6)
 	}
-	c.state = ingesterStateRunning
+	c.setState(ingesterStateRunning)
+	c.notify(eventIngesterStarted, "")
 
 	refRep := c.asset.refRep
 	lastNr := findLastSegNr(c.cfg, c.asset, nowMS, refRep)
@@ -312,7 +500,7 @@ func (c *cmafIngester) start(ctx context.Context) {
 	availabilityTime, err := calcSegmentAvailabilityTime(c.asset, refRep, uint32(nextSegNr), c.cfg)
 	if err != nil {
 		msg := fmt.Sprintf("Error calculating segment availability time: %v", err)
-		c.report = append(c.report, msg)
+		c.addReport(msg)
 		c.log.Error(msg)
 		return
 	}
@@ -320,7 +508,7 @@ func (c *cmafIngester) start(ctx context.Context) {
 	var timer *time.Timer
 	deltaTime := 24 * time.Hour
 	if c.testNowMS == nil {
-		deltaTime = time.Duration(availabilityTime-int64(nowMS)) * time.Millisecond
+		deltaTime = time.Duration(availabilityTime-int64(nowMS))*time.Millisecond + c.delay
 	}
 	timer = time.NewTimer(deltaTime)
 	defer func() {
@@ -349,7 +537,7 @@ func (c *cmafIngester) start(ctx context.Context) {
 		err := c.sendMediaSegments(ctx, nextSegNr, int(availabilityTime), isLast)
 		if err != nil {
 			msg := fmt.Sprintf("Error sending media segments: %v", err)
-			c.report = append(c.report, msg)
+			c.addReport(msg)
 			c.log.Error(msg)
 			return
 		}
@@ -357,7 +545,7 @@ func (c *cmafIngester) start(ctx context.Context) {
 		availabilityTime, err = calcSegmentAvailabilityTime(c.asset, refRep, uint32(nextSegNr), c.cfg)
 		if err != nil {
 			msg := fmt.Sprintf("Error calculating segment availability time: %v", err)
-			c.report = append(c.report, msg)
+			c.addReport(msg)
 			c.log.Error(msg)
 			return
 		}
@@ -367,18 +555,19 @@ func (c *cmafIngester) start(ctx context.Context) {
 
 		c.log.Info("Next segment availability time", "time", availabilityTime)
 		if c.testNowMS == nil {
-			deltaTime := time.Duration(availabilityTime-int64(nowMS)) * time.Millisecond
+			deltaTime := time.Duration(availabilityTime-int64(nowMS))*time.Millisecond + c.delay
 			for {
 				if deltaTime > 0 {
 					break
 				}
 				msg := fmt.Sprintf("Segment availability time in the past: %d", availabilityTime)
-				c.report = append(c.report, msg)
+				c.addReport(msg)
 				c.log.Error(msg)
+				c.notify(eventIngesterFellBehind, msg)
 				err := c.sendMediaSegments(ctx, nextSegNr, int(availabilityTime), false /* isLast */)
 				if err != nil {
 					msg := fmt.Sprintf("Error sending media segments: %v", err)
-					c.report = append(c.report, msg)
+					c.addReport(msg)
 					c.log.Error(msg)
 					return
 				}
@@ -386,12 +575,12 @@ func (c *cmafIngester) start(ctx context.Context) {
 				availabilityTime, err = calcSegmentAvailabilityTime(c.asset, refRep, uint32(nextSegNr), c.cfg)
 				if err != nil {
 					msg := fmt.Sprintf("Error calculating segment availability time: %v", err)
-					c.report = append(c.report, msg)
+					c.addReport(msg)
 					c.log.Error(msg)
 					return
 				}
 				nowMS = int(time.Now().UnixNano() / 1e6)
-				deltaTime = time.Duration(availabilityTime-int64(nowMS)) * time.Millisecond
+				deltaTime = time.Duration(availabilityTime-int64(nowMS))*time.Millisecond + c.delay
 			}
 			timer.Reset(deltaTime)
 		}
@@ -434,12 +623,32 @@ func (c *cmafIngester) start(ctx context.Context) {
 	//    * The metadata then need to be added like role in `kind` boxes`, but also prft
 	//    * Sending an MPD would help
 	//
-	//    * SCTE-35 events should be sent as a separate event stream. This will mostly have
-	//      empty segments. Should check what AWS is outputting to get a reference
+	//    * SCTE-35 events can be sent as a separate event stream, as done by the aws-elemental profile.
+	//      This will mostly have empty segments.
 
 	//
 }
 
+// ingestStartTime returns the time to start pushing given startAt, which is either empty,
+// a UTC time in RFC3339 format, or nextMinute for the next full minute after now.
+// A zero time means that pushing starts directly.
+func ingestStartTime(startAt string, now time.Time) (time.Time, error) {
+	switch startAt {
+	case "":
+		return time.Time{}, nil
+	case "nextMinute":
+		return now.Truncate(time.Minute).Add(time.Minute), nil
+	}
+	t, err := time.Parse(time.RFC3339, startAt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("startAt: %w", err)
+	}
+	if t.Before(now) {
+		return time.Time{}, fmt.Errorf("startAt %s is in the past", startAt)
+	}
+	return t, nil
+}
+
 func (c *cmafIngester) triggerNextSegment() {
 	c.nextSegTrigger <- struct{}{}
 }
@@ -457,19 +666,28 @@ func (c *cmafIngester) sendInitSegment(ctx context.Context, rd cmafRepData, rawI
 	if c.streamsURLs {
 		fileName = fmt.Sprintf("Streams(%s%s)", rd.repID, rd.extension)
 	}
+	c.log.Info("Sending init segment", "fileName", fileName)
+	return c.sendData(ctx, rd, fileName, rawInitSeg)
+}
+
+// sendData sends data for the track of rd as a single request to fileName at the destination.
+func (c *cmafIngester) sendData(ctx context.Context, rd cmafRepData, fileName string, data []byte) error {
 	url := fmt.Sprintf("%s/%s", c.dest(), fileName)
-	buf := bytes.NewBuffer(rawInitSeg)
-	req, err := http.NewRequestWithContext(ctx, "PUT", url, buf)
+	body := c.throttle(ctx, bytes.NewBuffer(data), rd.repID)
+	req, err := http.NewRequestWithContext(ctx, c.profile.method, url, body)
 	if err != nil {
 		return fmt.Errorf("error creating request: %w", err)
 	}
+	req.ContentLength = int64(len(data))
 	setIngestHeader(req)
 	req.Header.Set("Content-Type", rd.mimeType)
 	req.Header.Set("Connection", "keep-alive")
+	setIngestHeaders(c.log, req, c.headers)
+	reqID := setTraceHeaders(req, c.trace)
 	if c.user != "" || c.passWord != "" {
 		req.SetBasicAuth(c.user, c.passWord)
 	}
-	slog.Info("Sending init segment", "fileName", fileName, "url", url)
+	c.log.Debug("Sending data", "url", url, "request_id", reqID)
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("Error sending request: %w", err)
@@ -521,7 +739,7 @@ func (c *cmafIngester) sendMediaSegments(ctx context.Context, nextSegNr, nowMS i
 				segPath = fmt.Sprintf("Streams(%s%s)", rd.repID, rd.extension)
 			}
 			wg.Add(1)
-			go c.sendMediaSegment(ctx, &wg, segPath, segPart, rd.contentType, nextSegNr, nowMS, isLast)
+			go c.sendMediaSegment(ctx, &wg, segPath, segPart, rd.repID, rd.contentType, nextSegNr, nowMS, isLast)
 		}
 	} else {
 		for _, rd := range c.repsData {
@@ -531,16 +749,68 @@ func (c *cmafIngester) sendMediaSegments(ctx context.Context, nextSegNr, nowMS i
 				segPath = fmt.Sprintf("Streams(%s%s)", rd.repID, rd.extension)
 			}
 			wg.Add(1)
-			go c.sendMediaSegment(ctx, &wg, segPath, segPart, rd.contentType, nextSegNr, nowMS, isLast)
+			go c.sendMediaSegment(ctx, &wg, segPath, segPart, rd.repID, rd.contentType, nextSegNr, nowMS, isLast)
 		}
 	}
+	if rd, ok := c.scte35Rep(); ok {
+		wg.Add(1)
+		go c.sendSCTE35Segment(ctx, &wg, rd, nextSegNr, wTimes, nowMS)
+	}
 	wg.Wait()
 	return nil
 }
 
+// scte35Rep returns the track data of the separate SCTE-35 event message track, if it is sent.
+func (c *cmafIngester) scte35Rep() (cmafRepData, bool) {
+	if c.profile.scte35Track == "" || c.cfg.SCTE35PerMinute == nil {
+		return cmafRepData{}, false
+	}
+	return cmafRepData{
+		repID:       c.profile.scte35Track,
+		contentType: "meta",
+		mimeType:    "application/mp4",
+		extension:   cmaf.CMAFMetaExtension,
+	}, true
+}
+
+// sendSCTE35Segment sends the segment of the SCTE-35 event message track that covers the
+// reference video segment with number segNr, or the last segment in wTimes for SegmentTimeline.
+func (c *cmafIngester) sendSCTE35Segment(ctx context.Context, wg *sync.WaitGroup, rd cmafRepData,
+	segNr int, wTimes wrapTimes, nowMS int) {
+	defer wg.Done()
+	refRep := c.asset.refRep
+	var meta segMeta
+	var err error
+	segPath := fmt.Sprintf("%s/%d%s", rd.repID, segNr, rd.extension)
+	if c.cfg.SegTimelineFlag {
+		atoMS := int(c.cfg.getAvailabilityTimeOffsetS() * 1000)
+		segTime := c.asset.generateTimelineEntries(refRep.ID, wTimes, atoMS).lastTime()
+		segPath = fmt.Sprintf("%s/%d%s", rd.repID, segTime, rd.extension)
+		meta, err = findSegMetaFromTime(c.asset, refRep, segTime, c.cfg, nowMS)
+	} else {
+		meta, err = findSegMetaFromNr(c.asset, refRep, uint32(segNr), c.cfg, nowMS)
+	}
+	if c.streamsURLs {
+		segPath = fmt.Sprintf("Streams(%s%s)", rd.repID, rd.extension)
+	}
+	var data []byte
+	if err == nil {
+		data, err = scte35TrackSegment(c.cfg, meta)
+	}
+	if err == nil {
+		err = c.sendData(ctx, rd, segPath, data)
+	}
+	if err != nil {
+		msg := fmt.Sprintf("SCTE-35 track segment %s: %v", segPath, err)
+		c.log.Error(msg)
+		c.addReport(msg)
+		c.notify(eventIngesterSegmentFailed, msg)
+	}
+}
+
 // sendMediaSegment sends a media segment to the destination URL.
 // The segment may be written in chunks, rather than as a whole.
-func (c *cmafIngester) sendMediaSegment(ctx context.Context, wg *sync.WaitGroup, segPath, segPart, contentType string, segNr, nowMS int, isLast bool) {
+func (c *cmafIngester) sendMediaSegment(ctx context.Context, wg *sync.WaitGroup, segPath, segPart, repID, contentType string, segNr, nowMS int, isLast bool) {
 	defer wg.Done()
 
 	u := fmt.Sprintf("%s/%s", c.dest(), segPath)
@@ -553,15 +823,28 @@ func (c *cmafIngester) sendMediaSegment(ctx context.Context, wg *sync.WaitGroup,
 	finishedSendCh := make(chan struct{})
 	defer close(finishedSendCh)
 
-	src := newCmafSource(nrBytesCh, writeMoreCh, c.log, u, contentType, c.user, c.passWord)
+	src := newCmafSource(nrBytesCh, writeMoreCh, c.log, u, c.profile.method, contentType, c.user, c.passWord)
+	src.headers = c.headers
+	src.trace = c.trace
+	src.body = c.throttle(ctx, src, repID)
 
 	// Create media segment based on number and send it to segPath
 	go src.startReadAndSend(ctx, finishedSendCh)
-	code, err := writeSegment(ctx, src, c.log, c.cfg, c.mgr.s.Cfg.DrmCfg, c.mgr.s.assetMgr.vodFS,
+	var w http.ResponseWriter = src
+	var sb *segmentBuffer
+	if c.profile.segmentFlush {
+		sb = newSegmentBuffer()
+		w = sb
+	}
+	code, err := writeSegment(ctx, w, c.log, c.cfg, c.mgr.s.Cfg.DrmCfg, c.mgr.s.assetMgr.vodFS,
 		c.asset, segPart, nowMS, c.mgr.s.textTemplates, isLast)
+	if err == nil && sb != nil && sb.Len() > 0 {
+		_, err = src.Write(sb.Bytes())
+	}
 	c.log.Info("writeSegment", "code", code, "err", err)
 	if err != nil {
 		c.log.Error("writeSegment", "code", code, "err", err)
+		c.notify(eventIngesterSegmentFailed, fmt.Sprintf("%s: %v", segPath, err))
 		var tooEarly errTooEarly
 		switch {
 		case errors.Is(err, errNotFound):
@@ -582,6 +865,46 @@ func (c *cmafIngester) sendMediaSegment(ctx context.Context, wg *sync.WaitGroup,
 	<-writeMoreCh   // Capture final message
 	nrBytesCh <- -1 // Signal that we are done
 	<-finishedSendCh
+	if c.testNowMS == nil {
+		deadlineMS := nowMS + int(c.delay.Milliseconds())
+		if late := lateMS(int(time.Now().UnixMilli()), deadlineMS, c.asset.SegmentDurMS); late > 0 {
+			msg := fmt.Sprintf("late segment %s: upload finished %dms after next segment availability", segPath, late)
+			c.log.Warn(msg)
+			c.addReport(msg)
+			c.notify(eventIngesterFellBehind, msg)
+		}
+	}
+}
+
+// segmentBuffer collects a complete media segment, so that it is sent at once instead of chunk by chunk.
+type segmentBuffer struct {
+	bytes.Buffer
+	h http.Header
+}
+
+func newSegmentBuffer() *segmentBuffer {
+	return &segmentBuffer{h: make(http.Header)}
+}
+
+func (sb *segmentBuffer) Header() http.Header {
+	return sb.h
+}
+
+func (sb *segmentBuffer) WriteHeader(status int) {}
+
+// Flush does nothing, since the segment is sent when complete.
+func (sb *segmentBuffer) Flush() {}
+
+// notify sends an ingester event to the webhook, if any.
+func (c *cmafIngester) notify(event, msg string) {
+	c.webhook.fire(c.log, webhookEvent{Event: event, IngesterID: strconv.FormatUint(c.id, 10), Message: msg})
+}
+
+// addReport adds a message to the report. It is safe to call from concurrent uploads.
+func (c *cmafIngester) addReport(msg string) {
+	c.reportMu.Lock()
+	c.report = append(c.report, msg)
+	c.reportMu.Unlock()
 }
 
 // cmafSource intermediates HTTP response writer and client push writer
@@ -589,6 +912,7 @@ func (c *cmafIngester) sendMediaSegment(ctx context.Context, wg *sync.WaitGroup,
 type cmafSource struct {
 	ctx         context.Context
 	req         *http.Request
+	method      string
 	contentType string
 	nrBytesCh   chan int // Used to signal how many bytes have been written to local buffer.
 	writeMoreCh chan struct{}
@@ -601,11 +925,15 @@ type cmafSource struct {
 	offset      int // Offset in local buffer
 	user        string
 	password    string
+	headers     []ingestHeader
+	trace       traceContext
+	body        io.Reader // data source for the request, normally the cmafSource itself
 }
 
-func newCmafSource(nrBytesCh chan int, writeMoreCh chan struct{}, log *slog.Logger, url string, contentType, user, password string) *cmafSource {
+func newCmafSource(nrBytesCh chan int, writeMoreCh chan struct{}, log *slog.Logger, url, method string, contentType, user, password string) *cmafSource {
 	cs := cmafSource{
 		url:         url,
+		method:      method,
 		contentType: contentType,
 		h:           make(http.Header),
 		log:         log,
@@ -615,13 +943,14 @@ func newCmafSource(nrBytesCh chan int, writeMoreCh chan struct{}, log *slog.Logg
 		user:        user,
 		password:    password,
 	}
+	cs.body = &cs
 	return &cs
 }
 
 func (cs *cmafSource) startReadAndSend(ctx context.Context, finishedCh chan struct{}) {
 	cs.writeMoreCh <- struct{}{} // Get the writer going
 	cs.ctx = ctx
-	req, err := http.NewRequestWithContext(ctx, "PUT", cs.url, cs)
+	req, err := http.NewRequestWithContext(ctx, cs.method, cs.url, cs.body)
 	if err != nil {
 		cs.log.Error("creating request", "err", err)
 		return
@@ -641,6 +970,9 @@ func (cs *cmafSource) startReadAndSend(ctx context.Context, finishedCh chan stru
 	default:
 		cs.log.Warn("unknown content type", "type", cs.contentType)
 	}
+	setIngestHeaders(cs.log, req, cs.headers)
+	reqID := setTraceHeaders(req, cs.trace)
+	cs.log.Debug("push", "url", cs.url, "request_id", reqID)
 	cs.req = req
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
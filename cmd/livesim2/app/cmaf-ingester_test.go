@@ -1,18 +1,26 @@
 package app
 
 import (
+	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/Dash-Industry-Forum/livesim2/pkg/chunkparser"
 	"github.com/Dash-Industry-Forum/livesim2/pkg/logging"
 	"github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/Eyevinn/mp4ff/mp4"
 	"github.com/stretchr/testify/require"
 )
 
@@ -24,16 +32,10 @@ func TestCmafIngesterMgr(t *testing.T) {
 	// Check that init and media segments are received.
 	// TODO: Add test for DRM
 
-	cfg := ServerConfig{
-		VodRoot:   "testdata/assets",
-		TimeoutS:  0,
-		LogFormat: logging.LogText,
-		LogLevel:  "debug",
-	}
-	err := logging.InitSlog(cfg.LogLevel, cfg.LogFormat)
-	require.NoError(t, err)
-	server, err := SetupServer(context.Background(), &cfg)
-	require.NoError(t, err)
+	server, _ := newTestServer(t, func(cfg *ServerConfig) {
+		cfg.LogFormat = logging.LogText
+		cfg.LogLevel = "debug"
+	})
 	cm := NewCmafIngesterMgr(server)
 	cm.Start()
 
@@ -41,11 +43,13 @@ func TestCmafIngesterMgr(t *testing.T) {
 		livesimURL         string
 		testNowMS          *int
 		streamsURLs        bool
+		profile            string
 		nrTriggers         int
 		expectedNrSegments int
 	}{
-		{"/livesim2/segtimeline_1/testpic_2s/Manifest.mpd", mpd.Ptr(int(10000)), true, 2, 2},
-		{"/livesim2/segtimeline_1/testpic_2s/Manifest.mpd", mpd.Ptr(int(10000)), false, 2, 6},
+		{"/livesim2/segtimeline_1/testpic_2s/Manifest.mpd", mpd.Ptr(int(10000)), true, "", 2, 2},
+		{"/livesim2/segtimeline_1/testpic_2s/Manifest.mpd", mpd.Ptr(int(10000)), false, "", 2, 6},
+		{"/livesim2/segtimeline_1/testpic_2s/Manifest.mpd", mpd.Ptr(int(10000)), false, "aws-elemental", 2, 2},
 	}
 
 	for i, c := range cases {
@@ -60,11 +64,12 @@ func TestCmafIngesterMgr(t *testing.T) {
 			TestNowMS:   c.testNowMS,
 			Duration:    nil,
 			StreamsURLs: c.streamsURLs,
+			Profile:     c.profile,
 		}
 		cId, err := cm.NewCmafIngester(setup)
 		require.NoError(t, err)
 		require.Equal(t, uint64(i+1), cId, "CMAF ingester ID be one-based and increase by 1")
-		cI := cm.ingesters[cId]
+		cI := mustGetIngester(t, cm, cId)
 		require.NotNil(t, cI, "CMAF ingester should be created")
 		ctx := context.Background()
 		ctx, cancel := context.WithTimeout(ctx, 1000*time.Second)
@@ -72,39 +77,80 @@ func TestCmafIngesterMgr(t *testing.T) {
 		for j := 0; j < c.nrTriggers; j++ {
 			cI.triggerNextSegment()
 		}
-		time.Sleep(500 * time.Millisecond)
 		// Now we need to check that the segments are received
-		require.Equal(t, c.expectedNrSegments, len(rc.receivedSegments), "Number of segments received")
+		require.Eventually(t, func() bool {
+			return rc.nrReceivedSegments() == c.expectedNrSegments
+		}, 5*time.Second, 10*time.Millisecond, "Number of segments received")
+		expectedMethod := http.MethodPut
+		if c.profile == "aws-elemental" {
+			expectedMethod = http.MethodPost
+		}
+		rc.mu.Lock()
+		require.Equal(t, []string{expectedMethod}, rc.methods, "HTTP methods used")
+		require.Equal(t, []string{cI.trace.traceID}, rc.traceIDs, "trace IDs of pushes")
+		rc.mu.Unlock()
 		cancel()
 		recServer.Close()
 	}
 }
 
+// mustGetIngester returns the ingester with number nr from the manager.
+func mustGetIngester(t *testing.T, cm *cmafIngesterMgr, nr uint64) *cmafIngester {
+	t.Helper()
+	c, ok := cm.getIngester(nr)
+	require.True(t, ok, "ingester %d", nr)
+	return c
+}
+
 type cmafReceiverTestServer struct {
 	receivedSegments        map[string][]byte
 	receivedPartialSegments map[string][]byte
+	fullSegments            map[string][][]byte // all requests with Content-Length per path
+	mu                      sync.Mutex
+	methods                 []string
+	traceIDs                []string
+	userAgents              []string
 }
 
 func newCmafReceiverTestServer() *cmafReceiverTestServer {
 	return &cmafReceiverTestServer{
 		receivedSegments:        make(map[string][]byte),
 		receivedPartialSegments: make(map[string][]byte),
+		fullSegments:            make(map[string][][]byte),
 	}
 }
 
+// nrReceivedSegments returns the number of paths with received data.
+func (s *cmafReceiverTestServer) nrReceivedSegments() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.receivedSegments)
+}
+
 // ServeHTTP implements the http.Handler interface
 // It is used to receive data from a CMAF ingester
-// that sends data using PUT requests.
+// that sends data using PUT or POST requests.
 // The data can either be a full segment or a stream
 // sent using HTTP Chunked-Transfer-Encoding so that it grows over time.
 // The data is stored in the receivedSegments map if complete,
 // but non-complete data is stored in the receivedPartialSegments map until completed.
 func (s *cmafReceiverTestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Check if the request is a PUT request
-	if r.Method != http.MethodPut {
+	// Check if the request is a PUT or POST request
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
+	s.mu.Lock()
+	if !slices.Contains(s.methods, r.Method) {
+		s.methods = append(s.methods, r.Method)
+	}
+	if tc, ok := parseTraceparent(r.Header.Get(traceparentHeader)); ok && !slices.Contains(s.traceIDs, tc.traceID) {
+		s.traceIDs = append(s.traceIDs, tc.traceID)
+	}
+	if ua := r.Header.Get("User-Agent"); !slices.Contains(s.userAgents, ua) {
+		s.userAgents = append(s.userAgents, ua)
+	}
+	s.mu.Unlock()
 
 	// Get the segment name from the URL path
 	segmentName := r.URL.Path[1:]
@@ -125,7 +171,10 @@ func (s *cmafReceiverTestServer) ServeHTTP(w http.ResponseWriter, r *http.Reques
 		if n != contentLen {
 			w.WriteHeader(http.StatusBadRequest)
 		}
+		s.mu.Lock()
 		s.receivedSegments[segmentName] = buf
+		s.fullSegments[segmentName] = append(s.fullSegments[segmentName], buf)
+		s.mu.Unlock()
 		w.WriteHeader(http.StatusOK)
 		return
 	}
@@ -140,12 +189,202 @@ func (s *cmafReceiverTestServer) ServeHTTP(w http.ResponseWriter, r *http.Reques
 
 	err := cp.Parse()
 	if err == nil {
+		s.mu.Lock()
 		for _, c := range ci {
 			s.receivedSegments[segmentName] = append(s.receivedSegments[segmentName], c.Data...)
 		}
+		s.mu.Unlock()
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 	w.WriteHeader(http.StatusInternalServerError)
 	slog.Error("Failed to parse MP4 chunk", "err", err)
 }
+
+func TestAWSElementalIngestProfile(t *testing.T) {
+	server, _ := newTestServer(t, nil)
+	cm := NewCmafIngesterMgr(server)
+	cm.Start()
+
+	rc := newCmafReceiverTestServer()
+	recServer := httptest.NewServer(rc)
+	defer recServer.Close()
+	setup := CmafIngesterSetup{
+		DestRoot:  recServer.URL,
+		DestName:  "testpic_ingest",
+		URL:       "/livesim2/scte35_1/testpic_2s/Manifest.mpd",
+		TestNowMS: mpd.Ptr(60000),
+		Profile:   "aws-elemental",
+		Headers:   map[string]string{"x-custom": "abc"},
+	}
+	cID, err := cm.NewCmafIngester(setup)
+	require.NoError(t, err)
+	cI := mustGetIngester(t, cm, cID)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cI.start(ctx)
+	for j := 0; j < 2; j++ {
+		cI.triggerNextSegment()
+	}
+	scteName := "testpic_ingest/Streams(scte.cmfm)"
+	require.Eventually(t, func() bool {
+		rc.mu.Lock()
+		defer rc.mu.Unlock()
+		return len(rc.receivedSegments) == 3 && len(rc.fullSegments[scteName]) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	require.Equal(t, []string{"AWS Elemental MediaLive"}, rc.userAgents)
+	require.Len(t, rc.receivedSegments, 3)
+	// The SCTE-35 track gets the init segment and one segment per video segment
+	scteData := rc.fullSegments[scteName]
+	require.Len(t, scteData, 3)
+	f, err := mp4.DecodeFile(bytes.NewBuffer(scteData[0]))
+	require.NoError(t, err)
+	require.Equal(t, "meta", f.Init.Moov.Trak.Mdia.Hdlr.HandlerType)
+	require.Equal(t, "evte", f.Init.Moov.Trak.Mdia.Minf.Stbl.Stsd.Children[0].Type())
+	var boxTypes []string
+	var lastSample mp4.FullSample
+	for _, data := range scteData[1:] {
+		f, err = mp4.DecodeFile(bytes.NewBuffer(data))
+		require.NoError(t, err)
+		samples, err := f.Segments[0].Fragments[0].GetFullSamples(nil)
+		require.NoError(t, err)
+		require.Len(t, samples, 1)
+		require.Equal(t, uint32(2*90000), samples[0].Dur, "video segment duration")
+		boxTypes = append(boxTypes, string(samples[0].Data[4:8]))
+		lastSample = samples[0]
+	}
+	// The ad break at 70s is announced in the segment 62-64s
+	require.Equal(t, []string{"emeb", "emib"}, boxTypes)
+	box, err := mp4.DecodeBox(0, bytes.NewReader(lastSample.Data))
+	require.NoError(t, err)
+	require.Equal(t, int64((70-62)*90000), box.(*mp4.EmibBox).PresentationTimeDelta)
+
+	headers := ingestProfileHeaders(t, "aws-elemental", map[string]string{"user-agent": "custom"})
+	require.Equal(t, "custom", headers.Get("User-Agent"))
+}
+
+// ingestProfileHeaders returns the headers set by profile name and setup headers.
+func ingestProfileHeaders(t *testing.T, name string, setupHeaders map[string]string) http.Header {
+	t.Helper()
+	p, err := getIngestProfile(name)
+	require.NoError(t, err)
+	ihs, err := parseIngestHeaders(p.ingestHeaderValues(setupHeaders))
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/upload/Streams(V300.cmfv)", nil)
+	setIngestHeaders(slog.Default(), req, ihs)
+	return req.Header
+}
+
+func TestUnknownIngestProfile(t *testing.T) {
+	_, err := getIngestProfile("unknown")
+	require.Error(t, err)
+	p, err := getIngestProfile("")
+	require.NoError(t, err)
+	require.Equal(t, http.MethodPut, p.method)
+}
+
+func TestIngestHeaders(t *testing.T) {
+	ihs, err := parseIngestHeaders(map[string]string{
+		"x-static":       "abc",
+		"X-Arrival-Time": "{{.NowMS}}",
+		"X-Token":        `exp={{.Expiry 60}}~hmac={{hmacSHA256 "key" (printf "exp=%d~acl=%s" (.Expiry 60) .Path)}}`,
+	})
+	require.NoError(t, err)
+	require.Len(t, ihs, 3)
+	require.Equal(t, "X-Arrival-Time", ihs[0].name)
+	req := httptest.NewRequest(http.MethodPut, "/upload/V300/1.cmfv", nil)
+	setIngestHeaders(slog.Default(), req, ihs)
+	require.Equal(t, "abc", req.Header.Get("X-Static"))
+	arrivalMS, err := strconv.ParseInt(req.Header.Get("X-Arrival-Time"), 10, 64)
+	require.NoError(t, err)
+	require.InDelta(t, time.Now().UnixMilli(), arrivalMS, 5000)
+	exp := arrivalMS/1000 + 60
+	msg := fmt.Sprintf("exp=%d~acl=/upload/V300/1.cmfv", exp)
+	mac := hmac.New(sha256.New, []byte("key"))
+	mac.Write([]byte(msg))
+	require.Equal(t, fmt.Sprintf("exp=%d~hmac=%s", exp, hex.EncodeToString(mac.Sum(nil))), req.Header.Get("X-Token"))
+
+	_, err = parseIngestHeaders(map[string]string{"X-Bad": "{{.Missing"})
+	require.Error(t, err)
+	_, err = parseIngestHeaders(map[string]string{"Bad Name": "x"})
+	require.Error(t, err)
+}
+
+func TestIngestStartTime(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 30, 15, 0, time.UTC)
+	st, err := ingestStartTime("", now)
+	require.NoError(t, err)
+	require.True(t, st.IsZero())
+	st, err = ingestStartTime("nextMinute", now)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2024, 5, 1, 12, 31, 0, 0, time.UTC), st)
+	st, err = ingestStartTime("2024-05-01T13:00:00Z", now)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC), st)
+	_, err = ingestStartTime("2024-05-01T12:00:00Z", now)
+	require.Error(t, err, "start in the past")
+	_, err = ingestStartTime("soon", now)
+	require.Error(t, err)
+}
+
+func TestCmafIngesterPair(t *testing.T) {
+	server, _ := newTestServer(t, nil)
+	cm := NewCmafIngesterMgr(server)
+	cm.Start()
+
+	setup := CmafIngesterPairSetup{
+		Main: CmafIngesterSetup{
+			DestRoot: "http://main.example.com/upload",
+			DestName: "testpic",
+			URL:      "/livesim2/testpic_2s/Manifest.mpd",
+		},
+		BackupDestRoot: "http://backup.example.com/upload",
+		OffsetMS:       500,
+	}
+	mainNr, backupNr, err := cm.NewCmafIngesterPair(setup)
+	require.NoError(t, err)
+	main, backup := mustGetIngester(t, cm, mainNr), mustGetIngester(t, cm, backupNr)
+	require.Equal(t, "http://main.example.com/upload/testpic", main.dest())
+	require.Equal(t, "http://backup.example.com/upload/testpic", backup.dest())
+	require.Equal(t, time.Duration(0), main.delay)
+	require.Equal(t, 500*time.Millisecond, backup.delay)
+	require.False(t, main.startAt.IsZero())
+	require.Equal(t, main.startAt, backup.startAt)
+	require.WithinDuration(t, time.Now(), main.startAt, 2*time.Second)
+
+	setup.OffsetMS = -1
+	_, _, err = cm.NewCmafIngesterPair(setup)
+	require.Error(t, err)
+}
+
+func TestBwLimiter(t *testing.T) {
+	// 800kbps is 100kB/s, so 20kB should take 200ms
+	l := newBwLimiter(800)
+	data := make([]byte, 20_000)
+	tr := &throttledReader{ctx: context.Background(), r: bytes.NewReader(data), limiters: []*bwLimiter{l}}
+	start := time.Now()
+	buf := make([]byte, 5_000)
+	nrRead := 0
+	for {
+		n, err := tr.Read(buf)
+		nrRead += n
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+	elapsed := time.Since(start)
+	require.Equal(t, len(data), nrRead)
+	require.GreaterOrEqual(t, elapsed, 190*time.Millisecond)
+	require.Less(t, elapsed, time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, l.wait(ctx, 100_000), context.Canceled)
+
+	require.Equal(t, 0, lateMS(10_500, 9_000, 2_000))
+	require.Equal(t, 500, lateMS(11_500, 9_000, 2_000))
+}
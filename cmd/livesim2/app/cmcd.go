@@ -0,0 +1,305 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// cmcdQueryKey is the query parameter carrying CMCD data
+	cmcdQueryKey = "CMCD"
+)
+
+// cmcdHeaders are the headers carrying CMCD data.
+var cmcdHeaders = []string{"CMCD-Request", "CMCD-Object", "CMCD-Status", "CMCD-Session"}
+
+// cmcdData is the Common Media Client Data (CTA-5004) sent with a request.
+// String values are unquoted, and boolean keys without value are "true".
+type cmcdData map[string]string
+
+// parseCMCD returns the CMCD data of a request from the CMCD query parameter and the CMCD headers.
+// Malformed entries are ignored.
+func parseCMCD(r *http.Request) cmcdData {
+	d := make(cmcdData)
+	d.parse(r.URL.Query().Get(cmcdQueryKey))
+	for _, h := range cmcdHeaders {
+		d.parse(r.Header.Get(h))
+	}
+	return d
+}
+
+func (d cmcdData) parse(s string) {
+	for _, kv := range splitCMCD(s) {
+		key, val, found := strings.Cut(strings.TrimSpace(kv), "=")
+		if key == "" {
+			continue
+		}
+		if !found {
+			val = "true"
+		}
+		if uq, err := strconv.Unquote(val); err == nil {
+			val = uq
+		}
+		d[key] = val
+	}
+}
+
+// splitCMCD splits CMCD data at commas outside quoted strings.
+func splitCMCD(s string) []string {
+	var parts []string
+	inQuote, escaped := false, false
+	start := 0
+	for i, c := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\' && inQuote:
+			escaped = true
+		case c == '"':
+			inQuote = !inQuote
+		case c == ',' && !inQuote:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// int returns an integer value and whether it is present.
+func (d cmcdData) int(key string) (int, bool) {
+	v, err := strconv.Atoi(d[key])
+	return v, err == nil
+}
+
+// isVideo tells if the object type is video or muxed audio and video.
+func (d cmcdData) isVideo() bool {
+	return d["ot"] == "v" || d["ot"] == "av"
+}
+
+// CMCDRepStats is the server-side statistics for a representation in a CMCD session.
+type CMCDRepStats struct {
+	Rep           string  `json:"rep" doc:"Representation ID, or MPD for manifest requests"`
+	Requests      int     `json:"requests" doc:"Number of requests"`
+	Errors        int     `json:"errors" doc:"Number of requests with status code >= 400"`
+	Bytes         int64   `json:"bytes" doc:"Number of bytes served"`
+	AvgResponseMS float64 `json:"avgResponseMS" doc:"Average time to serve the response (ms)"`
+}
+
+// CMCDSession is the server-side QoS summary of the requests with the same CMCD session ID and content ID.
+type CMCDSession struct {
+	SessionID         string         `json:"sessionID" doc:"CMCD session ID (sid)"`
+	ContentID         string         `json:"contentID,omitempty" doc:"CMCD content ID (cid)"`
+	StreamingFormat   string         `json:"streamingFormat,omitempty" doc:"CMCD streaming format (sf)"`
+	StreamType        string         `json:"streamType,omitempty" doc:"CMCD stream type (st)"`
+	FirstSeen         time.Time      `json:"firstSeen" doc:"Time of first request"`
+	LastSeen          time.Time      `json:"lastSeen" doc:"Time of last request"`
+	Requests          int            `json:"requests" doc:"Number of requests"`
+	Errors            int            `json:"errors" doc:"Number of requests with status code >= 400"`
+	Bytes             int64          `json:"bytes" doc:"Number of bytes served"`
+	AvgResponseMS     float64        `json:"avgResponseMS" doc:"Average time to serve the response (ms)"`
+	StartupRequests   int            `json:"startupRequests" doc:"Number of requests during startup or rebuffering (su)"`
+	BufferStarvations int            `json:"bufferStarvations" doc:"Number of requests reporting buffer starvation (bs)"`
+	MinBufferMS       int            `json:"minBufferMS,omitempty" doc:"Minimum reported buffer length (bl)"`
+	AvgBufferMS       float64        `json:"avgBufferMS,omitempty" doc:"Average reported buffer length (bl)"`
+	AvgThroughputKbps float64        `json:"avgThroughputKbps,omitempty" doc:"Average measured throughput (mtp)"`
+	AvgBitrateKbps    float64        `json:"avgBitrateKbps,omitempty" doc:"Average encoded bitrate of video requests (br)"`
+	BitrateSwitches   int            `json:"bitrateSwitches" doc:"Number of changes of the encoded bitrate of video requests"`
+	TopBitrateKbps    int            `json:"topBitrateKbps,omitempty" doc:"Last reported top playable bitrate (tb)"`
+	Reps              []CMCDRepStats `json:"reps" doc:"Per-representation statistics"`
+}
+
+// cmcdAvg is a running average.
+type cmcdAvg struct {
+	sum float64
+	n   int
+}
+
+func (a *cmcdAvg) add(v float64) {
+	a.sum += v
+	a.n++
+}
+
+func (a *cmcdAvg) value() float64 {
+	if a.n == 0 {
+		return 0
+	}
+	return a.sum / float64(a.n)
+}
+
+type cmcdRep struct {
+	stats    CMCDRepStats
+	response cmcdAvg
+}
+
+// cmcdSession accumulates the statistics of a CMCDSession.
+type cmcdSession struct {
+	CMCDSession
+	response   cmcdAvg
+	buffer     cmcdAvg
+	throughput cmcdAvg
+	bitrate    cmcdAvg
+	lastBR     int
+	reps       map[string]*cmcdRep
+}
+
+type cmcdKey struct {
+	sid, cid string
+}
+
+// cmcdStore aggregates request metrics of the CMCD sessions seen.
+// The least recently seen session is dropped when size is reached.
+type cmcdStore struct {
+	mu       sync.Mutex
+	size     int
+	sessions map[cmcdKey]*cmcdSession
+}
+
+func newCMCDStore(size int) *cmcdStore {
+	return &cmcdStore{size: size, sessions: make(map[cmcdKey]*cmcdSession)}
+}
+
+// record adds a request with CMCD data, response status, served bytes, and response time.
+// Requests without a CMCD session ID are ignored.
+func (cs *cmcdStore) record(now time.Time, d cmcdData, rep string, status, bytes int, dur time.Duration) {
+	sid := d["sid"]
+	if sid == "" {
+		return
+	}
+	key := cmcdKey{sid: sid, cid: d["cid"]}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	sess, ok := cs.sessions[key]
+	if !ok {
+		if len(cs.sessions) >= cs.size {
+			var oldestKey cmcdKey
+			var oldest *cmcdSession
+			for k, s := range cs.sessions {
+				if oldest == nil || s.LastSeen.Before(oldest.LastSeen) {
+					oldestKey, oldest = k, s
+				}
+			}
+			delete(cs.sessions, oldestKey)
+		}
+		sess = &cmcdSession{reps: make(map[string]*cmcdRep)}
+		sess.SessionID, sess.ContentID, sess.FirstSeen = key.sid, key.cid, now
+		cs.sessions[key] = sess
+	}
+	sess.add(now, d, rep, status, bytes, dur)
+}
+
+func (s *cmcdSession) add(now time.Time, d cmcdData, rep string, status, bytes int, dur time.Duration) {
+	isError := status >= http.StatusBadRequest
+	responseMS := float64(dur.Microseconds()) / 1000
+	s.LastSeen = now
+	s.Requests++
+	if isError {
+		s.Errors++
+	}
+	s.Bytes += int64(bytes)
+	s.response.add(responseMS)
+	if v, ok := d["sf"]; ok {
+		s.StreamingFormat = v
+	}
+	if v, ok := d["st"]; ok {
+		s.StreamType = v
+	}
+	if d["su"] == "true" {
+		s.StartupRequests++
+	}
+	if d["bs"] == "true" {
+		s.BufferStarvations++
+	}
+	if bl, ok := d.int("bl"); ok {
+		if s.buffer.n == 0 || bl < s.MinBufferMS {
+			s.MinBufferMS = bl
+		}
+		s.buffer.add(float64(bl))
+	}
+	if mtp, ok := d.int("mtp"); ok {
+		s.throughput.add(float64(mtp))
+	}
+	if br, ok := d.int("br"); ok && d.isVideo() {
+		if s.bitrate.n > 0 && br != s.lastBR {
+			s.BitrateSwitches++
+		}
+		s.lastBR = br
+		s.bitrate.add(float64(br))
+	}
+	if tb, ok := d.int("tb"); ok {
+		s.TopBitrateKbps = tb
+	}
+	if rep == "" {
+		return
+	}
+	r, ok := s.reps[rep]
+	if !ok {
+		r = &cmcdRep{stats: CMCDRepStats{Rep: rep}}
+		s.reps[rep] = r
+	}
+	r.stats.Requests++
+	if isError {
+		r.stats.Errors++
+	}
+	r.stats.Bytes += int64(bytes)
+	r.response.add(responseMS)
+}
+
+// summary returns the session statistics with representations sorted by ID.
+func (s *cmcdSession) summary() CMCDSession {
+	out := s.CMCDSession
+	out.AvgResponseMS = s.response.value()
+	out.AvgBufferMS = s.buffer.value()
+	out.AvgThroughputKbps = s.throughput.value()
+	out.AvgBitrateKbps = s.bitrate.value()
+	out.Reps = make([]CMCDRepStats, 0, len(s.reps))
+	for _, r := range s.reps {
+		rs := r.stats
+		rs.AvgResponseMS = r.response.value()
+		out.Reps = append(out.Reps, rs)
+	}
+	sort.Slice(out.Reps, func(i, j int) bool { return out.Reps[i].Rep < out.Reps[j].Rep })
+	return out
+}
+
+// list returns the sessions with session ID sid, or all sessions if sid is empty,
+// sorted by session ID and content ID.
+func (cs *cmcdStore) list(sid string) []CMCDSession {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	out := make([]CMCDSession, 0, len(cs.sessions))
+	for key, s := range cs.sessions {
+		if sid != "" && key.sid != sid {
+			continue
+		}
+		out = append(out, s.summary())
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].SessionID != out[j].SessionID {
+			return out[i].SessionID < out[j].SessionID
+		}
+		return out[i].ContentID < out[j].ContentID
+	})
+	return out
+}
+
+// remove deletes the sessions with session ID sid and reports whether there were any.
+func (cs *cmcdStore) remove(sid string) bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	found := false
+	for key := range cs.sessions {
+		if key.sid == sid {
+			delete(cs.sessions, key)
+			found = true
+		}
+	}
+	return found
+}
@@ -0,0 +1,97 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCMCD(t *testing.T) {
+	r := httptest.NewRequest("GET", "/livesim2/testpic_2s/V300/1.m4s?CMCD="+
+		url.QueryEscape(`bl=2100,br=300,cid="movie,1",ot=v,sid="abc",su`), nil)
+	r.Header.Set("CMCD-Status", "bs")
+	r.Header.Set("CMCD-Request", "mtp=5000")
+	d := parseCMCD(r)
+	require.Equal(t, cmcdData{"bl": "2100", "br": "300", "cid": "movie,1",
+		"ot": "v", "sid": "abc", "su": "true", "bs": "true", "mtp": "5000"}, d)
+	r = httptest.NewRequest("GET", "/livesim2/testpic_2s/V300/1.m4s", nil)
+	r.Header.Set("CMCD-Session", `cid="movie",sid="abc",sf=d,st=l`)
+	r.Header.Set("CMCD-Object", "br=300,ot=v")
+	d = parseCMCD(r)
+	require.Equal(t, cmcdData{"cid": "movie", "sid": "abc", "sf": "d", "st": "l", "br": "300", "ot": "v"}, d)
+}
+
+func TestCMCDSessions(t *testing.T) {
+	_, ts := newTestServer(t, func(cfg *ServerConfig) {
+		cfg.CMCDSessions = 2
+	})
+
+	get := func(path, cmcd string) {
+		t.Helper()
+		resp, _ := testFullRequest(t, ts, "GET", path+"&CMCD="+url.QueryEscape(cmcd), nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	get("/livesim2/testpic_2s/Manifest.mpd?nowMS=100000", `cid="tp",ot=m,sf=d,sid="s1",st=l,su`)
+	get("/livesim2/testpic_2s/V300/40.m4s?nowMS=100000", `bl=0,br=300,cid="tp",ot=v,sid="s1",su`)
+	get("/livesim2/testpic_2s/V300/41.m4s?nowMS=100000", `bl=2000,br=300,cid="tp",mtp=4000,ot=v,sid="s1"`)
+	get("/livesim2/testpic_2s/V300/42.m4s?nowMS=100000", `bl=4000,br=600,bs,cid="tp",mtp=6000,ot=v,sid="s1",tb=600`)
+	get("/livesim2/testpic_2s/A48/40.m4s?nowMS=100000", `br=48,cid="tp",ot=a,sid="s1"`)
+	get("/livesim2/testpic_2s/V300/40.m4s?nowMS=100000", `sid="s2"`)
+	resp, _ := testFullRequest(t, ts, "GET", "/livesim2/testpic_2s/V300/100.m4s?nowMS=100000&CMCD=sid%3D%22s2%22", nil)
+	require.Equal(t, http.StatusTooEarly, resp.StatusCode)
+	get("/livesim2/testpic_2s/V300/40.m4s?nowMS=100000", `br=300`) // No session ID
+
+	resp, body := testFullRequest(t, ts, "GET", "/api/cmcd-sessions", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var list CMCDListResponse
+	require.NoError(t, json.Unmarshal(body, &list.Body))
+	require.Equal(t, 2, list.Body.Size)
+	require.Len(t, list.Body.Sessions, 2)
+	s1 := list.Body.Sessions[0]
+	require.Equal(t, "s1", s1.SessionID)
+	require.Equal(t, "tp", s1.ContentID)
+	require.Equal(t, "d", s1.StreamingFormat)
+	require.Equal(t, "l", s1.StreamType)
+	require.Equal(t, 5, s1.Requests)
+	require.Equal(t, 0, s1.Errors)
+	require.Equal(t, 2, s1.StartupRequests)
+	require.Equal(t, 1, s1.BufferStarvations)
+	require.Equal(t, 0, s1.MinBufferMS)
+	require.Equal(t, 2000.0, s1.AvgBufferMS)
+	require.Equal(t, 5000.0, s1.AvgThroughputKbps)
+	require.Equal(t, 400.0, s1.AvgBitrateKbps)
+	require.Equal(t, 1, s1.BitrateSwitches)
+	require.Equal(t, 600, s1.TopBitrateKbps)
+	require.Len(t, s1.Reps, 3)
+	require.Equal(t, "A48", s1.Reps[0].Rep)
+	require.Equal(t, "MPD", s1.Reps[1].Rep)
+	require.Equal(t, "V300", s1.Reps[2].Rep)
+	require.Equal(t, 3, s1.Reps[2].Requests)
+	s2 := list.Body.Sessions[1]
+	require.Equal(t, 2, s2.Requests)
+	require.Equal(t, 1, s2.Errors)
+
+	// A third session drops the least recently seen one
+	get("/livesim2/testpic_2s/V300/40.m4s?nowMS=100000", `sid="s3"`)
+	resp, _ = testFullRequest(t, ts, "GET", "/api/cmcd-sessions/s1", nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, body = testFullRequest(t, ts, "GET", "/api/cmcd-sessions/s3", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var sess CMCDSessionResponse
+	require.NoError(t, json.Unmarshal(body, &sess.Body))
+	require.Len(t, sess.Body.Sessions, 1)
+	require.Equal(t, 1, sess.Body.Sessions[0].Requests)
+
+	resp, _ = testFullRequest(t, ts, "DELETE", "/api/cmcd-sessions/s3", nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, _ = testFullRequest(t, ts, "DELETE", "/api/cmcd-sessions/s3", nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
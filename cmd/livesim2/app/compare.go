@@ -0,0 +1,362 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/Eyevinn/mp4ff/mp4"
+	"github.com/spf13/pflag"
+
+	"github.com/Dash-Industry-Forum/livesim2/pkg/mpddiff"
+)
+
+// CompareOptions configures a comparison of two origins.
+type CompareOptions struct {
+	URLA       string
+	URLB       string
+	NrSegments int
+	Timeout    time.Duration
+	AllAttrs   bool
+}
+
+// ParseCompareArgs parses the arguments after the compare subcommand.
+func ParseCompareArgs(args []string) (*CompareOptions, error) {
+	o := CompareOptions{}
+	f := pflag.NewFlagSet("compare", pflag.ContinueOnError)
+	f.IntVar(&o.NrSegments, "segments", 3, "number of media segments per representation to compare")
+	f.DurationVar(&o.Timeout, "timeout", 10*time.Second, "timeout for each HTTP request")
+	f.BoolVar(&o.AllAttrs, "all", false, "compare also publishTime and SegmentTimeline, which normally differ between requests")
+	f.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: livesim2 compare [options] <mpdURLA> <mpdURLB>\n\n")
+		fmt.Fprintf(os.Stderr, "Fetch MPD and segments from two origins and report differences.\n")
+		fmt.Fprintf(os.Stderr, "Segment URLs are derived from the first MPD and resolved against both MPD URLs.\n\nOptions:\n")
+		f.PrintDefaults()
+	}
+	if err := f.Parse(args); err != nil {
+		return nil, err
+	}
+	if f.NArg() != 2 {
+		f.Usage()
+		return nil, fmt.Errorf("need exactly two MPD URLs")
+	}
+	o.URLA, o.URLB = f.Arg(0), f.Arg(1)
+	if o.NrSegments < 0 {
+		return nil, fmt.Errorf("segments must be non-negative")
+	}
+	return &o, nil
+}
+
+// Compare fetches the MPD and segments from both origins and writes discrepancies to w.
+// The number of discrepancies is returned.
+func Compare(ctx context.Context, o *CompareOptions, w io.Writer) (int, error) {
+	client := &http.Client{Timeout: o.Timeout}
+	mpdA, err := fetchBytes(ctx, client, o.URLA)
+	if err != nil {
+		return 0, err
+	}
+	mpdB, err := fetchBytes(ctx, client, o.URLB)
+	if err != nil {
+		return 0, err
+	}
+	opts := mpddiff.DefaultOptions
+	if o.AllAttrs {
+		opts = mpddiff.Options{}
+	}
+	changes, err := mpddiff.Diff(mpdA, mpdB, opts)
+	if err != nil {
+		return 0, err
+	}
+	nrDiffs := len(changes)
+	fmt.Fprintf(w, "MPD: %d differences\n", len(changes))
+	for _, c := range changes {
+		fmt.Fprintf(w, "  %s\n", c)
+	}
+
+	mpd, err := m.MPDFromBytes(mpdA)
+	if err != nil {
+		return nrDiffs, fmt.Errorf("parse MPD: %w", err)
+	}
+	uris, err := compareSegmentURIs(mpd, o.NrSegments, time.Now())
+	if err != nil {
+		return nrDiffs, err
+	}
+	baseA, err := url.Parse(o.URLA)
+	if err != nil {
+		return nrDiffs, err
+	}
+	baseB, err := url.Parse(o.URLB)
+	if err != nil {
+		return nrDiffs, err
+	}
+	for _, uri := range uris {
+		ref, err := url.Parse(uri)
+		if err != nil {
+			return nrDiffs, err
+		}
+		issues := compareSegment(ctx, client, baseA.ResolveReference(ref).String(), baseB.ResolveReference(ref).String())
+		if len(issues) == 0 {
+			fmt.Fprintf(w, "%s: ok\n", uri)
+			continue
+		}
+		nrDiffs += len(issues)
+		fmt.Fprintf(w, "%s: %d differences\n", uri, len(issues))
+		for _, issue := range issues {
+			fmt.Fprintf(w, "  %s\n", issue)
+		}
+	}
+	return nrDiffs, nil
+}
+
+// compareSegmentURIs returns init and media segment URIs (relative to the MPD) for all representations.
+// For dynamic MPDs, the last nrSegs segments available at now are used. For static MPDs, the first nrSegs.
+func compareSegmentURIs(mpd *m.MPD, nrSegs int, now time.Time) ([]string, error) {
+	var uris []string
+	for _, p := range mpd.Periods {
+		for _, as := range p.AdaptationSets {
+			for _, rep := range as.Representations {
+				initURI, mediaURIs, err := repSegmentURIs(mpd, p, rep, nrSegs, now)
+				if err != nil {
+					return nil, err
+				}
+				if initURI != "" {
+					uris = append(uris, initURI)
+				}
+				uris = append(uris, mediaURIs...)
+			}
+		}
+	}
+	return uris, nil
+}
+
+// repSegmentURIs returns the init segment URI (if any) and the media segment URIs of one representation.
+// For dynamic MPDs, the last nrSegs segments available at now are used. For static MPDs, the first nrSegs.
+func repSegmentURIs(mpd *m.MPD, p *m.Period, rep *m.RepresentationType, nrSegs int,
+	now time.Time) (initURI string, mediaURIs []string, err error) {
+	isDynamic := mpd.GetType() == "dynamic"
+	st := rep.GetSegmentTemplate()
+	if st == nil {
+		return "", nil, fmt.Errorf("no SegmentTemplate for representation %s", rep.Id)
+	}
+	if st.Initialization != "" {
+		initURI = replaceIdentifiers(rep, st.Initialization)
+	}
+	media := replaceIdentifiers(rep, st.Media)
+	startNr := 1
+	if st.StartNumber != nil {
+		startNr = int(*st.StartNumber)
+	}
+	var segs []Segment
+	if st.SegmentTimeline != nil {
+		segs = timelineSegments(st.SegmentTimeline, startNr)
+	} else {
+		segs, err = numberSegments(mpd, p, st, startNr, nrSegs, isDynamic, now)
+		if err != nil {
+			return "", nil, fmt.Errorf("representation %s: %w", rep.Id, err)
+		}
+	}
+	if len(segs) > nrSegs {
+		if isDynamic {
+			segs = segs[len(segs)-nrSegs:]
+		} else {
+			segs = segs[:nrSegs]
+		}
+	}
+	for _, s := range segs {
+		mediaURIs = append(mediaURIs, replaceTimeAndNr(media, s.StartTime, s.Nr))
+	}
+	return initURI, mediaURIs, nil
+}
+
+// timelineSegments expands a SegmentTimeline into segments.
+func timelineSegments(stl *m.SegmentTimelineType, startNr int) []Segment {
+	var segs []Segment
+	t := uint64(0)
+	nr := uint32(startNr)
+	for _, s := range stl.S {
+		if s.T != nil {
+			t = *s.T
+		}
+		for i := 0; i <= s.R; i++ {
+			segs = append(segs, Segment{StartTime: t, EndTime: t + s.D, Nr: nr})
+			t += s.D
+			nr++
+		}
+	}
+	return segs
+}
+
+// numberSegments calculates the first (static) or last (dynamic) nrSegs segments of a $Number$ template.
+func numberSegments(mpd *m.MPD, p *m.Period, st *m.SegmentTemplateType, startNr, nrSegs int,
+	isDynamic bool, now time.Time) ([]Segment, error) {
+	if st.Duration == nil || *st.Duration == 0 {
+		return nil, fmt.Errorf("no segment duration")
+	}
+	dur := uint64(*st.Duration)
+	first := 0
+	if isDynamic {
+		pStartS, err := p.AbsoluteStart(mpd)
+		if err != nil {
+			return nil, fmt.Errorf("period start: %w", err)
+		}
+		elapsedS := float64(now.UnixMilli())/1000 - pStartS
+		if elapsedS < 0 {
+			return nil, nil
+		}
+		nrAvailable := int(elapsedS * float64(st.GetTimescale()) / float64(dur))
+		first = max(0, nrAvailable-nrSegs)
+		nrSegs = min(nrSegs, nrAvailable)
+	}
+	segs := make([]Segment, 0, nrSegs)
+	pto := uint64(0)
+	if st.PresentationTimeOffset != nil {
+		pto = *st.PresentationTimeOffset
+	}
+	for i := first; i < first+nrSegs; i++ {
+		start := uint64(i)*dur + pto
+		segs = append(segs, Segment{StartTime: start, EndTime: start + dur, Nr: uint32(startNr + i)})
+	}
+	return segs, nil
+}
+
+// compareSegment fetches a segment from both origins and compares status, size, and box structure.
+func compareSegment(ctx context.Context, client *http.Client, urlA, urlB string) []string {
+	respA, dataA, errA := fetchResponse(ctx, client, urlA)
+	respB, dataB, errB := fetchResponse(ctx, client, urlB)
+	switch {
+	case errA != nil && errB != nil:
+		return nil
+	case errA != nil:
+		return []string{fmt.Sprintf("fetch A: %s", errA)}
+	case errB != nil:
+		return []string{fmt.Sprintf("fetch B: %s", errB)}
+	}
+	if respA != respB {
+		return []string{fmt.Sprintf("status: %d != %d", respA, respB)}
+	}
+	if respA != http.StatusOK {
+		return nil
+	}
+	var issues []string
+	if len(dataA) != len(dataB) {
+		issues = append(issues, fmt.Sprintf("size: %d != %d", len(dataA), len(dataB)))
+	}
+	boxesA, err := boxSummary(dataA)
+	if err != nil {
+		return append(issues, fmt.Sprintf("decode A: %s", err))
+	}
+	boxesB, err := boxSummary(dataB)
+	if err != nil {
+		return append(issues, fmt.Sprintf("decode B: %s", err))
+	}
+	for i := 0; i < max(len(boxesA), len(boxesB)); i++ {
+		var a, b string
+		if i < len(boxesA) {
+			a = boxesA[i]
+		}
+		if i < len(boxesB) {
+			b = boxesB[i]
+		}
+		if a != b {
+			issues = append(issues, fmt.Sprintf("box %d: %q != %q", i, a, b))
+		}
+	}
+	return issues
+}
+
+// boxSummary returns one line per box with its path and key timing values.
+func boxSummary(data []byte) ([]string, error) {
+	f, err := mp4.DecodeFile(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	var walk func(prefix string, boxes []mp4.Box)
+	walk = func(prefix string, boxes []mp4.Box) {
+		for _, b := range boxes {
+			p := prefix + b.Type()
+			line := p
+			switch box := b.(type) {
+			case *mp4.MdhdBox:
+				line += fmt.Sprintf(" timescale=%d", box.Timescale)
+			case *mp4.MfhdBox:
+				line += fmt.Sprintf(" sequenceNumber=%d", box.SequenceNumber)
+			case *mp4.TfdtBox:
+				line += fmt.Sprintf(" baseMediaDecodeTime=%d", box.BaseMediaDecodeTime())
+			case *mp4.TrunBox:
+				line += fmt.Sprintf(" sampleCount=%d duration=%d", box.SampleCount(), box.Duration(0))
+			}
+			lines = append(lines, line)
+			if c, ok := b.(mp4.ContainerBox); ok {
+				walk(p+"/", c.GetChildren())
+			}
+		}
+	}
+	walk("", f.Children)
+	return lines, nil
+}
+
+// mpdDiffSource returns mpdXML if set, otherwise the MPD fetched from rawURL.
+// A rawURL starting with / is served internally by the server.
+func (s *Server) mpdDiffSource(ctx context.Context, rawURL, mpdXML string) ([]byte, error) {
+	switch {
+	case mpdXML != "" && rawURL != "":
+		return nil, fmt.Errorf("both URL and MPD given")
+	case mpdXML != "":
+		return []byte(mpdXML), nil
+	case rawURL == "":
+		return nil, fmt.Errorf("URL or MPD needed")
+	case strings.HasPrefix(rawURL, "/"):
+		// The API request context is not reused, since it carries the chi routing state
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, rawURL, nil)
+		s.Router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			return nil, fmt.Errorf("get %s: status %d", rawURL, rec.Code)
+		}
+		return rec.Body.Bytes(), nil
+	default:
+		return fetchBytes(ctx, &http.Client{Timeout: 10 * time.Second}, rawURL)
+	}
+}
+
+// fetchBytes fetches a URL and returns the body if the status is 200 OK.
+func fetchBytes(ctx context.Context, client *http.Client, rawURL string) ([]byte, error) {
+	status, data, err := fetchResponse(ctx, client, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("get %s: status %d", rawURL, status)
+	}
+	return data, nil
+}
+
+func fetchResponse(ctx context.Context, client *http.Client, rawURL string) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("read %s: %w", rawURL, err)
+	}
+	return resp.StatusCode, data, nil
+}
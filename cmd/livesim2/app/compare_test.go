@@ -0,0 +1,127 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dash-Industry-Forum/livesim2/pkg/mpddiff"
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompare(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	cases := []struct {
+		desc         string
+		pathA        string
+		pathB        string
+		wantedDiffs  int
+		wantedOutput []string
+	}{
+		{desc: "identical", pathA: "/livesim2/testpic_2s/Manifest.mpd", pathB: "/livesim2/testpic_2s/Manifest.mpd",
+			wantedDiffs: 0, wantedOutput: []string{"MPD: 0 differences", "V300/init.mp4: ok"}},
+		{desc: "different mup", pathA: "/livesim2/testpic_2s/Manifest.mpd", pathB: "/livesim2/mup_4/testpic_2s/Manifest.mpd",
+			wantedDiffs: 1, wantedOutput: []string{"~ MPD@minimumUpdatePeriod"}},
+		{desc: "segment timeline", pathA: "/livesim2/segtimeline_1/testpic_2s/Manifest.mpd",
+			pathB: "/livesim2/segtimeline_1/testpic_2s/Manifest.mpd", wantedDiffs: 0},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			o, err := ParseCompareArgs([]string{"--segments", "2", ts.URL + c.pathA, ts.URL + c.pathB})
+			require.NoError(t, err)
+			var buf bytes.Buffer
+			nrDiffs, err := Compare(context.Background(), o, &buf)
+			require.NoError(t, err)
+			require.Equal(t, c.wantedDiffs, nrDiffs, buf.String())
+			for _, w := range c.wantedOutput {
+				require.Contains(t, buf.String(), w)
+			}
+		})
+	}
+
+	_, err := ParseCompareArgs([]string{ts.URL})
+	require.Error(t, err)
+}
+
+func TestCompareSegmentURIs(t *testing.T) {
+	mpdStr := `<?xml version="1.0" encoding="utf-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" type="dynamic" availabilityStartTime="1970-01-01T00:00:00Z">
+  <Period id="P0" start="PT0S">
+    <AdaptationSet id="1" contentType="video">
+      <SegmentTemplate startNumber="1" initialization="$RepresentationID$/init.mp4" duration="2" media="$RepresentationID$/$Number$.m4s"/>
+      <Representation id="V300" bandwidth="300000"/>
+    </AdaptationSet>
+    <AdaptationSet id="2" contentType="audio">
+      <SegmentTemplate timescale="1000" initialization="$RepresentationID$/init.mp4" media="$RepresentationID$/$Time$.m4s">
+        <SegmentTimeline><S t="50000" d="2000" r="5"/></SegmentTimeline>
+      </SegmentTemplate>
+      <Representation id="A48" bandwidth="48000"/>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+	mpd, err := m.ReadFromString(mpdStr)
+	require.NoError(t, err)
+	uris, err := compareSegmentURIs(mpd, 2, time.UnixMilli(61_000))
+	require.NoError(t, err)
+	require.Equal(t, []string{"V300/init.mp4", "V300/29.m4s", "V300/30.m4s",
+		"A48/init.mp4", "A48/58000.m4s", "A48/60000.m4s"}, uris)
+}
+
+func TestMPDDiffAPI(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	cases := []struct {
+		desc          string
+		body          string
+		wantedStatus  int
+		wantedChanges int
+		wantedAttr    string
+	}{
+		{desc: "same MPD at different times",
+			body: `{"urlA": "/livesim2/segtimeline_1/testpic_2s/Manifest.mpd?nowMS=100000",
+				"urlB": "/livesim2/segtimeline_1/testpic_2s/Manifest.mpd?nowMS=104000"}`,
+			wantedStatus: http.StatusOK, wantedChanges: 1, wantedAttr: "value"}, // UTCTiming with virtual clock
+		{desc: "different tsbd",
+			body: `{"urlA": "/livesim2/testpic_2s/Manifest.mpd?nowMS=100000",
+				"urlB": "/livesim2/tsbd_30/testpic_2s/Manifest.mpd?nowMS=100000"}`,
+			wantedStatus: http.StatusOK, wantedChanges: 1, wantedAttr: "timeShiftBufferDepth"},
+		{desc: "all including publishTime",
+			body: `{"urlA": "/livesim2/segtimeline_1/testpic_2s/Manifest.mpd?nowMS=100000",
+				"urlB": "/livesim2/segtimeline_1/testpic_2s/Manifest.mpd?nowMS=104000", "all": true}`,
+			wantedStatus: http.StatusOK, wantedChanges: 36, wantedAttr: "publishTime"},
+		{desc: "xml bodies",
+			body:         `{"mpdA": "<MPD a=\"1\"/>", "mpdB": "<MPD a=\"2\"/>"}`,
+			wantedStatus: http.StatusOK, wantedChanges: 1, wantedAttr: "a"},
+		{desc: "missing second", body: `{"mpdA": "<MPD/>"}`, wantedStatus: http.StatusBadRequest},
+		{desc: "unknown asset", body: `{"urlA": "/livesim2/nothing/Manifest.mpd", "mpdB": "<MPD/>"}`,
+			wantedStatus: http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			resp, body := testFullRequest(t, ts, "POST", "/api/mpddiff", strings.NewReader(c.body))
+			require.Equal(t, c.wantedStatus, resp.StatusCode, string(body))
+			if c.wantedStatus != http.StatusOK {
+				return
+			}
+			var res struct {
+				NrChanges int              `json:"nrChanges"`
+				Changes   []mpddiff.Change `json:"changes"`
+			}
+			require.NoError(t, json.Unmarshal(body, &res))
+			require.Equal(t, c.wantedChanges, res.NrChanges, string(body))
+			if c.wantedAttr != "" {
+				require.Equal(t, c.wantedAttr, res.Changes[0].Attr)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@
 package app
 
 import (
+	gojson "encoding/json"
 	"fmt"
 	"os"
 	"path"
@@ -23,6 +24,9 @@ import (
 	"github.com/spf13/pflag"
 )
 
+// envPrefix is the prefix of environment variables that set configuration options.
+const envPrefix = "LIVESIM_"
+
 const (
 	defaultReqIntervalS             = 24 * 3600
 	defaultAvailabilityStartTimeS   = 0
@@ -46,7 +50,30 @@ type ServerConfig struct {
 	MaxRequests int    `json:"maxrequests"`
 	// WhiteListBlocks is a comma-separated list of CIDR blocks that are not rate limited
 	WhiteListBlocks string `json:"whitelistblocks"`
-	VodRoot         string `json:"vodroot"`
+	// TrustedProxies is a comma-separated list of CIDR blocks of proxies whose X-Forwarded-For is trusted
+	TrustedProxies string `json:"trustedproxies"`
+	// AllowBlocks is a comma-separated list of CIDR blocks allowed access. Empty means all.
+	AllowBlocks string `json:"allowblocks"`
+	// DenyBlocks is a comma-separated list of CIDR blocks denied access
+	DenyBlocks string `json:"denyblocks"`
+	// MirrorOrigin is an origin (scheme://host) to which all livesim2 and vod requests are mirrored for comparison
+	MirrorOrigin string `json:"mirrororigin"`
+	// HostAliases is a comma-separated list of host names of this server for the xhost URL parameter.
+	// *.<domain> expands to h1.<domain> to h8.<domain> for wildcard DNS.
+	HostAliases string `json:"hostaliases"`
+	// MPDSignKey is a PEM file with an ECDSA P-256 private key for signing MPDs. Empty means an ephemeral key.
+	MPDSignKey string `json:"mpdsignkey"`
+	// MPDHistory is the number of generated MPDs kept per session or MPD path. 0 disables recording.
+	MPDHistory int `json:"mpdhistory"`
+	// QoEReports is the number of DASH metrics reports kept per MPD path. 0 disables the /qoe endpoint.
+	QoEReports int `json:"qoereports"`
+	// CMCDSessions is the number of CMCD sessions for which request metrics are aggregated. 0 disables aggregation.
+	CMCDSessions int `json:"cmcdsessions"`
+	// SAND is the number of SAND status messages kept per client. 0 disables the /sand DANE endpoint.
+	SAND int `json:"sand"`
+	// SANDThroughputKbps is the guaranteed throughput sent in PER messages. 0 means no throughput hint.
+	SANDThroughputKbps int    `json:"sandthroughput"`
+	VodRoot            string `json:"vodroot"`
 	// RepDataRoot is the root directory for representation metadata
 	RepDataRoot string `json:"repdataroot"`
 	// WriteRepData is true if representation metadata should be written (will override existing metadata)
@@ -64,6 +91,34 @@ type ServerConfig struct {
 	PlayURL    string         `json:"playurl"`
 	DrmCfgFile string         `json:"drmcfgfile"`
 	DrmCfg     *drm.DrmConfig `json:"drmcfg"`
+	// LaxURLParams disables the strict check for unknown and repeated URL parameters
+	LaxURLParams bool `json:"laxurlparams"`
+	// Pprof enables the net/http/pprof profiling endpoints under /debug
+	Pprof bool `json:"pprof"`
+	// AssetUpload enables uploading zipped assets to the vodroot directory via the API
+	AssetUpload bool `json:"assetupload"`
+	// Archive is a storage URI for generated artifacts: a directory, file:///dir, or s3://bucket/prefix
+	Archive string `json:"archive"`
+	// RecordDir is a storage URI for VoD recordings of live windows: a directory, file:///dir, or s3://bucket/prefix
+	RecordDir string `json:"recorddir"`
+	// StateFile is a JSON file where sessions, CMAF ingesters, and bookmarks are persisted across restarts
+	StateFile string `json:"statefile"`
+	// Plugins is a comma-separated list of Go plugin files with hooks
+	Plugins string `json:"plugins"`
+	// WasmPlugins is a comma-separated list of WASM plugin files for custom fault injection
+	WasmPlugins string `json:"wasmplugins"`
+	// Scaled rejects features with per-instance state, so that several instances can serve the same output
+	Scaled bool `json:"scaled"`
+	// VanityPaths maps public paths to full livesim2 paths. Only settable in config file.
+	VanityPaths []VanityPath `json:"vanitypaths,omitempty"`
+	// Channels defines linear channels playing sequences of assets. Only settable in config file.
+	Channels []ChannelConfig `json:"channels,omitempty"`
+	// Proxies defines upstream origins served through livesim2. Only settable in config file.
+	Proxies []ProxyConfig `json:"proxies,omitempty"`
+	// Experiments are A/B tests assigning sessions to variant configurations. Only settable in config file.
+	Experiments []ExperimentConfig `json:"experiments,omitempty"`
+	// Listeners replaces port, domains, and TLS settings by multiple listeners. Only settable in config file.
+	Listeners []ListenerConfig `json:"listeners,omitempty"`
 }
 
 var DefaultConfig = ServerConfig{
@@ -119,6 +174,17 @@ func LoadConfig(args []string, cwd string) (*ServerConfig, error) {
 	f.String("repdataroot", k.String("repdataroot"), `Representation metadata root directory. "+" copies vodroot value. "-" disables usage.`)
 	f.Bool("writerepdata", k.Bool("writerepdata"), "Write representation metadata if not present")
 	f.String("whitelistblocks", k.String("whitelistblocks"), "comma-separated list of CIDR blocks that are not rate limited")
+	f.String("trustedproxies", k.String("trustedproxies"), "comma-separated list of CIDR blocks of proxies trusted for X-Forwarded-For")
+	f.String("allowblocks", k.String("allowblocks"), "comma-separated list of CIDR blocks allowed access (default all)")
+	f.String("denyblocks", k.String("denyblocks"), "comma-separated list of CIDR blocks denied access")
+	f.String("mirrororigin", k.String("mirrororigin"), "origin to mirror livesim2 and vod requests to, comparing status codes and sizes")
+	f.String("hostaliases", k.String("hostaliases"), "comma-separated host names aliasing this server for xhost_<mode> (*.<domain> for wildcard DNS)")
+	f.String("mpdsignkey", k.String("mpdsignkey"), "PEM file with ECDSA P-256 private key for MPD signatures with mpdsign_jws (empty = ephemeral key)")
+	f.Int("mpdhistory", k.Int("mpdhistory"), "number of generated MPDs to keep per session or MPD path for /api/mpd-history (0 = disabled)")
+	f.Int("qoereports", k.Int("qoereports"), "number of DASH metrics reports to keep per MPD path for /api/qoe-reports (0 = disabled)")
+	f.Int("cmcdsessions", k.Int("cmcdsessions"), "number of CMCD sessions to aggregate request metrics for in /api/cmcd-sessions (0 = disabled)")
+	f.Int("sand", k.Int("sand"), "number of SAND status messages to keep per client for the /sand DANE endpoint (0 = disabled)")
+	f.Int("sandthroughput", k.Int("sandthroughput"), "guaranteed throughput (kbps) to send in SAND PER messages (0 = none)")
 	f.Int("timeoutS", k.Int("timeouts"), "timeout for all requests (seconds)")
 	f.Int("maxrequests", k.Int("maxrequests"), "max nr of request per IP address per 24 hours")
 	f.String("reqlimitlog", k.String("reqlimitlog"), "path to request limit log file (only written if maxrequests > 0)")
@@ -130,12 +196,24 @@ func LoadConfig(args []string, cwd string) (*ServerConfig, error) {
 	f.String("host", k.String("host"), "host (and possible prefix) used in MPD elements. Overrides auto-detected full scheme://host")
 	f.String("playurl", k.String("playurl"), "URL template to play mpd. %s will be replaced by MPD URL")
 	f.String("drmcfgfile", k.String("drmcfgfile"), "DRM config file path")
+	f.Bool("laxurlparams", k.Bool("laxurlparams"), "Do not return 400 for unknown or repeated URL parameters")
+	f.Bool("assetupload", k.Bool("assetupload"), "enable PUT /api/assets/{name} for uploading zipped assets to vodroot")
+	f.String("archive", k.String("archive"), "storage for reports, session recordings, and MPD history: directory, file:///dir, or s3://bucket/prefix (empty = none)")
+	f.Bool("pprof", k.Bool("pprof"), "enable profiling endpoints under /debug/pprof (admin listener if listeners are configured)")
+	f.Bool("scaled", k.Bool("scaled"), "horizontally scaled mode: reject features that keep per-instance state (sessions, request limits, histories)")
+	f.String("plugins", k.String("plugins"), "comma-separated list of Go plugin files (.so) exporting LivesimHooks with request hooks")
+	f.String("recorddir", k.String("recorddir"), "storage for VoD recordings of live windows via /api/recordings: directory, file:///dir, or s3://bucket/prefix (empty = disabled)")
+	f.String("wasmplugins", k.String("wasmplugins"), "comma-separated list of WASM plugin files (.wasm) for fault injection with the wasm URL parameter, e.g. wasm_<name>")
+	f.String("statefile", k.String("statefile"), "JSON file where sessions, CMAF ingesters, and bookmarks are persisted across restarts (empty = memory only)")
 
 	if err := f.Parse(args[1:]); err != nil {
 		return nil, fmt.Errorf("command line parse: %w", err)
 	}
 
-	// Load the config files provided in the commandline.
+	// Load the config file provided in the commandline or environment.
+	if *cfgFile == "" {
+		*cfgFile = os.Getenv(envPrefix + "CFG")
+	}
 	if *cfgFile != "" {
 		cf := file.Provider(*cfgFile)
 		if err := k.Load(cf, json.Parser()); err != nil {
@@ -149,13 +227,14 @@ func LoadConfig(args []string, cwd string) (*ServerConfig, error) {
 	}
 
 	// Overload with environment variables
-	err = k.Load(env.Provider("LIVESIM_", ".", func(s string) string {
-		return strings.Replace(strings.ToLower(
-			strings.TrimPrefix(s, "LIVESIM_")), "_", ".", -1)
-	}), nil)
+	em := newEnvMapper(k, f)
+	err = k.Load(env.ProviderWithValue(envPrefix, ".", em.keyValue), nil)
 	if err != nil {
 		return nil, err
 	}
+	if em.err != nil {
+		return nil, fmt.Errorf("environment: %w", em.err)
+	}
 
 	err = checkTLSParams(k)
 	if err != nil {
@@ -229,6 +308,44 @@ func LoadConfig(args []string, cwd string) (*ServerConfig, error) {
 	return &cfg, nil
 }
 
+// envMapper maps environment variables to configuration keys and values.
+// LIVESIM_<NAME> sets the option with the same name as the command-line parameter or config-file key,
+// matched case-insensitively, so that e.g. LIVESIM_TIMEOUTS sets timeoutS.
+// The values of config-file only options are JSON, e.g. LIVESIM_LISTENERS='[{"addr": ":8888"}]'.
+// Other names are lowercased with "_" replaced by ".".
+type envMapper struct {
+	keys map[string]string // lowercase to actual key
+	err  error
+}
+
+func newEnvMapper(k *koanf.Koanf, f *pflag.FlagSet) *envMapper {
+	em := envMapper{keys: make(map[string]string)}
+	for _, key := range k.Keys() {
+		em.keys[strings.ToLower(key)] = key
+	}
+	f.VisitAll(func(fl *pflag.Flag) {
+		em.keys[strings.ToLower(fl.Name)] = fl.Name
+	})
+	return &em
+}
+
+func (em *envMapper) keyValue(name, value string) (string, any) {
+	name = strings.ToLower(strings.TrimPrefix(name, envPrefix))
+	switch name {
+	case "vanitypaths", "listeners", "channels", "proxies", "experiments":
+		var v any
+		if err := gojson.Unmarshal([]byte(value), &v); err != nil {
+			em.err = fmt.Errorf("%s%s: %w", envPrefix, strings.ToUpper(name), err)
+			return "", nil
+		}
+		return name, v
+	}
+	if key, ok := em.keys[name]; ok {
+		return key, value
+	}
+	return strings.ReplaceAll(name, "_", "."), value
+}
+
 func makeAbsolutePath(k *koanf.Koanf, key, cwd string) (string, error) {
 	absPath := k.String(key)
 	if absPath != "" && !path.IsAbs(absPath) {
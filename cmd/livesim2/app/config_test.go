@@ -7,9 +7,11 @@ package app
 import (
 	"encoding/json"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDefaults(t *testing.T) {
@@ -65,3 +67,38 @@ func TestEnv(t *testing.T) {
 	c.LogLevel = "warn"
 	assert.Equal(t, c, *cfg)
 }
+
+func TestEnvAllOptions(t *testing.T) {
+	osArgs := []string{"/path/livesim2"}
+	t.Setenv("LIVESIM_TIMEOUTS", "30")
+	t.Setenv("LIVESIM_MAXREQUESTS", "100")
+	t.Setenv("LIVESIM_LAXURLPARAMS", "true")
+	t.Setenv("LIVESIM_CERTPATH", "/certs/cert.pem")
+	t.Setenv("LIVESIM_KEYPATH", "/certs/key.pem")
+	t.Setenv("LIVESIM_LISTENERS", `[{"addr": ":9000", "routes": "admin"}]`)
+	t.Setenv("LIVESIM_VANITYPATHS", `[{"from": "/live", "to": "/livesim2/testpic_2s/Manifest.mpd"}]`)
+	cfg, err := LoadConfig(osArgs, "/root")
+	require.NoError(t, err)
+	require.Equal(t, 30, cfg.TimeoutS)
+	require.Equal(t, 100, cfg.MaxRequests)
+	require.True(t, cfg.LaxURLParams)
+	require.Equal(t, "/certs/cert.pem", cfg.CertPath)
+	require.Equal(t, "/certs/key.pem", cfg.KeyPath)
+	require.Equal(t, []ListenerConfig{{Addr: ":9000", Routes: "admin"}}, cfg.Listeners)
+	require.Equal(t, "/live", cfg.VanityPaths[0].From)
+
+	t.Setenv("LIVESIM_LISTENERS", `[{"addr": `)
+	_, err = LoadConfig(osArgs, "/root")
+	require.ErrorContains(t, err, "LIVESIM_LISTENERS")
+}
+
+func TestEnvConfigFile(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "livesim2.json")
+	require.NoError(t, os.WriteFile(cfgPath, []byte(`{"port": 9999, "loglevel": "error"}`), 0o644))
+	t.Setenv("LIVESIM_CFG", cfgPath)
+	t.Setenv("LIVESIM_LOGLEVEL", "warn")
+	cfg, err := LoadConfig([]string{"/path/livesim2"}, "/root")
+	require.NoError(t, err)
+	require.Equal(t, 9999, cfg.Port)
+	require.Equal(t, "warn", cfg.LogLevel, "environment overrides config file")
+}
@@ -9,6 +9,7 @@ import (
 	"math"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 	"time"
 
@@ -81,7 +82,9 @@ type ResponseConfig struct {
 	EtpDuration                  *int              `json:"EtpDuration,omitempty"`
 	PeriodOffset                 *int              `json:"PeriodOffset,omitempty"`
 	SCTE35PerMinute              *int              `json:"SCTE35PerMinute,omitempty"`
+	SCTE35Type                   string            `json:"SCTE35Type,omitempty"`
 	StartNr                      *int              `json:"StartNr,omitempty"`
+	EndNr                        *int              `json:"EndNr,omitempty"`
 	SuggestedPresentationDelayS  *int              `json:"SuggestedPresentationDelayS,omitempty"`
 	AvailabilityTimeOffsetS      float64           `json:"AvailabilityTimeOffsetS,omitempty"`
 	ChunkDurS                    *float64          `json:"ChunkDurS,omitempty"`
@@ -96,15 +99,76 @@ type ResponseConfig struct {
 	SidxFlag                     bool              `json:"SidxFlag,omitempty"`
 	SegTimelineLossFlag          bool              `json:"SegTimelineLossFlag,omitempty"`
 	AvailabilityTimeCompleteFlag bool              `json:"AvailabilityTimeCompleteFlag,omitempty"`
+	SignaledATC                  *bool             `json:"SignaledATC,omitempty"`
+	LLBrokenFlag                 bool              `json:"LLBrokenFlag,omitempty"`
 	TimeSubsStpp                 []string          `json:"TimeSubsStppLanguages,omitempty"`
 	TimeSubsWvtt                 []string          `json:"TimeSubsWvttLanguages,omitempty"`
 	TimeSubsDurMS                int               `json:"TimeSubsDurMS,omitempty"`
 	TimeSubsRegion               int               `json:"TimeSubsRegion,omitempty"`
+	TimeSubsSampleFlag           bool              `json:"TimeSubsSampleFlag,omitempty"`
+	TimeSubsTimeZone             string            `json:"TimeSubsTimeZone,omitempty"`
+	TimeSubsLocale               string            `json:"TimeSubsLocale,omitempty"`
+	TimecodeFlag                 bool              `json:"TimecodeFlag,omitempty"`
+	CCStripFlag                  bool              `json:"CCStripFlag,omitempty"`
 	Host                         string            `json:"Host,omitempty"`
+	HostAliases                  []string          `json:"-"`
 	PatchTTL                     int               `json:"Patch,omitempty"`
 	DRM                          string            `json:"DRM,omitempty"` // Includes ECCP as eccp-cbcs or eccp-cenc
+	DRMMix                       string            `json:"DRMMix,omitempty"`
+	PSSH                         *PSSHConfig       `json:"PSSH,omitempty"`
+	License                      *LicenseSim       `json:"License,omitempty"`
 	SegStatusCodes               []SegStatusCodes  `json:"SegStatus,omitempty"`
 	Traffic                      []LossItvls       `json:"Traffic,omitempty"`
+	Chaos                        *ChaosConfig      `json:"Chaos,omitempty"`
+	WasmPlugin                   string            `json:"WasmPlugin,omitempty"`
+	ThrottleKbps                 *int              `json:"ThrottleKbps,omitempty"`
+	MPDStall                     *MPDStall         `json:"MPDStall,omitempty"`
+	BurstS                       *int              `json:"BurstS,omitempty"`
+	EarlyMS                      *int              `json:"EarlyMS,omitempty"`
+	Redirect                     *Redirect         `json:"Redirect,omitempty"`
+	CrossHost                    *CrossHost        `json:"CrossHost,omitempty"`
+	PublishTimeCadence           *int              `json:"PublishTimeCadence,omitempty"` // 0 means never
+	MPDExpiryEvents              *int              `json:"MPDExpiryEvents,omitempty"`    // Every n segments with MUP=0
+	ClockSkewS                   *float64          `json:"ClockSkewS,omitempty"`
+	DateSkewS                    *float64          `json:"DateSkewS,omitempty"`
+	VirtualClockFlag             bool              `json:"-"`
+	STLInject                    []string          `json:"STLInject,omitempty"`
+	MPDInflate                   *MPDInflate       `json:"MPDInflate,omitempty"`
+	Viewpoints                   *int              `json:"Viewpoints,omitempty"`
+	HDR                          *HDRSignal        `json:"HDR,omitempty"`
+	IntegrityFlag                bool              `json:"IntegrityFlag,omitempty"`
+	ServerTimingFlag             bool              `json:"ServerTimingFlag,omitempty"`
+	LatencyProbeFlag             bool              `json:"LatencyProbeFlag,omitempty"`
+	MPDSign                      string            `json:"MPDSign,omitempty"`
+	Capabilities                 *CapabilityFilter `json:"Capabilities,omitempty"`
+	Device                       string            `json:"Device,omitempty"`
+	Experiment                   string            `json:"Experiment,omitempty"`
+	BandwidthDrift               *BandwidthDrift   `json:"BandwidthDrift,omitempty"`
+	DurationDriftPct             *float64          `json:"DurationDriftPct,omitempty"`
+	MPDQuirks                    []string          `json:"MPDQuirks,omitempty"`
+	MPDMinimizeFlag              bool              `json:"MPDMinimizeFlag,omitempty"`
+	SizeVariance                 *SizeVariance     `json:"SizeVariance,omitempty"`
+	ChunkCadence                 *ChunkCadence     `json:"ChunkCadence,omitempty"`
+	LookaheadMS                  *int              `json:"LookaheadMS,omitempty"`
+	Trailers                     []string          `json:"Trailers,omitempty"`
+	ChunkAbortFlag               bool              `json:"ChunkAbortFlag,omitempty"`
+	RepChange                    *RepChange        `json:"RepChange,omitempty"`
+	RepIDChange                  *RepIDChange      `json:"RepIDChange,omitempty"`
+	Ladder                       *Ladder           `json:"Ladder,omitempty"`
+	LadderRungKbps               int               `json:"-"`
+	Quota                        *RepQuota         `json:"Quota,omitempty"`
+	SSAI                         *SSAI             `json:"SSAI,omitempty"`
+	Slate                        *Slate            `json:"Slate,omitempty"`
+	Blackout                     *Blackout         `json:"Blackout,omitempty"`
+	Events                       []SessionEvent    `json:"Events,omitempty"`
+	Programs                     *Programs         `json:"Programs,omitempty"`
+	ID3IntervalS                 *int              `json:"ID3IntervalS,omitempty"`
+	QoEProbability               *int              `json:"QoEProbability,omitempty"`
+	SegDurS                      *float64          `json:"SegDurS,omitempty"`
+	LoopS                        *int              `json:"LoopS,omitempty"`
+	Timescale                    *int              `json:"Timescale,omitempty"`
+	LargeTfdtS                   *int              `json:"LargeTfdtS,omitempty"`
+	SessionID                    string            `json:"-"`
 }
 
 // SegStatusCodes configures regular extraordinary segment response codes
@@ -119,6 +183,47 @@ type SegStatusCodes struct {
 	Reps []string
 }
 
+// validate checks that the cycle is positive, rsq non-negative, and the code an HTTP error code.
+func (sc *SegStatusCodes) validate() error {
+	switch {
+	case sc.Cycle <= 0:
+		return fmt.Errorf("cycle is too small")
+	case sc.Rsq < 0:
+		return fmt.Errorf("rsq is too small")
+	case sc.Code < 400 || sc.Code > 599:
+		return fmt.Errorf("code is not in range 400-599")
+	}
+	return nil
+}
+
+// MPDStall configures cyclic freezing of MPD updates.
+// In each cycle, the MPD is generated at the cycle start time for the first DurS seconds,
+// while segments continue to be produced.
+type MPDStall struct {
+	// CycleS is cycle length in seconds
+	CycleS int
+	// DurS is the stall duration in seconds at the start of each cycle
+	DurS int
+}
+
+// validate checks that 0 < DurS < CycleS.
+func (ms *MPDStall) validate() error {
+	if ms.DurS <= 0 || ms.DurS >= ms.CycleS {
+		return fmt.Errorf("mpdstall duration %ds must be > 0 and less than cycle %ds", ms.DurS, ms.CycleS)
+	}
+	return nil
+}
+
+// mpdNowMS returns the time to generate the MPD for at nowMS.
+func (ms *MPDStall) mpdNowMS(nowMS int) int {
+	cycleMS := ms.CycleS * 1000
+	cycleStartMS := nowMS - nowMS%cycleMS
+	if nowMS-cycleStartMS < ms.DurS*1000 {
+		return cycleStartMS
+	}
+	return nowMS
+}
+
 // CreateAllLossItvls creates loss intervals for multiple BaseURLs
 func CreateAllLossItvls(pattern string) ([]LossItvls, error) {
 	if pattern == "" {
@@ -162,6 +267,20 @@ func (l LossItvls) CycleDurS() int {
 	return dur
 }
 
+// validate checks that there is at least one interval and that all durations are positive.
+// The intervals can only be set with the traffic URL parameter.
+func (l LossItvls) validate() error {
+	if len(l.Itvls) == 0 {
+		return fmt.Errorf("traffic pattern has no intervals")
+	}
+	for _, itvl := range l.Itvls {
+		if itvl.durS <= 0 {
+			return fmt.Errorf("traffic interval duration %ds must be > 0", itvl.durS)
+		}
+	}
+	return nil
+}
+
 func (l LossItvls) StateAt(nowS int) lossState {
 	dur := l.CycleDurS()
 	rest := nowS % dur
@@ -263,6 +382,14 @@ func (rc *ResponseConfig) getAvailabilityTimeOffsetS() float64 {
 	return rc.AvailabilityTimeOffsetS
 }
 
+// scte35Type returns the kind of SCTE-35 messages. Default is splice_insert.
+func (rc *ResponseConfig) scte35Type() (scte35.SignalType, error) {
+	if rc.SCTE35Type == "" {
+		return scte35.SpliceInsert, nil
+	}
+	return scte35.ParseSignalType(rc.SCTE35Type)
+}
+
 // getStartNr for MPD. Default value if not set is 1.
 func (rc *ResponseConfig) getStartNr() int {
 	// Default startNr is 1 according to spec, but can be overridden by actual value set in cfg.
@@ -272,6 +399,23 @@ func (rc *ResponseConfig) getStartNr() int {
 	return 1
 }
 
+// endNrTimeMS returns the wall-clock end time of the endNumber segment, and false if endNumber is not set.
+// The end time is that of the reference representation segment, rounded up to milliseconds,
+// so segments of varying duration are handled.
+func (rc *ResponseConfig) endNrTimeMS(a *asset) (int, bool) {
+	if rc.EndNr == nil {
+		return 0, false
+	}
+	rep := a.refRep
+	wrapLen := len(rep.Segments)
+	nrAfterStart := *rc.EndNr - rc.getStartNr()
+	nrWraps := nrAfterStart / wrapLen
+	relNr := nrAfterStart - nrWraps*wrapLen
+	endTime := nrWraps*a.LoopDurMS*rep.MediaTimescale/1000 + int(rep.Segments[relNr].EndTime)
+	endTimeMS := (endTime*1000 + rep.MediaTimescale - 1) / rep.MediaTimescale
+	return rc.StartTimeS*1000 + endTimeMS, true
+}
+
 // processURLCfg returns all information that can be extracted from url
 func processURLCfg(confURL string, nowMS int) (*ResponseConfig, error) {
 	// Mimics configprocessor.process_url
@@ -319,7 +463,7 @@ cfgLoop:
 		case "mup": //minimum update period (in s)
 			cfg.MinimumUpdatePeriodS = sc.AtoiPtr(key, val)
 		case "modulo": // Make a number of time-limited sessions every hour
-			return nil, fmt.Errorf("option %q not implemented", key)
+			return nil, newReasonError(reasonUnknownParameter, fmt.Errorf("option %q not implemented", key))
 		case "tfdt": // Use 32-bit tfdt (which means that AST must be more recent as well)
 			cfg.Tfdt32Flag = true
 		case "cont": // Continuous update of MPD AST and segNr
@@ -344,10 +488,14 @@ cfgLoop:
 			cfg.PeriodOffset = sc.AtoiPtr(key, val)
 		case "scte35": // Signal this many SCTE-35 ad periods inband (emsg messages) every minute
 			cfg.SCTE35PerMinute = sc.AtoiPtr(key, val)
+		case "scte35type": // insert, signal, or program
+			cfg.SCTE35Type = val
 		case "utc": // Get hyphen-separated list of utc-timing methods and make into list
 			cfg.UTCTimingMethods = sc.SplitUTCTimings(key, val)
 		case "snr": // Segment startNumber. -1 means default implicit number which ==  1
 			cfg.StartNr = sc.AtoiPtr(key, val)
+		case "enr": // Segment endNumber. Later segments are not available, and the MPD becomes static after the last one
+			cfg.EndNr = sc.AtoiPtr(key, val)
 		case "ato": // availabilityTimeOffset
 			cfg.AvailabilityTimeOffsetS = sc.AtofInf(key, val)
 		case "ltgt": // latencyTargetMS
@@ -361,6 +509,18 @@ cfgLoop:
 		case "chunkdur": // chunk duration in seconds
 			cfg.ChunkDurS = sc.AtofPosPtr(key, val)
 			cfg.AvailabilityTimeCompleteFlag = false
+		case "chunkcadence": // irregular chunk emission within segments, <front|back|jitter>[_<pct>]
+			cfg.ChunkCadence = sc.ParseChunkCadence(key, val)
+		case "lookahead": // delay of chunk emission after nominal availability (ms)
+			cfg.LookaheadMS = sc.AtoiPtr(key, val)
+		case "trailers": // HTTP trailers on chunked segments, hyphen-separated
+			cfg.Trailers = sc.ParseTrailers(key, val)
+		case "chunkabort": // end chunked segments without the terminating chunk
+			cfg.ChunkAbortFlag = true
+		case "atc": // explicit availabilityTimeComplete, 0 or 1
+			cfg.SignaledATC = sc.ParseZeroOne(key, val)
+		case "llbroken": // allow inconsistent low-latency signaling as test mode
+			cfg.LLBrokenFlag = true
 		case "timesubsstpp": // comma-separated list of languages
 			cfg.TimeSubsStpp = strings.Split(val, ",")
 		case "timesubswvtt": // comma-separated list of languages
@@ -369,14 +529,114 @@ cfgLoop:
 			cfg.TimeSubsDurMS = sc.Atoi(key, val)
 		case "timesubsreg": // region (0 or 1)
 			cfg.TimeSubsRegion = sc.Atoi(key, val)
+		case "timesubssample": // add RTL or CJK sample text for languages with such a sample
+			cfg.TimeSubsSampleFlag = true
+		case "timesubstz": // IANA time zone with / replaced by . like Europe.Stockholm
+			cfg.TimeSubsTimeZone = strings.ReplaceAll(val, ".", "/")
+		case "timesubslocale": // locale format of the time, like en-US
+			cfg.TimeSubsLocale = val
+		case "timecode": // wall-clock time of each video sample in SEI, formatted like the time subtitles
+			cfg.TimecodeFlag = true
+		case "ccstrip": // remove CEA-608/708 captions from video SEI
+			cfg.CCStripFlag = true
 		case "statuscode":
 			cfg.SegStatusCodes = sc.ParseSegStatusCodes(key, val)
 		case "traffic":
 			cfg.Traffic = sc.ParseLossItvls(key, val)
+		case "clockskew": // MPD times offset from segment availability (s)
+			cfg.ClockSkewS = sc.Atof(key, val)
+		case "dateskew": // Date header and time endpoint offset from the (virtual) clock (s)
+			cfg.DateSkewS = sc.Atof(key, val)
+		case "stlinject": // pathological SegmentTimeline constructs, hyphen-separated
+			cfg.STLInject = sc.ParseSTLInject(key, val)
+		case "repchange": // Representations appear or disappear, <add|remove>_<atS>_<repIDs>
+			cfg.RepChange = sc.ParseRepChange(key, val)
+		case "repidchange": // Representation@id gets suffix, <atS>_<suffix>
+			cfg.RepIDChange = sc.ParseRepIDChange(key, val)
+		case "quota": // per-session request quota, <n>_<repIDs>[_<windowS>]
+			cfg.Quota = sc.ParseQuota(key, val)
+		case "ssai": // ad Periods in proxied MPDs, <everyS>_<durS>
+			cfg.SSAI = sc.ParseSSAI(key, val)
+		case "ladder": // synthesized rungs duplicating a Representation, <repID>_<kbps>[,<kbps>...][_pad]
+			cfg.Ladder = sc.ParseLadder(key, val)
+		case "slate": // encoder failover to slate, <cycleS>_<durS>[_signal]
+			cfg.Slate = sc.ParseSlate(key, val)
+		case "blackout": // rights blackout to slate, <startS>_<endS>[_<rep>,...] in wall-clock seconds, endS=0 for no end
+			cfg.Blackout = sc.ParseBlackout(key, val)
+		case "programs": // program boundary events, <durS>[_inband]
+			cfg.Programs = sc.ParsePrograms(key, val)
+		case "id3": // ID3 timed metadata emsg interval (s)
+			cfg.ID3IntervalS = sc.AtoiPtr(key, val)
+		case "metrics": // DVB metrics reporting to /qoe with probability (1-1000)
+			cfg.QoEProbability = sc.AtoiPtr(key, val)
+		case "segdur": // re-chunk video segments to this duration (s)
+			cfg.SegDurS = sc.AtofPosPtr(key, val)
+		case "loop": // loop over only the first seconds of the asset
+			cfg.LoopS = sc.AtoiPtr(key, val)
+		case "timescale": // rewrite video track timescale in MPD, init and media segments
+			cfg.Timescale = sc.AtoiPtr(key, val)
+		case "largetfdt": // offset audio/video media times to pass 2^32 this many seconds after availabilityStartTime
+			cfg.LargeTfdtS = sc.AtoiPtr(key, val)
+		case "mpdinflate": // pad MPD, <kind>_<n> with kind props or as
+			cfg.MPDInflate = sc.ParseMPDInflate(key, val)
+		case "mpdmin": // most compact MPD serialization, with sizes in X-MPD-Size header
+			cfg.MPDMinimizeFlag = true
+		case "mpdquirks": // MPD serialization quirks, hyphen-separated
+			cfg.MPDQuirks = sc.ParseMPDQuirks(key, val)
+		case "mpdsign": // signature of MPD in X-MPD-Signature header, jws for detached JWS
+			cfg.MPDSign = val
+		case "device": // device profile conditioning the MPD: oldtv, android, or desktop
+			cfg.Device = val
+		case "ab": // A/B experiment from config file, variant assigned by session
+			cfg.Experiment = val
+		case "integrity": // SHA-256 of segments in Repr-Digest header, or trailer for chunked segments
+			cfg.IntegrityFlag = true
+		case "servertiming": // Server-Timing header with queue, generation, and wait times
+			cfg.ServerTimingFlag = true
+		case "latencyprobe": // prft boxes and emsg correlation IDs for end-to-end latency reports
+			cfg.LatencyProbeFlag = true
+		case "viewpoints": // number of camera angles of the video, 2-8
+			cfg.Viewpoints = sc.AtoiPtr(key, val)
+		case "hdr": // HDR signaling of video, <kind>[_supplemental][_codecs] with kind pq, hlg, or hlgcompat
+			cfg.HDR = sc.ParseHDR(key, val)
+		case "bwdrift": // declared @bandwidth in percent of actual, comma-separated [<repID>:]<pct>
+			cfg.BandwidthDrift = sc.ParseBandwidthDrift(key, val)
+		case "durdrift": // declared SegmentTemplate@duration in percent of actual
+			cfg.DurationDriftPct = sc.Atof(key, val)
+		case "sizevar": // pad segment mdat with 0-2*pct percent filler, or pad and trim ±pct percent, <pct>[_trim][_<repIDs>]
+			cfg.SizeVariance = sc.ParseSizeVariance(key, val)
+		case "mpdstall": // freeze MPD updates, <cycleS>_<durS>
+			cfg.MPDStall = sc.ParseMPDStall(key, val)
+		case "burst": // publish segments in bursts every n seconds
+			cfg.BurstS = sc.AtoiPtr(key, val)
+		case "early": // segments available this many ms before their nominal availability time
+			cfg.EarlyMS = sc.AtoiPtr(key, val)
+		case "redirect": // redirect segment requests, <depth>[_<delayMS>[_<code>]] with code 302 or 307
+			cfg.Redirect = sc.ParseRedirect(key, val)
+		case "xhost": // BaseURLs on host aliases, <mode>[_<n>] with mode as, rep, or alt
+			cfg.CrossHost = sc.ParseCrossHost(key, val)
+		case "pubtime": // publishTime update cadence, seg, <n> segments, or never
+			cfg.PublishTimeCadence = sc.ParsePublishTimeCadence(key, val)
+		case "mpdevents": // minimumUpdatePeriod=0 and MPD validity expiration emsg every n segments
+			cfg.MPDExpiryEvents = sc.AtoiPtr(key, val)
+		case "chaos": // seeded random faults
+			cfg.Chaos = sc.ParseChaos(key, val)
+		case "wasm": // fault injection by the named WASM plugin
+			cfg.WasmPlugin = val
+		case "throttle": // response body rate limit (kbps)
+			cfg.ThrottleKbps = sc.AtoiPtr(key, val)
 		case "drm":
 			cfg.DRM = val
 		case "eccp":
 			cfg.DRM = "eccp-" + val
+		case "drmmix": // clearaudio or periods (alternating encrypted and clear periods)
+			cfg.DRMMix = val
+		case "pssh": // pssh boxes in init segments, <count>[_<payloadBytes>][_kidsonly]
+			cfg.PSSH = sc.ParsePSSH(key, val)
+		case "license": // delayed and failing license responses, <delayMS>[_<failPercent>]
+			cfg.License = sc.ParseLicense(key, val)
+		case "session": // stored configuration created via /api/sessions
+			cfg.SessionID = val
 		case "patch":
 			ttl := sc.Atoi(key, val)
 			if ttl > 0 {
@@ -393,12 +653,12 @@ cfgLoop:
 	if contentStartIdx == -1 {
 		return nil, fmt.Errorf("no content part")
 	}
+	cfg.URLContentIdx = contentStartIdx
 
 	err = verifyAndFillConfig(cfg, nowMS)
 	if err != nil {
 		return cfg, fmt.Errorf("url config: %w", err)
 	}
-	cfg.URLContentIdx = contentStartIdx
 	return cfg, nil
 }
 
@@ -407,17 +667,260 @@ func verifyAndFillConfig(cfg *ResponseConfig, nowMS int) error {
 		return fmt.Errorf("nowMS must be >= 0")
 	}
 	if cfg.SegTimelineNrFlag && cfg.SegTimelineFlag {
-		return fmt.Errorf("SegmentTimelineTime and SegmentTimelineNr cannot be used at same time")
+		return newReasonError(reasonBadCombination,
+			fmt.Errorf("SegmentTimelineTime and SegmentTimelineNr cannot be used at same time"))
+	}
+	if len(cfg.STLInject) > 0 && !cfg.SegTimelineFlag && !cfg.SegTimelineNrFlag {
+		return newReasonError(reasonBadCombination,
+			fmt.Errorf("stlinject requires segtimeline or segtimelinenr"))
+	}
+	if cfg.EndNr != nil {
+		switch {
+		case cfg.liveMPDType() != segmentNumber:
+			return newReasonError(reasonBadCombination,
+				fmt.Errorf("endNumber requires SegmentTemplate without SegmentTimeline"))
+		case cfg.PeriodsPerHour != nil:
+			return newReasonError(reasonBadCombination, fmt.Errorf("endNumber cannot be combined with periods"))
+		case *cfg.EndNr < cfg.getStartNr():
+			return fmt.Errorf("endNumber %d less than startNumber %d", *cfg.EndNr, cfg.getStartNr())
+		}
+	}
+	if cfg.DurationDriftPct != nil {
+		switch {
+		case *cfg.DurationDriftPct < minDurationDriftPct || *cfg.DurationDriftPct > maxDurationDriftPct:
+			return fmt.Errorf("durdrift %g not in range %d-%d", *cfg.DurationDriftPct, minDurationDriftPct, maxDurationDriftPct)
+		case cfg.liveMPDType() != segmentNumber:
+			return newReasonError(reasonBadCombination,
+				fmt.Errorf("durdrift requires SegmentTemplate without SegmentTimeline"))
+		}
+	}
+	if slices.Contains(cfg.MPDQuirks, quirkDefaults) && slices.Contains(cfg.MPDQuirks, quirkNoDefaults) {
+		return newReasonError(reasonBadCombination, fmt.Errorf("mpdquirks defaults and nodefaults cannot be combined"))
+	}
+	if cfg.MPDMinimizeFlag && len(cfg.MPDQuirks) > 0 {
+		// The quirks re-serialize the MPD, which undoes the minimization and makes the size report wrong
+		return newReasonError(reasonBadCombination, fmt.Errorf("mpdmin cannot be combined with mpdquirks"))
+	}
+	if err := validateSTLInject(cfg.STLInject); err != nil {
+		return err
+	}
+	for i := range cfg.SegStatusCodes {
+		if err := cfg.SegStatusCodes[i].validate(); err != nil {
+			return fmt.Errorf("statuscode: %w", err)
+		}
+	}
+	for _, li := range cfg.Traffic {
+		if err := li.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.Chaos != nil {
+		if err := cfg.Chaos.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.MPDStall != nil {
+		if err := cfg.MPDStall.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.PublishTimeCadence != nil && *cfg.PublishTimeCadence < 0 {
+		return fmt.Errorf("pubtime cadence %d must be >= 0", *cfg.PublishTimeCadence)
+	}
+	if cfg.HDR != nil {
+		if err := cfg.HDR.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.BandwidthDrift != nil {
+		if err := cfg.BandwidthDrift.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.ChunkCadence != nil {
+		if err := cfg.ChunkCadence.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.RepChange != nil {
+		if err := cfg.RepChange.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.RepIDChange != nil {
+		if err := cfg.RepIDChange.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.SSAI != nil {
+		if err := cfg.SSAI.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.MPDInflate != nil {
+		if err := cfg.MPDInflate.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.SizeVariance != nil {
+		if err := cfg.SizeVariance.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.Ladder != nil {
+		if err := cfg.Ladder.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.SegDurS != nil && *cfg.SegDurS <= 0 {
+		return fmt.Errorf("segdur must be > 0")
+	}
+	if cfg.LoopS != nil && *cfg.LoopS <= 0 {
+		return fmt.Errorf("loop must be > 0")
+	}
+	if cfg.Timescale != nil && (*cfg.Timescale < 1 || *cfg.Timescale > maxTimescale) {
+		return fmt.Errorf("timescale %d not in range 1-%d", *cfg.Timescale, maxTimescale)
+	}
+	if cfg.LargeTfdtS != nil && *cfg.LargeTfdtS < 0 {
+		return fmt.Errorf("largetfdt must be >= 0")
+	}
+	if cfg.Timescale != nil && cfg.ChunkDurS != nil {
+		return newReasonError(reasonBadCombination,
+			fmt.Errorf("timescale cannot be combined with chunked low-latency mode"))
+	}
+	if cfg.ChunkCadence != nil && cfg.ChunkDurS == nil {
+		return newReasonError(reasonBadCombination, fmt.Errorf("chunkcadence requires chunkdur"))
+	}
+	if cfg.ThrottleKbps != nil && *cfg.ThrottleKbps <= 0 {
+		return fmt.Errorf("throttle %dkbps must be > 0", *cfg.ThrottleKbps)
+	}
+	if cfg.LookaheadMS != nil {
+		switch {
+		case *cfg.LookaheadMS <= 0 || *cfg.LookaheadMS > maxLookaheadMS:
+			return fmt.Errorf("lookahead %dms not in range 1-%d", *cfg.LookaheadMS, maxLookaheadMS)
+		case cfg.ChunkDurS == nil:
+			return newReasonError(reasonBadCombination, fmt.Errorf("lookahead requires chunkdur"))
+		}
+	}
+	if (len(cfg.Trailers) > 0 || cfg.ChunkAbortFlag) && cfg.ChunkDurS == nil {
+		return newReasonError(reasonBadCombination, fmt.Errorf("trailers and chunkabort require chunkdur"))
+	}
+	if err := verifyLLSignaling(cfg); err != nil {
+		return err
+	}
+	if cfg.QoEProbability != nil && (*cfg.QoEProbability < 1 || *cfg.QoEProbability > 1000) {
+		return fmt.Errorf("metrics probability %d not in range 1-1000", *cfg.QoEProbability)
+	}
+	if cfg.Slate != nil {
+		if err := cfg.Slate.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.Blackout != nil {
+		if err := cfg.Blackout.validate(); err != nil {
+			return err
+		}
+	}
+	if err := validateSessionEvents(cfg.Events); err != nil {
+		return err
+	}
+	if cfg.Quota != nil {
+		if err := cfg.Quota.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.Programs != nil {
+		if err := cfg.Programs.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.ID3IntervalS != nil && *cfg.ID3IntervalS <= 0 {
+		return fmt.Errorf("id3 interval must be > 0")
+	}
+	if err := verifyDRMMix(cfg); err != nil {
+		return err
+	}
+	if cfg.PSSH != nil {
+		if err := cfg.PSSH.validate(cfg); err != nil {
+			return err
+		}
+	}
+	if cfg.License != nil {
+		if err := cfg.License.validate(cfg); err != nil {
+			return err
+		}
+	}
+	if cfg.Viewpoints != nil && (*cfg.Viewpoints < 2 || *cfg.Viewpoints > maxViewpoints) {
+		return fmt.Errorf("viewpoints must be 2 to %d", maxViewpoints)
+	}
+	if cfg.MPDSign != "" && cfg.MPDSign != mpdSignJWS {
+		return fmt.Errorf("mpdsign %q is not supported, only %s", cfg.MPDSign, mpdSignJWS)
 	}
 	if cfg.TimeSubsRegion < 0 || cfg.TimeSubsRegion > 1 {
 		return fmt.Errorf("timesubsreg number must be 0 or 1")
 	}
+	if _, err := newSubsClock(cfg); err != nil {
+		return err
+	}
 	if cfg.MinimumUpdatePeriodS != nil && *cfg.MinimumUpdatePeriodS <= 0 {
 		return fmt.Errorf("minimumUpdatePeriod must be > 0")
 	}
+	if cfg.MPDExpiryEvents != nil {
+		n := *cfg.MPDExpiryEvents
+		switch {
+		case n <= 0:
+			return fmt.Errorf("mpdevents segment cadence must be > 0")
+		case cfg.MinimumUpdatePeriodS != nil:
+			return newReasonError(reasonBadCombination,
+				fmt.Errorf("mpdevents sets minimumUpdatePeriod to 0 and cannot be combined with mup"))
+		case cfg.PublishTimeCadence != nil && *cfg.PublishTimeCadence != n:
+			return newReasonError(reasonBadCombination,
+				fmt.Errorf("mpdevents_%d needs the same publishTime cadence, not pubtime %d", n, *cfg.PublishTimeCadence))
+		}
+		cfg.PublishTimeCadence = Ptr(n)
+	}
+	if cfg.Device != "" {
+		if err := applyDeviceProfile(cfg); err != nil {
+			return err
+		}
+	}
 	if cfg.getAvailabilityTimeOffsetS() > 0 && cfg.LatencyTargetMS == nil {
 		cfg.LatencyTargetMS = Ptr(defaultLatencyTargetMS)
 	}
+	if cfg.BurstS != nil {
+		switch {
+		case *cfg.BurstS <= 0:
+			return fmt.Errorf("burst period must be > 0")
+		case cfg.getAvailabilityTimeOffsetS() != 0 || cfg.ChunkDurS != nil:
+			return newReasonError(reasonBadCombination,
+				fmt.Errorf("burst cannot be combined with availabilityTimeOffset or chunked low-latency mode"))
+		case cfg.TimeShiftBufferDepthS != nil && *cfg.BurstS >= *cfg.TimeShiftBufferDepthS:
+			return newReasonError(reasonBadCombination,
+				fmt.Errorf("burst period %ds must be less than timeShiftBufferDepth %ds", *cfg.BurstS, *cfg.TimeShiftBufferDepthS))
+		}
+	}
+	if cfg.EarlyMS != nil && (*cfg.EarlyMS <= 0 || *cfg.EarlyMS > maxEarlyMS) {
+		return fmt.Errorf("early margin %dms not in range 1-%d", *cfg.EarlyMS, maxEarlyMS)
+	}
+	if cfg.DateSkewS != nil && math.Abs(*cfg.DateSkewS) > maxDateSkewS {
+		return fmt.Errorf("dateskew %gs not in range ±%d", *cfg.DateSkewS, maxDateSkewS)
+	}
+	if cfg.Redirect != nil {
+		if err := cfg.Redirect.validate(); err != nil {
+			return err
+		}
+		if !cfg.Redirect.fromURL(cfg) {
+			return newReasonError(reasonBadCombination, fmt.Errorf("redirect can only be set by the redirect URL parameter"))
+		}
+	}
+	if cfg.CrossHost != nil {
+		if err := cfg.CrossHost.validate(); err != nil {
+			return err
+		}
+		if len(cfg.Traffic) > 0 {
+			return newReasonError(reasonBadCombination, fmt.Errorf("xhost cannot be combined with traffic"))
+		}
+	}
 	if cfg.TimeShiftBufferDepthS != nil {
 		tsbd := *cfg.TimeShiftBufferDepthS
 		if tsbd < 0 || tsbd > MAX_TIME_SHIFT_BUFFER_DEPTH_S {
@@ -425,7 +928,8 @@ func verifyAndFillConfig(cfg *ResponseConfig, nowMS int) error {
 		}
 	}
 	if cfg.ContMultiPeriodFlag && cfg.PeriodsPerHour == nil {
-		return fmt.Errorf("period continuity set, but not multiple periods per hour")
+		return newReasonError(reasonBadCombination,
+			fmt.Errorf("period continuity set, but not multiple periods per hour"))
 	}
 	if cfg.SCTE35PerMinute != nil {
 		err := scte35.IsValidSCTE35Interval(*cfg.SCTE35PerMinute)
@@ -433,6 +937,14 @@ func verifyAndFillConfig(cfg *ResponseConfig, nowMS int) error {
 			return err
 		}
 	}
+	if cfg.SCTE35Type != "" {
+		if cfg.SCTE35PerMinute == nil {
+			return newReasonError(reasonBadCombination, fmt.Errorf("scte35type requires scte35"))
+		}
+		if _, err := scte35.ParseSignalType(cfg.SCTE35Type); err != nil {
+			return err
+		}
+	}
 	// We do not check here that the drm is one that has been configured,
 	// since pre-encrypted content will influence what is valid.
 	return nil
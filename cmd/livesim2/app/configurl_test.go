@@ -237,6 +237,75 @@ func TestProcessURLCfg(t *testing.T) {
 			},
 			err: "",
 		},
+		{
+			url:         "/livesim2/scte35_2/scte35type_program/asset.mpd",
+			nowMS:       0,
+			contentPart: "asset.mpd",
+			wantedCfg: &ResponseConfig{
+				URLParts:                     []string{"", "livesim2", "scte35_2", "scte35type_program", "asset.mpd"},
+				URLContentIdx:                4,
+				StartTimeS:                   0,
+				TimeShiftBufferDepthS:        Ptr(defaultTimeShiftBufferDepthS),
+				StartNr:                      Ptr(0),
+				AvailabilityTimeCompleteFlag: true,
+				TimeSubsDurMS:                defaultTimeSubsDurMS,
+				SCTE35PerMinute:              Ptr(2),
+				SCTE35Type:                   "program",
+			},
+			err: "",
+		},
+		{
+			url:   "/livesim2/scte35type_signal/asset.mpd",
+			nowMS: 0,
+			err:   "url config: scte35type requires scte35",
+		},
+		{
+			url:   "/livesim2/scte35_1/scte35type_splice/asset.mpd",
+			nowMS: 0,
+			err:   `url config: scte35 type "splice" is not insert, signal, or program`,
+		},
+		{
+			url:         "/livesim2/bwdrift_80,V300:150/asset.mpd",
+			nowMS:       0,
+			contentPart: "asset.mpd",
+			wantedCfg: &ResponseConfig{
+				URLParts:                     []string{"", "livesim2", "bwdrift_80,V300:150", "asset.mpd"},
+				URLContentIdx:                3,
+				StartTimeS:                   0,
+				TimeShiftBufferDepthS:        Ptr(defaultTimeShiftBufferDepthS),
+				StartNr:                      Ptr(0),
+				AvailabilityTimeCompleteFlag: true,
+				TimeSubsDurMS:                defaultTimeSubsDurMS,
+				BandwidthDrift:               &BandwidthDrift{DefaultPct: 80, RepPct: map[string]int{"V300": 150}},
+			},
+			err: "",
+		},
+		{
+			url:   "/livesim2/bwdrift_V300:0/asset.mpd",
+			nowMS: 0,
+			err:   "key=bwdrift, pct=0 must be in range 1-1000",
+		},
+		{
+			url:         "/livesim2/sizevar_30_V300,V600/asset.mpd",
+			nowMS:       0,
+			contentPart: "asset.mpd",
+			wantedCfg: &ResponseConfig{
+				URLParts:                     []string{"", "livesim2", "sizevar_30_V300,V600", "asset.mpd"},
+				URLContentIdx:                3,
+				StartTimeS:                   0,
+				TimeShiftBufferDepthS:        Ptr(defaultTimeShiftBufferDepthS),
+				StartNr:                      Ptr(0),
+				AvailabilityTimeCompleteFlag: true,
+				TimeSubsDurMS:                defaultTimeSubsDurMS,
+				SizeVariance:                 &SizeVariance{Pct: 30, RepIDs: []string{"V300", "V600"}},
+			},
+			err: "",
+		},
+		{
+			url:   "/livesim2/sizevar_101/asset.mpd",
+			nowMS: 0,
+			err:   "key=sizevar, pct=101 must be in range 1-100",
+		},
 	}
 
 	for _, c := range cases {
@@ -0,0 +1,132 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+)
+
+const (
+	// crossHostAS gives each AdaptationSet a BaseURL on the next host
+	crossHostAS = "as"
+	// crossHostRep gives each Representation a BaseURL on the next host
+	crossHostRep = "rep"
+	// crossHostAlt lists all hosts as alternative Period BaseURLs
+	crossHostAlt = "alt"
+	// maxCrossHosts is the maximal number of hosts, and the number of hosts a wildcard alias expands to
+	maxCrossHosts = 8
+	// defaultCrossHosts is the number of hosts used if not given
+	defaultCrossHosts = 2
+)
+
+// CrossHost distributes segment URLs over several host names that are aliases of this server.
+type CrossHost struct {
+	// Mode is as, rep, or alt
+	Mode string `json:"Mode"`
+	// N is the number of hosts, 0 means defaultCrossHosts
+	N int `json:"N,omitempty"`
+}
+
+// validate checks the mode and that N is in range.
+func (ch *CrossHost) validate() error {
+	switch ch.Mode {
+	case crossHostAS, crossHostRep, crossHostAlt:
+	default:
+		return fmt.Errorf("xhost unknown mode %q, allowed: %s, %s, %s", ch.Mode, crossHostAS, crossHostRep, crossHostAlt)
+	}
+	if ch.N < 0 || ch.N > maxCrossHosts {
+		return fmt.Errorf("xhost n=%d must be in range 1-%d", ch.N, maxCrossHosts)
+	}
+	return nil
+}
+
+func (ch *CrossHost) nrHosts() int {
+	if ch.N == 0 {
+		return defaultCrossHosts
+	}
+	return ch.N
+}
+
+// parseHostAliases parses a comma-separated list of host names, optionally with port.
+// A wildcard entry *.<domain> expands to h1.<domain> to h<maxCrossHosts>.<domain>.
+func parseHostAliases(aliases string) ([]string, error) {
+	if aliases == "" {
+		return nil, nil
+	}
+	var hosts []string
+	for _, alias := range strings.Split(aliases, ",") {
+		alias = strings.TrimSpace(alias)
+		if alias == "" || strings.ContainsAny(alias, "/?#@") {
+			return nil, fmt.Errorf("hostaliases: %q is not a host name", alias)
+		}
+		if domain, ok := strings.CutPrefix(alias, "*."); ok {
+			for i := 1; i <= maxCrossHosts; i++ {
+				hosts = append(hosts, fmt.Sprintf("h%d.%s", i, domain))
+			}
+			continue
+		}
+		hosts = append(hosts, alias)
+	}
+	return hosts, nil
+}
+
+// crossHostBaseURLs returns absolute BaseURLs of the MPD directory on the first n host aliases.
+// The scheme, port, and path prefix are taken from cfg.Host.
+func crossHostBaseURLs(cfg *ResponseConfig, n int) ([]string, error) {
+	if n > len(cfg.HostAliases) {
+		return nil, newReasonError(reasonBadCombination,
+			fmt.Errorf("xhost needs %d host aliases, but %d are configured", n, len(cfg.HostAliases)))
+	}
+	u, err := url.Parse(cfg.Host)
+	if err != nil {
+		return nil, fmt.Errorf("host %q: %w", cfg.Host, err)
+	}
+	mpdDir := strings.Join(cfg.URLParts[:len(cfg.URLParts)-1], "/") + "/"
+	baseURLs := make([]string, n)
+	for i, alias := range cfg.HostAliases[:n] {
+		host := alias
+		if u.Port() != "" && !strings.Contains(alias, ":") {
+			host += ":" + u.Port()
+		}
+		baseURLs[i] = u.Scheme + "://" + host + strings.TrimSuffix(u.Path, "/") + mpdDir
+	}
+	return baseURLs, nil
+}
+
+// applyCrossHost adds absolute BaseURLs on the host aliases to the MPD.
+func applyCrossHost(mpd *m.MPD, cfg *ResponseConfig) error {
+	baseURLs, err := crossHostBaseURLs(cfg, cfg.CrossHost.nrHosts())
+	if err != nil {
+		return err
+	}
+	for _, p := range mpd.Periods {
+		if cfg.CrossHost.Mode == crossHostAlt {
+			p.BaseURLs = nil
+			for i, bu := range baseURLs {
+				b := m.NewBaseURL(bu)
+				b.ServiceLocation = fmt.Sprintf("host%d", i+1)
+				p.BaseURLs = append(p.BaseURLs, b)
+			}
+			continue
+		}
+		i := 0
+		for _, as := range p.AdaptationSets {
+			if cfg.CrossHost.Mode == crossHostAS {
+				as.BaseURLs = []*m.BaseURLType{m.NewBaseURL(baseURLs[i%len(baseURLs)])}
+				i++
+				continue
+			}
+			for _, rep := range as.Representations {
+				rep.BaseURLs = []*m.BaseURLType{m.NewBaseURL(baseURLs[i%len(baseURLs)])}
+				i++
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHostAliases(t *testing.T) {
+	hosts, err := parseHostAliases("")
+	require.NoError(t, err)
+	require.Nil(t, hosts)
+	hosts, err = parseHostAliases("a.example.com, b.example.com:8443")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a.example.com", "b.example.com:8443"}, hosts)
+	hosts, err = parseHostAliases("*.lvh.me")
+	require.NoError(t, err)
+	require.Len(t, hosts, maxCrossHosts)
+	require.Equal(t, "h1.lvh.me", hosts[0])
+	require.Equal(t, "h8.lvh.me", hosts[7])
+	_, err = parseHostAliases("http://a.example.com")
+	require.Error(t, err)
+}
+
+func TestCrossHost(t *testing.T) {
+	_, ts := newTestServer(t, func(cfg *ServerConfig) {
+		cfg.HostAliases = "*.lvh.me"
+	})
+	port := ts.Listener.Addr().(interface{ String() string }).String()
+	port = port[strings.LastIndex(port, ":")+1:]
+
+	getMPD := func(path string) *m.MPD {
+		resp, body := testFullRequest(t, ts, "GET", path, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode, path)
+		mpd, err := m.ReadFromString(string(body))
+		require.NoError(t, err)
+		return mpd
+	}
+	base := func(host string) string {
+		return "http://" + host + ":" + port + "/livesim2/xhost_"
+	}
+
+	mpd := getMPD("/livesim2/xhost_as/testpic_2s/Manifest.mpd?nowMS=100000")
+	p := mpd.Periods[0]
+	require.Len(t, p.BaseURLs, 0)
+	require.Len(t, p.AdaptationSets, 2)
+	require.Equal(t, base("h1.lvh.me")+"as/testpic_2s/", string(p.AdaptationSets[0].BaseURLs[0].Value))
+	require.Equal(t, base("h2.lvh.me")+"as/testpic_2s/", string(p.AdaptationSets[1].BaseURLs[0].Value))
+
+	mpd = getMPD("/livesim2/xhost_rep_3/testpic_2s/Manifest_imsc1.mpd?nowMS=100000")
+	var hosts []string
+	for _, as := range mpd.Periods[0].AdaptationSets {
+		require.Len(t, as.BaseURLs, 0)
+		for _, rep := range as.Representations {
+			u, err := url.Parse(string(rep.BaseURLs[0].Value))
+			require.NoError(t, err)
+			hosts = append(hosts, u.Hostname())
+		}
+	}
+	require.Equal(t, []string{"h1.lvh.me", "h2.lvh.me", "h3.lvh.me", "h1.lvh.me"}, hosts)
+
+	mpd = getMPD("/livesim2/xhost_alt_4/testpic_2s/Manifest.mpd?nowMS=100000")
+	p = mpd.Periods[0]
+	require.Len(t, p.BaseURLs, 4)
+	require.Equal(t, base("h4.lvh.me")+"alt_4/testpic_2s/", string(p.BaseURLs[3].Value))
+	require.Equal(t, "host4", p.BaseURLs[3].ServiceLocation)
+
+	// The server answers segment requests on any host alias
+	req, err := http.NewRequest("GET", ts.URL+"/livesim2/xhost_as/testpic_2s/V300/45.m4s?nowMS=100000", nil)
+	require.NoError(t, err)
+	req.Host = "h2.lvh.me:" + port
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "*", resp.Header.Get("Access-Control-Allow-Origin"))
+
+	cases := []struct {
+		url          string
+		wantedStatus int
+	}{
+		{url: "/livesim2/xhost_as_9/testpic_2s/Manifest.mpd", wantedStatus: http.StatusBadRequest},
+		{url: "/livesim2/xhost_ring/testpic_2s/Manifest.mpd", wantedStatus: http.StatusBadRequest},
+		{url: "/livesim2/xhost_as/traffic_u10d10/testpic_2s/Manifest.mpd", wantedStatus: http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		resp, _ := testFullRequest(t, ts, "GET", c.url, nil)
+		require.Equal(t, c.wantedStatus, resp.StatusCode, c.url)
+	}
+}
+
+func TestCrossHostWithoutAliases(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	resp, body := testFullRequest(t, ts, "GET", "/livesim2/xhost_as/testpic_2s/Manifest.mpd", nil)
+	require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	require.Contains(t, string(body), "xhost needs 2 host aliases, but 0 are configured")
+}
@@ -0,0 +1,88 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// timePath is the time endpoint usable for all HTTP UTCTiming schemes
+	timePath = "/time"
+	// maxDateSkewS is the maximal absolute skew of the Date header
+	maxDateSkewS = 24 * 3600
+	// isoTimeMSFormat is the format of the time endpoint body, valid as xs:dateTime and ISO 8601
+	isoTimeMSFormat = "2006-01-02T15:04:05.000Z"
+)
+
+// dateMS returns the time to signal in the Date header and time endpoints, and true,
+// if it should differ from the local clock. That is the case for a virtual clock set by nowMS or nowDate
+// queries, or if the Date is skewed by the dateskew parameter.
+func (rc *ResponseConfig) dateMS(nowMS int) (int, bool) {
+	if !rc.VirtualClockFlag && rc.DateSkewS == nil {
+		return 0, false
+	}
+	if rc.DateSkewS != nil {
+		nowMS += int(math.Round(*rc.DateSkewS * 1000))
+	}
+	return nowMS, true
+}
+
+// setDateHeader sets the Date header of the response to follow the virtual clock and date skew.
+// The Go HTTP server only adds its own Date header if none is set.
+func setDateHeader(w http.ResponseWriter, cfg *ResponseConfig, nowMS int) {
+	if dateMS, ok := cfg.dateMS(nowMS); ok {
+		w.Header().Set("Date", time.UnixMilli(int64(dateMS)).UTC().Format(http.TimeFormat))
+	}
+}
+
+// timeURL returns the URL of the time endpoint with the virtual clock nowMS, if set, and the date skew of cfg.
+func (rc *ResponseConfig) timeURL(nowMS int) string {
+	var params []string
+	if rc.VirtualClockFlag {
+		params = append(params, "nowMS="+strconv.Itoa(nowMS))
+	}
+	if rc.DateSkewS != nil {
+		params = append(params, "dateskew="+strconv.FormatFloat(*rc.DateSkewS, 'f', -1, 64))
+	}
+	if len(params) == 0 {
+		return rc.Host + timePath
+	}
+	return rc.Host + timePath + "?" + strings.Join(params, "&")
+}
+
+// timeHandlerFunc returns the time in the Date header, and with millisecond precision in the body.
+// The nowMS query sets a virtual time and dateskew (s) offsets it, like for MPD and segment responses.
+func (s *Server) timeHandlerFunc(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	nowMS, err := getNowMS(q.Get("nowMS"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, reasonBadQuery, "bad nowMS query")
+		return
+	}
+	if skew := q.Get("dateskew"); skew != "" {
+		skewS, err := strconv.ParseFloat(skew, 64)
+		if err != nil || math.Abs(skewS) > maxDateSkewS {
+			writeProblem(w, r, http.StatusBadRequest, reasonBadQuery,
+				fmt.Sprintf("dateskew %q not a number in range ±%d", skew, maxDateSkewS))
+			return
+		}
+		nowMS += int(math.Round(skewS * 1000))
+	}
+	t := time.UnixMilli(int64(nowMS)).UTC()
+	w.Header().Set("Date", t.Format(http.TimeFormat))
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Cache-Control", "no-store")
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = io.WriteString(w, t.Format(isoTimeMSFormat))
+}
@@ -0,0 +1,74 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDateHeader(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	cases := []struct {
+		desc       string
+		url        string
+		wantedCode int
+		wantedDate string
+		wantedBody string
+	}{
+		{
+			desc:       "virtual clock MPD",
+			url:        "/livesim2/testpic_2s/Manifest.mpd?nowMS=100000",
+			wantedCode: http.StatusOK,
+			wantedDate: "Thu, 01 Jan 1970 00:01:40 GMT",
+		},
+		{
+			desc:       "virtual clock segment with date skew",
+			url:        "/livesim2/dateskew_-30/testpic_2s/V300/45.m4s?nowMS=100000",
+			wantedCode: http.StatusOK,
+			wantedDate: "Thu, 01 Jan 1970 00:01:10 GMT",
+		},
+		{
+			desc:       "date skew out of range",
+			url:        "/livesim2/dateskew_100000/testpic_2s/Manifest.mpd?nowMS=100000",
+			wantedCode: http.StatusBadRequest,
+		},
+		{
+			desc:       "time endpoint",
+			url:        "/time?nowMS=100000&dateskew=2.5",
+			wantedCode: http.StatusOK,
+			wantedDate: "Thu, 01 Jan 1970 00:01:42 GMT",
+			wantedBody: "1970-01-01T00:01:42.500Z",
+		},
+		{
+			desc:       "time endpoint bad skew",
+			url:        "/time?dateskew=x",
+			wantedCode: http.StatusBadRequest,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			resp, body := testFullRequest(t, ts, "GET", c.url, nil)
+			require.Equal(t, c.wantedCode, resp.StatusCode)
+			if c.wantedDate != "" {
+				require.Equal(t, c.wantedDate, resp.Header.Get("Date"))
+			}
+			if c.wantedBody != "" {
+				require.Equal(t, c.wantedBody, string(body))
+			}
+		})
+	}
+
+	// UTCTiming points to the time endpoint with the same virtual clock and skew
+	_, body := testFullRequest(t, ts, "GET", "/livesim2/dateskew_2.5/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Contains(t, string(body), `/time?nowMS=100000&amp;dateskew=2.5"`)
+	_, body = testFullRequest(t, ts, "GET", "/livesim2/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Contains(t, string(body), `/time?nowMS=100000"`)
+	_, body = testFullRequest(t, ts, "GET", "/livesim2/testpic_2s/Manifest.mpd", nil)
+	require.Contains(t, string(body), `value="https://time.akamai.com/?iso&amp;ms"`)
+}
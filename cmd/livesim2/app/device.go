@@ -0,0 +1,69 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// deviceProfile is a named device class whose capabilities condition the MPD.
+type deviceProfile struct {
+	caps CapabilityFilter
+	// lowLatency is false if the device cannot play chunked low-latency streams
+	lowLatency bool
+	// spdS is the suggestedPresentationDelay set if not configured, 0 means none
+	spdS int
+}
+
+// deviceProfiles are the profiles available with the device_<name> URL parameter.
+var deviceProfiles = map[string]deviceProfile{
+	// older smart TV with hardware decoders for H.264 and AAC only
+	"oldtv": {
+		caps: CapabilityFilter{Codecs: []string{"avc1", "avc3", "mp4a"}, MaxWidth: 1280, MaxHeight: 720,
+			MaxBandwidth: 4_000_000},
+		spdS: 30,
+	},
+	// mid-range Android phone with a platform player
+	"android": {
+		caps: CapabilityFilter{Codecs: []string{"avc1", "avc3", "hev1", "hvc1", "mp4a", "ac-3", "ec-3", "stpp", "wvtt"},
+			MaxWidth: 1920, MaxHeight: 1080, MaxBandwidth: 8_000_000},
+		lowLatency: true,
+		spdS:       10,
+	},
+	// desktop browser with Media Source Extensions
+	"desktop": {
+		caps: CapabilityFilter{Codecs: []string{"avc1", "avc3", "vp09", "av01", "mp4a", "opus", "stpp", "wvtt"},
+			MaxWidth: 3840, MaxHeight: 2160},
+		lowLatency: true,
+	},
+}
+
+// applyDeviceProfile fills in capabilities and latency settings of the device profile cfg.Device.
+// Capabilities already configured, like from the query, take precedence.
+func applyDeviceProfile(cfg *ResponseConfig) error {
+	dp, ok := deviceProfiles[cfg.Device]
+	if !ok {
+		names := make([]string, 0, len(deviceProfiles))
+		for name := range deviceProfiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("device %q not one of %s", cfg.Device, strings.Join(names, ", "))
+	}
+	if !dp.lowLatency && cfg.ChunkDurS != nil {
+		return newReasonError(reasonBadCombination,
+			fmt.Errorf("device %s does not support chunked low-latency mode", cfg.Device))
+	}
+	caps := dp.caps
+	caps.Codecs = slices.Clone(dp.caps.Codecs)
+	cfg.Capabilities = caps.overriddenBy(cfg.Capabilities)
+	if cfg.SuggestedPresentationDelayS == nil && dp.spdS > 0 {
+		cfg.SuggestedPresentationDelayS = Ptr(dp.spdS)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"net/http"
+	"testing"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeviceProfiles(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	cases := []struct {
+		url              string
+		wantedRepIDs     []string
+		wantedSPD        string
+		wantedStatusCode int
+	}{
+		{url: "device_oldtv/testpic_2s/Manifest_imsc1.mpd", wantedRepIDs: []string{"V300", "A48"},
+			wantedSPD: "PT30S", wantedStatusCode: http.StatusOK},
+		{url: "device_oldtv/spd_5/testpic_2s/Manifest.mpd", wantedRepIDs: []string{"V300", "A48"},
+			wantedSPD: "PT5S", wantedStatusCode: http.StatusOK},
+		{url: "device_oldtv/testpic_2s/Manifest.mpd?nowMS=100000&maxheight=240", wantedRepIDs: []string{"A48"},
+			wantedSPD: "PT30S", wantedStatusCode: http.StatusOK},
+		{url: "device_android/bbb_hevc_ac3_8s/manifest.mpd", wantedRepIDs: []string{"1", "2"},
+			wantedSPD: "PT10S", wantedStatusCode: http.StatusOK},
+		{url: "device_desktop/testpic_2s/Manifest_imsc1.mpd",
+			wantedRepIDs:     []string{"V300", "A48", "imsc1_img_en", "imsc1_txt_sv"},
+			wantedStatusCode: http.StatusOK},
+		{url: "device_desktop/chunkdur_1/ato_1/testpic_2s/Manifest.mpd", wantedRepIDs: []string{"V300", "A48"},
+			wantedStatusCode: http.StatusOK},
+		{url: "device_oldtv/bbb_hevc_ac3_8s/manifest.mpd", wantedStatusCode: http.StatusBadRequest},
+		{url: "device_oldtv/chunkdur_1/ato_1/testpic_2s/Manifest.mpd", wantedStatusCode: http.StatusBadRequest},
+		{url: "device_fridge/testpic_2s/Manifest.mpd", wantedStatusCode: http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		resp, body := testFullRequest(t, ts, "GET", "/livesim2/"+c.url, nil)
+		require.Equal(t, c.wantedStatusCode, resp.StatusCode, c.url)
+		if c.wantedStatusCode != http.StatusOK {
+			continue
+		}
+		mpd, err := m.ReadFromString(string(body))
+		require.NoError(t, err)
+		var repIDs []string
+		for _, as := range mpd.Periods[0].AdaptationSets {
+			for _, rep := range as.Representations {
+				repIDs = append(repIDs, rep.Id)
+			}
+		}
+		require.ElementsMatch(t, c.wantedRepIDs, repIDs, c.url)
+		spd := ""
+		if mpd.SuggestedPresentationDelay != nil {
+			spd = mpd.SuggestedPresentationDelay.String()
+		}
+		require.Equal(t, c.wantedSPD, spd, c.url)
+	}
+}
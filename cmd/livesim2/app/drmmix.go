@@ -0,0 +1,114 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+)
+
+// DRM mix modes, where only part of the content is encrypted with the configured DRM.
+const (
+	// drmMixClearAudio encrypts video, but leaves audio in the clear.
+	drmMixClearAudio = "clearaudio"
+	// drmMixPeriods alternates encrypted and clear periods, starting with an encrypted one.
+	drmMixPeriods = "periods"
+)
+
+// clearInitPrefix is put in front of the init segment file name in clear periods,
+// since the clear and encrypted init segments of a representation must have different URLs.
+const clearInitPrefix = "clear_"
+
+func verifyDRMMix(cfg *ResponseConfig) error {
+	switch cfg.DRMMix {
+	case "":
+		return nil
+	case drmMixClearAudio:
+	case drmMixPeriods:
+		if cfg.PeriodsPerHour == nil {
+			return newReasonError(reasonBadCombination, fmt.Errorf("drmmix periods requires periods"))
+		}
+	default:
+		return fmt.Errorf("drmmix %q not one of %s and %s", cfg.DRMMix, drmMixClearAudio, drmMixPeriods)
+	}
+	if cfg.DRM == "" {
+		return newReasonError(reasonBadCombination, fmt.Errorf("drmmix requires drm or eccp"))
+	}
+	return nil
+}
+
+// clearInitURI returns the URI or template of the clear init segment given the original one.
+func clearInitURI(uri string) string {
+	i := strings.LastIndex(uri, "/")
+	return uri[:i+1] + clearInitPrefix + uri[i+1:]
+}
+
+// clearPeriod returns true if the period with number pNr (since the availabilityStartTime) is clear.
+func clearPeriod(pNr int) bool {
+	return pNr%2 == 1
+}
+
+// isClearInit returns true if segmentPart is a request for a clear init segment of rep.
+func isClearInit(cfg *ResponseConfig, rep *RepData, segmentPart string) bool {
+	switch cfg.DRMMix {
+	case drmMixClearAudio:
+		return rep.ContentType == "audio" && segmentPart == rep.InitURI
+	case drmMixPeriods:
+		return segmentPart == clearInitURI(rep.InitURI)
+	default:
+		return false
+	}
+}
+
+// encryptSegment returns true if the segment of rep starting at mediaTime should be encrypted.
+func encryptSegment(cfg *ResponseConfig, rep *RepData, mediaTime uint64, timescale uint32) bool {
+	if cfg.DRM == "" {
+		return false
+	}
+	switch cfg.DRMMix {
+	case drmMixClearAudio:
+		return rep.ContentType != "audio"
+	case drmMixPeriods:
+		periodDur := uint64(3600 / *cfg.PeriodsPerHour)
+		pNr := mediaTime / uint64(timescale) / periodDur
+		return !clearPeriod(int(pNr))
+	default:
+		return true
+	}
+}
+
+// applyDRMMix removes the ContentProtection descriptors of clear AdaptationSets,
+// and points the clear periods to the clear init segments.
+func applyDRMMix(mpd *m.MPD, cfg *ResponseConfig) {
+	for _, p := range mpd.Periods {
+		periodClear := false
+		if cfg.DRMMix == drmMixPeriods {
+			periodDurMS := 3600 * 1000 / *cfg.PeriodsPerHour
+			periodClear = clearPeriod(periodStartMS(p) / periodDurMS)
+		}
+		for _, as := range p.AdaptationSets {
+			if as.ContentType != "video" && as.ContentType != "audio" {
+				continue
+			}
+			if !periodClear && !(cfg.DRMMix == drmMixClearAudio && as.ContentType == "audio") {
+				continue
+			}
+			as.ContentProtections = nil
+			if !periodClear {
+				continue
+			}
+			if as.SegmentTemplate != nil && as.SegmentTemplate.Initialization != "" {
+				as.SegmentTemplate.Initialization = clearInitURI(as.SegmentTemplate.Initialization)
+			}
+			for _, rep := range as.Representations {
+				if rep.SegmentTemplate != nil && rep.SegmentTemplate.Initialization != "" {
+					rep.SegmentTemplate.Initialization = clearInitURI(rep.SegmentTemplate.Initialization)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/Eyevinn/mp4ff/mp4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDRMMix(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	getMPD := func(prefix string) *m.MPD {
+		resp, body := testFullRequest(t, ts, "GET", prefix+"/testpic_2s/Manifest.mpd?nowMS=130000", nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+		mpd, err := m.ReadFromString(string(body))
+		require.NoError(t, err)
+		return mpd
+	}
+	isEncryptedInit := func(prefix, path string) bool {
+		resp, body := testFullRequest(t, ts, "GET", prefix+"/testpic_2s/"+path, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		f, err := mp4.DecodeFile(bytes.NewBuffer(body))
+		require.NoError(t, err)
+		return f.Init.Moov.Trak.Mdia.Minf.Stbl.Stsd.Encv != nil || f.Init.Moov.Trak.Mdia.Minf.Stbl.Stsd.Enca != nil
+	}
+	isEncryptedSeg := func(prefix, path string) bool {
+		resp, body := testFullRequest(t, ts, "GET", prefix+"/testpic_2s/"+path+"?nowMS=130000", nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		f, err := mp4.DecodeFile(bytes.NewBuffer(body))
+		require.NoError(t, err)
+		return f.Segments[0].Fragments[0].Moof.Traf.Senc != nil
+	}
+
+	// Encrypted video and clear audio
+	prefix := "/livesim2/eccp_cbcs/drmmix_clearaudio"
+	mpd := getMPD(prefix)
+	for _, as := range mpd.Periods[0].AdaptationSets {
+		require.Equal(t, as.ContentType == "video", len(as.ContentProtections) > 0, as.ContentType)
+	}
+	require.True(t, isEncryptedInit(prefix, "V300/init.mp4"))
+	require.False(t, isEncryptedInit(prefix, "A48/init.mp4"))
+	require.True(t, isEncryptedSeg(prefix, "V300/60.m4s"))
+	require.False(t, isEncryptedSeg(prefix, "A48/60.m4s"))
+
+	// Alternating encrypted and clear periods of 60s
+	prefix = "/livesim2/periods_60/eccp_cbcs/drmmix_periods"
+	mpd = getMPD(prefix)
+	require.Len(t, mpd.Periods, 2)
+	for _, p := range mpd.Periods {
+		for _, as := range p.AdaptationSets {
+			clear := p.Id == "P1"
+			require.Equal(t, clear, len(as.ContentProtections) == 0)
+			initTmpl := "$RepresentationID$/init.mp4"
+			if clear {
+				initTmpl = "$RepresentationID$/clear_init.mp4"
+			}
+			require.Equal(t, initTmpl, as.SegmentTemplate.Initialization)
+		}
+	}
+	require.True(t, isEncryptedInit(prefix, "V300/init.mp4"))
+	require.False(t, isEncryptedInit(prefix, "V300/clear_init.mp4"))
+	require.False(t, isEncryptedInit(prefix, "A48/clear_init.mp4"))
+	require.False(t, isEncryptedSeg(prefix, "V300/40.m4s"))
+	require.False(t, isEncryptedSeg(prefix, "A48/40.m4s"))
+	require.True(t, isEncryptedSeg(prefix, "V300/60.m4s"))
+	require.True(t, isEncryptedSeg(prefix, "A48/60.m4s"))
+
+	for _, path := range []string{
+		"/livesim2/eccp_cbcs/drmmix_periods/testpic_2s/Manifest.mpd",
+		"/livesim2/drmmix_clearaudio/testpic_2s/Manifest.mpd",
+		"/livesim2/eccp_cbcs/drmmix_other/testpic_2s/Manifest.mpd",
+	} {
+		resp, _ := testFullRequest(t, ts, "GET", path, nil)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, path)
+	}
+}
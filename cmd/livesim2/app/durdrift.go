@@ -0,0 +1,46 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"math"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+)
+
+const (
+	// minDurationDriftPct is the minimal declared @duration in percent of the actual one
+	minDurationDriftPct = 50
+	// maxDurationDriftPct is the maximal declared @duration in percent of the actual one
+	maxDurationDriftPct = 200
+)
+
+// applyDurationDrift scales the SegmentTemplate@duration values of all AdaptationSets by pct percent.
+// The segments are not changed, so number-addressing players computing segment numbers from
+// the declared duration drift away from the actual media timeline over time.
+func applyDurationDrift(mpd *m.MPD, pct float64) {
+	for _, p := range mpd.Periods {
+		for _, as := range p.AdaptationSets {
+			st := as.SegmentTemplate
+			if st == nil || st.Duration == nil {
+				continue
+			}
+			timescale := uint32(1)
+			if st.Timescale != nil {
+				timescale = *st.Timescale
+			}
+			if timescale < 1000 {
+				// Increase the precision so that small drifts are not rounded away
+				st.Timescale = Ptr(timescale * 1000)
+				st.Duration = Ptr(*st.Duration * 1000)
+				if st.PresentationTimeOffset != nil {
+					st.PresentationTimeOffset = Ptr(*st.PresentationTimeOffset * 1000)
+				}
+			}
+			dur := uint32(math.Round(float64(*st.Duration) * pct / 100))
+			st.Duration = Ptr(max(dur, 1))
+		}
+	}
+}
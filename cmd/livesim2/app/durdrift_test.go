@@ -0,0 +1,47 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"net/http"
+	"testing"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDurationDrift(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	durations := func(drift string) map[string]float64 {
+		t.Helper()
+		resp, body := testFullRequest(t, ts, "GET", "/livesim2/"+drift+"testpic_2s/Manifest.mpd?nowMS=100000", nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		mpd, err := m.ReadFromString(string(body))
+		require.NoError(t, err)
+		durs := make(map[string]float64)
+		for _, as := range mpd.Periods[0].AdaptationSets {
+			st := as.SegmentTemplate
+			timescale := uint32(1)
+			if st.Timescale != nil {
+				timescale = *st.Timescale
+			}
+			durs[string(as.ContentType)] = float64(*st.Duration) / float64(timescale)
+		}
+		return durs
+	}
+	require.Equal(t, map[string]float64{"video": 2, "audio": 2}, durations(""))
+	require.Equal(t, map[string]float64{"video": 2.02, "audio": 2.02}, durations("durdrift_101/"))
+	require.Equal(t, map[string]float64{"video": 1.99, "audio": 1.99}, durations("durdrift_99.5/"))
+
+	// The segments are not changed, so segment 50 is still available at 102s
+	resp, _ := testFullRequest(t, ts, "GET", "/livesim2/durdrift_101/testpic_2s/V300/50.m4s?nowMS=102000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	for _, drift := range []string{"durdrift_40/", "durdrift_101/segtimeline_1/"} {
+		resp, _ := testFullRequest(t, ts, "GET", "/livesim2/"+drift+"testpic_2s/Manifest.mpd?nowMS=100000", nil)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, drift)
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+)
+
+// defaultEmbeddedAddr only accepts local connections on a free port.
+const defaultEmbeddedAddr = "127.0.0.1:0"
+
+// Option configures a Server created by NewServer.
+type Option func(*serverOptions)
+
+type serverOptions struct {
+	cfg    *ServerConfig
+	vodFS  fs.FS
+	logger *slog.Logger
+	addr   string
+	hooks  []Hook
+}
+
+// WithConfig sets the server configuration. The default is DefaultConfig.
+func WithConfig(cfg *ServerConfig) Option {
+	return func(o *serverOptions) {
+		o.cfg = cfg
+	}
+}
+
+// WithVodFS sets the file system with the VoD assets, instead of the vodroot directory of the configuration.
+// It can be an embed.FS or fstest.MapFS in tests.
+func WithVodFS(vodFS fs.FS) Option {
+	return func(o *serverOptions) {
+		o.vodFS = vodFS
+	}
+}
+
+// WithLogger sets the logger of the server. The default is slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *serverOptions) {
+		o.logger = logger
+	}
+}
+
+// WithAddr sets the address that Start listens on. The default is 127.0.0.1:0, a free local port.
+func WithAddr(addr string) Option {
+	return func(o *serverOptions) {
+		o.addr = addr
+	}
+}
+
+// WithHook registers a hook. It can be given several times.
+func WithHook(h Hook) Option {
+	return func(o *serverOptions) {
+		o.hooks = append(o.hooks, h)
+	}
+}
+
+// NewServer creates a livesim2 server that can be embedded in another program, e.g. to test players
+// or proxies in-process. The server is started by Start and stopped by Shutdown,
+// but its Router can also be used directly, e.g. with httptest.NewServer.
+func NewServer(ctx context.Context, opts ...Option) (*Server, error) {
+	cfg := DefaultConfig
+	o := serverOptions{cfg: &cfg, logger: slog.Default(), addr: defaultEmbeddedAddr}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	cfg = *o.cfg
+	// Representation metadata is stored on disk next to the assets, so it is only used without a custom file system
+	switch {
+	case o.vodFS != nil, cfg.RepDataRoot == "-":
+		cfg.RepDataRoot = ""
+	case cfg.RepDataRoot == "+":
+		cfg.RepDataRoot = cfg.VodRoot
+	}
+	if o.vodFS == nil {
+		o.vodFS = os.DirFS(cfg.VodRoot)
+	} else if cfg.AssetUpload {
+		return nil, fmt.Errorf("assetupload requires the vodroot directory, not a custom file system")
+	}
+	o.cfg = &cfg
+	s, err := setupServer(ctx, o.cfg, o.vodFS, o.logger)
+	if err != nil {
+		return nil, err
+	}
+	s.addr = o.addr
+	for _, h := range o.hooks {
+		s.AddHook(h)
+	}
+	return s, nil
+}
+
+// AddHook registers a hook after hooks from plugins and options. It can be called while the server is running.
+func (s *Server) AddHook(h Hook) {
+	s.hooks.add(h)
+}
+
+// Start listens on the configured address and serves requests in the background.
+func (s *Server) Start() error {
+	if s.httpServer != nil {
+		return fmt.Errorf("server already started")
+	}
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	s.listener = ln
+	s.httpServer = &http.Server{Handler: s.Router}
+	go func() {
+		if err := s.httpServer.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("embedded server", "err", err)
+		}
+	}()
+	s.logger.Info("livesim2 listening", "addr", ln.Addr().String())
+	return nil
+}
+
+// URL returns the base URL of a started server, like http://127.0.0.1:34567.
+func (s *Server) URL() string {
+	if s.listener == nil {
+		return ""
+	}
+	return "http://" + s.listener.Addr().String()
+}
+
+// Shutdown gracefully stops the HTTP server and all CMAF ingesters.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.cmafMgr.Close()
+	if s.wasm != nil {
+		if err := s.wasm.close(ctx); err != nil {
+			s.logger.Error("close wasm plugins", "err", err)
+		}
+	}
+	if s.httpServer == nil {
+		return nil
+	}
+	err := s.httpServer.Shutdown(ctx)
+	s.httpServer = nil
+	s.listener = nil
+	return err
+}
@@ -0,0 +1,64 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedServer(t *testing.T) {
+	// Copy an asset to an in-memory file system under a new name
+	vodFS := fstest.MapFS{}
+	srcFS := os.DirFS("testdata/assets/testpic_2s")
+	err := fs.WalkDir(srcFS, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(srcFS, p)
+		if err != nil {
+			return err
+		}
+		vodFS[path.Join("embedded", p)] = &fstest.MapFile{Data: data}
+		return nil
+	})
+	require.NoError(t, err)
+
+	cfg := DefaultConfig
+	cfg.TimeoutS = 0
+	cfg.VodRoot = "nonexistent"
+	server, err := NewServer(context.Background(), WithConfig(&cfg), WithVodFS(vodFS),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	require.NoError(t, err)
+	require.Equal(t, "", server.URL())
+	require.NoError(t, server.Start())
+	require.Error(t, server.Start())
+	baseURL := server.URL()
+	require.NotEqual(t, "", baseURL)
+
+	for _, p := range []string{"/livesim2/embedded/Manifest.mpd", "/vod/embedded/Manifest.mpd", "/healthz"} {
+		resp, err := http.Get(baseURL + p)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode, p)
+	}
+	resp, err := http.Get(baseURL + "/livesim2/testpic_2s/Manifest.mpd")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	require.NoError(t, server.Shutdown(context.Background()))
+	_, err = http.Get(baseURL + "/healthz")
+	require.Error(t, err)
+}
@@ -0,0 +1,144 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// epgMaxProgrammes limits the number of programmes per channel in one EPG response.
+const epgMaxProgrammes = 10000
+
+// xmltvTimeFormat is the XMLTV date format with explicit UTC offset.
+const xmltvTimeFormat = "20060102150405 -0700"
+
+// EPGChannel is the schedule of a channel in a time interval.
+type EPGChannel struct {
+	Name       string         `json:"name"`
+	URL        string         `json:"url" doc:"Path of the channel MPD for the first asset's MPD name"`
+	LoopDurS   float64        `json:"loopDurS" doc:"Duration of one pass through all items"`
+	Programmes []EPGProgramme `json:"programmes"`
+}
+
+// EPGProgramme is one item occurrence in a channel, corresponding to one Period.
+type EPGProgramme struct {
+	Period        int       `json:"period" doc:"Period number, used in the Period id and BaseURL"`
+	Title         string    `json:"title"`
+	Asset         string    `json:"asset"`
+	Start         time.Time `json:"start"`
+	Stop          time.Time `json:"stop"`
+	StartMS       int       `json:"startMS"`
+	StopMS        int       `json:"stopMS"`
+	Discontinuity bool      `json:"discontinuity,omitempty" doc:"Media time and segment numbers restart at the start"`
+}
+
+// epg returns the schedule of all channels, sorted by name, for programmes overlapping [fromMS, toMS).
+// astMS is the availabilityStartTime of the channels, which is 0 unless set via the start URL parameter.
+func (s *Server) epg(fromMS, toMS, astMS int) ([]EPGChannel, error) {
+	names := make([]string, 0, len(s.channels))
+	for name := range s.channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	channels := make([]EPGChannel, 0, len(names))
+	for _, name := range names {
+		ch := s.channels[name]
+		ec := EPGChannel{
+			Name:       name,
+			URL:        fmt.Sprintf("/livesim2/%s%s/%s", channelPathPrefix, name, ch.mpdName()),
+			LoopDurS:   float64(ch.loopDurMS) / 1000,
+			Programmes: []EPGProgramme{},
+		}
+		if toMS > astMS {
+			first := ch.periodAt(max(fromMS, astMS), astMS)
+			for nr := first.nr; ; nr++ {
+				p := ch.period(nr, astMS)
+				if p.startMS >= toMS {
+					break
+				}
+				if len(ec.Programmes) == epgMaxProgrammes {
+					return nil, fmt.Errorf("channel %s has more than %d programmes in the interval", name, epgMaxProgrammes)
+				}
+				ec.Programmes = append(ec.Programmes, EPGProgramme{
+					Period:        p.nr,
+					Title:         p.item.title,
+					Asset:         p.item.a.AssetPath,
+					Start:         time.UnixMilli(int64(p.startMS)).UTC(),
+					Stop:          time.UnixMilli(int64(p.endMS())).UTC(),
+					StartMS:       p.startMS,
+					StopMS:        p.endMS(),
+					Discontinuity: p.item.discontinuity,
+				})
+			}
+		}
+		channels = append(channels, ec)
+	}
+	return channels, nil
+}
+
+// mpdName returns an MPD name of the first item's asset, preferring Manifest.mpd.
+func (ch *channel) mpdName() string {
+	a := ch.items[0].a
+	if _, ok := a.MPDs["Manifest.mpd"]; ok {
+		return "Manifest.mpd"
+	}
+	names := make([]string, 0, len(a.MPDs))
+	for name := range a.MPDs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return "Manifest.mpd"
+	}
+	return names[0]
+}
+
+type xmltvDoc struct {
+	XMLName    xml.Name         `xml:"tv"`
+	Generator  string           `xml:"generator-info-name,attr"`
+	Channels   []xmltvChannel   `xml:"channel"`
+	Programmes []xmltvProgramme `xml:"programme"`
+}
+
+type xmltvChannel struct {
+	ID          string `xml:"id,attr"`
+	DisplayName string `xml:"display-name"`
+	URL         string `xml:"url,omitempty"`
+}
+
+type xmltvProgramme struct {
+	Start   string `xml:"start,attr"`
+	Stop    string `xml:"stop,attr"`
+	Channel string `xml:"channel,attr"`
+	Title   string `xml:"title"`
+	Desc    string `xml:"desc,omitempty"`
+}
+
+// xmltv returns the channel schedules as an XMLTV document.
+func xmltv(channels []EPGChannel) ([]byte, error) {
+	doc := xmltvDoc{Generator: "livesim2"}
+	for _, ec := range channels {
+		doc.Channels = append(doc.Channels, xmltvChannel{ID: ec.Name, DisplayName: ec.Name, URL: ec.URL})
+	}
+	for _, ec := range channels {
+		for _, p := range ec.Programmes {
+			doc.Programmes = append(doc.Programmes, xmltvProgramme{
+				Start:   p.Start.Format(xmltvTimeFormat),
+				Stop:    p.Stop.Format(xmltvTimeFormat),
+				Channel: ec.Name,
+				Title:   p.Title,
+				Desc:    fmt.Sprintf("asset %s, period P%d", p.Asset, p.Period),
+			})
+		}
+	}
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(out, '\n')...), nil
+}
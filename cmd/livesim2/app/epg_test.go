@@ -0,0 +1,80 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEPG(t *testing.T) {
+	_, ts := newTestServer(t, func(cfg *ServerConfig) {
+		cfg.Channels = []ChannelConfig{
+			{Name: "linear", Items: []ChannelItem{
+				{Asset: "testpic_2s", Count: 2, Title: "Test picture"},
+				{Asset: "testpic_2s", DurS: 4, Discontinuity: true},
+			}},
+			{Name: "other", Items: []ChannelItem{{Asset: "testpic_8s"}}},
+		}
+	})
+
+	// The sequence is 16s + 4s = 20s long. From 95s to 120s, there are the programmes
+	// 80s-96s (Period 8), 96s-100s (9), 100s-116s (10), and 116s-120s (11).
+	resp, body := testFullRequest(t, ts, "GET", "/api/epg?nowMS=100000&pastS=5&futureS=20", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var epg struct {
+		Channels []EPGChannel `json:"channels"`
+	}
+	require.NoError(t, json.Unmarshal(body, &epg))
+	require.Len(t, epg.Channels, 2)
+	linear := epg.Channels[0]
+	require.Equal(t, "linear", linear.Name)
+	require.Equal(t, "/livesim2/channels/linear/Manifest.mpd", linear.URL)
+	require.Equal(t, 20.0, linear.LoopDurS)
+	require.Len(t, linear.Programmes, 4)
+	wanted := []struct {
+		period, startMS, stopMS int
+		title                   string
+	}{
+		{8, 80000, 96000, "Test picture"},
+		{9, 96000, 100000, "testpic_2s"},
+		{10, 100000, 116000, "Test picture"},
+		{11, 116000, 120000, "testpic_2s"},
+	}
+	for i, w := range wanted {
+		p := linear.Programmes[i]
+		require.Equal(t, w.period, p.Period)
+		require.Equal(t, w.startMS, p.StartMS)
+		require.Equal(t, w.stopMS, p.StopMS)
+		require.Equal(t, w.title, p.Title)
+		require.Equal(t, int64(w.startMS), p.Start.UnixMilli())
+		require.Equal(t, w.period%2 == 1, p.Discontinuity)
+	}
+	require.Equal(t, "other", epg.Channels[1].Name)
+	require.Len(t, epg.Channels[1].Programmes, 4)
+
+	// Every programme is a Period of the channel MPD
+	resp, body = testFullRequest(t, ts, "GET", "/livesim2/channels/linear/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), `<Period id="P9" start="PT1M36S" duration="PT4S">`)
+
+	resp, body = testFullRequest(t, ts, "GET", "/api/epg/xmltv?nowMS=100000&pastS=0&futureS=10&channel=linear", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/xml", resp.Header.Get("Content-Type"))
+	xmlStr := string(body)
+	require.Contains(t, xmlStr, `<channel id="linear">`)
+	require.NotContains(t, xmlStr, `<channel id="other">`)
+	require.Contains(t, xmlStr, `<programme start="19700101000140 +0000" stop="19700101000156 +0000" channel="linear">`)
+	require.Equal(t, 1, strings.Count(xmlStr, "<programme "))
+
+	resp, _ = testFullRequest(t, ts, "GET", "/api/epg?channel=none", nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = testFullRequest(t, ts, "GET", "/api/epg?pastS=700000", nil)
+	require.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+}
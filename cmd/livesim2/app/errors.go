@@ -7,11 +7,13 @@ package app
 import (
 	"errors"
 	"fmt"
+	"net/http"
 )
 
 var (
 	errNotFound       = errors.New("not found")
 	errGone           = errors.New("gone")
+	errNoCapableRep   = errors.New("no representation matches the client capabilities")
 	ErrAtoInfTimeline = errors.New("infinite availabilityTimeOffset for SegmentTimeline")
 )
 
@@ -26,3 +28,49 @@ func newErrTooEarly(deltaMS int) errTooEarly {
 func (e errTooEarly) Error() string {
 	return fmt.Sprintf("too early by %dms", e.deltaMS)
 }
+
+// reasonError annotates an error with a machine-readable reason code
+// that is reported in problem+json responses.
+type reasonError struct {
+	reason string
+	err    error
+}
+
+func newReasonError(reason string, err error) reasonError {
+	return reasonError{reason: reason, err: err}
+}
+
+func (e reasonError) Error() string {
+	return e.err.Error()
+}
+
+func (e reasonError) Unwrap() error {
+	return e.err
+}
+
+// reasonFromError returns the reason code for an error, or fallback if none is found.
+func reasonFromError(err error, fallback string) string {
+	var rErr reasonError
+	var tooEarly errTooEarly
+	switch {
+	case errors.As(err, &rErr):
+		return rErr.reason
+	case errors.As(err, &tooEarly):
+		return reasonTooEarly
+	case errors.Is(err, errGone):
+		return reasonGone
+	case errors.Is(err, errNotFound):
+		return reasonNotFound
+	default:
+		return fallback
+	}
+}
+
+// mpdErrorStatus returns the HTTP status for an error generating an MPD.
+// Client capabilities that match no representation give 400, and other errors 500.
+func mpdErrorStatus(err error) int {
+	if errors.Is(err, errNoCapableRep) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
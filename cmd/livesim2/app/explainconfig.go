@@ -0,0 +1,93 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ConfigExplanation shows how the configuration part of a livesim2 URL is interpreted.
+type ConfigExplanation struct {
+	URL         string            `json:"url" doc:"Explained URL (path and query)"`
+	NowMS       int               `json:"nowMS" doc:"Wall-clock time (ms) used for time-relative parameters like startrel"`
+	Parameters  []ConfigParameter `json:"parameters" doc:"URL configuration parameters in order"`
+	ContentPart string            `json:"contentPart" doc:"Part of URL after the configuration parameters"`
+	Config      map[string]any    `json:"config" doc:"Fully resolved response configuration, including defaults and unset fields"`
+}
+
+// ConfigParameter is one <key>_<value> part of the URL.
+type ConfigParameter struct {
+	Part  string `json:"part"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// explainConfig resolves the configuration of rawURL at nowMS.
+// A negative nowMS means that the current time is used, unless nowMS or nowDate are in the URL.
+// An invalid configuration gives an error with the same message as a request to the URL.
+func (s *Server) explainConfig(rawURL string, nowMS int) (*ConfigExplanation, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse url: %w", err)
+	}
+	if u.Path == "" || !strings.HasPrefix(u.Path, "/livesim2/") {
+		return nil, fmt.Errorf("url path must start with /livesim2/")
+	}
+	q := u.Query()
+	if nowMS >= 0 {
+		q.Set("nowMS", strconv.Itoa(nowMS))
+	}
+	u.RawQuery = q.Encode()
+
+	log := s.logger.With("explain", u.Path)
+	req := httptest.NewRequest("GET", u.RequestURI(), nil)
+	reqNowMS, cfg, errHT := cfgFromRequest(req, log, s.liveSessions())
+	if errHT == nil {
+		errHT = s.validateURLParams(log, cfg)
+	}
+	if errHT != nil {
+		return nil, fmt.Errorf("%s", errHT.msg)
+	}
+	ce := ConfigExplanation{
+		URL:         u.RequestURI(),
+		NowMS:       reqNowMS,
+		Parameters:  make([]ConfigParameter, 0, cfg.URLContentIdx),
+		ContentPart: cfg.URLContentPart(),
+		Config:      configFields(cfg),
+	}
+	for _, part := range cfg.URLParts[2:cfg.URLContentIdx] {
+		key, val, _ := strings.Cut(part, "_")
+		ce.Parameters = append(ce.Parameters, ConfigParameter{Part: part, Key: key, Value: val})
+	}
+	return &ce, nil
+}
+
+// configFields returns all JSON-visible fields of cfg by JSON name, ignoring omitempty,
+// so that zero values and unset (nil) fields are shown as well.
+func configFields(cfg *ResponseConfig) map[string]any {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	fields := make(map[string]any, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = f.Name
+		}
+		fields[name] = v.Field(i).Interface()
+	}
+	return fields
+}
@@ -0,0 +1,52 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainConfig(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	explain := func(u string) (int, ConfigExplanation) {
+		resp, body := testFullRequest(t, ts, "GET", "/api/explain-config?nowMS=100000&url="+url.QueryEscape(u), nil)
+		var ce ConfigExplanation
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.Unmarshal(body, &ce))
+		}
+		return resp.StatusCode, ce
+	}
+
+	code, ce := explain("/livesim2/segtimeline_1/tsbd_30/startrel_-10/testpic_2s/Manifest.mpd")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, []ConfigParameter{
+		{Part: "segtimeline_1", Key: "segtimeline", Value: "1"},
+		{Part: "tsbd_30", Key: "tsbd", Value: "30"},
+		{Part: "startrel_-10", Key: "startrel", Value: "-10"},
+	}, ce.Parameters)
+	require.Equal(t, "testpic_2s/Manifest.mpd", ce.ContentPart)
+	require.Equal(t, true, ce.Config["SegTimelineFlag"])
+	require.Equal(t, 30.0, ce.Config["TimeShiftBufferDepthS"])
+	require.Equal(t, 90.0, ce.Config["StartTimeS"])
+	require.Contains(t, ce.Config, "SCTE35PerMinute", "unset fields are included")
+	require.Nil(t, ce.Config["SCTE35PerMinute"])
+	require.NotContains(t, ce.Config, "URLContentIdx")
+
+	code, ce = explain("/livesim2/testpic_2s/Manifest.mpd")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, ce.Parameters, 0)
+	require.Equal(t, float64(defaultTimeShiftBufferDepthS), ce.Config["TimeShiftBufferDepthS"])
+
+	code, _ = explain("/livesim2/tsbd_a/testpic_2s/Manifest.mpd")
+	require.Equal(t, http.StatusBadRequest, code)
+	code, _ = explain("/vod/testpic_2s/Manifest.mpd")
+	require.Equal(t, http.StatusBadRequest, code)
+}
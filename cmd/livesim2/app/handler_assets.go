@@ -35,13 +35,7 @@ type mpdInfo struct {
 // assetHandlerFunc returns information about assets
 func (s *Server) assetsHandlerFunc(w http.ResponseWriter, r *http.Request) {
 	forVod := strings.HasPrefix(r.URL.String(), "/vod")
-	assets := make([]*asset, 0, len(s.assetMgr.assets))
-	for _, a := range s.assetMgr.assets {
-		assets = append(assets, a)
-	}
-	sort.Slice(assets, func(i, j int) bool {
-		return assets[i].AssetPath < assets[j].AssetPath
-	})
+	assets := s.assetMgr.listAssets()
 	fh := fullHost(s.Cfg.Host, r)
 	playURL, err := createPlayURL(fh, s.Cfg.PlayURL)
 	if err != nil {
@@ -45,5 +45,5 @@ func (s *Server) optionsHandlerFunc(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) versionHandlerFunc(w http.ResponseWriter, r *http.Request) {
-	s.jsonResponse(w, struct{ Version string }{Version: internal.GetVersion()}, http.StatusOK)
+	s.jsonResponse(w, versionBody{Version: internal.GetVersion()}, http.StatusOK)
 }
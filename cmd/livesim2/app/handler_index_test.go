@@ -5,31 +5,19 @@
 package app
 
 import (
-	"context"
 	"fmt"
 	"net/http"
-	"net/http/httptest"
 	"strings"
 	"testing"
 
 	"github.com/Dash-Industry-Forum/livesim2/internal"
-	"github.com/Dash-Industry-Forum/livesim2/pkg/logging"
 	"github.com/stretchr/testify/require"
 )
 
 func TestIndexPageWithHost(t *testing.T) {
-	cfg := ServerConfig{
-		VodRoot:   "testdata/assets",
-		TimeoutS:  0,
-		LogFormat: logging.LogDiscard,
-		Host:      "https://example.com/subfolder",
-	}
-	err := logging.InitSlog(cfg.LogLevel, cfg.LogFormat)
-	require.NoError(t, err)
-	server, err := SetupServer(context.Background(), &cfg)
-	require.NoError(t, err)
-	ts := httptest.NewServer(server.Router)
-	defer ts.Close()
+	_, ts := newTestServer(t, func(cfg *ServerConfig) {
+		cfg.Host = "https://example.com/subfolder"
+	})
 
 	resp, body := testFullRequest(t, ts, "GET", "/", nil)
 	require.Equal(t, http.StatusOK, resp.StatusCode)
@@ -37,17 +25,7 @@ func TestIndexPageWithHost(t *testing.T) {
 }
 
 func TestIndexPageWithoutHostAndVersion(t *testing.T) {
-	cfg := ServerConfig{
-		VodRoot:   "testdata/assets",
-		TimeoutS:  0,
-		LogFormat: logging.LogDiscard,
-	}
-	err := logging.InitSlog(cfg.LogLevel, cfg.LogFormat)
-	require.NoError(t, err)
-	server, err := SetupServer(context.Background(), &cfg)
-	require.NoError(t, err)
-	ts := httptest.NewServer(server.Router)
-	defer ts.Close()
+	_, ts := newTestServer(t, nil)
 
 	resp, body := testFullRequest(t, ts, "GET", "/", nil)
 	require.Equal(t, http.StatusOK, resp.StatusCode)
@@ -4,9 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/Dash-Industry-Forum/livesim2/pkg/logging"
 )
@@ -19,12 +19,28 @@ const (
 // The response is a JSON array of key IDs.
 // Protocol defined in https://dashif.org/docs/IOP-Guidelines/DASH-IF-IOP-Part6-v5.0.0.pdf.
 func (s *Server) laURLHandlerFunc(w http.ResponseWriter, r *http.Request) {
-	log := logging.SubLoggerWithRequestID(slog.Default(), r)
+	log := logging.SubLoggerWithRequestID(s.logger, r)
 	uPath := r.URL.Path
-	if !strings.HasSuffix(uPath, laURLSuffix) {
+	drmSystem, isProxy := licenseProxySystem(uPath)
+	if !strings.HasSuffix(uPath, laURLSuffix) && !isProxy {
 		msg := fmt.Sprintf("URL does not end with %s", laURLSuffix)
 		log.Error(msg)
 		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	cfg, err := processURLCfg(uPath, int(time.Now().UnixMilli()))
+	if err != nil {
+		msg := fmt.Sprintf("processURL error: %q", err)
+		log.Error(msg)
+		writeProblem(w, r, http.StatusBadRequest, reasonFromError(err, reasonBadValue), msg)
+		return
+	}
+	if cfg.License != nil && cfg.License.apply(w, r, log) {
+		return
+	}
+	if isProxy {
+		licenseProxy(w, r, log, cfg, s.Cfg.DrmCfg, drmSystem)
+		return
 	}
 	// Parse JSON request body which looks like {"kids":["nrQFDeRLSAKTLifXUIPiZg"],"type":"temporary"}
 	// We only care about the kids array.
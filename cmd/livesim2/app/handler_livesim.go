@@ -23,40 +23,49 @@ import (
 	"github.com/Dash-Industry-Forum/livesim2/pkg/drm"
 	"github.com/Dash-Industry-Forum/livesim2/pkg/logging"
 	"github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/go-chi/chi/v5/middleware"
 )
 
 type errorWithHttpType struct {
 	msg        string
 	statusCode int
+	reason     string
+	issues     []urlParamIssue
 }
 
 func (e errorWithHttpType) Error() string {
 	return e.msg
 }
 
-func generateAndLogHttpError(log *slog.Logger, msg string, statusCode int) *errorWithHttpType {
+func generateAndLogHttpError(log *slog.Logger, msg string, statusCode int, reason string) *errorWithHttpType {
 	log.Error(msg)
-	return &errorWithHttpType{msg, statusCode}
+	return &errorWithHttpType{msg: msg, statusCode: statusCode, reason: reason}
 }
 
-func cfgFromRequest(r *http.Request, log *slog.Logger) (nowMS int, cfg *ResponseConfig, errHT *errorWithHttpType) {
+// cfgFromRequest derives the response configuration from URL, query and configuration overlays.
+// sessions may be nil, in which case session_<id> URL parameters are not supported.
+func cfgFromRequest(r *http.Request, log *slog.Logger, sessions *sessionStore) (nowMS int, cfg *ResponseConfig,
+	errHT *errorWithHttpType) {
 	uPath := r.URL.Path
 	u, err := url.Parse(uPath)
 	if err != nil {
-		return 0, nil, generateAndLogHttpError(log, "URL parsing", http.StatusInternalServerError)
+		return 0, nil, generateAndLogHttpError(log, "URL parsing", http.StatusInternalServerError, reasonInternal)
 	}
 
 	q := r.URL.Query()
 	nowMS, err = getNowMS(q.Get("nowMS"))
 	if err != nil {
-		return 0, nil, generateAndLogHttpError(log, "bad nowMS query", http.StatusBadRequest)
+		return 0, nil, generateAndLogHttpError(log, "bad nowMS query", http.StatusBadRequest, reasonBadQuery)
+	}
+	if clockMS, ok := r.Context().Value(wallClockKey{}).(int); ok && q.Get("nowMS") == "" {
+		nowMS = clockMS
 	}
 
 	nowDate := q.Get("nowDate")
 	if nowDate != "" {
 		nowMS, err = getMSFromDate(nowDate)
 		if err != nil {
-			return 0, nil, generateAndLogHttpError(log, "bad nowDate query", http.StatusBadRequest)
+			return 0, nil, generateAndLogHttpError(log, "bad nowDate query", http.StatusBadRequest, reasonBadQuery)
 		}
 	}
 
@@ -64,14 +73,28 @@ func cfgFromRequest(r *http.Request, log *slog.Logger) (nowMS int, cfg *Response
 	if publishTime != "" {
 		nowMS, err = getMSFromDate(publishTime)
 		if err != nil {
-			return 0, nil, generateAndLogHttpError(log, "bad publishTime query", http.StatusBadRequest)
+			return 0, nil, generateAndLogHttpError(log, "bad publishTime query", http.StatusBadRequest, reasonBadQuery)
 		}
 	}
 
 	cfg, err = processURLCfg(u.String(), nowMS)
 	if err != nil {
 		msg := fmt.Sprintf("processURL error: %q", err)
-		return 0, nil, generateAndLogHttpError(log, msg, http.StatusBadRequest)
+		return 0, nil, generateAndLogHttpError(log, msg, http.StatusBadRequest, reasonFromError(err, reasonBadValue))
+	}
+	// publishTime only regenerates an earlier MPD for patches, and is not a clock of the client
+	cfg.VirtualClockFlag = q.Get("nowMS") != "" || nowDate != ""
+
+	qCaps, err := parseCapabilityQuery(q)
+	if err != nil {
+		msg := fmt.Sprintf("bad capability query: %s", err)
+		return 0, nil, generateAndLogHttpError(log, msg, http.StatusBadRequest, reasonBadQuery)
+	}
+	cfg.Capabilities = cfg.Capabilities.overriddenBy(qCaps)
+
+	if errHT := applyConfigOverlays(cfg, sessions, r.Header.Get(configHeader), nowMS); errHT != nil {
+		log.Error(errHT.msg)
+		return 0, nil, errHT
 	}
 
 	if cfg.TimeOffsetS != nil {
@@ -82,7 +105,7 @@ func cfgFromRequest(r *http.Request, log *slog.Logger) (nowMS int, cfg *Response
 	if nowMS < cfg.StartTimeS*1000 {
 		tooEarlyMS := cfg.StartTimeS - nowMS
 		msg := fmt.Sprintf("%dms too early", tooEarlyMS)
-		return 0, nil, generateAndLogHttpError(log, msg, http.StatusTooEarly)
+		return 0, nil, generateAndLogHttpError(log, msg, http.StatusTooEarly, reasonTooEarly)
 	}
 
 	return nowMS, cfg, nil
@@ -91,87 +114,220 @@ func cfgFromRequest(r *http.Request, log *slog.Logger) (nowMS int, cfg *Response
 // livesimHandlerFunc handles mpd and segment requests.
 // ?nowMS=... can be used to set the current time for testing.
 func (s *Server) livesimHandlerFunc(w http.ResponseWriter, r *http.Request) {
-	log := logging.SubLoggerWithRequestID(slog.Default(), r)
-	nowMS, cfg, errHT := cfgFromRequest(r, log)
+	start := time.Now()
+	log := logging.SubLoggerWithRequestID(s.logger, r)
+	nowMS, cfg, errHT := cfgFromRequest(r, log, s.liveSessions())
+	if errHT == nil {
+		setDateHeader(w, cfg, nowMS)
+		errHT = s.validateURLParams(log, cfg)
+	}
+	if errHT == nil {
+		log, errHT = s.applyVariant(w, r, log, cfg, nowMS)
+	}
 	if errHT != nil {
-		http.Error(w, errHT.Error(), errHT.statusCode)
+		writeHttpTypeProblem(w, r, errHT)
 		return
 	}
 
+	if cfg.ServerTimingFlag {
+		kind := "segment"
+		if isManifest(r.URL.Path) {
+			kind = "mpd"
+		}
+		var st *serverTiming
+		st, r = newServerTiming(r, start, kind)
+		w = &serverTimingWriter{ResponseWriter: w, st: st}
+	}
+
 	contentPart := cfg.URLContentPart()
 	log.Debug("requested content", "url", contentPart)
+	if ch, rest, ok := s.findChannel(contentPart); ok {
+		cfg.SetHost(s.Cfg.Host, r)
+		s.writeChannel(w, r, log, cfg, ch, rest, start, nowMS)
+		return
+	}
+	if px, rest, ok := s.findProxy(contentPart); ok {
+		cfg.SetHost(s.Cfg.Host, r)
+		s.writeProxy(w, r, log, cfg, px, rest, nowMS)
+		return
+	}
+	if cfg.SSAI != nil {
+		writeProblem(w, r, http.StatusBadRequest, reasonBadCombination, "ssai requires a proxied upstream")
+		return
+	}
 	a, ok := s.assetMgr.findAsset(contentPart)
 	if !ok {
 		msg := fmt.Sprintf("unknown asset %q", contentPart)
 		log.Error(msg)
-		http.Error(w, msg, http.StatusNotFound)
+		writeProblem(w, r, http.StatusNotFound, reasonNotFound, msg)
+		return
+	}
+	a, err := configuredAsset(s.assetMgr.vodFS, a, cfg)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, reasonBadCombination, err.Error())
 		return
 	}
 	cfg.SetHost(s.Cfg.Host, r)
+	cfg.HostAliases = s.hostAliases
+	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+	w = ww
+	defer func() {
+		s.recordStats(r, ww, start, a.AssetPath, statsRepID(a, contentPart))
+	}()
+	var done bool
+	if w, nowMS, done = s.applyFaults(w, r, log, cfg, contentPart, nowMS); done {
+		return
+	}
 	switch filepath.Ext(r.URL.Path) {
 	case ".mpd":
 		_, mpdName := path.Split(contentPart)
-		err := writeLiveMPD(log, w, cfg, s.Cfg.DrmCfg, a, mpdName, nowMS)
+		if cfg.MPDStall != nil {
+			nowMS = cfg.MPDStall.mpdNowMS(nowMS)
+		}
+		nowMS = cfg.burstNowMS(nowMS)
+		mpd, err := writeLiveMPD(log, w, cfg, s.Cfg.DrmCfg, a, mpdName, nowMS,
+			func(lMPD *mpd.MPD) error { return s.hooks.rewriteMPD(r, lMPD) }, s.mpdSigner)
 		if err != nil {
 			log.Error("liveMPD", "err", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeProblem(w, r, mpdErrorStatus(err), reasonFromError(err, reasonInternal), err.Error())
 			return
 		}
+		s.recordMPD(r, cfg, nowMS, mpd)
+	case hlsExt, ".json":
+		_, fileName := path.Split(contentPart)
+		s.writeSibling(w, r, cfg, a, fileName, nowMS)
 	case ".mp4", ".m4s", ".cmfv", ".cmfa", ".cmft", ".jpg", ".jpeg", ".m4v", ".m4a":
+		if cfg.Redirect != nil {
+			cfg.Redirect.apply(w, r, log, cfg)
+			return
+		}
 		segmentPart := strings.TrimPrefix(contentPart, a.AssetPath) // includes heading slash
 		if len(cfg.Traffic) > 0 {
-			var patternNr int
-			patternNr, segmentPart = extractPattern(segmentPart)
-			if patternNr >= 0 {
-				itvls := cfg.Traffic[patternNr]
-				switch itvls.StateAt(nowMS / 1000) {
-				case lossNo:
-					// Just continue
-				case loss404:
-					http.Error(w, "Not Found", http.StatusNotFound)
-					return
-				case lossSlow:
-					time.Sleep(lossSlowTime)
-				case lossHang:
-					// Get the result, but after 10s
-					time.Sleep(lossHangTime)
-					http.Error(w, "Hang", http.StatusServiceUnavailable)
-					return
-				default:
-					http.Error(w, "strange loss state", http.StatusInternalServerError)
-					return
-				}
+			var done bool
+			segmentPart, done = applyTrafficLoss(w, r, cfg, segmentPart, nowMS)
+			if done {
+				return
 			}
 		}
-		code, err := writeSegment(r.Context(), w, log, cfg, s.Cfg.DrmCfg, s.assetMgr.vodFS, a, segmentPart[1:],
-			nowMS, s.textTemplates, false /*isLast */)
-		if err != nil {
-			log.Error("writeSegment", "code", code, "err", err)
-			var tooEarly errTooEarly
-			switch {
-			case errors.Is(err, errNotFound):
-				http.Error(w, "Not Found", http.StatusNotFound)
+		if cfg.Viewpoints != nil {
+			segmentPart = origViewpointSegmentPart(segmentPart)
+		}
+		if cfg.RepIDChange != nil {
+			segmentPart = cfg.RepIDChange.origSegmentPart(a, segmentPart)
+		}
+		if cfg.Ladder != nil {
+			segmentPart, cfg.LadderRungKbps, err = cfg.Ladder.origSegmentPart(segmentPart)
+			if err != nil {
+				writeProblem(w, r, http.StatusNotFound, reasonNotFound, "ladder rung not found")
 				return
-			case errors.As(err, &tooEarly):
-				http.Error(w, tooEarly.Error(), http.StatusTooEarly)
-			case errors.Is(err, errGone):
-				http.Error(w, "Gone", http.StatusGone)
-			default:
-				http.Error(w, "writeSegment", http.StatusInternalServerError)
+			}
+		}
+		if cfg.Quota != nil {
+			repID := statsRepID(a, a.AssetPath+segmentPart)
+			if cfg.LadderRungKbps > 0 {
+				repID = cfg.Ladder.rungID(cfg.LadderRungKbps)
+			}
+			if !s.checkQuota(w, r, cfg, a, repID) {
 				return
 			}
 		}
+		sw := w
+		var dw *digestWriter
+		if cfg.IntegrityFlag {
+			dw = newDigestWriter(w)
+			sw = dw
+		}
+		code, err := writeSegment(r.Context(), sw, log, cfg, s.Cfg.DrmCfg, s.assetMgr.vodFS, a, segmentPart[1:],
+			nowMS, s.textTemplates, false /*isLast */)
+		if errors.Is(err, errChunkAbort) {
+			abortChunkedResponse()
+		}
+		if err != nil {
+			log.Error("writeSegment", "code", code, "err", err)
+			writeSegmentProblem(w, r, err)
+			return
+		}
 		if code != 0 {
 			log.Debug("special return code", "code", code)
-			http.Error(w, "triggered code", code)
+			writeProblem(w, r, code, reasonTriggeredStatus, "triggered code")
 			return
 		}
+		if dw != nil {
+			if err := dw.finish(); err != nil {
+				log.Error("write segment with digest", "err", err)
+			}
+		}
+	case "":
+		s.writeSmooth(w, r, log, cfg, a, contentPart, nowMS)
 	default:
-		http.Error(w, "unknown file extension", http.StatusNotFound)
+		writeProblem(w, r, http.StatusNotFound, reasonUnknownExtension, "unknown file extension")
 		return
 	}
 }
 
+// recordStats adds a finished request to the asset statistics, and to the CMCD sessions if enabled.
+func (s *Server) recordStats(r *http.Request, ww middleware.WrapResponseWriter, start time.Time, assetPath, repID string) {
+	now := time.Now()
+	s.assetStats.record(now, assetPath, repID, ww.Status(), ww.BytesWritten())
+	if s.cmcd != nil {
+		s.cmcd.record(now, parseCMCD(r), repID, ww.Status(), ww.BytesWritten(), now.Sub(start))
+	}
+}
+
+// applyFaults applies the chaos, wasm, and throttle response faults.
+// It returns the writer and time to use, and done if the response has already been written.
+func (s *Server) applyFaults(w http.ResponseWriter, r *http.Request, log *slog.Logger, cfg *ResponseConfig,
+	contentPart string, nowMS int) (http.ResponseWriter, int, bool) {
+	if cfg.Chaos != nil {
+		var done bool
+		w, nowMS, done = applyChaos(w, r, log, cfg.Chaos, contentPart, isManifest(r.URL.Path), nowMS)
+		if done {
+			return w, nowMS, true
+		}
+	}
+	if cfg.WasmPlugin != "" {
+		var done bool
+		w, done = s.applyWasmPlugin(w, r, log, cfg.WasmPlugin, isManifest(r.URL.Path), nowMS)
+		if done {
+			return w, nowMS, true
+		}
+	}
+	if cfg.ThrottleKbps != nil {
+		w = &throttledWriter{ResponseWriter: w, ctx: r.Context(), limiter: newBwLimiter(*cfg.ThrottleKbps)}
+	}
+	return w, nowMS, false
+}
+
+// recordMPD adds a generated MPD to the MPD history if enabled.
+func (s *Server) recordMPD(r *http.Request, cfg *ResponseConfig, nowMS int, mpd []byte) {
+	if s.mpdHistory == nil {
+		return
+	}
+	key := mpdHistoryKey(cfg, r.URL.Path)
+	snap := MPDSnapshot{Time: time.Now(), NowMS: nowMS, URL: r.URL.RequestURI(), MPD: string(mpd)}
+	s.mpdHistory.add(key, snap)
+	s.archiveMPD(key, snap)
+}
+
+// writeSegmentProblem writes a problem response for an error from writeSegment.
+func writeSegmentProblem(w http.ResponseWriter, r *http.Request, err error) {
+	var tooEarly errTooEarly
+	switch {
+	case errors.Is(err, errNotFound):
+		writeProblem(w, r, http.StatusNotFound, reasonNotFound, "Not Found")
+	case errors.As(err, &tooEarly):
+		writeProblem(w, r, http.StatusTooEarly, reasonTooEarly, tooEarly.Error())
+	case errors.Is(err, errGone):
+		writeProblem(w, r, http.StatusGone, reasonGone, "Gone")
+	default:
+		writeProblem(w, r, http.StatusInternalServerError, reasonInternal, "writeSegment")
+	}
+}
+
+// wallClockKey is a context key for a fixed wall-clock time (ms) to use instead of the local clock.
+// It is set when recording and replaying sessions.
+type wallClockKey struct{}
+
 // getNowMS returns value from query or local clock.
 func getNowMS(nowMSValue string) (nowMS int, err error) {
 	if nowMSValue != "" {
@@ -191,6 +347,36 @@ func getMSFromDate(publishTimeValue string) (nowMS int, err error) {
 	return int(t.UnixMilli()) + 1, nil
 }
 
+// applyTrafficLoss applies the loss state of the traffic pattern in the segment part, and returns
+// the segment part without the pattern, or done if the response has been written.
+func applyTrafficLoss(w http.ResponseWriter, r *http.Request, cfg *ResponseConfig, segmentPart string,
+	nowMS int) (string, bool) {
+	patternNr, segmentPart := extractPattern(segmentPart)
+	if patternNr < 0 {
+		return segmentPart, false
+	}
+	itvls := cfg.Traffic[patternNr]
+	switch itvls.StateAt(nowMS / 1000) {
+	case lossNo:
+		// Just continue
+	case loss404:
+		writeProblem(w, r, http.StatusNotFound, reasonTrafficLoss, "Not Found")
+		return segmentPart, true
+	case lossSlow:
+		addServerTimingWait(r.Context(), lossSlowTime)
+		time.Sleep(lossSlowTime)
+	case lossHang:
+		// Get the result, but after 10s
+		time.Sleep(lossHangTime)
+		writeProblem(w, r, http.StatusServiceUnavailable, reasonTrafficLoss, "Hang")
+		return segmentPart, true
+	default:
+		writeProblem(w, r, http.StatusInternalServerError, reasonInternal, "strange loss state")
+		return segmentPart, true
+	}
+	return segmentPart, false
+}
+
 // extractPattern extracts the pattern number and return a modified segmentPart.
 func extractPattern(segmentPart string) (int, string) {
 	parts := strings.Split(segmentPart, "/")
@@ -208,32 +394,74 @@ func extractPattern(segmentPart string) (int, string) {
 	return nr, strings.Join(parts, "/")
 }
 
+// writeLiveMPD generates and writes a live MPD, and returns the written bytes.
+// If rewrite is not nil, it is applied to the MPD before it is serialized.
 func writeLiveMPD(log *slog.Logger, w http.ResponseWriter, cfg *ResponseConfig, drmCfg *drm.DrmConfig,
-	a *asset, mpdName string, nowMS int) error {
-	work := make([]byte, 0, 1024)
-	buf := bytes.NewBuffer(work)
+	a *asset, mpdName string, nowMS int, rewrite func(*mpd.MPD) error, signer *mpdSigner) ([]byte, error) {
 	lMPD, err := LiveMPD(a, mpdName, cfg, drmCfg, nowMS)
 	if err != nil {
-		return fmt.Errorf("convertToLive: %w", err)
+		return nil, fmt.Errorf("convertToLive: %w", err)
+	}
+	return writeMPD(log, w, cfg, lMPD, rewrite, signer)
+}
+
+// writeMPD applies rewrite, if not nil, and writes the serialized MPD. It returns the written bytes.
+// If mpdsign is configured, the detached signature of the bytes is sent in the X-MPD-Signature header.
+func writeMPD(log *slog.Logger, w http.ResponseWriter, cfg *ResponseConfig, lMPD *mpd.MPD,
+	rewrite func(*mpd.MPD) error, signer *mpdSigner) ([]byte, error) {
+	work := make([]byte, 0, 1024)
+	buf := bytes.NewBuffer(work)
+	if rewrite != nil {
+		if err := rewrite(lMPD); err != nil {
+			return nil, err
+		}
 	}
 	size, err := lMPD.Write(buf, "  ", true)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if cfg.MPDMinimizeFlag {
+		data, err := writeMinimizedMPD(lMPD)
+		if err != nil {
+			return nil, fmt.Errorf("mpdmin: %w", err)
+		}
+		w.Header().Set(mpdSizeHeader, mpdSizeReport(size, len(data)))
+		buf = bytes.NewBuffer(data)
+		size = buf.Len()
+	}
+	if cfg.QoEProbability != nil {
+		buf = bytes.NewBuffer(applyDVBReporting(cfg, buf.Bytes()))
+		size = buf.Len()
+	}
+	if len(cfg.MPDQuirks) > 0 {
+		data, err := applyMPDQuirks(buf.Bytes(), cfg.MPDQuirks)
+		if err != nil {
+			return nil, fmt.Errorf("mpdquirks: %w", err)
+		}
+		buf = bytes.NewBuffer(data)
+		size = buf.Len()
+	}
+	if cfg.MPDSign == mpdSignJWS && signer != nil {
+		sig, err := signer.sign(buf.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("sign MPD: %w", err)
+		}
+		w.Header().Set(mpdSignatureHeader, sig)
 	}
 	w.Header().Set("Content-Length", strconv.Itoa(size))
 	w.Header().Set("Content-Type", "application/dash+xml")
 	n, err := w.Write(buf.Bytes())
 	if err != nil {
 		log.Error("writing response")
-		return err
+		return nil, err
 	}
 	if n != size {
 		log.Error("could not write all bytes",
 			"size", size,
 			"nr written", n)
-		return err
+		return nil, err
 	}
-	return nil
+	return buf.Bytes(), nil
 }
 
 // writeSegment writes a segment to the response writer, but may also return a special status code if configured.
@@ -5,29 +5,16 @@
 package app
 
 import (
-	"context"
 	"fmt"
 	"net/http"
-	"net/http/httptest"
 	"strings"
 	"testing"
 
-	"github.com/Dash-Industry-Forum/livesim2/pkg/logging"
 	"github.com/stretchr/testify/require"
 )
 
 func TestParamToMPD(t *testing.T) {
-	cfg := ServerConfig{
-		VodRoot:   "testdata/assets",
-		TimeoutS:  0,
-		LogFormat: logging.LogDiscard,
-	}
-	err := logging.InitSlog(cfg.LogLevel, cfg.LogFormat)
-	require.NoError(t, err)
-	server, err := SetupServer(context.Background(), &cfg)
-	require.NoError(t, err)
-	ts := httptest.NewServer(server.Router)
-	defer ts.Close()
+	_, ts := newTestServer(t, nil)
 	testCases := []struct {
 		desc             string
 		mpd              string
@@ -56,6 +43,62 @@ func TestParamToMPD(t *testing.T) {
 			wantedStatusCode: http.StatusOK,
 			wantedInMPD:      []string{`<Latency referenceId="0" target="3500" max="7000" min="2625"></Latency>`},
 		},
+		{
+			desc:             "chunk cadence",
+			mpd:              "testpic_2s/Manifest.mpd",
+			params:           "ato_1/chunkdur_0.25/chunkcadence_back_30/",
+			wantedStatusCode: http.StatusOK,
+		},
+		{
+			desc:             "chunk cadence without chunks",
+			mpd:              "testpic_2s/Manifest.mpd",
+			params:           "chunkcadence_jitter/",
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "encoder lookahead",
+			mpd:              "testpic_2s/Manifest.mpd",
+			params:           "ato_1/chunkdur_0.25/lookahead_400/",
+			wantedStatusCode: http.StatusOK,
+		},
+		{
+			desc:             "encoder lookahead out of range",
+			mpd:              "testpic_2s/Manifest.mpd",
+			params:           "ato_1/chunkdur_0.25/lookahead_20000/",
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "availabilityTimeComplete true with chunks",
+			mpd:              "testpic_2s/Manifest.mpd",
+			params:           "ato_1/chunkdur_0.25/atc_1/",
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "availabilityTimeComplete true with chunks as test mode",
+			mpd:              "testpic_2s/Manifest.mpd",
+			params:           "ato_1/chunkdur_0.25/atc_1/llbroken_1/",
+			wantedStatusCode: http.StatusOK,
+			wantedInMPD:      []string{`availabilityTimeOffset="1"`},
+		},
+		{
+			desc:             "availabilityTimeComplete false without chunks",
+			mpd:              "testpic_2s/Manifest.mpd",
+			params:           "atc_0/",
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "ato larger than segment duration",
+			mpd:              "testpic_2s/Manifest.mpd",
+			params:           "ato_3/chunkdur_0.25/",
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "ato larger than segment duration as test mode",
+			mpd:              "testpic_2s/Manifest.mpd",
+			params:           "ato_3/chunkdur_0.25/llbroken_1/",
+			wantedStatusCode: http.StatusOK,
+			wantedInMPD:      []string{`availabilityTimeOffset="3" availabilityTimeComplete="false"`},
+		},
 		{
 			desc:             "period continuity",
 			mpd:              "testpic_2s/Manifest.mpd",
@@ -86,6 +129,102 @@ func TestParamToMPD(t *testing.T) {
 				`<PatchLocation ttl="60">/patch/livesim2/patch_60/testpic_6s/Manifest.mpp?publishTime=`, // PatchLocation
 			},
 		},
+		{
+			desc:             "MPD stalled at cycle start",
+			mpd:              "testpic_2s/Manifest.mpd?nowMS=135000",
+			params:           "segtimeline_1/mpdstall_60_20/",
+			wantedStatusCode: http.StatusOK,
+			wantedInMPD:      []string{`publishTime="1970-01-01T00:02:00Z"`},
+		},
+		{
+			desc:             "MPD not stalled after stall duration",
+			mpd:              "testpic_2s/Manifest.mpd?nowMS=145000",
+			params:           "segtimeline_1/mpdstall_60_20/",
+			wantedStatusCode: http.StatusOK,
+			wantedInMPD:      []string{`publishTime="1970-01-01T00:02:24Z"`},
+		},
+		{
+			desc:             "publishTime every 3 segments",
+			mpd:              "testpic_2s/Manifest.mpd?nowMS=100000",
+			params:           "segtimeline_1/pubtime_3/",
+			wantedStatusCode: http.StatusOK,
+			wantedInMPD:      []string{`publishTime="1970-01-01T00:01:36Z"`},
+		},
+		{
+			desc:             "publishTime never changes",
+			mpd:              "testpic_2s/Manifest.mpd?nowMS=100000",
+			params:           "segtimeline_1/pubtime_never/",
+			wantedStatusCode: http.StatusOK,
+			wantedInMPD:      []string{`publishTime="1970-01-01T00:00:00Z"`},
+		},
+		{
+			desc:             "publishTime every segment with $Number$",
+			mpd:              "testpic_2s/Manifest.mpd?nowMS=101000",
+			params:           "pubtime_seg/",
+			wantedStatusCode: http.StatusOK,
+			wantedInMPD:      []string{`publishTime="1970-01-01T00:01:40Z"`},
+		},
+		{
+			desc:             "clock skew",
+			mpd:              "testpic_2s/Manifest.mpd?nowMS=100000",
+			params:           "segtimeline_1/clockskew_-5.5/",
+			wantedStatusCode: http.StatusOK,
+			wantedInMPD: []string{`availabilityStartTime="1969-12-31T23:59:54.5Z"`,
+				`publishTime="1970-01-01T00:01:34.5Z"`},
+		},
+		{
+			desc:             "SegmentTimeline duplicate time",
+			mpd:              "testpic_2s/Manifest.mpd?nowMS=100000",
+			params:           "segtimeline_1/stlinject_duptime/",
+			wantedStatusCode: http.StatusOK,
+			wantedInMPD: []string{`<S t="6120000" d="180000"></S>
+          <S t="6120000" d="180000"></S>`},
+		},
+		{
+			desc:             "SegmentTimeline injection without timeline",
+			mpd:              "testpic_2s/Manifest.mpd",
+			params:           "stlinject_overlap/",
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "SegmentTimeline injection unknown fault",
+			mpd:              "testpic_2s/Manifest.mpd",
+			params:           "segtimeline_1/stlinject_gap/",
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "MPD inflated with properties",
+			mpd:              "testpic_2s/Manifest.mpd",
+			params:           "mpdinflate_props_3/",
+			wantedStatusCode: http.StatusOK,
+			wantedInMPD:      []string{`<SupplementalProperty schemeIdUri="urn:livesim2:padding:2024" value="2"></SupplementalProperty>`},
+		},
+		{
+			desc:             "MPD inflated with AdaptationSets",
+			mpd:              "testpic_2s/Manifest.mpd",
+			params:           "mpdinflate_as_2/",
+			wantedStatusCode: http.StatusOK,
+			wantedInMPD: []string{`<EssentialProperty schemeIdUri="urn:livesim2:padding:2024" value="1"></EssentialProperty>`,
+				`id="A48_pad1"`},
+		},
+		{
+			desc:             "MPD inflation too large",
+			mpd:              "testpic_2s/Manifest.mpd",
+			params:           "mpdinflate_as_10001/",
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "bad publishTime cadence",
+			mpd:              "testpic_2s/Manifest.mpd",
+			params:           "pubtime_0/",
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "MPD stall longer than cycle",
+			mpd:              "testpic_2s/Manifest.mpd",
+			params:           "mpdstall_20_20/",
+			wantedStatusCode: http.StatusBadRequest,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -106,17 +245,7 @@ func TestParamToMPD(t *testing.T) {
 
 // TestFetches tests fetching of segments and other content.
 func TestFetches(t *testing.T) {
-	cfg := ServerConfig{
-		VodRoot:   "testdata/assets",
-		TimeoutS:  0,
-		LogFormat: logging.LogDiscard,
-	}
-	err := logging.InitSlog(cfg.LogLevel, cfg.LogFormat)
-	require.NoError(t, err)
-	server, err := SetupServer(context.Background(), &cfg)
-	require.NoError(t, err)
-	ts := httptest.NewServer(server.Router)
-	defer ts.Close()
+	_, ts := newTestServer(t, nil)
 	testCases := []struct {
 		desc              string
 		url               string
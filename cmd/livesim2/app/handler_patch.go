@@ -35,7 +35,8 @@ func (s *Server) patchHandlerFunc(w http.ResponseWriter, r *http.Request) {
 	publishTime := q.Get("publishTime")
 	if publishTime == "" {
 		slog.Warn("publishTime query is required, but not provided in patch request")
-		http.Error(w, "publishTime query is required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, reasonBadQuery, "publishTime query is required")
+		return
 	}
 	old := &rec{}
 	oldQuery := removeQuery(origQuery, "nowMS")
@@ -53,21 +54,21 @@ func (s *Server) patchHandlerFunc(w http.ResponseWriter, r *http.Request) {
 	doc, expiration, err := patch.MPDDiff(old.body, new.body)
 	switch {
 	case errors.Is(err, patch.ErrPatchSamePublishTime):
-		http.Error(w, err.Error(), http.StatusTooEarly)
+		writeProblem(w, r, http.StatusTooEarly, reasonTooEarly, err.Error())
 		return
 	case errors.Is(err, patch.ErrPatchTooLate):
-		http.Error(w, err.Error(), http.StatusGone)
+		writeProblem(w, r, http.StatusGone, reasonGone, err.Error())
 		return
 	case err != nil:
 		slog.Error("MPDDiff", "err", err)
-		http.Error(w, "MPDDiff", http.StatusInternalServerError)
+		writeProblem(w, r, http.StatusInternalServerError, reasonInternal, "MPDDiff")
 		return
 	}
 	doc.Indent(2)
 	b, err := doc.WriteToBytes()
 	if err != nil {
 		slog.Error("WriteToBytes", "err", err)
-		http.Error(w, "WriteToBytes", http.StatusInternalServerError)
+		writeProblem(w, r, http.StatusInternalServerError, reasonInternal, "WriteToBytes")
 		return
 	}
 	w.Header().Set("Content-Type", "application/dash-patch+xml")
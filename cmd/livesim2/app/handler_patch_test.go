@@ -1,12 +1,9 @@
 package app
 
 import (
-	"context"
 	"net/http"
-	"net/http/httptest"
 	"testing"
 
-	"github.com/Dash-Industry-Forum/livesim2/pkg/logging"
 	"github.com/stretchr/testify/require"
 )
 
@@ -28,6 +25,7 @@ var wantedPatchSegTimelineTime = `<?xml version="1.0" encoding="UTF-8"?>
   <add sel="/MPD/Period[@id=&apos;P0&apos;]/AdaptationSet[@id=&apos;2&apos;]/SegmentTemplate/SegmentTimeline" pos="prepend">
     <S t="154086573420000" d="180000" r="30"/>
   </add>
+  <replace sel="/MPD/UTCTiming[@schemeIdUri=&apos;urn:mpeg:dash:utc:http-xsdate:2014&apos;]/@value">https://livesim.example.com/time?nowMS=1712073100001</replace>
 </Patch>
 `
 
@@ -50,21 +48,14 @@ var wantedPatchSegTimelineNumberWithAddAtEnd = `<?xml version="1.0" encoding="UT
   <add sel="/MPD/Period[@id=&apos;P0&apos;]/AdaptationSet[@id=&apos;2&apos;]/SegmentTemplate/SegmentTimeline" pos="prepend">
     <S t="154192755060000" d="180000" r="30"/>
   </add>
+  <replace sel="/MPD/UTCTiming[@schemeIdUri=&apos;urn:mpeg:dash:utc:http-xsdate:2014&apos;]/@value">https://livesim.example.com/time?nowMS=1713252897001</replace>
 </Patch>
 `
 
 func TestPatchHandler(t *testing.T) {
-	cfg := ServerConfig{
-		VodRoot:   "testdata/assets",
-		TimeoutS:  0,
-		LogFormat: logging.LogDiscard,
-	}
-	err := logging.InitSlog(cfg.LogLevel, cfg.LogFormat)
-	require.NoError(t, err)
-	server, err := SetupServer(context.Background(), &cfg)
-	require.NoError(t, err)
-	ts := httptest.NewServer(server.Router)
-	defer ts.Close()
+	_, ts := newTestServer(t, func(cfg *ServerConfig) {
+		cfg.Host = "https://livesim.example.com"
+	})
 	testCases := []struct {
 		desc              string
 		url               string
@@ -77,14 +68,14 @@ func TestPatchHandler(t *testing.T) {
 			desc:              "segTimeline no update yet",
 			url:               "/patch/livesim2/patch_60/segtimeline_1/testpic_2s/Manifest.mpp?publishTime=2024-04-16T07:34:38Z&nowDate=2024-04-16T07:34:39Z",
 			wantedStatusCode:  http.StatusTooEarly,
-			wantedContentType: "text/plain; charset=utf-8",
+			wantedContentType: problemContentType,
 			wantedBody:        "",
 		},
 		{
 			desc:              "segTimeline too late",
 			url:               "/patch/livesim2/patch_60/segtimeline_1/testpic_2s/Manifest.mpp?publishTime=2024-04-16T07:34:38Z&nowDate=2024-04-16T07:44:39Z",
 			wantedStatusCode:  http.StatusGone,
-			wantedContentType: "text/plain; charset=utf-8",
+			wantedContentType: problemContentType,
 			wantedBody:        "",
 		},
 		{
@@ -0,0 +1,34 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// qoeHandlerFunc receives DASH metrics reports for the MPD path following /qoe.
+func (s *Server) qoeHandlerFunc(w http.ResponseWriter, r *http.Request) {
+	if s.qoe == nil {
+		writeProblem(w, r, http.StatusNotFound, reasonNotFound, "QoE reports not enabled (use --qoereports)")
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, qoePathPrefix)
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxQoEReportSize))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, reasonBadValue, "could not read body")
+		return
+	}
+	ip, _ := ipFromRequest(r)
+	s.qoe.add(key, QoEReport{
+		Time:        time.Now(),
+		ClientIP:    ip,
+		ContentType: r.Header.Get("Content-Type"),
+		Report:      string(body),
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,51 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxSANDMessageSize limits the size of a received SAND message.
+const maxSANDMessageSize = 64 * 1024
+
+// sandHandlerFunc is the DANE endpoint receiving SAND status messages and responding with PER messages.
+func (s *Server) sandHandlerFunc(w http.ResponseWriter, r *http.Request) {
+	if s.sand == nil {
+		writeProblem(w, r, http.StatusNotFound, reasonNotFound, "SAND not enabled (use --sand)")
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSANDMessageSize))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, reasonBadValue, "could not read body")
+		return
+	}
+	var sm SANDMessage
+	if err := xml.Unmarshal(body, &sm); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, reasonBadValue, fmt.Sprintf("bad SAND message: %s", err))
+		return
+	}
+	senderID := sm.SenderID
+	if senderID == "" {
+		senderID, err = ipFromRequest(r)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, reasonBadValue, "no senderId or client IP")
+			return
+		}
+	}
+	resp := s.sand.receive(senderID, &sm, time.Now())
+	out, err := xml.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		writeProblem(w, r, http.StatusInternalServerError, reasonInternal, "could not marshal PER message")
+		return
+	}
+	w.Header().Set("Content-Type", sandContentType)
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(out)
+}
@@ -17,13 +17,7 @@ import (
 
 // urlGenHandlerFunc returns page for generating URLs
 func (s *Server) urlGenHandlerFunc(w http.ResponseWriter, r *http.Request) {
-	assets := make([]*asset, 0, len(s.assetMgr.assets))
-	for _, a := range s.assetMgr.assets {
-		assets = append(assets, a)
-	}
-	sort.Slice(assets, func(i, j int) bool {
-		return assets[i].AssetPath < assets[j].AssetPath
-	})
+	assets := s.assetMgr.listAssets()
 	fh := fullHost(s.Cfg.Host, r)
 	playURL, err := createPlayURL(fh, s.Cfg.PlayURL)
 	if err != nil {
@@ -16,7 +16,6 @@ func (s *Server) vodHandlerFunc(w http.ResponseWriter, r *http.Request) {
 	rctx := chi.RouteContext(r.Context())
 	rp := rctx.RoutePattern()
 	pathPrefix := strings.TrimSuffix(rp, "/*")
-	vodRoot := s.Cfg.VodRoot
-	fs := http.StripPrefix(pathPrefix, http.FileServer(http.Dir(vodRoot)))
+	fs := http.StripPrefix(pathPrefix, http.FileServer(http.FS(s.assetMgr.vodFS)))
 	fs.ServeHTTP(w, r)
 }
@@ -0,0 +1,242 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/Dash-Industry-Forum/livesim2/internal"
+)
+
+// maxHAREntries limits the number of recorded requests per session. The oldest are dropped.
+const maxHAREntries = 10_000
+
+// Headers that are recorded in HAR entries.
+var (
+	harRequestHeaders  = []string{"Accept", "If-Modified-Since", "If-None-Match", "Range", "User-Agent", configHeader}
+	harResponseHeaders = []string{"Cache-Control", "Content-Length", "Content-Range", "Content-Type", "Date", "ETag",
+		"Last-Modified", "Location"}
+)
+
+// HAR is an HTTP Archive 1.2 file with the subset of fields known to livesim2.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+	Comment string     `json:"comment,omitempty"`
+	// LivesimConfig is the session configuration, used when replaying.
+	LivesimConfig json.RawMessage `json:"_livesimConfig,omitempty" doc:"Session configuration overlay"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time" doc:"Total time in ms"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	// WallClockMS is the wall-clock time used by livesim2 to generate the response.
+	WallClockMS int `json:"_wallClockMS,omitempty" doc:"Wall-clock time (ms) used to generate the response"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	Cookies     []HARNameValue `json:"cookies"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []HARNameValue `json:"headers"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	SHA256   string `json:"_sha256,omitempty" doc:"Hex SHA-256 of response body"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARTimings only has wait (server processing) time since livesim2 does not see the network.
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// harRecorder keeps HAR entries for a session.
+type harRecorder struct {
+	mu      sync.Mutex
+	entries []HAREntry
+	dropped int
+}
+
+func newHARRecorder() *harRecorder {
+	return &harRecorder{}
+}
+
+func (hr *harRecorder) add(e HAREntry) {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	if len(hr.entries) >= maxHAREntries {
+		hr.entries = hr.entries[1:]
+		hr.dropped++
+	}
+	hr.entries = append(hr.entries, e)
+}
+
+// har returns the recorded entries and the session configuration as a HAR file.
+func (hr *harRecorder) har(sessionConfig []byte) HAR {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	h := HAR{Log: HARLog{
+		Version:       "1.2",
+		Creator:       HARCreator{Name: "livesim2", Version: internal.GetVersion()},
+		Entries:       make([]HAREntry, len(hr.entries)),
+		LivesimConfig: sessionConfig,
+	}}
+	copy(h.Log.Entries, hr.entries)
+	if hr.dropped > 0 {
+		h.Log.Comment = "oldest entries dropped"
+	}
+	return h
+}
+
+// sessionIDFromPath returns the id of a session_<id> URL parameter.
+func sessionIDFromPath(p string) string {
+	for _, part := range strings.Split(p, "/") {
+		if id, ok := strings.CutPrefix(part, "session_"); ok {
+			return id
+		}
+	}
+	return ""
+}
+
+// removeSessionFromPath removes a session_<id> URL parameter.
+func removeSessionFromPath(p string) string {
+	parts := strings.Split(p, "/")
+	out := parts[:0]
+	for _, part := range parts {
+		if !strings.HasPrefix(part, "session_") {
+			out = append(out, part)
+		}
+	}
+	return strings.Join(out, "/")
+}
+
+// sessionRecorderMiddleware records requests for sessions created with recording enabled.
+func (s *Server) sessionRecorderMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := sessionIDFromPath(r.URL.Path)
+		if id == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		sess, ok := s.sessions.get(id, time.Now())
+		if !ok || sess.recorder == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		clockMS := int(start.UnixMilli())
+		r = r.WithContext(context.WithValue(r.Context(), wallClockKey{}, clockMS))
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		hash := sha256.New()
+		ww.Tee(hash)
+		next.ServeHTTP(ww, r)
+		e := newHAREntry(r, ww, start, time.Since(start))
+		e.WallClockMS = clockMS
+		e.Response.Content.SHA256 = hex.EncodeToString(hash.Sum(nil))
+		sess.recorder.add(e)
+	})
+}
+
+func newHAREntry(r *http.Request, ww middleware.WrapResponseWriter, start time.Time, dur time.Duration) HAREntry {
+	status := ww.Status()
+	if status == 0 {
+		status = http.StatusOK
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	durMS := float64(dur.Microseconds()) / 1000
+	e := HAREntry{
+		StartedDateTime: start,
+		Time:            durMS,
+		Request: HARRequest{
+			Method:      r.Method,
+			URL:         scheme + "://" + r.Host + r.URL.RequestURI(),
+			HTTPVersion: r.Proto,
+			Headers:     harHeaders(r.Header, harRequestHeaders),
+			QueryString: []HARNameValue{},
+			Cookies:     []HARNameValue{},
+			HeadersSize: -1,
+			BodySize:    0,
+		},
+		Response: HARResponse{
+			Status:      status,
+			StatusText:  http.StatusText(status),
+			HTTPVersion: r.Proto,
+			Headers:     harHeaders(ww.Header(), harResponseHeaders),
+			Cookies:     []HARNameValue{},
+			Content:     HARContent{Size: ww.BytesWritten(), MimeType: ww.Header().Get("Content-Type")},
+			RedirectURL: ww.Header().Get("Location"),
+			HeadersSize: -1,
+			BodySize:    ww.BytesWritten(),
+		},
+		Timings: HARTimings{Wait: durMS},
+	}
+	for key, vals := range r.URL.Query() {
+		for _, v := range vals {
+			e.Request.QueryString = append(e.Request.QueryString, HARNameValue{Name: key, Value: v})
+		}
+	}
+	return e
+}
+
+func harHeaders(hdr http.Header, names []string) []HARNameValue {
+	nvs := []HARNameValue{}
+	for _, name := range names {
+		for _, v := range hdr.Values(name) {
+			nvs = append(nvs, HARNameValue{Name: name, Value: v})
+		}
+	}
+	return nvs
+}
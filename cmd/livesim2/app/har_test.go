@@ -0,0 +1,90 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionHAR(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	resp, body := testFullRequest(t, ts, "POST", "/api/sessions",
+		strings.NewReader(`{"config": {"SegTimelineFlag": true}, "record": true}`))
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+	var info SessionInfo
+	require.NoError(t, json.Unmarshal(body, &info))
+	require.True(t, info.Recording)
+
+	resp, _ = testFullRequest(t, ts, "GET", info.URLPrefix+"/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = testFullRequest(t, ts, "GET", info.URLPrefix+"/testpic_2s/V300/init.mp4", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = testFullRequest(t, ts, "GET", info.URLPrefix+"/testpic_2s/V300/notes.txt", nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, body = testFullRequest(t, ts, "GET", "/api/sessions/"+info.ID+"/har", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	require.Contains(t, resp.Header.Get("Content-Disposition"), "session_"+info.ID+".har")
+	var har HAR
+	require.NoError(t, json.Unmarshal(body, &har))
+	require.Equal(t, "1.2", har.Log.Version)
+	require.Len(t, har.Log.Entries, 3)
+	mpdEntry := har.Log.Entries[0]
+	require.Equal(t, "GET", mpdEntry.Request.Method)
+	require.True(t, strings.HasSuffix(mpdEntry.Request.URL, "/testpic_2s/Manifest.mpd?nowMS=100000"))
+	require.Equal(t, []HARNameValue{{Name: "nowMS", Value: "100000"}}, mpdEntry.Request.QueryString)
+	require.Equal(t, http.StatusOK, mpdEntry.Response.Status)
+	require.Equal(t, "application/dash+xml", mpdEntry.Response.Content.MimeType)
+	require.Greater(t, mpdEntry.Response.BodySize, 0)
+	require.Equal(t, http.StatusNotFound, har.Log.Entries[2].Response.Status)
+
+	resp, body = testFullRequest(t, ts, "POST", "/api/sessions", strings.NewReader(`{"config": {}}`))
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.NoError(t, json.Unmarshal(body, &info))
+	resp, _ = testFullRequest(t, ts, "GET", "/api/sessions/"+info.ID+"/har", nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestReplayHAR(t *testing.T) {
+	server, ts := newTestServer(t, nil)
+
+	resp, body := testFullRequest(t, ts, "POST", "/api/sessions",
+		strings.NewReader(`{"config": {"SegTimelineFlag": true, "TimeShiftBufferDepthS": 20}, "record": true}`))
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+	var info SessionInfo
+	require.NoError(t, json.Unmarshal(body, &info))
+	for _, p := range []string{"/testpic_2s/Manifest.mpd", "/testpic_2s/V300/init.mp4", "/testpic_2s/Manifest.mpd?nowMS=100000"} {
+		resp, _ = testFullRequest(t, ts, "GET", info.URLPrefix+p, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	_, body = testFullRequest(t, ts, "GET", "/api/sessions/"+info.ID+"/har", nil)
+	var har HAR
+	require.NoError(t, json.Unmarshal(body, &har))
+	require.Len(t, har.Log.Entries, 3)
+	require.Greater(t, har.Log.Entries[0].WallClockMS, 0)
+
+	// Replay later, with the session configuration sent as header
+	var out strings.Builder
+	nrDiffs, err := replayHAR(server.Router, &har, &out)
+	require.NoError(t, err)
+	require.Equal(t, 0, nrDiffs, out.String())
+
+	har.Log.Entries[0].Response.Content.SHA256 = "00"
+	har.Log.Entries[1].Response.Status = http.StatusNotFound
+	out.Reset()
+	nrDiffs, err = replayHAR(server.Router, &har, &out)
+	require.NoError(t, err)
+	require.Equal(t, 2, nrDiffs, out.String())
+	require.Contains(t, out.String(), "content differs")
+	require.Contains(t, out.String(), "status 404 != 200")
+}
@@ -0,0 +1,122 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+)
+
+// HDR signaling kinds
+const (
+	// hdrPQ is HDR10 with BT.2020 colour primaries and the PQ transfer function
+	hdrPQ = "pq"
+	// hdrHLG is BT.2020 colour primaries and the HLG transfer function
+	hdrHLG = "hlg"
+	// hdrHLGCompat is HLG signaled as backwards compatible with BT.2020 SDR
+	hdrHLGCompat = "hlgcompat"
+)
+
+// CICP schemes for colour signaling in DASH descriptors
+const (
+	cicpColourPrimaries         = "urn:mpeg:mpegB:cicp:ColourPrimaries"
+	cicpTransferCharacteristics = "urn:mpeg:mpegB:cicp:TransferCharacteristics"
+	cicpMatrixCoefficients      = "urn:mpeg:mpegB:cicp:MatrixCoefficients"
+)
+
+// CICP code points
+const (
+	cicpBT2020      = 9  // colour primaries and non-constant luminance matrix coefficients
+	cicpTCBT2020    = 14 // BT.2020 10-bit SDR transfer
+	cicpTCPQ        = 16
+	cicpTCHLG       = 18
+	hdrDefaultDepth = 10
+)
+
+// HDRSignal configures HDR signaling of the video AdaptationSets, independent of the actual video.
+type HDRSignal struct {
+	Kind string `json:"Kind"`
+	// Supplemental puts the descriptors in SupplementalProperty instead of EssentialProperty,
+	// so that players without HDR support may still select the video.
+	Supplemental bool `json:"Supplemental,omitempty"`
+	// Codecs adds the colour information and 10-bit depth to vp09 and av01 codecs strings.
+	Codecs bool `json:"Codecs,omitempty"`
+}
+
+// validate checks the kind.
+func (h *HDRSignal) validate() error {
+	switch h.Kind {
+	case hdrPQ, hdrHLG, hdrHLGCompat:
+		return nil
+	}
+	return fmt.Errorf("hdr unknown kind %q, allowed: %s, %s, %s", h.Kind, hdrPQ, hdrHLG, hdrHLGCompat)
+}
+
+// transfer returns the signaled transfer characteristics, and a backwards-compatible one if any.
+func (h *HDRSignal) transfer() (tc, compatTC int) {
+	switch h.Kind {
+	case hdrPQ:
+		return cicpTCPQ, 0
+	case hdrHLG:
+		return cicpTCHLG, 0
+	default: // hdrHLGCompat
+		return cicpTCBT2020, cicpTCHLG
+	}
+}
+
+// addHDRSignaling adds CICP descriptors to all video AdaptationSets.
+// For hlgcompat, the primary transfer characteristics is BT.2020 SDR and HLG is given in a
+// SupplementalProperty, so that HLG-capable players can use it.
+func addHDRSignaling(mpd *m.MPD, h *HDRSignal) {
+	tc, compatTC := h.transfer()
+	for _, p := range mpd.Periods {
+		for _, as := range p.AdaptationSets {
+			if as.ContentType != "video" {
+				continue
+			}
+			descs := []*m.DescriptorType{
+				m.NewDescriptor(cicpColourPrimaries, fmt.Sprintf("%d", cicpBT2020), ""),
+				m.NewDescriptor(cicpTransferCharacteristics, fmt.Sprintf("%d", tc), ""),
+				m.NewDescriptor(cicpMatrixCoefficients, fmt.Sprintf("%d", cicpBT2020), ""),
+			}
+			if h.Supplemental {
+				as.SupplementalProperties = append(as.SupplementalProperties, descs...)
+			} else {
+				as.EssentialProperties = append(as.EssentialProperties, descs...)
+			}
+			if compatTC != 0 {
+				as.SupplementalProperties = append(as.SupplementalProperties,
+					m.NewDescriptor(cicpTransferCharacteristics, fmt.Sprintf("%d", compatTC), ""))
+			}
+			if h.Codecs {
+				as.Codecs = hdrCodecs(as.Codecs, tc)
+				for _, rep := range as.Representations {
+					rep.Codecs = hdrCodecs(rep.Codecs, tc)
+				}
+			}
+		}
+	}
+}
+
+// hdrCodecs returns the codecs string with 10-bit depth and BT.2020 colour information
+// for vp09 and av01. Other codecs strings have no colour information and are returned unchanged.
+func hdrCodecs(codecs string, tc int) string {
+	parts := strings.Split(codecs, ".")
+	if len(parts) < 4 {
+		return codecs
+	}
+	switch parts[0] {
+	case "vp09": // vp09.PP.LL.DD.CC.cp.tc.mc.FF
+		return fmt.Sprintf("vp09.%s.%s.%02d.01.%02d.%02d.%02d.00", parts[1], parts[2], hdrDefaultDepth,
+			cicpBT2020, tc, cicpBT2020)
+	case "av01": // av01.P.LLT.DD.M.CCC.cp.tc.mc.F
+		return fmt.Sprintf("av01.%s.%s.%02d.0.110.%02d.%02d.%02d.0", parts[1], parts[2], hdrDefaultDepth,
+			cicpBT2020, tc, cicpBT2020)
+	default:
+		return codecs
+	}
+}
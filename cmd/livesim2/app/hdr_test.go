@@ -0,0 +1,76 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"net/http"
+	"testing"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHDRSignaling(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	type desc struct{ scheme, value string }
+	toDescs := func(props []*m.DescriptorType) []desc {
+		var ds []desc
+		for _, p := range props {
+			ds = append(ds, desc{string(p.SchemeIdUri), p.Value})
+		}
+		return ds
+	}
+	cicp := func(tc string) []desc {
+		return []desc{{cicpColourPrimaries, "9"}, {cicpTransferCharacteristics, tc}, {cicpMatrixCoefficients, "9"}}
+	}
+
+	cases := []struct {
+		hdr              string
+		wantedEssential  []desc
+		wantedSuppl      []desc
+		wantedStatusCode int
+	}{
+		{hdr: "pq", wantedEssential: cicp("16"), wantedStatusCode: http.StatusOK},
+		{hdr: "hlg_supplemental", wantedSuppl: cicp("18"), wantedStatusCode: http.StatusOK},
+		{hdr: "hlgcompat", wantedEssential: cicp("14"),
+			wantedSuppl: []desc{{cicpTransferCharacteristics, "18"}}, wantedStatusCode: http.StatusOK},
+		{hdr: "dolby", wantedStatusCode: http.StatusBadRequest},
+		{hdr: "pq_extra", wantedStatusCode: http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		resp, body := testFullRequest(t, ts, "GET", "/livesim2/hdr_"+c.hdr+"/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+		require.Equal(t, c.wantedStatusCode, resp.StatusCode, c.hdr)
+		if c.wantedStatusCode != http.StatusOK {
+			continue
+		}
+		mpd, err := m.ReadFromString(string(body))
+		require.NoError(t, err)
+		for _, as := range mpd.Periods[0].AdaptationSets {
+			if as.ContentType != "video" {
+				require.Len(t, as.EssentialProperties, 0, c.hdr)
+				continue
+			}
+			require.Equal(t, c.wantedEssential, toDescs(as.EssentialProperties), c.hdr)
+			require.Equal(t, c.wantedSuppl, toDescs(as.SupplementalProperties), c.hdr)
+		}
+	}
+}
+
+func TestHDRCodecs(t *testing.T) {
+	cases := []struct {
+		codecs string
+		tc     int
+		wanted string
+	}{
+		{"avc1.64001e", cicpTCPQ, "avc1.64001e"},
+		{"vp09.00.10.08", cicpTCPQ, "vp09.00.10.10.01.09.16.09.00"},
+		{"vp09.02.10.10.01.09.16.09.01", cicpTCHLG, "vp09.02.10.10.01.09.18.09.00"},
+		{"av01.0.04M.08", cicpTCHLG, "av01.0.04M.10.0.110.09.18.09.0"},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.wanted, hdrCodecs(c.codecs, c.tc))
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Dash-Industry-Forum/livesim2/pkg/logging"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestServer sets up a server with the test assets and an HTTP test server for it,
+// which is closed when the test ends. mod, if not nil, changes the configuration before setup.
+func newTestServer(t *testing.T, mod func(cfg *ServerConfig)) (*Server, *httptest.Server) {
+	t.Helper()
+	cfg := ServerConfig{
+		VodRoot:   "testdata/assets",
+		TimeoutS:  0,
+		LogFormat: logging.LogDiscard,
+	}
+	if mod != nil {
+		mod(&cfg)
+	}
+	err := logging.InitSlog(cfg.LogLevel, cfg.LogFormat)
+	require.NoError(t, err)
+	server, err := SetupServer(context.Background(), &cfg)
+	require.NoError(t, err)
+	ts := httptest.NewServer(server.Router)
+	t.Cleanup(ts.Close)
+	return server, ts
+}
@@ -0,0 +1,165 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"plugin"
+	"strings"
+	"sync"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+)
+
+// pluginHooksSymbol is the symbol that a Go plugin must export as a func() []app.Hook.
+const pluginHooksSymbol = "LivesimHooks"
+
+// Hook adds custom behavior to request handling without forking the simulator.
+// Hooks are registered with WithHook or AddHook in library mode, or loaded from Go plugins.
+// All callbacks are optional and are called in registration order.
+type Hook struct {
+	Name string
+	// OnRequest is called before routing and may change the request and set response headers.
+	// Returning false vetoes the request, and OnRequest must then have written the response.
+	OnRequest func(w http.ResponseWriter, r *http.Request) bool
+	// OnResponse is called before the response header is sent, and may change the headers.
+	// Returning a non-zero status vetoes the response, which is replaced by a problem response with that status.
+	OnResponse func(r *http.Request, status int, header http.Header) (vetoStatus int)
+	// OnMPD is called with every generated live MPD before it is serialized, and may rewrite it.
+	// An error results in a 500 response.
+	OnMPD func(r *http.Request, mpd *m.MPD) error
+}
+
+// hookRegistry holds the registered hooks. Hooks can be added while the server is running.
+type hookRegistry struct {
+	mu    sync.RWMutex
+	hooks []Hook
+}
+
+func (hr *hookRegistry) add(h Hook) {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	hr.hooks = append(hr.hooks, h)
+}
+
+func (hr *hookRegistry) list() []Hook {
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+	return hr.hooks[:len(hr.hooks):len(hr.hooks)]
+}
+
+// middleware runs the OnRequest hooks and wraps the response writer for the OnResponse hooks.
+func (hr *hookRegistry) middleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		hooks := hr.list()
+		if len(hooks) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		for _, h := range hooks {
+			if h.OnRequest != nil && !h.OnRequest(w, r) {
+				return
+			}
+		}
+		next.ServeHTTP(&hookWriter{ResponseWriter: w, r: r, hooks: hooks}, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// rewriteMPD runs the OnMPD hooks.
+func (hr *hookRegistry) rewriteMPD(r *http.Request, mpd *m.MPD) error {
+	for _, h := range hr.list() {
+		if h.OnMPD == nil {
+			continue
+		}
+		if err := h.OnMPD(r, mpd); err != nil {
+			return fmt.Errorf("hook %q: %w", h.Name, err)
+		}
+	}
+	return nil
+}
+
+// hookWriter calls the OnResponse hooks when the response header is written.
+// After a veto, everything written by the handler is dropped.
+type hookWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	hooks       []Hook
+	wroteHeader bool
+	vetoed      bool
+}
+
+func (hw *hookWriter) WriteHeader(status int) {
+	if hw.wroteHeader {
+		return
+	}
+	hw.wroteHeader = true
+	for _, h := range hw.hooks {
+		if h.OnResponse == nil {
+			continue
+		}
+		if veto := h.OnResponse(hw.r, status, hw.Header()); veto != 0 {
+			hw.vetoed = true
+			writeProblem(hw.ResponseWriter, hw.r, veto, reasonHookVeto, fmt.Sprintf("response vetoed by hook %q", h.Name))
+			return
+		}
+	}
+	hw.ResponseWriter.WriteHeader(status)
+}
+
+func (hw *hookWriter) Write(p []byte) (int, error) {
+	if !hw.wroteHeader {
+		hw.WriteHeader(http.StatusOK)
+	}
+	if hw.vetoed {
+		return len(p), nil
+	}
+	return hw.ResponseWriter.Write(p)
+}
+
+// Flush makes chunked low-latency segments work with hooks.
+func (hw *hookWriter) Flush() {
+	if hw.vetoed {
+		return
+	}
+	if !hw.wroteHeader {
+		hw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := hw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (hw *hookWriter) Unwrap() http.ResponseWriter {
+	return hw.ResponseWriter
+}
+
+// loadPlugins loads hooks from a comma-separated list of Go plugin files.
+// Each plugin must export LivesimHooks as a func() []app.Hook and be built with the same
+// Go version and dependencies as livesim2.
+func loadPlugins(paths string) ([]Hook, error) {
+	var hooks []Hook
+	for _, p := range strings.Split(paths, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		plug, err := plugin.Open(p)
+		if err != nil {
+			return nil, fmt.Errorf("open plugin %s: %w", p, err)
+		}
+		sym, err := plug.Lookup(pluginHooksSymbol)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s: %w", p, err)
+		}
+		hooksFunc, ok := sym.(func() []Hook)
+		if !ok {
+			return nil, fmt.Errorf("plugin %s: %s is %T, not func() []app.Hook", p, pluginHooksSymbol, sym)
+		}
+		hooks = append(hooks, hooksFunc()...)
+	}
+	return hooks, nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHooks(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.TimeoutS = 0
+	server, err := NewServer(context.Background(), WithConfig(&cfg), WithVodFS(os.DirFS("testdata/assets")),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		WithHook(Hook{
+			Name: "blocker",
+			OnRequest: func(w http.ResponseWriter, r *http.Request) bool {
+				if r.Header.Get("X-Block") != "" {
+					http.Error(w, "blocked", http.StatusForbidden)
+					return false
+				}
+				w.Header().Set("X-Hooked", "yes")
+				return true
+			},
+		}))
+	require.NoError(t, err)
+	server.AddHook(Hook{
+		Name: "rewriter",
+		OnResponse: func(r *http.Request, status int, header http.Header) int {
+			if strings.HasSuffix(r.URL.Path, ".m4s") {
+				return http.StatusTeapot
+			}
+			return 0
+		},
+		OnMPD: func(r *http.Request, mpd *m.MPD) error {
+			mpd.ProgramInformation = []*m.ProgramInformationType{{Title: "hooked"}}
+			return nil
+		},
+	})
+	ts := httptest.NewServer(server.Router)
+	defer ts.Close()
+
+	mpdPath := "/livesim2/testpic_2s/Manifest.mpd"
+	resp, body := testFullRequest(t, ts, "GET", mpdPath, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "yes", resp.Header.Get("X-Hooked"))
+	require.Contains(t, string(body), "<Title>hooked</Title>")
+
+	resp, body = testFullRequest(t, ts, "GET", "/livesim2/testpic_2s/V300/init.mp4", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Greater(t, len(body), 0)
+
+	resp, body = testFullRequest(t, ts, "GET", "/vod/testpic_2s/V300/1.m4s", nil)
+	require.Equal(t, http.StatusTeapot, resp.StatusCode)
+	require.Contains(t, string(body), reasonHookVeto)
+
+	req, err := http.NewRequest("GET", ts.URL+mpdPath, nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Block", "1")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestLoadPlugins(t *testing.T) {
+	hooks, err := loadPlugins(" , ")
+	require.NoError(t, err)
+	require.Len(t, hooks, 0)
+	_, err = loadPlugins("testdata/nonexistent.so")
+	require.Error(t, err)
+}
@@ -0,0 +1,74 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"time"
+
+	"github.com/Eyevinn/mp4ff/mp4"
+)
+
+// id3SchemeIdUri is the AOM scheme for ID3 timed metadata in emsg boxes.
+// The message data is a complete ID3v2 tag.
+const id3SchemeIdUri = "https://aomedia.org/emsg/ID3"
+
+// id3TimeDescription is the TXXX description of the wall-clock time frame.
+const id3TimeDescription = "livesim2-time"
+
+// id3Emsgs returns emsg boxes with ID3 tags at every multiple of intervalS seconds of wall-clock time
+// in the segment [segStart, segEnd). Each tag has a TXXX frame with the wall-clock time in RFC 3339 format.
+// The times are in timescale units with startTimeS as offset to wall-clock time.
+func id3Emsgs(intervalS int, segStart, segEnd, timescale uint64, startTimeS int) []*mp4.EmsgBox {
+	offset := uint64(startTimeS) * timescale
+	interval := uint64(intervalS) * timescale
+	var emsgs []*mp4.EmsgBox
+	for t := (segStart + offset + interval - 1) / interval * interval; t < segEnd+offset; t += interval {
+		wallMS := int64(t * 1000 / timescale)
+		timeStr := time.UnixMilli(wallMS).UTC().Format(time.RFC3339Nano)
+		emsgs = append(emsgs, &mp4.EmsgBox{
+			Version:          1,
+			TimeScale:        uint32(timescale),
+			PresentationTime: t - offset,
+			ID:               uint32(t / interval),
+			SchemeIDURI:      id3SchemeIdUri,
+			MessageData:      id3Tag(id3TXXXFrame(id3TimeDescription, timeStr)),
+		})
+	}
+	return emsgs
+}
+
+// id3Tag returns an ID3v2.4 tag with the given frames.
+func id3Tag(frames ...[]byte) []byte {
+	size := 0
+	for _, f := range frames {
+		size += len(f)
+	}
+	tag := make([]byte, 0, 10+size)
+	tag = append(tag, 'I', 'D', '3', 0x04, 0x00, 0x00) // version 2.4.0, no flags
+	tag = appendSynchsafe(tag, uint32(size))
+	for _, f := range frames {
+		tag = append(tag, f...)
+	}
+	return tag
+}
+
+// id3TXXXFrame returns a user-defined text information frame with UTF-8 encoding.
+func id3TXXXFrame(description, value string) []byte {
+	payload := make([]byte, 0, 2+len(description)+len(value))
+	payload = append(payload, 0x03) // UTF-8
+	payload = append(payload, description...)
+	payload = append(payload, 0x00)
+	payload = append(payload, value...)
+	frame := make([]byte, 0, 10+len(payload))
+	frame = append(frame, 'T', 'X', 'X', 'X')
+	frame = appendSynchsafe(frame, uint32(len(payload)))
+	frame = append(frame, 0x00, 0x00) // no flags
+	return append(frame, payload...)
+}
+
+// appendSynchsafe appends a 28-bit size as a 4-byte synchsafe integer.
+func appendSynchsafe(b []byte, size uint32) []byte {
+	return append(b, byte(size>>21)&0x7f, byte(size>>14)&0x7f, byte(size>>7)&0x7f, byte(size)&0x7f)
+}
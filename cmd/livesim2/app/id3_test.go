@@ -0,0 +1,58 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Eyevinn/mp4ff/mp4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestID3Tag(t *testing.T) {
+	tag := id3Tag(id3TXXXFrame("d", "v"))
+	require.Equal(t, []byte{
+		'I', 'D', '3', 4, 0, 0, 0, 0, 0, 14,
+		'T', 'X', 'X', 'X', 0, 0, 0, 4, 0, 0,
+		3, 'd', 0, 'v'}, tag)
+	require.Equal(t, []byte{0, 0, 0x02, 0x01}, appendSynchsafe(nil, 257))
+}
+
+func TestID3Emsg(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	resp, body := testFullRequest(t, ts, "GET", "/livesim2/id3_4/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), `<InbandEventStream schemeIdUri="https://aomedia.org/emsg/ID3"`)
+
+	for _, tc := range []struct {
+		nr      int
+		nrEmsgs int
+	}{
+		{nr: 30, nrEmsgs: 1},
+		{nr: 31, nrEmsgs: 0},
+	} {
+		url := fmt.Sprintf("/livesim2/id3_4/testpic_2s/V300/%d.m4s?nowMS=100000", tc.nr)
+		resp, body = testFullRequest(t, ts, "GET", url, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		f, err := mp4.DecodeFile(bytes.NewBuffer(body))
+		require.NoError(t, err)
+		emsgs := f.Segments[0].Fragments[0].Emsgs
+		require.Len(t, emsgs, tc.nrEmsgs)
+		if tc.nrEmsgs == 0 {
+			continue
+		}
+		e := emsgs[0]
+		require.Equal(t, id3SchemeIdUri, e.SchemeIDURI)
+		require.Equal(t, 60*uint64(e.TimeScale), e.PresentationTime)
+		require.Equal(t, id3Tag(id3TXXXFrame(id3TimeDescription, "1970-01-01T00:01:00Z")), e.MessageData)
+	}
+
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/id3_0/testpic_2s/Manifest.mpd", nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,255 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+)
+
+// InspectReport explains what livesim2 would serve for a URL at a given time.
+type InspectReport struct {
+	URL          string              `json:"url" doc:"Inspected URL (path and query)"`
+	NowMS        int                 `json:"nowMS" doc:"Wall-clock time (ms) used for the inspection, including any timeoffset"`
+	Kind         string              `json:"kind" doc:"Kind of request: mpd, init, segment, or unknown"`
+	Status       int                 `json:"status" doc:"HTTP status code that would be returned"`
+	Reason       string              `json:"reason,omitempty" doc:"Explanation if status is not 200"`
+	ReasonCode   string              `json:"reasonCode,omitempty" doc:"Reason code as in problem+json error responses"`
+	Config       *ResponseConfig     `json:"config,omitempty" doc:"Resolved response configuration"`
+	AssetPath    string              `json:"assetPath,omitempty" doc:"Path of the matched asset"`
+	ContentPart  string              `json:"contentPart,omitempty" doc:"Part of URL after the configuration parameters"`
+	Availability *InspectWindow      `json:"availability,omitempty" doc:"Availability window for the reference representation"`
+	Segment      *InspectSegmentInfo `json:"segment,omitempty" doc:"Mapping of the requested segment to VoD segment"`
+}
+
+// InspectWindow is the availability window of segments at the inspected time.
+type InspectWindow struct {
+	AvailabilityStartTimeS int `json:"availabilityStartTimeS"`
+	TimeShiftBufferDepthS  int `json:"timeShiftBufferDepthS"`
+	WindowStartMS          int `json:"windowStartMS"`
+	WindowEndMS            int `json:"windowEndMS"`
+	LoopDurMS              int `json:"loopDurMS"`
+	FirstSegNr             int `json:"firstSegNr"`
+	LastSegNr              int `json:"lastSegNr"`
+	LastSegTime            int `json:"lastSegTime"`
+	Timescale              int `json:"timescale"`
+}
+
+// InspectSegmentInfo describes how a live segment maps to the VoD asset.
+type InspectSegmentInfo struct {
+	RepID              string `json:"repID"`
+	RefRepID           string `json:"refRepID,omitempty"`
+	RequestedID        int    `json:"requestedID" doc:"Number or time extracted from URL"`
+	Nr                 int    `json:"nr"`
+	Time               int    `json:"time"`
+	Dur                int    `json:"dur"`
+	Timescale          int    `json:"timescale"`
+	VodNr              int    `json:"vodNr"`
+	VodTime            int    `json:"vodTime"`
+	AvailabilityTimeMS int    `json:"availabilityTimeMS"`
+}
+
+// inspectURL generates an InspectReport for rawURL at nowMS.
+// A negative nowMS means that the current time is used, unless nowMS or nowDate are in the URL.
+func (s *Server) inspectURL(rawURL string, nowMS int) (*InspectReport, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse url: %w", err)
+	}
+	if u.Path == "" || !strings.HasPrefix(u.Path, "/livesim2/") {
+		return nil, fmt.Errorf("url path must start with /livesim2/")
+	}
+	q := u.Query()
+	if nowMS >= 0 {
+		q.Set("nowMS", strconv.Itoa(nowMS))
+	}
+	u.RawQuery = q.Encode()
+	rep := InspectReport{URL: u.RequestURI(), Kind: "unknown"}
+
+	log := s.logger.With("inspect", u.Path)
+	req := httptest.NewRequest("GET", u.RequestURI(), nil)
+	reqNowMS, cfg, errHT := cfgFromRequest(req, log, s.liveSessions())
+	if errHT == nil {
+		errHT = s.validateURLParams(log, cfg)
+	}
+	if errHT == nil {
+		_, errHT = s.applyVariant(nil, req, log, cfg, reqNowMS)
+	}
+	if errHT != nil {
+		rep.Status = errHT.statusCode
+		rep.Reason = errHT.msg
+		rep.ReasonCode = errHT.reason
+		return &rep, nil
+	}
+	rep.NowMS = reqNowMS
+	rep.Config = cfg
+	rep.ContentPart = cfg.URLContentPart()
+	a, ok := s.assetMgr.findAsset(rep.ContentPart)
+	if !ok {
+		rep.Status = http.StatusNotFound
+		rep.Reason = fmt.Sprintf("unknown asset %q", rep.ContentPart)
+		rep.ReasonCode = reasonNotFound
+		return &rep, nil
+	}
+	a, err = configuredAsset(s.assetMgr.vodFS, a, cfg)
+	if err != nil {
+		rep.Status = http.StatusBadRequest
+		rep.Reason = err.Error()
+		rep.ReasonCode = reasonBadCombination
+		return &rep, nil
+	}
+	rep.AssetPath = a.AssetPath
+	cfg.HostAliases = s.hostAliases
+	rep.Availability = inspectWindow(a, cfg, reqNowMS)
+
+	switch filepath.Ext(u.Path) {
+	case ".mpd":
+		rep.Kind = "mpd"
+		_, mpdName := path.Split(rep.ContentPart)
+		if _, err := LiveMPD(a, mpdName, cfg, s.Cfg.DrmCfg, reqNowMS); err != nil {
+			rep.Status = mpdErrorStatus(err)
+			rep.Reason = err.Error()
+			rep.ReasonCode = reasonFromError(err, reasonInternal)
+			return &rep, nil
+		}
+		rep.Status = http.StatusOK
+	case ".mp4", ".m4s", ".cmfv", ".cmfa", ".cmft", ".jpg", ".jpeg", ".m4v", ".m4a":
+		segmentPart := strings.TrimPrefix(rep.ContentPart, a.AssetPath)
+		if len(cfg.Traffic) > 0 {
+			var patternNr int
+			patternNr, segmentPart = extractPattern(segmentPart)
+			if patternNr >= 0 && cfg.Traffic[patternNr].StateAt(reqNowMS/1000) == loss404 {
+				rep.Kind = "segment"
+				rep.Status = http.StatusNotFound
+				rep.Reason = fmt.Sprintf("traffic pattern %d is down at this time", patternNr)
+				rep.ReasonCode = reasonTrafficLoss
+				return &rep, nil
+			}
+		}
+		segmentPart = strings.TrimPrefix(segmentPart, "/")
+		inspectSegment(&rep, a, cfg, segmentPart, reqNowMS)
+	default:
+		rep.Status = http.StatusNotFound
+		rep.Reason = "unknown file extension"
+		rep.ReasonCode = reasonUnknownExtension
+	}
+	return &rep, nil
+}
+
+// inspectWindow returns the window of available segments for the reference representation.
+func inspectWindow(a *asset, cfg *ResponseConfig, nowMS int) *InspectWindow {
+	tsbdS := *cfg.TimeShiftBufferDepthS
+	wTimes := calcWrapTimes(a, cfg, nowMS, m.Duration(tsbdS)*m.Duration(1_000_000_000))
+	atoMS := int(1000 * cfg.getAvailabilityTimeOffsetS())
+	if cfg.liveMPDType() != segmentNumber && atoMS < 0 {
+		atoMS = 0
+	}
+	se := a.generateTimelineEntries(a.refRep.ID, wTimes, atoMS)
+	w := InspectWindow{
+		AvailabilityStartTimeS: cfg.StartTimeS,
+		TimeShiftBufferDepthS:  tsbdS,
+		WindowStartMS:          wTimes.startTimeMS,
+		WindowEndMS:            wTimes.nowMS,
+		LoopDurMS:              a.LoopDurMS,
+		FirstSegNr:             -1,
+		LastSegNr:              -1,
+		Timescale:              int(se.mediaTimescale),
+	}
+	if se.startNr >= 0 {
+		w.FirstSegNr = se.startNr + cfg.getStartNr()
+		w.LastSegNr = se.lastNr() + cfg.getStartNr()
+		w.LastSegTime = int(se.lastTime())
+	}
+	return &w
+}
+
+// inspectSegment fills in segment mapping and status for segmentPart.
+func inspectSegment(rep *InspectReport, a *asset, cfg *ResponseConfig, segmentPart string, nowMS int) {
+	rep.Kind = "segment"
+	if _, ok := isTimeSubsInitSegment(SUBS_STPP_PREFIX, segmentPart); ok {
+		rep.Kind = "init"
+	}
+	if _, ok := isTimeSubsInitSegment(SUBS_WVTT_PREFIX, segmentPart); ok {
+		rep.Kind = "init"
+	}
+	for _, rp := range a.Reps {
+		if segmentPart == rp.InitURI {
+			rep.Kind = "init"
+		}
+	}
+	if rep.Kind == "init" {
+		rep.Status = http.StatusOK
+		return
+	}
+	rp, segID, err := findRepAndSegmentID(a, segmentPart)
+	if err != nil {
+		rep.Status = http.StatusNotFound
+		rep.Reason = fmt.Sprintf("no representation matches %q", segmentPart)
+		rep.ReasonCode = reasonNotFound
+		return
+	}
+	sm, err := findSegMeta(a, cfg, segmentPart, nowMS)
+	if err != nil {
+		rep.Status, rep.Reason = inspectSegError(err)
+		rep.ReasonCode = reasonFromError(err, reasonNotFound)
+		return
+	}
+	si := InspectSegmentInfo{
+		RepID:       rp.ID,
+		RequestedID: segID,
+		Nr:          int(sm.newNr),
+		Time:        int(sm.newTime),
+		Dur:         int(sm.newDur),
+		Timescale:   int(sm.timescale),
+		VodNr:       int(sm.origNr),
+		VodTime:     int(sm.origTime),
+	}
+	if sm.rep != nil && sm.rep.ID != rp.ID {
+		si.RefRepID = sm.rep.ID
+	}
+	availS := float64(int(sm.newTime+uint64(sm.newDur)))/float64(sm.timescale) + float64(cfg.StartTimeS)
+	si.AvailabilityTimeMS = int(1000 * (availS - cfg.getAvailabilityTimeOffsetS()))
+	rep.Segment = &si
+	if len(cfg.SegStatusCodes) > 0 {
+		code, err := calcStatusCode(cfg, a, segmentPart, nowMS)
+		if err != nil {
+			rep.Status = http.StatusInternalServerError
+			rep.Reason = err.Error()
+			rep.ReasonCode = reasonInternal
+			return
+		}
+		if code != 0 {
+			rep.Status = code
+			rep.Reason = fmt.Sprintf("statuscode parameter triggers %d for this segment", code)
+			rep.ReasonCode = reasonTriggeredStatus
+			return
+		}
+	}
+	rep.Status = http.StatusOK
+}
+
+// inspectSegError translates segment lookup errors into status code and explanation.
+func inspectSegError(err error) (int, string) {
+	var tooEarly errTooEarly
+	switch {
+	case errors.Is(err, errNotFound):
+		return http.StatusNotFound, "segment number is before startNumber or does not exist"
+	case errors.As(err, &tooEarly):
+		return http.StatusTooEarly, fmt.Sprintf("segment not yet available: %s", tooEarly.Error())
+	case errors.Is(err, errGone):
+		return http.StatusGone, "segment has left the timeShiftBufferDepth window"
+	default:
+		return http.StatusNotFound, err.Error()
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspect(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	cases := []struct {
+		desc           string
+		url            string
+		nowMS          int
+		expectedCode   int
+		expectedStatus int
+		expectedKind   string
+		expectedNr     int
+		expectedReason string
+	}{
+		{desc: "mpd", url: "/livesim2/testpic_2s/Manifest.mpd", nowMS: 100_000,
+			expectedCode: http.StatusOK, expectedStatus: http.StatusOK, expectedKind: "mpd"},
+		{desc: "init", url: "/livesim2/testpic_2s/V300/init.mp4", nowMS: 100_000,
+			expectedCode: http.StatusOK, expectedStatus: http.StatusOK, expectedKind: "init"},
+		{desc: "available segment", url: "/livesim2/testpic_2s/V300/40.m4s", nowMS: 100_000,
+			expectedCode: http.StatusOK, expectedStatus: http.StatusOK, expectedKind: "segment", expectedNr: 40},
+		{desc: "too early", url: "/livesim2/testpic_2s/V300/100.m4s", nowMS: 180_000,
+			expectedCode: http.StatusOK, expectedStatus: http.StatusTooEarly, expectedKind: "segment",
+			expectedReason: "segment not yet available: too early by 22000ms"},
+		{desc: "gone", url: "/livesim2/testpic_2s/V300/10.m4s", nowMS: 200_000,
+			expectedCode: http.StatusOK, expectedStatus: http.StatusGone, expectedKind: "segment"},
+		{desc: "unknown asset", url: "/livesim2/nothing/Manifest.mpd", nowMS: 100_000,
+			expectedCode: http.StatusOK, expectedStatus: http.StatusNotFound, expectedKind: "unknown"},
+		{desc: "bad parameter", url: "/livesim2/tsbd_a/testpic_2s/Manifest.mpd", nowMS: 100_000,
+			expectedCode: http.StatusOK, expectedStatus: http.StatusBadRequest, expectedKind: "unknown"},
+		{desc: "not livesim2", url: "/vod/testpic_2s/Manifest.mpd", nowMS: 100_000,
+			expectedCode: http.StatusBadRequest},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			q := url.Values{}
+			q.Set("url", c.url)
+			q.Set("nowMS", strconv.Itoa(c.nowMS))
+			resp, err := http.Get(ts.URL + "/api/inspect?" + q.Encode())
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, c.expectedCode, resp.StatusCode)
+			if c.expectedCode != http.StatusOK {
+				return
+			}
+			var rep InspectReport
+			err = json.NewDecoder(resp.Body).Decode(&rep)
+			require.NoError(t, err)
+			require.Equal(t, c.expectedStatus, rep.Status)
+			require.Equal(t, c.expectedKind, rep.Kind)
+			if c.expectedReason != "" {
+				require.Equal(t, c.expectedReason, rep.Reason)
+			}
+			if c.expectedNr > 0 {
+				require.NotNil(t, rep.Segment)
+				require.Equal(t, c.expectedNr, rep.Segment.Nr)
+			}
+		})
+	}
+}
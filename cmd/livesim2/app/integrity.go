@@ -0,0 +1,203 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+)
+
+const (
+	// integrityHeader carries the SHA-256 digest of segments as defined in RFC 9530
+	integrityHeader = "Repr-Digest"
+	// integrityMaxSegments limits the number of segments in an integrity listing
+	integrityMaxSegments = 2000
+)
+
+// reprDigest returns the RFC 9530 Repr-Digest value for a SHA-256 sum.
+func reprDigest(sum []byte) string {
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum) + ":"
+}
+
+// digestWriter computes the SHA-256 digest of a segment response.
+// The body is buffered, so that the digest can be sent as a header in finish.
+// If the segment is flushed chunk by chunk, the buffer is written out and the digest
+// is sent as an HTTP trailer instead.
+type digestWriter struct {
+	http.ResponseWriter
+	h         hash.Hash
+	buf       bytes.Buffer
+	status    int
+	streaming bool
+}
+
+func newDigestWriter(w http.ResponseWriter) *digestWriter {
+	return &digestWriter{ResponseWriter: w, h: sha256.New(), status: http.StatusOK}
+}
+
+func (dw *digestWriter) WriteHeader(status int) {
+	if dw.streaming {
+		dw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	dw.status = status
+}
+
+func (dw *digestWriter) Write(p []byte) (int, error) {
+	dw.h.Write(p)
+	if dw.streaming {
+		return dw.ResponseWriter.Write(p)
+	}
+	return dw.buf.Write(p)
+}
+
+// Flush switches to streaming with the digest in a trailer, and flushes.
+func (dw *digestWriter) Flush() {
+	if !dw.streaming {
+		dw.streaming = true
+		dw.Header().Add("Trailer", integrityHeader)
+		dw.ResponseWriter.WriteHeader(dw.status)
+		if _, err := dw.ResponseWriter.Write(dw.buf.Bytes()); err != nil {
+			return
+		}
+		dw.buf.Reset()
+	}
+	if f, ok := dw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// finish sends the digest, and the buffered response if not streaming.
+func (dw *digestWriter) finish() error {
+	dw.Header().Set(integrityHeader, reprDigest(dw.h.Sum(nil)))
+	if dw.streaming {
+		return nil
+	}
+	dw.ResponseWriter.WriteHeader(dw.status)
+	_, err := dw.ResponseWriter.Write(dw.buf.Bytes())
+	return err
+}
+
+// IntegrityListing lists the SHA-256 digests of an MPD and of all segments in its window.
+type IntegrityListing struct {
+	NowMS     int              `json:"nowMS" doc:"Wall-clock time (ms) of the MPD and segment requests"`
+	MPD       IntegrityEntry   `json:"mpd" doc:"The MPD itself"`
+	Segments  []IntegrityEntry `json:"segments" doc:"Init segments and the media segments in the time-shift window"`
+	Truncated bool             `json:"truncated,omitempty" doc:"True if there were too many segments to list"`
+}
+
+// IntegrityEntry is the status, size and digest of one response.
+type IntegrityEntry struct {
+	URL    string `json:"url" doc:"Path and query of the request"`
+	Status int    `json:"status"`
+	Size   int    `json:"size,omitempty"`
+	SHA256 string `json:"sha256,omitempty" doc:"Hex-encoded SHA-256 of the body if status is 200"`
+}
+
+// integrityListing fetches the MPD mpdURL at nowMS and all segments in its window,
+// and lists their digests. A negative nowMS means now, unless nowMS is in the URL.
+func (s *Server) integrityListing(mpdURL string, nowMS int) (*IntegrityListing, error) {
+	u, err := url.Parse(mpdURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse url: %w", err)
+	}
+	if !strings.HasPrefix(u.Path, "/livesim2/") || filepath.Ext(u.Path) != ".mpd" {
+		return nil, fmt.Errorf("url must be a livesim2 MPD path")
+	}
+	q := u.Query()
+	switch {
+	case nowMS >= 0:
+		q.Set("nowMS", strconv.Itoa(nowMS))
+	case q.Get("nowMS") == "":
+		q.Set("nowMS", strconv.Itoa(int(time.Now().UnixMilli())))
+	}
+	nowMS, err = strconv.Atoi(q.Get("nowMS"))
+	if err != nil {
+		return nil, fmt.Errorf("bad nowMS: %w", err)
+	}
+	query := q.Encode()
+	u.RawQuery = query
+
+	il := IntegrityListing{NowMS: nowMS, Segments: []IntegrityEntry{}}
+	var body []byte
+	il.MPD, body = s.integrityEntry(u.RequestURI())
+	if il.MPD.Status != http.StatusOK {
+		return &il, nil
+	}
+	mpd, err := m.ReadFromString(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("parse MPD: %w", err)
+	}
+	mpdDir := path.Dir(u.Path)
+	for _, p := range mpd.Periods {
+		base := mpdDir
+		if len(p.BaseURLs) > 0 && !strings.Contains(string(p.BaseURLs[0].Value), "://") {
+			base = path.Join(mpdDir, string(p.BaseURLs[0].Value))
+		}
+		for _, as := range p.AdaptationSets {
+			for _, rep := range as.Representations {
+				initURI, mediaURIs, err := repSegmentURIs(mpd, p, rep, integrityWindowSegments(mpd, rep),
+					time.UnixMilli(int64(nowMS)))
+				if err != nil {
+					return nil, err
+				}
+				uris := mediaURIs
+				if initURI != "" {
+					uris = append([]string{initURI}, mediaURIs...)
+				}
+				for _, uri := range uris {
+					if len(il.Segments) == integrityMaxSegments {
+						il.Truncated = true
+						return &il, nil
+					}
+					e, _ := s.integrityEntry(path.Join(base, uri) + "?" + query)
+					il.Segments = append(il.Segments, e)
+				}
+			}
+		}
+	}
+	return &il, nil
+}
+
+// integrityWindowSegments returns the number of segments of rep in the time-shift buffer.
+// SegmentTimeline already describes the window, so all its segments are used.
+func integrityWindowSegments(mpd *m.MPD, rep *m.RepresentationType) int {
+	st := rep.GetSegmentTemplate()
+	if st == nil || st.SegmentTimeline != nil || st.Duration == nil || *st.Duration == 0 ||
+		mpd.TimeShiftBufferDepth == nil {
+		return integrityMaxSegments
+	}
+	tsbdS := time.Duration(*mpd.TimeShiftBufferDepth).Seconds()
+	return int(tsbdS * float64(st.GetTimescale()) / float64(*st.Duration))
+}
+
+// integrityEntry makes an internal request for uri and returns the entry and the body.
+func (s *Server) integrityEntry(uri string) (IntegrityEntry, []byte) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, uri, nil)
+	s.Router.ServeHTTP(rec, req)
+	e := IntegrityEntry{URL: uri, Status: rec.Code}
+	if rec.Code != http.StatusOK {
+		return e, nil
+	}
+	sum := sha256.Sum256(rec.Body.Bytes())
+	e.Size = rec.Body.Len()
+	e.SHA256 = hex.EncodeToString(sum[:])
+	return e, rec.Body.Bytes()
+}
@@ -0,0 +1,62 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntegrity(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	for _, mode := range []string{"", "chunkdur_1/ato_1/"} {
+		segURL := "/livesim2/integrity_1/" + mode + "testpic_2s/V300/50.m4s?nowMS=110000"
+		resp, body := testFullRequest(t, ts, "GET", segURL, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode, mode)
+		sum := sha256.Sum256(body)
+		digest := reprDigest(sum[:])
+		if mode == "" {
+			require.Equal(t, digest, resp.Header.Get(integrityHeader))
+		} else {
+			require.Equal(t, digest, resp.Trailer.Get(integrityHeader))
+		}
+	}
+	resp, _ := testFullRequest(t, ts, "GET", "/livesim2/integrity_1/testpic_2s/V300/init.mp4", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.True(t, strings.HasPrefix(resp.Header.Get(integrityHeader), "sha-256=:"))
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/integrity_1/testpic_2s/V300/100.m4s?nowMS=110000", nil)
+	require.Equal(t, http.StatusTooEarly, resp.StatusCode)
+	require.Equal(t, "", resp.Header.Get(integrityHeader))
+
+	mpdURL := "/livesim2/tsbd_10/testpic_2s/Manifest.mpd"
+	resp, body := testFullRequest(t, ts, "GET", "/api/integrity?nowMS=110000&url="+url.QueryEscape(mpdURL), nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var il IntegrityListing
+	require.NoError(t, json.Unmarshal(body, &il))
+	require.Equal(t, 110000, il.NowMS)
+	require.Equal(t, http.StatusOK, il.MPD.Status)
+	require.Equal(t, mpdURL+"?nowMS=110000", il.MPD.URL)
+	// init and 5 media segments for each of the 2 representations
+	require.Len(t, il.Segments, 12)
+	require.Equal(t, "/livesim2/tsbd_10/testpic_2s/A48/init.mp4?nowMS=110000", il.Segments[0].URL)
+	for _, e := range il.Segments {
+		require.Equal(t, http.StatusOK, e.Status, e.URL)
+		_, body := testFullRequest(t, ts, "GET", e.URL, nil)
+		sum := sha256.Sum256(body)
+		require.Equal(t, hex.EncodeToString(sum[:]), e.SHA256, e.URL)
+	}
+	require.Equal(t, "/livesim2/tsbd_10/testpic_2s/V300/54.m4s?nowMS=110000", il.Segments[11].URL)
+
+	resp, _ = testFullRequest(t, ts, "GET", "/api/integrity?url="+url.QueryEscape("/livesim2/testpic_2s/V300/1.m4s"), nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
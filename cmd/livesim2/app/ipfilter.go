@@ -0,0 +1,129 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type clientIPKey struct{}
+
+// parseCIDRBlocks parses a comma-separated list of CIDR blocks.
+// Plain IP addresses are treated as single-address blocks.
+func parseCIDRBlocks(blocks string) ([]*net.IPNet, error) {
+	if blocks == "" {
+		return nil, nil
+	}
+	parts := strings.Split(blocks, ",")
+	cidrBlocks := make([]*net.IPNet, 0, len(parts))
+	for _, block := range parts {
+		block = strings.TrimSpace(block)
+		if !strings.Contains(block, "/") {
+			if ip := net.ParseIP(block); ip != nil {
+				bits := 8 * len(ip.To16())
+				if ip.To4() != nil {
+					bits = 32
+				}
+				block = fmt.Sprintf("%s/%d", block, bits)
+			}
+		}
+		_, ciBlock, err := net.ParseCIDR(block)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR block %s: %w", block, err)
+		}
+		cidrBlocks = append(cidrBlocks, ciBlock)
+	}
+	return cidrBlocks, nil
+}
+
+func inBlocks(ip net.IP, blocks []*net.IPNet) bool {
+	for _, b := range blocks {
+		if b.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ipFilter resolves the client IP address behind trusted proxies and enforces allow/deny lists.
+type ipFilter struct {
+	trusted []*net.IPNet
+	allow   []*net.IPNet
+	deny    []*net.IPNet
+}
+
+func newIPFilter(trustedProxies, allowBlocks, denyBlocks string) (*ipFilter, error) {
+	var f ipFilter
+	var err error
+	if f.trusted, err = parseCIDRBlocks(trustedProxies); err != nil {
+		return nil, fmt.Errorf("trustedproxies: %w", err)
+	}
+	if f.allow, err = parseCIDRBlocks(allowBlocks); err != nil {
+		return nil, fmt.Errorf("allowblocks: %w", err)
+	}
+	if f.deny, err = parseCIDRBlocks(denyBlocks); err != nil {
+		return nil, fmt.Errorf("denyblocks: %w", err)
+	}
+	return &f, nil
+}
+
+// clientIP returns the client IP. If the peer is a trusted proxy, X-Forwarded-For is walked
+// from the right, and the first address that is not a trusted proxy is the client.
+func (f *ipFilter) clientIP(r *http.Request) (net.IP, error) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("no IP found in %q", r.RemoteAddr)
+	}
+	if !inBlocks(ip, f.trusted) {
+		return ip, nil
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hopIP := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hopIP == nil {
+			break
+		}
+		ip = hopIP
+		if !inBlocks(ip, f.trusted) {
+			break
+		}
+	}
+	return ip, nil
+}
+
+// allowed checks the client IP against the deny and allow lists.
+func (f *ipFilter) allowed(ip net.IP) bool {
+	if inBlocks(ip, f.deny) {
+		return false
+	}
+	return len(f.allow) == 0 || inBlocks(ip, f.allow)
+}
+
+// middleware stores the client IP in the request context and rejects disallowed clients with 403.
+func (f *ipFilter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, err := f.clientIP(r)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, reasonBadQuery, "could not read client IP")
+			return
+		}
+		if !f.allowed(ip) {
+			slog.Warn("client IP not allowed", "ip", ip.String(), "url", r.URL.Path)
+			writeProblem(w, r, http.StatusForbidden, reasonForbidden, "client IP not allowed")
+			return
+		}
+		ctx := context.WithValue(r.Context(), clientIPKey{}, ip.String())
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
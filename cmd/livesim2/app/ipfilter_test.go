@@ -0,0 +1,87 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIP(t *testing.T) {
+	f, err := newIPFilter("10.0.0.0/8,192.168.1.1", "", "")
+	require.NoError(t, err)
+	testCases := []struct {
+		desc       string
+		remoteAddr string
+		xff        string
+		wantedIP   string
+	}{
+		{"no proxy", "1.2.3.4:5678", "", "1.2.3.4"},
+		{"untrusted peer with xff", "1.2.3.4:5678", "5.6.7.8", "1.2.3.4"},
+		{"trusted peer", "10.1.1.1:5678", "5.6.7.8", "5.6.7.8"},
+		{"trusted chain", "10.1.1.1:5678", "5.6.7.8, 192.168.1.1", "5.6.7.8"},
+		{"spoofed first entry", "10.1.1.1:5678", "9.9.9.9, 5.6.7.8, 10.2.2.2", "5.6.7.8"},
+		{"trusted peer without xff", "192.168.1.1:80", "", "192.168.1.1"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tc.remoteAddr
+			if tc.xff != "" {
+				r.Header.Set("X-Forwarded-For", tc.xff)
+			}
+			ip, err := f.clientIP(r)
+			require.NoError(t, err)
+			require.Equal(t, tc.wantedIP, ip.String())
+		})
+	}
+}
+
+func TestIPFilterMiddleware(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		allow      string
+		deny       string
+		remoteAddr string
+		wantedCode int
+	}{
+		{"no lists", "", "", "1.2.3.4:1", http.StatusOK},
+		{"allowed", "1.2.3.0/24", "", "1.2.3.4:1", http.StatusOK},
+		{"not allowed", "1.2.3.0/24", "", "1.2.4.4:1", http.StatusForbidden},
+		{"denied", "", "1.2.3.4", "1.2.3.4:1", http.StatusForbidden},
+		{"deny wins over allow", "1.2.3.0/24", "1.2.3.4", "1.2.3.4:1", http.StatusForbidden},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			f, err := newIPFilter("", tc.allow, tc.deny)
+			require.NoError(t, err)
+			var gotIP string
+			h := f.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotIP, _ = ipFromRequest(r)
+			}))
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tc.remoteAddr
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, r)
+			require.Equal(t, tc.wantedCode, rr.Code)
+			if tc.wantedCode == http.StatusOK {
+				require.Equal(t, "1.2.3.4", gotIP)
+			}
+		})
+	}
+}
+
+func TestParseCIDRBlocks(t *testing.T) {
+	blocks, err := parseCIDRBlocks("10.0.0.0/8, 1.2.3.4,::1")
+	require.NoError(t, err)
+	require.Len(t, blocks, 3)
+	require.Equal(t, "1.2.3.4/32", blocks[1].String())
+	require.Equal(t, "::1/128", blocks[2].String())
+	_, err = parseCIDRBlocks("10.0.0.0/33")
+	require.Error(t, err)
+}
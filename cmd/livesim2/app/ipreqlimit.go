@@ -11,7 +11,6 @@ import (
 	"net"
 	"net/http"
 	"os"
-	"strings"
 	"sync"
 	"time"
 )
@@ -32,17 +31,9 @@ type IPRequestLimiter struct {
 // If logFile is not empty, the IPRequestLimiter is dumped to the logFile at the end of each interval.
 func NewIPRequestLimiter(maxNrRequests int, interval time.Duration, start time.Time,
 	whiteListBlocks string, logFile string) (*IPRequestLimiter, error) {
-	var cidrBlocks []*net.IPNet
-	if whiteListBlocks != "" {
-		blocks := strings.Split(whiteListBlocks, ",")
-		cidrBlocks = make([]*net.IPNet, 0, len(blocks))
-		for _, cidrBlock := range blocks {
-			_, ciBlock, err := net.ParseCIDR(cidrBlock)
-			if err != nil {
-				return nil, fmt.Errorf("invalid CIDR block %s: %w", cidrBlock, err)
-			}
-			cidrBlocks = append(cidrBlocks, ciBlock)
-		}
+	cidrBlocks, err := parseCIDRBlocks(whiteListBlocks)
+	if err != nil {
+		return nil, err
 	}
 
 	return &IPRequestLimiter{
@@ -147,7 +138,12 @@ func (il *IPRequestLimiter) dump() {
 	}
 }
 
+// ipFromRequest returns the client IP set by the IP filter middleware if available.
+// Otherwise, X-Forwarded-For or the peer address is used.
 func ipFromRequest(req *http.Request) (string, error) {
+	if ip, ok := req.Context().Value(clientIPKey{}).(string); ok {
+		return ip, nil
+	}
 	forwardIP := req.Header.Get("X-Forwarded-For")
 	if forwardIP != "" {
 		return forwardIP, nil
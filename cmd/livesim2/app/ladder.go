@@ -0,0 +1,131 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/Eyevinn/mp4ff/mp4"
+)
+
+const (
+	// maxLadderRungs is the maximal number of synthesized rungs
+	maxLadderRungs = 16
+	// maxLadderKbps is the maximal bitrate of a synthesized rung
+	maxLadderKbps = 100_000
+	// maxLadderFillerSize is the maximal number of filler bytes added to a segment
+	maxLadderFillerSize = 64 * 1024 * 1024
+)
+
+// ladderRepIDRegexp matches the suffix of the Representation ids of synthesized rungs.
+var ladderRepIDRegexp = regexp.MustCompile(`_r([0-9]+)k(/|\.|_|$)`)
+
+// Ladder synthesizes bitrate ladder rungs by duplicating the Representation RepID with new ids and bandwidths.
+// The ids have the suffix _r<kbps>k, so the rungs have separate segment URLs with the same content.
+// With Pad, the mdat of the rung segments is padded with filler to reach the signaled bitrate.
+type Ladder struct {
+	RepID string `json:"RepID"`
+	Kbps  []int  `json:"Kbps"`
+	Pad   bool   `json:"Pad,omitempty"`
+}
+
+// rungID returns the Representation id of the rung with bitrate kbps.
+func (l *Ladder) rungID(kbps int) string {
+	return fmt.Sprintf("%s_r%dk", l.RepID, kbps)
+}
+
+// validate checks the number of rungs and that the bitrates are unique and in range.
+func (l *Ladder) validate() error {
+	if l.RepID == "" {
+		return fmt.Errorf("ladder representation id is empty")
+	}
+	if len(l.Kbps) == 0 || len(l.Kbps) > maxLadderRungs {
+		return fmt.Errorf("ladder must have 1 to %d rungs", maxLadderRungs)
+	}
+	for i, kbps := range l.Kbps {
+		if kbps <= 0 || kbps > maxLadderKbps {
+			return fmt.Errorf("ladder bitrate %dkbps not in range 1-%d", kbps, maxLadderKbps)
+		}
+		if slices.Contains(l.Kbps[:i], kbps) {
+			return fmt.Errorf("ladder bitrate %dkbps is not unique", kbps)
+		}
+	}
+	return nil
+}
+
+// verify checks that the duplicated Representation exists in the asset.
+func (l *Ladder) verify(a *asset) error {
+	if _, ok := a.Reps[l.RepID]; !ok {
+		return fmt.Errorf("ladder representation %q not in asset %s", l.RepID, a.AssetPath)
+	}
+	return nil
+}
+
+// applyLadder adds the synthesized rungs after the duplicated Representation in all Periods.
+func applyLadder(mpd *m.MPD, l *Ladder) {
+	for _, p := range mpd.Periods {
+		for _, as := range p.AdaptationSets {
+			var orig *m.RepresentationType
+			for _, rep := range as.Representations {
+				if rep.Id == l.RepID {
+					orig = rep
+				}
+			}
+			if orig == nil {
+				continue
+			}
+			for _, kbps := range l.Kbps {
+				rung := *orig
+				rung.Id = l.rungID(kbps)
+				rung.Bandwidth = uint32(kbps * 1000)
+				as.AppendRepresentation(&rung)
+			}
+		}
+	}
+}
+
+// origSegmentPart maps a segment path of a synthesized rung to the duplicated Representation.
+// The bitrate of the rung is returned as well, or 0 if the path is not for a rung.
+// errNotFound is returned for rungs of the Representation that are not in the ladder.
+func (l *Ladder) origSegmentPart(segmentPart string) (string, int, error) {
+	match := ladderRepIDRegexp.FindStringSubmatchIndex(segmentPart)
+	if match == nil || !strings.HasSuffix(segmentPart[:match[0]], l.RepID) {
+		return segmentPart, 0, nil
+	}
+	kbps, err := strconv.Atoi(segmentPart[match[2]:match[3]])
+	if err != nil || !slices.Contains(l.Kbps, kbps) {
+		return segmentPart, 0, errNotFound
+	}
+	return segmentPart[:match[0]] + segmentPart[match[3]+1:], kbps, nil
+}
+
+// padToBitrate appends filler to the mdat of the last fragment of a segment of segSize bytes,
+// so that it reaches the size of kbps during durS seconds. Segments that are already larger are not changed,
+// and the filler is limited to maxLadderFillerSize.
+// The last sidx reference, if any, is updated to the new size.
+func padToBitrate(frags []*mp4.Fragment, sidx *mp4.SidxBox, segSize uint64, kbps int, durS float64) {
+	if len(frags) == 0 {
+		return
+	}
+	target := uint64(float64(kbps) * 1000 * durS / 8)
+	if target <= segSize {
+		return
+	}
+	filler := min(target-segSize, maxLadderFillerSize)
+	mdat := frags[len(frags)-1].Mdat
+	if len(mdat.DataParts) > 0 {
+		mdat.AddSampleDataPart(make([]byte, filler))
+	} else {
+		mdat.AddSampleData(make([]byte, filler))
+	}
+	if sidx != nil && len(sidx.SidxRefs) > 0 {
+		sidx.SidxRefs[len(sidx.SidxRefs)-1].ReferencedSize += uint32(filler)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"net/http"
+	"testing"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLadder(t *testing.T) {
+	sc := newStringConverter()
+	require.Equal(t, &Ladder{RepID: "V300", Kbps: []int{600, 1200}, Pad: true}, sc.ParseLadder("ladder", "V300_600,1200_pad"))
+	require.Equal(t, &Ladder{RepID: "video_1", Kbps: []int{800}}, sc.ParseLadder("ladder", "video_1_800"))
+	require.NoError(t, sc.err)
+	for _, val := range []string{"V300", "_600", "V300_600,600", "V300_0", "V300_x", "V300_100001"} {
+		sc = newStringConverter()
+		require.Nil(t, sc.ParseLadder("ladder", val))
+		require.Error(t, sc.err, val)
+	}
+}
+
+func TestLadderOrigSegmentPart(t *testing.T) {
+	l := Ladder{RepID: "V300", Kbps: []int{1200}}
+	part, kbps, err := l.origSegmentPart("V300_r1200k/45.m4s")
+	require.NoError(t, err)
+	require.Equal(t, "V300/45.m4s", part)
+	require.Equal(t, 1200, kbps)
+	part, kbps, err = l.origSegmentPart("V300/45.m4s")
+	require.NoError(t, err)
+	require.Equal(t, "V300/45.m4s", part)
+	require.Equal(t, 0, kbps)
+	part, kbps, err = l.origSegmentPart("A48_r7k/45.m4s")
+	require.NoError(t, err)
+	require.Equal(t, "A48_r7k/45.m4s", part)
+	require.Equal(t, 0, kbps)
+	for _, segPart := range []string{"V300_r7k/45.m4s", "V300_r99999999k/45.m4s"} {
+		_, _, err = l.origSegmentPart(segPart)
+		require.ErrorIs(t, err, errNotFound, segPart)
+	}
+}
+
+func TestLadder(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	resp, body := testFullRequest(t, ts, "GET", "/livesim2/ladder_V300_600,1200_pad/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	mpd, err := m.ReadFromString(string(body))
+	require.NoError(t, err)
+	var bandwidths []uint32
+	for _, as := range mpd.Periods[0].AdaptationSets {
+		for _, rep := range as.Representations {
+			if as.ContentType == "video" {
+				bandwidths = append(bandwidths, rep.Bandwidth)
+			}
+		}
+	}
+	require.Equal(t, []uint32{300000, 600000, 1200000}, bandwidths)
+
+	_, orig := testFullRequest(t, ts, "GET", "/livesim2/testpic_2s/V300/45.m4s?nowMS=100000", nil)
+	resp, body = testFullRequest(t, ts, "GET", "/livesim2/ladder_V300_600,1200/testpic_2s/V300_r1200k/45.m4s?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, orig, body)
+	resp, body = testFullRequest(t, ts, "GET", "/livesim2/ladder_V300_600,1200_pad/testpic_2s/V300_r1200k/45.m4s?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, 1200*1000*2/8, len(body))
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/ladder_V300_600,1200_pad/testpic_2s/V300_r1200k/init.mp4", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	for _, rung := range []string{"V300_r7k", "V300_r99999999k"} {
+		resp, _ = testFullRequest(t, ts, "GET", "/livesim2/ladder_V300_600,1200_pad/testpic_2s/"+rung+"/45.m4s?nowMS=100000", nil)
+		require.Equal(t, http.StatusNotFound, resp.StatusCode, rung)
+	}
+
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/ladder_V9_600/testpic_2s/Manifest.mpd", nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,146 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/Eyevinn/mp4ff/mp4"
+)
+
+// largeTfdtOffset returns the media time offset in timescale that makes the media time
+// pass 2^32 rolloverS seconds after availabilityStartTime.
+// If the media time passes 2^32 earlier without offset, the offset is zero.
+func largeTfdtOffset(rolloverS int, timescale uint64) uint64 {
+	if uint64(rolloverS)*timescale >= 1<<32 {
+		return 0
+	}
+	return 1<<32 - uint64(rolloverS)*timescale
+}
+
+// largeTfdtRep returns true if the representation gets its media times offset by the largetfdt parameter.
+func largeTfdtRep(cfg *ResponseConfig, rep *RepData) bool {
+	if cfg.LargeTfdtS == nil {
+		return false
+	}
+	return rep.ContentType == "video" || rep.ContentType == "audio"
+}
+
+// outTimescale returns the timescale of the representation in the generated media segments.
+func outTimescale(cfg *ResponseConfig, rep *RepData) uint64 {
+	if rescaleRep(cfg, rep) {
+		return uint64(*cfg.Timescale)
+	}
+	return uint64(rep.MediaTimescale)
+}
+
+// repLargeTfdtOffset returns the media time offset for rep, or 0 if the largetfdt parameter does not apply.
+func repLargeTfdtOffset(cfg *ResponseConfig, rep *RepData) uint64 {
+	if !largeTfdtRep(cfg, rep) {
+		return 0
+	}
+	return largeTfdtOffset(*cfg.LargeTfdtS, outTimescale(cfg, rep))
+}
+
+// offsetFragTimes adds offset to the decode times of the fragments.
+// Version-1 tfdt boxes are used when needed, and data offsets are adjusted for the size change.
+// The presentation times of version-1 emsg boxes are moved by the same amount.
+func offsetFragTimes(frags []*mp4.Fragment, offset, timescale uint64) {
+	if offset == 0 {
+		return
+	}
+	for _, frag := range frags {
+		traf := frag.Moof.Traf
+		tfdt := traf.Tfdt
+		oldTfdtSize := tfdt.Size()
+		tfdt.SetBaseMediaDecodeTime(tfdt.BaseMediaDecodeTime() + offset)
+		tfdtSizeDiff := int32(tfdt.Size()) - int32(oldTfdtSize)
+		if tfdtSizeDiff != 0 {
+			traf.Trun.DataOffset += tfdtSizeDiff
+			frag.Mdat.StartPos += uint64(tfdtSizeDiff)
+			if traf.Saio != nil && saioAfterTfdt(traf) {
+				for i := range traf.Saio.Offset {
+					traf.Saio.Offset[i] += int64(tfdtSizeDiff)
+				}
+			}
+		}
+		for _, emsg := range frag.Emsgs {
+			if emsg.Version == 1 {
+				emsg.PresentationTime += rescaleTime(offset, timescale, uint64(emsg.TimeScale))
+			}
+		}
+		if frag.Prft != nil {
+			frag.Prft.MediaTime += offset
+		}
+	}
+}
+
+// offsetSegmentTimes adds offset to the decode times of the fragments and
+// to the earliest presentation time of any sidx box.
+func offsetSegmentTimes(seg *mp4.MediaSegment, offset, timescale uint64) {
+	if offset == 0 {
+		return
+	}
+	offsetFragTimes(seg.Fragments, offset, timescale)
+	for _, sidx := range seg.Sidxs {
+		sidx.EarliestPresentationTime += offset
+	}
+}
+
+// applyLargeTfdt sets presentationTimeOffset and moves SegmentTimeline times of
+// audio and video SegmentTemplates to match the media time offset of the largetfdt parameter.
+// SegmentTemplates get the media timescale, so that the offset is exact.
+func applyLargeTfdt(mpd *m.MPD, a *asset, cfg *ResponseConfig) {
+	for _, p := range mpd.Periods {
+		for _, as := range p.AdaptationSets {
+			if len(as.Representations) == 0 {
+				continue
+			}
+			if as.SegmentTemplate != nil {
+				rep, ok := a.Reps[as.Representations[0].Id]
+				if ok && largeTfdtRep(cfg, rep) {
+					offsetSegmentTemplate(as.SegmentTemplate, *cfg.LargeTfdtS, outTimescale(cfg, rep))
+				}
+			}
+			for _, mRep := range as.Representations {
+				rep, ok := a.Reps[mRep.Id]
+				if ok && mRep.SegmentTemplate != nil && largeTfdtRep(cfg, rep) {
+					offsetSegmentTemplate(mRep.SegmentTemplate, *cfg.LargeTfdtS, outTimescale(cfg, rep))
+				}
+			}
+		}
+	}
+}
+
+func offsetSegmentTemplate(st *m.SegmentTemplateType, rolloverS int, timescale uint64) {
+	oldTimescale := uint64(st.GetTimescale())
+	if oldTimescale != timescale {
+		rescaleSegmentTemplate(st, timescale)
+	}
+	offset := largeTfdtOffset(rolloverS, timescale)
+	var pto uint64
+	if st.PresentationTimeOffset != nil {
+		pto = *st.PresentationTimeOffset
+	}
+	st.PresentationTimeOffset = Ptr(pto + offset)
+	if st.SegmentTimeline != nil {
+		for _, s := range st.SegmentTimeline.S {
+			if s.T != nil {
+				s.T = Ptr(*s.T + offset)
+			}
+		}
+	}
+}
+
+// removeLargeTfdtOffset converts a $Time$ segment identifier back to media time without the largetfdt offset.
+func removeLargeTfdtOffset(cfg *ResponseConfig, rep *RepData, segmentPart string, segID int) (int, error) {
+	offset := repLargeTfdtOffset(cfg, rep)
+	if offset == 0 || cfg.getRepType(segmentPart) != timeLineTime {
+		return segID, nil
+	}
+	if uint64(segID) < offset {
+		return 0, errNotFound
+	}
+	return segID - int(offset), nil
+}
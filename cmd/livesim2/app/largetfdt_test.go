@@ -0,0 +1,60 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Eyevinn/mp4ff/mp4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLargeTfdt(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	videoOffset := uint64(1<<32 - 60*90000)
+	audioOffset := uint64(1<<32 - 60*48000)
+
+	resp, body := testFullRequest(t, ts, "GET", "/livesim2/largetfdt_60/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), fmt.Sprintf(`duration="96000" startNumber="0" timescale="48000" presentationTimeOffset="%d"`, audioOffset))
+	require.Contains(t, string(body), fmt.Sprintf(`duration="180000" startNumber="0" timescale="90000" presentationTimeOffset="%d"`, videoOffset))
+
+	getSeg := func(url string) *mp4.MediaSegment {
+		resp, body := testFullRequest(t, ts, "GET", url, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode, url)
+		f, err := mp4.DecodeFile(bytes.NewBuffer(body))
+		require.NoError(t, err)
+		require.Len(t, f.Segments, 1)
+		return f.Segments[0]
+	}
+
+	// Rollover after 60s
+	tfdt := getSeg("/livesim2/largetfdt_60/testpic_2s/V300/20.m4s?nowMS=100000").Fragments[0].Moof.Traf.Tfdt
+	require.Equal(t, uint64(40*90000)+videoOffset, tfdt.BaseMediaDecodeTime())
+	require.Equal(t, byte(0), tfdt.Version)
+	tfdt = getSeg("/livesim2/largetfdt_60/testpic_2s/V300/40.m4s?nowMS=100000").Fragments[0].Moof.Traf.Tfdt
+	require.Equal(t, uint64(80*90000)+videoOffset, tfdt.BaseMediaDecodeTime())
+	require.Equal(t, byte(1), tfdt.Version)
+	tfdt = getSeg("/livesim2/largetfdt_60/testpic_2s/A48/40.m4s?nowMS=100000").Fragments[0].Moof.Traf.Tfdt
+	require.Equal(t, byte(1), tfdt.Version)
+	require.InDelta(t, float64(80*48000+audioOffset), float64(tfdt.BaseMediaDecodeTime()), 1024)
+
+	// SegmentTimeline with $Time$ addressing
+	resp, body = testFullRequest(t, ts, "GET", "/livesim2/segtimeline_1/largetfdt_60/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), fmt.Sprintf(`<S t="%d" d="180000" r="30">`, 38*90000+videoOffset))
+	videoTime := 80*90000 + videoOffset
+	tfdt = getSeg(fmt.Sprintf("/livesim2/segtimeline_1/largetfdt_60/testpic_2s/V300/%d.m4s?nowMS=100000", videoTime)).Fragments[0].Moof.Traf.Tfdt
+	require.Equal(t, videoTime, tfdt.BaseMediaDecodeTime())
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/segtimeline_1/largetfdt_60/testpic_2s/V300/7200000.m4s?nowMS=100000", nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/largetfdt_-1/testpic_2s/Manifest.mpd", nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
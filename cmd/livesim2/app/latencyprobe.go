@@ -0,0 +1,247 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/Eyevinn/mp4ff/mp4"
+)
+
+const (
+	// latencyProbeSchemeIdUri is the scheme of the emsg boxes with correlation IDs.
+	// The Value of the InbandEventStream is the URL to which players report.
+	latencyProbeSchemeIdUri = "urn:livesim2:latencyprobe:2024"
+	// latencyProbePath is the path of the latency report endpoint
+	latencyProbePath = "/latency-probe"
+	// maxLatencySamples is the number of latency samples kept per asset
+	maxLatencySamples = 1000
+	// maxLatencyMS is the largest accepted latency. Larger values indicate unsynchronized clocks.
+	maxLatencyMS = 3600_000
+	// maxLatencyReportSize limits the size of a received report
+	maxLatencyReportSize = 4096
+)
+
+// latencyProbeID returns the correlation ID of the media time produced at wall-clock time prodMS.
+// It is the emsg message data, and prodMS is also the NTP time of the prft box of the same segment.
+func latencyProbeID(assetPath string, prodMS int64) string {
+	return fmt.Sprintf("%s@%d", assetPath, prodMS)
+}
+
+// parseLatencyProbeID returns the asset path and production time of a correlation ID.
+func parseLatencyProbeID(id string) (assetPath string, prodMS int64, err error) {
+	idx := strings.LastIndex(id, "@")
+	if idx <= 0 {
+		return "", 0, fmt.Errorf("bad correlation id %q", id)
+	}
+	prodMS, err = strconv.ParseInt(id[idx+1:], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("bad correlation id %q", id)
+	}
+	return id[:idx], prodMS, nil
+}
+
+// latencyProbeProdMS returns the wall-clock time in ms when mediaTime was produced.
+func latencyProbeProdMS(mediaTime, timescale uint64, startTimeS int) int64 {
+	return int64(mediaTime*1000/timescale) + int64(startTimeS)*1000
+}
+
+// addLatencyProbe adds a prft box with the production time of the fragment start,
+// and, if withEmsg is set, an emsg box with the matching correlation ID.
+func addLatencyProbe(frag *mp4.Fragment, assetPath string, segNr uint32, timescale uint64, startTimeS int, withEmsg bool) {
+	traf := frag.Moof.Traf
+	mediaTime := traf.Tfdt.BaseMediaDecodeTime()
+	prodMS := latencyProbeProdMS(mediaTime, timescale, startTimeS)
+	if withEmsg {
+		emsg := &mp4.EmsgBox{
+			Version:          1,
+			TimeScale:        uint32(timescale),
+			PresentationTime: mediaTime,
+			ID:               segNr,
+			SchemeIDURI:      latencyProbeSchemeIdUri,
+			MessageData:      []byte(latencyProbeID(assetPath, prodMS)),
+		}
+		frag.AddEmsg(emsg)
+		frag.Emsgs = append(frag.Emsgs, emsg) // So that largetfdt moves its presentation time
+	}
+	prft := mp4.CreatePrftBox(1, mp4.PrftTimeEncoderInput, traf.Tfhd.TrackID,
+		mp4.NewNTP64(float64(prodMS)/1000), mediaTime)
+	for i, c := range frag.Children {
+		if c == frag.Moof {
+			frag.Children = append(frag.Children[:i+1], frag.Children[i:]...)
+			frag.Children[i] = prft
+			break
+		}
+	}
+	frag.Prft = prft
+}
+
+// latencyProbeRep tells if media segments of the representation get latency probes.
+func latencyProbeRep(rep *RepData) bool {
+	return rep.ContentType == "video" || rep.ContentType == "audio"
+}
+
+// addLatencyProbeSignaling signals inband prft boxes in audio and video AdaptationSets,
+// and the latency probe emsg in video AdaptationSets with the report URL as value.
+func addLatencyProbeSignaling(as *m.AdaptationSetType, cfg *ResponseConfig) {
+	if as.ContentType != "video" && as.ContentType != "audio" {
+		return
+	}
+	if as.ProducerReferenceTimes == nil {
+		as.ProducerReferenceTimes = createProducerReferenceTimes(cfg.StartTimeS)
+	}
+	for _, prt := range as.ProducerReferenceTimes {
+		prt.Inband = true
+	}
+	if as.ContentType == "video" {
+		as.InbandEventStreams = append(as.InbandEventStreams,
+			&m.EventStreamType{
+				SchemeIdUri: latencyProbeSchemeIdUri,
+				Value:       cfg.Host + latencyProbePath,
+			})
+	}
+}
+
+// latencyReport is a report from a player that presented the media time of a latency probe emsg.
+type latencyReport struct {
+	// ID is the correlation ID in the emsg message data
+	ID string `json:"id" doc:"Correlation ID in the emsg message data"`
+	// OffsetMS is the presentation time of the observed frame relative to the emsg presentation time
+	OffsetMS int64 `json:"offsetMS" required:"false" doc:"Presentation time of the observed frame relative to the emsg (ms)"`
+	// WallClockMS is the wall-clock time of the observation. The time of reception is used if not set.
+	WallClockMS *int64 `json:"wallClockMS" required:"false" doc:"Wall-clock time of the observation (default reception time)"`
+}
+
+// latencySamples is a ring buffer of latencies for an asset.
+type latencySamples struct {
+	samples []float64
+	next    int
+	total   int
+	updated time.Time
+}
+
+// latencyStats keeps end-to-end latency samples per asset.
+type latencyStats struct {
+	mu     sync.Mutex
+	assets map[string]*latencySamples
+}
+
+func newLatencyStats() *latencyStats {
+	return &latencyStats{assets: make(map[string]*latencySamples)}
+}
+
+func (ls *latencyStats) add(assetPath string, latencyMS float64, now time.Time) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	s, ok := ls.assets[assetPath]
+	if !ok {
+		s = &latencySamples{}
+		ls.assets[assetPath] = s
+	}
+	if len(s.samples) < maxLatencySamples {
+		s.samples = append(s.samples, latencyMS)
+	} else {
+		s.samples[s.next] = latencyMS
+	}
+	s.next = (s.next + 1) % maxLatencySamples
+	s.total++
+	s.updated = now
+}
+
+// LatencyStats is the end-to-end latency distribution for an asset.
+type LatencyStats struct {
+	Asset   string    `json:"asset" doc:"Asset path"`
+	Total   int       `json:"total" doc:"Number of reports received"`
+	Count   int       `json:"count" doc:"Number of latest reports in the distribution"`
+	Updated time.Time `json:"updated" doc:"Time of last report"`
+	MinMS   float64   `json:"minMS" doc:"Minimum latency (ms)"`
+	MeanMS  float64   `json:"meanMS" doc:"Mean latency (ms)"`
+	P50MS   float64   `json:"p50MS" doc:"Median latency (ms)"`
+	P90MS   float64   `json:"p90MS" doc:"90th percentile latency (ms)"`
+	P99MS   float64   `json:"p99MS" doc:"99th percentile latency (ms)"`
+	MaxMS   float64   `json:"maxMS" doc:"Maximum latency (ms)"`
+}
+
+// stats returns the latency distributions of all assets, or only assetPath if not empty, sorted by asset.
+func (ls *latencyStats) stats(assetPath string) []LatencyStats {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	out := make([]LatencyStats, 0, len(ls.assets))
+	for ap, s := range ls.assets {
+		if assetPath != "" && ap != assetPath {
+			continue
+		}
+		sorted := append([]float64(nil), s.samples...)
+		sort.Float64s(sorted)
+		sum := 0.0
+		for _, v := range sorted {
+			sum += v
+		}
+		n := len(sorted)
+		percentile := func(p float64) float64 {
+			return sorted[int(math.Ceil(p*float64(n)))-1]
+		}
+		out = append(out, LatencyStats{
+			Asset:   ap,
+			Total:   s.total,
+			Count:   n,
+			Updated: s.updated,
+			MinMS:   sorted[0],
+			MeanMS:  sum / float64(n),
+			P50MS:   percentile(0.5),
+			P90MS:   percentile(0.9),
+			P99MS:   percentile(0.99),
+			MaxMS:   sorted[n-1],
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Asset < out[j].Asset })
+	return out
+}
+
+// latencyProbeHandlerFunc receives latency reports from players and returns the computed latency.
+func (s *Server) latencyProbeHandlerFunc(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	var rep latencyReport
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxLatencyReportSize))
+	if err == nil {
+		err = json.Unmarshal(body, &rep)
+	}
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, reasonBadValue, "bad latency report")
+		return
+	}
+	assetPath, prodMS, err := parseLatencyProbeID(rep.ID)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, reasonBadValue, err.Error())
+		return
+	}
+	if _, ok := s.assetMgr.getAsset(assetPath); !ok {
+		writeProblem(w, r, http.StatusNotFound, reasonNotFound, fmt.Sprintf("unknown asset %q", assetPath))
+		return
+	}
+	wallClockMS := now.UnixMilli()
+	if rep.WallClockMS != nil {
+		wallClockMS = *rep.WallClockMS
+	}
+	latencyMS := wallClockMS - prodMS - rep.OffsetMS
+	if latencyMS < 0 || latencyMS > maxLatencyMS {
+		msg := fmt.Sprintf("latency %dms out of range, check client clock synchronization", latencyMS)
+		writeProblem(w, r, http.StatusBadRequest, reasonBadValue, msg)
+		return
+	}
+	s.latency.add(assetPath, float64(latencyMS), now)
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = fmt.Fprintf(w, `{"latencyMS":%d}`, latencyMS)
+}
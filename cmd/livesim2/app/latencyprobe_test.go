@@ -0,0 +1,96 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/Eyevinn/mp4ff/mp4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencyProbe(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	resp, body := testFullRequest(t, ts, "GET", "/livesim2/latencyprobe_1/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	mpd, err := m.ReadFromString(string(body))
+	require.NoError(t, err)
+	for _, as := range mpd.Periods[0].AdaptationSets {
+		require.Len(t, as.ProducerReferenceTimes, 1)
+		require.True(t, as.ProducerReferenceTimes[0].Inband)
+		if as.ContentType == "video" {
+			require.Len(t, as.InbandEventStreams, 1)
+			require.Equal(t, m.AnyURI(latencyProbeSchemeIdUri), as.InbandEventStreams[0].SchemeIdUri)
+			require.Equal(t, ts.URL+latencyProbePath, as.InbandEventStreams[0].Value)
+		}
+	}
+
+	// getSeg returns the fragments and the prft boxes, which mp4ff only keeps as top-level boxes
+	getSeg := func(url string) ([]*mp4.Fragment, []*mp4.PrftBox) {
+		t.Helper()
+		resp, body := testFullRequest(t, ts, "GET", url, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		f, err := mp4.DecodeFile(bytes.NewBuffer(body))
+		require.NoError(t, err)
+		var prfts []*mp4.PrftBox
+		for _, c := range f.Children {
+			if prft, ok := c.(*mp4.PrftBox); ok {
+				prfts = append(prfts, prft)
+			}
+		}
+		return f.Segments[0].Fragments, prfts
+	}
+	// Segment 40 starts at 80s. The prft and emsg of the video segment have the same production time.
+	frags, prfts := getSeg("/livesim2/latencyprobe_1/testpic_2s/V300/40.m4s?nowMS=100000")
+	require.Len(t, prfts, 1)
+	require.Equal(t, 80.0, prfts[0].NTPTimestamp.UTC())
+	require.Equal(t, frags[0].Moof.Traf.Tfdt.BaseMediaDecodeTime(), prfts[0].MediaTime)
+	require.Len(t, frags[0].Emsgs, 1)
+	emsg := frags[0].Emsgs[0]
+	require.Equal(t, latencyProbeSchemeIdUri, emsg.SchemeIDURI)
+	require.Equal(t, "testpic_2s@80000", string(emsg.MessageData))
+	require.Equal(t, uint32(40), emsg.ID)
+	frags, prfts = getSeg("/livesim2/latencyprobe_1/testpic_2s/A48/40.m4s?nowMS=100000")
+	require.Len(t, prfts, 1)
+	require.Len(t, frags[0].Emsgs, 0)
+
+	// Chunked segments have a prft box in every chunk, and the emsg in the first one
+	frags, prfts = getSeg("/livesim2/latencyprobe_1/chunkdur_0.5/ato_1.5/testpic_2s/V300/40.m4s?nowMS=100000")
+	require.Len(t, frags, 4)
+	require.Len(t, prfts, 4)
+	require.Len(t, frags[0].Emsgs, 1)
+	require.Equal(t, 80.5, prfts[1].NTPTimestamp.UTC())
+
+	report := func(rep string, wantedStatus int) []byte {
+		t.Helper()
+		resp, body := testFullRequest(t, ts, "POST", latencyProbePath, strings.NewReader(rep))
+		require.Equal(t, wantedStatus, resp.StatusCode, rep)
+		return body
+	}
+	body = report(`{"id": "testpic_2s@80000", "wallClockMS": 81500}`, http.StatusOK)
+	require.JSONEq(t, `{"latencyMS": 1500}`, string(body))
+	report(`{"id": "testpic_2s@80000", "offsetMS": 500, "wallClockMS": 83000}`, http.StatusOK)
+	report(`{"id": "testpic_2s@80000", "wallClockMS": 79000}`, http.StatusBadRequest)
+	report(`{"id": "testpic_2s"}`, http.StatusBadRequest)
+	report(`{"id": "nosuch@80000"}`, http.StatusNotFound)
+
+	resp, body = testFullRequest(t, ts, "GET", "/api/stats/latency?asset=testpic_2s", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var stats LatencyStatsResponse
+	require.NoError(t, json.Unmarshal(body, &stats.Body))
+	require.Len(t, stats.Body.Assets, 1)
+	ls := stats.Body.Assets[0]
+	require.Equal(t, 2, ls.Count)
+	require.Equal(t, 1500.0, ls.MinMS)
+	require.Equal(t, 2000.0, ls.MeanMS)
+	require.Equal(t, 1500.0, ls.P50MS)
+	require.Equal(t, 2500.0, ls.P99MS)
+}
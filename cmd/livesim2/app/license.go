@@ -0,0 +1,128 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Dash-Industry-Forum/livesim2/pkg/drm"
+)
+
+const (
+	maxLicenseDelayMS = 60_000
+	// licenseProxyInfix precedes the DRM system name in the URL of the license proxy.
+	licenseProxyInfix = "/license/"
+	// licenseProxyTimeout limits the time waiting for the real license server.
+	licenseProxyTimeout = 30 * time.Second
+)
+
+// LicenseSim configures the license_<delayMS>[_<failPercent>] URL parameter,
+// which delays the responses of the license endpoints and lets a share of them fail.
+// For ClearKey, it applies to the built-in laURL endpoint. For CPIX-configured DRMs,
+// the laURL in the MPD is replaced by a livesim2 proxy to the real license server.
+type LicenseSim struct {
+	DelayMS     int `json:"DelayMS"`
+	FailPercent int `json:"FailPercent,omitempty"`
+}
+
+func (ls *LicenseSim) validate(cfg *ResponseConfig) error {
+	if cfg.DRM == "" {
+		return newReasonError(reasonBadCombination, fmt.Errorf("license requires drm or eccp"))
+	}
+	if ls.DelayMS < 0 || ls.DelayMS > maxLicenseDelayMS {
+		return fmt.Errorf("license delay %dms not in range 0-%d", ls.DelayMS, maxLicenseDelayMS)
+	}
+	if ls.FailPercent < 0 || ls.FailPercent > 100 {
+		return fmt.Errorf("license fail percentage %d not in range 0-100", ls.FailPercent)
+	}
+	return nil
+}
+
+// apply waits for the configured delay and then possibly writes a failure response.
+// It returns true if the response has been written, or the client has gone away.
+func (ls *LicenseSim) apply(w http.ResponseWriter, r *http.Request, log *slog.Logger) bool {
+	if ls.DelayMS > 0 {
+		select {
+		case <-time.After(time.Duration(ls.DelayMS) * time.Millisecond):
+		case <-r.Context().Done():
+			return true
+		}
+	}
+	if rand.IntN(100) < ls.FailPercent {
+		log.Debug("simulated license failure", "url", r.URL.Path)
+		writeProblem(w, r, http.StatusServiceUnavailable, reasonTriggeredStatus, "simulated license failure")
+		return true
+	}
+	return false
+}
+
+// genLicenseProxyURL returns the URL of the license proxy for drmSystem.
+func genLicenseProxyURL(cfg *ResponseConfig, drmSystem string) string {
+	return cfg.Host + strings.Join(cfg.URLParts[:cfg.URLContentIdx+1], "/") + licenseProxyInfix + drmSystem
+}
+
+// licenseProxySystem returns the DRM system name if uPath is a license proxy URL.
+func licenseProxySystem(uPath string) (string, bool) {
+	i := strings.LastIndex(uPath, licenseProxyInfix)
+	if i < 0 {
+		return "", false
+	}
+	drmSystem := uPath[i+len(licenseProxyInfix):]
+	if _, ok := drm.SystemIDs[drmSystem]; !ok {
+		return "", false
+	}
+	return drmSystem, true
+}
+
+// licenseProxy forwards the license request to the license server configured for
+// cfg.DRM and drmSystem, and copies the response back.
+func licenseProxy(w http.ResponseWriter, r *http.Request, log *slog.Logger, cfg *ResponseConfig,
+	drmCfg *drm.DrmConfig, drmSystem string) {
+	if drmCfg == nil {
+		writeProblem(w, r, http.StatusNotFound, reasonNotFound, "no DRM configured")
+		return
+	}
+	d, ok := drmCfg.Map[cfg.DRM]
+	if !ok || d.URLs[drmSystem].LaURL == "" {
+		writeProblem(w, r, http.StatusNotFound, reasonNotFound,
+			fmt.Sprintf("no license URL for drm %q and system %s", cfg.DRM, drmSystem))
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, reasonBadValue, "could not read request body")
+		return
+	}
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, d.URLs[drmSystem].LaURL, bytes.NewReader(body))
+	if err != nil {
+		writeProblem(w, r, http.StatusInternalServerError, reasonInternal, "could not create license request")
+		return
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		req.Header.Set("Content-Type", ct)
+	}
+	client := http.Client{Timeout: licenseProxyTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Error("license proxy", "url", d.URLs[drmSystem].LaURL, "err", err)
+		writeProblem(w, r, http.StatusBadGateway, reasonInternal, "license server request failed")
+		return
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		w.Header().Set("Content-Type", ct)
+	}
+	w.WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		log.Error("license proxy response", "err", err)
+	}
+}
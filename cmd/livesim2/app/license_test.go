@@ -0,0 +1,70 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dash-Industry-Forum/livesim2/pkg/drm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLicenseSim(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	laReq := func(params string) io.Reader {
+		kid := kidFromString(ts.URL + "/livesim2/" + params + "/testpic_2s/eccp.json")
+		return strings.NewReader(`{"kids":["` + urlSafeBase64(kid.PackBase64()) + `"],"type":"temporary"}`)
+	}
+
+	resp, body := testFullRequest(t, ts, "GET", "/livesim2/license_200/eccp_cbcs/testpic_2s/Manifest.mpd", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), ts.URL+"/livesim2/license_200/eccp_cbcs/testpic_2s/eccp.json")
+
+	start := time.Now()
+	resp, body = testFullRequest(t, ts, "POST", "/livesim2/license_200/eccp_cbcs/testpic_2s/eccp.json",
+		laReq("license_200/eccp_cbcs"))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	require.Contains(t, string(body), `"kty":"oct"`)
+
+	resp, _ = testFullRequest(t, ts, "POST", "/livesim2/license_0_100/eccp_cbcs/testpic_2s/eccp.json",
+		laReq("license_0_100/eccp_cbcs"))
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	for _, params := range []string{"license_100", "eccp_cbcs/license_-1", "eccp_cbcs/license_0_101",
+		"eccp_cbcs/license_1_2_3"} {
+		resp, _ := testFullRequest(t, ts, "GET", "/livesim2/"+params+"/testpic_2s/Manifest.mpd", nil)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, params)
+	}
+}
+
+func TestLicenseProxy(t *testing.T) {
+	licenseServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(append([]byte("license:"), body...))
+	}))
+	defer licenseServer.Close()
+
+	server, ts := newTestServer(t, nil)
+	server.Cfg.DrmCfg = &drm.DrmConfig{Map: map[string]*drm.Package{
+		"test": {Name: "test", URLs: map[string]drm.LicenseURL{"widevine": {LaURL: licenseServer.URL}}},
+	}}
+
+	resp, body := testFullRequest(t, ts, "POST", "/livesim2/drm_test/license_0/testpic_2s/license/widevine",
+		strings.NewReader("challenge"))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "license:challenge", string(body))
+
+	resp, _ = testFullRequest(t, ts, "POST", "/livesim2/drm_test/license_0/testpic_2s/license/playready",
+		strings.NewReader("challenge"))
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
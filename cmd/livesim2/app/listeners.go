@@ -0,0 +1,209 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Route sets that can be served by a listener.
+const (
+	listenerRoutesAll   = "all"
+	listenerRoutesMedia = "media"
+	listenerRoutesAdmin = "admin"
+)
+
+// adminPathPrefixes are the paths served by admin listeners and not by media listeners.
+var adminPathPrefixes = []string{"/api", "/metrics", "/debug", "/config", "/loglevel", "/reqcount", "/healthz"}
+
+// ListenerConfig configures one address to serve on.
+// If any listeners are configured, they replace the port/domains/certpath setup.
+type ListenerConfig struct {
+	// Addr is host:port to listen on, e.g. ":8888" or "127.0.0.1:9000".
+	// "unix:/path/to.sock" listens on a Unix domain socket, and
+	// "systemd:N" or "systemd:name" uses a socket passed by systemd socket activation.
+	Addr string `json:"addr"`
+	// CertPath and KeyPath enable HTTPS for this listener
+	CertPath string `json:"certpath,omitempty"`
+	KeyPath  string `json:"keypath,omitempty"`
+	// Routes is one of "all" (default), "media", or "admin"
+	Routes string `json:"routes,omitempty"`
+	// TimeoutS is an extra per-listener request timeout (seconds)
+	TimeoutS int `json:"timeoutS,omitempty"`
+	// Shaping emulates TCP characteristics on each connection
+	Shaping *ShapingConfig `json:"shaping,omitempty"`
+}
+
+func validateListeners(lcs []ListenerConfig) error {
+	for _, lc := range lcs {
+		if lc.Addr == "" || lc.Addr == "unix:" || lc.Addr == "systemd:" {
+			return fmt.Errorf("listener without address: %q", lc.Addr)
+		}
+		switch lc.Routes {
+		case "", listenerRoutesAll, listenerRoutesMedia, listenerRoutesAdmin:
+		default:
+			return fmt.Errorf("listener %s: unknown routes %q", lc.Addr, lc.Routes)
+		}
+		if (lc.CertPath == "") != (lc.KeyPath == "") {
+			return fmt.Errorf("listener %s: certpath and keypath must both be empty or set", lc.Addr)
+		}
+		if lc.Shaping != nil {
+			if err := lc.Shaping.validate(); err != nil {
+				return fmt.Errorf("listener %s: %w", lc.Addr, err)
+			}
+		}
+	}
+	return nil
+}
+
+func isAdminPath(p string) bool {
+	for _, prefix := range adminPathPrefixes {
+		if p == prefix || strings.HasPrefix(p, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// restrictRoutes only lets requests through for which isAdminPath equals admin.
+func restrictRoutes(admin bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isAdminPath(r.URL.Path) != admin {
+				http.NotFound(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// listenerHandler returns the server router wrapped in the middleware chain of the listener.
+func (s *Server) listenerHandler(lc ListenerConfig) http.Handler {
+	h := http.Handler(s.Router)
+	if lc.TimeoutS > 0 {
+		h = middleware.Timeout(time.Duration(lc.TimeoutS) * time.Second)(h)
+	}
+	switch lc.Routes {
+	case listenerRoutesMedia:
+		h = restrictRoutes(false)(h)
+	case listenerRoutesAdmin:
+		h = restrictRoutes(true)(h)
+	}
+	return h
+}
+
+// ServeListeners serves on all configured listeners until ctx is done or one of them fails.
+func (s *Server) ServeListeners(ctx context.Context) error {
+	lcs := s.Cfg.Listeners
+	errCh := make(chan error, len(lcs))
+	servers := make([]*http.Server, 0, len(lcs))
+	for _, lc := range lcs {
+		ln, err := listen(lc.Addr)
+		if err != nil {
+			for _, hs := range servers {
+				_ = hs.Close()
+			}
+			return fmt.Errorf("listener %s: %w", lc.Addr, err)
+		}
+		if lc.Shaping != nil {
+			ln = &shapingListener{Listener: ln, sc: lc.Shaping}
+		}
+		hs := &http.Server{Addr: lc.Addr, Handler: s.listenerHandler(lc)}
+		servers = append(servers, hs)
+		slog.Info("Starting listener", "addr", lc.Addr, "routes", lc.Routes, "tls", lc.CertPath != "",
+			"shaping", lc.Shaping != nil)
+		go func(lc ListenerConfig) {
+			var err error
+			if lc.CertPath != "" {
+				err = hs.ServeTLS(ln, lc.CertPath, lc.KeyPath)
+			} else {
+				err = hs.Serve(ln)
+			}
+			errCh <- fmt.Errorf("listener %s: %w", lc.Addr, err)
+		}(lc)
+	}
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-errCh:
+	}
+	for _, hs := range servers {
+		_ = hs.Close()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// listen creates a TCP, Unix domain socket, or systemd-activated listener depending on addr.
+func listen(addr string) (net.Listener, error) {
+	switch {
+	case strings.HasPrefix(addr, "unix:"):
+		sockPath := strings.TrimPrefix(addr, "unix:")
+		// Remove stale socket from earlier run
+		if fi, err := os.Stat(sockPath); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			if err := os.Remove(sockPath); err != nil {
+				return nil, err
+			}
+		}
+		return net.Listen("unix", sockPath)
+	case strings.HasPrefix(addr, "systemd:"):
+		fd, err := systemdFD(strings.TrimPrefix(addr, "systemd:"), os.Getenv, os.Getpid())
+		if err != nil {
+			return nil, err
+		}
+		f := os.NewFile(uintptr(fd), addr)
+		defer f.Close()
+		return net.FileListener(f)
+	default:
+		return net.Listen("tcp", addr)
+	}
+}
+
+// systemdFirstFD is the first file descriptor passed by systemd socket activation.
+const systemdFirstFD = 3
+
+// systemdFD returns the file descriptor for a socket passed by systemd.
+// spec is either an index or a name from LISTEN_FDNAMES (FileDescriptorName= in the socket unit).
+func systemdFD(spec string, getenv func(string) string, pid int) (int, error) {
+	listenPID, err := strconv.Atoi(getenv("LISTEN_PID"))
+	if err != nil || listenPID != pid {
+		return 0, fmt.Errorf("no sockets passed by systemd to this process")
+	}
+	nrFDs, err := strconv.Atoi(getenv("LISTEN_FDS"))
+	if err != nil || nrFDs <= 0 {
+		return 0, fmt.Errorf("bad LISTEN_FDS %q", getenv("LISTEN_FDS"))
+	}
+	idx, err := strconv.Atoi(spec)
+	if err != nil {
+		idx = -1
+		for i, name := range strings.Split(getenv("LISTEN_FDNAMES"), ":") {
+			if name == spec {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return 0, fmt.Errorf("no systemd socket named %q", spec)
+		}
+	}
+	if idx < 0 || idx >= nrFDs {
+		return 0, fmt.Errorf("systemd socket index %d out of range [0, %d)", idx, nrFDs)
+	}
+	return systemdFirstFD + idx, nil
+}
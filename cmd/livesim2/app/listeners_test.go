@@ -0,0 +1,147 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenerRoutes(t *testing.T) {
+	server, _ := newTestServer(t, nil)
+
+	testCases := []struct {
+		routes     string
+		path       string
+		wantedCode int
+	}{
+		{listenerRoutesAll, "/healthz", http.StatusOK},
+		{listenerRoutesAll, "/livesim2/testpic_2s/V300/init.mp4", http.StatusOK},
+		{listenerRoutesMedia, "/livesim2/testpic_2s/V300/init.mp4", http.StatusOK},
+		{listenerRoutesMedia, "/healthz", http.StatusNotFound},
+		{listenerRoutesMedia, "/api/sessions/abc", http.StatusNotFound},
+		{listenerRoutesAdmin, "/healthz", http.StatusOK},
+		{listenerRoutesAdmin, "/livesim2/testpic_2s/V300/init.mp4", http.StatusNotFound},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.routes+tc.path, func(t *testing.T) {
+			ts := httptest.NewServer(server.listenerHandler(ListenerConfig{Addr: ":0", Routes: tc.routes}))
+			defer ts.Close()
+			resp, _ := testFullRequest(t, ts, "GET", tc.path, nil)
+			require.Equal(t, tc.wantedCode, resp.StatusCode)
+		})
+	}
+}
+
+func TestServeListeners(t *testing.T) {
+	server, _ := newTestServer(t, func(cfg *ServerConfig) {
+		cfg.Listeners = []ListenerConfig{
+			{Addr: "127.0.0.1:0"},
+			{Addr: "127.0.0.1:0", Routes: listenerRoutesAdmin},
+		}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- server.ServeListeners(ctx) }()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestValidateListeners(t *testing.T) {
+	require.NoError(t, validateListeners([]ListenerConfig{{Addr: ":8888"}, {Addr: "127.0.0.1:9000", Routes: "admin"}}))
+	require.Error(t, validateListeners([]ListenerConfig{{Addr: ""}}))
+	require.Error(t, validateListeners([]ListenerConfig{{Addr: "unix:"}}))
+	require.Error(t, validateListeners([]ListenerConfig{{Addr: ":80", Routes: "other"}}))
+	require.Error(t, validateListeners([]ListenerConfig{{Addr: ":443", CertPath: "cert.pem"}}))
+	require.Error(t, validateListeners([]ListenerConfig{{Addr: ":8888", Shaping: &ShapingConfig{StallPercent: 10}}}))
+}
+
+func TestUnixSocketListener(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "livesim2.sock")
+	server, _ := newTestServer(t, func(cfg *ServerConfig) {
+		cfg.Listeners = []ListenerConfig{{Addr: "unix:" + sockPath}}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- server.ServeListeners(ctx) }()
+
+	client := http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", sockPath)
+			},
+		},
+	}
+	var resp *http.Response
+	var err error
+	for i := 0; i < 50; i++ {
+		resp, err = client.Get("http://livesim2/healthz")
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestSystemdFD(t *testing.T) {
+	env := map[string]string{
+		"LISTEN_PID":     "42",
+		"LISTEN_FDS":     "2",
+		"LISTEN_FDNAMES": "http:admin",
+	}
+	getenv := func(k string) string { return env[k] }
+	testCases := []struct {
+		spec     string
+		pid      int
+		wantedFD int
+		wantErr  bool
+	}{
+		{"0", 42, 3, false},
+		{"1", 42, 4, false},
+		{"admin", 42, 4, false},
+		{"2", 42, 0, true},
+		{"other", 42, 0, true},
+		{"0", 43, 0, true},
+	}
+	for _, tc := range testCases {
+		fd, err := systemdFD(tc.spec, getenv, tc.pid)
+		if tc.wantErr {
+			require.Error(t, err, tc.spec)
+			continue
+		}
+		require.NoError(t, err)
+		require.Equal(t, tc.wantedFD, fd)
+	}
+}
+
+func TestPprofRoutes(t *testing.T) {
+	for _, pprof := range []bool{false, true} {
+		server, _ := newTestServer(t, func(cfg *ServerConfig) {
+			cfg.Pprof = pprof
+		})
+		ts := httptest.NewServer(server.listenerHandler(ListenerConfig{Addr: ":0", Routes: listenerRoutesAdmin}))
+		resp, _ := testFullRequest(t, ts, "GET", "/debug/pprof/", nil)
+		require.Equal(t, pprof, resp.StatusCode == http.StatusOK, "pprof=%t status %d", pprof, resp.StatusCode)
+		ts.Close()
+		ts = httptest.NewServer(server.listenerHandler(ListenerConfig{Addr: ":0", Routes: listenerRoutesMedia}))
+		resp, _ = testFullRequest(t, ts, "GET", "/debug/pprof/", nil)
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		ts.Close()
+	}
+}
@@ -15,6 +15,7 @@ import (
 
 	"github.com/Dash-Industry-Forum/livesim2/pkg/drm"
 	"github.com/Dash-Industry-Forum/livesim2/pkg/scte35"
+	"github.com/Dash-Industry-Forum/livesim2/pkg/segtemplate"
 	m "github.com/Eyevinn/dash-mpd/mpd"
 )
 
@@ -54,6 +55,97 @@ func genLaURL(cfg *ResponseConfig) string {
 
 // LiveMPD generates a dynamic configured MPD for a VoD asset.
 func LiveMPD(a *asset, mpdName string, cfg *ResponseConfig, drmCfg *drm.DrmConfig, nowMS int) (*m.MPD, error) {
+	mpd, err := liveMPD(a, mpdName, cfg, drmCfg, nowMS)
+	if err != nil {
+		return nil, err
+	}
+	signalCaptions(mpd, a, cfg.CCStripFlag)
+	if cfg.DRMMix != "" {
+		applyDRMMix(mpd, cfg)
+	}
+	if cfg.Blackout != nil {
+		addBlackoutEvents(mpd, cfg.Blackout, cfg.StartTimeS)
+	}
+	if len(cfg.Events) > 0 {
+		addSessionEvents(mpd, cfg.Events, cfg.StartTimeS)
+	}
+	if cfg.Programs != nil && !cfg.Programs.Inband {
+		addProgramEvents(mpd, cfg.Programs, cfg.StartTimeS, nowMS)
+	}
+	if cfg.ClockSkewS != nil {
+		if err := applyClockSkew(mpd, *cfg.ClockSkewS); err != nil {
+			return nil, fmt.Errorf("clockSkew: %w", err)
+		}
+	}
+	if cfg.Timescale != nil {
+		rescaleMPD(mpd, uint64(*cfg.Timescale))
+	}
+	if cfg.LargeTfdtS != nil {
+		applyLargeTfdt(mpd, a, cfg)
+	}
+	if len(cfg.STLInject) > 0 {
+		injectTimelineFaults(mpd, cfg.STLInject)
+	}
+	if cfg.Ladder != nil {
+		applyLadder(mpd, cfg.Ladder)
+	}
+	if cfg.SizeVariance != nil {
+		applySizeVarianceBandwidth(mpd, cfg.SizeVariance)
+	}
+	if cfg.RepChange != nil {
+		applyRepChange(mpd, cfg.RepChange, nowMS)
+	}
+	if cfg.RepIDChange != nil {
+		applyRepIDChange(mpd, a, cfg.RepIDChange, nowMS)
+	}
+	if cfg.Viewpoints != nil {
+		addViewpoints(mpd, *cfg.Viewpoints)
+	}
+	if cfg.HDR != nil {
+		addHDRSignaling(mpd, cfg.HDR)
+	}
+	if cfg.BandwidthDrift != nil {
+		applyBandwidthDrift(mpd, a, cfg.BandwidthDrift)
+	}
+	if cfg.DurationDriftPct != nil {
+		applyDurationDrift(mpd, *cfg.DurationDriftPct)
+	}
+	if cfg.Capabilities != nil {
+		if err := filterByCapabilities(mpd, cfg.Capabilities); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.CrossHost != nil {
+		if err := applyCrossHost(mpd, cfg); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.QoEProbability != nil {
+		addMetricsReporting(mpd)
+	}
+	if cfg.MPDInflate != nil {
+		inflateMPD(mpd, cfg.MPDInflate)
+	}
+	return mpd, nil
+}
+
+// applyClockSkew shifts availabilityStartTime and publishTime by skewS, simulating an origin with a wrong clock.
+// Segment availability and UTCTiming are not changed.
+func applyClockSkew(mpd *m.MPD, skewS float64) error {
+	for _, dt := range []*m.DateTime{&mpd.AvailabilityStartTime, &mpd.PublishTime} {
+		if *dt == "" {
+			continue
+		}
+		t, err := dt.ConvertToSeconds()
+		if err != nil {
+			return err
+		}
+		*dt = m.ConvertToDateTime(t + skewS)
+	}
+	return nil
+}
+
+func liveMPD(a *asset, mpdName string, cfg *ResponseConfig, drmCfg *drm.DrmConfig, nowMS int) (*m.MPD, error) {
 	mpd, err := a.getVodMPD(mpdName)
 	if err != nil {
 		return nil, err
@@ -65,6 +157,10 @@ func LiveMPD(a *asset, mpdName string, cfg *ResponseConfig, drmCfg *drm.DrmConfi
 	if cfg.MinimumUpdatePeriodS != nil {
 		mpd.MinimumUpdatePeriod = m.Seconds2DurPtr(*cfg.MinimumUpdatePeriodS)
 	}
+	if cfg.MPDExpiryEvents != nil {
+		// Updates are only signaled by MPD validity expiration events
+		mpd.MinimumUpdatePeriod = Ptr(m.Duration(0))
+	}
 	if cfg.SuggestedPresentationDelayS != nil {
 		mpd.SuggestedPresentationDelay = m.Seconds2DurPtr(*cfg.SuggestedPresentationDelayS)
 	}
@@ -93,7 +189,7 @@ func LiveMPD(a *asset, mpdName string, cfg *ResponseConfig, drmCfg *drm.DrmConfi
 	}
 
 	if cfg.getAvailabilityTimeOffsetS() > 0 {
-		if !cfg.AvailabilityTimeCompleteFlag {
+		if !cfg.signaledATC() {
 			if cfg.LatencyTargetMS == nil {
 				return nil, fmt.Errorf("latencyTargetMS (ltgt) not set")
 			}
@@ -102,7 +198,7 @@ func LiveMPD(a *asset, mpdName string, cfg *ResponseConfig, drmCfg *drm.DrmConfi
 		}
 	}
 
-	addUTCTimings(mpd, cfg)
+	addUTCTimings(mpd, cfg, nowMS)
 
 	afterStop := false
 	endTimeMS := nowMS
@@ -113,6 +209,10 @@ func LiveMPD(a *asset, mpdName string, cfg *ResponseConfig, drmCfg *drm.DrmConfi
 			afterStop = true
 		}
 	}
+	if endNrTimeMS, ok := cfg.endNrTimeMS(a); ok && endNrTimeMS < endTimeMS {
+		endTimeMS = endNrTimeMS
+		afterStop = true
+	}
 
 	wTimes := calcWrapTimes(a, cfg, endTimeMS, *mpd.TimeShiftBufferDepth)
 
@@ -131,7 +231,7 @@ func LiveMPD(a *asset, mpdName string, cfg *ResponseConfig, drmCfg *drm.DrmConfi
 	var refSegEntries segEntries
 	for asIdx, as := range adaptationSets {
 		if as.SegmentTemplate != nil {
-			as.SegmentTemplate.EndNumber = nil // Never output endNumber
+			as.SegmentTemplate.EndNumber = nil // The endNumber of the VoD asset is never output, see enr
 		}
 		switch as.ContentType {
 		case "video", "audio":
@@ -202,9 +302,13 @@ func LiveMPD(a *asset, mpdName string, cfg *ResponseConfig, drmCfg *drm.DrmConfi
 								Value: drmSys.PSSH,
 							}
 						}
+						laURL := d.URLs[drmSystem].LaURL
+						if cfg.License != nil {
+							laURL = genLicenseProxyURL(cfg, drmSystem)
+						}
 						cp.LaURL = &m.LaURLType{
 							LicenseType: "EME-1.0",
-							Value:       m.AnyURI(d.URLs[drmSystem].LaURL),
+							Value:       m.AnyURI(laURL),
 						}
 						if drmSys.SmoothStreamingProtectionHeaderData != "" {
 							cp.MSPro = &m.MSProType{
@@ -224,10 +328,47 @@ func LiveMPD(a *asset, mpdName string, cfg *ResponseConfig, drmCfg *drm.DrmConfi
 					Value:       "",
 				})
 		}
+		if as.ContentType == "video" && cfg.Slate != nil && cfg.Slate.Signal {
+			as.InbandEventStreams = append(as.InbandEventStreams,
+				&m.EventStreamType{
+					SchemeIdUri: slateSchemeIdUri,
+				})
+		}
+		if as.ContentType == "video" && cfg.Blackout != nil {
+			as.InbandEventStreams = append(as.InbandEventStreams,
+				&m.EventStreamType{
+					SchemeIdUri: blackoutSchemeIdUri,
+				})
+		}
+		if as.ContentType == "video" && len(cfg.Events) > 0 {
+			addSessionInbandEventStreams(as, cfg.Events)
+		}
+		if as.ContentType == "video" && cfg.Programs != nil && cfg.Programs.Inband {
+			as.InbandEventStreams = append(as.InbandEventStreams,
+				&m.EventStreamType{
+					SchemeIdUri: programSchemeIdUri,
+				})
+		}
+		if as.ContentType == "video" && cfg.MPDExpiryEvents != nil {
+			as.InbandEventStreams = append(as.InbandEventStreams,
+				&m.EventStreamType{
+					SchemeIdUri: mpdEventSchemeIdUri,
+					Value:       mpdValidityExpiration,
+				})
+		}
+		if as.ContentType == "video" && cfg.ID3IntervalS != nil {
+			as.InbandEventStreams = append(as.InbandEventStreams,
+				&m.EventStreamType{
+					SchemeIdUri: id3SchemeIdUri,
+				})
+		}
 		atoMS, err := setOffsetInAdaptationSet(cfg, as)
 		if err != nil {
 			return nil, err
 		}
+		if cfg.LatencyProbeFlag {
+			addLatencyProbeSignaling(as, cfg)
+		}
 		var se segEntries
 		if asIdx == 0 {
 			// Assume that first representation is as good as any, so can be reference
@@ -271,6 +412,9 @@ func LiveMPD(a *asset, mpdName string, cfg *ResponseConfig, drmCfg *drm.DrmConfi
 				return nil, fmt.Errorf("adjustASForSegmentNumber: %w", err)
 			}
 			mpd.PublishTime = mpd.AvailabilityStartTime
+			if cfg.PublishTimeCadence != nil {
+				mpd.PublishTime = m.ConvertToDateTime(calcPublishTime(cfg, refSegEntries.lsi))
+			}
 		default:
 			return nil, fmt.Errorf("unknown mpd type")
 		}
@@ -289,8 +433,7 @@ func LiveMPD(a *asset, mpdName string, cfg *ResponseConfig, drmCfg *drm.DrmConfi
 	}
 	if cfg.PeriodsPerHour == nil {
 		if afterStop {
-			mpdDurS := *cfg.StopTimeS - cfg.StartTimeS
-			makeMPDStatic(mpd, mpdDurS)
+			makeMPDStatic(mpd, endTimeMS-cfg.StartTimeS*1000)
 			return mpd, nil
 		}
 		addPatchLocation(mpd, cfg)
@@ -303,7 +446,7 @@ func LiveMPD(a *asset, mpdName string, cfg *ResponseConfig, drmCfg *drm.DrmConfi
 		return nil, fmt.Errorf("splitPeriods: %w", err)
 	}
 
-	if cfg.liveMPDType() == segmentNumber {
+	if cfg.liveMPDType() == segmentNumber && cfg.PublishTimeCadence == nil {
 		mpd.PublishTime, err = lastPeriodStartTime(mpd)
 		if err != nil {
 			return nil, fmt.Errorf("lastPeriodStartTime: %w", err)
@@ -311,8 +454,7 @@ func LiveMPD(a *asset, mpdName string, cfg *ResponseConfig, drmCfg *drm.DrmConfi
 	}
 
 	if afterStop {
-		mpdDurS := *cfg.StopTimeS - cfg.StartTimeS
-		makeMPDStatic(mpd, mpdDurS)
+		makeMPDStatic(mpd, endTimeMS-cfg.StartTimeS*1000)
 		return mpd, nil
 	}
 	addPatchLocation(mpd, cfg)
@@ -347,12 +489,12 @@ func addPatchLocation(mpd *m.MPD, cfg *ResponseConfig) {
 	}
 }
 
-func makeMPDStatic(mpd *m.MPD, mpdDurS int) {
+func makeMPDStatic(mpd *m.MPD, mpdDurMS int) {
 	mpd.Type = Ptr(m.STATIC_TYPE)
 	mpd.TimeShiftBufferDepth = nil
 	mpd.MinimumUpdatePeriod = nil
 	mpd.SuggestedPresentationDelay = nil
-	mpd.MediaPresentationDuration = m.Seconds2DurPtr(mpdDurS)
+	mpd.MediaPresentationDuration = Ptr(m.Duration(time.Duration(mpdDurMS) * time.Millisecond))
 }
 
 // splitPeriod splits the single-period MPD into multiple periods given cfg.PeriodsPerHour
@@ -555,7 +697,7 @@ func setOffsetInAdaptationSet(cfg *ResponseConfig, as *m.AdaptationSetType) (ato
 	if ato != 0 {
 		as.SegmentTemplate.AvailabilityTimeOffset = m.FloatInf64(ato)
 	}
-	if !cfg.AvailabilityTimeCompleteFlag {
+	if !cfg.signaledATC() {
 		as.SegmentTemplate.AvailabilityTimeComplete = Ptr(false)
 		if cfg.getAvailabilityTimeOffsetS() > 0 {
 			as.SegmentTemplate.AvailabilityTimeOffset = m.FloatInf64(cfg.getAvailabilityTimeOffsetS())
@@ -572,7 +714,7 @@ func adjustAdaptationSetForTimelineTime(se segEntries, as *m.AdaptationSetType)
 	}
 	as.SegmentTemplate.StartNumber = nil
 	as.SegmentTemplate.Duration = nil
-	as.SegmentTemplate.Media = strings.Replace(as.SegmentTemplate.Media, "$Number$", "$Time$", -1)
+	as.SegmentTemplate.Media = segtemplate.Rename(as.SegmentTemplate.Media, "Number", "Time")
 	as.SegmentTemplate.Timescale = Ptr(se.mediaTimescale)
 	as.SegmentTemplate.SegmentTimeline.S = se.entries
 	return nil
@@ -584,7 +726,7 @@ func adjustAdaptationSetForTimelineNr(se segEntries, as *m.AdaptationSetType) er
 	}
 	as.SegmentTemplate.StartNumber = nil
 	as.SegmentTemplate.Duration = nil
-	as.SegmentTemplate.Media = strings.Replace(as.SegmentTemplate.Media, "$Time$", "$Number$", -1)
+	as.SegmentTemplate.Media = segtemplate.Rename(as.SegmentTemplate.Media, "Time", "Number")
 	as.SegmentTemplate.Timescale = Ptr(se.mediaTimescale)
 	as.SegmentTemplate.SegmentTimeline.S = se.entries
 
@@ -614,7 +756,10 @@ func adjustAdaptationSetForSegmentNumber(cfg *ResponseConfig, a *asset, as *m.Ad
 		startNr := Ptr(uint32(*cfg.StartNr))
 		as.SegmentTemplate.StartNumber = startNr
 	}
-	as.SegmentTemplate.Media = strings.Replace(as.SegmentTemplate.Media, "$Time$", "$Number$", -1)
+	if cfg.EndNr != nil && as.ContentType != "image" {
+		as.SegmentTemplate.EndNumber = Ptr(uint32(*cfg.EndNr))
+	}
+	as.SegmentTemplate.Media = segtemplate.Rename(as.SegmentTemplate.Media, "Time", "Number")
 	return nil
 }
 
@@ -681,7 +826,18 @@ func addTimeSubs(cfg *ResponseConfig, a *asset, period *m.Period, languages []st
 }
 
 // calcPublishTime calculates the last time there was a change in the manifest in seconds.
+// A configured publishTime cadence overrides this, so that publishTime only changes every n segments, or never.
 func calcPublishTime(cfg *ResponseConfig, lsi lastSegInfo) float64 {
+	if cfg.PublishTimeCadence != nil {
+		n := *cfg.PublishTimeCadence
+		if n == 0 {
+			return float64(cfg.StartTimeS)
+		}
+		if lsi.nr >= 0 {
+			lsi = lsi.earlier((lsi.nr + 1) % n)
+		}
+		return lastSegAvailTimeS(cfg, lsi)
+	}
 	switch cfg.liveMPDType() {
 	case segmentNumber:
 		// For single-period case, nothing change after startTime
@@ -709,14 +865,22 @@ func lastSegAvailTimeS(cfg *ResponseConfig, lsi lastSegInfo) float64 {
 }
 
 // addUTCTimings adds or keeps the UTCTiming elements to the MPD.
-func addUTCTimings(mpd *m.MPD, cfg *ResponseConfig) {
+// For a virtual clock or skewed Date, the HTTP methods use the time endpoint of this server
+// with the same time nowMS and skew.
+func addUTCTimings(mpd *m.MPD, cfg *ResponseConfig, nowMS int) {
+	httpServer := func(external string) string {
+		if _, ok := cfg.dateMS(nowMS); ok {
+			return cfg.timeURL(nowMS)
+		}
+		return external
+	}
 	switch {
 	case len(cfg.UTCTimingMethods) == 0:
 		// default if none is set. Use HTTP with ms precision.
 		mpd.UTCTimings = []*m.DescriptorType{
 			{
 				SchemeIdUri: UtcTimingHttpXSDateScheme,
-				Value:       UtcTimingXSDateHttpServerMS,
+				Value:       httpServer(UtcTimingXSDateHttpServerMS),
 			},
 		}
 		return
@@ -745,27 +909,27 @@ func addUTCTimings(mpd *m.MPD, cfg *ResponseConfig) {
 			case UtcTimingHttpXSDate:
 				ut = &m.DescriptorType{
 					SchemeIdUri: UtcTimingHttpXSDateScheme,
-					Value:       UtcTimingXSDateHttpServer,
+					Value:       httpServer(UtcTimingXSDateHttpServer),
 				}
 			case UtcTimingHttpXSDateMs:
 				ut = &m.DescriptorType{
 					SchemeIdUri: UtcTimingHttpXSDateScheme,
-					Value:       UtcTimingXSDateHttpServerMS,
+					Value:       httpServer(UtcTimingXSDateHttpServerMS),
 				}
 			case UtcTimingHttpISO:
 				ut = &m.DescriptorType{
 					SchemeIdUri: UtcTimingHttpISOScheme,
-					Value:       UtcTimingISOHttpServer,
+					Value:       httpServer(UtcTimingISOHttpServer),
 				}
 			case UtcTimingHttpISOMs:
 				ut = &m.DescriptorType{
 					SchemeIdUri: UtcTimingHttpISOScheme,
-					Value:       UtcTimingISOHttpServerMS,
+					Value:       httpServer(UtcTimingISOHttpServerMS),
 				}
 			case UtcTimingHttpHead:
 				ut = &m.DescriptorType{
 					SchemeIdUri: UtcTimingHttpHeadScheme,
-					Value:       fmt.Sprintf("%s%s", cfg.Host, UtcTimingHeadAsset),
+					Value:       httpServer(fmt.Sprintf("%s%s", cfg.Host, UtcTimingHeadAsset)),
 				}
 
 			case UtcTimingNone:
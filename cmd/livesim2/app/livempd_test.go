@@ -5,6 +5,7 @@
 package app
 
 import (
+	"io"
 	"log/slog"
 	"math"
 	"os"
@@ -1037,3 +1038,114 @@ func TestEndNumberRemovedFromMPD(t *testing.T) {
 		assert.Nil(t, stl.EndNumber)
 	}
 }
+
+func TestEndNumber(t *testing.T) {
+	vodFS := os.DirFS("testdata/assets")
+	am := newAssetMgr(vodFS, "", false)
+	err := am.discoverAssets(slog.Default())
+	require.NoError(t, err)
+	asset, ok := am.findAsset("testpic_2s")
+	require.True(t, ok)
+	cfg := NewResponseConfig()
+	cfg.EndNr = Ptr(10)
+	require.NoError(t, verifyAndFillConfig(cfg, 0))
+
+	// Segment 10 is the 11th segment and ends at 22s
+	liveMPD, err := LiveMPD(asset, "Manifest.mpd", cfg, nil, 20_000)
+	require.NoError(t, err)
+	require.Equal(t, "dynamic", *liveMPD.Type)
+	for _, as := range liveMPD.Periods[0].AdaptationSets {
+		require.Equal(t, uint32(10), *as.SegmentTemplate.EndNumber)
+	}
+	liveMPD, err = LiveMPD(asset, "Manifest.mpd", cfg, nil, 30_000)
+	require.NoError(t, err)
+	require.Equal(t, "static", *liveMPD.Type)
+	require.Equal(t, m.Duration(22*time.Second), *liveMPD.MediaPresentationDuration)
+	for _, as := range liveMPD.Periods[0].AdaptationSets {
+		require.Equal(t, uint32(10), *as.SegmentTemplate.EndNumber)
+	}
+
+	_, err = findSegMeta(asset, cfg, "V300/10.m4s", 30_000)
+	require.NoError(t, err)
+	_, err = findSegMeta(asset, cfg, "A48/10.m4s", 30_000)
+	require.NoError(t, err)
+	_, err = findSegMeta(asset, cfg, "V300/11.m4s", 30_000)
+	require.ErrorIs(t, err, errNotFound)
+	_, err = findSegMeta(asset, cfg, "A48/11.m4s", 30_000)
+	require.ErrorIs(t, err, errNotFound)
+
+	cfg.SegTimelineFlag = true
+	require.Error(t, verifyAndFillConfig(cfg, 0))
+	cfg.SegTimelineFlag = false
+	cfg.StartNr = Ptr(11)
+	require.Error(t, verifyAndFillConfig(cfg, 0))
+}
+
+func TestEndNrTimeMSVariableSegments(t *testing.T) {
+	// Segments of 1.92s, 2.08s, and 2s at timescale 90000 in a 6s loop
+	rep := &RepData{MediaTimescale: 90000, Segments: []Segment{
+		{StartTime: 0, EndTime: 172800, Nr: 0},
+		{StartTime: 172800, EndTime: 360000, Nr: 1},
+		{StartTime: 360000, EndTime: 540000, Nr: 2},
+	}}
+	a := &asset{SegmentDurMS: 2000, LoopDurMS: 6000, refRep: rep}
+	cases := []struct {
+		startNr, endNr int
+		wantedMS       int
+	}{
+		{startNr: 0, endNr: 0, wantedMS: 1920},
+		{startNr: 0, endNr: 1, wantedMS: 4000},
+		{startNr: 0, endNr: 3, wantedMS: 7920},
+		{startNr: 1, endNr: 5, wantedMS: 10000},
+	}
+	for _, tc := range cases {
+		cfg := NewResponseConfig()
+		cfg.StartNr = Ptr(tc.startNr)
+		cfg.EndNr = Ptr(tc.endNr)
+		endMS, ok := cfg.endNrTimeMS(a)
+		require.True(t, ok)
+		require.Equal(t, tc.wantedMS, endMS, "endNr %d", tc.endNr)
+	}
+}
+
+func BenchmarkLiveMPD(b *testing.B) {
+	vodFS := os.DirFS("testdata/assets")
+	am := newAssetMgr(vodFS, "", false)
+	err := am.discoverAssets(slog.Default())
+	require.NoError(b, err)
+	asset, ok := am.findAsset("testpic_2s")
+	require.True(b, ok)
+	nowMS := 1_000_000
+
+	cases := []struct {
+		desc   string
+		config func(cfg *ResponseConfig)
+	}{
+		{desc: "Number", config: func(cfg *ResponseConfig) {}},
+		{desc: "TimelineTime", config: func(cfg *ResponseConfig) { cfg.SegTimelineFlag = true }},
+		{desc: "TimelineNumber", config: func(cfg *ResponseConfig) { cfg.SegTimelineNrFlag = true }},
+		{desc: "TimelineTimeLowLatency", config: func(cfg *ResponseConfig) {
+			cfg.SegTimelineFlag = true
+			cfg.AvailabilityTimeOffsetS = 1.5
+			cfg.AvailabilityTimeCompleteFlag = false
+			cfg.ChunkDurS = Ptr(0.5)
+			cfg.LatencyTargetMS = Ptr(3500)
+		}},
+	}
+	for _, c := range cases {
+		b.Run(c.desc, func(b *testing.B) {
+			cfg := NewResponseConfig()
+			c.config(cfg)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				liveMPD, err := LiveMPD(asset, "Manifest.mpd", cfg, nil, nowMS+i)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := liveMPD.Write(io.Discard, "  ", true); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
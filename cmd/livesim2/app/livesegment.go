@@ -95,13 +95,89 @@ func genLiveSegment(log *slog.Logger, vodFS fs.FS, a *asset, cfg *ResponseConfig
 			startTime := uint64(meta.newTime)
 			endTime := startTime + uint64(meta.newDur)
 			timescale := uint64(meta.timescale)
-			emsg, err := scte35.CreateEmsgAhead(startTime, endTime, timescale, *cfg.SCTE35PerMinute)
+			st, err := cfg.scte35Type()
 			if err != nil {
 				return so, fmt.Errorf("insertSCTE35: %w", err)
 			}
+			emsgs, err := scte35.CreateEmsgsAhead(startTime, endTime, timescale, *cfg.SCTE35PerMinute, st)
+			if err != nil {
+				return so, fmt.Errorf("insertSCTE35: %w", err)
+			}
+			for _, emsg := range emsgs {
+				seg.Fragments[0].AddEmsg(emsg)
+				log.Debug("added SCTE-35 emsg message", "asset", a.AssetPath, "segment", segmentPart, "type", st)
+			}
+		}
+		if cfg.Slate != nil && cfg.Slate.Signal && contentType == "video" {
+			startTime := uint64(meta.newTime)
+			emsg := slateEmsg(cfg.Slate, startTime, startTime+uint64(meta.newDur), uint64(meta.timescale), cfg.StartTimeS)
 			if emsg != nil {
 				seg.Fragments[0].AddEmsg(emsg)
-				log.Debug("added SCTE-35 emsg message", "asset", a.AssetPath, "segment", segmentPart)
+				log.Debug("added slate emsg message", "asset", a.AssetPath, "segment", segmentPart)
+			}
+		}
+		if cfg.Blackout != nil && contentType == "video" {
+			startTime := uint64(meta.newTime)
+			for _, emsg := range blackoutEmsgs(cfg.Blackout, startTime, startTime+uint64(meta.newDur), uint64(meta.timescale), cfg.StartTimeS) {
+				seg.Fragments[0].AddEmsg(emsg)
+				log.Debug("added blackout emsg message", "asset", a.AssetPath, "segment", segmentPart)
+			}
+		}
+		if len(cfg.Events) > 0 && contentType == "video" {
+			startTime := uint64(meta.newTime)
+			for _, emsg := range sessionEventEmsgs(cfg.Events, startTime, startTime+uint64(meta.newDur), uint64(meta.timescale), cfg.StartTimeS) {
+				seg.Fragments[0].AddEmsg(emsg)
+				log.Debug("added session event emsg message", "asset", a.AssetPath, "segment", segmentPart)
+			}
+		}
+		if cfg.Programs != nil && cfg.Programs.Inband && contentType == "video" {
+			startTime := uint64(meta.newTime)
+			for _, emsg := range programEmsgs(cfg.Programs, startTime, startTime+uint64(meta.newDur), uint64(meta.timescale), cfg.StartTimeS) {
+				seg.Fragments[0].AddEmsg(emsg)
+				log.Debug("added program emsg message", "asset", a.AssetPath, "segment", segmentPart)
+			}
+		}
+		if cfg.ID3IntervalS != nil && contentType == "video" {
+			startTime := uint64(meta.newTime)
+			for _, emsg := range id3Emsgs(*cfg.ID3IntervalS, startTime, startTime+uint64(meta.newDur), uint64(meta.timescale), cfg.StartTimeS) {
+				seg.Fragments[0].AddEmsg(emsg)
+				log.Debug("added ID3 emsg message", "asset", a.AssetPath, "segment", segmentPart)
+			}
+		}
+		if cfg.MPDExpiryEvents != nil && contentType == "video" {
+			segNr := int(meta.newNr) - cfg.getStartNr()
+			emsg := mpdExpiryEmsg(cfg, *cfg.MPDExpiryEvents, segNr, uint64(meta.newTime), uint64(meta.newDur), uint64(meta.timescale))
+			if emsg != nil {
+				seg.Fragments[0].AddEmsg(emsg)
+				log.Debug("added MPD expiry emsg message", "asset", a.AssetPath, "segment", segmentPart)
+			}
+		}
+		if cfg.CCStripFlag && contentType == "video" {
+			err = stripCaptions(seg, getTrex(meta.rep.initSeg), meta.rep.Codecs)
+			if err != nil {
+				return so, fmt.Errorf("stripCaptions: %w", err)
+			}
+		}
+		if cfg.TimecodeFlag && contentType == "video" {
+			clock, err := newSubsClock(cfg)
+			if err != nil {
+				return so, err
+			}
+			err = addTimecodes(seg, getTrex(meta.rep.initSeg), meta.rep.Codecs, uint64(meta.timescale), clock, cfg.StartTimeS)
+			if err != nil {
+				return so, fmt.Errorf("addTimecodes: %w", err)
+			}
+		}
+		if rescaleRep(cfg, meta.rep) {
+			err = rescaleSegment(seg, getTrex(meta.rep.initSeg), uint64(meta.timescale), uint64(*cfg.Timescale))
+			if err != nil {
+				return so, fmt.Errorf("rescaleSegment: %w", err)
+			}
+		}
+		if cfg.SizeVariance != nil && !meta.rep.PreEncrypted {
+			err = trimSegment(cfg.SizeVariance, meta.rep.ID, meta.newNr, seg, getTrex(meta.rep.initSeg))
+			if err != nil {
+				return so, fmt.Errorf("trimSegment: %w", err)
 			}
 		}
 		outSeg.seg = seg
@@ -155,6 +231,19 @@ func CheckTimeValidity(availTimeS, nowS, timeShiftBufferDepthS, availabilityTime
 	return nil
 }
 
+// maxEarlyMS is the maximal early availability margin of the early URL parameter
+const maxEarlyMS = 60_000
+
+// publishedAvailTimeS returns the time when a segment with nominal availability time availTimeS is published,
+// including burst publication and early availability. The MPD signaling is not affected.
+func (rc *ResponseConfig) publishedAvailTimeS(availTimeS float64) float64 {
+	availTimeS = rc.burstAvailTimeS(availTimeS)
+	if rc.EarlyMS != nil {
+		availTimeS -= float64(*rc.EarlyMS) * 0.001
+	}
+	return availTimeS
+}
+
 // segMeta provides meta data information about a segment.
 // For audio it may be a combination of several segments and sampleNrOffset may be non-zero.
 type segMeta struct {
@@ -189,7 +278,7 @@ func findSegMetaFromTime(a *asset, rep *RepData, time uint64, cfg *ResponseConfi
 	}
 
 	// Check interval validity
-	segAvailTimeS := float64(int(seg.EndTime)+wrapTime+mediaRef) / float64(rep.MediaTimescale)
+	segAvailTimeS := cfg.publishedAvailTimeS(float64(int(seg.EndTime)+wrapTime+mediaRef) / float64(rep.MediaTimescale))
 	nowS := float64(nowMS) * 0.001
 	err := CheckTimeValidity(segAvailTimeS, nowS, float64(*cfg.TimeShiftBufferDepthS), cfg.getAvailabilityTimeOffsetS())
 	if err != nil {
@@ -252,7 +341,7 @@ func findRefSegMetaFromTime(a *asset, rep *RepData, time uint64, cfg *ResponseCo
 	dur := uint32(refRep.Segments[relNr].EndTime - refRep.Segments[relNr].StartTime)
 
 	// Check interval validity
-	segAvailTimeS := float64(refEndTime) / float64(refRep.MediaTimescale)
+	segAvailTimeS := cfg.publishedAvailTimeS(float64(refEndTime) / float64(refRep.MediaTimescale))
 	nowS := float64(nowMS) * 0.001
 	err := CheckTimeValidity(segAvailTimeS, nowS, float64(*cfg.TimeShiftBufferDepthS), cfg.getAvailabilityTimeOffsetS())
 	if err != nil {
@@ -284,7 +373,7 @@ func calcSegmentAvailabilityTime(a *asset, rep *RepData, nr uint32, cfg *Respons
 	mediaRef := cfg.StartTimeS * rep.MediaTimescale // TODO. Add period offset
 
 	// Check interval validity
-	segAvailTimeS := float64(int(seg.EndTime)+wrapTime+mediaRef) / float64(rep.MediaTimescale)
+	segAvailTimeS := cfg.publishedAvailTimeS(float64(int(seg.EndTime)+wrapTime+mediaRef) / float64(rep.MediaTimescale))
 	ato := cfg.getAvailabilityTimeOffsetS()
 	if ato == +math.Inf(1) {
 		return int64(cfg.StartTimeS) * 1000, nil
@@ -308,7 +397,7 @@ func findSegMetaFromNr(a *asset, rep *RepData, nr uint32, cfg *ResponseConfig, n
 	mediaRef := cfg.StartTimeS * rep.MediaTimescale // TODO. Add period offset
 
 	// Check interval validity
-	segAvailTimeS := float64(int(seg.EndTime)+wrapTime+mediaRef) / float64(rep.MediaTimescale)
+	segAvailTimeS := cfg.publishedAvailTimeS(float64(int(seg.EndTime)+wrapTime+mediaRef) / float64(rep.MediaTimescale))
 	nowS := float64(nowMS) * 0.001
 	err := CheckTimeValidity(segAvailTimeS, nowS, float64(*cfg.TimeShiftBufferDepthS), cfg.getAvailabilityTimeOffsetS())
 	if err != nil {
@@ -360,12 +449,18 @@ func writeInitSegment(log *slog.Logger, w http.ResponseWriter, cfg *ResponseConf
 func matchInit(segmentPart string, cfg *ResponseConfig, drmCfg *drm.DrmConfig, a *asset) (initMatch, error) {
 	var im initMatch
 	for _, rep := range a.Reps {
+		if rep.encData != nil && isClearInit(cfg, rep, segmentPart) {
+			im.init = rep.initBytes
+			im.isInit = true
+			im.rep = rep
+			return rescaledInit(im, cfg)
+		}
 		if segmentPart == rep.InitURI {
 			im.init = rep.initBytes
 			if rep.encData == nil { // pre-encrypted or subtitle track
 				im.isInit = true
 				im.rep = rep
-				return im, nil
+				return rescaledInit(im, cfg)
 			}
 			if cfg.DRM != "" {
 				switch cfg.DRM {
@@ -396,15 +491,38 @@ func matchInit(segmentPart string, cfg *ResponseConfig, drmCfg *drm.DrmConfig, a
 					}
 					im.init = sw.Bytes()
 				}
+				if cfg.PSSH != nil {
+					psshs, err := psshBoxes(cfg.PSSH, cfg, drmCfg, rep)
+					if err != nil {
+						return im, fmt.Errorf("psshBoxes: %w", err)
+					}
+					im.init, err = replacePsshBoxes(im.init, psshs)
+					if err != nil {
+						return im, fmt.Errorf("replacePsshBoxes: %w", err)
+					}
+				}
 			}
 			im.rep = rep
 			im.isInit = true
-			return im, nil
+			return rescaledInit(im, cfg)
 		}
 	}
 	return im, nil
 }
 
+// rescaledInit changes the timescale of the matched init segment if requested by cfg.
+func rescaledInit(im initMatch, cfg *ResponseConfig) (initMatch, error) {
+	if !rescaleRep(cfg, im.rep) {
+		return im, nil
+	}
+	var err error
+	im.init, err = rescaleInit(im.init, uint64(im.rep.MediaTimescale), uint64(*cfg.Timescale))
+	if err != nil {
+		return im, fmt.Errorf("rescaleInit: %w", err)
+	}
+	return im, nil
+}
+
 func writeLiveSegment(log *slog.Logger, w http.ResponseWriter, cfg *ResponseConfig, drmCfg *drm.DrmConfig, vodFS fs.FS,
 	a *asset, segmentPart string, nowMS int, tt *template.Template, isLast bool) error {
 	log.Debug("writeLiveSegment", "segmentPart", segmentPart)
@@ -418,13 +536,26 @@ func writeLiveSegment(log *slog.Logger, w http.ResponseWriter, cfg *ResponseConf
 	}
 	var data []byte
 	if outSeg.seg != nil {
-		if cfg.DRM != "" {
+		rep := outSeg.meta.rep
+		if cfg.LatencyProbeFlag && latencyProbeRep(rep) {
+			addLatencyProbe(outSeg.seg.Fragments[0], a.AssetPath, outSeg.meta.newNr, outTimescale(cfg, rep),
+				cfg.StartTimeS, rep.ContentType == "video")
+		}
+		offsetSegmentTimes(outSeg.seg, repLargeTfdtOffset(cfg, rep), outTimescale(cfg, rep))
+		if encryptSegment(cfg, rep, outSeg.meta.newTime, outSeg.meta.timescale) {
 			frags := outSeg.seg.Fragments
 			err := encryptFrags(log, cfg, drmCfg, outSeg.meta.rep, frags)
 			if err != nil {
 				return fmt.Errorf("encryptFrags: %w", err)
 			}
 		}
+		if cfg.SizeVariance != nil {
+			padSegment(cfg.SizeVariance, rep.ID, outSeg.meta.newNr, outSeg.seg.Fragments, outSeg.seg.Sidx)
+		}
+		if cfg.LadderRungKbps > 0 && cfg.Ladder.Pad {
+			durS := float64(outSeg.meta.newDur) / float64(outSeg.meta.timescale)
+			padToBitrate(outSeg.seg.Fragments, outSeg.seg.Sidx, outSeg.seg.Size(), cfg.LadderRungKbps, durS)
+		}
 		sw := bits.NewFixedSliceWriter(int(outSeg.seg.Size()))
 		err = outSeg.seg.EncodeSW(sw)
 		if err != nil {
@@ -507,13 +638,20 @@ func findRepAndSegmentID(a *asset, segmentPart string) (r *RepData, segID int, e
 		if mParts == nil {
 			continue
 		}
-		if len(mParts) != 2 {
+		idIdx := rep.mediaRegexp.SubexpIndex(tmplSegIDGroup)
+		if idIdx < 0 {
 			return nil, -1, fmt.Errorf("bad segment match")
 		}
-		segID, err = strconv.Atoi(mParts[1])
+		segID, err = strconv.Atoi(mParts[idIdx])
 		if err != nil {
 			return nil, -1, err
 		}
+		// Segments are not split into sub-segments, so only $SubNumber$ 1 exists
+		if subIdx := rep.mediaRegexp.SubexpIndex(tmplSubNrGroup); subIdx >= 0 {
+			if subNr, err := strconv.Atoi(mParts[subIdx]); err != nil || subNr != 1 {
+				return nil, -1, errNotFound
+			}
+		}
 		return rep, segID, nil
 	}
 	return nil, -1, errNotFound
@@ -526,6 +664,13 @@ func createOutSeg(vodFS fs.FS, a *asset, cfg *ResponseConfig, segmentPart string
 	if err != nil {
 		return so, fmt.Errorf("findRepAndSegmentID: %w", err)
 	}
+	segID, err = removeLargeTfdtOffset(cfg, rep, segmentPart, segID)
+	if err != nil {
+		return so, err
+	}
+	if cfg.EndNr != nil && !isImage(segmentPart) && segID > *cfg.EndNr {
+		return so, errNotFound
+	}
 
 	if rep.ContentType == "audio" && !rep.PreEncrypted {
 		so, err = createAudioSegment(vodFS, a, cfg, segmentPart, nowMS, rep, segID)
@@ -544,6 +689,12 @@ func createOutSeg(vodFS fs.FS, a *asset, cfg *ResponseConfig, segmentPart string
 		so.meta, err = findSegMetaFromNr(a, rep, nr, cfg, nowMS)
 	case timeLineTime:
 		time := uint64(segID)
+		if rescaleRep(cfg, rep) {
+			time, err = unscaleSegmentTime(a, rep, time, uint64(*cfg.Timescale))
+			if err != nil {
+				return so, err
+			}
+		}
 		so.meta, err = findSegMetaFromTime(a, rep, time, cfg, nowMS)
 	default:
 		return so, fmt.Errorf("unknown liveMPD type")
@@ -551,8 +702,13 @@ func createOutSeg(vodFS fs.FS, a *asset, cfg *ResponseConfig, segmentPart string
 	if err != nil {
 		return so, err
 	}
-	segPath := path.Join(a.AssetPath, replaceTimeAndNr(rep.MediaURI, so.meta.origTime, so.meta.origNr))
-	so.data, err = fs.ReadFile(vodFS, segPath)
+	if cfg.Slate != nil && rep.ContentType == "video" && cfg.Slate.active(so.meta.newTime, so.meta.timescale, cfg.StartTimeS) {
+		useSlateSegment(&so.meta)
+	}
+	if cfg.Blackout != nil && cfg.Blackout.applies(rep) && cfg.Blackout.active(so.meta.newTime, so.meta.timescale, cfg.StartTimeS) {
+		useSlateSegment(&so.meta)
+	}
+	so.data, err = rep.readSegmentData(vodFS, a.AssetPath, so.meta.origTime, so.meta.origNr)
 	if err != nil {
 		return so, fmt.Errorf("read segment: %w", err)
 	}
@@ -565,6 +721,13 @@ func findSegMeta(a *asset, cfg *ResponseConfig, segmentPart string, nowMS int) (
 	if err != nil {
 		return sm, fmt.Errorf("findRepAndSegmentID: %w", err)
 	}
+	segID, err = removeLargeTfdtOffset(cfg, rep, segmentPart, segID)
+	if err != nil {
+		return sm, err
+	}
+	if cfg.EndNr != nil && !isImage(segmentPart) && segID > *cfg.EndNr {
+		return sm, errNotFound
+	}
 
 	if rep.ContentType == "audio" {
 		sm, err := findRefSegMeta(a, cfg, segmentPart, nowMS, rep, segID)
@@ -582,6 +745,12 @@ func findSegMeta(a *asset, cfg *ResponseConfig, segmentPart string, nowMS int) (
 			sm, err = findSegMetaFromNr(a, rep, nr, cfg, nowMS)
 		case timeLineTime:
 			time := uint64(segID)
+			if rescaleRep(cfg, rep) {
+				time, err = unscaleSegmentTime(a, rep, time, uint64(*cfg.Timescale))
+				if err != nil {
+					return sm, err
+				}
+			}
 			sm, err = findSegMetaFromTime(a, rep, time, cfg, nowMS)
 		default:
 			return sm, fmt.Errorf("unknown liveMPD type")
@@ -607,6 +776,12 @@ func createAudioSegment(vodFS fs.FS, a *asset, cfg *ResponseConfig, segmentPart
 		refMeta.newTime+uint64(refMeta.newDur),
 		uint64(refRep.duration()),
 		refTimescale, rep)
+	if cfg.Slate != nil && cfg.Slate.active(recipe.startTime, uint32(rep.MediaTimescale), cfg.StartTimeS) {
+		useSlateAudio(&recipe)
+	}
+	if cfg.Blackout != nil && cfg.Blackout.applies(rep) && cfg.Blackout.active(recipe.startTime, uint32(rep.MediaTimescale), cfg.StartTimeS) {
+		useSlateAudio(&recipe)
+	}
 	var so segOut
 	so.seg, err = createAudioSeg(vodFS, a, recipe)
 	if err != nil {
@@ -676,12 +851,25 @@ func writeChunkedSegment(ctx context.Context, log *slog.Logger, w http.ResponseW
 	// The rest are returned HTTP chunks as time passes.
 	// In general, we should extract all the samples and build a new one with the right fragment duration.
 	// That fragment/chunk duration is segment_duration-availabilityTimeOffset.
-	chunkDur := (a.SegmentDurMS - int(cfg.AvailabilityTimeOffsetS*1000)) * int(rep.MediaTimescale) / 1000
+	chunkDur := llChunkDur(cfg, a.SegmentDurMS, int(rep.MediaTimescale))
 	chunks, err := chunkSegment(rep.initSeg, seg, so.meta, chunkDur)
 	if err != nil {
 		return fmt.Errorf("chunkSegment: %w", err)
 	}
-	if cfg.DRM != "" {
+	if cfg.LatencyProbeFlag && latencyProbeRep(rep) {
+		for i, chk := range chunks {
+			addLatencyProbe(chk.frag, a.AssetPath, so.meta.newNr, outTimescale(cfg, rep),
+				cfg.StartTimeS, i == 0 && rep.ContentType == "video")
+		}
+	}
+	if offset := repLargeTfdtOffset(cfg, rep); offset > 0 {
+		frags := make([]*mp4.Fragment, len(chunks))
+		for i, chk := range chunks {
+			frags[i] = chk.frag
+		}
+		offsetFragTimes(frags, offset, outTimescale(cfg, rep))
+	}
+	if encryptSegment(cfg, rep, so.meta.newTime, so.meta.timescale) {
 		frags := make([]*mp4.Fragment, len(chunks))
 		for i, chk := range chunks {
 			frags[i] = chk.frag
@@ -691,11 +879,36 @@ func writeChunkedSegment(ctx context.Context, log *slog.Logger, w http.ResponseW
 			return fmt.Errorf("encryptFrags: %w", err)
 		}
 	}
+	if cfg.SizeVariance != nil {
+		frags := make([]*mp4.Fragment, len(chunks))
+		for i, chk := range chunks {
+			frags[i] = chk.frag
+		}
+		padSegment(cfg.SizeVariance, rep.ID, so.meta.newNr, frags, nil)
+	}
+	if cfg.LadderRungKbps > 0 && cfg.Ladder.Pad {
+		frags := make([]*mp4.Fragment, len(chunks))
+		var size uint64
+		for i, chk := range chunks {
+			frags[i] = chk.frag
+			size += chk.frag.Size()
+			if chk.styp != nil {
+				size += chk.styp.Size()
+			}
+		}
+		padToBitrate(frags, nil, size, cfg.LadderRungKbps, float64(so.meta.newDur)/float64(so.meta.timescale))
+	}
 
 	startUnixMS := unixMS()
-	chunkAvailTime := int(so.meta.newTime) + cfg.StartTimeS*int(rep.MediaTimescale)
-	for _, chk := range chunks {
-		chunkAvailTime += int(chk.dur)
+	segStartTime := int(so.meta.newTime) + cfg.StartTimeS*int(rep.MediaTimescale)
+	emitEnds := chunkEmitEnds(cfg, rep, so.meta.newNr, chunks)
+	var tw *trailerWriter
+	if len(cfg.Trailers) > 0 {
+		tw = newTrailerWriter(w, cfg.Trailers)
+		w = tw
+	}
+	for i, chk := range chunks {
+		chunkAvailTime := segStartTime + emitEnds[i]
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
@@ -716,12 +929,19 @@ func writeChunkedSegment(ctx context.Context, log *slog.Logger, w http.ResponseW
 			continue
 		}
 		sleepMS := chunkAvailMS - nowUpdateMS
+		addServerTimingWait(ctx, time.Duration(sleepMS)*time.Millisecond)
 		time.Sleep(time.Duration(sleepMS * 1_000_000))
 		err = writeChunk(w, chk)
 		if err != nil {
 			return fmt.Errorf("writeChunk: %w", err)
 		}
 	}
+	if tw != nil {
+		tw.finish(len(chunks))
+	}
+	if cfg.ChunkAbortFlag {
+		return errChunkAbort
+	}
 	return nil
 }
 
@@ -728,3 +728,66 @@ func TestMehdBoxRemovedFromInitSegment(t *testing.T) {
 	require.NotNil(t, initSeg)
 	require.Nil(t, initSeg.Moov.Mvex.Mehd)
 }
+
+func BenchmarkWriteLiveSegment(b *testing.B) {
+	vodFS := os.DirFS("testdata/assets")
+	am := newAssetMgr(vodFS, "", false)
+	log := slog.Default()
+	err := am.discoverAssets(log)
+	require.NoError(b, err)
+	asset, ok := am.findAsset("testpic_2s")
+	require.True(b, ok)
+	nowMS := 1_000_000
+
+	cases := []struct {
+		desc    string
+		segment string
+		config  func(cfg *ResponseConfig)
+	}{
+		{desc: "video", segment: "V300/490.m4s", config: func(cfg *ResponseConfig) {}},
+		{desc: "audio", segment: "A48/490.m4s", config: func(cfg *ResponseConfig) {}},
+		{desc: "videoTimeline", segment: "V300/88200000.m4s", config: func(cfg *ResponseConfig) { cfg.SegTimelineFlag = true }},
+		{desc: "videoEncrypted", segment: "V300/490.m4s", config: func(cfg *ResponseConfig) { cfg.DRM = "eccp-cbcs" }},
+	}
+	for _, c := range cases {
+		b.Run(c.desc, func(b *testing.B) {
+			cfg := NewResponseConfig()
+			c.config(cfg)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				rr := httptest.NewRecorder()
+				err := writeLiveSegment(log, rr, cfg, nil, vodFS, asset, c.segment, nowMS, nil, false /* isLast */)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestEarlyAvailability(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	// Segment 48 ends at 98s
+	cases := []struct {
+		url          string
+		wantedStatus int
+	}{
+		{url: "/livesim2/testpic_2s/V300/48.m4s?nowMS=97000", wantedStatus: http.StatusTooEarly},
+		{url: "/livesim2/early_1500/testpic_2s/V300/48.m4s?nowMS=97000", wantedStatus: http.StatusOK},
+		{url: "/livesim2/early_1500/testpic_2s/V300/48.m4s?nowMS=96499", wantedStatus: http.StatusTooEarly},
+		{url: "/livesim2/segtimeline_1/early_1500/testpic_2s/V300/8640000.m4s?nowMS=97000", wantedStatus: http.StatusOK},
+		{url: "/livesim2/burst_6/early_1000/testpic_2s/V300/48.m4s?nowMS=101000", wantedStatus: http.StatusOK},
+		{url: "/livesim2/burst_6/early_1000/testpic_2s/V300/48.m4s?nowMS=100999", wantedStatus: http.StatusTooEarly},
+		{url: "/livesim2/early_0/testpic_2s/Manifest.mpd", wantedStatus: http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		resp, _ := testFullRequest(t, ts, "GET", c.url, nil)
+		require.Equal(t, c.wantedStatus, resp.StatusCode, c.url)
+	}
+
+	// The MPD is not changed
+	_, refMPD := testFullRequest(t, ts, "GET", "/livesim2/testpic_2s/Manifest.mpd?nowMS=97000", nil)
+	_, earlyMPD := testFullRequest(t, ts, "GET", "/livesim2/early_1500/testpic_2s/Manifest.mpd?nowMS=97000", nil)
+	require.Equal(t, string(refMPD), string(earlyMPD))
+}
@@ -0,0 +1,61 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"math"
+)
+
+// signaledATC returns the availabilityTimeComplete value to signal in the MPD.
+// It is set explicitly by the atc parameter, and otherwise follows the segment delivery mode.
+func (rc *ResponseConfig) signaledATC() bool {
+	if rc.SignaledATC != nil {
+		return *rc.SignaledATC
+	}
+	return rc.AvailabilityTimeCompleteFlag
+}
+
+// verifyLLSignaling checks that the signaled availabilityTimeComplete matches the delivery mode.
+// Inconsistent combinations are only allowed as test mode with llbroken.
+func verifyLLSignaling(cfg *ResponseConfig) error {
+	if cfg.SignaledATC == nil || cfg.LLBrokenFlag {
+		return nil
+	}
+	switch {
+	case *cfg.SignaledATC && cfg.ChunkDurS != nil:
+		return newReasonError(reasonBadCombination,
+			fmt.Errorf("atc_1 signals complete segments, but chunkdur delivers chunks (allow with llbroken_1)"))
+	case !*cfg.SignaledATC && cfg.ChunkDurS == nil:
+		return newReasonError(reasonBadCombination,
+			fmt.Errorf("atc_0 requires chunked delivery with chunkdur (allow with llbroken_1)"))
+	}
+	return nil
+}
+
+// verifyLLAssetSignaling checks the availabilityTimeOffset against the segment duration of the asset.
+// An offset larger than the segment duration signals segments before they start,
+// which is only allowed as test mode with llbroken.
+func verifyLLAssetSignaling(a *asset, cfg *ResponseConfig) error {
+	ato := cfg.getAvailabilityTimeOffsetS()
+	if cfg.LLBrokenFlag || math.IsInf(ato, 1) {
+		return nil
+	}
+	if int(ato*1000) > a.SegmentDurMS {
+		return fmt.Errorf("ato %gs larger than segment duration %dms (allow with llbroken_1)", ato, a.SegmentDurMS)
+	}
+	return nil
+}
+
+// llChunkDur returns the chunk duration in media timescale for chunked delivery.
+// It is segment duration minus availabilityTimeOffset, but the full segment if the offset
+// is not smaller than the segment duration, so that the last chunk is held until produced.
+func llChunkDur(cfg *ResponseConfig, segDurMS, timescale int) int {
+	chunkDurMS := segDurMS - int(cfg.AvailabilityTimeOffsetS*1000)
+	if chunkDurMS <= 0 {
+		chunkDurMS = segDurMS
+	}
+	return chunkDurMS * timescale / 1000
+}
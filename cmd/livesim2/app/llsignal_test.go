@@ -0,0 +1,36 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLLChunkDur(t *testing.T) {
+	testCases := []struct {
+		atoS     float64
+		wantedMS int
+	}{
+		{atoS: 0, wantedMS: 2000},
+		{atoS: 1.5, wantedMS: 500},
+		{atoS: 2, wantedMS: 2000},
+		{atoS: 3, wantedMS: 2000},
+	}
+	for _, tc := range testCases {
+		cfg := ResponseConfig{AvailabilityTimeOffsetS: tc.atoS}
+		require.Equal(t, tc.wantedMS*90, llChunkDur(&cfg, 2000, 90_000), "ato=%g", tc.atoS)
+	}
+}
+
+func TestVerifyLLSignaling(t *testing.T) {
+	chunkDur := 0.5
+	require.NoError(t, verifyLLSignaling(&ResponseConfig{SignaledATC: Ptr(false), ChunkDurS: &chunkDur}))
+	require.NoError(t, verifyLLSignaling(&ResponseConfig{SignaledATC: Ptr(true)}))
+	require.Error(t, verifyLLSignaling(&ResponseConfig{SignaledATC: Ptr(true), ChunkDurS: &chunkDur}))
+	require.Error(t, verifyLLSignaling(&ResponseConfig{SignaledATC: Ptr(false)}))
+	require.NoError(t, verifyLLSignaling(&ResponseConfig{SignaledATC: Ptr(false), LLBrokenFlag: true}))
+}
@@ -0,0 +1,277 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/spf13/pflag"
+)
+
+const (
+	loadKindMPD     = "mpd"
+	loadKindInit    = "init"
+	loadKindSegment = "segment"
+	// loadDefaultSegDur is used when no segment duration can be found in the MPD
+	loadDefaultSegDur = 2 * time.Second
+)
+
+// LoadOptions configures load generation with simulated players.
+type LoadOptions struct {
+	MPDURL   string
+	Sessions int
+	Duration time.Duration
+	RampUp   time.Duration
+	Timeout  time.Duration
+}
+
+// ParseLoadArgs parses the arguments after the load subcommand.
+func ParseLoadArgs(args []string) (*LoadOptions, error) {
+	o := LoadOptions{}
+	f := pflag.NewFlagSet("load", pflag.ContinueOnError)
+	f.StringVar(&o.MPDURL, "mpd", "", "URL of live MPD to play (required)")
+	f.IntVar(&o.Sessions, "sessions", 10, "number of concurrent simulated players")
+	f.DurationVar(&o.Duration, "duration", time.Minute, "duration of the test")
+	f.DurationVar(&o.RampUp, "rampup", 5*time.Second, "time over which player starts are spread")
+	f.DurationVar(&o.Timeout, "timeout", 10*time.Second, "timeout for each HTTP request")
+	f.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: livesim2 load [options]\n\n")
+		fmt.Fprintf(os.Stderr, "Simulate concurrent DASH players polling a live MPD and fetching live-edge segments\n")
+		fmt.Fprintf(os.Stderr, "of the lowest bitrate representation of each AdaptationSet, and report latencies.\n\nOptions:\n")
+		f.PrintDefaults()
+	}
+	if err := f.Parse(args); err != nil {
+		return nil, err
+	}
+	if o.MPDURL == "" {
+		f.Usage()
+		return nil, fmt.Errorf("--mpd is required")
+	}
+	if o.Sessions <= 0 {
+		return nil, fmt.Errorf("sessions must be positive")
+	}
+	return &o, nil
+}
+
+// loadStats collects request latencies and errors per kind of request.
+type loadStats struct {
+	mu        sync.Mutex
+	latencies map[string][]time.Duration
+	errors    map[string]int
+	bytes     int64
+}
+
+func newLoadStats() *loadStats {
+	return &loadStats{latencies: make(map[string][]time.Duration), errors: make(map[string]int)}
+}
+
+func (ls *loadStats) add(kind string, latency time.Duration, nrBytes int, failed bool) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	ls.latencies[kind] = append(ls.latencies[kind], latency)
+	ls.bytes += int64(nrBytes)
+	if failed {
+		ls.errors[kind]++
+	}
+}
+
+// percentile returns the p:th percentile of sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(p / 100 * float64(len(sorted)-1))
+	return sorted[idx]
+}
+
+// report writes a table with request counts, errors, and latency percentiles.
+func (ls *loadStats) report(w io.Writer, o *LoadOptions, elapsed time.Duration) int {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	fmt.Fprintf(w, "Sessions: %d, duration: %s, received: %.1f MB (%.2f Mbps)\n", o.Sessions, elapsed.Round(time.Second),
+		float64(ls.bytes)/1e6, float64(ls.bytes)*8/1e6/elapsed.Seconds())
+	fmt.Fprintf(w, "%-8s %9s %7s %9s %9s %9s %9s\n", "kind", "requests", "errors", "p50", "p90", "p99", "max")
+	nrErrors := 0
+	for _, kind := range []string{loadKindMPD, loadKindInit, loadKindSegment} {
+		lats := ls.latencies[kind]
+		sort.Slice(lats, func(i, j int) bool { return lats[i] < lats[j] })
+		nrErrors += ls.errors[kind]
+		fmt.Fprintf(w, "%-8s %9d %7d %9s %9s %9s %9s\n", kind, len(lats), ls.errors[kind],
+			percentile(lats, 50).Round(time.Millisecond/10), percentile(lats, 90).Round(time.Millisecond/10),
+			percentile(lats, 99).Round(time.Millisecond/10), percentile(lats, 100).Round(time.Millisecond/10))
+	}
+	return nrErrors
+}
+
+// Load runs o.Sessions simulated players until o.Duration has passed, and writes a report to w.
+// The number of failed requests is returned.
+func Load(ctx context.Context, o *LoadOptions, w io.Writer) (int, error) {
+	mpdURL, err := url.Parse(o.MPDURL)
+	if err != nil {
+		return 0, fmt.Errorf("mpd URL: %w", err)
+	}
+	client := &http.Client{
+		Timeout:   o.Timeout,
+		Transport: &http.Transport{MaxIdleConnsPerHost: 2 * o.Sessions},
+	}
+	ctx, cancel := context.WithTimeout(ctx, o.Duration)
+	defer cancel()
+	stats := newLoadStats()
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < o.Sessions; i++ {
+		delay := o.RampUp * time.Duration(i) / time.Duration(o.Sessions)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return
+			}
+			p := loadPlayer{client: client, mpdURL: mpdURL, stats: stats, lastSegs: make(map[string]string)}
+			p.run(ctx)
+		}()
+	}
+	wg.Wait()
+	return stats.report(w, o, time.Since(start)), nil
+}
+
+// loadPlayer simulates a player that follows the live edge.
+type loadPlayer struct {
+	client   *http.Client
+	mpdURL   *url.URL
+	stats    *loadStats
+	mpd      *m.MPD
+	lastMPD  time.Time
+	lastSegs map[string]string // Last fetched URI per representation. Init segments are also stored.
+}
+
+func (p *loadPlayer) run(ctx context.Context) {
+	for ctx.Err() == nil {
+		if p.mpd == nil || time.Since(p.lastMPD) >= p.updatePeriod() {
+			p.refreshMPD(ctx)
+		}
+		wait := loadDefaultSegDur
+		if p.mpd != nil && len(p.mpd.Periods) > 0 {
+			wait = p.fetchLiveEdge(ctx)
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// updatePeriod returns the MPD minimumUpdatePeriod, or the default segment duration if not set.
+func (p *loadPlayer) updatePeriod() time.Duration {
+	if p.mpd == nil || p.mpd.MinimumUpdatePeriod == nil || *p.mpd.MinimumUpdatePeriod == 0 {
+		return loadDefaultSegDur
+	}
+	return time.Duration(*p.mpd.MinimumUpdatePeriod)
+}
+
+func (p *loadPlayer) refreshMPD(ctx context.Context) {
+	data, ok := p.fetch(ctx, p.mpdURL.String(), loadKindMPD)
+	if !ok {
+		return
+	}
+	mpd, err := m.MPDFromBytes(data)
+	if err != nil {
+		p.stats.add(loadKindMPD, 0, 0, true)
+		return
+	}
+	p.mpd = mpd
+	p.lastMPD = time.Now()
+}
+
+// fetchLiveEdge fetches new live-edge segments of the lowest bitrate representation of each AdaptationSet
+// in the last Period, and returns the shortest segment duration.
+func (p *loadPlayer) fetchLiveEdge(ctx context.Context) time.Duration {
+	period := p.mpd.Periods[len(p.mpd.Periods)-1]
+	segDur := time.Duration(0)
+	for _, as := range period.AdaptationSets {
+		if len(as.Representations) == 0 {
+			continue
+		}
+		rep := as.Representations[0]
+		for _, r := range as.Representations[1:] {
+			if r.Bandwidth < rep.Bandwidth {
+				rep = r
+			}
+		}
+		initURI, mediaURIs, err := repSegmentURIs(p.mpd, period, rep, 1, time.Now())
+		if err != nil {
+			continue
+		}
+		if d := repSegmentDur(rep); segDur == 0 || d < segDur {
+			segDur = d
+		}
+		if initURI != "" && p.lastSegs[initURI] == "" {
+			if _, ok := p.fetch(ctx, p.resolve(initURI), loadKindInit); ok {
+				p.lastSegs[initURI] = initURI
+			}
+		}
+		if len(mediaURIs) == 0 {
+			continue
+		}
+		uri := mediaURIs[len(mediaURIs)-1]
+		if p.lastSegs[rep.Id] != uri {
+			p.lastSegs[rep.Id] = uri
+			_, _ = p.fetch(ctx, p.resolve(uri), loadKindSegment)
+		}
+	}
+	if segDur == 0 {
+		return loadDefaultSegDur
+	}
+	return segDur
+}
+
+// repSegmentDur returns the segment duration from the SegmentTemplate, or the last S element in a SegmentTimeline.
+func repSegmentDur(rep *m.RepresentationType) time.Duration {
+	st := rep.GetSegmentTemplate()
+	if st == nil {
+		return loadDefaultSegDur
+	}
+	var dur uint64
+	switch {
+	case st.Duration != nil:
+		dur = uint64(*st.Duration)
+	case st.SegmentTimeline != nil && len(st.SegmentTimeline.S) > 0:
+		dur = st.SegmentTimeline.S[len(st.SegmentTimeline.S)-1].D
+	default:
+		return loadDefaultSegDur
+	}
+	return time.Duration(dur) * time.Second / time.Duration(st.GetTimescale())
+}
+
+func (p *loadPlayer) resolve(uri string) string {
+	ref, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	return p.mpdURL.ResolveReference(ref).String()
+}
+
+// fetch gets rawURL and records latency and outcome. ok is true for status 200.
+func (p *loadPlayer) fetch(ctx context.Context, rawURL, kind string) (data []byte, ok bool) {
+	start := time.Now()
+	status, data, err := fetchResponse(ctx, p.client, rawURL)
+	if err != nil && ctx.Err() != nil {
+		return nil, false // Test ended during request
+	}
+	ok = err == nil && status == http.StatusOK
+	p.stats.add(kind, time.Since(start), len(data), !ok)
+	return data, ok
+}
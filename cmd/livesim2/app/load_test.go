@@ -0,0 +1,44 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLoadArgs(t *testing.T) {
+	o, err := ParseLoadArgs([]string{"--sessions", "5", "--mpd", "http://localhost/live.mpd", "--duration", "10s"})
+	require.NoError(t, err)
+	require.Equal(t, LoadOptions{MPDURL: "http://localhost/live.mpd", Sessions: 5, Duration: 10 * time.Second,
+		RampUp: 5 * time.Second, Timeout: 10 * time.Second}, *o)
+	_, err = ParseLoadArgs([]string{"--sessions", "5"})
+	require.Error(t, err)
+}
+
+func TestLoad(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	o := LoadOptions{
+		MPDURL:   ts.URL + "/livesim2/segtimeline_1/testpic_2s/Manifest.mpd",
+		Sessions: 3,
+		Duration: 1500 * time.Millisecond,
+		RampUp:   100 * time.Millisecond,
+		Timeout:  time.Second,
+	}
+	var buf bytes.Buffer
+	nrErrors, err := Load(context.Background(), &o, &buf)
+	require.NoError(t, err)
+	require.Equal(t, 0, nrErrors, buf.String())
+	// Each player fetches the MPD, and init and one media segment for video and audio
+	require.Regexp(t, regexp.MustCompile(`(?m)^mpd\s+[3-9]\s+0\s`), buf.String())
+	require.Regexp(t, regexp.MustCompile(`(?m)^init\s+6\s+0\s`), buf.String())
+	require.Regexp(t, regexp.MustCompile(`(?m)^segment\s+([6-9]|1\d)\s+0\s`), buf.String())
+}
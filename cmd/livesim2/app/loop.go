@@ -0,0 +1,93 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"io/fs"
+)
+
+// configuredAsset returns the asset as modified by the loop and segdur parameters in cfg.
+// The loop sub-range is applied first, so that segdur re-chunks the shorter asset.
+// The availabilityTimeOffset and ladder are then checked against the resulting asset.
+func configuredAsset(vodFS fs.FS, a *asset, cfg *ResponseConfig) (*asset, error) {
+	if cfg.LoopS != nil {
+		var err error
+		a, err = a.withLoopDur(*cfg.LoopS * 1000)
+		if err != nil {
+			return nil, err
+		}
+	}
+	a, err := segDurAsset(vodFS, a, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyLLAssetSignaling(a, cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Ladder != nil {
+		if err := cfg.Ladder.verify(a); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// withLoopDur returns a version of the asset that only loops over its first loopDurMS.
+// The loop must end at a segment boundary of all representations with own segments.
+// Audio that is generated from the reference track timing keeps a segment extending
+// past the loop end, since its samples are cut at the loop end when generating segments.
+// The shortened assets are cached, so they are only computed once per loop duration.
+func (a *asset) withLoopDur(loopDurMS int) (*asset, error) {
+	if loopDurMS == a.LoopDurMS {
+		return a, nil
+	}
+	if loopDurMS <= 0 || loopDurMS > a.LoopDurMS {
+		return nil, fmt.Errorf("loop %dms not in range (0, %dms] of asset %s", loopDurMS, a.LoopDurMS, a.AssetPath)
+	}
+	if la, ok := a.loopAssets.Load(loopDurMS); ok {
+		return la.(*asset), nil
+	}
+	la, err := a.truncate(loopDurMS)
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := a.loopAssets.LoadOrStore(loopDurMS, la)
+	return actual.(*asset), nil
+}
+
+// truncate creates a new asset with the segments of the first loopDurMS of all representations.
+func (a *asset) truncate(loopDurMS int) (*asset, error) {
+	la := &asset{
+		AssetPath:    a.AssetPath,
+		MPDs:         a.MPDs,
+		SegmentDurMS: a.SegmentDurMS,
+		LoopDurMS:    loopDurMS,
+		Reps:         make(map[string]*RepData, len(a.Reps)),
+	}
+	for id, rep := range a.Reps {
+		if loopDurMS*rep.MediaTimescale%1000 != 0 {
+			return nil, fmt.Errorf("loop %dms is not an integral number of ticks in timescale %d of representation %s",
+				loopDurMS, rep.MediaTimescale, id)
+		}
+		end := rep.Segments[0].StartTime + uint64(loopDurMS*rep.MediaTimescale/1000)
+		followsRef := rep.ContentType == "audio" && !rep.PreEncrypted
+		n := 0
+		for _, seg := range rep.Segments {
+			if followsRef && seg.StartTime >= end || !followsRef && seg.EndTime > end {
+				break
+			}
+			n++
+		}
+		if !followsRef && (n == 0 || rep.Segments[n-1].EndTime != end) {
+			return nil, fmt.Errorf("loop %dms does not end at a segment boundary of representation %s", loopDurMS, id)
+		}
+		lr := *rep
+		lr.Segments = rep.Segments[:n]
+		la.Reps[id] = &lr
+	}
+	la.refRep = la.Reps[a.refRep.ID]
+	return la, nil
+}
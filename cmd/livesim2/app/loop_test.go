@@ -0,0 +1,68 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Eyevinn/mp4ff/mp4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoop(t *testing.T) {
+	server, ts := newTestServer(t, nil)
+
+	a, ok := server.assetMgr.findAsset("testpic_2s")
+	require.True(t, ok)
+	timescale := a.Reps["V300"].MediaTimescale
+	la, err := a.withLoopDur(4000)
+	require.NoError(t, err)
+	require.Len(t, la.Reps["V300"].Segments, 2)
+	require.Equal(t, 4000, la.LoopDurMS)
+	la2, err := a.withLoopDur(4000)
+	require.NoError(t, err)
+	require.True(t, la == la2, "loop assets should be cached")
+
+	getSeg := func(url string) *mp4.MediaSegment {
+		resp, body := testFullRequest(t, ts, "GET", url, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		f, err := mp4.DecodeFile(bytes.NewBuffer(body))
+		require.NoError(t, err)
+		require.Len(t, f.Segments, 1)
+		return f.Segments[0]
+	}
+
+	resp, body := testFullRequest(t, ts, "GET", "/livesim2/segtimeline_1/loop_4/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), fmt.Sprintf(`<S t="%d" d="%d" r="30">`, 38*timescale, 2*timescale))
+
+	// Segment 22 is the first segment of a 4s loop, but the third of the full 8s loop
+	looped := getSeg("/livesim2/loop_4/testpic_2s/V300/22.m4s?nowMS=100000")
+	require.Equal(t, uint64(44*timescale), looped.Fragments[0].Moof.Traf.Tfdt.BaseMediaDecodeTime())
+	first := getSeg("/livesim2/testpic_2s/V300/20.m4s?nowMS=100000")
+	third := getSeg("/livesim2/testpic_2s/V300/22.m4s?nowMS=100000")
+	require.Equal(t, first.Fragments[0].Mdat.Data, looped.Fragments[0].Mdat.Data)
+	require.NotEqual(t, third.Fragments[0].Mdat.Data, looped.Fragments[0].Mdat.Data)
+
+	audio := getSeg("/livesim2/loop_4/testpic_2s/A48/23.m4s?nowMS=100000")
+	dur := uint64(0)
+	for _, frag := range audio.Fragments {
+		dur += frag.Moof.Traf.Trun.Duration(a.Reps["A48"].DefaultSampleDuration)
+	}
+	require.InDelta(t, 2*48000, dur, 1024)
+
+	// Loop and segdur combined
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/loop_4/segdur_4/testpic_2s/V300/11.m4s?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Longer than the asset, not at a segment boundary, and non-positive
+	for _, loop := range []string{"loop_10", "loop_3", "loop_0"} {
+		resp, _ = testFullRequest(t, ts, "GET", "/livesim2/"+loop+"/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, loop)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	mirrorMaxConcurrent = 64
+	mirrorTimeout       = 30 * time.Second
+)
+
+// mirrorResult compares a local response with the response from the mirror origin.
+type mirrorResult struct {
+	URI          string
+	Status       int
+	Size         int
+	MirrorStatus int
+	MirrorSize   int
+	Err          error
+}
+
+// outcome classifies the result as "match", "status", "size", or "error".
+func (mr mirrorResult) outcome() string {
+	switch {
+	case mr.Err != nil:
+		return "error"
+	case mr.Status != mr.MirrorStatus:
+		return "status"
+	case mr.Size != mr.MirrorSize:
+		return "size"
+	default:
+		return "match"
+	}
+}
+
+// mirror sends a copy of each request to another origin and compares status codes and sizes.
+type mirror struct {
+	origin *url.URL
+	client *http.Client
+	sem    chan struct{}
+	report func(mirrorResult)
+}
+
+func newMirror(origin string) (*mirror, error) {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return nil, fmt.Errorf("mirror origin: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("mirror origin %q: scheme must be http or https", origin)
+	}
+	return &mirror{
+		origin: u,
+		client: &http.Client{Timeout: mirrorTimeout},
+		sem:    make(chan struct{}, mirrorMaxConcurrent),
+		report: logMirrorResult,
+	}, nil
+}
+
+func logMirrorResult(mr mirrorResult) {
+	outcome := mr.outcome()
+	mirrorResults.WithLabelValues(outcome).Inc()
+	switch outcome {
+	case "match":
+		slog.Debug("mirror match", "uri", mr.URI, "status", mr.Status, "size", mr.Size)
+	case "error":
+		slog.Warn("mirror error", "uri", mr.URI, "err", mr.Err)
+	default:
+		slog.Warn("mirror mismatch", "uri", mr.URI, "status", mr.Status, "mirrorStatus", mr.MirrorStatus,
+			"size", mr.Size, "mirrorSize", mr.MirrorSize)
+	}
+}
+
+// middleware serves the request and then mirrors it asynchronously.
+// If too many mirror requests are in flight, the request is not mirrored.
+func (m *mirror) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			return
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		mr := mirrorResult{URI: r.URL.RequestURI(), Status: status, Size: ww.BytesWritten()}
+		hdr := r.Header.Clone()
+		select {
+		case m.sem <- struct{}{}:
+			go func() {
+				defer func() { <-m.sem }()
+				m.report(m.fetch(r.Method, hdr, mr))
+			}()
+		default:
+			slog.Debug("mirror skipped, too many in flight", "uri", mr.URI)
+		}
+	})
+}
+
+// fetch makes the mirror request and fills in its status and size.
+func (m *mirror) fetch(method string, hdr http.Header, mr mirrorResult) mirrorResult {
+	mirrorURL := strings.TrimSuffix(m.origin.String(), "/") + mr.URI
+	req, err := http.NewRequest(method, mirrorURL, nil)
+	if err != nil {
+		mr.Err = err
+		return mr
+	}
+	for _, h := range []string{"Accept", "Range", "User-Agent", configHeader} {
+		if v := hdr.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		mr.Err = err
+		return mr
+	}
+	defer resp.Body.Close()
+	n, err := io.Copy(io.Discard, resp.Body)
+	mr.MirrorStatus = resp.StatusCode
+	mr.MirrorSize = int(n)
+	mr.Err = err
+	return mr
+}
@@ -0,0 +1,61 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMirror(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/livesim2/same.m4s":
+			_, _ = w.Write([]byte("12345"))
+		case "/livesim2/longer.m4s":
+			_, _ = w.Write([]byte("123456"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer origin.Close()
+
+	m, err := newMirror(origin.URL)
+	require.NoError(t, err)
+	results := make(chan mirrorResult, 1)
+	m.report = func(mr mirrorResult) { results <- mr }
+	local := m.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("12345"))
+	}))
+	ts := httptest.NewServer(local)
+	defer ts.Close()
+
+	testCases := []struct {
+		path          string
+		wantedOutcome string
+	}{
+		{"/livesim2/same.m4s", "match"},
+		{"/livesim2/longer.m4s", "size"},
+		{"/livesim2/missing.m4s", "status"},
+	}
+	for _, tc := range testCases {
+		resp, _ := testFullRequest(t, ts, "GET", tc.path, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		select {
+		case mr := <-results:
+			require.Equal(t, tc.path, mr.URI)
+			require.Equal(t, tc.wantedOutcome, mr.outcome(), tc.path)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no mirror result for %s", tc.path)
+		}
+	}
+
+	_, err = newMirror("ftp://example.com")
+	require.Error(t, err)
+}
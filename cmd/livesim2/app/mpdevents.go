@@ -0,0 +1,38 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/Eyevinn/mp4ff/mp4"
+)
+
+const (
+	// mpdEventSchemeIdUri is the DASH scheme for MPD events in emsg boxes.
+	mpdEventSchemeIdUri = "urn:mpeg:dash:event:2012"
+	// mpdValidityExpiration is the value for MPD validity expiration events.
+	// The message data is the publishTime of an MPD that the client must fetch.
+	mpdValidityExpiration = "1"
+)
+
+// mpdExpiryEmsg returns an MPD validity expiration emsg if the MPD changes when segment segNr becomes
+// available, which is every n segments. The times are in timescale units.
+// The publishTime in the message data is the same as in the new MPD, since publishTime has the same cadence.
+func mpdExpiryEmsg(cfg *ResponseConfig, n, segNr int, segStart, segDur, timescale uint64) *mp4.EmsgBox {
+	if segNr < 0 || (segNr+1)%n != 0 {
+		return nil
+	}
+	lsi := lastSegInfo{timescale: timescale, startTime: segStart, dur: segDur, nr: segNr}
+	publishTime := m.ConvertToDateTime(lastSegAvailTimeS(cfg, lsi))
+	return &mp4.EmsgBox{
+		Version:          1,
+		TimeScale:        uint32(timescale),
+		PresentationTime: segStart,
+		ID:               uint32(segNr),
+		SchemeIDURI:      mpdEventSchemeIdUri,
+		Value:            mpdValidityExpiration,
+		MessageData:      []byte(publishTime),
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Eyevinn/mp4ff/mp4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMPDExpiryEvents(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	resp, body := testFullRequest(t, ts, "GET", "/livesim2/mpdevents_3/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	mpdStr := string(body)
+	require.Contains(t, mpdStr, `minimumUpdatePeriod="PT0S"`)
+	require.Contains(t, mpdStr, `publishTime="1970-01-01T00:01:36Z"`)
+	require.Contains(t, mpdStr, `<InbandEventStream schemeIdUri="urn:mpeg:dash:event:2012" value="1">`)
+
+	for _, tc := range []struct {
+		nr          int
+		publishTime string
+	}{
+		{nr: 47, publishTime: "1970-01-01T00:01:36Z"},
+		{nr: 48, publishTime: ""},
+		{nr: 49, publishTime: ""},
+	} {
+		url := fmt.Sprintf("/livesim2/mpdevents_3/testpic_2s/V300/%d.m4s?nowMS=100000", tc.nr)
+		resp, body = testFullRequest(t, ts, "GET", url, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		f, err := mp4.DecodeFile(bytes.NewBuffer(body))
+		require.NoError(t, err)
+		emsgs := f.Segments[0].Fragments[0].Emsgs
+		if tc.publishTime == "" {
+			require.Len(t, emsgs, 0, tc.nr)
+			continue
+		}
+		require.Len(t, emsgs, 1)
+		e := emsgs[0]
+		require.Equal(t, mpdEventSchemeIdUri, e.SchemeIDURI)
+		require.Equal(t, mpdValidityExpiration, e.Value)
+		require.Equal(t, tc.publishTime, string(e.MessageData))
+	}
+
+	for _, params := range []string{"mpdevents_0", "mpdevents_3/mup_2", "mpdevents_3/pubtime_2"} {
+		resp, _ = testFullRequest(t, ts, "GET", "/livesim2/"+params+"/testpic_2s/Manifest.mpd", nil)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, params)
+	}
+}
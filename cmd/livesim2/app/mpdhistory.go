@@ -0,0 +1,128 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxMPDHistoryKeys limits the number of sessions or MPD paths recorded.
+// The least recently updated is dropped when the limit is reached.
+const maxMPDHistoryKeys = 1000
+
+// MPDSnapshot is one generated MPD.
+type MPDSnapshot struct {
+	Seq   int       `json:"seq" doc:"Sequence number of MPD for this key, starting at 1"`
+	Time  time.Time `json:"time" doc:"Wall-clock time of the request"`
+	NowMS int       `json:"nowMS" doc:"Time (ms) used to generate the MPD"`
+	URL   string    `json:"url" doc:"Request URL (path and query)"`
+	MPD   string    `json:"mpd" doc:"Generated MPD"`
+}
+
+// MPDHistoryKey summarizes the recorded MPDs for a key.
+type MPDHistoryKey struct {
+	Key     string    `json:"key" doc:"Session (session_<id>) or MPD URL path"`
+	Count   int       `json:"count" doc:"Number of MPDs kept"`
+	Total   int       `json:"total" doc:"Number of MPDs generated since recording started"`
+	Updated time.Time `json:"updated" doc:"Time of last MPD"`
+}
+
+// mpdRing is a ring buffer of snapshots.
+type mpdRing struct {
+	snapshots []MPDSnapshot
+	next      int
+	total     int
+	updated   time.Time
+}
+
+// mpdHistory records generated MPDs per session or MPD path.
+type mpdHistory struct {
+	mu    sync.Mutex
+	size  int
+	rings map[string]*mpdRing
+}
+
+func newMPDHistory(size int) *mpdHistory {
+	return &mpdHistory{size: size, rings: make(map[string]*mpdRing)}
+}
+
+// mpdHistoryKey returns the session if set, and the URL path otherwise.
+func mpdHistoryKey(cfg *ResponseConfig, urlPath string) string {
+	if cfg.SessionID != "" {
+		return "session_" + cfg.SessionID
+	}
+	return urlPath
+}
+
+// add appends a snapshot for key, overwriting the oldest one if the buffer is full.
+func (h *mpdHistory) add(key string, snap MPDSnapshot) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ring, ok := h.rings[key]
+	if !ok {
+		if len(h.rings) >= maxMPDHistoryKeys {
+			h.dropOldest()
+		}
+		ring = &mpdRing{snapshots: make([]MPDSnapshot, 0, h.size)}
+		h.rings[key] = ring
+	}
+	ring.total++
+	snap.Seq = ring.total
+	ring.updated = snap.Time
+	if len(ring.snapshots) < h.size {
+		ring.snapshots = append(ring.snapshots, snap)
+		return
+	}
+	ring.snapshots[ring.next] = snap
+	ring.next = (ring.next + 1) % h.size
+}
+
+func (h *mpdHistory) dropOldest() {
+	var oldestKey string
+	var oldest time.Time
+	for key, ring := range h.rings {
+		if oldestKey == "" || ring.updated.Before(oldest) {
+			oldestKey, oldest = key, ring.updated
+		}
+	}
+	delete(h.rings, oldestKey)
+}
+
+// get returns the snapshots for key, oldest first.
+func (h *mpdHistory) get(key string) ([]MPDSnapshot, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ring, ok := h.rings[key]
+	if !ok {
+		return nil, false
+	}
+	snaps := make([]MPDSnapshot, 0, len(ring.snapshots))
+	snaps = append(snaps, ring.snapshots[ring.next:]...)
+	snaps = append(snaps, ring.snapshots[:ring.next]...)
+	return snaps, true
+}
+
+// keys returns a summary of all keys sorted by key.
+func (h *mpdHistory) keys() []MPDHistoryKey {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	keys := make([]MPDHistoryKey, 0, len(h.rings))
+	for key, ring := range h.rings {
+		keys = append(keys, MPDHistoryKey{Key: key, Count: len(ring.snapshots), Total: ring.total, Updated: ring.updated})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Key < keys[j].Key })
+	return keys
+}
+
+// remove deletes the history for key and reports whether it existed.
+func (h *mpdHistory) remove(key string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, ok := h.rings[key]
+	delete(h.rings, key)
+	return ok
+}
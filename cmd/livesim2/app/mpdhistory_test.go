@@ -0,0 +1,78 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMPDHistoryRing(t *testing.T) {
+	h := newMPDHistory(3)
+	now := time.Now()
+	for i := 1; i <= 5; i++ {
+		h.add("a", MPDSnapshot{Time: now, NowMS: i * 1000})
+	}
+	snaps, ok := h.get("a")
+	require.True(t, ok)
+	require.Len(t, snaps, 3)
+	for i, s := range snaps {
+		require.Equal(t, (i+3)*1000, s.NowMS)
+		require.Equal(t, i+3, s.Seq)
+	}
+	keys := h.keys()
+	require.Equal(t, []MPDHistoryKey{{Key: "a", Count: 3, Total: 5, Updated: now}}, keys)
+	require.True(t, h.remove("a"))
+	_, ok = h.get("a")
+	require.False(t, ok)
+}
+
+func TestMPDHistoryAPI(t *testing.T) {
+	_, ts := newTestServer(t, func(cfg *ServerConfig) {
+		cfg.MPDHistory = 2
+	})
+
+	mpdPath := "/livesim2/segtimeline_1/testpic_2s/Manifest.mpd"
+	for _, nowMS := range []int{100_000, 102_000, 104_000} {
+		resp, _ := testFullRequest(t, ts, "GET", fmt.Sprintf("%s?nowMS=%d", mpdPath, nowMS), nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	resp, body := testFullRequest(t, ts, "GET", "/api/mpd-history", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var list struct {
+		Size int             `json:"size"`
+		Keys []MPDHistoryKey `json:"keys"`
+	}
+	require.NoError(t, json.Unmarshal(body, &list))
+	require.Equal(t, 2, list.Size)
+	require.Len(t, list.Keys, 1)
+	require.Equal(t, mpdPath, list.Keys[0].Key)
+	require.Equal(t, 3, list.Keys[0].Total)
+
+	histPath := "/api/mpd-history/snapshots?key=" + url.QueryEscape(mpdPath)
+	resp, body = testFullRequest(t, ts, "GET", histPath, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var hist struct {
+		Snapshots []MPDSnapshot `json:"snapshots"`
+	}
+	require.NoError(t, json.Unmarshal(body, &hist))
+	require.Len(t, hist.Snapshots, 2)
+	require.Equal(t, 102_000, hist.Snapshots[0].NowMS)
+	require.Equal(t, 104_000, hist.Snapshots[1].NowMS)
+	require.True(t, strings.Contains(hist.Snapshots[1].MPD, `publishTime="1970-01-01T00:01:44Z"`))
+
+	resp, _ = testFullRequest(t, ts, "DELETE", histPath, nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, _ = testFullRequest(t, ts, "GET", histPath, nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
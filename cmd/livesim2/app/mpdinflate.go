@@ -0,0 +1,74 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+)
+
+const (
+	// mpdInflateProps adds SupplementalProperty descriptors to the AdaptationSets
+	mpdInflateProps = "props"
+	// mpdInflateAS adds copies of the first AdaptationSet with an EssentialProperty that players must not understand
+	mpdInflateAS = "as"
+	// mpdInflateMax is the maximal number of padding elements per Period
+	mpdInflateMax = 10000
+	// paddingSchemeIdUri is a scheme unknown to players, so padding AdaptationSets are ignored
+	paddingSchemeIdUri = "urn:livesim2:padding:2024"
+)
+
+// MPDInflate configures padding of the MPD with N dummy elements per Period.
+type MPDInflate struct {
+	Kind string `json:"Kind"`
+	N    int    `json:"N"`
+}
+
+// validate checks the kind and that N is in range.
+func (mi *MPDInflate) validate() error {
+	if mi.Kind != mpdInflateProps && mi.Kind != mpdInflateAS {
+		return fmt.Errorf("mpdinflate unknown kind %q, allowed: %s, %s", mi.Kind, mpdInflateProps, mpdInflateAS)
+	}
+	if mi.N <= 0 || mi.N > mpdInflateMax {
+		return fmt.Errorf("mpdinflate n=%d must be in range 1-%d", mi.N, mpdInflateMax)
+	}
+	return nil
+}
+
+// inflateMPD adds dummy but schema-valid elements to all Periods of the MPD.
+func inflateMPD(mpd *m.MPD, mi *MPDInflate) {
+	for _, p := range mpd.Periods {
+		if len(p.AdaptationSets) == 0 {
+			continue
+		}
+		switch mi.Kind {
+		case mpdInflateProps:
+			for i := 0; i < mi.N; i++ {
+				as := p.AdaptationSets[i%len(p.AdaptationSets)]
+				as.SupplementalProperties = append(as.SupplementalProperties,
+					m.NewDescriptor(paddingSchemeIdUri, fmt.Sprintf("%d", i), ""))
+			}
+		case mpdInflateAS:
+			maxID := uint32(0)
+			for _, as := range p.AdaptationSets {
+				if as.Id != nil {
+					maxID = max(maxID, *as.Id)
+				}
+			}
+			orig := p.AdaptationSets[0]
+			for i := 0; i < mi.N; i++ {
+				as := orig.Clone()
+				as.Id = Ptr(maxID + uint32(i) + 1)
+				as.EssentialProperties = append(as.EssentialProperties,
+					m.NewDescriptor(paddingSchemeIdUri, fmt.Sprintf("%d", i), ""))
+				for _, rep := range as.Representations {
+					rep.Id = fmt.Sprintf("%s_pad%d", rep.Id, i)
+				}
+				p.AppendAdaptationSet(as)
+			}
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+)
+
+// mpdSizeHeader reports the size of the minimized MPD relative to the normal one
+const mpdSizeHeader = "X-MPD-Size"
+
+// mpdSizeReport returns the mpdSizeHeader value for the normal and minimized MPD sizes.
+func mpdSizeReport(origSize, minSize int) string {
+	savedPct := 100 * float64(origSize-minSize) / float64(origSize)
+	return fmt.Sprintf("original=%d, minimized=%d, saved=%.1f%%", origSize, minSize, savedPct)
+}
+
+// writeMinimizedMPD minimizes the MPD and returns its most compact serialization,
+// without indentation, XML declaration, attributes with default values, and end tags of empty elements.
+func writeMinimizedMPD(mpd *m.MPD) ([]byte, error) {
+	minimizeMPD(mpd)
+	var buf bytes.Buffer
+	if _, err := mpd.Write(&buf, "", false); err != nil {
+		return nil, err
+	}
+	data, err := applyMPDQuirks(buf.Bytes(), []string{quirkNoDefaults})
+	if err != nil {
+		return nil, err
+	}
+	return selfCloseEmptyElements(data), nil
+}
+
+// minimizeMPD collapses identical Representation SegmentTemplates to the AdaptationSet level
+// and compacts all SegmentTimelines.
+func minimizeMPD(mpd *m.MPD) {
+	for _, p := range mpd.Periods {
+		for _, as := range p.AdaptationSets {
+			collapseSegmentTemplates(as)
+			if as.SegmentTemplate != nil {
+				compactSegmentTimeline(as.SegmentTemplate.SegmentTimeline)
+			}
+			for _, rep := range as.Representations {
+				if rep.SegmentTemplate != nil {
+					compactSegmentTimeline(rep.SegmentTemplate.SegmentTimeline)
+				}
+			}
+		}
+	}
+}
+
+// collapseSegmentTemplates moves SegmentTemplates that are the same for all Representations
+// to the AdaptationSet, and removes Representation SegmentTemplates equal to the AdaptationSet one.
+func collapseSegmentTemplates(as *m.AdaptationSetType) {
+	if len(as.Representations) == 0 {
+		return
+	}
+	if as.SegmentTemplate == nil {
+		first := as.Representations[0].SegmentTemplate
+		if first == nil {
+			return
+		}
+		for _, rep := range as.Representations[1:] {
+			if !reflect.DeepEqual(rep.SegmentTemplate, first) {
+				return
+			}
+		}
+		as.SegmentTemplate = first
+	}
+	for _, rep := range as.Representations {
+		if reflect.DeepEqual(rep.SegmentTemplate, as.SegmentTemplate) {
+			rep.SegmentTemplate = nil
+		}
+	}
+}
+
+// compactSegmentTimeline removes @t values that follow from the previous entries
+// and merges consecutive entries with the same duration using @r.
+// The entries are replaced, since they may be shared with the asset.
+func compactSegmentTimeline(stl *m.SegmentTimelineType) {
+	if stl == nil {
+		return
+	}
+	out := make([]*m.S, 0, len(stl.S))
+	var nextT uint64 // start of the next entry, if known
+	knownT := true
+	for _, s := range stl.S {
+		c := *s
+		if c.T != nil && knownT && *c.T == nextT && c.N == nil {
+			c.T = nil
+		}
+		if c.T != nil {
+			nextT = *c.T
+			knownT = true
+		}
+		if len(out) > 0 {
+			prev := out[len(out)-1]
+			if c.T == nil && c.N == nil && c.K == nil && prev.K == nil && prev.R >= 0 && c.D == prev.D {
+				if c.R < 0 {
+					prev.R = -1
+				} else {
+					prev.R += c.R + 1
+				}
+				nextT += c.D * uint64(c.R+1)
+				knownT = c.R >= 0
+				continue
+			}
+		}
+		out = append(out, &c)
+		nextT += c.D * uint64(c.R+1)
+		knownT = knownT && c.R >= 0
+	}
+	stl.S = out
+}
+
+// selfCloseEmptyElements replaces <X ...></X> with <X .../>.
+// It relies on < and > being escaped in attribute values and character data.
+func selfCloseEmptyElements(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	pos := 0
+	for {
+		idx := bytes.Index(data[pos:], []byte("></"))
+		if idx < 0 {
+			break
+		}
+		endStart := pos + idx + 1 // Start of </X>
+		endClose := bytes.IndexByte(data[endStart:], '>')
+		if endClose < 0 {
+			break
+		}
+		name := data[endStart+2 : endStart+endClose]
+		startTag := bytes.LastIndexByte(data[:endStart], '<')
+		if startTag >= 0 && bytes.HasPrefix(data[startTag+1:], name) && data[startTag+1] != '/' {
+			after := data[startTag+1+len(name)]
+			if after == ' ' || after == '>' {
+				out = append(out, data[pos:endStart-1]...)
+				out = append(out, "/>"...)
+				pos = endStart + endClose + 1
+				continue
+			}
+		}
+		out = append(out, data[pos:endStart]...)
+		pos = endStart
+	}
+	return append(out, data[pos:]...)
+}
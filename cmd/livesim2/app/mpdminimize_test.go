@@ -0,0 +1,103 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompactSegmentTimeline(t *testing.T) {
+	s := func(t *uint64, d uint64, r int) *m.S { return &m.S{T: t, D: d, R: r} }
+	testCases := []struct {
+		desc   string
+		in     []*m.S
+		wanted []*m.S
+	}{
+		{"merge implicit", []*m.S{s(Ptr(uint64(10)), 2, 0), s(nil, 2, 1), s(nil, 3, 0)},
+			[]*m.S{s(Ptr(uint64(10)), 2, 2), s(nil, 3, 0)}},
+		{"drop redundant t", []*m.S{s(Ptr(uint64(0)), 2, 1), s(Ptr(uint64(4)), 2, 0), s(Ptr(uint64(6)), 3, 0)},
+			[]*m.S{s(nil, 2, 2), s(nil, 3, 0)}},
+		{"keep gap", []*m.S{s(nil, 2, 0), s(Ptr(uint64(5)), 2, 0)},
+			[]*m.S{s(nil, 2, 0), s(Ptr(uint64(5)), 2, 0)}},
+		{"open-ended", []*m.S{s(nil, 2, 0), s(nil, 2, -1)},
+			[]*m.S{s(nil, 2, -1)}},
+	}
+	for _, tc := range testCases {
+		in := make([]*m.S, len(tc.in))
+		copy(in, tc.in)
+		stl := &m.SegmentTimelineType{S: in}
+		compactSegmentTimeline(stl)
+		require.Equal(t, tc.wanted, stl.S, tc.desc)
+		require.Equal(t, *tc.in[0], *in[0], "input entries must not be modified")
+	}
+}
+
+func TestSelfCloseEmptyElements(t *testing.T) {
+	in := `<MPD a="x&lt;&gt;"><P></P><Q b="1"></Q><R>text</R><!-- c --></MPD>`
+	wanted := `<MPD a="x&lt;&gt;"><P/><Q b="1"/><R>text</R><!-- c --></MPD>`
+	require.Equal(t, wanted, string(selfCloseEmptyElements([]byte(in))))
+}
+
+func TestCollapseSegmentTemplates(t *testing.T) {
+	st := func(ts uint32) *m.SegmentTemplateType {
+		return &m.SegmentTemplateType{Media: "$RepresentationID$/$Time$.m4s",
+			MultipleSegmentBaseType: m.MultipleSegmentBaseType{SegmentBaseType: m.SegmentBaseType{Timescale: Ptr(ts)}}}
+	}
+	as := &m.AdaptationSetType{Representations: []*m.RepresentationType{
+		{SegmentTemplate: st(90000)}, {SegmentTemplate: st(90000)}}}
+	collapseSegmentTemplates(as)
+	require.Equal(t, st(90000), as.SegmentTemplate)
+	require.Nil(t, as.Representations[0].SegmentTemplate)
+	require.Nil(t, as.Representations[1].SegmentTemplate)
+
+	as = &m.AdaptationSetType{Representations: []*m.RepresentationType{
+		{SegmentTemplate: st(90000)}, {SegmentTemplate: st(48000)}}}
+	collapseSegmentTemplates(as)
+	require.Nil(t, as.SegmentTemplate)
+	require.NotNil(t, as.Representations[0].SegmentTemplate)
+}
+
+func TestMPDMinimize(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	// nrSegments returns the number of segments in all SegmentTimelines
+	nrSegments := func(mpd *m.MPD) int {
+		nr := 0
+		for _, as := range mpd.Periods[0].AdaptationSets {
+			for _, s := range as.SegmentTemplate.SegmentTimeline.S {
+				nr += s.R + 1
+			}
+		}
+		return nr
+	}
+	for _, params := range []string{"segtimeline_1/", "segtimelinenr_1/", "segtimeline_1/periods_60/"} {
+		url := "/livesim2/" + params + "%stestpic_2s/Manifest.mpd?nowMS=100000"
+		resp, body := testFullRequest(t, ts, "GET", fmt.Sprintf(url, ""), nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Empty(t, resp.Header.Get(mpdSizeHeader))
+		mpd, err := m.ReadFromString(string(body))
+		require.NoError(t, err)
+
+		resp, minBody := testFullRequest(t, ts, "GET", fmt.Sprintf(url, "mpdmin_1/"), nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Less(t, len(minBody), len(body))
+		require.NotContains(t, string(minBody), "\n")
+		require.NotContains(t, string(minBody), "></Role>")
+		require.Equal(t, mpdSizeReport(len(body), len(minBody)), resp.Header.Get(mpdSizeHeader), params)
+		minMPD, err := m.ReadFromString(string(minBody))
+		require.NoError(t, err)
+		require.Equal(t, len(mpd.Periods), len(minMPD.Periods))
+		require.Equal(t, nrSegments(mpd), nrSegments(minMPD), params)
+		require.Equal(t, *mpd.Type, *minMPD.Type)
+	}
+	resp, _ := testFullRequest(t, ts, "GET", "/livesim2/mpdmin_1/mpdquirks_defaults/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Equal(t, "original=2000, minimized=1500, saved=25.0%", mpdSizeReport(2000, 1500))
+}
@@ -0,0 +1,177 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// MPD serialization quirks that can be selected by the mpdquirks URL parameter.
+const (
+	// quirkAttrOrder reverses the order of the attributes of every element
+	quirkAttrOrder = "attrorder"
+	// quirkDefaults adds omitted attributes with their default values
+	quirkDefaults = "defaults"
+	// quirkNoDefaults removes attributes that have their default values
+	quirkNoDefaults = "nodefaults"
+	// quirkNSPrefix puts DASH elements in an mpd: prefixed namespace instead of the default namespace
+	quirkNSPrefix = "nsprefix"
+	// quirkComments inserts an XML comment before every element
+	quirkComments = "comments"
+	// quirkBOM starts the MPD with a UTF-8 byte order mark
+	quirkBOM = "bom"
+)
+
+var mpdQuirks = []string{quirkAttrOrder, quirkDefaults, quirkNoDefaults, quirkNSPrefix, quirkComments, quirkBOM}
+
+const (
+	dashNamespace       = "urn:mpeg:dash:schema:mpd:2011"
+	quirkNSPrefixName   = "mpd"
+	quirkCommentText    = " livesim2 "
+	utf8ByteOrderMark   = "\xef\xbb\xbf"
+	segmentTemplateName = "SegmentTemplate"
+)
+
+// mpdDefaultAttr is an attribute with the default value it has when omitted.
+type mpdDefaultAttr struct {
+	name, value string
+}
+
+// mpdDefaultAttrs are the attributes handled by the defaults and nodefaults quirks.
+// SegmentTemplate values are only changed on the highest level, since lower levels inherit from it.
+var mpdDefaultAttrs = map[string][]mpdDefaultAttr{
+	"AdaptationSet": {
+		{"segmentAlignment", "false"},
+		{"subsegmentAlignment", "false"},
+		{"bitstreamSwitching", "false"},
+	},
+	segmentTemplateName: {
+		{"timescale", "1"},
+		{"startNumber", "1"},
+		{"presentationTimeOffset", "0"},
+	},
+}
+
+// quirkFrame is the state of an open element.
+type quirkFrame struct {
+	defaultNS string
+	hasST     bool // a child SegmentTemplate has been seen
+}
+
+// applyMPDQuirks re-serializes the MPD XML with the quirks.
+func applyMPDQuirks(mpdXML []byte, quirks []string) ([]byte, error) {
+	var out bytes.Buffer
+	if slices.Contains(quirks, quirkBOM) {
+		out.WriteString(utf8ByteOrderMark)
+	}
+	d := xml.NewDecoder(bytes.NewReader(mpdXML))
+	stack := []quirkFrame{{}}
+	for {
+		tok, err := d.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse MPD: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			parent := &stack[len(stack)-1]
+			frame := quirkFrame{defaultNS: parent.defaultNS}
+			for _, a := range t.Attr {
+				if a.Name.Space == "" && a.Name.Local == "xmlns" {
+					frame.defaultNS = a.Value
+				}
+			}
+			if t.Name.Space == "" {
+				inherits := false
+				if t.Name.Local == segmentTemplateName {
+					for _, f := range stack[:len(stack)-1] {
+						inherits = inherits || f.hasST
+					}
+					parent.hasST = true
+				}
+				if !inherits {
+					t.Attr = applyDefaultQuirks(t.Name.Local, t.Attr, quirks)
+				}
+			}
+			if slices.Contains(quirks, quirkNSPrefix) && t.Name.Space == "" && frame.defaultNS == dashNamespace {
+				t.Name.Space = quirkNSPrefixName
+				for i, a := range t.Attr {
+					if a.Name.Space == "" && a.Name.Local == "xmlns" && a.Value == dashNamespace {
+						t.Attr[i].Name = xml.Name{Space: "xmlns", Local: quirkNSPrefixName}
+					}
+				}
+			}
+			if slices.Contains(quirks, quirkAttrOrder) {
+				slices.Reverse(t.Attr)
+			}
+			if slices.Contains(quirks, quirkComments) {
+				fmt.Fprintf(&out, "<!--%s-->", quirkCommentText)
+			}
+			out.WriteString("<" + qualifiedName(t.Name))
+			for _, a := range t.Attr {
+				out.WriteString(" " + qualifiedName(a.Name) + `="` + attrEscaper.Replace(a.Value) + `"`)
+			}
+			out.WriteString(">")
+			stack = append(stack, frame)
+		case xml.EndElement:
+			if slices.Contains(quirks, quirkNSPrefix) && t.Name.Space == "" &&
+				stack[len(stack)-1].defaultNS == dashNamespace {
+				t.Name.Space = quirkNSPrefixName
+			}
+			out.WriteString("</" + qualifiedName(t.Name) + ">")
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			out.WriteString(textEscaper.Replace(string(t)))
+		case xml.Comment:
+			out.WriteString("<!--" + string(t) + "-->")
+		case xml.ProcInst:
+			out.WriteString("<?" + t.Target + " " + string(t.Inst) + "?>")
+		case xml.Directive:
+			out.WriteString("<!" + string(t) + ">")
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// applyDefaultQuirks adds or removes attributes with default values of an element.
+func applyDefaultQuirks(elem string, attrs []xml.Attr, quirks []string) []xml.Attr {
+	defaults := mpdDefaultAttrs[elem]
+	switch {
+	case len(defaults) == 0:
+		return attrs
+	case slices.Contains(quirks, quirkDefaults):
+		for _, da := range defaults {
+			if !slices.ContainsFunc(attrs, func(a xml.Attr) bool { return a.Name.Space == "" && a.Name.Local == da.name }) {
+				attrs = append(attrs, xml.Attr{Name: xml.Name{Local: da.name}, Value: da.value})
+			}
+		}
+	case slices.Contains(quirks, quirkNoDefaults):
+		attrs = slices.DeleteFunc(attrs, func(a xml.Attr) bool {
+			return a.Name.Space == "" && slices.Contains(defaults, mpdDefaultAttr{a.Name.Local, a.Value})
+		})
+	}
+	return attrs
+}
+
+// qualifiedName returns the name with prefix as in the raw XML.
+func qualifiedName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return n.Space + ":" + n.Local
+}
+
+var (
+	attrEscaper = strings.NewReplacer(`&`, "&amp;", `<`, "&lt;", `"`, "&quot;", "\n", "&#xA;", "\r", "&#xD;", "\t", "&#x9;")
+	textEscaper = strings.NewReplacer(`&`, "&amp;", `<`, "&lt;", `>`, "&gt;")
+)
@@ -0,0 +1,78 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMPDQuirks(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	getMPD := func(params string, wantedStatus int) string {
+		t.Helper()
+		resp, body := testFullRequest(t, ts, "GET", "/livesim2/"+params+"testpic_2s/Manifest.mpd?nowMS=100000", nil)
+		require.Equal(t, wantedStatus, resp.StatusCode, params)
+		return string(body)
+	}
+	// normalize parses and re-serializes an MPD
+	normalize := func(mpdStr string) string {
+		t.Helper()
+		mpd, err := m.ReadFromString(strings.TrimPrefix(mpdStr, utf8ByteOrderMark))
+		require.NoError(t, err)
+		out, err := mpd.WriteToString("  ", true)
+		require.NoError(t, err)
+		return out
+	}
+
+	plain := getMPD("", http.StatusOK)
+	// These quirks only change the serialization
+	for _, q := range []string{quirkAttrOrder, quirkNSPrefix, quirkComments, quirkBOM, "attrorder-nsprefix-comments-bom"} {
+		quirked := getMPD("mpdquirks_"+q+"/", http.StatusOK)
+		require.NotEqual(t, plain, quirked, q)
+		normalized := normalize(quirked)
+		if strings.Contains(q, quirkNSPrefix) {
+			// dash-mpd only keeps the default namespace declaration
+			normalized = strings.Replace(normalized, "<MPD ", `<MPD xmlns="`+dashNamespace+`" `, 1)
+		}
+		require.Equal(t, normalize(plain), normalized, q)
+	}
+	quirked := getMPD("mpdquirks_bom/", http.StatusOK)
+	require.True(t, strings.HasPrefix(quirked, utf8ByteOrderMark+"<?xml"))
+	quirked = getMPD("mpdquirks_nsprefix/", http.StatusOK)
+	require.Contains(t, quirked, `<mpd:MPD xmlns:mpd="urn:mpeg:dash:schema:mpd:2011"`)
+	require.Contains(t, quirked, `</mpd:Period>`)
+	require.NotContains(t, quirked, `<Period`)
+	quirked = getMPD("mpdquirks_comments/", http.StatusOK)
+	require.Contains(t, quirked, "<!--"+quirkCommentText+"--><MPD ")
+
+	quirked = getMPD("mpdquirks_defaults/", http.StatusOK)
+	require.Contains(t, quirked, `startNumber="0" timescale="1" presentationTimeOffset="0">`)
+	require.Contains(t, quirked, `bitstreamSwitching="false"`)
+	require.Contains(t, getMPD("snr_1/", http.StatusOK), `startNumber="1"`)
+	require.NotContains(t, getMPD("snr_1/mpdquirks_nodefaults/", http.StatusOK), `startNumber=`)
+
+	getMPD("mpdquirks_defaults-nodefaults/", http.StatusBadRequest)
+	getMPD("mpdquirks_cdata/", http.StatusBadRequest)
+}
+
+func TestMPDQuirksInheritedSegmentTemplate(t *testing.T) {
+	mpdXML := `<MPD xmlns="urn:mpeg:dash:schema:mpd:2011"><Period><AdaptationSet>` +
+		`<SegmentTemplate timescale="90000"></SegmentTemplate>` +
+		`<Representation id="1"><SegmentTemplate timescale="1"></SegmentTemplate></Representation>` +
+		`</AdaptationSet></Period></MPD>`
+	out, err := applyMPDQuirks([]byte(mpdXML), []string{quirkNoDefaults})
+	require.NoError(t, err)
+	require.Equal(t, mpdXML, string(out))
+	out, err = applyMPDQuirks([]byte(mpdXML), []string{quirkDefaults})
+	require.NoError(t, err)
+	require.Contains(t, string(out), `<SegmentTemplate timescale="90000" startNumber="1" presentationTimeOffset="0">`)
+	require.Contains(t, string(out), `<Representation id="1"><SegmentTemplate timescale="1">`)
+}
@@ -0,0 +1,170 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+const (
+	// mpdSignatureHeader carries the detached JWS of the MPD body
+	mpdSignatureHeader = "X-MPD-Signature"
+	// mpdSignJWS is the mpdsign value for detached JWS (RFC 7515 Appendix F) signatures
+	mpdSignJWS = "jws"
+	// mpdSignAlg is the JWS algorithm. Only ECDSA P-256 keys are supported.
+	mpdSignAlg = "ES256"
+)
+
+var b64url = base64.RawURLEncoding
+
+// JWK is the public key used to sign MPDs, as a JSON Web Key.
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+// jwsHeader is the protected header of MPD signatures.
+type jwsHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Cty string `json:"cty"`
+}
+
+// mpdSigner signs MPDs with an ECDSA P-256 key.
+type mpdSigner struct {
+	key *ecdsa.PrivateKey
+	jwk JWK
+}
+
+// newMPDSigner loads a PEM-encoded ECDSA P-256 private key (SEC 1 or PKCS #8) from keyFile.
+// If keyFile is empty, an ephemeral key is generated.
+func newMPDSigner(keyFile string) (*mpdSigner, error) {
+	var key *ecdsa.PrivateKey
+	var err error
+	if keyFile == "" {
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("generate key: %w", err)
+		}
+	} else {
+		key, err = readECKey(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("mpdsignkey: %w", err)
+		}
+	}
+	pub, err := key.PublicKey.ECDH()
+	if err != nil {
+		return nil, fmt.Errorf("public key: %w", err)
+	}
+	xy := pub.Bytes()[1:] // uncompressed point 0x04 || X || Y
+	jwk := JWK{Kty: "EC", Crv: "P-256", X: b64url.EncodeToString(xy[:32]), Y: b64url.EncodeToString(xy[32:]),
+		Alg: mpdSignAlg, Use: "sig"}
+	// RFC 7638 thumbprint with the required members in lexicographic order
+	thumb := sha256.Sum256([]byte(fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q,"y":%q}`, jwk.Crv, jwk.Kty, jwk.X, jwk.Y)))
+	jwk.Kid = b64url.EncodeToString(thumb[:])
+	return &mpdSigner{key: key, jwk: jwk}, nil
+}
+
+func readECKey(keyFile string) (*ecdsa.PrivateKey, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %s", keyFile)
+	}
+	var key *ecdsa.PrivateKey
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+	case "PRIVATE KEY":
+		k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		if key, ok = k.(*ecdsa.PrivateKey); !ok {
+			return nil, fmt.Errorf("not an ECDSA key")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PEM type %q", block.Type)
+	}
+	if key.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("curve must be P-256")
+	}
+	return key, nil
+}
+
+// sign returns a detached compact JWS (header..signature) of payload.
+func (ms *mpdSigner) sign(payload []byte) (string, error) {
+	hdr, err := json.Marshal(jwsHeader{Alg: mpdSignAlg, Kid: ms.jwk.Kid, Cty: "application/dash+xml"})
+	if err != nil {
+		return "", err
+	}
+	protected := b64url.EncodeToString(hdr)
+	digest := sha256.Sum256([]byte(protected + "." + b64url.EncodeToString(payload)))
+	r, s, err := ecdsa.Sign(rand.Reader, ms.key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return protected + ".." + b64url.EncodeToString(sig), nil
+}
+
+// verify checks that jws is a valid detached signature of payload made with the signer's key.
+func (ms *mpdSigner) verify(payload []byte, jws string) error {
+	parts := strings.Split(jws, ".")
+	if len(parts) != 3 || parts[1] != "" {
+		return errors.New("not a detached compact JWS (header..signature)")
+	}
+	hdrBytes, err := b64url.DecodeString(parts[0])
+	if err != nil {
+		return fmt.Errorf("header: %w", err)
+	}
+	var hdr jwsHeader
+	if err := json.Unmarshal(hdrBytes, &hdr); err != nil {
+		return fmt.Errorf("header: %w", err)
+	}
+	if hdr.Alg != mpdSignAlg {
+		return fmt.Errorf("alg %q is not %s", hdr.Alg, mpdSignAlg)
+	}
+	if hdr.Kid != ms.jwk.Kid {
+		return fmt.Errorf("unknown kid %q", hdr.Kid)
+	}
+	sig, err := b64url.DecodeString(parts[2])
+	if err != nil || len(sig) != 64 {
+		return errors.New("bad signature encoding")
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + b64url.EncodeToString(payload)))
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	if !ecdsa.Verify(&ms.key.PublicKey, digest[:], r, s) {
+		return errors.New("signature does not match MPD")
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMPDSign(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	keyFile := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+
+	server, ts := newTestServer(t, func(cfg *ServerConfig) {
+		cfg.MPDSignKey = keyFile
+	})
+
+	otherSigner, err := newMPDSigner("")
+	require.NoError(t, err)
+	fileSigner, err := newMPDSigner(keyFile)
+	require.NoError(t, err)
+	require.Equal(t, fileSigner.jwk, server.mpdSigner.jwk, "same key should give same JWK")
+	require.NotEqual(t, otherSigner.jwk.Kid, server.mpdSigner.jwk.Kid)
+
+	resp, body := testFullRequest(t, ts, "GET", "/api/mpd-signing/keys", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var keys struct {
+		Keys []JWK `json:"keys"`
+	}
+	require.NoError(t, json.Unmarshal(body, &keys))
+	require.Equal(t, []JWK{server.mpdSigner.jwk}, keys.Keys)
+
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, "", resp.Header.Get(mpdSignatureHeader))
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/mpdsign_xmldsig/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, mpd := testFullRequest(t, ts, "GET", "/livesim2/mpdsign_jws/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	sig := resp.Header.Get(mpdSignatureHeader)
+	require.Len(t, strings.Split(sig, "."), 3)
+	require.Contains(t, sig, "..", "detached payload")
+
+	otherSig, err := otherSigner.sign(mpd)
+	require.NoError(t, err)
+
+	cases := []struct {
+		desc        string
+		mpd         []byte
+		sig         string
+		wantedValid bool
+	}{
+		{desc: "valid", mpd: mpd, sig: sig, wantedValid: true},
+		{desc: "modified MPD", mpd: bytes.Replace(mpd, []byte("V300"), []byte("V301"), 1), sig: sig},
+		{desc: "other key", mpd: mpd, sig: otherSig},
+		{desc: "not detached", mpd: mpd, sig: "a.b.c"},
+	}
+	for _, c := range cases {
+		reqBody, err := json.Marshal(map[string]string{"mpd": string(c.mpd), "signature": c.sig})
+		require.NoError(t, err)
+		resp, body := testFullRequest(t, ts, "POST", "/api/mpd-signing/verify", bytes.NewReader(reqBody))
+		require.Equal(t, http.StatusOK, resp.StatusCode, c.desc)
+		var res struct {
+			Valid  bool   `json:"valid"`
+			Kid    string `json:"kid"`
+			Reason string `json:"reason"`
+		}
+		require.NoError(t, json.Unmarshal(body, &res))
+		require.Equal(t, c.wantedValid, res.Valid, c.desc)
+		require.Equal(t, server.mpdSigner.jwk.Kid, res.Kid)
+		require.Equal(t, c.wantedValid, res.Reason == "", c.desc)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// rootRoute is an endpoint outside /api.
+// It is routed by Routes and documented in the OpenAPI document of /api from the same definition,
+// so that the two cannot diverge.
+type rootRoute struct {
+	op      huma.Operation
+	pattern string // Router pattern, if different from op.Path
+	reqType string // Content type of the request body, if any
+	reqBody any    // Value of the request body type, used to generate the schema
+	body    any    // Value of the JSON response body type, or nil for op.DefaultStatus without content
+	handler func(s *Server) http.HandlerFunc
+}
+
+// routePattern returns the pattern to route rr by.
+func (rr *rootRoute) routePattern() string {
+	if rr.pattern != "" {
+		return rr.pattern
+	}
+	return rr.op.Path
+}
+
+type versionBody struct {
+	Version string
+}
+
+type latencyProbeBody struct {
+	LatencyMS int64 `json:"latencyMS" doc:"Computed latency (ms)"`
+}
+
+func rootRoutes() []rootRoute {
+	return []rootRoute{
+		{
+			op: huma.Operation{
+				OperationID: "get-healthz",
+				Method:      http.MethodGet,
+				Path:        "/healthz",
+				Summary:     "Health check",
+				Tags:        []string{"Server"},
+			},
+			body:    true,
+			handler: func(s *Server) http.HandlerFunc { return s.healthzHandlerFunc },
+		},
+		{
+			op: huma.Operation{
+				OperationID: "get-version",
+				Method:      http.MethodGet,
+				Path:        "/version",
+				Summary:     "Server version",
+				Tags:        []string{"Server"},
+			},
+			body:    versionBody{},
+			handler: func(s *Server) http.HandlerFunc { return s.versionHandlerFunc },
+		},
+		{
+			op: huma.Operation{
+				OperationID: "get-config",
+				Method:      http.MethodGet,
+				Path:        "/config",
+				Summary:     "Server configuration",
+				Tags:        []string{"Server"},
+			},
+			body:    ServerConfig{},
+			handler: func(s *Server) http.HandlerFunc { return s.configHandlerFunc },
+		},
+		{
+			op: huma.Operation{
+				OperationID: "post-latency-probe",
+				Method:      http.MethodPost,
+				Path:        latencyProbePath,
+				Summary:     "Report an observed latency probe",
+				Description: "An instrumented player reports the ID of a latency probe emsg, and gets the glass-to-glass " +
+					"latency, which is added to the statistics of /api/stats/latency.",
+				Tags:   []string{"Debug"},
+				Errors: []int{400, 404},
+			},
+			reqType: "application/json",
+			reqBody: latencyReport{},
+			body:    latencyProbeBody{},
+			handler: func(s *Server) http.HandlerFunc { return s.latencyProbeHandlerFunc },
+		},
+		{
+			op: huma.Operation{
+				OperationID: "post-qoe-report",
+				Method:      http.MethodPost,
+				Path:        qoePathPrefix + "/{mpdPath}",
+				Summary:     "Receive a DASH metrics report",
+				Description: "Reporting URL signaled by the qoe URL parameter. The report is kept for the MPD path, " +
+					"and is available via /api/qoe-reports. Requires --qoereports.",
+				Tags:          []string{"Debug"},
+				DefaultStatus: http.StatusNoContent,
+				Errors:        []int{400, 404},
+				Parameters: []*huma.Param{{
+					Name:        "mpdPath",
+					In:          "path",
+					Required:    true,
+					Description: "URL path of the MPD, starting with livesim2/",
+					Schema:      &huma.Schema{Type: huma.TypeString},
+				}},
+			},
+			pattern: qoePathPrefix + "/*",
+			reqType: "*/*",
+			reqBody: "",
+			handler: func(s *Server) http.HandlerFunc { return s.qoeHandlerFunc },
+		},
+	}
+}
+
+// documentRootRoutes adds the operations of the root routes to the OpenAPI document of api.
+// Since the document is served under /api, they get their own server URL.
+func documentRootRoutes(api huma.API) {
+	oapi := api.OpenAPI()
+	for _, rr := range rootRoutes() {
+		op := rr.op
+		op.Servers = []*huma.Server{{URL: "/", Description: "Server root"}}
+		if rr.reqBody != nil {
+			schema := oapi.Components.Schemas.Schema(reflect.TypeOf(rr.reqBody), true, "")
+			op.RequestBody = &huma.RequestBody{
+				Required: true,
+				Content:  map[string]*huma.MediaType{rr.reqType: {Schema: schema}},
+			}
+		}
+		op.Responses = make(map[string]*huma.Response)
+		if rr.body != nil {
+			schema := oapi.Components.Schemas.Schema(reflect.TypeOf(rr.body), true, "")
+			op.Responses["200"] = &huma.Response{
+				Description: "OK",
+				Content:     map[string]*huma.MediaType{"application/json": {Schema: schema}},
+			}
+		} else {
+			op.Responses[strconv.Itoa(op.DefaultStatus)] = &huma.Response{Description: http.StatusText(op.DefaultStatus)}
+		}
+		for _, code := range op.Errors {
+			op.Responses[strconv.Itoa(code)] = &huma.Response{
+				Description: http.StatusText(code),
+				Content:     map[string]*huma.MediaType{problemContentType: {}},
+			}
+		}
+		oapi.AddOperation(&op)
+	}
+}
+
+// swaggerUIPage shows the OpenAPI document of /api in Swagger UI.
+// The Swagger UI scripts and styles are loaded from the unpkg CDN, so the browser needs Internet access.
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8" />
+  <title>Livesim2 API - Swagger UI</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css" />
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = () => {
+      window.ui = SwaggerUIBundle({url: "openapi.json", dom_id: "#swagger-ui"});
+    };
+  </script>
+</body>
+</html>
+`
+
+func swaggerUIHandlerFunc(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(swaggerUIPage))
+}
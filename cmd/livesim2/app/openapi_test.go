@@ -0,0 +1,42 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPI(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	resp, body := testFullRequest(t, ts, "GET", "/api/openapi.json", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var doc struct {
+		Paths map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+		} `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(body, &doc))
+	for _, p := range []string{"/cmaf-ingests", "/sessions", "/inspect", "/explain-config"} {
+		require.Contains(t, doc.Paths, p)
+	}
+	for _, rr := range rootRoutes() {
+		require.Contains(t, doc.Paths, rr.op.Path)
+		if rr.op.Method != http.MethodGet {
+			continue
+		}
+		resp, body = testFullRequest(t, ts, rr.op.Method, rr.op.Path, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode, rr.op.Path)
+		require.True(t, json.Valid(body), rr.op.Path)
+	}
+
+	resp, body = testFullRequest(t, ts, "GET", "/api/swagger", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), "SwaggerUIBundle")
+}
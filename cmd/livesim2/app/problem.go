@@ -0,0 +1,84 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+const problemContentType = "application/problem+json"
+
+// Reason codes used in problem+json error responses.
+const (
+	reasonTooEarly         = "tooEarly"
+	reasonGone             = "gone"
+	reasonNotFound         = "notFound"
+	reasonUnknownParameter = "unknownParameter"
+	reasonBadCombination   = "badCombination"
+	reasonBadValue         = "badValue"
+	reasonBadQuery         = "badQuery"
+	reasonUnknownExtension = "unknownExtension"
+	reasonTrafficLoss      = "trafficLoss"
+	reasonTriggeredStatus  = "triggeredStatus"
+	reasonForbidden        = "forbidden"
+	reasonInternal         = "internalError"
+	reasonHookVeto         = "hookVeto"
+	reasonQuotaExceeded    = "quotaExceeded"
+	reasonUpstream         = "upstreamError"
+)
+
+// problemDetails is an RFC 7807 problem details object extended with a reason code.
+type problemDetails struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Reason   string `json:"reason"`
+	// Issues lists offending URL parts for URL parameter errors.
+	Issues []urlParamIssue `json:"issues,omitempty"`
+}
+
+// writeProblem writes an application/problem+json response.
+// Like http.Error, it should be the last write to w.
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, reason, detail string) {
+	writeProblemDetails(w, r, newProblemDetails(status, reason, detail))
+}
+
+// writeHttpTypeProblem writes errHT as an application/problem+json response.
+func writeHttpTypeProblem(w http.ResponseWriter, r *http.Request, errHT *errorWithHttpType) {
+	p := newProblemDetails(errHT.statusCode, errHT.reason, errHT.msg)
+	p.Issues = errHT.issues
+	writeProblemDetails(w, r, p)
+}
+
+func newProblemDetails(status int, reason, detail string) problemDetails {
+	return problemDetails{
+		Type:   "urn:livesim2:problem:" + reason,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Reason: reason,
+	}
+}
+
+func writeProblemDetails(w http.ResponseWriter, r *http.Request, p problemDetails) {
+	status := p.Status
+	if r != nil {
+		p.Instance = r.URL.RequestURI()
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		http.Error(w, p.Detail, status)
+		return
+	}
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", problemContentType)
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,49 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProblemResponses(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	testCases := []struct {
+		desc         string
+		url          string
+		wantedStatus int
+		wantedReason string
+	}{
+		{"too early", "/livesim2/testpic_2s/V300/100.m4s?nowMS=180000", http.StatusTooEarly, reasonTooEarly},
+		{"gone", "/livesim2/testpic_2s/V300/10.m4s?nowMS=200000", http.StatusGone, reasonGone},
+		{"unknown asset", "/livesim2/nothing/Manifest.mpd?nowMS=100000", http.StatusNotFound, reasonNotFound},
+		{"bad value", "/livesim2/tsbd_a/testpic_2s/Manifest.mpd?nowMS=100000", http.StatusBadRequest, reasonBadValue},
+		{"bad query", "/livesim2/testpic_2s/Manifest.mpd?nowMS=abc", http.StatusBadRequest, reasonBadQuery},
+		{"unknown parameter", "/livesim2/modulo_10/testpic_2s/Manifest.mpd?nowMS=100000",
+			http.StatusBadRequest, reasonUnknownParameter},
+		{"bad combination", "/livesim2/segtimeline_1/segtimelinenr_1/testpic_2s/Manifest.mpd?nowMS=100000",
+			http.StatusBadRequest, reasonBadCombination},
+		{"triggered status", "/livesim2/statuscode_[{cycle:30,rsq:0,code:404}]/testpic_2s/V300/45.m4s?nowMS=100000",
+			http.StatusNotFound, reasonTriggeredStatus},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			resp, body := testFullRequest(t, ts, "GET", tc.url, nil)
+			require.Equal(t, tc.wantedStatus, resp.StatusCode)
+			require.Equal(t, problemContentType, resp.Header.Get("Content-Type"))
+			var p problemDetails
+			require.NoError(t, json.Unmarshal(body, &p))
+			require.Equal(t, tc.wantedStatus, p.Status)
+			require.Equal(t, tc.wantedReason, p.Reason)
+			require.Equal(t, http.StatusText(tc.wantedStatus), p.Title)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/Eyevinn/mp4ff/mp4"
+)
+
+// programSchemeIdUri is used for MPD and inband events signaling program boundaries.
+const programSchemeIdUri = "urn:livesim2:program:2024"
+
+const defaultProgramTitle = "Program"
+
+// Programs configures a schedule of back-to-back programs of DurS seconds each, starting at multiples
+// of DurS wall-clock time since the Epoch. Each program boundary is signaled by an event with
+// the program metadata as JSON message data. The events are in an MPD EventStream with the current
+// and next programs, or, if Inband is set, in emsg boxes of the video segments where the programs start.
+type Programs struct {
+	DurS   int    `json:"DurS"`
+	Title  string `json:"Title,omitempty"` // Followed by the program number
+	Inband bool   `json:"Inband,omitempty"`
+}
+
+// programInfo is the message data of a program boundary event.
+type programInfo struct {
+	ID    string    `json:"id"`
+	Title string    `json:"title"`
+	Start time.Time `json:"start"`
+	DurS  int       `json:"durS"`
+}
+
+func (pr *Programs) validate() error {
+	if pr.DurS <= 0 {
+		return fmt.Errorf("program duration %ds must be > 0", pr.DurS)
+	}
+	return nil
+}
+
+// info returns the metadata of program nr, starting at nr*DurS since the Epoch.
+func (pr *Programs) info(nr int) programInfo {
+	title := pr.Title
+	if title == "" {
+		title = defaultProgramTitle
+	}
+	return programInfo{
+		ID:    fmt.Sprintf("prog-%d", nr),
+		Title: fmt.Sprintf("%s %d", title, nr),
+		Start: time.Unix(int64(nr*pr.DurS), 0).UTC(),
+		DurS:  pr.DurS,
+	}
+}
+
+func (pr *Programs) messageData(nr int) string {
+	data, _ := json.Marshal(pr.info(nr)) // Cannot fail
+	return string(data)
+}
+
+// programEmsgs returns emsg boxes for the programs starting in the segment [segStart, segEnd).
+// The times are in timescale units with startTimeS as offset to wall-clock time.
+func programEmsgs(pr *Programs, segStart, segEnd, timescale uint64, startTimeS int) []*mp4.EmsgBox {
+	offset := uint64(startTimeS) * timescale
+	progDur := uint64(pr.DurS) * timescale
+	var emsgs []*mp4.EmsgBox
+	for t := (segStart + offset + progDur - 1) / progDur * progDur; t < segEnd+offset; t += progDur {
+		nr := int(t / progDur)
+		emsgs = append(emsgs, &mp4.EmsgBox{
+			Version:          1,
+			TimeScale:        uint32(timescale),
+			PresentationTime: t - offset,
+			EventDuration:    uint32(progDur),
+			ID:               uint32(nr),
+			SchemeIDURI:      programSchemeIdUri,
+			MessageData:      []byte(pr.messageData(nr)),
+		})
+	}
+	return emsgs
+}
+
+// addProgramEvents adds EventStreams with the programs from the start of the time-shift window
+// to the one after the current one (now and next) to the Periods where they start.
+// The event times are relative to the Period start in milliseconds.
+func addProgramEvents(mpd *m.MPD, pr *Programs, startTimeS, nowMS int) {
+	windowStartMS := 0
+	if mpd.TimeShiftBufferDepth != nil {
+		windowStartMS = nowMS - int(time.Duration(*mpd.TimeShiftBufferDepth).Milliseconds())
+	}
+	if windowStartMS < startTimeS*1000 {
+		windowStartMS = startTimeS * 1000
+	}
+	durMS := pr.DurS * 1000
+	firstNr, nextNr := windowStartMS/durMS, nowMS/durMS+1
+	for i, p := range mpd.Periods {
+		pStartMS := periodStartMS(p)
+		pEndMS := -1
+		if i < len(mpd.Periods)-1 {
+			pEndMS = periodStartMS(mpd.Periods[i+1])
+		}
+		var es *m.EventStreamType
+		for nr := firstNr; nr <= nextNr; nr++ {
+			tMS := nr*durMS - startTimeS*1000
+			if tMS < pStartMS || (pEndMS >= 0 && tMS >= pEndMS) {
+				continue
+			}
+			if es == nil {
+				es = &m.EventStreamType{SchemeIdUri: programSchemeIdUri, Timescale: Ptr(uint32(1000))}
+				p.EventStreams = append(p.EventStreams, es)
+			}
+			es.Events = append(es.Events, &m.EventType{
+				PresentationTime: uint64(tMS - pStartMS),
+				Duration:         uint64(durMS),
+				Id:               uint32(nr),
+				MessageData:      pr.messageData(nr),
+			})
+		}
+	}
+}
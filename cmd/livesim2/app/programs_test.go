@@ -0,0 +1,76 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/Eyevinn/mp4ff/mp4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrograms(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	resp, body := testFullRequest(t, ts, "GET", "/livesim2/tsbd_60/programs_30/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	mpd, err := m.ReadFromString(string(body))
+	require.NoError(t, err)
+	require.Len(t, mpd.Periods[0].EventStreams, 1)
+	es := mpd.Periods[0].EventStreams[0]
+	require.Equal(t, programSchemeIdUri, string(es.SchemeIdUri))
+	// Window [40s, 100s] has programs starting at 30, 60, 90 and the next one at 120
+	require.Len(t, es.Events, 4)
+	for i, e := range es.Events {
+		nr := 1 + i
+		require.Equal(t, uint32(nr), e.Id)
+		require.Equal(t, uint64(nr*30_000), e.PresentationTime)
+		require.Equal(t, uint64(30_000), e.Duration)
+		var info programInfo
+		require.NoError(t, json.Unmarshal([]byte(e.MessageData), &info))
+		require.Equal(t, fmt.Sprintf("Program %d", nr), info.Title)
+		require.Equal(t, time.Unix(int64(nr*30), 0).UTC(), info.Start)
+	}
+
+	// Inband variant
+	resp, body = testFullRequest(t, ts, "GET", "/livesim2/programs_30_inband/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NotContains(t, string(body), "<EventStream")
+	require.Contains(t, string(body), `<InbandEventStream schemeIdUri="urn:livesim2:program:2024"`)
+	for _, tc := range []struct {
+		nr       int
+		nrEmsgs  int
+		progNr   int
+		progTime uint64
+	}{
+		{nr: 30, nrEmsgs: 1, progNr: 2, progTime: 60},
+		{nr: 31, nrEmsgs: 0},
+	} {
+		url := fmt.Sprintf("/livesim2/programs_30_inband/testpic_2s/V300/%d.m4s?nowMS=100000", tc.nr)
+		resp, body = testFullRequest(t, ts, "GET", url, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		f, err := mp4.DecodeFile(bytes.NewBuffer(body))
+		require.NoError(t, err)
+		emsgs := f.Segments[0].Fragments[0].Emsgs
+		require.Len(t, emsgs, tc.nrEmsgs)
+		if tc.nrEmsgs == 0 {
+			continue
+		}
+		require.Equal(t, uint32(tc.progNr), emsgs[0].ID)
+		require.Equal(t, tc.progTime*uint64(emsgs[0].TimeScale), emsgs[0].PresentationTime)
+		var info programInfo
+		require.NoError(t, json.Unmarshal(emsgs[0].MessageData, &info))
+		require.Equal(t, "prog-2", info.ID)
+	}
+
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/programs_0/testpic_2s/Manifest.mpd", nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
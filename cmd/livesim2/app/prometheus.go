@@ -13,6 +13,7 @@ import (
 var (
 	defaultBuckets = []float64{5, 10, 20, 50, 100, 200, 500, 1000}
 	prometheusMW   prometheusMiddleware
+	mirrorResults  *prometheus.CounterVec
 )
 
 const (
@@ -22,6 +23,7 @@ const (
 	mpdLatencyName     = "mpd_request_duration_milliseconds"
 	otherReqsName      = "other_requests_total"
 	otherLatencyName   = "other_request_duration_milliseconds"
+	mirrorResultsName  = "mirror_requests_total"
 )
 
 // prometheusMiddleware provides a handler that exposes prometheus metrics for various requests
@@ -47,6 +49,8 @@ func init() {
 		"Number other requests processed, partitioned by status code.", "livesim2")
 	prometheusMW.otherLatency = newHistogram(otherLatencyName,
 		"Other response latency.", "livesim2", defaultBuckets)
+	mirrorResults = newCounter(mirrorResultsName,
+		"Number mirrored requests, partitioned by outcome (match, status, size, error).", "livesim2")
 }
 
 // NewPrometheusMiddleware returns a new prometheus Middleware handler.
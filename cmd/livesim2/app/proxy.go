@@ -0,0 +1,318 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+)
+
+const (
+	// proxyPathPrefix starts the content part of livesim2 URLs for proxied upstream origins.
+	proxyPathPrefix = "proxy/"
+	proxyTimeout    = 30 * time.Second
+)
+
+// proxyRequestHeaders are forwarded to the upstream origin.
+var proxyRequestHeaders = []string{"Accept", "Range", "User-Agent", "If-None-Match", "If-Modified-Since"}
+
+// proxyResponseHeaders are copied from the upstream response.
+var proxyResponseHeaders = []string{"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges",
+	"Cache-Control", "Expires", "ETag", "Last-Modified"}
+
+// ProxyConfig defines an upstream origin served through livesim2 at /livesim2/proxy/<name>/.
+type ProxyConfig struct {
+	Name string `json:"name"`
+	// Origin is the upstream base URL to which the rest of the path is appended
+	Origin string `json:"origin"`
+	// AdAsset is the asset played in ad Periods inserted by the ssai URL parameter
+	AdAsset string `json:"adAsset,omitempty"`
+	// Cache enables an origin shield cache of upstream responses
+	Cache *ProxyCacheConfig `json:"cache,omitempty"`
+}
+
+// proxy is a validated proxy configuration.
+type proxy struct {
+	name    string
+	origin  *url.URL
+	adAsset *asset
+	adMPD   string // Name of the ad asset MPD used for ad Periods
+	client  *http.Client
+	cache   *proxyCache
+}
+
+// newProxies validates the proxy configurations and resolves their ad assets.
+func newProxies(cfgs []ProxyConfig, am *assetMgr) (map[string]*proxy, error) {
+	proxies := make(map[string]*proxy, len(cfgs))
+	for _, pc := range cfgs {
+		if pc.Name == "" || strings.Contains(pc.Name, "/") {
+			return nil, fmt.Errorf("proxy name %q must be non-empty without /", pc.Name)
+		}
+		if _, ok := proxies[pc.Name]; ok {
+			return nil, fmt.Errorf("proxy %q defined twice", pc.Name)
+		}
+		u, err := url.Parse(pc.Origin)
+		if err != nil {
+			return nil, fmt.Errorf("proxy %q origin: %w", pc.Name, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("proxy %q origin %q: scheme must be http or https", pc.Name, pc.Origin)
+		}
+		px := proxy{name: pc.Name, origin: u, client: &http.Client{Timeout: proxyTimeout}}
+		if pc.AdAsset != "" {
+			a, ok := am.assets[pc.AdAsset]
+			if !ok {
+				return nil, fmt.Errorf("proxy %q: unknown ad asset %q", pc.Name, pc.AdAsset)
+			}
+			mpdNames := make([]string, 0, len(a.MPDs))
+			for name := range a.MPDs {
+				mpdNames = append(mpdNames, name)
+			}
+			if len(mpdNames) == 0 {
+				return nil, fmt.Errorf("proxy %q: ad asset %q has no MPD", pc.Name, pc.AdAsset)
+			}
+			sort.Strings(mpdNames)
+			px.adAsset = a
+			px.adMPD = mpdNames[0]
+		}
+		if pc.Cache != nil {
+			if err := pc.Cache.validate(); err != nil {
+				return nil, fmt.Errorf("proxy %q: %w", pc.Name, err)
+			}
+			px.cache = newProxyCache(*pc.Cache)
+		}
+		proxies[pc.Name] = &px
+	}
+	return proxies, nil
+}
+
+// findProxy returns the proxy and the rest of the content part, if the content part is for a proxy.
+func (s *Server) findProxy(contentPart string) (*proxy, string, bool) {
+	name, rest, ok := strings.Cut(strings.TrimPrefix(contentPart, proxyPathPrefix), "/")
+	if !ok || !strings.HasPrefix(contentPart, proxyPathPrefix) {
+		return nil, "", false
+	}
+	px, ok := s.proxies[name]
+	return px, rest, ok
+}
+
+// upstreamURL returns the upstream URL for the rest of the content part and the request query.
+func (px *proxy) upstreamURL(rest, rawQuery string) string {
+	u := strings.TrimSuffix(px.origin.String(), "/") + "/" + rest
+	if rawQuery != "" {
+		u += "?" + rawQuery
+	}
+	return u
+}
+
+// checkProxyCfg returns an error for URL parameters that cannot be applied to proxied content.
+func checkProxyCfg(cfg *ResponseConfig, px *proxy) error {
+	switch {
+	case cfg.SSAI != nil && px.adAsset == nil:
+		return fmt.Errorf("ssai requires an adAsset for proxy %q", px.name)
+	case len(cfg.SegStatusCodes) > 0:
+		return fmt.Errorf("statuscode cannot be combined with proxies, use chaos or traffic")
+	}
+	return nil
+}
+
+// writeProxy handles MPD and segment requests for a proxied upstream.
+// The fault-injection parameters chaos, wasm, throttle, and traffic are applied as for livesim2 content.
+// MPDs are rewritten if ssai or traffic is configured. Everything else is passed through.
+func (s *Server) writeProxy(w http.ResponseWriter, r *http.Request, log *slog.Logger, cfg *ResponseConfig,
+	px *proxy, rest string, nowMS int) {
+	if err := checkProxyCfg(cfg, px); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, reasonBadCombination, err.Error())
+		return
+	}
+	isMPD := filepath.Ext(rest) == ".mpd"
+	if cfg.Chaos != nil {
+		var done bool
+		w, nowMS, done = applyChaos(w, r, log, cfg.Chaos, proxyPathPrefix+px.name+"/"+rest, isMPD, nowMS)
+		if done {
+			return
+		}
+	}
+	if cfg.WasmPlugin != "" {
+		var done bool
+		w, done = s.applyWasmPlugin(w, r, log, cfg.WasmPlugin, isMPD, nowMS)
+		if done {
+			return
+		}
+	}
+	if cfg.ThrottleKbps != nil {
+		w = &throttledWriter{ResponseWriter: w, ctx: r.Context(), limiter: newBwLimiter(*cfg.ThrottleKbps)}
+	}
+	if !isMPD && len(cfg.Traffic) > 0 {
+		segmentPart, done := applyTrafficLoss(w, r, cfg, "/"+rest, nowMS)
+		if done {
+			return
+		}
+		rest = segmentPart[1:]
+	}
+	upURL := px.upstreamURL(rest, r.URL.RawQuery)
+	if !isMPD || (cfg.SSAI == nil && len(cfg.Traffic) == 0) {
+		px.passThrough(w, r, log, upURL)
+		return
+	}
+	e, cacheStatus, err := px.fetch(r.Context(), upURL, r.Header)
+	if err == nil && e.status != http.StatusOK {
+		err = fmt.Errorf("get %s: status %d", upURL, e.status)
+	}
+	if err != nil {
+		log.Warn("proxy MPD", "url", upURL, "err", err)
+		writeProblem(w, r, http.StatusBadGateway, reasonUpstream, err.Error())
+		return
+	}
+	if cacheStatus != "" {
+		w.Header().Set(cacheStatusHeader, cacheStatus)
+	}
+	mpd, err := m.ReadFromString(string(e.body))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadGateway, reasonUpstream, fmt.Sprintf("upstream MPD: %s", err))
+		return
+	}
+	if cfg.SSAI != nil {
+		if err := cfg.SSAI.insertAds(mpd, px, cfg.Host, nowMS); err != nil {
+			writeProblem(w, r, http.StatusBadGateway, reasonUpstream, err.Error())
+			return
+		}
+	}
+	if len(cfg.Traffic) > 0 {
+		addTrafficBaseURLs(mpd, len(cfg.Traffic))
+	}
+	if _, err := writeMPD(log, w, cfg, mpd, func(lMPD *m.MPD) error { return s.hooks.rewriteMPD(r, lMPD) },
+		s.mpdSigner); err != nil {
+		log.Error("writeMPD", "err", err)
+	}
+}
+
+// addTrafficBaseURLs makes the upstream Periods use one BaseURL per traffic pattern.
+// Relative Period BaseURLs are prefixed by the patterns, and absolute ones are left unchanged,
+// since their segments are not requested through livesim2.
+func addTrafficBaseURLs(mpd *m.MPD, nrPatterns int) {
+	for _, p := range mpd.Periods {
+		if len(p.BaseURLs) == 0 {
+			p.BaseURLs = []*m.BaseURLType{{}}
+		}
+		var bus []*m.BaseURLType
+		for _, bu := range p.BaseURLs {
+			if u, err := url.Parse(string(bu.Value)); err != nil || u.IsAbs() || strings.HasPrefix(u.Path, "/") {
+				bus = append(bus, bu)
+				continue
+			}
+			for bNr := 0; bNr < nrPatterns; bNr++ {
+				nb := *bu
+				nb.Value = m.AnyURI(baseURL(bNr)) + bu.Value
+				bus = append(bus, &nb)
+			}
+		}
+		p.BaseURLs = bus
+	}
+}
+
+// fetch returns the full upstream response to a GET request, and the cache status if there is a cache.
+func (px *proxy) fetch(ctx context.Context, upURL string, hdr http.Header) (*cacheEntry, string, error) {
+	hdr = hdr.Clone() // May be used in a background revalidation
+	fetch := func(ctx context.Context) (*cacheEntry, error) {
+		return px.fetchEntry(ctx, upURL, hdr)
+	}
+	if px.cache == nil {
+		e, err := fetch(ctx)
+		return e, "", err
+	}
+	return px.cache.get(ctx, upURL, fetch)
+}
+
+// fetchEntry makes a GET request to upstream and reads the response.
+func (px *proxy) fetchEntry(ctx context.Context, upURL string, hdr http.Header) (*cacheEntry, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, upURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range proxyRequestHeaders {
+		if v := hdr.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+	resp, err := px.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", upURL, err)
+	}
+	e := cacheEntry{status: resp.StatusCode, header: make(http.Header), body: body}
+	for _, h := range proxyResponseHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			e.header.Set(h, v)
+		}
+	}
+	return &e, nil
+}
+
+// passThrough forwards the request to upstream and copies the response.
+// GET requests without Range go through the cache if there is one.
+func (px *proxy) passThrough(w http.ResponseWriter, r *http.Request, log *slog.Logger, upURL string) {
+	if px.cache != nil && r.Method == http.MethodGet && r.Header.Get("Range") == "" {
+		e, cacheStatus, err := px.fetch(r.Context(), upURL, r.Header)
+		if err != nil {
+			log.Warn("proxy request", "url", upURL, "err", err)
+			writeProblem(w, r, http.StatusBadGateway, reasonUpstream, "upstream request failed")
+			return
+		}
+		for h := range e.header {
+			w.Header().Set(h, e.header.Get(h))
+		}
+		if cacheStatus != "MISS" {
+			w.Header().Set("Age", strconv.Itoa(int(px.cache.now().Sub(e.storedAt).Seconds())))
+		}
+		w.Header().Set(cacheStatusHeader, cacheStatus)
+		w.WriteHeader(e.status)
+		if _, err := w.Write(e.body); err != nil {
+			log.Debug("proxy write", "url", upURL, "err", err)
+		}
+		return
+	}
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, upURL, nil)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, reasonBadValue, err.Error())
+		return
+	}
+	for _, h := range proxyRequestHeaders {
+		if v := r.Header.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+	resp, err := px.client.Do(req)
+	if err != nil {
+		log.Warn("proxy request", "url", upURL, "err", err)
+		writeProblem(w, r, http.StatusBadGateway, reasonUpstream, "upstream request failed")
+		return
+	}
+	defer resp.Body.Close()
+	for _, h := range proxyResponseHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		log.Debug("proxy copy", "url", upURL, "err", err)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyPassThrough(t *testing.T) {
+	upstream, ts := setupProxyServers(t)
+	defer upstream.Close()
+	defer ts.Close()
+
+	_, upBody := testFullRequest(t, upstream, "GET", "/livesim2/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	resp, body := testFullRequest(t, ts, "GET", "/livesim2/proxy/up/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/dash+xml", resp.Header.Get("Content-Type"))
+	require.Equal(t, string(upBody), string(body))
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/proxy/up/testpic_2s/V300/nosuch.m4s", nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestProxyFaults(t *testing.T) {
+	upstream, ts := setupProxyServers(t)
+	defer upstream.Close()
+	defer ts.Close()
+
+	// Traffic patterns give one BaseURL each. At 5s, the first pattern is up and the second is down.
+	prefix := "/livesim2/traffic_u10d10,d10u10/proxy/up/"
+	resp, body := testFullRequest(t, ts, "GET", prefix+"testpic_2s/Manifest.mpd?nowMS=5000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	mpd, err := m.ReadFromString(string(body))
+	require.NoError(t, err)
+	bus := mpd.Periods[0].BaseURLs
+	require.Len(t, bus, 2)
+	require.Equal(t, m.AnyURI("bu0/"), bus[0].Value)
+	require.Equal(t, m.AnyURI("bu1/"), bus[1].Value)
+	resp, _ = testFullRequest(t, ts, "GET", prefix+"bu0/testpic_2s/V300/1.m4s?nowMS=5000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = testFullRequest(t, ts, "GET", prefix+"bu1/testpic_2s/V300/1.m4s?nowMS=5000", nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Throttling delays the response according to its size
+	start := time.Now()
+	resp, body = testFullRequest(t, ts, "GET", "/livesim2/throttle_4000/proxy/up/testpic_2s/V300/1.m4s?nowMS=5000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	minDur := time.Duration(len(body)*8/4000) * time.Millisecond
+	require.GreaterOrEqual(t, time.Since(start), minDur*9/10)
+
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/throttle_0/proxy/up/testpic_2s/V300/1.m4s", nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	statusURL := "/livesim2/statuscode_[{cycle:30,rsq:0,code:404}]/proxy/up/testpic_2s/V300/1.m4s"
+	resp, body = testFullRequest(t, ts, "GET", statusURL, nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Contains(t, string(body), "statuscode cannot be combined with proxies")
+}
@@ -0,0 +1,282 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultProxyCacheEntries = 1000
+	// cacheStatusHeader tells whether a proxied response came from the cache (HIT, STALE) or upstream (MISS).
+	cacheStatusHeader = "X-Cache"
+)
+
+// ProxyCacheConfig emulates an origin shield cache in front of the upstream of a proxy.
+// The TTL is taken from s-maxage or max-age in the upstream Cache-Control header, with the
+// stale-while-revalidate and stale-if-error extensions. no-store, no-cache, and private responses are not cached.
+type ProxyCacheConfig struct {
+	// DefaultTTLS is the TTL of 200 responses without max-age. 0 means not cached.
+	DefaultTTLS int `json:"defaultTTLS,omitempty"`
+	// NegativeTTLS is the TTL of 404 and 410 responses without max-age. 0 means not cached.
+	NegativeTTLS int `json:"negativeTTLS,omitempty"`
+	// MaxEntries limits the number of entries. The oldest entry is evicted first. 0 means 1000.
+	MaxEntries int `json:"maxEntries,omitempty"`
+}
+
+func (pc *ProxyCacheConfig) validate() error {
+	if pc.DefaultTTLS < 0 || pc.NegativeTTLS < 0 || pc.MaxEntries < 0 {
+		return fmt.Errorf("cache values must be >= 0")
+	}
+	return nil
+}
+
+// cacheEntry is an upstream response, possibly stored in the cache.
+type cacheEntry struct {
+	status       int
+	header       http.Header
+	body         []byte
+	storedAt     time.Time
+	ttl          time.Duration
+	swr          time.Duration // stale-while-revalidate
+	sie          time.Duration // stale-if-error
+	hits         int
+	revalidating bool
+}
+
+type cacheState string
+
+const (
+	cacheFresh   cacheState = "fresh"
+	cacheStale   cacheState = "stale" // May be served while revalidating
+	cacheExpired cacheState = "expired"
+)
+
+func (e *cacheEntry) state(now time.Time) cacheState {
+	age := now.Sub(e.storedAt)
+	switch {
+	case age < e.ttl:
+		return cacheFresh
+	case age < e.ttl+e.swr:
+		return cacheStale
+	default:
+		return cacheExpired
+	}
+}
+
+// cacheFill is an upstream request for a missing entry. Concurrent misses wait for it, like request collapsing in a CDN.
+type cacheFill struct {
+	done chan struct{}
+	e    *cacheEntry
+	err  error
+}
+
+// proxyCache is an in-memory cache of upstream responses keyed by upstream URL.
+type proxyCache struct {
+	cfg     ProxyCacheConfig
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	fills   map[string]*cacheFill
+	now     func() time.Time
+}
+
+func newProxyCache(cfg ProxyCacheConfig) *proxyCache {
+	if cfg.MaxEntries == 0 {
+		cfg.MaxEntries = defaultProxyCacheEntries
+	}
+	return &proxyCache{
+		cfg:     cfg,
+		entries: make(map[string]*cacheEntry),
+		fills:   make(map[string]*cacheFill),
+		now:     time.Now,
+	}
+}
+
+// get returns the response for key and the cache status HIT, STALE, or MISS.
+// A stale entry within stale-while-revalidate is served while it is refetched in the background.
+// If the upstream request fails or gives a 5xx response, an entry within stale-if-error is served instead.
+func (pc *proxyCache) get(ctx context.Context, key string,
+	fetch func(context.Context) (*cacheEntry, error)) (*cacheEntry, string, error) {
+	pc.mu.Lock()
+	now := pc.now()
+	old := pc.entries[key]
+	if old != nil {
+		switch old.state(now) {
+		case cacheFresh:
+			old.hits++
+			pc.mu.Unlock()
+			return old, "HIT", nil
+		case cacheStale:
+			old.hits++
+			if !old.revalidating {
+				old.revalidating = true
+				go pc.revalidate(key, old, fetch)
+			}
+			pc.mu.Unlock()
+			return old, "STALE", nil
+		}
+	}
+	if f, ok := pc.fills[key]; ok {
+		pc.mu.Unlock()
+		select {
+		case <-f.done:
+			return f.e, "HIT", f.err
+		case <-ctx.Done():
+			return nil, "", ctx.Err()
+		}
+	}
+	f := &cacheFill{done: make(chan struct{})}
+	pc.fills[key] = f
+	pc.mu.Unlock()
+
+	e, err := fetch(ctx)
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	delete(pc.fills, key)
+	status := "MISS"
+	switch {
+	case (err != nil || e.status >= 500) && old != nil && pc.now().Sub(old.storedAt) < old.ttl+old.sie:
+		old.hits++
+		e, err, status = old, nil, "STALE"
+	case err == nil:
+		pc.store(key, e)
+	}
+	f.e, f.err = e, err
+	close(f.done)
+	return e, status, err
+}
+
+// revalidate refetches a stale entry and replaces it, unless the upstream request fails.
+func (pc *proxyCache) revalidate(key string, old *cacheEntry, fetch func(context.Context) (*cacheEntry, error)) {
+	ctx, cancel := context.WithTimeout(context.Background(), proxyTimeout)
+	defer cancel()
+	e, err := fetch(ctx)
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	old.revalidating = false
+	if err != nil || e.status >= 500 {
+		slog.Debug("proxy cache revalidation failed", "url", key, "err", err)
+		return
+	}
+	pc.store(key, e)
+}
+
+// store stores e if it is cacheable, or removes the entry for key if not. The lock must be held.
+func (pc *proxyCache) store(key string, e *cacheEntry) {
+	var ok bool
+	e.ttl, e.swr, e.sie, ok = cacheLifetimes(e.status, e.header.Get("Cache-Control"), &pc.cfg)
+	if !ok {
+		delete(pc.entries, key)
+		return
+	}
+	if _, exists := pc.entries[key]; !exists && len(pc.entries) >= pc.cfg.MaxEntries {
+		var oldestKey string
+		for k, oe := range pc.entries {
+			if oldestKey == "" || oe.storedAt.Before(pc.entries[oldestKey].storedAt) {
+				oldestKey = k
+			}
+		}
+		delete(pc.entries, oldestKey)
+	}
+	e.storedAt = pc.now()
+	pc.entries[key] = e
+}
+
+// cacheLifetimes returns the TTL, stale-while-revalidate, and stale-if-error durations of a response,
+// and false if it should not be cached.
+func cacheLifetimes(status int, cacheControl string, cfg *ProxyCacheConfig) (ttl, swr, sie time.Duration, ok bool) {
+	directives := make(map[string]string)
+	for _, d := range strings.Split(cacheControl, ",") {
+		name, val, _ := strings.Cut(strings.TrimSpace(d), "=")
+		directives[strings.ToLower(name)] = strings.Trim(val, `"`)
+	}
+	for _, d := range []string{"no-store", "no-cache", "private"} {
+		if _, found := directives[d]; found {
+			return 0, 0, 0, false
+		}
+	}
+	seconds := func(name string) (time.Duration, bool) {
+		s, err := strconv.Atoi(directives[name])
+		if err != nil || s < 0 {
+			return 0, false
+		}
+		return time.Duration(s) * time.Second, true
+	}
+	switch status {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo:
+		ttl = time.Duration(cfg.DefaultTTLS) * time.Second
+	case http.StatusNotFound, http.StatusGone:
+		ttl = time.Duration(cfg.NegativeTTLS) * time.Second
+	default:
+		return 0, 0, 0, false
+	}
+	if d, found := seconds("s-maxage"); found {
+		ttl = d
+	} else if d, found := seconds("max-age"); found {
+		ttl = d
+	}
+	swr, _ = seconds("stale-while-revalidate")
+	sie, _ = seconds("stale-if-error")
+	return ttl, swr, sie, ttl > 0
+}
+
+// ProxyCacheEntry describes an entry in a proxy cache.
+type ProxyCacheEntry struct {
+	URL                   string  `json:"url" doc:"Upstream URL"`
+	Status                int     `json:"status" doc:"HTTP status code of the cached response"`
+	Size                  int     `json:"size" doc:"Size of the cached body in bytes"`
+	AgeS                  float64 `json:"ageS" doc:"Time since the response was stored (s)"`
+	TTLS                  float64 `json:"ttlS" doc:"Time to live (s)"`
+	StaleWhileRevalidateS float64 `json:"staleWhileRevalidateS,omitempty" doc:"Time after the TTL that the entry is served while refetched (s)"`
+	StaleIfErrorS         float64 `json:"staleIfErrorS,omitempty" doc:"Time after the TTL that the entry is served if upstream fails (s)"`
+	Hits                  int     `json:"hits" doc:"Number of responses served from the entry"`
+	State                 string  `json:"state" enum:"fresh,stale,expired" doc:"Freshness of the entry"`
+}
+
+// list returns the entries sorted by URL.
+func (pc *proxyCache) list() []ProxyCacheEntry {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	now := pc.now()
+	entries := make([]ProxyCacheEntry, 0, len(pc.entries))
+	for key, e := range pc.entries {
+		entries = append(entries, ProxyCacheEntry{
+			URL:                   key,
+			Status:                e.status,
+			Size:                  len(e.body),
+			AgeS:                  now.Sub(e.storedAt).Seconds(),
+			TTLS:                  e.ttl.Seconds(),
+			StaleWhileRevalidateS: e.swr.Seconds(),
+			StaleIfErrorS:         e.sie.Seconds(),
+			Hits:                  e.hits,
+			State:                 string(e.state(now)),
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].URL < entries[j].URL })
+	return entries
+}
+
+// purge removes the entries with URLs starting with prefix, and returns the number removed.
+func (pc *proxyCache) purge(prefix string) int {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	n := 0
+	for key := range pc.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(pc.entries, key)
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,133 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheLifetimes(t *testing.T) {
+	cfg := ProxyCacheConfig{DefaultTTLS: 2, NegativeTTLS: 1}
+	testCases := []struct {
+		status       int
+		cacheControl string
+		wantedTTL    time.Duration
+		wantedSWR    time.Duration
+		wantedOK     bool
+	}{
+		{200, "", 2 * time.Second, 0, true},
+		{200, "max-age=10, stale-while-revalidate=5", 10 * time.Second, 5 * time.Second, true},
+		{200, "public, max-age=10, s-maxage=20", 20 * time.Second, 0, true},
+		{200, "max-age=0", 0, 0, false},
+		{200, "no-store", 0, 0, false},
+		{200, "private, max-age=10", 0, 0, false},
+		{404, "", time.Second, 0, true},
+		{410, "max-age=30", 30 * time.Second, 0, true},
+		{500, "max-age=30", 0, 0, false},
+	}
+	for _, tc := range testCases {
+		ttl, swr, _, ok := cacheLifetimes(tc.status, tc.cacheControl, &cfg)
+		require.Equal(t, tc.wantedOK, ok, tc.cacheControl)
+		if ok {
+			require.Equal(t, tc.wantedTTL, ttl, tc.cacheControl)
+			require.Equal(t, tc.wantedSWR, swr, tc.cacheControl)
+		}
+	}
+}
+
+func TestProxyCache(t *testing.T) {
+	var nrRequests atomic.Int32
+	var failing atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nr := nrRequests.Add(1)
+		switch r.URL.Path {
+		case "/seg.m4s":
+			w.Header().Set("Cache-Control", "max-age=10, stale-while-revalidate=5")
+		case "/flaky.m4s":
+			if failing.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Cache-Control", "max-age=2, stale-if-error=10")
+		case "/nostore.m4s":
+			w.Header().Set("Cache-Control", "no-store")
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, "response %d", nr)
+	}))
+	defer upstream.Close()
+	server, ts := newTestServer(t, func(cfg *ServerConfig) {
+		cfg.Proxies = []ProxyConfig{{Name: "c", Origin: upstream.URL, Cache: &ProxyCacheConfig{NegativeTTLS: 5}}}
+	})
+	now := time.Now()
+	server.proxies["c"].cache.now = func() time.Time { return now }
+
+	get := func(path, wantedCache, wantedBody string) {
+		t.Helper()
+		resp, body := testFullRequest(t, ts, "GET", "/livesim2/proxy/c/"+path, nil)
+		require.Equal(t, wantedCache, resp.Header.Get(cacheStatusHeader), path)
+		if wantedBody != "" {
+			require.Equal(t, wantedBody, string(body), path)
+		}
+	}
+
+	get("seg.m4s", "MISS", "response 1")
+	get("seg.m4s", "HIT", "response 1")
+	now = now.Add(12 * time.Second) // Within stale-while-revalidate
+	get("seg.m4s", "STALE", "response 1")
+	require.Eventually(t, func() bool { return nrRequests.Load() == 2 }, time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		_, body := testFullRequest(t, ts, "GET", "/livesim2/proxy/c/seg.m4s", nil)
+		return string(body) == "response 2"
+	}, time.Second, 10*time.Millisecond)
+	now = now.Add(20 * time.Second) // Expired
+	get("seg.m4s", "MISS", "response 3")
+
+	// 404 responses are cached for NegativeTTLS, and no-store responses are not cached
+	get("nosuch.m4s", "MISS", "")
+	get("nosuch.m4s", "HIT", "")
+	get("nostore.m4s", "MISS", "response 5")
+	get("nostore.m4s", "MISS", "response 6")
+
+	// An expired entry is served within stale-if-error if upstream fails
+	get("flaky.m4s", "MISS", "response 7")
+	failing.Store(true)
+	now = now.Add(5 * time.Second)
+	get("flaky.m4s", "STALE", "response 7")
+	now = now.Add(10 * time.Second)
+	resp, _ := testFullRequest(t, ts, "GET", "/livesim2/proxy/c/flaky.m4s", nil)
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	resp, body := testFullRequest(t, ts, "GET", "/api/proxies/c/cache", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var entries []ProxyCacheEntry
+	require.NoError(t, json.Unmarshal(body, &entries))
+	require.Len(t, entries, 2) // The uncacheable 503 response removed the flaky entry
+	require.Equal(t, upstream.URL+"/nosuch.m4s", entries[0].URL)
+	require.Equal(t, http.StatusNotFound, entries[0].Status)
+	require.Equal(t, 1, entries[0].Hits)
+	require.Equal(t, "expired", entries[0].State)
+	require.Equal(t, upstream.URL+"/seg.m4s", entries[1].URL)
+	require.Equal(t, 10.0, entries[1].TTLS)
+
+	resp, body = testFullRequest(t, ts, "DELETE", "/api/proxies/c/cache?prefix=/seg", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var purged ProxyCachePurgeResponse
+	require.NoError(t, json.Unmarshal(body, &purged.Body))
+	require.Equal(t, 1, purged.Body.Purged)
+	get("seg.m4s", "MISS", "")
+	resp, _ = testFullRequest(t, ts, "GET", "/api/proxies/nosuch/cache", nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
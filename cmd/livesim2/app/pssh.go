@@ -0,0 +1,135 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/Dash-Industry-Forum/livesim2/pkg/drm"
+	"github.com/Eyevinn/mp4ff/bits"
+	"github.com/Eyevinn/mp4ff/mp4"
+)
+
+// commonPsshSystemID is the W3C Common PSSH box format system ID, listing only key IDs.
+const commonPsshSystemID = "1077efec-c0b2-4d02-ace3-3c1e52e2fb4b"
+
+const (
+	maxPsshCount       = 100
+	maxPsshPayloadSize = 1 << 20
+)
+
+// PSSHConfig configures the pssh boxes in the init segments of encrypted representations,
+// to reproduce the variability of packagers. Without it, the init segments have no pssh boxes.
+type PSSHConfig struct {
+	Count       int  `json:"Count"`                 // Number of copies of each pssh box
+	PayloadSize int  `json:"PayloadSize,omitempty"` // Data size of an extra Common PSSH box, if > 0
+	KIDsOnly    bool `json:"KIDsOnly,omitempty"`    // Version 1 boxes with only key IDs and no system-specific data
+}
+
+func (pc *PSSHConfig) validate(cfg *ResponseConfig) error {
+	if cfg.DRM == "" {
+		return newReasonError(reasonBadCombination, fmt.Errorf("pssh requires drm or eccp"))
+	}
+	if pc.Count < 1 || pc.Count > maxPsshCount {
+		return fmt.Errorf("pssh count %d not in range 1-%d", pc.Count, maxPsshCount)
+	}
+	if pc.PayloadSize < 0 || pc.PayloadSize > maxPsshPayloadSize {
+		return fmt.Errorf("pssh payload size %d not in range 0-%d", pc.PayloadSize, maxPsshPayloadSize)
+	}
+	return nil
+}
+
+// psshBoxes returns the pssh boxes for an init segment of rep.
+// With ECCP, there is only the Common PSSH box. With a CPIX configuration, there is one box per DRM system
+// for the content key of rep, taken from the CPIX data unless KIDsOnly is set.
+func psshBoxes(pc *PSSHConfig, cfg *ResponseConfig, drmCfg *drm.DrmConfig, rep *RepData) ([]*mp4.PsshBox, error) {
+	commonID, _ := mp4.NewUUIDFromHex(commonPsshSystemID) // Cannot fail
+	var base []*mp4.PsshBox
+	var kid mp4.UUID
+	switch cfg.DRM {
+	case "eccp-cenc", "eccp-cbcs":
+		kid = mp4.UUID(rep.encData.keyID[:])
+		base = append(base, &mp4.PsshBox{Version: 1, SystemID: commonID, KIDs: []mp4.UUID{kid}})
+	default:
+		d, ok := drmCfg.Map[cfg.DRM]
+		if !ok {
+			return nil, fmt.Errorf("drm configuration %q not found", cfg.DRM)
+		}
+		keyData, err := d.CPIXData.GetContentKey(rep.ContentType)
+		if err != nil {
+			return nil, fmt.Errorf("get content key: %w", err)
+		}
+		kid = keyData.KeyID
+		for _, drmSys := range d.CPIXData.DRMSystems {
+			if !bytes.Equal(drmSys.KeyID, kid) {
+				continue
+			}
+			if pc.KIDsOnly {
+				sysID, err := mp4.NewUUIDFromHex(strings.TrimPrefix(drmSys.SystemID, "urn:uuid:"))
+				if err != nil {
+					return nil, fmt.Errorf("system ID %s: %w", drmSys.SystemID, err)
+				}
+				base = append(base, &mp4.PsshBox{Version: 1, SystemID: sysID, KIDs: []mp4.UUID{kid}})
+				continue
+			}
+			if drmSys.PSSH == "" {
+				continue
+			}
+			pssh, err := decodePssh(drmSys.PSSH)
+			if err != nil {
+				return nil, fmt.Errorf("pssh for system %s: %w", drmSys.SystemID, err)
+			}
+			base = append(base, pssh)
+		}
+	}
+	boxes := make([]*mp4.PsshBox, 0, len(base)*pc.Count+1)
+	for _, b := range base {
+		for i := 0; i < pc.Count; i++ {
+			boxes = append(boxes, b)
+		}
+	}
+	if pc.PayloadSize > 0 {
+		boxes = append(boxes, &mp4.PsshBox{Version: 1, SystemID: commonID, KIDs: []mp4.UUID{kid},
+			Data: make([]byte, pc.PayloadSize)})
+	}
+	return boxes, nil
+}
+
+// decodePssh decodes a base64-encoded pssh box.
+func decodePssh(b64 string) (*mp4.PsshBox, error) {
+	data, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return nil, err
+	}
+	box, err := mp4.DecodeBox(0, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	pssh, ok := box.(*mp4.PsshBox)
+	if !ok {
+		return nil, fmt.Errorf("box %s is not pssh", box.Type())
+	}
+	return pssh, nil
+}
+
+// replacePsshBoxes returns the init segment with its pssh boxes replaced by psshs.
+func replacePsshBoxes(rawInit []byte, psshs []*mp4.PsshBox) ([]byte, error) {
+	f, err := mp4.DecodeFileSR(bits.NewFixedSliceReader(rawInit))
+	if err != nil {
+		return nil, fmt.Errorf("decode init: %w", err)
+	}
+	if f.Init == nil {
+		return nil, fmt.Errorf("no init segment")
+	}
+	moov := f.Init.Moov
+	moov.RemovePsshs()
+	for _, p := range psshs {
+		moov.AddChild(p)
+	}
+	return getInitBytes(f.Init)
+}
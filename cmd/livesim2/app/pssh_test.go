@@ -0,0 +1,65 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"github.com/Eyevinn/mp4ff/mp4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPSSH(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	getPsshs := func(params string) []*mp4.PsshBox {
+		resp, body := testFullRequest(t, ts, "GET", "/livesim2/"+params+"/testpic_2s/V300/init.mp4", nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		f, err := mp4.DecodeFile(bytes.NewBuffer(body))
+		require.NoError(t, err)
+		require.NotNil(t, f.Init.Moov.Trak.Mdia.Minf.Stbl.Stsd.Encv)
+		return f.Init.Moov.Psshs
+	}
+
+	require.Len(t, getPsshs("eccp_cbcs"), 0)
+
+	psshs := getPsshs("eccp_cbcs/pssh_3")
+	require.Len(t, psshs, 3)
+	for _, p := range psshs {
+		require.Equal(t, commonPsshSystemID, p.SystemID.String())
+		require.Len(t, p.KIDs, 1)
+		require.Len(t, p.Data, 0)
+	}
+
+	psshs = getPsshs("eccp_cenc/pssh_1_5000_kidsonly")
+	require.Len(t, psshs, 2)
+	require.Len(t, psshs[1].Data, 5000)
+	require.Equal(t, psshs[0].KIDs, psshs[1].KIDs)
+
+	for _, params := range []string{"pssh_1", "eccp_cbcs/pssh_0", "eccp_cbcs/pssh_1_x", "eccp_cbcs/pssh_1_2_3"} {
+		resp, _ := testFullRequest(t, ts, "GET", "/livesim2/"+params+"/testpic_2s/Manifest.mpd", nil)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, params)
+	}
+}
+
+func TestDecodePssh(t *testing.T) {
+	sysID, err := mp4.NewUUIDFromHex("edef8ba9-79d6-4ace-a3c8-27dcd51d21ed")
+	require.NoError(t, err)
+	in := &mp4.PsshBox{SystemID: sysID, Data: []byte{1, 2, 3}}
+	buf := bytes.Buffer{}
+	require.NoError(t, in.Encode(&buf))
+	out, err := decodePssh(base64.StdEncoding.EncodeToString(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, in.SystemID, out.SystemID)
+	require.Equal(t, in.Data, out.Data)
+
+	buf.Reset()
+	require.NoError(t, (&mp4.FreeBox{}).Encode(&buf))
+	_, err = decodePssh(base64.StdEncoding.EncodeToString(buf.Bytes()))
+	require.Error(t, err)
+}
@@ -0,0 +1,167 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+)
+
+const (
+	// dvbReportingScheme is the DVB DASH metrics reporting scheme supported by players such as dash.js
+	dvbReportingScheme = "urn:dvb:dash:reporting:2014"
+	dvbNamespace       = "urn:dvb:metadata:dash:2014"
+	// qoeMetrics are the metrics requested in the MPD
+	qoeMetrics = "BufferLevel,HttpList,RepSwitchList,PlayList"
+	// qoePathPrefix is the path prefix of the report collection endpoint, followed by the MPD path
+	qoePathPrefix = "/qoe"
+	// qoeReportingMarker is a placeholder for the DVB attributes, that cannot be expressed by the MPD structs.
+	qoeReportingMarker = "livesim2-dvb-reporting"
+	// maxQoEKeys limits the number of MPD paths for which reports are kept.
+	// The least recently updated is dropped when the limit is reached.
+	maxQoEKeys = 1000
+	// maxQoEReportSize limits the size of a received report.
+	maxQoEReportSize = 64 * 1024
+)
+
+// QoEReport is one received metrics report.
+type QoEReport struct {
+	Seq         int       `json:"seq" doc:"Sequence number of report for this MPD, starting at 1"`
+	Time        time.Time `json:"time" doc:"Wall-clock time of reception"`
+	ClientIP    string    `json:"clientIP" doc:"Client IP address"`
+	ContentType string    `json:"contentType,omitempty" doc:"Content-Type of report"`
+	Report      string    `json:"report" doc:"Report body"`
+}
+
+// QoEKey summarizes the reports received for an MPD path.
+type QoEKey struct {
+	Key     string    `json:"key" doc:"MPD URL path"`
+	Count   int       `json:"count" doc:"Number of reports kept"`
+	Total   int       `json:"total" doc:"Number of reports received"`
+	Updated time.Time `json:"updated" doc:"Time of last report"`
+}
+
+type qoeReports struct {
+	reports []QoEReport
+	total   int
+	updated time.Time
+}
+
+// qoeStore keeps the last received metrics reports per MPD path.
+type qoeStore struct {
+	mu   sync.Mutex
+	size int
+	keys map[string]*qoeReports
+}
+
+func newQoEStore(size int) *qoeStore {
+	return &qoeStore{size: size, keys: make(map[string]*qoeReports)}
+}
+
+// add appends a report for key, dropping the oldest one if size is reached.
+func (q *qoeStore) add(key string, rep QoEReport) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	qr, ok := q.keys[key]
+	if !ok {
+		if len(q.keys) >= maxQoEKeys {
+			var oldestKey string
+			for k, r := range q.keys {
+				if oldestKey == "" || r.updated.Before(q.keys[oldestKey].updated) {
+					oldestKey = k
+				}
+			}
+			delete(q.keys, oldestKey)
+		}
+		qr = &qoeReports{}
+		q.keys[key] = qr
+	}
+	qr.total++
+	rep.Seq = qr.total
+	qr.updated = rep.Time
+	qr.reports = append(qr.reports, rep)
+	if len(qr.reports) > q.size {
+		qr.reports = append([]QoEReport(nil), qr.reports[1:]...)
+	}
+}
+
+// get returns the reports for key, oldest first.
+func (q *qoeStore) get(key string) ([]QoEReport, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	qr, ok := q.keys[key]
+	if !ok {
+		return nil, false
+	}
+	return append([]QoEReport(nil), qr.reports...), true
+}
+
+// list returns a summary of all keys sorted by key.
+func (q *qoeStore) list() []QoEKey {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	keys := make([]QoEKey, 0, len(q.keys))
+	for key, qr := range q.keys {
+		keys = append(keys, QoEKey{Key: key, Count: len(qr.reports), Total: qr.total, Updated: qr.updated})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Key < keys[j].Key })
+	return keys
+}
+
+// remove deletes the reports for key and reports whether they existed.
+func (q *qoeStore) remove(key string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	_, ok := q.keys[key]
+	delete(q.keys, key)
+	return ok
+}
+
+// addMetricsReporting adds a Metrics element with DVB Reporting to the MPD.
+// The reporting URL and probability are inserted by insertDVBReportingAttrs after serialization.
+func addMetricsReporting(mpd *m.MPD) {
+	mpd.Metrics = append(mpd.Metrics, &m.MetricsType{
+		Metrics: qoeMetrics,
+		Reportings: []*m.DescriptorType{
+			m.NewDescriptor(dvbReportingScheme, "1", qoeReportingMarker),
+		},
+	})
+}
+
+// removeMetricsReporting removes the Metrics element added by addMetricsReporting.
+// It is used for MPDs that are converted to other formats, where DVB reporting cannot be signaled.
+func removeMetricsReporting(mpd *m.MPD) {
+	mpd.Metrics = slices.DeleteFunc(mpd.Metrics, func(mt *m.MetricsType) bool {
+		return slices.ContainsFunc(mt.Reportings, func(d *m.DescriptorType) bool {
+			return d.Id == qoeReportingMarker
+		})
+	})
+}
+
+// applyDVBReporting inserts the DVB reporting attributes in the serialized MPD if cfg has QoE reporting.
+func applyDVBReporting(cfg *ResponseConfig, mpdXML []byte) []byte {
+	if cfg.QoEProbability == nil {
+		return mpdXML
+	}
+	reportingURL := cfg.Host + qoePathPrefix + strings.Join(cfg.URLParts, "/")
+	return insertDVBReportingAttrs(mpdXML, reportingURL, *cfg.QoEProbability)
+}
+
+// insertDVBReportingAttrs replaces the Reporting marker by the DVB reportingUrl and probability attributes.
+func insertDVBReportingAttrs(mpdXML []byte, reportingURL string, probability int) []byte {
+	var attrs bytes.Buffer
+	attrs.WriteString(fmt.Sprintf(`xmlns:dvb="%s" dvb:reportingUrl="`, dvbNamespace))
+	_ = xml.EscapeText(&attrs, []byte(reportingURL))
+	attrs.WriteString(fmt.Sprintf(`" dvb:probability="%d"`, probability))
+	return bytes.Replace(mpdXML, []byte(fmt.Sprintf(`id="%s"`, qoeReportingMarker)), attrs.Bytes(), 1)
+}
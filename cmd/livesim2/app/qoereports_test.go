@@ -0,0 +1,77 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQoEReports(t *testing.T) {
+	_, ts := newTestServer(t, func(cfg *ServerConfig) {
+		cfg.QoEReports = 2
+	})
+
+	mpdPath := "/livesim2/metrics_500/testpic_2s/Manifest.mpd"
+	resp, body := testFullRequest(t, ts, "GET", mpdPath, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	reportingURL := ts.URL + qoePathPrefix + mpdPath
+	require.Contains(t, string(body), `<Reporting schemeIdUri="urn:dvb:dash:reporting:2014" value="1" `+
+		`xmlns:dvb="urn:dvb:metadata:dash:2014" dvb:reportingUrl="`+reportingURL+`" dvb:probability="500">`)
+	_, err := m.ReadFromString(string(body))
+	require.NoError(t, err)
+
+	for _, report := range []string{"<r1/>", "<r2/>", "<r3/>"} {
+		resp, _ = testFullRequest(t, ts, "POST", qoePathPrefix+mpdPath, strings.NewReader(report))
+		require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	}
+
+	resp, body = testFullRequest(t, ts, "GET", "/api/qoe-reports", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var list struct {
+		Size int      `json:"size"`
+		Keys []QoEKey `json:"keys"`
+	}
+	require.NoError(t, json.Unmarshal(body, &list))
+	require.Len(t, list.Keys, 1)
+	require.Equal(t, mpdPath, list.Keys[0].Key)
+	require.Equal(t, 2, list.Keys[0].Count)
+	require.Equal(t, 3, list.Keys[0].Total)
+
+	reportsPath := "/api/qoe-reports/reports?key=" + url.QueryEscape(mpdPath)
+	resp, body = testFullRequest(t, ts, "GET", reportsPath, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var reports struct {
+		Reports []QoEReport `json:"reports"`
+	}
+	require.NoError(t, json.Unmarshal(body, &reports))
+	require.Len(t, reports.Reports, 2)
+	require.Equal(t, "<r2/>", reports.Reports[0].Report)
+	require.Equal(t, 3, reports.Reports[1].Seq)
+
+	resp, _ = testFullRequest(t, ts, "DELETE", reportsPath, nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, _ = testFullRequest(t, ts, "GET", reportsPath, nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/metrics_1001/testpic_2s/Manifest.mpd", nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestRemoveMetricsReporting(t *testing.T) {
+	mpd := m.NewMPD("dynamic")
+	other := &m.MetricsType{Metrics: "DVBErrors"}
+	mpd.Metrics = append(mpd.Metrics, other)
+	addMetricsReporting(mpd)
+	require.Len(t, mpd.Metrics, 2)
+	removeMetricsReporting(mpd)
+	require.Equal(t, []*m.MetricsType{other}, mpd.Metrics)
+}
@@ -0,0 +1,113 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultQuotaWindowS is the quota window if not set
+	defaultQuotaWindowS = 60
+	// quotaPurgeSize is the number of windows above which expired windows are removed
+	quotaPurgeSize = 10_000
+)
+
+// RepQuota limits the number of requests per session to the Representations RepIDs to N per window,
+// like cost-control policies of origins. Requests beyond the quota get 429 Too Many Requests.
+type RepQuota struct {
+	N       int      `json:"N"`
+	RepIDs  []string `json:"RepIDs"`
+	WindowS int      `json:"WindowS"`
+}
+
+func (q *RepQuota) validate() error {
+	if q.N <= 0 || q.WindowS <= 0 {
+		return fmt.Errorf("quota n %d and windowS %d must be > 0", q.N, q.WindowS)
+	}
+	if len(q.RepIDs) == 0 || slices.Contains(q.RepIDs, "") {
+		return fmt.Errorf("quota repIDs %q must be non-empty", q.RepIDs)
+	}
+	return nil
+}
+
+// appliesTo returns true if the requests of Representation repID are limited.
+func (q *RepQuota) appliesTo(repID string) bool {
+	return slices.Contains(q.RepIDs, repID)
+}
+
+// quotaWindow counts the requests in a window starting at the first request.
+type quotaWindow struct {
+	start time.Time
+	count int
+}
+
+// quotaStore keeps the quota windows per session and Representation.
+type quotaStore struct {
+	mu      sync.Mutex
+	windows map[string]*quotaWindow
+}
+
+func newQuotaStore() *quotaStore {
+	return &quotaStore{windows: make(map[string]*quotaWindow)}
+}
+
+// take counts a request for key at now. If the quota q is exhausted, false is returned
+// together with the time until the window ends.
+func (qs *quotaStore) take(key string, q *RepQuota, now time.Time) (ok bool, retryAfter time.Duration) {
+	window := time.Duration(q.WindowS) * time.Second
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	if len(qs.windows) > quotaPurgeSize {
+		for k, w := range qs.windows {
+			if now.Sub(w.start) >= window {
+				delete(qs.windows, k)
+			}
+		}
+	}
+	w, found := qs.windows[key]
+	if !found || now.Sub(w.start) >= window {
+		w = &quotaWindow{start: now}
+		qs.windows[key] = w
+	}
+	if w.count >= q.N {
+		return false, w.start.Add(window).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+// quotaSessionID returns the session that quotas are counted for: the session_<id> URL parameter,
+// the sid query parameter, or the client IP address.
+func quotaSessionID(r *http.Request, cfg *ResponseConfig) string {
+	if sid := abSessionID(r, cfg); sid != "" {
+		return sid
+	}
+	ip, _ := ipFromRequest(r)
+	return ip
+}
+
+// checkQuota counts the request for Representation repID and writes a 429 response with Retry-After
+// if the quota is exhausted. It returns true if the request may continue.
+func (s *Server) checkQuota(w http.ResponseWriter, r *http.Request, cfg *ResponseConfig, a *asset, repID string) bool {
+	if !cfg.Quota.appliesTo(repID) {
+		return true
+	}
+	key := quotaSessionID(r, cfg) + "/" + a.AssetPath + "/" + repID
+	ok, retryAfter := s.quotas.take(key, cfg.Quota, time.Now())
+	if ok {
+		return true
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	writeProblem(w, r, http.StatusTooManyRequests, reasonQuotaExceeded,
+		fmt.Sprintf("request quota of %d per %ds exceeded for %s", cfg.Quota.N, cfg.Quota.WindowS, repID))
+	return false
+}
@@ -0,0 +1,60 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotaStore(t *testing.T) {
+	qs := newQuotaStore()
+	q := &RepQuota{N: 2, RepIDs: []string{"V300"}, WindowS: 10}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		ok, _ := qs.take("s1/V300", q, start.Add(time.Duration(i)*time.Second))
+		require.True(t, ok)
+	}
+	ok, retryAfter := qs.take("s1/V300", q, start.Add(4*time.Second))
+	require.False(t, ok)
+	require.Equal(t, 6*time.Second, retryAfter)
+	ok, _ = qs.take("s2/V300", q, start.Add(4*time.Second))
+	require.True(t, ok, "other session")
+	ok, _ = qs.take("s1/V300", q, start.Add(10*time.Second))
+	require.True(t, ok, "new window")
+}
+
+func TestParseQuota(t *testing.T) {
+	sc := newStringConverter()
+	require.Equal(t, &RepQuota{N: 5, RepIDs: []string{"V300", "V600"}, WindowS: defaultQuotaWindowS}, sc.ParseQuota("quota", "5_V300,V600"))
+	require.Equal(t, &RepQuota{N: 5, RepIDs: []string{"V300"}, WindowS: 10}, sc.ParseQuota("quota", "5_V300_10"))
+	require.NoError(t, sc.err)
+	for _, val := range []string{"5", "0_V300", "5_V300_0", "x_V300", "5__10"} {
+		sc = newStringConverter()
+		require.Nil(t, sc.ParseQuota("quota", val))
+		require.Error(t, sc.err, val)
+	}
+}
+
+func TestQuota(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	for nr := 45; nr < 47; nr++ {
+		resp, _ := testFullRequest(t, ts, "GET", fmt.Sprintf("/livesim2/quota_2_V300/testpic_2s/V300/%d.m4s?nowMS=100000&sid=a", nr), nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	resp, body := testFullRequest(t, ts, "GET", "/livesim2/quota_2_V300/testpic_2s/V300/47.m4s?nowMS=100000&sid=a", nil)
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	require.Equal(t, "60", resp.Header.Get("Retry-After"))
+	require.Contains(t, string(body), reasonQuotaExceeded)
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/quota_2_V300/testpic_2s/V300/47.m4s?nowMS=100000&sid=b", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode, "other session")
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/quota_2_V300/testpic_2s/A48/47.m4s?nowMS=100000&sid=a", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode, "other representation")
+}
@@ -0,0 +1,317 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/Eyevinn/mp4ff/mp4"
+)
+
+// maxRecordingDurS limits the length of a recording.
+const maxRecordingDurS = 3600
+
+// RecordingSetup selects a window of a live stream to record as VoD.
+type RecordingSetup struct {
+	URL       string `json:"livesimURL" doc:"livesim2 MPD URL (path and query)" example:"/livesim2/segtimeline_1/testpic_2s/Manifest.mpd"`
+	Name      string `json:"name" doc:"Name (relative path) of the recording in the record directory" example:"glitch_1"`
+	StartMS   int    `json:"startMS" minimum:"0" doc:"Wall-clock start of the window (ms since epoch)" example:"1700000000000"`
+	DurationS int    `json:"durationS" minimum:"1" maximum:"3600" doc:"Duration of the window in seconds" example:"60"`
+}
+
+// RecordingInfo describes a finished recording.
+type RecordingInfo struct {
+	Name     string         `json:"name" doc:"Name of the recording"`
+	MPD      string         `json:"mpd" doc:"Key of the VoD MPD in the record directory"`
+	VodURL   string         `json:"vodURL,omitempty" doc:"URL of the VoD MPD, if the record directory is in the VoD root"`
+	StartMS  int            `json:"startMS" doc:"Wall-clock start of the first recorded segment (ms)"`
+	Duration float64        `json:"durationS" doc:"Duration of the recording in seconds"`
+	Segments map[string]int `json:"segments" doc:"Number of recorded segments per representation"`
+}
+
+// recordedTrack is a track with the recorded segments, whose times have been rewritten to start at 0.
+type recordedTrack struct {
+	timescale uint64
+	times     []uint64
+	durs      []uint64
+}
+
+// recordLive records the segments of the live MPD URL that start in the window, and stores them
+// with an MPD as a static on-demand asset. The recording is generated at the end of the window,
+// so it is identical to what a client would have received. Media times are shifted so that the
+// earliest track starts at 0, keeping the tracks in sync.
+func (s *Server) recordLive(ctx context.Context, setup RecordingSetup) (*RecordingInfo, error) {
+	if s.recordings == nil {
+		return nil, fmt.Errorf("recording is not enabled (see --recorddir)")
+	}
+	if setup.Name == "" || !filepath.IsLocal(setup.Name) || strings.Contains(setup.Name, "\\") {
+		return nil, fmt.Errorf("bad recording name %q", setup.Name)
+	}
+	if setup.DurationS <= 0 || setup.DurationS > maxRecordingDurS {
+		return nil, fmt.Errorf("durationS must be in 1-%d", maxRecordingDurS)
+	}
+	u, err := url.Parse(setup.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parse url: %w", err)
+	}
+	if !strings.HasPrefix(u.Path, "/livesim2/") || path.Ext(u.Path) != ".mpd" {
+		return nil, fmt.Errorf("url must be a /livesim2/ MPD URL")
+	}
+	endMS := setup.StartMS + setup.DurationS*1000
+	q := u.Query()
+	q.Set("nowMS", strconv.Itoa(endMS))
+	u.RawQuery = q.Encode()
+
+	log := s.logger.With("recording", setup.Name)
+	req := httptest.NewRequest("GET", u.RequestURI(), nil)
+	nowMS, cfg, errHT := cfgFromRequest(req, log, s.liveSessions())
+	if errHT == nil {
+		errHT = s.validateURLParams(log, cfg)
+	}
+	if errHT != nil {
+		return nil, errHT
+	}
+	cfg.Host = s.Cfg.Host
+	if cfg.Host == "" && u.Host != "" {
+		cfg.Host = u.Scheme + "://" + u.Host
+	}
+	contentPart := cfg.URLContentPart()
+	a, ok := s.assetMgr.findAsset(contentPart)
+	if !ok {
+		return nil, fmt.Errorf("unknown asset %q", contentPart)
+	}
+	if a, err = configuredAsset(s.assetMgr.vodFS, a, cfg); err != nil {
+		return nil, err
+	}
+	// The time-shift buffer must cover the window, and segments are recorded complete
+	tsbdS := setup.DurationS + 2*a.SegmentDurMS/1000 + 1
+	if cfg.TimeShiftBufferDepthS == nil || *cfg.TimeShiftBufferDepthS < tsbdS {
+		cfg.TimeShiftBufferDepthS = Ptr(tsbdS)
+	}
+	cfg.AvailabilityTimeCompleteFlag = true
+	_, mpdName := path.Split(contentPart)
+	lMPD, err := LiveMPD(a, mpdName, cfg, s.Cfg.DrmCfg, nowMS)
+	if err != nil {
+		return nil, fmt.Errorf("live MPD: %w", err)
+	}
+	cl, err := cmafListingFromMPD(lMPD, mpdName, nowMS)
+	if err != nil {
+		return nil, err
+	}
+	startS := float64(setup.StartMS) / 1000
+	offsetS := math.Inf(1)
+	for i := range cl.Tracks {
+		tr := &cl.Tracks[i]
+		var segs []CMAFSegment
+		for _, seg := range tr.Segments {
+			if seg.wallS >= startS-0.0005 {
+				segs = append(segs, seg)
+			}
+		}
+		tr.Segments = segs
+		if len(segs) > 0 {
+			offsetS = min(offsetS, segs[0].wallS)
+		}
+	}
+	if math.IsInf(offsetS, 1) {
+		return nil, fmt.Errorf("no segments in window")
+	}
+
+	info := RecordingInfo{
+		Name:     setup.Name,
+		MPD:      setup.Name + "/" + mpdName,
+		StartMS:  int(math.Round(offsetS * 1000)),
+		Segments: make(map[string]int),
+	}
+	recorded := make(map[string]*recordedTrack)
+	for i := range cl.Tracks {
+		tr := &cl.Tracks[i]
+		if len(tr.Segments) == 0 {
+			continue
+		}
+		rt, err := s.recordTrack(ctx, log, cfg, a, tr, nowMS, offsetS, setup.Name)
+		if err != nil {
+			return nil, fmt.Errorf("representation %s: %w", tr.RepID, err)
+		}
+		recorded[tr.RepID] = rt
+		info.Segments[tr.RepID] = len(rt.times)
+		last := len(rt.times) - 1
+		info.Duration = max(info.Duration, float64(rt.times[last]+rt.durs[last])/float64(rt.timescale))
+	}
+
+	vodMPD := recordedMPD(lMPD, recorded, info.Duration)
+	var buf bytes.Buffer
+	if _, err := vodMPD.Write(&buf, "  ", true); err != nil {
+		return nil, err
+	}
+	if err := s.recordings.Put(ctx, info.MPD, applyDVBReporting(cfg, buf.Bytes())); err != nil {
+		return nil, err
+	}
+	info.VodURL = s.recordingVodURL(info.MPD)
+	log.Info("recorded live window", "url", setup.URL, "startMS", info.StartMS, "durationS", info.Duration)
+	return &info, nil
+}
+
+// recordTrack stores the init segment and the segments of a track with rewritten tfdt times.
+func (s *Server) recordTrack(ctx context.Context, log *slog.Logger, cfg *ResponseConfig,
+	a *asset, tr *CMAFTrack, nowMS int, offsetS float64, name string) (*recordedTrack, error) {
+	rep, ok := a.Reps[tr.RepID]
+	if !ok {
+		return nil, fmt.Errorf("not in asset")
+	}
+	rt := recordedTrack{timescale: uint64(rep.MediaTimescale)}
+	getSegment := func(segPart string) ([]byte, error) {
+		rec := httptest.NewRecorder()
+		code, err := writeSegment(ctx, rec, log, cfg, s.Cfg.DrmCfg, s.assetMgr.vodFS, a, segPart, nowMS,
+			s.textTemplates, false /* isLast */)
+		if err != nil {
+			return nil, err
+		}
+		if code != 0 {
+			return nil, fmt.Errorf("segment %s: configured status %d", segPart, code)
+		}
+		return rec.Body.Bytes(), nil
+	}
+	initBytes, err := getSegment(strings.TrimPrefix(tr.Init, tr.baseURL))
+	if err != nil {
+		return nil, fmt.Errorf("init segment: %w", err)
+	}
+	if err := s.recordings.Put(ctx, path.Join(name, tr.RepID, "init.mp4"), initBytes); err != nil {
+		return nil, err
+	}
+	var delta uint64 // Subtracted from the media times
+	for i, seg := range tr.Segments {
+		data, err := getSegment(strings.TrimPrefix(seg.URL, tr.baseURL))
+		if err != nil {
+			return nil, err
+		}
+		f, err := mp4.DecodeFile(bytes.NewReader(data))
+		if err != nil || len(f.Segments) == 0 || len(f.Segments[0].Fragments) == 0 {
+			return nil, fmt.Errorf("segment %s: bad media segment", seg.URL)
+		}
+		firstTfdt := f.Segments[0].Fragments[0].Moof.Traf.Tfdt.BaseMediaDecodeTime()
+		if i == 0 {
+			newT := uint64(math.Round((seg.wallS - offsetS) * float64(rt.timescale)))
+			delta = firstTfdt - newT
+		}
+		var out bytes.Buffer
+		for _, ms := range f.Segments {
+			for _, frag := range ms.Fragments {
+				tfdt := frag.Moof.Traf.Tfdt
+				tfdt.SetBaseMediaDecodeTime(tfdt.BaseMediaDecodeTime() - delta)
+			}
+			if err := ms.Encode(&out); err != nil {
+				return nil, err
+			}
+		}
+		t := firstTfdt - delta
+		if err := s.recordings.Put(ctx, path.Join(name, tr.RepID, fmt.Sprintf("%d.m4s", t)), out.Bytes()); err != nil {
+			return nil, err
+		}
+		rt.times = append(rt.times, t)
+		rt.durs = append(rt.durs, seg.Duration*rt.timescale/uint64(tr.Timescale))
+	}
+	log.Debug("recorded track", "rep", tr.RepID, "segments", len(rt.times))
+	return &rt, nil
+}
+
+// recordedMPD turns the live MPD into a static single-Period MPD with the recorded representations.
+func recordedMPD(lMPD *m.MPD, recorded map[string]*recordedTrack, durS float64) *m.MPD {
+	vod := lMPD
+	vod.Type = Ptr("static")
+	vod.AvailabilityStartTime = ""
+	vod.PublishTime = ""
+	vod.MinimumUpdatePeriod = nil
+	vod.TimeShiftBufferDepth = nil
+	vod.SuggestedPresentationDelay = nil
+	vod.UTCTimings = nil
+	vod.Location = nil
+	vod.PatchLocation = nil
+	vod.ServiceDescription = nil
+	vod.MediaPresentationDuration = Ptr(m.Duration(time.Duration(durS * float64(time.Second))))
+	p := vod.Periods[0]
+	p.Id = "P0"
+	p.Start = Ptr(m.Duration(0))
+	p.Duration = nil
+	p.BaseURLs = nil
+	p.EventStreams = nil
+	var adaptationSets []*m.AdaptationSetType
+	for _, as := range p.AdaptationSets {
+		var reps []*m.RepresentationType
+		for _, rep := range as.Representations {
+			rt, ok := recorded[rep.Id]
+			if !ok {
+				continue
+			}
+			rep.SegmentTemplate = &m.SegmentTemplateType{
+				Media:          "$RepresentationID$/$Time$.m4s",
+				Initialization: "$RepresentationID$/init.mp4",
+				MultipleSegmentBaseType: m.MultipleSegmentBaseType{
+					SegmentBaseType: m.SegmentBaseType{Timescale: Ptr(uint32(rt.timescale))},
+					SegmentTimeline: &m.SegmentTimelineType{S: recordedTimeline(rt)},
+				},
+			}
+			reps = append(reps, rep)
+		}
+		if len(reps) == 0 {
+			continue
+		}
+		as.SegmentTemplate = nil
+		as.Representations = reps
+		adaptationSets = append(adaptationSets, as)
+	}
+	p.AdaptationSets = adaptationSets
+	vod.Periods = []*m.Period{p}
+	return vod
+}
+
+// recordedTimeline returns S elements with repeat counts, and t for the first and after gaps.
+func recordedTimeline(rt *recordedTrack) []*m.S {
+	var ss []*m.S
+	var nextT uint64
+	for i, t := range rt.times {
+		d := rt.durs[i]
+		if i > 0 && t == nextT && ss[len(ss)-1].D == d {
+			ss[len(ss)-1].R++
+		} else {
+			s := m.S{D: d}
+			if i == 0 || t != nextT {
+				s.T = Ptr(t)
+			}
+			ss = append(ss, &s)
+		}
+		nextT = t + d
+	}
+	return ss
+}
+
+// recordingVodURL returns the /vod/ URL of a recorded MPD if the record directory is in the VoD root.
+func (s *Server) recordingVodURL(mpdKey string) string {
+	if strings.Contains(s.Cfg.RecordDir, "://") {
+		return ""
+	}
+	vodRoot, err1 := filepath.Abs(s.Cfg.VodRoot)
+	recDir, err2 := filepath.Abs(s.Cfg.RecordDir)
+	if err1 != nil || err2 != nil {
+		return ""
+	}
+	rel, err := filepath.Rel(vodRoot, recDir)
+	if err != nil || !filepath.IsLocal(rel) && rel != "." {
+		return ""
+	}
+	return path.Join("/vod", filepath.ToSlash(rel), mpdKey)
+}
@@ -0,0 +1,95 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/Eyevinn/mp4ff/mp4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordLive(t *testing.T) {
+	recDir := t.TempDir()
+	_, ts := newTestServer(t, func(cfg *ServerConfig) {
+		cfg.RecordDir = recDir
+		cfg.QoEReports = 2
+	})
+
+	body := `{"livesimURL": "/livesim2/segtimeline_1/testpic_2s/Manifest.mpd", "name": "rec/glitch",
+		"startMS": 80000, "durationS": 10}`
+	resp, respBody := testFullRequest(t, ts, "POST", "/api/recordings", strings.NewReader(body))
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(respBody))
+	var info RecordingInfo
+	require.NoError(t, json.Unmarshal(respBody, &info))
+	require.Equal(t, "rec/glitch/Manifest.mpd", info.MPD)
+	require.Equal(t, 80000, info.StartMS)
+	require.Equal(t, 5, info.Segments["V300"])
+	require.InDelta(t, 10.0, info.Duration, 0.01) // Audio segments are not aligned with video
+	require.Equal(t, "", info.VodURL)
+
+	mpdData, err := os.ReadFile(filepath.Join(recDir, "rec", "glitch", "Manifest.mpd"))
+	require.NoError(t, err)
+	vod, err := m.ReadFromString(string(mpdData))
+	require.NoError(t, err)
+	require.Equal(t, "static", vod.GetType())
+	require.InDelta(t, 10.0, vod.MediaPresentationDuration.Seconds(), 0.01)
+	for _, as := range vod.Periods[0].AdaptationSets {
+		st := as.Representations[0].SegmentTemplate
+		require.NotNil(t, st)
+		require.Equal(t, uint64(0), *st.SegmentTimeline.S[0].T)
+		if as.ContentType == "video" {
+			require.Equal(t, []*m.S{{T: Ptr(uint64(0)), D: 180000, R: 4}}, st.SegmentTimeline.S)
+		}
+	}
+	_, err = os.Stat(filepath.Join(recDir, "rec", "glitch", "V300", "init.mp4"))
+	require.NoError(t, err)
+	seg, err := os.ReadFile(filepath.Join(recDir, "rec", "glitch", "V300", "360000.m4s"))
+	require.NoError(t, err)
+	f, err := mp4.DecodeFile(bytes.NewReader(seg))
+	require.NoError(t, err)
+	require.Equal(t, uint64(360000), f.Segments[0].Fragments[0].Moof.Traf.Tfdt.BaseMediaDecodeTime())
+
+	// The DVB reporting attributes are inserted in the recorded MPD
+	body = `{"livesimURL": "/livesim2/metrics_500/testpic_2s/Manifest.mpd", "name": "rec/qoe",
+		"startMS": 80000, "durationS": 10}`
+	resp, respBody = testFullRequest(t, ts, "POST", "/api/recordings", strings.NewReader(body))
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(respBody))
+	mpdData, err = os.ReadFile(filepath.Join(recDir, "rec", "qoe", "Manifest.mpd"))
+	require.NoError(t, err)
+	require.Contains(t, string(mpdData), `dvb:reportingUrl="`)
+	require.Contains(t, string(mpdData), `dvb:probability="500"`)
+	require.NotContains(t, string(mpdData), qoeReportingMarker)
+
+	cases := []struct {
+		body   string
+		status int
+	}{
+		{`{"livesimURL": "/livesim2/testpic_2s/Manifest.mpd", "name": "../x", "startMS": 80000, "durationS": 10}`,
+			http.StatusBadRequest},
+		{`{"livesimURL": "/livesim2/testpic_2s/V300/1.m4s", "name": "x", "startMS": 80000, "durationS": 10}`,
+			http.StatusBadRequest},
+		{`{"livesimURL": "/livesim2/testpic_2s/Manifest.mpd", "name": "x", "startMS": 80000, "durationS": 0}`,
+			http.StatusUnprocessableEntity},
+	}
+	for _, c := range cases {
+		resp, _ = testFullRequest(t, ts, "POST", "/api/recordings", strings.NewReader(c.body))
+		require.Equal(t, c.status, resp.StatusCode, c.body)
+	}
+}
+
+func TestRecordingVodURL(t *testing.T) {
+	s := Server{Cfg: &ServerConfig{VodRoot: "testdata/assets", RecordDir: "testdata/assets/recordings"}}
+	require.Equal(t, "/vod/recordings/a/Manifest.mpd", s.recordingVodURL("a/Manifest.mpd"))
+	s.Cfg.RecordDir = "/tmp/recordings"
+	require.Equal(t, "", s.recordingVodURL("a/Manifest.mpd"))
+}
@@ -0,0 +1,115 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// maxRedirectDepth is the maximal number of redirects before a segment is served
+	maxRedirectDepth = 30
+	// maxRedirectDelayMS is the maximal latency of each redirect response
+	maxRedirectDelayMS = 10_000
+)
+
+// Redirect makes segment requests pass through a chain of redirects on the same server,
+// emulating CDN token-redirect flows.
+type Redirect struct {
+	// Depth is the number of redirects before the segment is served
+	Depth int `json:"Depth"`
+	// DelayMS is the latency of each redirect response
+	DelayMS int `json:"DelayMS,omitempty"`
+	// Code is the redirect status code, 302 (default) or 307
+	Code int `json:"Code,omitempty"`
+}
+
+func (rd *Redirect) validate() error {
+	if rd.Depth < 1 || rd.Depth > maxRedirectDepth {
+		return fmt.Errorf("redirect depth %d not in range 1-%d", rd.Depth, maxRedirectDepth)
+	}
+	if rd.DelayMS < 0 || rd.DelayMS > maxRedirectDelayMS {
+		return fmt.Errorf("redirect delay %dms not in range 0-%d", rd.DelayMS, maxRedirectDelayMS)
+	}
+	switch rd.Code {
+	case 0, http.StatusFound, http.StatusTemporaryRedirect:
+	default:
+		return fmt.Errorf("redirect code %d is not %d or %d", rd.Code, http.StatusFound, http.StatusTemporaryRedirect)
+	}
+	return nil
+}
+
+// fromURL reports whether rd equals the redirect URL parameter in cfg.URLParts.
+// The remaining depth is carried in the URL, so a Redirect that is set or changed by
+// a config overlay would never reach the last hop.
+func (rd *Redirect) fromURL(cfg *ResponseConfig) bool {
+	for i, part := range cfg.URLParts {
+		if i >= cfg.URLContentIdx {
+			break
+		}
+		val, ok := strings.CutPrefix(part, "redirect_")
+		if !ok {
+			continue
+		}
+		sc := strConvAccErr{}
+		urlRd := sc.ParseRedirect("redirect", val)
+		return urlRd != nil && urlRd.Depth == rd.Depth && urlRd.DelayMS == rd.DelayMS &&
+			urlRd.statusCode() == rd.statusCode()
+	}
+	return false
+}
+
+// urlPart returns the redirect URL parameter for rd.
+func (rd *Redirect) urlPart() string {
+	return fmt.Sprintf("redirect_%d_%d_%d", rd.Depth, rd.DelayMS, rd.statusCode())
+}
+
+func (rd *Redirect) statusCode() int {
+	if rd.Code == 0 {
+		return http.StatusFound
+	}
+	return rd.Code
+}
+
+// location returns the path and query of the next hop. The redirect URL parameter is replaced
+// by one with depth reduced by one, or removed for the last hop.
+func (rd *Redirect) location(cfg *ResponseConfig, rawQuery string) string {
+	parts := make([]string, 0, len(cfg.URLParts))
+	for i, part := range cfg.URLParts {
+		if i < cfg.URLContentIdx && strings.HasPrefix(part, "redirect_") {
+			if rd.Depth > 1 {
+				next := *rd
+				next.Depth--
+				parts = append(parts, next.urlPart())
+			}
+			continue
+		}
+		parts = append(parts, part)
+	}
+	loc := strings.Join(parts, "/")
+	if rawQuery != "" {
+		loc += "?" + rawQuery
+	}
+	return loc
+}
+
+// apply waits for the configured delay and redirects to the next hop.
+func (rd *Redirect) apply(w http.ResponseWriter, r *http.Request, log *slog.Logger, cfg *ResponseConfig) {
+	if rd.DelayMS > 0 {
+		addServerTimingWait(r.Context(), time.Duration(rd.DelayMS)*time.Millisecond)
+		select {
+		case <-time.After(time.Duration(rd.DelayMS) * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	loc := rd.location(cfg, r.URL.RawQuery)
+	log.Debug("segment redirect", "depth", rd.Depth, "location", loc)
+	http.Redirect(w, r, loc, rd.statusCode())
+}
@@ -0,0 +1,77 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSegmentRedirect(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	noFollow := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	hop := func(path string) *http.Response {
+		resp, err := noFollow.Get(ts.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := hop("/livesim2/redirect_2_0_307/testpic_2s/V300/45.m4s?nowMS=100000")
+	require.Equal(t, http.StatusTemporaryRedirect, resp.StatusCode)
+	require.Equal(t, "/livesim2/redirect_1_0_307/testpic_2s/V300/45.m4s?nowMS=100000", resp.Header.Get("Location"))
+	resp = hop("/livesim2/redirect_1_0_307/testpic_2s/V300/45.m4s?nowMS=100000")
+	require.Equal(t, http.StatusTemporaryRedirect, resp.StatusCode)
+	require.Equal(t, "/livesim2/testpic_2s/V300/45.m4s?nowMS=100000", resp.Header.Get("Location"))
+
+	resp = hop("/livesim2/redirect_1/testpic_2s/init.mp4")
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	require.Equal(t, "/livesim2/testpic_2s/init.mp4", resp.Header.Get("Location"))
+
+	// The MPD is not redirected
+	resp = hop("/livesim2/redirect_1/testpic_2s/Manifest.mpd")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Following all redirects gives the segment after the configured latency
+	start := time.Now()
+	resp, body := testFullRequest(t, ts, "GET", "/livesim2/redirect_3_20/testpic_2s/V300/45.m4s?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.GreaterOrEqual(t, time.Since(start), 60*time.Millisecond)
+	_, refBody := testFullRequest(t, ts, "GET", "/livesim2/testpic_2s/V300/45.m4s?nowMS=100000", nil)
+	require.Equal(t, refBody, body)
+
+	// Go clients stop after 10 redirects
+	_, err := http.Get(ts.URL + "/livesim2/redirect_11/testpic_2s/V300/45.m4s?nowMS=100000")
+	require.Error(t, err)
+
+	for _, bad := range []string{"redirect_0", "redirect_31", "redirect_1_0_301", "redirect_1_-1", "redirect_1_0_302_1"} {
+		resp = hop("/livesim2/" + bad + "/testpic_2s/V300/45.m4s?nowMS=100000")
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, bad)
+	}
+
+	// The remaining depth is carried in the URL, so overlays must not set or change it
+	for _, path := range []string{"/livesim2/testpic_2s/V300/45.m4s", "/livesim2/redirect_1/testpic_2s/V300/45.m4s"} {
+		req, err := http.NewRequest("GET", ts.URL+path+"?nowMS=100000", nil)
+		require.NoError(t, err)
+		req.Header.Set(configHeader, `{"Redirect": {"Depth": 2}}`)
+		resp, err = noFollow.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, path)
+	}
+	req, err := http.NewRequest("GET", ts.URL+"/livesim2/redirect_2/testpic_2s/V300/45.m4s?nowMS=100000", nil)
+	require.NoError(t, err)
+	req.Header.Set(configHeader, `{"Redirect": {"Depth": 2, "Code": 302}}`)
+	resp, err = noFollow.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+}
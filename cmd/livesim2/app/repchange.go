@@ -0,0 +1,78 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+)
+
+const (
+	repChangeAdd    = "add"
+	repChangeRemove = "remove"
+)
+
+// RepChange configures Representations that appear or disappear at a specific time.
+// An AdaptationSet disappears when all its Representations are removed.
+// For multi-period MPDs, the change applies from the first Period starting at or after AtS.
+type RepChange struct {
+	Mode   string   `json:"Mode"`
+	AtS    int      `json:"AtS"`
+	RepIDs []string `json:"RepIDs"`
+}
+
+// validate checks the mode, that AtS >= 0, and that the RepIDs are not empty.
+func (rc *RepChange) validate() error {
+	if rc.Mode != repChangeAdd && rc.Mode != repChangeRemove {
+		return fmt.Errorf("repchange unknown mode %q, allowed: %s, %s", rc.Mode, repChangeAdd, repChangeRemove)
+	}
+	if rc.AtS < 0 {
+		return fmt.Errorf("repchange atS=%d must be >= 0", rc.AtS)
+	}
+	if len(rc.RepIDs) == 0 || slices.Contains(rc.RepIDs, "") {
+		return fmt.Errorf("repchange repIDs %q must be non-empty", rc.RepIDs)
+	}
+	return nil
+}
+
+// present returns true if the Representations should be in a Period starting at periodStartMS given nowMS.
+func (rc *RepChange) present(periodStartMS, nowMS int, multiPeriod bool) bool {
+	t := nowMS
+	if multiPeriod {
+		t = periodStartMS
+	}
+	changed := t >= rc.AtS*1000
+	if rc.Mode == repChangeAdd {
+		return changed
+	}
+	return !changed
+}
+
+// applyRepChange removes the Representations configured by rc from Periods where they should not be present.
+func applyRepChange(mpd *m.MPD, rc *RepChange, nowMS int) {
+	multiPeriod := len(mpd.Periods) > 1
+	for _, p := range mpd.Periods {
+		periodStartMS := 0
+		if p.Start != nil {
+			periodStartMS = int(time.Duration(*p.Start).Milliseconds())
+		}
+		if rc.present(periodStartMS, nowMS, multiPeriod) {
+			continue
+		}
+		adaptationSets := p.AdaptationSets[:0]
+		for _, as := range p.AdaptationSets {
+			as.Representations = slices.DeleteFunc(as.Representations, func(rep *m.RepresentationType) bool {
+				return slices.Contains(rc.RepIDs, rep.Id)
+			})
+			if len(as.Representations) > 0 {
+				adaptationSets = append(adaptationSets, as)
+			}
+		}
+		p.AdaptationSets = adaptationSets
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"net/http"
+	"testing"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepChange(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	testCases := []struct {
+		desc         string
+		url          string
+		wantedStatus int
+		wantedReps   map[string][]string // Period id to Representation ids
+	}{
+		{
+			desc:         "before removal",
+			url:          "/livesim2/repchange_remove_90_V300/testpic_2s/Manifest.mpd?nowMS=80000",
+			wantedStatus: http.StatusOK,
+			wantedReps:   map[string][]string{"P0": {"A48", "V300"}},
+		},
+		{
+			desc:         "after removal",
+			url:          "/livesim2/repchange_remove_90_V300/testpic_2s/Manifest.mpd?nowMS=100000",
+			wantedStatus: http.StatusOK,
+			wantedReps:   map[string][]string{"P0": {"A48"}},
+		},
+		{
+			desc:         "AdaptationSet added at Period boundary",
+			url:          "/livesim2/periods_60/repchange_add_90_A48/testpic_2s/Manifest.mpd?nowMS=150000",
+			wantedStatus: http.StatusOK,
+			wantedReps:   map[string][]string{"P1": {"V300"}, "P2": {"A48", "V300"}},
+		},
+		{
+			desc:         "bad mode",
+			url:          "/livesim2/repchange_drop_90_A48/testpic_2s/Manifest.mpd",
+			wantedStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			resp, body := testFullRequest(t, ts, "GET", tc.url, nil)
+			require.Equal(t, tc.wantedStatus, resp.StatusCode)
+			if tc.wantedStatus != http.StatusOK {
+				return
+			}
+			mpd, err := m.ReadFromString(string(body))
+			require.NoError(t, err)
+			gotReps := make(map[string][]string)
+			for _, p := range mpd.Periods {
+				for _, as := range p.AdaptationSets {
+					for _, rep := range as.Representations {
+						gotReps[p.Id] = append(gotReps[p.Id], rep.Id)
+					}
+				}
+			}
+			for pID, wanted := range tc.wantedReps {
+				require.ElementsMatch(t, wanted, gotReps[pID], pID)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+)
+
+var repIDSuffixRegexp = regexp.MustCompile(`^[a-zA-Z0-9-]+$`)
+
+// RepIDChange configures a change of Representation@id at a specific time, as after an encoder failover.
+// The new id is the old one with Suffix appended, so segment paths using $RepresentationID$ change as well.
+// For multi-period MPDs, the change applies from the first Period starting at or after AtS.
+type RepIDChange struct {
+	AtS    int    `json:"AtS"`
+	Suffix string `json:"Suffix"`
+}
+
+// validate checks that AtS >= 0 and that Suffix is alphanumeric, so that it cannot change the segment paths.
+func (rc *RepIDChange) validate() error {
+	if rc.AtS < 0 {
+		return fmt.Errorf("repidchange atS=%d must be >= 0", rc.AtS)
+	}
+	if !repIDSuffixRegexp.MatchString(rc.Suffix) {
+		return fmt.Errorf("repidchange suffix %q is not alphanumeric", rc.Suffix)
+	}
+	return nil
+}
+
+// applyRepIDChange renames the asset's Representations in Periods where the change is active.
+func applyRepIDChange(mpd *m.MPD, a *asset, rc *RepIDChange, nowMS int) {
+	multiPeriod := len(mpd.Periods) > 1
+	for _, p := range mpd.Periods {
+		t := nowMS
+		if multiPeriod && p.Start != nil {
+			t = int(time.Duration(*p.Start).Milliseconds())
+		}
+		if t < rc.AtS*1000 {
+			continue
+		}
+		for _, as := range p.AdaptationSets {
+			for _, rep := range as.Representations {
+				if _, ok := a.Reps[rep.Id]; ok {
+					rep.Id += rc.Suffix
+				}
+			}
+		}
+	}
+}
+
+// origSegmentPart maps a segment path with a renamed Representation back to the original one.
+// Paths with the original ids are returned unchanged, so old segment URLs continue to work.
+func (rc *RepIDChange) origSegmentPart(a *asset, segmentPart string) string {
+	ids := make([]string, 0, len(a.Reps))
+	for id := range a.Reps {
+		ids = append(ids, id)
+	}
+	// Longest first, so that an id being a prefix of another does not match the wrong one
+	sort.Slice(ids, func(i, j int) bool { return len(ids[i]) > len(ids[j]) })
+	for _, id := range ids {
+		if strings.Contains(segmentPart, id+rc.Suffix) {
+			return strings.Replace(segmentPart, id+rc.Suffix, id, 1)
+		}
+	}
+	return segmentPart
+}
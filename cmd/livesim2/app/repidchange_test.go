@@ -0,0 +1,41 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepIDChange(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	prefix := "/livesim2/repidchange_90_b2/testpic_2s/"
+	testCases := []struct {
+		desc         string
+		url          string
+		wantedStatus int
+		wantedInBody string
+	}{
+		{desc: "MPD before change", url: "Manifest.mpd?nowMS=80000", wantedStatus: http.StatusOK, wantedInBody: `id="V300"`},
+		{desc: "MPD after change", url: "Manifest.mpd?nowMS=100000", wantedStatus: http.StatusOK, wantedInBody: `id="V300b2"`},
+		{desc: "new init segment", url: "V300b2/init.mp4", wantedStatus: http.StatusOK},
+		{desc: "new media segment", url: "V300b2/45.m4s?nowMS=100000", wantedStatus: http.StatusOK},
+		{desc: "old media segment", url: "V300/45.m4s?nowMS=100000", wantedStatus: http.StatusOK},
+		{desc: "unknown representation", url: "V301b2/45.m4s?nowMS=100000", wantedStatus: http.StatusNotFound},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			resp, body := testFullRequest(t, ts, "GET", prefix+tc.url, nil)
+			require.Equal(t, tc.wantedStatus, resp.StatusCode)
+			if tc.wantedInBody != "" {
+				require.True(t, strings.Contains(string(body), tc.wantedInBody))
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/Dash-Industry-Forum/livesim2/pkg/logging"
+)
+
+// ReplayOptions configures a replay of a recorded session.
+type ReplayOptions struct {
+	HARPath     string
+	VodRoot     string
+	RepDataRoot string
+	LogLevel    string
+}
+
+// ParseReplayArgs parses the arguments after the replay subcommand.
+func ParseReplayArgs(args []string) (*ReplayOptions, error) {
+	o := ReplayOptions{}
+	f := pflag.NewFlagSet("replay", pflag.ContinueOnError)
+	f.StringVar(&o.VodRoot, "vodroot", DefaultConfig.VodRoot, "VoD root directory")
+	f.StringVar(&o.RepDataRoot, "repdataroot", DefaultConfig.RepDataRoot, `Representation metadata root directory. "+" copies vodroot value. "-" disables usage.`)
+	f.StringVar(&o.LogLevel, "loglevel", "WARN", "log level for the replay server")
+	f.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: livesim2 replay [options] <session.har>\n\n")
+		fmt.Fprintf(os.Stderr, "Re-issue the requests of a recorded session with the same wall-clock times\n")
+		fmt.Fprintf(os.Stderr, "against this build, and report responses that differ in status, size, or content.\n\nOptions:\n")
+		f.PrintDefaults()
+	}
+	if err := f.Parse(args); err != nil {
+		return nil, err
+	}
+	if f.NArg() != 1 {
+		f.Usage()
+		return nil, fmt.Errorf("need exactly one HAR file")
+	}
+	o.HARPath = f.Arg(0)
+	return &o, nil
+}
+
+// Replay sets up a server and replays the recorded session, writing differences to w.
+// The number of differing responses is returned.
+func Replay(ctx context.Context, o *ReplayOptions, w io.Writer) (int, error) {
+	data, err := os.ReadFile(o.HARPath)
+	if err != nil {
+		return 0, err
+	}
+	var har HAR
+	if err := json.Unmarshal(data, &har); err != nil {
+		return 0, fmt.Errorf("parse HAR: %w", err)
+	}
+	if err := logging.InitSlog(o.LogLevel, "text"); err != nil {
+		return 0, err
+	}
+	cfg := DefaultConfig
+	cfg.VodRoot = o.VodRoot
+	cfg.RepDataRoot = o.RepDataRoot
+	if cfg.RepDataRoot == "+" {
+		cfg.RepDataRoot = cfg.VodRoot
+	}
+	cfg.TimeoutS = 0
+	server, err := SetupServer(ctx, &cfg)
+	if err != nil {
+		return 0, err
+	}
+	return replayHAR(server.Router, &har, w)
+}
+
+// replayHAR re-issues the HAR requests to handler and compares the responses.
+// session_<id> URL parameters are replaced by the recorded session configuration in a header.
+func replayHAR(handler http.Handler, har *HAR, w io.Writer) (int, error) {
+	nrDiffs := 0
+	for i, e := range har.Log.Entries {
+		u, err := url.Parse(e.Request.URL)
+		if err != nil {
+			return nrDiffs, fmt.Errorf("entry %d: %w", i, err)
+		}
+		hadSession := sessionIDFromPath(u.Path) != ""
+		u.Path = removeSessionFromPath(u.Path)
+		u.RawPath = ""
+		clockMS := e.WallClockMS
+		if clockMS == 0 {
+			clockMS = int(e.StartedDateTime.UnixMilli())
+		}
+		// An absolute URL sets Host and TLS, so that generated MPD URLs are the same as when recorded
+		req := httptest.NewRequest(e.Request.Method, u.String(), nil)
+		req = req.WithContext(context.WithValue(req.Context(), wallClockKey{}, clockMS))
+		for _, h := range e.Request.Headers {
+			req.Header.Add(h.Name, h.Value)
+		}
+		if hadSession && len(har.Log.LivesimConfig) > 0 && req.Header.Get(configHeader) == "" {
+			req.Header.Set(configHeader, string(har.Log.LivesimConfig))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		var issues []string
+		if rec.Code != e.Response.Status {
+			issues = append(issues, fmt.Sprintf("status %d != %d", e.Response.Status, rec.Code))
+		}
+		if size := rec.Body.Len(); size != e.Response.BodySize {
+			issues = append(issues, fmt.Sprintf("size %d != %d", e.Response.BodySize, size))
+		}
+		if e.Response.Content.SHA256 != "" {
+			sum := sha256.Sum256(rec.Body.Bytes())
+			if hex.EncodeToString(sum[:]) != e.Response.Content.SHA256 {
+				issues = append(issues, "content differs")
+			}
+		}
+		clock := time.UnixMilli(int64(clockMS)).UTC().Format(time.RFC3339Nano)
+		if len(issues) == 0 {
+			fmt.Fprintf(w, "%d %s %s at %s: ok\n", i, e.Request.Method, u.RequestURI(), clock)
+			continue
+		}
+		nrDiffs++
+		fmt.Fprintf(w, "%d %s %s at %s: %v\n", i, e.Request.Method, u.RequestURI(), clock, issues)
+	}
+	return nrDiffs, nil
+}
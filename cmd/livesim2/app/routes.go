@@ -44,11 +44,13 @@ func (s *Server) Routes(ctx context.Context) error {
 	for _, route := range logging.LogRoutes {
 		s.Router.MethodFunc(route.Method, route.Path, route.Handler)
 	}
-	s.Router.Mount("/debug", middleware.Profiler())
-	s.Router.MethodFunc("GET", "/healthz", s.healthzHandlerFunc)
+	if s.Cfg.Pprof {
+		s.Router.Mount("/debug", middleware.Profiler())
+	}
+	for _, rr := range rootRoutes() {
+		s.Router.MethodFunc(rr.op.Method, rr.routePattern(), rr.handler(s))
+	}
 	s.Router.MethodFunc("GET", "/favicon.ico", s.favIconFunc)
-	s.Router.MethodFunc("GET", "/config", s.configHandlerFunc)
-	s.Router.MethodFunc("GET", "/version", s.versionHandlerFunc)
 	s.Router.MethodFunc("GET", "/assets", s.assetsHandlerFunc)
 	s.Router.MethodFunc("GET", "/vod", s.assetsHandlerFunc)
 	s.Router.MethodFunc("GET", "/urlgen/*", s.urlGenHandlerFunc)
@@ -56,10 +58,13 @@ func (s *Server) Routes(ctx context.Context) error {
 	s.Router.MethodFunc("GET", "/static/*", s.embeddedStaticHandlerFunc)
 	s.Router.MethodFunc("HEAD", "/static/*", s.embeddedStaticHandlerFunc)
 	s.Router.MethodFunc("GET", "/reqcount", s.reqCountHandlerFunc)
+	s.Router.MethodFunc("GET", timePath, s.timeHandlerFunc)
+	s.Router.MethodFunc("HEAD", timePath, s.timeHandlerFunc)
 	s.Router.MethodFunc("OPTIONS", "/*", s.optionsHandlerFunc)
 	s.Router.Handle("/player/*", createReversePlayerProxy("/player", s.Cfg.PlayURL))
 	s.Router.MethodFunc("GET", "/patch/*", s.patchHandlerFunc)
 	s.Router.MethodFunc("GET", "/", s.indexHandlerFunc)
+	s.Router.MethodFunc("POST", "/sand", s.sandHandlerFunc)
 	s.Router.MethodFunc("POST", "/*", s.laURLHandlerFunc)
 	// LiveRouter is mounted at /livesim2
 	s.LiveRouter.MethodFunc("GET", "/*", s.livesimHandlerFunc)
@@ -0,0 +1,157 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/xml"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// sandNamespace is the namespace of SAND messages (ISO/IEC 23009-5)
+	sandNamespace = "urn:mpeg:dash:schema:sandmessage:2016"
+	// sandContentType is the MIME type of SAND messages
+	sandContentType = "application/sand+xml"
+	// sandSenderID identifies livesim2 as DANE in PER messages
+	sandSenderID = "livesim2"
+	// maxSANDClients limits the number of clients for which status messages are kept.
+	// The least recently updated is dropped when the limit is reached.
+	maxSANDClients = 1000
+	// sandPERValidityS is the validity of PER messages in seconds
+	sandPERValidityS = 10
+)
+
+// SANDMessage is the envelope of received SAND messages.
+// Individual messages are kept as generic elements, since livesim2 only records status messages.
+type SANDMessage struct {
+	XMLName        xml.Name         `xml:"SANDMessage"`
+	SenderID       string           `xml:"senderId,attr,omitempty"`
+	GenerationTime string           `xml:"generationTime,attr,omitempty"`
+	Messages       []SANDAnyMessage `xml:",any"`
+}
+
+// SANDPERMessage is the envelope of PER messages sent by livesim2.
+type SANDPERMessage struct {
+	XMLName        xml.Name           `xml:"SANDMessage"`
+	Xmlns          string             `xml:"xmlns,attr"`
+	SenderID       string             `xml:"senderId,attr"`
+	GenerationTime string             `xml:"generationTime,attr"`
+	Throughput     *SANDPERThroughput `xml:"Throughput,omitempty"`
+}
+
+// SANDAnyMessage is a status or metrics message from a client.
+type SANDAnyMessage struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	InnerXML string     `xml:",innerxml"`
+}
+
+// SANDPERThroughput is a PER message with a throughput hint for the client.
+type SANDPERThroughput struct {
+	MessageID            int    `xml:"messageId,attr"`
+	ValidityTime         string `xml:"validityTime,attr"`
+	GuaranteedThroughput int    `xml:"guaranteedThroughput,attr"`
+}
+
+// SANDStatus is one received status message.
+type SANDStatus struct {
+	Time     time.Time         `json:"time" doc:"Wall-clock time of reception"`
+	Type     string            `json:"type" doc:"Message element name, e.g. ClientCapabilities"`
+	Attrs    map[string]string `json:"attrs,omitempty" doc:"Message attributes"`
+	InnerXML string            `json:"innerXML,omitempty" doc:"Message content"`
+}
+
+// SANDClient summarizes the status messages received from one client.
+type SANDClient struct {
+	SenderID string       `json:"senderId" doc:"SAND senderId or client IP if not set"`
+	Total    int          `json:"total" doc:"Number of status messages received"`
+	Updated  time.Time    `json:"updated" doc:"Time of last message"`
+	Messages []SANDStatus `json:"messages" doc:"Last status messages, oldest first"`
+}
+
+// sandDANE is a minimal DASH-Aware Network Element that records status messages per client
+// and answers with PER messages.
+type sandDANE struct {
+	mu             sync.Mutex
+	size           int
+	throughputKbps int
+	nextMessageID  int
+	clients        map[string]*SANDClient
+}
+
+func newSANDDANE(size, throughputKbps int) *sandDANE {
+	return &sandDANE{size: size, throughputKbps: throughputKbps, clients: make(map[string]*SANDClient)}
+}
+
+// receive records the messages of sm for senderID and returns the PER response.
+func (d *sandDANE) receive(senderID string, sm *SANDMessage, now time.Time) *SANDPERMessage {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c, ok := d.clients[senderID]
+	if !ok {
+		if len(d.clients) >= maxSANDClients {
+			d.dropOldest()
+		}
+		c = &SANDClient{SenderID: senderID}
+		d.clients[senderID] = c
+	}
+	for _, msg := range sm.Messages {
+		st := SANDStatus{Time: now, Type: msg.XMLName.Local, InnerXML: msg.InnerXML}
+		if len(msg.Attrs) > 0 {
+			st.Attrs = make(map[string]string, len(msg.Attrs))
+			for _, a := range msg.Attrs {
+				st.Attrs[a.Name.Local] = a.Value
+			}
+		}
+		c.Messages = append(c.Messages, st)
+		c.Total++
+	}
+	if extra := len(c.Messages) - d.size; extra > 0 {
+		c.Messages = append([]SANDStatus(nil), c.Messages[extra:]...)
+	}
+	c.Updated = now
+
+	resp := SANDPERMessage{
+		Xmlns:          sandNamespace,
+		SenderID:       sandSenderID,
+		GenerationTime: now.UTC().Format(time.RFC3339),
+	}
+	if d.throughputKbps > 0 {
+		d.nextMessageID++
+		resp.Throughput = &SANDPERThroughput{
+			MessageID:            d.nextMessageID,
+			ValidityTime:         now.Add(sandPERValidityS * time.Second).UTC().Format(time.RFC3339),
+			GuaranteedThroughput: d.throughputKbps,
+		}
+	}
+	return &resp
+}
+
+func (d *sandDANE) dropOldest() {
+	var oldestID string
+	var oldest time.Time
+	for id, c := range d.clients {
+		if oldestID == "" || c.Updated.Before(oldest) {
+			oldestID, oldest = id, c.Updated
+		}
+	}
+	delete(d.clients, oldestID)
+}
+
+// list returns copies of all clients sorted by senderId.
+func (d *sandDANE) list() []SANDClient {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	clients := make([]SANDClient, 0, len(d.clients))
+	for _, c := range d.clients {
+		cc := *c
+		cc.Messages = append([]SANDStatus(nil), c.Messages...)
+		clients = append(clients, cc)
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].SenderID < clients[j].SenderID })
+	return clients
+}
@@ -0,0 +1,67 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testSANDStatus = `<?xml version="1.0" encoding="UTF-8"?>
+<SANDMessage xmlns="urn:mpeg:dash:schema:sandmessage:2016" senderId="player1" generationTime="2024-01-01T00:00:00Z">
+  <ClientCapabilities messageId="1"><supportedMessage messageType="Throughput"/></ClientCapabilities>
+  <BufferLevel messageId="2"><entry t="2024-01-01T00:00:00Z" level="4000"/></BufferLevel>
+  <Throughput messageId="3" throughput="3500"/>
+</SANDMessage>`
+
+func TestSAND(t *testing.T) {
+	_, ts := newTestServer(t, func(cfg *ServerConfig) {
+		cfg.SAND = 2
+		cfg.SANDThroughputKbps = 5000
+	})
+
+	resp, body := testFullRequest(t, ts, "POST", "/sand", strings.NewReader(testSANDStatus))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, sandContentType, resp.Header.Get("Content-Type"))
+	var per SANDPERMessage
+	require.NoError(t, xml.Unmarshal(body, &per))
+	require.Equal(t, sandSenderID, per.SenderID)
+	require.NotNil(t, per.Throughput)
+	require.Equal(t, 5000, per.Throughput.GuaranteedThroughput)
+
+	resp, body = testFullRequest(t, ts, "GET", "/api/sand", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var list struct {
+		Size    int          `json:"size"`
+		Clients []SANDClient `json:"clients"`
+	}
+	require.NoError(t, json.Unmarshal(body, &list))
+	require.Equal(t, 2, list.Size)
+	require.Len(t, list.Clients, 1)
+	c := list.Clients[0]
+	require.Equal(t, "player1", c.SenderID)
+	require.Equal(t, 3, c.Total)
+	require.Len(t, c.Messages, 2)
+	require.Equal(t, "BufferLevel", c.Messages[0].Type)
+	require.Equal(t, "Throughput", c.Messages[1].Type)
+	require.Equal(t, "3500", c.Messages[1].Attrs["throughput"])
+
+	resp, _ = testFullRequest(t, ts, "POST", "/sand", strings.NewReader("not xml"))
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestSANDDisabled(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	resp, _ := testFullRequest(t, ts, "POST", "/sand", strings.NewReader(testSANDStatus))
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = testFullRequest(t, ts, "GET", "/api/sand", nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
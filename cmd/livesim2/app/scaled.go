@@ -0,0 +1,65 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// In scaled mode, several livesim2 instances serve the same content behind a load balancer.
+// All live output is then derived from the URL configuration, the assets, and the UTC wall-clock
+// time, so any instance gives the same response to a request at the same time. Features that
+// keep state in one instance are rejected, since a following request may go to another instance.
+// CMAF ingest segment numbers are also derived from the wall-clock time, but the ingester ids and
+// control API are per instance.
+
+// checkScaledConfig returns an error if a stateful option is set in scaled mode.
+func checkScaledConfig(cfg *ServerConfig) error {
+	if !cfg.Scaled {
+		return nil
+	}
+	stateful := []struct {
+		name string
+		set  bool
+	}{
+		{"maxrequests", cfg.MaxRequests > 0},
+		{"mpdhistory", cfg.MPDHistory > 0},
+		{"qoereports", cfg.QoEReports > 0},
+		{"cmcdsessions", cfg.CMCDSessions > 0},
+		{"sand", cfg.SAND > 0},
+		{"statefile", cfg.StateFile != ""},
+		{"assetupload", cfg.AssetUpload},
+	}
+	for _, s := range stateful {
+		if s.set {
+			return fmt.Errorf("scaled mode: %s keeps per-instance state and cannot be used", s.name)
+		}
+	}
+	return nil
+}
+
+// liveSessions returns the sessions used for session_<id> URL parameters.
+// It is nil in scaled mode, since sessions are only stored in the instance that created them.
+func (s *Server) liveSessions() *sessionStore {
+	if s.Cfg.Scaled {
+		return nil
+	}
+	return s.sessions
+}
+
+// checkScaledResponseConfig returns an error if a response configuration, from the URL or an overlay,
+// uses a stateful option in scaled mode.
+func (s *Server) checkScaledResponseConfig(log *slog.Logger, cfg *ResponseConfig) *errorWithHttpType {
+	if !s.Cfg.Scaled {
+		return nil
+	}
+	if cfg.Quota != nil {
+		return generateAndLogHttpError(log, "scaled mode: quota keeps per-instance counters and cannot be used",
+			http.StatusBadRequest, reasonBadCombination)
+	}
+	return nil
+}
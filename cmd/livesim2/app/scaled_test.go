@@ -0,0 +1,76 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckScaledConfig(t *testing.T) {
+	cfg := ServerConfig{MPDHistory: 10, SAND: 5}
+	require.NoError(t, checkScaledConfig(&cfg))
+	cfg.Scaled = true
+	require.ErrorContains(t, checkScaledConfig(&cfg), "mpdhistory")
+	cfg.MPDHistory = 0
+	require.ErrorContains(t, checkScaledConfig(&cfg), "sand")
+	cfg.SAND = 0
+	require.NoError(t, checkScaledConfig(&cfg))
+}
+
+func TestScaledInstances(t *testing.T) {
+	var servers []*Server
+	var tss []*httptest.Server
+	for i := 0; i < 2; i++ {
+		server, ts := newTestServer(t, func(cfg *ServerConfig) {
+			cfg.Host = "https://livesim.example.com"
+			cfg.Scaled = true
+		})
+		servers = append(servers, server)
+		tss = append(tss, ts)
+	}
+
+	for _, path := range []string{
+		"/livesim2/segtimeline_1/testpic_2s/Manifest.mpd?nowMS=100000",
+		"/livesim2/chaos_5_2/testpic_2s/Manifest.mpd?nowMS=100000",
+		"/livesim2/testpic_2s/V300/49.m4s?nowMS=100000",
+	} {
+		resp0, body0 := testFullRequest(t, tss[0], "GET", path, nil)
+		resp1, body1 := testFullRequest(t, tss[1], "GET", path, nil)
+		require.Equal(t, http.StatusOK, resp0.StatusCode, path)
+		require.Equal(t, resp0.StatusCode, resp1.StatusCode, path)
+		require.Equal(t, body0, body1, path)
+	}
+
+	resp, _ := testFullRequest(t, tss[0], "POST", "/api/sessions", strings.NewReader(`{"config": {}}`))
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = testFullRequest(t, tss[0], "GET", "/livesim2/session_0123456789abcdef/testpic_2s/Manifest.mpd", nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Quota counters are per instance
+	resp, _ = testFullRequest(t, tss[0], "GET", "/livesim2/quota_5_V300/testpic_2s/V300/49.m4s?nowMS=100000", nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	req, err := http.NewRequest("GET", tss[0].URL+"/livesim2/testpic_2s/V300/49.m4s?nowMS=100000", nil)
+	require.NoError(t, err)
+	req.Header.Set(configHeader, `{"Quota": {"N": 5, "RepIDs": ["V300"], "WindowS": 60}}`)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// Ingest segment numbers only depend on the wall-clock time
+	var nrs []int
+	for _, s := range servers {
+		a, ok := s.assetMgr.findAsset("testpic_2s")
+		require.True(t, ok)
+		nrs = append(nrs, findLastSegNr(NewResponseConfig(), a, 100_000, a.refRep))
+	}
+	require.Equal(t, nrs[0], nrs[1])
+	require.Equal(t, 49, nrs[0])
+}
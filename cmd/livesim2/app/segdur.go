@@ -0,0 +1,352 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"path"
+	"strings"
+
+	"github.com/Dash-Industry-Forum/livesim2/internal"
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/Eyevinn/mp4ff/bits"
+	"github.com/Eyevinn/mp4ff/mp4"
+)
+
+// segDurAsset returns the asset re-chunked according to the segdur parameter in cfg.
+func segDurAsset(vodFS fs.FS, a *asset, cfg *ResponseConfig) (*asset, error) {
+	if cfg.SegDurS == nil {
+		return a, nil
+	}
+	return a.withSegDur(vodFS, int(math.Round(*cfg.SegDurS*1000)))
+}
+
+// withSegDur returns a version of the asset where video representations,
+// and other representations with own segments, are re-chunked to segments of segDurMS.
+// Audio that is aligned to the reference track follows automatically.
+// The re-chunked assets are cached, so they are only computed once per segment duration.
+func (a *asset) withSegDur(vodFS fs.FS, segDurMS int) (*asset, error) {
+	if segDurMS == a.SegmentDurMS {
+		return a, nil
+	}
+	if ra, ok := a.segDurAssets.Load(segDurMS); ok {
+		return ra.(*asset), nil
+	}
+	ra, err := a.rechunk(vodFS, segDurMS)
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := a.segDurAssets.LoadOrStore(segDurMS, ra)
+	return actual.(*asset), nil
+}
+
+// segSource is the part [start, end) of a source segment used in a re-chunked segment.
+type segSource struct {
+	seg        Segment
+	start, end uint64
+}
+
+// rechunk creates a new asset with segments of duration segDurMS.
+// Longer segments are made by concatenating the fragments of consecutive source segments,
+// and shorter segments by splitting source segments at fragment boundaries or sync samples.
+func (a *asset) rechunk(vodFS fs.FS, segDurMS int) (*asset, error) {
+	if a.refRep == nil || a.refRep.ContentType != "video" {
+		return nil, fmt.Errorf("segdur requires an asset with video")
+	}
+	if a.LoopDurMS%segDurMS != 0 {
+		return nil, fmt.Errorf("asset loop duration %dms is not a multiple of segdur %dms", a.LoopDurMS, segDurMS)
+	}
+	var rechunkSegs func(rep *RepData) ([]Segment, [][]segSource, error)
+	switch {
+	case segDurMS > a.SegmentDurMS && segDurMS%a.SegmentDurMS == 0:
+		rechunkSegs = mergeSegments(segDurMS / a.SegmentDurMS)
+	case segDurMS < a.SegmentDurMS && a.SegmentDurMS%segDurMS == 0:
+		rechunkSegs = splitSegments(vodFS, a.AssetPath, segDurMS)
+	default:
+		return nil, fmt.Errorf("segdur %dms is neither a multiple nor a divisor of asset segment duration %dms",
+			segDurMS, a.SegmentDurMS)
+	}
+	ra := &asset{
+		AssetPath:    a.AssetPath,
+		MPDs:         make(map[string]internal.MPDData, len(a.MPDs)),
+		SegmentDurMS: segDurMS,
+		LoopDurMS:    a.LoopDurMS,
+		Reps:         make(map[string]*RepData, len(a.Reps)),
+	}
+	for id, rep := range a.Reps {
+		if !rechunkRep(rep) {
+			ra.Reps[id] = rep
+			continue
+		}
+		segs, sources, err := rechunkSegs(rep)
+		if err != nil {
+			return nil, fmt.Errorf("representation %s: %w", id, err)
+		}
+		rr := *rep
+		rr.Segments = segs
+		rr.sources = sources
+		ra.Reps[id] = &rr
+	}
+	ra.refRep = ra.Reps[a.refRep.ID]
+	for name, md := range a.MPDs {
+		mpdStr, err := rechunkMPD(md.MPDStr, segDurMS)
+		if err != nil {
+			return nil, fmt.Errorf("rechunk MPD %s: %w", name, err)
+		}
+		md.MPDStr = mpdStr
+		ra.MPDs[name] = md
+	}
+	return ra, nil
+}
+
+// mergeSegments returns a function that groups factor consecutive segments into one.
+func mergeSegments(factor int) func(rep *RepData) ([]Segment, [][]segSource, error) {
+	return func(rep *RepData) ([]Segment, [][]segSource, error) {
+		nrSegs := len(rep.Segments)
+		if nrSegs%factor != 0 {
+			return nil, nil, fmt.Errorf("%d segments is not a multiple of %d", nrSegs, factor)
+		}
+		segs := make([]Segment, 0, nrSegs/factor)
+		sources := make([][]segSource, 0, nrSegs/factor)
+		for i := 0; i < nrSegs; i += factor {
+			group := rep.Segments[i : i+factor]
+			segs = append(segs, Segment{
+				StartTime: group[0].StartTime,
+				EndTime:   group[factor-1].EndTime,
+				Nr:        group[0].Nr,
+			})
+			srcs := make([]segSource, 0, factor)
+			for _, seg := range group {
+				srcs = append(srcs, segSource{seg: seg, start: seg.StartTime, end: seg.EndTime})
+			}
+			sources = append(sources, srcs)
+		}
+		return segs, sources, nil
+	}
+}
+
+// splitSegments returns a function that splits every segment into parts of segDurMS.
+// All split points must be at fragment boundaries or sync samples of the source segments.
+func splitSegments(vodFS fs.FS, assetPath string, segDurMS int) func(rep *RepData) ([]Segment, [][]segSource, error) {
+	return func(rep *RepData) ([]Segment, [][]segSource, error) {
+		if segDurMS*rep.MediaTimescale%1000 != 0 {
+			return nil, nil, fmt.Errorf("segdur %dms is not an integral number of ticks in timescale %d",
+				segDurMS, rep.MediaTimescale)
+		}
+		subDur := uint64(segDurMS * rep.MediaTimescale / 1000)
+		var segs []Segment
+		var sources [][]segSource
+		for _, seg := range rep.Segments {
+			if seg.dur()%subDur != 0 {
+				return nil, nil, fmt.Errorf("segment %d duration %d is not a multiple of %d", seg.Nr, seg.dur(), subDur)
+			}
+			splitTimes, err := rep.splitTimes(vodFS, assetPath, seg)
+			if err != nil {
+				return nil, nil, err
+			}
+			for t := seg.StartTime; t < seg.EndTime; t += subDur {
+				if t > seg.StartTime && !splitTimes[t] {
+					return nil, nil, fmt.Errorf("segdur %dms: segment %d has no fragment boundary or sync sample at time %d",
+						segDurMS, seg.Nr, t)
+				}
+				segs = append(segs, Segment{StartTime: t, EndTime: t + subDur, Nr: seg.Nr})
+				sources = append(sources, []segSource{{seg: seg, start: t, end: t + subDur}})
+			}
+		}
+		return segs, sources, nil
+	}
+}
+
+// splitTimes returns the times where the source segment can be cut by cutFragment,
+// which are the fragment starts and the sync samples of unencrypted single-trun fragments.
+func (r *RepData) splitTimes(vodFS fs.FS, assetPath string, seg Segment) (map[uint64]bool, error) {
+	segPath := path.Join(assetPath, replaceTimeAndNr(r.MediaURI, seg.StartTime, seg.Nr))
+	data, err := fs.ReadFile(vodFS, segPath)
+	if err != nil {
+		return nil, err
+	}
+	segFile, err := mp4.DecodeFileSR(bits.NewFixedSliceReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", segPath, err)
+	}
+	trex := getTrex(r.initSeg)
+	times := make(map[uint64]bool)
+	for _, s := range segFile.Segments {
+		for _, frag := range s.Fragments {
+			traf := frag.Moof.Traf
+			times[traf.Tfdt.BaseMediaDecodeTime()] = true
+			if traf.Senc != nil || len(traf.Truns) != 1 {
+				continue
+			}
+			fss, err := frag.GetFullSamples(trex)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", segPath, err)
+			}
+			for _, sample := range fss {
+				if mp4.IsSyncSampleFlags(sample.Flags) {
+					times[sample.DecodeTime] = true
+				}
+			}
+		}
+	}
+	return times, nil
+}
+
+// rechunkRep returns true if the representation is re-chunked.
+// Audio that is not pre-encrypted is generated from the reference track timing,
+// and text and image representations keep their segment durations.
+func rechunkRep(rep *RepData) bool {
+	switch rep.ContentType {
+	case "video":
+		return true
+	case "audio":
+		return rep.PreEncrypted
+	default:
+		return false
+	}
+}
+
+// rechunkMPD removes the SegmentTemplate durations of audio and video AdaptationSets,
+// so that they are calculated from the re-chunked segments, and sets maxSegmentDuration.
+func rechunkMPD(mpdStr string, segDurMS int) (string, error) {
+	mpd, err := m.ReadFromString(mpdStr)
+	if err != nil {
+		return "", err
+	}
+	if mpd.MaxSegmentDuration != nil {
+		mpd.MaxSegmentDuration = Ptr(m.Duration(segDurMS) * m.Duration(1_000_000))
+	}
+	for _, p := range mpd.Periods {
+		for _, as := range p.AdaptationSets {
+			ct := string(as.ContentType)
+			if ct == "" {
+				ct = guessContentTypeForAS(as)
+			}
+			if ct != "video" && ct != "audio" {
+				continue
+			}
+			if as.SegmentTemplate != nil {
+				as.SegmentTemplate.Duration = nil
+			}
+			for _, rep := range as.Representations {
+				if rep.SegmentTemplate != nil {
+					rep.SegmentTemplate.Duration = nil
+				}
+			}
+		}
+	}
+	var sb strings.Builder
+	if _, err := mpd.Write(&sb, "  ", true); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// readSegmentData reads the source data for a segment.
+// For re-chunked representations, the fragments of the source segment parts are concatenated into one segment.
+func (r *RepData) readSegmentData(vodFS fs.FS, assetPath string, time uint64, nr uint32) ([]byte, error) {
+	if r.sources == nil {
+		return fs.ReadFile(vodFS, path.Join(assetPath, replaceTimeAndNr(r.MediaURI, time, nr)))
+	}
+	idx := r.findSegmentIndexFromTime(time)
+	if idx == len(r.Segments) || r.Segments[idx].StartTime != time {
+		return nil, fmt.Errorf("no re-chunked segment at time %d", time)
+	}
+	trex := getTrex(r.initSeg)
+	var outSeg *mp4.MediaSegment
+	for _, src := range r.sources[idx] {
+		segPath := path.Join(assetPath, replaceTimeAndNr(r.MediaURI, src.seg.StartTime, src.seg.Nr))
+		data, err := fs.ReadFile(vodFS, segPath)
+		if err != nil {
+			return nil, err
+		}
+		sr := bits.NewFixedSliceReader(data)
+		segFile, err := mp4.DecodeFileSR(sr)
+		if err != nil {
+			return nil, fmt.Errorf("decode %s: %w", segPath, err)
+		}
+		if len(segFile.Segments) != 1 {
+			return nil, fmt.Errorf("%s has %d segments, not 1", segPath, len(segFile.Segments))
+		}
+		seg := segFile.Segments[0]
+		frags := make([]*mp4.Fragment, 0, len(seg.Fragments))
+		for _, frag := range seg.Fragments {
+			keep, err := cutFragment(frag, trex, src.start, src.end)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", segPath, err)
+			}
+			if keep {
+				frags = append(frags, frag)
+			}
+		}
+		if outSeg == nil {
+			outSeg = seg
+			outSeg.Sidx = nil
+			outSeg.Sidxs = nil
+			outSeg.Fragments = nil
+		}
+		for _, frag := range frags {
+			outSeg.AddFragment(frag)
+		}
+	}
+	if len(outSeg.Fragments) == 0 {
+		return nil, fmt.Errorf("no samples in re-chunked segment at time %d", time)
+	}
+	sw := bits.NewFixedSliceWriter(int(outSeg.Size()))
+	if err := outSeg.EncodeSW(sw); err != nil {
+		return nil, err
+	}
+	return sw.Bytes(), nil
+}
+
+// cutFragment keeps the samples of frag that have decode time in [start, end).
+// It returns false if no samples are left. A cut fragment must start with a sync sample.
+func cutFragment(frag *mp4.Fragment, trex *mp4.TrexBox, start, end uint64) (bool, error) {
+	traf := frag.Moof.Traf
+	fragStart := traf.Tfdt.BaseMediaDecodeTime()
+	defaultSampleDur := traf.Tfhd.DefaultSampleDuration
+	if !traf.Tfhd.HasDefaultSampleDuration() && trex != nil {
+		defaultSampleDur = trex.DefaultSampleDuration
+	}
+	fragEnd := fragStart + traf.Trun.Duration(defaultSampleDur)
+	if fragStart >= start && fragEnd <= end {
+		return true, nil
+	}
+	if fragEnd <= start || fragStart >= end {
+		return false, nil
+	}
+	if traf.Senc != nil || len(traf.Truns) != 1 {
+		return false, fmt.Errorf("cannot split encrypted or multi-trun fragment at time %d", start)
+	}
+	fss, err := frag.GetFullSamples(trex)
+	if err != nil {
+		return false, err
+	}
+	var kept []mp4.FullSample
+	for _, s := range fss {
+		if s.DecodeTime >= start && s.DecodeTime < end {
+			kept = append(kept, s)
+		}
+	}
+	if len(kept) == 0 {
+		return false, nil
+	}
+	if kept[0].DecodeTime != start || !mp4.IsSyncSampleFlags(kept[0].Flags) {
+		return false, fmt.Errorf("no sync sample at time %d", start)
+	}
+	trun := traf.Trun
+	trun.Samples = make([]mp4.Sample, 0, len(kept))
+	frag.Mdat.Data = nil
+	for _, s := range kept {
+		frag.AddFullSample(s)
+	}
+	if trun.HasFirstSampleFlags() {
+		trun.SetFirstSampleFlags(kept[0].Flags)
+	}
+	traf.Tfdt.SetBaseMediaDecodeTime(kept[0].DecodeTime)
+	return true, nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Eyevinn/mp4ff/mp4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSegDur(t *testing.T) {
+	server, ts := newTestServer(t, nil)
+
+	a, ok := server.assetMgr.findAsset("testpic_2s")
+	require.True(t, ok)
+	timescale := a.Reps["V300"].MediaTimescale
+
+	resp, body := testFullRequest(t, ts, "GET", "/livesim2/segdur_4/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), `duration="360000" startNumber="0" timescale="90000"`)
+	require.Contains(t, string(body), `minimumUpdatePeriod="PT4S"`)
+
+	resp, body = testFullRequest(t, ts, "GET", "/livesim2/segtimeline_1/segdur_4/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), fmt.Sprintf(`<S t="%d" d="%d" r="15">`, 36*timescale, 4*timescale))
+
+	getSeg := func(url string) *mp4.MediaSegment {
+		resp, body := testFullRequest(t, ts, "GET", url, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		f, err := mp4.DecodeFile(bytes.NewBuffer(body))
+		require.NoError(t, err)
+		require.Len(t, f.Segments, 1)
+		return f.Segments[0]
+	}
+	segDur := func(seg *mp4.MediaSegment, defaultSampleDur uint32) uint64 {
+		dur := uint64(0)
+		for _, frag := range seg.Fragments {
+			dur += frag.Moof.Traf.Trun.Duration(defaultSampleDur)
+		}
+		return dur
+	}
+
+	// Segment number 20 covers [80s, 84s)
+	video := getSeg("/livesim2/segdur_4/testpic_2s/V300/20.m4s?nowMS=100000")
+	require.Equal(t, uint64(80*timescale), video.Fragments[0].Moof.Traf.Tfdt.BaseMediaDecodeTime())
+	require.Equal(t, uint64(4*timescale), segDur(video, a.Reps["V300"].DefaultSampleDuration))
+	require.Equal(t, uint32(20), video.Fragments[len(video.Fragments)-1].Moof.Mfhd.SequenceNumber)
+
+	video = getSeg(fmt.Sprintf("/livesim2/segtimeline_1/segdur_4/testpic_2s/V300/%d.m4s?nowMS=100000", 80*timescale))
+	require.Equal(t, uint64(4*timescale), segDur(video, a.Reps["V300"].DefaultSampleDuration))
+
+	audio := getSeg("/livesim2/segdur_4/testpic_2s/A48/20.m4s?nowMS=100000")
+	audioDur := segDur(audio, a.Reps["A48"].DefaultSampleDuration)
+	require.InDelta(t, 4*48000, audioDur, 1024)
+
+	// Not yet available
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/segdur_4/testpic_2s/V300/25.m4s?nowMS=100000", nil)
+	require.Equal(t, http.StatusTooEarly, resp.StatusCode)
+
+	// 6s does not fit the 8s loop
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/segdur_6/testpic_2s/Manifest.mpd", nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	// 1.5s is neither a multiple nor a divisor of 2s
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/segdur_1.5/testpic_2s/Manifest.mpd", nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// Splitting 6s segments with sync samples every second into 2s segments
+	resp, body = testFullRequest(t, ts, "GET", "/livesim2/segdur_2/testpic_6s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), `duration="180000" startNumber="0" timescale="90000"`)
+	for _, nr := range []int{30, 31, 32} {
+		video = getSeg(fmt.Sprintf("/livesim2/segdur_2/testpic_6s/V300/%d.m4s?nowMS=100000", nr))
+		require.Equal(t, uint64(2*nr*timescale), video.Fragments[0].Moof.Traf.Tfdt.BaseMediaDecodeTime())
+		require.Equal(t, uint64(2*timescale), segDur(video, a.Reps["V300"].DefaultSampleDuration))
+		fss, err := video.Fragments[0].GetFullSamples(nil)
+		require.NoError(t, err)
+		require.True(t, mp4.IsSyncSampleFlags(fss[0].Flags))
+	}
+	audio = getSeg("/livesim2/segdur_2/testpic_6s/A48/31.m4s?nowMS=100000")
+	require.InDelta(t, 2*48000, segDur(audio, a.Reps["A48"].DefaultSampleDuration), 1024)
+
+	// 0.5s splits are not at sync samples, so both MPD and segments are rejected
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/segdur_0.5/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/segdur_0.5/testpic_2s/V300/195.m4s?nowMS=100000", nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,59 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Dash-Industry-Forum/livesim2/pkg/segtemplate"
+)
+
+const (
+	// tmplSegIDGroup is the regexp group name of the $Number$ or $Time$ value
+	tmplSegIDGroup = "id"
+	// tmplSubNrGroup is the regexp group name of the $SubNumber$ value
+	tmplSubNrGroup = "sub"
+)
+
+// mediaTemplateRegexp returns a regular expression matching the paths of a media template where
+// $RepresentationID$ and $Bandwidth$ have already been replaced. The $Number$ or $Time$ value is
+// captured in the group tmplSegIDGroup, and any $SubNumber$ value in the group tmplSubNrGroup.
+func mediaTemplateRegexp(str string) (*regexp.Regexp, error) {
+	ids := segtemplate.Identifiers(str)
+	var sb strings.Builder
+	pos := 0
+	nrSegIDs, nrSubNrs := 0, 0
+	for _, id := range ids {
+		sb.WriteString(regexp.QuoteMeta(str[pos:id.Start]))
+		pos = id.End
+		switch id.Name {
+		case "":
+			sb.WriteString(`\$`)
+		case "Number", "Time":
+			if nrSegIDs > 0 {
+				sb.WriteString(`\d+`)
+				continue
+			}
+			fmt.Fprintf(&sb, `(?P<%s>\d+)`, tmplSegIDGroup)
+			nrSegIDs++
+		case "SubNumber":
+			if nrSubNrs > 0 {
+				sb.WriteString(`\d+`)
+				continue
+			}
+			fmt.Fprintf(&sb, `(?P<%s>\d+)`, tmplSubNrGroup)
+			nrSubNrs++
+		default:
+			return nil, fmt.Errorf("identifier $%s$ not replaced in %q", id.Name, str)
+		}
+	}
+	if nrSegIDs == 0 {
+		return nil, fmt.Errorf("neither $Number$, nor $Time$ found in media")
+	}
+	sb.WriteString(regexp.QuoteMeta(str[pos:]))
+	return regexp.Compile(sb.String())
+}
@@ -0,0 +1,62 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"testing"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateSubstitution(t *testing.T) {
+	rep := &m.RepresentationType{Id: "V300", Bandwidth: 300000}
+	testCases := []struct {
+		tmpl       string
+		wantedTmpl string
+		wantedURI  string
+	}{
+		{"$RepresentationID$/$Number$.m4s", "V300/$Number$.m4s", "V300/7.m4s"},
+		{"$RepresentationID%03d$/$Number%05d$.m4s", "V300/$Number%05d$.m4s", "V300/00007.m4s"},
+		{"$Bandwidth%08d$/$Time%012d$.m4s", "00300000/$Time%012d$.m4s", "00300000/000000014000.m4s"},
+		{"$RepresentationID$/$Number%01d$_$SubNumber%02d$.m4s", "V300/$Number%01d$_$SubNumber%02d$.m4s", "V300/7_01.m4s"},
+		{"$RepresentationID$/$$$Time$$.m4s", "V300/$$$Time$$.m4s", "V300/$14000$.m4s"},
+		{"$Unknown$/$Number$.m4s", "$Unknown$/$Number$.m4s", "$Unknown$/7.m4s"},
+	}
+	for _, tc := range testCases {
+		tmpl := replaceIdentifiers(rep, tc.tmpl)
+		require.Equal(t, tc.wantedTmpl, tmpl, tc.tmpl)
+		require.Equal(t, tc.wantedURI, replaceTimeAndNr(tmpl, 14000, 7), tc.tmpl)
+	}
+	require.Equal(t, "V300/00042.m4s", replaceTimeOrNr("V300/$Time%05d$.m4s", 42))
+}
+
+func TestMediaTemplateRegexp(t *testing.T) {
+	testCases := []struct {
+		tmpl        string
+		segmentPart string
+		wantedID    int
+		wantedErr   error
+	}{
+		{"V300/$Number%05d$.m4s", "V300/00040.m4s", 40, nil},
+		{"V300/$Number%05d$.m4s", "V300/40.m4s", 40, nil},
+		{"V300/$Time%012d$.m4s", "V300/000000014000.m4s", 14000, nil},
+		{"V300/$SubNumber$/$Number$.m4s", "V300/1/40.m4s", 40, nil},
+		{"V300/$SubNumber$/$Number$.m4s", "V300/2/40.m4s", -1, errNotFound},
+		{"V300/$Number$.m4s", "V300/40xm4s", -1, errNotFound},
+	}
+	for _, tc := range testCases {
+		rexp, err := mediaTemplateRegexp(tc.tmpl)
+		require.NoError(t, err, tc.tmpl)
+		a := &asset{Reps: map[string]*RepData{"V300": {ID: "V300", MediaURI: tc.tmpl, mediaRegexp: rexp}}}
+		_, segID, err := findRepAndSegmentID(a, tc.segmentPart)
+		require.ErrorIs(t, err, tc.wantedErr, tc.segmentPart)
+		require.Equal(t, tc.wantedID, segID, tc.segmentPart)
+	}
+	_, err := mediaTemplateRegexp("V300/$SubNumber$.m4s")
+	require.Error(t, err)
+	_, err = mediaTemplateRegexp("$RepresentationID$/$Number$.m4s")
+	require.Error(t, err)
+}
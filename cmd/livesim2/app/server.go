@@ -8,9 +8,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"strconv"
 
+	"github.com/Dash-Industry-Forum/livesim2/pkg/storage"
 	"github.com/go-chi/chi/v5"
 
 	htmpl "html/template"
@@ -29,6 +31,30 @@ type Server struct {
 	textTemplates *ttmpl.Template
 	htmlTemplates *htmpl.Template
 	reqLimiter    *IPRequestLimiter
+	sessions      *sessionStore
+	mpdHistory    *mpdHistory
+	mpdSigner     *mpdSigner
+	hostAliases   []string
+	sand          *sandDANE
+	qoe           *qoeStore
+	cmcd          *cmcdStore
+	assetStats    *assetStats
+	latency       *latencyStats
+	quotas        *quotaStore
+	state         *stateStore
+	archive       storage.Storage
+	recordings    storage.Storage
+	bookmarks     *bookmarkStore
+	channels      map[string]*channel
+	proxies       map[string]*proxy
+	experiments   map[string]*experiment
+	logger        *slog.Logger
+	hooks         *hookRegistry
+	wasm          *wasmPlugins
+	// Set when embedded and started by Start
+	addr       string
+	httpServer *http.Server
+	listener   net.Listener
 }
 
 func (s *Server) healthzHandlerFunc(w http.ResponseWriter, r *http.Request) {
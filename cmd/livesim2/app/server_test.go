@@ -6,6 +6,7 @@ package app_test
 
 import (
 	"context"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -45,7 +46,16 @@ func TestServer(t *testing.T) {
 	// Test too early
 	resp, respBody = testRequest(t, ts, "GET", "/livesim2/testpic_2s/V300/100.m4s?nowMS=180000", nil)
 	require.Equal(t, http.StatusTooEarly, resp.StatusCode, "too early response code")
-	require.Equal(t, "too early by 22000ms\n", string(respBody))
+	require.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+	var problem struct {
+		Status int    `json:"status"`
+		Detail string `json:"detail"`
+		Reason string `json:"reason"`
+	}
+	require.NoError(t, json.Unmarshal(respBody, &problem))
+	require.Equal(t, http.StatusTooEarly, problem.Status)
+	require.Equal(t, "too early by 22000ms", problem.Detail)
+	require.Equal(t, "tooEarly", problem.Reason)
 
 	// Test healthz
 	resp, _ = testRequest(t, ts, "GET", "/healthz", nil)
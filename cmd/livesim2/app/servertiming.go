@@ -0,0 +1,101 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// serverTimingHeader breaks down the server time of a response, https://www.w3.org/TR/server-timing/.
+const serverTimingHeader = "Server-Timing"
+
+type arrivalKey struct{}
+type serverTimingKey struct{}
+
+// arrivalMiddleware stores the arrival time of the request, before any other middleware.
+func arrivalMiddleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), arrivalKey{}, time.Now())
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// serverTiming collects the server time of one response until its first byte.
+type serverTiming struct {
+	arrival time.Time // request arrival at the server
+	start   time.Time // start of the livesim2 handler
+	kind    string    // mpd or segment
+	// wait is the time intentionally spent waiting before the first byte,
+	// like waiting for chunk availability or configured latencies
+	wait time.Duration
+	sent bool
+}
+
+// newServerTiming starts collecting server timing for a request handled since start.
+// It returns the request with the timing in its context.
+func newServerTiming(r *http.Request, start time.Time, kind string) (*serverTiming, *http.Request) {
+	st := &serverTiming{arrival: start, start: start, kind: kind}
+	if arrival, ok := r.Context().Value(arrivalKey{}).(time.Time); ok {
+		st.arrival = arrival
+	}
+	return st, r.WithContext(context.WithValue(r.Context(), serverTimingKey{}, st))
+}
+
+// addServerTimingWait adds d to the wait time of the response, if server timing is collected
+// and no byte has been sent yet.
+func addServerTimingWait(ctx context.Context, d time.Duration) {
+	if st, ok := ctx.Value(serverTimingKey{}).(*serverTiming); ok && !st.sent {
+		st.wait += d
+	}
+}
+
+// value returns the Server-Timing header value with durations until firstByte.
+func (st *serverTiming) value(firstByte time.Time) string {
+	ms := func(d time.Duration) string {
+		return fmt.Sprintf("%.3f", float64(d.Microseconds())/1000)
+	}
+	gen := firstByte.Sub(st.start) - st.wait
+	metrics := []string{
+		fmt.Sprintf("queue;dur=%s;desc=\"before handler\"", ms(st.start.Sub(st.arrival))),
+		fmt.Sprintf("gen;dur=%s;desc=\"%s generation\"", ms(gen), st.kind),
+	}
+	if st.wait > 0 {
+		metrics = append(metrics, fmt.Sprintf("wait;dur=%s;desc=\"scheduled wait\"", ms(st.wait)))
+	}
+	metrics = append(metrics, fmt.Sprintf("total;dur=%s;desc=\"until first byte\"", ms(firstByte.Sub(st.arrival))))
+	return strings.Join(metrics, ", ")
+}
+
+// serverTimingWriter sets the Server-Timing header when the response header is written.
+type serverTimingWriter struct {
+	http.ResponseWriter
+	st *serverTiming
+}
+
+func (sw *serverTimingWriter) WriteHeader(status int) {
+	if !sw.st.sent {
+		sw.st.sent = true
+		sw.Header().Set(serverTimingHeader, sw.st.value(time.Now()))
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *serverTimingWriter) Write(p []byte) (int, error) {
+	if !sw.st.sent {
+		sw.WriteHeader(http.StatusOK)
+	}
+	return sw.ResponseWriter.Write(p)
+}
+
+func (sw *serverTimingWriter) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTimingValue(t *testing.T) {
+	arrival := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	st := serverTiming{arrival: arrival, start: arrival.Add(500 * time.Microsecond), kind: "segment"}
+	firstByte := arrival.Add(3500 * time.Microsecond)
+	require.Equal(t, `queue;dur=0.500;desc="before handler", gen;dur=3.000;desc="segment generation", `+
+		`total;dur=3.500;desc="until first byte"`, st.value(firstByte))
+	st.wait = 2 * time.Millisecond
+	require.Equal(t, `queue;dur=0.500;desc="before handler", gen;dur=1.000;desc="segment generation", `+
+		`wait;dur=2.000;desc="scheduled wait", total;dur=3.500;desc="until first byte"`, st.value(firstByte))
+}
+
+func TestServerTiming(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	resp, _ := testFullRequest(t, ts, "GET", "/livesim2/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, resp.Header.Get(serverTimingHeader))
+
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/servertiming_1/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	st := resp.Header.Get(serverTimingHeader)
+	require.True(t, strings.HasPrefix(st, "queue;dur="), st)
+	require.Contains(t, st, `desc="mpd generation"`)
+	require.NotContains(t, st, "wait;")
+	require.Contains(t, st, "total;dur=")
+
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/servertiming_1/testpic_2s/V300/45.m4s?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, resp.Header.Get(serverTimingHeader), `desc="segment generation"`)
+
+	// Error responses also carry the timing
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/servertiming_1/testpic_2s/V300/60.m4s?nowMS=100000", nil)
+	require.Equal(t, 425, resp.StatusCode)
+	require.Contains(t, resp.Header.Get(serverTimingHeader), "total;dur=")
+
+	// A configured redirect delay is reported as wait
+	noFollow := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	resp, err := noFollow.Get(ts.URL + "/livesim2/servertiming_1/redirect_1_50/testpic_2s/V300/45.m4s?nowMS=100000")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	require.Contains(t, resp.Header.Get(serverTimingHeader), `wait;dur=50.000;desc="scheduled wait"`)
+}
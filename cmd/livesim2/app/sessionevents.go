@@ -0,0 +1,138 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/Eyevinn/mp4ff/mp4"
+)
+
+// maxSessionEvents is the maximal number of events injected into a session
+const maxSessionEvents = 100
+
+// SessionEvent is an event injected into a session via the API.
+// Like blackout events, it is signaled by an MPD EventStream and by emsg boxes in the video segments.
+type SessionEvent struct {
+	SchemeIdUri string `json:"SchemeIdUri"`
+	Value       string `json:"Value,omitempty"`
+	ID          uint32 `json:"ID"`
+	StartS      int    `json:"StartS"` // Wall-clock time in seconds since the Epoch
+	DurS        int    `json:"DurS,omitempty"`
+	MessageData string `json:"MessageData,omitempty"`
+}
+
+func (ev *SessionEvent) validate() error {
+	if ev.SchemeIdUri == "" {
+		return fmt.Errorf("event %d has no schemeIdUri", ev.ID)
+	}
+	if ev.StartS < 0 || ev.DurS < 0 {
+		return fmt.Errorf("event %d start %ds and duration %ds must be >= 0", ev.ID, ev.StartS, ev.DurS)
+	}
+	return nil
+}
+
+// validateSessionEvents checks the events and that the ids are unique per scheme and value.
+func validateSessionEvents(events []SessionEvent) error {
+	if len(events) > maxSessionEvents {
+		return fmt.Errorf("%d events is more than %d", len(events), maxSessionEvents)
+	}
+	type key struct {
+		scheme, value string
+		id            uint32
+	}
+	ids := make(map[key]bool, len(events))
+	for i := range events {
+		ev := &events[i]
+		if err := ev.validate(); err != nil {
+			return err
+		}
+		k := key{ev.SchemeIdUri, ev.Value, ev.ID}
+		if ids[k] {
+			return fmt.Errorf("event id %d is not unique for scheme %s", ev.ID, ev.SchemeIdUri)
+		}
+		ids[k] = true
+	}
+	return nil
+}
+
+// sessionEventEmsgs returns emsg boxes for the events starting in the segment [segStart, segEnd).
+// The times are in timescale units with startTimeS as offset to wall-clock time.
+func sessionEventEmsgs(events []SessionEvent, segStart, segEnd, timescale uint64, startTimeS int) []*mp4.EmsgBox {
+	offset := int64(startTimeS) * int64(timescale)
+	var emsgs []*mp4.EmsgBox
+	for _, ev := range events {
+		t := int64(ev.StartS)*int64(timescale) - offset
+		if t < int64(segStart) || t >= int64(segEnd) {
+			continue
+		}
+		emsgs = append(emsgs, &mp4.EmsgBox{
+			Version:          1,
+			TimeScale:        uint32(timescale),
+			PresentationTime: uint64(t),
+			EventDuration:    uint32(uint64(ev.DurS) * timescale),
+			ID:               ev.ID,
+			SchemeIDURI:      ev.SchemeIdUri,
+			Value:            ev.Value,
+			MessageData:      []byte(ev.MessageData),
+		})
+	}
+	return emsgs
+}
+
+// addSessionEvents adds the events to EventStreams, one per scheme and value, of the Periods where they occur.
+// The event times are relative to the Period start in milliseconds.
+func addSessionEvents(mpd *m.MPD, events []SessionEvent, startTimeS int) {
+	for i, p := range mpd.Periods {
+		pStartMS := periodStartMS(p)
+		pEndMS := -1
+		if i < len(mpd.Periods)-1 {
+			pEndMS = periodStartMS(mpd.Periods[i+1])
+		}
+		for _, ev := range events {
+			tMS := (ev.StartS - startTimeS) * 1000
+			if tMS < pStartMS || (pEndMS >= 0 && tMS >= pEndMS) {
+				continue
+			}
+			es := sessionEventStream(p, ev.SchemeIdUri, ev.Value)
+			es.Events = append(es.Events, &m.EventType{
+				PresentationTime: uint64(tMS - pStartMS),
+				Duration:         uint64(ev.DurS) * 1000,
+				Id:               ev.ID,
+				MessageData:      ev.MessageData,
+			})
+		}
+	}
+}
+
+// sessionEventStream returns the EventStream of p with scheme and value, and adds it if not present.
+func sessionEventStream(p *m.Period, scheme, value string) *m.EventStreamType {
+	for _, es := range p.EventStreams {
+		if string(es.SchemeIdUri) == scheme && es.Value == value && es.Timescale != nil && *es.Timescale == 1000 {
+			return es
+		}
+	}
+	es := &m.EventStreamType{SchemeIdUri: m.AnyURI(scheme), Value: value, Timescale: Ptr(uint32(1000))}
+	p.EventStreams = append(p.EventStreams, es)
+	return es
+}
+
+// addSessionInbandEventStreams signals the inband events of all schemes and values in the video AdaptationSet.
+func addSessionInbandEventStreams(as *m.AdaptationSetType, events []SessionEvent) {
+	for _, ev := range events {
+		found := false
+		for _, ies := range as.InbandEventStreams {
+			if string(ies.SchemeIdUri) == ev.SchemeIdUri && ies.Value == ev.Value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			as.InbandEventStreams = append(as.InbandEventStreams,
+				&m.EventStreamType{SchemeIdUri: m.AnyURI(ev.SchemeIdUri), Value: ev.Value})
+		}
+	}
+}
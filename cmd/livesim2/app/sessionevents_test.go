@@ -0,0 +1,69 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Eyevinn/mp4ff/mp4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionEvents(t *testing.T) {
+	server, ts := newTestServer(t, nil)
+
+	resp, body := testFullRequest(t, ts, "POST", "/api/sessions", strings.NewReader(`{"config": {}}`))
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+	var info SessionInfo
+	require.NoError(t, json.Unmarshal(body, &info))
+	eventsPath := "/api/sessions/" + info.ID + "/events"
+
+	resp, body = testFullRequest(t, ts, "POST", eventsPath,
+		strings.NewReader(`{"schemeIdUri": "urn:test", "value": "1", "startS": 40, "durS": 4, "messageData": "ad"}`))
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+	resp, body = testFullRequest(t, ts, "POST", eventsPath,
+		strings.NewReader(`{"schemeIdUri": "urn:test", "value": "1", "id": 7, "startS": 60}`))
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+	var events []SessionEvent
+	require.NoError(t, json.Unmarshal(body, &events))
+	require.Equal(t, []SessionEvent{
+		{SchemeIdUri: "urn:test", Value: "1", ID: 40, StartS: 40, DurS: 4, MessageData: "ad"},
+		{SchemeIdUri: "urn:test", Value: "1", ID: 7, StartS: 60},
+	}, events)
+
+	// Event ids must be unique
+	resp, _ = testFullRequest(t, ts, "POST", eventsPath, strings.NewReader(`{"schemeIdUri": "urn:test", "value": "1", "id": 7}`))
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = testFullRequest(t, ts, "POST", "/api/sessions/0123/events", strings.NewReader(`{"schemeIdUri": "urn:test"}`))
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, body = testFullRequest(t, ts, "GET", info.URLPrefix+"/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	mpd := string(body)
+	require.Contains(t, mpd, `<InbandEventStream schemeIdUri="urn:test" value="1"`)
+	require.Contains(t, mpd, `<EventStream schemeIdUri="urn:test" value="1" timescale="1000">`)
+	require.Contains(t, mpd, `<Event presentationTime="40000" duration="4000" id="40" messageData="ad"`)
+	require.Contains(t, mpd, `<Event presentationTime="60000" id="7"`)
+
+	a, ok := server.assetMgr.findAsset("testpic_2s")
+	require.True(t, ok)
+	timescale := a.Reps["V300"].MediaTimescale
+	url := fmt.Sprintf("%s/segtimeline_1/testpic_2s/V300/%d.m4s?nowMS=100000", info.URLPrefix, 40*timescale)
+	resp, body = testFullRequest(t, ts, "GET", url, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	f, err := mp4.DecodeFile(bytes.NewBuffer(body))
+	require.NoError(t, err)
+	emsgs := f.Segments[0].Fragments[0].Emsgs
+	require.Len(t, emsgs, 1)
+	require.Equal(t, "urn:test", emsgs[0].SchemeIDURI)
+	require.Equal(t, uint64(40*timescale), emsgs[0].PresentationTime)
+	require.Equal(t, uint32(4*timescale), emsgs[0].EventDuration)
+	require.Equal(t, "ad", string(emsgs[0].MessageData))
+}
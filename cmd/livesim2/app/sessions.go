@@ -0,0 +1,211 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// configHeader carries a JSON ResponseConfig overlay on a request.
+	configHeader      = "X-Livesim-Config"
+	defaultSessionTTL = 24 * time.Hour
+	maxSessionTTL     = 7 * 24 * time.Hour
+)
+
+// configSession is a stored ResponseConfig overlay referenced by the session_<id> URL parameter.
+// If recorder is set, request metadata is recorded for HAR export.
+type configSession struct {
+	id       string
+	mu       sync.Mutex // protects config
+	config   []byte
+	expires  time.Time
+	recorder *harRecorder
+	webhook  *webhook
+	used     atomic.Bool
+}
+
+// notify sends a session event to the session webhook, if any.
+func (sess *configSession) notify(event string) {
+	sess.webhook.fire(slog.Default(), webhookEvent{Event: event, SessionID: sess.id})
+}
+
+// configJSON returns the stored config overlay.
+func (sess *configSession) configJSON() []byte {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sess.config
+}
+
+// sessionStore keeps config sessions in memory.
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*configSession
+	onEnd    func(sess *configSession) // called when a session is deleted or expires, if set
+}
+
+func newSessionStore() *sessionStore {
+	return &sessionStore{sessions: make(map[string]*configSession)}
+}
+
+// add stores a validated config overlay and returns the new session.
+// If record is true, requests using the session are recorded.
+// Session milestones are sent to wh if it is not nil.
+func (ss *sessionStore) add(cfgJSON []byte, ttl time.Duration, record bool, wh *webhook, now time.Time) (*configSession, error) {
+	if err := applyConfigJSON(NewResponseConfig(), cfgJSON); err != nil {
+		return nil, err
+	}
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, fmt.Errorf("session id: %w", err)
+	}
+	sess := &configSession{
+		id:      hex.EncodeToString(idBytes),
+		config:  cfgJSON,
+		expires: now.Add(ttl),
+		webhook: wh,
+	}
+	if record {
+		sess.recorder = newHARRecorder()
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.purgeExpired(now)
+	ss.sessions[sess.id] = sess
+	sess.notify(eventSessionCreated)
+	return sess, nil
+}
+
+// get returns a non-expired session.
+func (ss *sessionStore) get(id string, now time.Time) (*configSession, bool) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	sess, ok := ss.sessions[id]
+	if !ok || now.After(sess.expires) {
+		return nil, false
+	}
+	return sess, true
+}
+
+// remove deletes a session and reports whether it existed.
+func (ss *sessionStore) remove(id string) bool {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	sess, ok := ss.sessions[id]
+	if ok {
+		delete(ss.sessions, id)
+		sess.notify(eventSessionDeleted)
+		ss.ended(sess)
+	}
+	return ok
+}
+
+// setConfigField sets a top-level ResponseConfig field in the config of a non-expired session,
+// or removes it if value is nil.
+// found is false if there is no such session.
+func (ss *sessionStore) setConfigField(id, name string, value any, now time.Time) (found bool, err error) {
+	sess, ok := ss.get(id, now)
+	if !ok {
+		return false, nil
+	}
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(sess.config, &fields); err != nil {
+		return true, err
+	}
+	if value == nil {
+		delete(fields, name)
+	} else {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return true, err
+		}
+		fields[name] = data
+	}
+	cfgJSON, err := json.Marshal(fields)
+	if err != nil {
+		return true, err
+	}
+	cfg := NewResponseConfig()
+	if err := applyConfigJSON(cfg, cfgJSON); err != nil {
+		return true, err
+	}
+	sess.config = cfgJSON
+	return true, nil
+}
+
+func (ss *sessionStore) purgeExpired(now time.Time) {
+	for id, sess := range ss.sessions {
+		if now.After(sess.expires) {
+			delete(ss.sessions, id)
+			sess.notify(eventSessionExpired)
+			ss.ended(sess)
+		}
+	}
+}
+
+func (ss *sessionStore) ended(sess *configSession) {
+	if ss.onEnd != nil {
+		ss.onEnd(sess)
+	}
+}
+
+// applyConfigJSON overlays the fields present in data onto cfg.
+// Unknown fields are reported as errors.
+func applyConfigJSON(cfg *ResponseConfig, data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return newReasonError(reasonBadValue, fmt.Errorf("config JSON: %w", err))
+	}
+	return nil
+}
+
+// applyConfigOverlays applies a stored session config and then the X-Livesim-Config header
+// on top of the URL configuration.
+func applyConfigOverlays(cfg *ResponseConfig, sessions *sessionStore, hdrValue string, nowMS int) *errorWithHttpType {
+	applied := false
+	if cfg.SessionID != "" {
+		if sessions == nil {
+			return &errorWithHttpType{msg: "sessions not supported", statusCode: http.StatusNotFound, reason: reasonNotFound}
+		}
+		sess, ok := sessions.get(cfg.SessionID, time.Now())
+		if !ok {
+			msg := fmt.Sprintf("unknown or expired session %q", cfg.SessionID)
+			return &errorWithHttpType{msg: msg, statusCode: http.StatusNotFound, reason: reasonNotFound}
+		}
+		if err := applyConfigJSON(cfg, sess.configJSON()); err != nil {
+			return &errorWithHttpType{msg: err.Error(), statusCode: http.StatusBadRequest, reason: reasonBadValue}
+		}
+		if sess.used.CompareAndSwap(false, true) {
+			sess.notify(eventSessionFirstRequest)
+		}
+		applied = true
+	}
+	if hdrValue != "" {
+		if err := applyConfigJSON(cfg, []byte(hdrValue)); err != nil {
+			msg := fmt.Sprintf("%s header: %s", configHeader, err)
+			return &errorWithHttpType{msg: msg, statusCode: http.StatusBadRequest, reason: reasonBadValue}
+		}
+		applied = true
+	}
+	if applied {
+		if err := verifyAndFillConfig(cfg, nowMS); err != nil {
+			msg := fmt.Sprintf("config overlay: %s", err)
+			return &errorWithHttpType{msg: msg, statusCode: http.StatusBadRequest, reason: reasonFromError(err, reasonBadValue)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigHeader(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	testCases := []struct {
+		desc             string
+		header           string
+		wantedStatusCode int
+		wantedInMPD      []string
+	}{
+		{
+			desc:             "segment timeline and tsbd",
+			header:           `{"SegTimelineFlag": true, "TimeShiftBufferDepthS": 30}`,
+			wantedStatusCode: http.StatusOK,
+			wantedInMPD:      []string{"<SegmentTimeline>", `timeShiftBufferDepth="PT30S"`},
+		},
+		{
+			desc:             "unknown field",
+			header:           `{"SegTimeline": true}`,
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "size variance out of range",
+			header:           `{"SizeVariance": {"Pct": -1}}`,
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "mpd inflation out of range",
+			header:           `{"MPDInflate": {"Kind": "props", "N": 100000000}}`,
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "unknown mpd inflation kind",
+			header:           `{"MPDInflate": {"Kind": "reps", "N": 10}}`,
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "redirect outside URL",
+			header:           `{"Redirect": {"Depth": 2}}`,
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "zero quota",
+			header:           `{"Quota": {"N": 0, "RepIDs": ["V300"], "WindowS": 60}}`,
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "quota without window",
+			header:           `{"Quota": {"N": 5, "RepIDs": ["V300"]}}`,
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "zero mpd stall cycle",
+			header:           `{"MPDStall": {"CycleS": 0, "DurS": 1}}`,
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "zero slate cycle",
+			header:           `{"Slate": {"CycleS": 0, "DurS": 1}}`,
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "negative xhost n",
+			header:           `{"CrossHost": {"Mode": "rep", "N": -1}}`,
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "unknown xhost mode",
+			header:           `{"CrossHost": {"Mode": "period"}}`,
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "zero chaos level",
+			header:           `{"Chaos": {"Seed": 1, "Level": 0}}`,
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "unknown stlinject fault",
+			header:           `{"SegTimelineFlag": true, "STLInject": ["gap"]}`,
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "negative repchange time",
+			header:           `{"RepChange": {"Mode": "add", "AtS": -1, "RepIDs": ["V300"]}}`,
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "repchange without repIDs",
+			header:           `{"RepChange": {"Mode": "remove", "AtS": 10}}`,
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "repidchange path suffix",
+			header:           `{"RepIDChange": {"AtS": 0, "Suffix": "/../x"}}`,
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "zero ssai period",
+			header:           `{"SSAI": {"EveryS": 0, "DurS": 0}}`,
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "negative bandwidth drift",
+			header:           `{"BandwidthDrift": {"DefaultPct": -10}}`,
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "zero bandwidth drift for rep",
+			header:           `{"BandwidthDrift": {"RepPct": {"V300": 0}}}`,
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "unknown hdr kind",
+			header:           `{"HDR": {"Kind": "dolby"}}`,
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "zero chunk cadence",
+			header:           `{"ChunkDurS": 0.5, "AvailabilityTimeOffsetS": 1.5, "ChunkCadence": {"Mode": "front", "Pct": 0}}`,
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "negative publish time cadence",
+			header:           `{"PublishTimeCadence": -1}`,
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "zero segment status cycle",
+			header:           `{"SegStatus": [{"Cycle": 0, "Rsq": 0, "Code": 404}]}`,
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "traffic without intervals",
+			header:           `{"Traffic": [{}]}`,
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "negative segment duration",
+			header:           `{"SegDurS": -2}`,
+			wantedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:             "bad combination",
+			header:           `{"SegTimelineFlag": true, "SegTimelineNrFlag": true}`,
+			wantedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			req, err := http.NewRequest("GET", ts.URL+"/livesim2/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+			require.NoError(t, err)
+			req.Header.Set(configHeader, tc.header)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tc.wantedStatusCode, resp.StatusCode)
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			for _, s := range tc.wantedInMPD {
+				require.Contains(t, string(body), s)
+			}
+		})
+	}
+}
+
+func TestConfigSessions(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	resp, body := testFullRequest(t, ts, "POST", "/api/sessions",
+		strings.NewReader(`{"config": {"SegTimelineFlag": true, "StartTimeS": 10}}`))
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+	var info SessionInfo
+	require.NoError(t, json.Unmarshal(body, &info))
+	require.Equal(t, "/livesim2/session_"+info.ID, info.URLPrefix)
+
+	resp, body = testFullRequest(t, ts, "GET", info.URLPrefix+"/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), "<SegmentTimeline>")
+	require.Contains(t, string(body), `availabilityStartTime="1970-01-01T00:00:10Z"`)
+
+	resp, _ = testFullRequest(t, ts, "GET", "/api/sessions/"+info.ID, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, _ = testFullRequest(t, ts, "POST", "/api/sessions", strings.NewReader(`{"config": {"Unknown": 1}}`))
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, _ = testFullRequest(t, ts, "DELETE", "/api/sessions/"+info.ID, nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp, body = testFullRequest(t, ts, "GET", info.URLPrefix+"/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	require.Contains(t, string(body), "unknown or expired session")
+}
@@ -0,0 +1,159 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"time"
+)
+
+const (
+	// defaultShapingRTTMS is the emulated round-trip time if not configured
+	defaultShapingRTTMS = 100
+	// shapingIdleReset is the idle time after which the window restarts from the initial window,
+	// like TCP slow-start restart after the retransmission timeout.
+	shapingIdleReset = time.Second
+	// shapingMaxWindow is the largest window reached by slow start without MaxKbps
+	shapingMaxWindow = 1 << 30
+)
+
+// ShapingConfig emulates TCP characteristics on every connection of a listener.
+// The sent data is paced in rounds of one RTT. Zero values disable the corresponding shaping.
+type ShapingConfig struct {
+	// RTTMS is the emulated round-trip time in milliseconds (default 100)
+	RTTMS int `json:"rttMS,omitempty"`
+	// InitialWindowKB is the data sent in the first round. It is doubled every round, like TCP slow start,
+	// until MaxKbps is reached. 0 means no slow-start emulation.
+	InitialWindowKB int `json:"initialWindowKB,omitempty"`
+	// MaxKbps is the maximal throughput of each connection
+	MaxKbps int `json:"maxKbps,omitempty"`
+	// StallPercent is the probability that a round starts with a stall
+	StallPercent float64 `json:"stallPercent,omitempty"`
+	// StallMS is the duration of a stall
+	StallMS int `json:"stallMS,omitempty"`
+}
+
+func (sc *ShapingConfig) validate() error {
+	if sc.RTTMS < 0 || sc.InitialWindowKB < 0 || sc.MaxKbps < 0 || sc.StallMS < 0 {
+		return fmt.Errorf("shaping values must be >= 0")
+	}
+	if sc.StallPercent < 0 || sc.StallPercent > 100 {
+		return fmt.Errorf("shaping stallPercent %g not in range 0-100", sc.StallPercent)
+	}
+	if sc.StallPercent > 0 && sc.StallMS == 0 {
+		return fmt.Errorf("shaping stallPercent requires stallMS")
+	}
+	return nil
+}
+
+func (sc *ShapingConfig) rtt() time.Duration {
+	if sc.RTTMS == 0 {
+		return defaultShapingRTTMS * time.Millisecond
+	}
+	return time.Duration(sc.RTTMS) * time.Millisecond
+}
+
+// maxWindow returns the number of bytes per round at MaxKbps, or 0 if there is no limit.
+func (sc *ShapingConfig) maxWindow() int {
+	if sc.MaxKbps == 0 {
+		return 0
+	}
+	return max(int(int64(sc.MaxKbps)*1000/8*sc.rtt().Milliseconds()/1000), 1)
+}
+
+// initialWindow returns the number of bytes in the first round, or 0 if there is no limit.
+func (sc *ShapingConfig) initialWindow() int {
+	iw := sc.InitialWindowKB * 1024
+	if mw := sc.maxWindow(); iw == 0 || (mw > 0 && iw > mw) {
+		return mw
+	}
+	return iw
+}
+
+// nextWindow returns the window of the round after one with window w during slow start.
+func (sc *ShapingConfig) nextWindow(w int) int {
+	mw := sc.maxWindow()
+	if mw == 0 {
+		mw = shapingMaxWindow
+	}
+	return min(2*w, mw)
+}
+
+// shapingListener shapes all accepted connections.
+type shapingListener struct {
+	net.Listener
+	sc *ShapingConfig
+}
+
+func (sl *shapingListener) Accept() (net.Conn, error) {
+	c, err := sl.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return newShapedConn(c, sl.sc), nil
+}
+
+// shapedConn paces writes in rounds of one RTT. Reads are not affected.
+type shapedConn struct {
+	net.Conn
+	sc *ShapingConfig
+	// window is the number of bytes that may be sent per round, 0 means no limit
+	window     int
+	sentBytes  int
+	roundStart time.Time
+	lastWrite  time.Time
+}
+
+func newShapedConn(c net.Conn, sc *ShapingConfig) *shapedConn {
+	return &shapedConn{Conn: c, sc: sc, window: sc.initialWindow()}
+}
+
+// startRound starts a new round, possibly with a stall.
+func (c *shapedConn) startRound(now time.Time) {
+	if c.sc.StallPercent > 0 && rand.Float64()*100 < c.sc.StallPercent {
+		time.Sleep(time.Duration(c.sc.StallMS) * time.Millisecond)
+		now = time.Now()
+	}
+	c.roundStart = now
+	c.sentBytes = 0
+}
+
+func (c *shapedConn) Write(p []byte) (int, error) {
+	now := time.Now()
+	if c.roundStart.IsZero() || now.Sub(c.lastWrite) > shapingIdleReset {
+		c.window = c.sc.initialWindow()
+		c.startRound(now)
+	}
+	written := 0
+	for written < len(p) {
+		if c.window == 0 && time.Since(c.roundStart) >= c.sc.rtt() {
+			c.startRound(time.Now())
+		}
+		if c.window > 0 && c.sentBytes >= c.window {
+			if wait := c.sc.rtt() - time.Since(c.roundStart); wait > 0 {
+				time.Sleep(wait)
+			}
+			if c.sc.InitialWindowKB > 0 {
+				c.window = c.sc.nextWindow(c.window)
+			}
+			c.startRound(time.Now())
+		}
+		end := len(p)
+		if c.window > 0 {
+			end = min(len(p), written+c.window-c.sentBytes)
+		}
+		n, err := c.Conn.Write(p[written:end])
+		written += n
+		c.sentBytes += n
+		if err != nil {
+			c.lastWrite = time.Now()
+			return written, err
+		}
+	}
+	c.lastWrite = time.Now()
+	return written, nil
+}
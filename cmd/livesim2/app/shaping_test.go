@@ -0,0 +1,73 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShapingWindows(t *testing.T) {
+	sc := ShapingConfig{RTTMS: 20, InitialWindowKB: 1, MaxKbps: 800}
+	require.Equal(t, 2000, sc.maxWindow())
+	require.Equal(t, 1024, sc.initialWindow())
+	require.Equal(t, 2000, sc.nextWindow(1024))
+	sc = ShapingConfig{MaxKbps: 800}
+	require.Equal(t, 10000, sc.maxWindow())
+	require.Equal(t, 10000, sc.initialWindow())
+	sc = ShapingConfig{InitialWindowKB: 10}
+	require.Equal(t, 0, sc.maxWindow())
+	require.Equal(t, 20480, sc.nextWindow(10240))
+	require.Equal(t, shapingMaxWindow, sc.nextWindow(shapingMaxWindow))
+
+	require.NoError(t, (&ShapingConfig{StallPercent: 5, StallMS: 200}).validate())
+	require.Error(t, (&ShapingConfig{StallPercent: 5}).validate())
+	require.Error(t, (&ShapingConfig{StallPercent: 101, StallMS: 200}).validate())
+	require.Error(t, (&ShapingConfig{MaxKbps: -1}).validate())
+}
+
+// shapedWriteDuration returns the time to write size bytes to a shaped connection.
+func shapedWriteDuration(t *testing.T, sc *ShapingConfig, size int) time.Duration {
+	t.Helper()
+	client, server := net.Pipe()
+	defer client.Close()
+	done := make(chan int)
+	go func() {
+		n, _ := io.Copy(io.Discard, client)
+		done <- int(n)
+	}()
+	c := newShapedConn(server, sc)
+	start := time.Now()
+	n, err := c.Write(make([]byte, size))
+	require.NoError(t, err)
+	require.Equal(t, size, n)
+	dur := time.Since(start)
+	require.NoError(t, c.Close())
+	require.Equal(t, size, <-done)
+	return dur
+}
+
+func TestShapedConn(t *testing.T) {
+	// 10 rounds of 2000 bytes
+	dur := shapedWriteDuration(t, &ShapingConfig{RTTMS: 20, MaxKbps: 800}, 20_000)
+	require.GreaterOrEqual(t, dur, 180*time.Millisecond)
+
+	// Slow start with windows 1, 2, 4, 8, and 16 KiB
+	dur = shapedWriteDuration(t, &ShapingConfig{RTTMS: 20, InitialWindowKB: 1}, 31*1024)
+	require.GreaterOrEqual(t, dur, 80*time.Millisecond)
+	require.Less(t, dur, 100*time.Millisecond+80*time.Millisecond)
+
+	// A stall at the start of every round
+	dur = shapedWriteDuration(t, &ShapingConfig{RTTMS: 10, MaxKbps: 8000, StallPercent: 100, StallMS: 30}, 20_000)
+	require.GreaterOrEqual(t, dur, 2*30*time.Millisecond)
+
+	// No shaping
+	dur = shapedWriteDuration(t, &ShapingConfig{}, 1_000_000)
+	require.Less(t, dur, 100*time.Millisecond)
+}
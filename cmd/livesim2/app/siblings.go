@@ -0,0 +1,482 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+)
+
+// The same live stream is available under sibling URLs of the MPD <name>.mpd:
+//
+//	<name>.m3u8            HLS multivariant playlist
+//	<name>_<repID>.m3u8    HLS media playlist of a representation
+//	<name>.cmaf.json       CMAF listing with init and media segment URLs of all tracks
+//	<name>.isml/Manifest   Smooth Streaming client manifest (see smooth.go)
+//
+// All are derived from the live MPD, so they list the same segment URLs and the same media bytes.
+const (
+	hlsExt         = ".m3u8"
+	cmafListingExt = ".cmaf.json"
+	hlsVersion     = 7
+)
+
+// CMAFListing lists the tracks of a live MPD with their available segments.
+type CMAFListing struct {
+	MPD    string      `json:"mpd"`
+	NowMS  int         `json:"nowMS"`
+	Live   bool        `json:"live"`
+	Tracks []CMAFTrack `json:"tracks"`
+}
+
+// CMAFTrack is one representation with its available segments.
+type CMAFTrack struct {
+	RepID       string        `json:"repId"`
+	ContentType string        `json:"contentType"`
+	Codecs      string        `json:"codecs"`
+	Bandwidth   uint32        `json:"bandwidth"`
+	Width       uint32        `json:"width,omitempty"`
+	Height      uint32        `json:"height,omitempty"`
+	FrameRate   string        `json:"frameRate,omitempty"`
+	Lang        string        `json:"lang,omitempty"`
+	Timescale   uint32        `json:"timescale"`
+	Init        string        `json:"init"`
+	Segments    []CMAFSegment `json:"segments"`
+	baseURL     string        // BaseURL prepended to Init and segment URLs
+	asIdx       int           // Index of the AdaptationSet in its first Period
+}
+
+// CMAFSegment is a media segment. Time and Duration are in the track timescale.
+type CMAFSegment struct {
+	Number          uint64 `json:"number"`
+	Time            uint64 `json:"time"`
+	Duration        uint64 `json:"duration"`
+	ProgramDateTime string `json:"programDateTime"`
+	URL             string `json:"url"`
+	// Discontinuity is set for the first segment of a new Period.
+	Discontinuity bool    `json:"discontinuity,omitempty"`
+	wallS         float64 // Start time in seconds since epoch
+}
+
+// cmafListingFromMPD lists the segments of all tracks in the live MPD that are available at nowMS.
+// Thumbnail tracks are skipped.
+func cmafListingFromMPD(mpd *m.MPD, mpdName string, nowMS int) (*CMAFListing, error) {
+	ast, err := mpd.AvailabilityStartTime.ConvertToSeconds()
+	if err != nil {
+		return nil, fmt.Errorf("availabilityStartTime: %w", err)
+	}
+	live := mpd.GetType() == "dynamic"
+	nowRelS := float64(nowMS)/1000 - ast
+	windowStartS := 0.0
+	availEndS, endS := math.Inf(1), math.Inf(1)
+	if live {
+		availEndS = nowRelS
+		if mpd.TimeShiftBufferDepth != nil {
+			windowStartS = nowRelS - mpd.TimeShiftBufferDepth.Seconds()
+		}
+	} else if mpd.MediaPresentationDuration != nil {
+		endS = mpd.MediaPresentationDuration.Seconds()
+	}
+	cl := CMAFListing{MPD: mpdName, NowMS: nowMS, Live: live}
+	trackIdx := make(map[string]int)
+	for pIdx, p := range mpd.Periods {
+		pStartS := 0.0
+		if p.Start != nil {
+			pStartS = p.Start.Seconds()
+		}
+		pEndS := endS
+		switch {
+		case pIdx+1 < len(mpd.Periods) && mpd.Periods[pIdx+1].Start != nil:
+			pEndS = min(pEndS, mpd.Periods[pIdx+1].Start.Seconds())
+		case p.Duration != nil:
+			pEndS = min(pEndS, pStartS+p.Duration.Seconds())
+		}
+		baseURL := ""
+		if len(p.BaseURLs) > 0 {
+			baseURL = string(p.BaseURLs[0].Value)
+		}
+		for asIdx, as := range p.AdaptationSets {
+			if as.ContentType == "image" {
+				continue
+			}
+			for _, rep := range as.Representations {
+				st := rep.SegmentTemplate
+				if st == nil {
+					st = as.SegmentTemplate
+				}
+				if st == nil {
+					return nil, fmt.Errorf("representation %s has no SegmentTemplate", rep.Id)
+				}
+				idx, ok := trackIdx[rep.Id]
+				if !ok {
+					idx = len(cl.Tracks)
+					trackIdx[rep.Id] = idx
+					tr := newCMAFTrack(as, rep, st, baseURL)
+					tr.asIdx = asIdx
+					cl.Tracks = append(cl.Tracks, tr)
+				}
+				tr := &cl.Tracks[idx]
+				segs := listSegments(st, rep, baseURL, ast, segWindow{pStartS, pEndS, windowStartS, availEndS})
+				if len(segs) > 0 && pIdx > 0 && len(tr.Segments) > 0 {
+					segs[0].Discontinuity = true
+				}
+				tr.Segments = append(tr.Segments, segs...)
+			}
+		}
+	}
+	return &cl, nil
+}
+
+func newCMAFTrack(as *m.AdaptationSetType, rep *m.RepresentationType, st *m.SegmentTemplateType, baseURL string) CMAFTrack {
+	tr := CMAFTrack{
+		RepID:       rep.Id,
+		ContentType: string(as.ContentType),
+		Codecs:      rep.Codecs,
+		Bandwidth:   rep.Bandwidth,
+		Width:       rep.Width,
+		Height:      rep.Height,
+		FrameRate:   string(rep.FrameRate),
+		Lang:        as.Lang,
+		Timescale:   st.GetTimescale(),
+		Init:        baseURL + expandTemplate(st.Initialization, rep, 0, 0),
+		baseURL:     baseURL,
+	}
+	if tr.Codecs == "" {
+		tr.Codecs = as.Codecs
+	}
+	if tr.Width == 0 {
+		tr.Width, tr.Height = as.Width, as.Height
+	}
+	if tr.FrameRate == "" {
+		tr.FrameRate = string(as.FrameRate)
+	}
+	return tr
+}
+
+// segWindow has times in seconds relative to availabilityStartTime.
+type segWindow struct {
+	pStartS, pEndS float64 // Period start and end
+	startS         float64 // Start of time-shift buffer
+	availEndS      float64 // Segments must end before this time to be available
+}
+
+// listSegments lists the segments of a Period that are in the window.
+// Segments in a SegmentTimeline are all listed, since the live MPD only has available segments.
+// Without startNumber, timeline segments are numbered by time divided by the mean segment duration,
+// so that numbers (and HLS media sequence numbers) stay stable as the window moves.
+func listSegments(st *m.SegmentTemplateType, rep *m.RepresentationType, baseURL string, ast float64,
+	sw segWindow) []CMAFSegment {
+	timescale := uint64(st.GetTimescale())
+	var pto uint64
+	if st.PresentationTimeOffset != nil {
+		pto = *st.PresentationTimeOffset
+	}
+	startNr := uint64(1)
+	if st.StartNumber != nil {
+		startNr = uint64(*st.StartNumber)
+	}
+	var segs []CMAFSegment
+	add := func(nr, t, d uint64) {
+		wallS := ast + sw.pStartS + float64(t-pto)/float64(timescale)
+		segs = append(segs, CMAFSegment{
+			Number:          nr,
+			Time:            t,
+			Duration:        d,
+			ProgramDateTime: string(m.ConvertToDateTime(wallS)),
+			wallS:           wallS,
+			URL:             baseURL + expandTemplate(st.Media, rep, nr, t),
+		})
+	}
+	if st.SegmentTimeline != nil {
+		var meanDur float64
+		if st.StartNumber == nil {
+			var totDur, nrSegs uint64
+			for _, s := range st.SegmentTimeline.S {
+				totDur += s.D * uint64(s.R+1)
+				nrSegs += uint64(s.R + 1)
+			}
+			if nrSegs > 0 {
+				meanDur = float64(totDur) / float64(nrSegs)
+			}
+		}
+		t, nr := uint64(0), startNr
+		for _, s := range st.SegmentTimeline.S {
+			if s.T != nil {
+				t = *s.T
+			}
+			for i := 0; i <= s.R; i++ {
+				if meanDur > 0 {
+					nr = uint64(math.Round((float64(t-pto) + sw.pStartS*float64(timescale)) / meanDur))
+				}
+				add(nr, t, s.D)
+				t += s.D
+				nr++
+			}
+		}
+		return segs
+	}
+	if st.Duration == nil || *st.Duration == 0 || math.IsInf(sw.pEndS, 1) && math.IsInf(sw.availEndS, 1) {
+		return nil
+	}
+	d := uint64(*st.Duration)
+	durS := float64(d) / float64(timescale)
+	k := uint64(0)
+	if sw.startS > sw.pStartS {
+		k = uint64((sw.startS - sw.pStartS) / durS)
+	}
+	for ; ; k++ {
+		segStartS := sw.pStartS + float64(k)*durS
+		segEndS := segStartS + durS
+		if segStartS >= sw.pEndS || segEndS > sw.availEndS {
+			break
+		}
+		if segEndS <= sw.startS {
+			continue
+		}
+		add(startNr+k, pto+k*d, d)
+	}
+	return segs
+}
+
+var templateIdentifier = regexp.MustCompile(`\$(RepresentationID|Number|Time|Bandwidth)?(%0(\d+)d)?\$`)
+
+// expandTemplate replaces the identifiers of a SegmentTemplate media or initialization attribute.
+func expandTemplate(tmpl string, rep *m.RepresentationType, nr, t uint64) string {
+	return templateIdentifier.ReplaceAllStringFunc(tmpl, func(id string) string {
+		parts := templateIdentifier.FindStringSubmatch(id)
+		var val uint64
+		switch parts[1] {
+		case "":
+			return "$" // $$ is an escaped $
+		case "RepresentationID":
+			return rep.Id
+		case "Number":
+			val = nr
+		case "Time":
+			val = t
+		case "Bandwidth":
+			val = uint64(rep.Bandwidth)
+		}
+		s := strconv.FormatUint(val, 10)
+		if parts[3] != "" {
+			width, _ := strconv.Atoi(parts[3])
+			s = fmt.Sprintf("%0*d", width, val)
+		}
+		return s
+	})
+}
+
+// isManifest returns true for MPD and sibling manifest paths.
+func isManifest(uPath string) bool {
+	return strings.HasSuffix(uPath, ".mpd") || strings.HasSuffix(uPath, hlsExt) ||
+		strings.HasSuffix(uPath, cmafListingExt) || strings.HasSuffix(uPath, smoothManifestSuffix)
+}
+
+// writeSibling writes an HLS playlist or CMAF listing derived from the live MPD.
+func (s *Server) writeSibling(w http.ResponseWriter, r *http.Request, cfg *ResponseConfig, a *asset,
+	fileName string, nowMS int) {
+	var mpdName, repID string
+	isHLS := false
+	switch {
+	case strings.HasSuffix(fileName, cmafListingExt):
+		mpdName = strings.TrimSuffix(fileName, cmafListingExt) + ".mpd"
+	case strings.HasSuffix(fileName, hlsExt):
+		isHLS = true
+		mpdName, repID = hlsMPDName(a, strings.TrimSuffix(fileName, hlsExt))
+	}
+	if _, ok := a.MPDs[mpdName]; !ok {
+		writeProblem(w, r, http.StatusNotFound, reasonNotFound, fmt.Sprintf("no MPD for %q", fileName))
+		return
+	}
+	if cfg.MPDStall != nil {
+		nowMS = cfg.MPDStall.mpdNowMS(nowMS)
+	}
+	nowMS = cfg.burstNowMS(nowMS)
+	lMPD, err := LiveMPD(a, mpdName, cfg, s.Cfg.DrmCfg, nowMS)
+	if err == nil {
+		removeMetricsReporting(lMPD)
+		err = s.hooks.rewriteMPD(r, lMPD)
+	}
+	if err != nil {
+		writeProblem(w, r, mpdErrorStatus(err), reasonFromError(err, reasonInternal), err.Error())
+		return
+	}
+	cl, err := cmafListingFromMPD(lMPD, mpdName, nowMS)
+	if err != nil {
+		writeProblem(w, r, http.StatusInternalServerError, reasonInternal, err.Error())
+		return
+	}
+	if !isHLS {
+		body, err := json.MarshalIndent(cl, "", "  ")
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, reasonInternal, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		_, _ = w.Write(body)
+		return
+	}
+	var playlist string
+	if repID == "" {
+		playlist = hlsMultivariantPlaylist(cl, strings.TrimSuffix(mpdName, ".mpd"))
+	} else {
+		var tr *CMAFTrack
+		for i := range cl.Tracks {
+			if cl.Tracks[i].RepID == repID {
+				tr = &cl.Tracks[i]
+			}
+		}
+		if tr == nil {
+			writeProblem(w, r, http.StatusNotFound, reasonNotFound, fmt.Sprintf("unknown representation %q", repID))
+			return
+		}
+		playlist = hlsMediaPlaylist(tr, cl.Live)
+	}
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Header().Set("Content-Length", strconv.Itoa(len(playlist)))
+	_, _ = w.Write([]byte(playlist))
+}
+
+// hlsMPDName returns the MPD name and representation ID (empty for multivariant playlists) of an HLS playlist name.
+// Media playlists are named <mpdName>_<repID>, where both may contain underscores.
+func hlsMPDName(a *asset, name string) (mpdName, repID string) {
+	if _, ok := a.MPDs[name+".mpd"]; ok {
+		return name + ".mpd", ""
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] != '_' {
+			continue
+		}
+		if _, ok := a.MPDs[name[:i]+".mpd"]; ok {
+			return name[:i] + ".mpd", name[i+1:]
+		}
+	}
+	return "", ""
+}
+
+// hlsMultivariantPlaylist returns a playlist with a variant per video track, and audio and subtitle renditions.
+// Without video, every audio track is a variant.
+func hlsMultivariantPlaylist(cl *CMAFListing, mpdBase string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "#EXTM3U\n#EXT-X-VERSION:%d\n#EXT-X-INDEPENDENT-SEGMENTS\n", hlsVersion)
+	var videos, audios, subs []*CMAFTrack
+	for i := range cl.Tracks {
+		tr := &cl.Tracks[i]
+		switch tr.ContentType {
+		case "video":
+			videos = append(videos, tr)
+		case "audio":
+			audios = append(audios, tr)
+		case "text":
+			subs = append(subs, tr)
+		}
+	}
+	uri := func(tr *CMAFTrack) string { return mpdBase + "_" + tr.RepID + hlsExt }
+	if len(videos) == 0 {
+		for _, tr := range audios {
+			fmt.Fprintf(&sb, "#EXT-X-STREAM-INF:BANDWIDTH=%d,CODECS=\"%s\"\n%s\n", tr.Bandwidth, tr.Codecs, uri(tr))
+		}
+		return sb.String()
+	}
+	writeRenditions := func(typ, group string, tracks []*CMAFTrack) {
+		for i, tr := range tracks {
+			fmt.Fprintf(&sb, "#EXT-X-MEDIA:TYPE=%s,GROUP-ID=\"%s\",NAME=\"%s\"", typ, group, tr.RepID)
+			if tr.Lang != "" {
+				fmt.Fprintf(&sb, ",LANGUAGE=\"%s\"", tr.Lang)
+			}
+			def := "NO"
+			if i == 0 {
+				def = "YES"
+			}
+			fmt.Fprintf(&sb, ",DEFAULT=%s,AUTOSELECT=YES,URI=\"%s\"\n", def, uri(tr))
+		}
+	}
+	writeRenditions("AUDIO", "audio", audios)
+	writeRenditions("SUBTITLES", "subs", subs)
+	var maxAudioBW uint32
+	for _, tr := range audios {
+		maxAudioBW = max(maxAudioBW, tr.Bandwidth)
+	}
+	for _, tr := range videos {
+		codecs := tr.Codecs
+		if len(audios) > 0 {
+			codecs += "," + audios[0].Codecs
+		}
+		fmt.Fprintf(&sb, "#EXT-X-STREAM-INF:BANDWIDTH=%d,CODECS=\"%s\"", tr.Bandwidth+maxAudioBW, codecs)
+		if tr.Width > 0 && tr.Height > 0 {
+			fmt.Fprintf(&sb, ",RESOLUTION=%dx%d", tr.Width, tr.Height)
+		}
+		if fr := hlsFrameRate(tr.FrameRate); fr != "" {
+			sb.WriteString(",FRAME-RATE=" + fr)
+		}
+		if len(audios) > 0 {
+			sb.WriteString(`,AUDIO="audio"`)
+		}
+		if len(subs) > 0 {
+			sb.WriteString(`,SUBTITLES="subs"`)
+		}
+		fmt.Fprintf(&sb, "\n%s\n", uri(tr))
+	}
+	return sb.String()
+}
+
+// hlsFrameRate converts an MPD frameRate like 25 or 30000/1001 to a decimal number.
+func hlsFrameRate(frameRate string) string {
+	if frameRate == "" {
+		return ""
+	}
+	num, den, ok := strings.Cut(frameRate, "/")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return ""
+	}
+	if ok {
+		d, err := strconv.ParseFloat(den, 64)
+		if err != nil || d == 0 {
+			return ""
+		}
+		n /= d
+	}
+	return strconv.FormatFloat(n, 'f', 3, 64)
+}
+
+// hlsMediaPlaylist returns a media playlist with the segments of the track.
+// A new Period gives a discontinuity. A finished (static) stream ends with EXT-X-ENDLIST.
+// The target duration is the maximum segment duration rounded to the nearest integer as required by HLS.
+func hlsMediaPlaylist(tr *CMAFTrack, live bool) string {
+	var sb strings.Builder
+	targetDur := 1
+	for _, seg := range tr.Segments {
+		targetDur = max(targetDur, int(math.Round(float64(seg.Duration)/float64(tr.Timescale))))
+	}
+	var mediaSeq uint64
+	if len(tr.Segments) > 0 {
+		mediaSeq = tr.Segments[0].Number
+	}
+	fmt.Fprintf(&sb, "#EXTM3U\n#EXT-X-VERSION:%d\n#EXT-X-TARGETDURATION:%d\n#EXT-X-MEDIA-SEQUENCE:%d\n",
+		hlsVersion, targetDur, mediaSeq)
+	fmt.Fprintf(&sb, "#EXT-X-MAP:URI=\"%s\"\n", tr.Init)
+	for i, seg := range tr.Segments {
+		if seg.Discontinuity {
+			fmt.Fprintf(&sb, "#EXT-X-DISCONTINUITY\n#EXT-X-MAP:URI=\"%s\"\n", tr.Init)
+		}
+		if i == 0 || seg.Discontinuity {
+			fmt.Fprintf(&sb, "#EXT-X-PROGRAM-DATE-TIME:%s\n", seg.ProgramDateTime)
+		}
+		fmt.Fprintf(&sb, "#EXTINF:%.3f,\n%s\n", float64(seg.Duration)/float64(tr.Timescale), seg.URL)
+	}
+	if !live {
+		sb.WriteString("#EXT-X-ENDLIST\n")
+	}
+	return sb.String()
+}
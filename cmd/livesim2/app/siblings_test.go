@@ -0,0 +1,72 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSiblingEndpoints(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	resp, body := testFullRequest(t, ts, "GET", "/livesim2/testpic_2s/Manifest.m3u8?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/vnd.apple.mpegurl", resp.Header.Get("Content-Type"))
+	mvp := string(body)
+	require.True(t, strings.HasPrefix(mvp, "#EXTM3U\n"))
+	require.Contains(t, mvp, `#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio",NAME="A48",LANGUAGE="en",DEFAULT=YES,AUTOSELECT=YES,URI="Manifest_A48.m3u8"`)
+	require.Contains(t, mvp, "RESOLUTION=640x360,FRAME-RATE=30.000,AUDIO=\"audio\"\nManifest_V300.m3u8\n")
+
+	resp, body = testFullRequest(t, ts, "GET", "/livesim2/testpic_2s/Manifest_V300.m3u8?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	mp := string(body)
+	require.Contains(t, mp, "#EXT-X-TARGETDURATION:2\n#EXT-X-MEDIA-SEQUENCE:20\n")
+	require.Contains(t, mp, `#EXT-X-MAP:URI="V300/init.mp4"`)
+	require.True(t, strings.HasSuffix(mp, "#EXTINF:2.000,\nV300/49.m4s\n"))
+	require.NotContains(t, mp, "#EXT-X-ENDLIST")
+
+	// The listed segments are served by the live handler
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/testpic_2s/V300/49.m4s?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, body = testFullRequest(t, ts, "GET", "/livesim2/segtimeline_1/testpic_2s/Manifest.cmaf.json?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	var cl CMAFListing
+	require.NoError(t, json.Unmarshal(body, &cl))
+	require.True(t, cl.Live)
+	require.Len(t, cl.Tracks, 2)
+	// Without startNumber, timeline segments are numbered by time, so audio and video numbers agree
+	for _, tr := range cl.Tracks {
+		require.Greater(t, len(tr.Segments), 0)
+		require.Equal(t, uint64(19), tr.Segments[0].Number, tr.RepID)
+		if tr.ContentType == "video" {
+			last := tr.Segments[len(tr.Segments)-1]
+			require.Equal(t, uint64(49), last.Number)
+			require.Equal(t, "V300/8820000.m4s", last.URL)
+			require.Equal(t, "1970-01-01T00:01:38Z", last.ProgramDateTime)
+		}
+	}
+
+	resp, body = testFullRequest(t, ts, "GET", "/livesim2/segtimeline_1/testpic_2s/Manifest_A48.m3u8?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), "#EXT-X-TARGETDURATION:2\n#EXT-X-MEDIA-SEQUENCE:19\n")
+
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/testpic_2s/Manifest_X.m3u8", nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/testpic_2s/Other.m3u8", nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestExpandTemplate(t *testing.T) {
+	tmpl := "$RepresentationID$/$Number%05d$-$Time$-$Bandwidth$$$.m4s"
+	require.Equal(t, "V300/00042-84000-300000$.m4s", expandTemplate(tmpl, &m.RepresentationType{Id: "V300", Bandwidth: 300000}, 42, 84000))
+}
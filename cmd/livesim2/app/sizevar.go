@@ -0,0 +1,169 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strconv"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/Eyevinn/mp4ff/mp4"
+)
+
+// maxSizeVariancePct is the maximal mean filler in percent of the media data
+const maxSizeVariancePct = 100
+
+// SizeVariance amplifies the size variance of media segments by appending a varying amount of
+// filler bytes to the mdat payload. The filler is between 0 and 2*Pct percent of the media data,
+// chosen deterministically per Representation and segment number, so the mean is Pct percent.
+// The filler is not referenced by any sample and is ignored by decoders.
+// With Trim, the size change is instead between -Pct and Pct percent. Segments are made smaller by
+// dropping samples from the end of the last fragment, and the duration of the dropped samples is
+// added to the last remaining sample, so the segment duration is unchanged.
+type SizeVariance struct {
+	Pct  int  `json:"Pct"`
+	Trim bool `json:"Trim,omitempty"`
+	// RepIDs limits the variance to these Representations. Empty means all.
+	RepIDs []string `json:"RepIDs,omitempty"`
+}
+
+// validate checks that Pct is in range and that the RepIDs are not empty.
+func (sv *SizeVariance) validate() error {
+	if sv.Pct <= 0 || sv.Pct > maxSizeVariancePct {
+		return fmt.Errorf("sizevar pct=%d must be in range 1-%d", sv.Pct, maxSizeVariancePct)
+	}
+	if slices.Contains(sv.RepIDs, "") {
+		return fmt.Errorf("sizevar has empty repID")
+	}
+	return nil
+}
+
+// appliesTo returns true if the segments of Representation repID are padded.
+func (sv *SizeVariance) appliesTo(repID string) bool {
+	return len(sv.RepIDs) == 0 || slices.Contains(sv.RepIDs, repID)
+}
+
+// sizeChange returns the number of bytes added (positive) or removed (negative) for
+// segment nr of repID with dataSize bytes of media data.
+func (sv *SizeVariance) sizeChange(repID string, nr uint32, dataSize uint64) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(repID + "/" + strconv.FormatUint(uint64(nr), 10)))
+	permille := int64(h.Sum64() % 2001) // 0 to 2000, with 1000 as the mean
+	if sv.Trim {
+		permille -= 1000
+	}
+	return int64(dataSize) * int64(sv.Pct) * permille / 100_000
+}
+
+// fillerSize returns the number of filler bytes for segment nr of repID with dataSize bytes of media data.
+func (sv *SizeVariance) fillerSize(repID string, nr uint32, dataSize uint64) uint64 {
+	return uint64(max(sv.sizeChange(repID, nr, dataSize), 0))
+}
+
+// trimSize returns the number of bytes to remove from segment nr of repID with dataSize bytes of media data.
+func (sv *SizeVariance) trimSize(repID string, nr uint32, dataSize uint64) uint64 {
+	return uint64(max(-sv.sizeChange(repID, nr, dataSize), 0))
+}
+
+// trimSegment drops samples from the end of the last fragment of a segment of repID with number nr,
+// until at least trimSize bytes are removed. The first sample is always kept.
+// The duration of the dropped samples is added to the last kept sample.
+// Encrypted fragments and fragments with multiple tracks or truns are left unchanged.
+func trimSegment(sv *SizeVariance, repID string, nr uint32, seg *mp4.MediaSegment, trex *mp4.TrexBox) error {
+	if !sv.Trim || len(seg.Fragments) == 0 || !sv.appliesTo(repID) {
+		return nil
+	}
+	var dataSize uint64
+	for _, frag := range seg.Fragments {
+		dataSize += frag.Mdat.DataLength()
+	}
+	trim := sv.trimSize(repID, nr, dataSize)
+	if trim == 0 {
+		return nil
+	}
+	frag := seg.Fragments[len(seg.Fragments)-1]
+	traf := frag.Moof.Traf
+	if len(frag.Moof.Trafs) != 1 || len(traf.Truns) != 1 || traf.Senc != nil {
+		return nil
+	}
+	samples, err := frag.GetFullSamples(trex)
+	if err != nil {
+		return err
+	}
+	keep := len(samples)
+	var removed uint64
+	var droppedDur uint32
+	for keep > 1 && removed < trim {
+		keep--
+		removed += uint64(samples[keep].Size)
+		droppedDur += samples[keep].Dur
+	}
+	if keep == len(samples) {
+		return nil
+	}
+	oldFragSize := frag.Size()
+	trun := traf.Trun
+	trun.AddSampleDefaultValues(traf.Tfhd, trex)
+	trun.Samples = trun.Samples[:keep]
+	trun.Samples[keep-1].Dur += droppedDur
+	trun.Flags |= mp4.TrunSampleDurationPresentFlag | mp4.TrunSampleSizePresentFlag
+	mdatData := make([]byte, 0, dataSize)
+	for _, s := range samples[:keep] {
+		mdatData = append(mdatData, s.Data...)
+	}
+	frag.Mdat.SetData(mdatData)
+	// Keep the positions consistent with the smaller moof, since samples may be read again for chunking
+	frag.Mdat.StartPos = frag.Moof.StartPos + frag.Moof.Size()
+	trun.DataOffset = int32(frag.Moof.Size() + frag.Mdat.HeaderSize())
+	if seg.Sidx != nil && len(seg.Sidx.SidxRefs) > 0 {
+		seg.Sidx.SidxRefs[len(seg.Sidx.SidxRefs)-1].ReferencedSize -= uint32(oldFragSize - frag.Size())
+	}
+	return nil
+}
+
+// padSegment appends filler to the mdat of the last fragment of a segment of repID with number nr.
+// The last sidx reference, if any, is updated to the new size.
+func padSegment(sv *SizeVariance, repID string, nr uint32, frags []*mp4.Fragment, sidx *mp4.SidxBox) {
+	if len(frags) == 0 || !sv.appliesTo(repID) {
+		return
+	}
+	var dataSize uint64
+	for _, frag := range frags {
+		dataSize += frag.Mdat.DataLength()
+	}
+	filler := sv.fillerSize(repID, nr, dataSize)
+	if filler == 0 {
+		return
+	}
+	mdat := frags[len(frags)-1].Mdat
+	if len(mdat.DataParts) > 0 {
+		mdat.AddSampleDataPart(make([]byte, filler))
+	} else {
+		mdat.AddSampleData(make([]byte, filler))
+	}
+	if sidx != nil && len(sidx.SidxRefs) > 0 {
+		sidx.SidxRefs[len(sidx.SidxRefs)-1].ReferencedSize += uint32(filler)
+	}
+}
+
+// applySizeVarianceBandwidth scales the @bandwidth of padded Representations by the mean size increase.
+// With Trim, the mean size is unchanged.
+func applySizeVarianceBandwidth(mpd *m.MPD, sv *SizeVariance) {
+	if sv.Trim {
+		return
+	}
+	for _, p := range mpd.Periods {
+		for _, as := range p.AdaptationSets {
+			for _, rep := range as.Representations {
+				if !sv.appliesTo(rep.Id) {
+					continue
+				}
+				rep.Bandwidth = uint32(uint64(rep.Bandwidth) * uint64(100+sv.Pct) / 100)
+			}
+		}
+	}
+}
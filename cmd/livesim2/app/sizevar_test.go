@@ -0,0 +1,151 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/Eyevinn/mp4ff/mp4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFillerSize(t *testing.T) {
+	sv := SizeVariance{Pct: 50}
+	var total uint64
+	sizes := make(map[uint64]bool)
+	for nr := uint32(0); nr < 200; nr++ {
+		f := sv.fillerSize("V300", nr, 10000)
+		require.LessOrEqual(t, f, uint64(10000))
+		require.Equal(t, f, sv.fillerSize("V300", nr, 10000), "deterministic")
+		sizes[f] = true
+		total += f
+	}
+	require.Greater(t, len(sizes), 100)
+	require.InDelta(t, 5000, float64(total)/200, 500)
+}
+
+func TestSizeVariance(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	resp, body := testFullRequest(t, ts, "GET", "/livesim2/sizevar_40_V300/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	mpd, err := m.ReadFromString(string(body))
+	require.NoError(t, err)
+	bw := make(map[string]uint32)
+	for _, as := range mpd.Periods[0].AdaptationSets {
+		for _, rep := range as.Representations {
+			bw[rep.Id] = rep.Bandwidth
+		}
+	}
+	require.Equal(t, uint32(420000), bw["V300"])
+	require.Equal(t, uint32(48000), bw["A48"])
+
+	getSeg := func(url string) ([]byte, *mp4.MediaSegment) {
+		resp, body := testFullRequest(t, ts, "GET", url, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode, url)
+		f, err := mp4.DecodeFile(bytes.NewBuffer(body))
+		require.NoError(t, err)
+		require.Len(t, f.Segments, 1)
+		return body, f.Segments[0]
+	}
+
+	sv := SizeVariance{Pct: 40}
+	sizes := make(map[int]bool)
+	for nr := 45; nr < 50; nr++ {
+		orig, origSeg := getSeg(fmt.Sprintf("/livesim2/testpic_2s/V300/%d.m4s?nowMS=100000", nr))
+		padded, paddedSeg := getSeg(fmt.Sprintf("/livesim2/sizevar_40_V300/testpic_2s/V300/%d.m4s?nowMS=100000", nr))
+		filler := sv.fillerSize("V300", uint32(nr), origSeg.Fragments[0].Mdat.DataLength())
+		require.Equal(t, len(orig)+int(filler), len(padded))
+		require.Equal(t, origSeg.Fragments[0].Moof.Traf.Trun.DataOffset, paddedSeg.Fragments[0].Moof.Traf.Trun.DataOffset)
+		origData := origSeg.Fragments[0].Mdat.Data
+		require.Equal(t, origData, paddedSeg.Fragments[0].Mdat.Data[:len(origData)])
+		sizes[len(padded)-len(orig)] = true
+
+		// Other representations are not padded
+		orig, _ = getSeg(fmt.Sprintf("/livesim2/testpic_2s/A48/%d.m4s?nowMS=100000", nr))
+		padded, _ = getSeg(fmt.Sprintf("/livesim2/sizevar_40_V300/testpic_2s/A48/%d.m4s?nowMS=100000", nr))
+		require.Equal(t, orig, padded)
+	}
+	require.Greater(t, len(sizes), 1)
+
+	// Chunked segments get the filler in the last chunk
+	orig, origSeg := getSeg("/livesim2/chunkdur_0.5/testpic_2s/V300/45.m4s?nowMS=100000")
+	padded, _ := getSeg("/livesim2/chunkdur_0.5/sizevar_40/testpic_2s/V300/45.m4s?nowMS=100000")
+	var dataSize uint64
+	for _, frag := range origSeg.Fragments {
+		dataSize += frag.Mdat.DataLength()
+	}
+	require.Equal(t, len(orig)+int(sv.fillerSize("V300", 45, dataSize)), len(padded))
+
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/sizevar_0/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestSizeVarianceTrim(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	sc := newStringConverter()
+	require.Equal(t, &SizeVariance{Pct: 40, Trim: true}, sc.ParseSizeVariance("sizevar", "40_trim"))
+	require.Equal(t, &SizeVariance{Pct: 40, Trim: true, RepIDs: []string{"V300"}}, sc.ParseSizeVariance("sizevar", "40_trim_V300"))
+	require.NoError(t, sc.err)
+
+	resp, body := testFullRequest(t, ts, "GET", "/livesim2/sizevar_40_trim/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), `bandwidth="300000"`)
+
+	getSeg := func(url string) ([]byte, *mp4.MediaSegment) {
+		resp, body := testFullRequest(t, ts, "GET", url, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode, url)
+		f, err := mp4.DecodeFile(bytes.NewBuffer(body))
+		require.NoError(t, err)
+		require.Len(t, f.Segments, 1)
+		return body, f.Segments[0]
+	}
+	// segDur returns the duration and number of samples of a single-fragment segment
+	segDur := func(seg *mp4.MediaSegment) (uint64, int) {
+		trun := seg.Fragments[0].Moof.Traf.Trun
+		trun.AddSampleDefaultValues(seg.Fragments[0].Moof.Traf.Tfhd, nil)
+		return trun.Duration(0), len(trun.Samples)
+	}
+
+	sv := SizeVariance{Pct: 40, Trim: true}
+	nrTrimmed, nrPadded := 0, 0
+	for nr := 40; nr < 50; nr++ {
+		orig, origSeg := getSeg(fmt.Sprintf("/livesim2/testpic_2s/V300/%d.m4s?nowMS=100000", nr))
+		varied, variedSeg := getSeg(fmt.Sprintf("/livesim2/sizevar_40_trim/testpic_2s/V300/%d.m4s?nowMS=100000", nr))
+		dataSize := origSeg.Fragments[0].Mdat.DataLength()
+		origDur, origNrSamples := segDur(origSeg)
+		variedDur, variedNrSamples := segDur(variedSeg)
+		require.Equal(t, origDur, variedDur, "segment duration must be unchanged")
+		require.Equal(t, origSeg.Fragments[0].Moof.Traf.Tfdt.BaseMediaDecodeTime(),
+			variedSeg.Fragments[0].Moof.Traf.Tfdt.BaseMediaDecodeTime())
+		if trim := sv.trimSize("V300", uint32(nr), dataSize); trim > 0 {
+			nrTrimmed++
+			require.Less(t, variedNrSamples, origNrSamples)
+			require.GreaterOrEqual(t, int(dataSize-variedSeg.Fragments[0].Mdat.DataLength()), int(trim))
+			samples, err := variedSeg.Fragments[0].GetFullSamples(nil)
+			require.NoError(t, err)
+			require.Equal(t, origSeg.Fragments[0].Mdat.Data[:len(samples[0].Data)], samples[0].Data)
+			continue
+		}
+		nrPadded++
+		require.Equal(t, len(orig)+int(sv.fillerSize("V300", uint32(nr), dataSize)), len(varied))
+	}
+	require.Greater(t, nrTrimmed, 0)
+	require.Greater(t, nrPadded, 0)
+
+	// Chunked segments are trimmed before chunking
+	for nr := 40; nr < 50; nr++ {
+		_, origSeg := getSeg(fmt.Sprintf("/livesim2/chunkdur_0.5/testpic_2s/V300/%d.m4s?nowMS=100000", nr))
+		_, variedSeg := getSeg(fmt.Sprintf("/livesim2/chunkdur_0.5/sizevar_40_trim/testpic_2s/V300/%d.m4s?nowMS=100000", nr))
+		lastOrig := origSeg.Fragments[len(origSeg.Fragments)-1].Moof.Traf.Tfdt.BaseMediaDecodeTime()
+		lastVaried := variedSeg.Fragments[len(variedSeg.Fragments)-1].Moof.Traf.Tfdt.BaseMediaDecodeTime()
+		require.LessOrEqual(t, lastOrig, lastVaried)
+	}
+}
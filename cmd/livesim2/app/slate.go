@@ -0,0 +1,76 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+
+	"github.com/Eyevinn/mp4ff/mp4"
+)
+
+// slateSchemeIdUri is used for inband events signaling a switch to slate.
+const slateSchemeIdUri = "urn:livesim2:slate:2024"
+
+// Slate configures cyclic encoder failover, where the media content is replaced by a slate
+// for the first DurS seconds of every CycleS seconds. The slate is the first segment of the asset
+// with the right duration, repeated. The MPD is not changed, except for an InbandEventStream if Signal is set.
+type Slate struct {
+	CycleS int  `json:"CycleS"`
+	DurS   int  `json:"DurS"`
+	Signal bool `json:"Signal,omitempty"`
+}
+
+// validate checks that 0 < DurS < CycleS.
+func (sl *Slate) validate() error {
+	if sl.DurS <= 0 || sl.DurS >= sl.CycleS {
+		return fmt.Errorf("slate duration %ds must be > 0 and less than cycle %ds", sl.DurS, sl.CycleS)
+	}
+	return nil
+}
+
+// active returns true if the segment starting at mediaTime is in a slate interval.
+// startTimeS is the offset of media time to wall-clock time.
+func (sl *Slate) active(mediaTime uint64, timescale uint32, startTimeS int) bool {
+	tMS := int(mediaTime*1000/uint64(timescale)) + startTimeS*1000
+	return tMS%(sl.CycleS*1000) < sl.DurS*1000
+}
+
+// useSlateSegment changes meta to use the first VoD segment with the same duration as input.
+func useSlateSegment(meta *segMeta) {
+	for _, seg := range meta.rep.Segments {
+		if uint32(seg.EndTime-seg.StartTime) == meta.origDur {
+			meta.origTime = seg.StartTime
+			meta.origNr = seg.Nr
+			return
+		}
+	}
+}
+
+// useSlateAudio changes the recipe to take audio from the start of the asset.
+func useSlateAudio(rec *audioRecipe) {
+	length := rec.audioInEnd - rec.audioInStart + rec.audioInEndAfterWrap
+	rec.audioInStart = 0
+	rec.audioInEnd = length
+	rec.audioInEndAfterWrap = 0
+}
+
+// slateEmsg returns an emsg box if a slate interval starts in the segment [segStart, segEnd), otherwise nil.
+// The times are in timescale units with startTimeS as offset to wall-clock time.
+func slateEmsg(sl *Slate, segStart, segEnd, timescale uint64, startTimeS int) *mp4.EmsgBox {
+	offset := uint64(startTimeS) * timescale
+	cycle := uint64(sl.CycleS) * timescale
+	slateStart := (segStart + offset + cycle - 1) / cycle * cycle
+	if slateStart >= segEnd+offset {
+		return nil
+	}
+	return &mp4.EmsgBox{
+		Version:          1,
+		TimeScale:        uint32(timescale),
+		PresentationTime: slateStart - offset,
+		EventDuration:    uint32(uint64(sl.DurS) * timescale),
+		ID:               uint32(slateStart / timescale),
+		SchemeIDURI:      slateSchemeIdUri,
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Eyevinn/mp4ff/mp4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlate(t *testing.T) {
+	server, ts := newTestServer(t, nil)
+
+	a, ok := server.assetMgr.findAsset("testpic_2s")
+	require.True(t, ok)
+	timescale := a.Reps["V300"].MediaTimescale
+
+	getSeg := func(startS int) *mp4.MediaSegment {
+		url := fmt.Sprintf("/livesim2/segtimeline_1/slate_20_10_signal/testpic_2s/V300/%d.m4s?nowMS=100000",
+			startS*timescale)
+		resp, body := testFullRequest(t, ts, "GET", url, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		f, err := mp4.DecodeFile(bytes.NewBuffer(body))
+		require.NoError(t, err)
+		require.Len(t, f.Segments, 1)
+		return f.Segments[0]
+	}
+
+	// Segments in slate interval [40, 50) have the same payload but their own timing
+	slate1, slate2, normal := getSeg(40), getSeg(42), getSeg(50)
+	require.Equal(t, slate1.Fragments[0].Mdat.Data, slate2.Fragments[0].Mdat.Data)
+	require.NotEqual(t, slate1.Fragments[0].Mdat.Data, normal.Fragments[0].Mdat.Data)
+	require.Equal(t, uint64(42*timescale), slate2.Fragments[0].Moof.Traf.Tfdt.BaseMediaDecodeTime())
+
+	// Only the first slate segment signals the switch
+	require.Len(t, slate1.Fragments[0].Emsgs, 1)
+	emsg := slate1.Fragments[0].Emsgs[0]
+	require.Equal(t, slateSchemeIdUri, emsg.SchemeIDURI)
+	require.Equal(t, uint64(40*timescale), emsg.PresentationTime)
+	require.Len(t, slate2.Fragments[0].Emsgs, 0)
+
+	// Audio is taken from the start of the asset
+	resp, audio1 := testFullRequest(t, ts, "GET", "/livesim2/slate_20_10/testpic_2s/A48/21.m4s?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, audio2 := testFullRequest(t, ts, "GET", "/livesim2/testpic_2s/A48/21.m4s?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NotEqual(t, audio1, audio2)
+
+	resp, body := testFullRequest(t, ts, "GET", "/livesim2/slate_20_10_signal/testpic_2s/Manifest.mpd", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), `<InbandEventStream schemeIdUri="urn:livesim2:slate:2024"`)
+
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/slate_20_20/testpic_2s/Manifest.mpd", nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
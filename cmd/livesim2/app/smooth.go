@@ -0,0 +1,363 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Eyevinn/mp4ff/hevc"
+	"github.com/Eyevinn/mp4ff/mp4"
+)
+
+// Microsoft Smooth Streaming [MS-SSTR] output for legacy players.
+// The client manifest of the MPD <name>.mpd is <name>.isml/Manifest, and the fragments are
+// <name>.isml/QualityLevels(<bitrate>)/Fragments(<streamName>=<time>).
+// Manifest and fragments are derived from the CMAF listing of the live MPD, so they have the same
+// timeline and media as the DASH and HLS outputs. Fragments have no styp box, but a tfxd box.
+const (
+	smoothManifestSuffix = ".isml/Manifest"
+	smoothTimescale      = 10_000_000
+)
+
+var smoothPathRegexp = regexp.MustCompile(`^(.*)\.isml/(?:Manifest|QualityLevels\((\d+)\)/Fragments\((\w+)=(\d+)\))$`)
+
+type smoothStreamingMedia struct {
+	XMLName         xml.Name            `xml:"SmoothStreamingMedia"`
+	MajorVersion    int                 `xml:"MajorVersion,attr"`
+	MinorVersion    int                 `xml:"MinorVersion,attr"`
+	TimeScale       uint64              `xml:"TimeScale,attr"`
+	Duration        uint64              `xml:"Duration,attr"`
+	IsLive          string              `xml:"IsLive,attr,omitempty"`
+	DVRWindowLength uint64              `xml:"DVRWindowLength,attr,omitempty"`
+	StreamIndexes   []smoothStreamIndex `xml:"StreamIndex"`
+}
+
+type smoothStreamIndex struct {
+	Type          string               `xml:"Type,attr"`
+	Name          string               `xml:"Name,attr"`
+	Language      string               `xml:"Language,attr,omitempty"`
+	TimeScale     uint32               `xml:"TimeScale,attr"`
+	Chunks        int                  `xml:"Chunks,attr"`
+	QualityLevels int                  `xml:"QualityLevels,attr"`
+	URL           string               `xml:"Url,attr"`
+	MaxWidth      uint32               `xml:"MaxWidth,attr,omitempty"`
+	MaxHeight     uint32               `xml:"MaxHeight,attr,omitempty"`
+	Levels        []smoothQualityLevel `xml:"QualityLevel"`
+	Chunk         []smoothChunk        `xml:"c"`
+	tracks        []*CMAFTrack
+	mpdTS         uint32 // Timescale of the tracks in the MPD
+}
+
+// mediaTime converts a time in the MPD timescale to the stream index timescale.
+func (si *smoothStreamIndex) mediaTime(t uint64) uint64 {
+	return t * uint64(si.TimeScale) / uint64(si.mpdTS)
+}
+
+type smoothQualityLevel struct {
+	Index            int    `xml:"Index,attr"`
+	Bitrate          uint32 `xml:"Bitrate,attr"`
+	FourCC           string `xml:"FourCC,attr"`
+	MaxWidth         uint32 `xml:"MaxWidth,attr,omitempty"`
+	MaxHeight        uint32 `xml:"MaxHeight,attr,omitempty"`
+	SamplingRate     uint16 `xml:"SamplingRate,attr,omitempty"`
+	Channels         uint16 `xml:"Channels,attr,omitempty"`
+	BitsPerSample    uint16 `xml:"BitsPerSample,attr,omitempty"`
+	PacketSize       int    `xml:"PacketSize,attr,omitempty"`
+	AudioTag         int    `xml:"AudioTag,attr,omitempty"`
+	CodecPrivateData string `xml:"CodecPrivateData,attr"`
+}
+
+// smoothChunk is a fragment. The time is only given if it is not the end of the previous fragment.
+type smoothChunk struct {
+	T *uint64 `xml:"t,attr,omitempty"`
+	D uint64  `xml:"d,attr"`
+}
+
+// smoothStreams groups the audio and video tracks of a listing by AdaptationSet into stream indexes.
+// The fragments of a stream index are those of its first track. Since fragment times must match
+// the tfdt and tfxd times, the timescale is the media timescale of the asset representation.
+func smoothStreams(cl *CMAFListing, a *asset) []smoothStreamIndex {
+	var sis []smoothStreamIndex
+	groups := make(map[int]int)
+	names := make(map[string]bool)
+	for i := range cl.Tracks {
+		tr := &cl.Tracks[i]
+		if tr.ContentType != "video" && tr.ContentType != "audio" {
+			continue
+		}
+		gIdx, ok := groups[tr.asIdx]
+		if !ok {
+			name := tr.ContentType
+			if tr.Lang != "" && tr.ContentType == "audio" {
+				name += "_" + tr.Lang
+			}
+			if names[name] {
+				name += "_" + strconv.Itoa(tr.asIdx)
+			}
+			names[name] = true
+			gIdx = len(sis)
+			groups[tr.asIdx] = gIdx
+			si := smoothStreamIndex{
+				Type:      tr.ContentType,
+				Name:      name,
+				Language:  tr.Lang,
+				TimeScale: tr.Timescale,
+				mpdTS:     tr.Timescale,
+				URL:       fmt.Sprintf("QualityLevels({bitrate})/Fragments(%s={start time})", name),
+			}
+			if rep := a.Reps[tr.RepID]; rep != nil && rep.MediaTimescale > 0 {
+				si.TimeScale = uint32(rep.MediaTimescale)
+			}
+			sis = append(sis, si)
+		}
+		sis[gIdx].tracks = append(sis[gIdx].tracks, tr)
+	}
+	return sis
+}
+
+// smoothManifest returns the client manifest of the listing.
+// Codec private data is taken from the init segments of the asset.
+func smoothManifest(cl *CMAFListing, a *asset, dvrWindowS float64) ([]byte, error) {
+	ssm := smoothStreamingMedia{
+		MajorVersion: 2,
+		MinorVersion: 2,
+		TimeScale:    smoothTimescale,
+	}
+	if cl.Live {
+		ssm.IsLive = "TRUE"
+		ssm.DVRWindowLength = uint64(dvrWindowS * smoothTimescale)
+	}
+	for _, si := range smoothStreams(cl, a) {
+		for i, tr := range si.tracks {
+			ql, err := smoothQualityLevelFor(tr, a.Reps[tr.RepID])
+			if err != nil {
+				return nil, err
+			}
+			ql.Index = i
+			si.Levels = append(si.Levels, ql)
+			si.MaxWidth = max(si.MaxWidth, tr.Width)
+			si.MaxHeight = max(si.MaxHeight, tr.Height)
+		}
+		si.QualityLevels = len(si.Levels)
+		var nextT uint64
+		for i, seg := range si.tracks[0].Segments {
+			t := si.mediaTime(seg.Time)
+			c := smoothChunk{D: si.mediaTime(seg.Time+seg.Duration) - t}
+			if i == 0 || t != nextT {
+				c.T = &t
+			}
+			si.Chunk = append(si.Chunk, c)
+			nextT = t + c.D
+		}
+		si.Chunks = len(si.Chunk)
+		if !cl.Live {
+			ssm.Duration = max(ssm.Duration, nextT*smoothTimescale/uint64(si.TimeScale))
+		}
+		ssm.StreamIndexes = append(ssm.StreamIndexes, si)
+	}
+	out, err := xml.MarshalIndent(ssm, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// smoothQualityLevelFor returns the quality level of a track with FourCC and CodecPrivateData
+// derived from the sample entry of the init segment.
+func smoothQualityLevelFor(tr *CMAFTrack, rep *RepData) (smoothQualityLevel, error) {
+	ql := smoothQualityLevel{Bitrate: tr.Bandwidth, MaxWidth: tr.Width, MaxHeight: tr.Height}
+	if rep == nil || rep.initSeg == nil || rep.initSeg.Moov == nil || rep.initSeg.Moov.Trak == nil {
+		return ql, fmt.Errorf("no init segment for representation %s", tr.RepID)
+	}
+	stsd := rep.initSeg.Moov.Trak.Mdia.Minf.Stbl.Stsd
+	codec, _, _ := strings.Cut(tr.Codecs, ".")
+	switch codec {
+	case "avc1", "avc3":
+		ql.FourCC = "H264"
+		if stsd.AvcX != nil && stsd.AvcX.AvcC != nil {
+			ql.CodecPrivateData = annexBHex(append(stsd.AvcX.AvcC.SPSnalus, stsd.AvcX.AvcC.PPSnalus...))
+		}
+	case "hvc1", "hev1":
+		ql.FourCC = "HEVC"
+		if stsd.HvcX != nil && stsd.HvcX.HvcC != nil {
+			var nalus [][]byte
+			for _, typ := range []hevc.NaluType{hevc.NALU_VPS, hevc.NALU_SPS, hevc.NALU_PPS} {
+				nalus = append(nalus, stsd.HvcX.HvcC.GetNalusForType(typ)...)
+			}
+			ql.CodecPrivateData = annexBHex(nalus)
+		}
+	case "mp4a":
+		ql.FourCC = "AACL"
+		ql.AudioTag = 255
+	case "ec-3", "ac-3":
+		ql.FourCC = strings.ToUpper(codec)
+		ql.AudioTag = 65534
+	default:
+		ql.FourCC = strings.ToUpper(codec)
+	}
+	if mp4a := stsd.Mp4a; mp4a != nil {
+		ql.SamplingRate = mp4a.SampleRate
+		ql.Channels = mp4a.ChannelCount
+		ql.BitsPerSample = mp4a.SampleSize
+		ql.PacketSize = 4
+		if esds := mp4a.Esds; esds != nil && esds.DecConfigDescriptor != nil && esds.DecConfigDescriptor.DecSpecificInfo != nil {
+			ql.CodecPrivateData = hex.EncodeToString(esds.DecConfigDescriptor.DecSpecificInfo.DecConfig)
+		}
+	}
+	ql.CodecPrivateData = strings.ToUpper(ql.CodecPrivateData)
+	return ql, nil
+}
+
+// annexBHex returns the NAL units with start codes in hex.
+func annexBHex(nalus [][]byte) string {
+	var sb strings.Builder
+	for _, nalu := range nalus {
+		sb.WriteString("00000001")
+		sb.WriteString(hex.EncodeToString(nalu))
+	}
+	return sb.String()
+}
+
+// writeSmooth writes a Smooth Streaming client manifest or fragment.
+func (s *Server) writeSmooth(w http.ResponseWriter, r *http.Request, log *slog.Logger, cfg *ResponseConfig,
+	a *asset, contentPart string, nowMS int) {
+	parts := smoothPathRegexp.FindStringSubmatch(strings.TrimPrefix(contentPart, a.AssetPath+"/"))
+	if parts == nil {
+		writeProblem(w, r, http.StatusNotFound, reasonUnknownExtension, "unknown file extension")
+		return
+	}
+	mpdName := parts[1] + ".mpd"
+	if _, ok := a.MPDs[mpdName]; !ok {
+		writeProblem(w, r, http.StatusNotFound, reasonNotFound, fmt.Sprintf("no MPD for %q", contentPart))
+		return
+	}
+	isManifest := parts[2] == ""
+	if isManifest && cfg.MPDStall != nil {
+		nowMS = cfg.MPDStall.mpdNowMS(nowMS)
+	}
+	if isManifest {
+		nowMS = cfg.burstNowMS(nowMS)
+	}
+	lMPD, err := LiveMPD(a, mpdName, cfg, s.Cfg.DrmCfg, nowMS)
+	if err == nil {
+		removeMetricsReporting(lMPD)
+		err = s.hooks.rewriteMPD(r, lMPD)
+	}
+	if err != nil {
+		writeProblem(w, r, mpdErrorStatus(err), reasonFromError(err, reasonInternal), err.Error())
+		return
+	}
+	cl, err := cmafListingFromMPD(lMPD, mpdName, nowMS)
+	if err != nil {
+		writeProblem(w, r, http.StatusInternalServerError, reasonInternal, err.Error())
+		return
+	}
+	if isManifest {
+		dvrWindowS := 0.0
+		if lMPD.TimeShiftBufferDepth != nil {
+			dvrWindowS = lMPD.TimeShiftBufferDepth.Seconds()
+		}
+		body, err := smoothManifest(cl, a, dvrWindowS)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, reasonInternal, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		_, _ = w.Write(body)
+		return
+	}
+	bitrate, _ := strconv.ParseUint(parts[2], 10, 32)
+	t, _ := strconv.ParseUint(parts[4], 10, 64)
+	si, tr, seg := findSmoothFragment(cl, a, parts[3], uint32(bitrate), t)
+	if seg == nil {
+		writeProblem(w, r, http.StatusNotFound, reasonNotFound, "Not Found")
+		return
+	}
+	// Smooth Streaming has no chunked transfer of fragments
+	segCfg := *cfg
+	segCfg.AvailabilityTimeCompleteFlag = true
+	rec := httptest.NewRecorder()
+	code, err := writeSegment(r.Context(), rec, log, &segCfg, s.Cfg.DrmCfg, s.assetMgr.vodFS, a,
+		strings.TrimPrefix(seg.URL, tr.baseURL), nowMS, s.textTemplates, false /* isLast */)
+	if err != nil {
+		log.Error("writeSegment", "code", code, "err", err)
+		writeSegmentProblem(w, r, err)
+		return
+	}
+	if code != 0 {
+		writeProblem(w, r, code, reasonTriggeredStatus, "triggered code")
+		return
+	}
+	frag, err := smoothFragment(rec.Body.Bytes(), t, si.mediaTime(seg.Time+seg.Duration)-t)
+	if err != nil {
+		writeProblem(w, r, http.StatusInternalServerError, reasonInternal, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", tr.ContentType+"/mp4")
+	w.Header().Set("Content-Length", strconv.Itoa(len(frag)))
+	_, _ = w.Write(frag)
+}
+
+// findSmoothFragment finds the stream index, track, and segment of a fragment request.
+func findSmoothFragment(cl *CMAFListing, a *asset, streamName string, bitrate uint32,
+	t uint64) (*smoothStreamIndex, *CMAFTrack, *CMAFSegment) {
+	for _, si := range smoothStreams(cl, a) {
+		if si.Name != streamName {
+			continue
+		}
+		for _, tr := range si.tracks {
+			if tr.Bandwidth != bitrate {
+				continue
+			}
+			for i := range tr.Segments {
+				if si.mediaTime(tr.Segments[i].Time) == t {
+					return &si, tr, &tr.Segments[i]
+				}
+			}
+		}
+	}
+	return nil, nil, nil
+}
+
+// smoothFragment converts a CMAF segment to a Smooth Streaming fragment by removing the styp box
+// and adding a tfxd box with the absolute time and duration of the fragment.
+func smoothFragment(segBytes []byte, absTime, absDur uint64) ([]byte, error) {
+	f, err := mp4.DecodeFile(bytes.NewReader(segBytes))
+	if err != nil {
+		return nil, fmt.Errorf("decode segment: %w", err)
+	}
+	if len(f.Segments) == 0 {
+		return nil, errors.New("no media segment")
+	}
+	var buf bytes.Buffer
+	for _, ms := range f.Segments {
+		ms.Styp = nil
+		for _, frag := range ms.Fragments {
+			tfxd := mp4.UUIDBox{}
+			if err := tfxd.SetUUID(mp4.UUIDTfxd); err != nil {
+				return nil, err
+			}
+			tfxd.Tfxd = &mp4.TfxdData{Version: 1, FragmentAbsoluteTime: absTime, FragmentAbsoluteDuration: absDur}
+			if err := frag.Moof.Traf.AddChild(&tfxd); err != nil {
+				return nil, err
+			}
+		}
+		if err := ms.Encode(&buf); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
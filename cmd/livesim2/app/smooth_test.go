@@ -0,0 +1,83 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Eyevinn/mp4ff/mp4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSmoothStreaming(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	resp, body := testFullRequest(t, ts, "GET", "/livesim2/testpic_2s/Manifest.isml/Manifest?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var ssm smoothStreamingMedia
+	require.NoError(t, xml.Unmarshal(body, &ssm))
+	require.Equal(t, "TRUE", ssm.IsLive)
+	require.Equal(t, uint64(60*smoothTimescale), ssm.DVRWindowLength)
+	require.Len(t, ssm.StreamIndexes, 2)
+	for _, si := range ssm.StreamIndexes {
+		require.Len(t, si.Levels, 1)
+		require.Equal(t, 30, si.Chunks)
+		require.NotNil(t, si.Chunk[0].T)
+		require.Nil(t, si.Chunk[1].T)
+		ql := si.Levels[0]
+		switch si.Type {
+		case "video":
+			require.Equal(t, "QualityLevels({bitrate})/Fragments(video={start time})", si.URL)
+			require.Equal(t, uint64(20*180000), *si.Chunk[0].T)
+			require.Equal(t, "H264", ql.FourCC)
+			require.True(t, strings.HasPrefix(ql.CodecPrivateData, "0000000167"), ql.CodecPrivateData)
+		case "audio":
+			require.Equal(t, "audio_en", si.Name)
+			require.Equal(t, "AACL", ql.FourCC)
+			require.Equal(t, "1190", ql.CodecPrivateData)
+			require.Equal(t, uint16(48000), ql.SamplingRate)
+			p := fmt.Sprintf("/livesim2/testpic_2s/Manifest.isml/QualityLevels(48000)/Fragments(audio_en=%d)?nowMS=100000",
+				*si.Chunk[0].T)
+			resp, _ := testFullRequest(t, ts, "GET", p, nil)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, "audio/mp4", resp.Header.Get("Content-Type"))
+		}
+	}
+
+	fragPath := "/livesim2/testpic_2s/Manifest.isml/QualityLevels(300000)/Fragments(video=8820000)?nowMS=100000"
+	resp, body = testFullRequest(t, ts, "GET", fragPath, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "video/mp4", resp.Header.Get("Content-Type"))
+	f, err := mp4.DecodeFile(bytes.NewReader(body))
+	require.NoError(t, err)
+	require.Len(t, f.Segments, 1)
+	require.Nil(t, f.Segments[0].Styp)
+	traf := f.Segments[0].Fragments[0].Moof.Traf
+	require.Equal(t, uint64(8820000), traf.Tfdt.BaseMediaDecodeTime())
+	var tfxd *mp4.TfxdData
+	for _, c := range traf.Children {
+		if u, ok := c.(*mp4.UUIDBox); ok && u.Tfxd != nil {
+			tfxd = u.Tfxd
+		}
+	}
+	require.NotNil(t, tfxd)
+	require.Equal(t, uint64(8820000), tfxd.FragmentAbsoluteTime)
+	require.Equal(t, uint64(180000), tfxd.FragmentAbsoluteDuration)
+
+	// Not yet available, unknown bitrate, and unknown MPD
+	for _, p := range []string{
+		"/livesim2/testpic_2s/Manifest.isml/QualityLevels(300000)/Fragments(video=9000000)?nowMS=100000",
+		"/livesim2/testpic_2s/Manifest.isml/QualityLevels(1)/Fragments(video=8820000)?nowMS=100000",
+		"/livesim2/testpic_2s/Other.isml/Manifest",
+	} {
+		resp, _ = testFullRequest(t, ts, "GET", p, nil)
+		require.Equal(t, http.StatusNotFound, resp.StatusCode, p)
+	}
+}
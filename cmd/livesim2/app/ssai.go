@@ -0,0 +1,367 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/Dash-Industry-Forum/livesim2/pkg/scte35"
+	m "github.com/Eyevinn/dash-mpd/mpd"
+)
+
+// SSAI inserts ad Periods playing the ad asset of a proxy into a proxied live MPD, emulating
+// server-side ad insertion. Ad breaks of DurS seconds start every EveryS seconds after the start
+// of the upstream Period, and replace the upstream content.
+// The break boundaries are moved to the next segment start of the first video AdaptationSet.
+type SSAI struct {
+	EveryS int
+	DurS   int
+}
+
+// validate checks that 0 < DurS < EveryS.
+func (sa *SSAI) validate() error {
+	if sa.DurS <= 0 || sa.DurS >= sa.EveryS {
+		return fmt.Errorf("ssai ad duration %ds must be > 0 and less than %ds", sa.DurS, sa.EveryS)
+	}
+	return nil
+}
+
+// ssaiSeg is a segment of an upstream SegmentTemplate with media time t, duration d, and number nr.
+type ssaiSeg struct {
+	t, d uint64
+	nr   int
+}
+
+// insertAds splits the single Period of a dynamic upstream MPD into content Periods around ad Periods.
+// The content Periods get SegmentTimelines, and presentationTimeOffset and startNumber matching
+// their start, so that the upstream segments are still requested with their original names.
+func (s *SSAI) insertAds(mpd *m.MPD, px *proxy, host string, nowMS int) error {
+	if mpd.GetType() != "dynamic" {
+		return fmt.Errorf("ssai: upstream MPD is not dynamic")
+	}
+	if len(mpd.Periods) != 1 {
+		return fmt.Errorf("ssai: upstream MPD has %d Periods, not 1", len(mpd.Periods))
+	}
+	ast, err := time.Parse(time.RFC3339, string(mpd.AvailabilityStartTime))
+	if err != nil {
+		return fmt.Errorf("ssai: availabilityStartTime: %w", err)
+	}
+	up := mpd.Periods[0]
+	upStartMS := periodStartMS(up)
+	nowRelMS := nowMS - int(ast.UnixMilli()) - upStartMS
+	if nowRelMS <= 0 {
+		return fmt.Errorf("ssai: upstream Period has not started")
+	}
+	windowRelMS := 0
+	if mpd.TimeShiftBufferDepth != nil {
+		windowRelMS = max(0, nowRelMS-int(time.Duration(*mpd.TimeShiftBufferDepth).Milliseconds()))
+	}
+	if err := checkSSAITemplates(up); err != nil {
+		return err
+	}
+	grid, err := newSSAIGrid(ssaiRefTemplate(up), windowRelMS, nowRelMS)
+	if err != nil {
+		return err
+	}
+	adMPD, err := px.adAsset.getVodMPD(px.adMPD)
+	if err != nil {
+		return fmt.Errorf("ssai: ad MPD: %w", err)
+	}
+	adBaseURL := host + path.Join("/vod", px.adAsset.AssetPath, path.Dir(px.adMPD)) + "/"
+	everyMS, durMS := s.EveryS*1000, s.DurS*1000
+
+	periods := make([]*m.Period, 0, 3)
+	for k := max(0, windowRelMS/everyMS-1); ; k++ {
+		contentStartMS := 0
+		if k > 0 {
+			adStartMS, adEndMS := grid.snapMS(k*everyMS), grid.snapMS(k*everyMS+durMS)
+			if adStartMS >= nowRelMS {
+				break
+			}
+			if adEndMS > windowRelMS && adEndMS > adStartMS {
+				periods = append(periods, s.adPeriods(adMPD.Periods[0], k, upStartMS+adStartMS, adEndMS-adStartMS,
+					px.adAsset.LoopDurMS, adBaseURL)...)
+			}
+			contentStartMS = adEndMS
+		}
+		if contentStartMS >= nowRelMS {
+			break
+		}
+		contentEndMS := grid.snapMS((k + 1) * everyMS)
+		if contentEndMS <= windowRelMS {
+			continue
+		}
+		if p := ssaiContentPeriod(up, k, upStartMS, contentStartMS, contentEndMS, windowRelMS, nowRelMS); p != nil {
+			periods = append(periods, p)
+		}
+	}
+	mpd.Periods = nil
+	for _, p := range periods {
+		mpd.AppendPeriod(p)
+	}
+	// The MPD must be refreshed through livesim2, and not from upstream
+	mpd.Location = nil
+	mpd.PatchLocation = nil
+	return nil
+}
+
+// adPeriods returns the ad pod for break k, with one Period per ad, and a SCTE-35 splice_insert event
+// for the full break in the first Period. The ad asset is repeated to fill the break, and the last ad is cut.
+func (s *SSAI) adPeriods(adPeriod *m.Period, k, startMS, durMS, adDurMS int, baseURL string) []*m.Period {
+	var periods []*m.Period
+	for offMS := 0; offMS < durMS; offMS += adDurMS {
+		p := &m.Period{
+			Id:       fmt.Sprintf("ad%d-%d", k, len(periods)),
+			Start:    Ptr(m.Duration(int64(startMS+offMS) * 1_000_000)),
+			Duration: Ptr(m.Duration(int64(min(adDurMS, durMS-offMS)) * 1_000_000)),
+			BaseURLs: []*m.BaseURLType{{Value: m.AnyURI(baseURL)}},
+		}
+		for _, as := range adPeriod.AdaptationSets {
+			p.AppendAdaptationSet(as.Clone())
+		}
+		periods = append(periods, p)
+	}
+	payload := scte35.CreateSpliceInsertPayload(scte35.SpliceInsertParams{
+		PtsTime:               uint64(startMS) * 90 % (1 << 33),
+		Duration:              uint64(durMS) * 90,
+		SpliceEventID:         uint32(k),
+		OutOfNetworkIndicator: true,
+		AutoReturn:            true,
+	})
+	periods[0].EventStreams = append(periods[0].EventStreams, &m.EventStreamType{
+		SchemeIdUri: scte35.SchemeIDURI,
+		Timescale:   Ptr(uint32(1000)),
+		Events: []*m.EventType{{
+			Id:              uint32(k),
+			Duration:        uint64(durMS),
+			ContentEncoding: "base64",
+			MessageData:     base64.StdEncoding.EncodeToString(payload),
+		}},
+	})
+	return periods
+}
+
+// ssaiContentPeriod returns the part of the upstream Period from startMS to endMS relative to its start,
+// or nil if some SegmentTemplate has no available segment in that interval.
+func ssaiContentPeriod(up *m.Period, k, upStartMS, startMS, endMS, windowMS, nowMS int) *m.Period {
+	p := up.Clone()
+	id := up.Id
+	if id == "" {
+		id = "P0"
+	}
+	p.Id = fmt.Sprintf("%s-%d", id, k)
+	p.Start = Ptr(m.Duration(int64(upStartMS+startMS) * 1_000_000))
+	p.Duration = nil
+	for _, st := range ssaiTemplates(p) {
+		if splitTemplate(st, startMS, endMS, windowMS, nowMS) == 0 {
+			return nil
+		}
+	}
+	return p
+}
+
+// checkSSAITemplates returns an error if some Representation is not described by a SegmentTemplate
+// with a SegmentTimeline or a duration.
+func checkSSAITemplates(p *m.Period) error {
+	if p.SegmentTemplate != nil || p.SegmentList != nil || p.SegmentBase != nil {
+		return fmt.Errorf("ssai: Period-level segment information is not supported")
+	}
+	for _, as := range p.AdaptationSets {
+		for _, rep := range as.Representations {
+			if rep.SegmentTemplate == nil && as.SegmentTemplate == nil {
+				return fmt.Errorf("ssai: Representation %q has no SegmentTemplate", rep.Id)
+			}
+		}
+	}
+	for _, st := range ssaiTemplates(p) {
+		if st.SegmentTimeline == nil && (st.Duration == nil || *st.Duration == 0) {
+			return fmt.Errorf("ssai: SegmentTemplate without SegmentTimeline or duration")
+		}
+	}
+	return nil
+}
+
+// ssaiTemplates returns all AdaptationSet and Representation SegmentTemplates of a Period.
+func ssaiTemplates(p *m.Period) []*m.SegmentTemplateType {
+	var sts []*m.SegmentTemplateType
+	for _, as := range p.AdaptationSets {
+		if as.SegmentTemplate != nil {
+			sts = append(sts, as.SegmentTemplate)
+		}
+		for _, rep := range as.Representations {
+			if rep.SegmentTemplate != nil {
+				sts = append(sts, rep.SegmentTemplate)
+			}
+		}
+	}
+	return sts
+}
+
+// ssaiRefTemplate returns the SegmentTemplate of the first video AdaptationSet, or of the first AdaptationSet.
+func ssaiRefTemplate(p *m.Period) *m.SegmentTemplateType {
+	if len(p.AdaptationSets) == 0 {
+		return nil
+	}
+	ref := p.AdaptationSets[0]
+	for _, as := range p.AdaptationSets {
+		if as.ContentType == "video" || as.MimeType == "video/mp4" {
+			ref = as
+			break
+		}
+	}
+	if ref.SegmentTemplate == nil && len(ref.Representations) > 0 {
+		return ref.Representations[0].SegmentTemplate
+	}
+	return ref.SegmentTemplate
+}
+
+// templateSegs returns the segments of a SegmentTemplate. For a template with duration,
+// these are the segments from windowT that end before nowT, in media time.
+func templateSegs(st *m.SegmentTemplateType, windowT, nowT uint64) []ssaiSeg {
+	pto := uint64(0)
+	if st.PresentationTimeOffset != nil {
+		pto = *st.PresentationTimeOffset
+	}
+	nr := 1
+	if st.StartNumber != nil {
+		nr = int(*st.StartNumber)
+	}
+	var segs []ssaiSeg
+	if st.SegmentTimeline != nil {
+		var t uint64
+		for _, s := range st.SegmentTimeline.S {
+			if s.T != nil {
+				t = *s.T
+			}
+			if s.D == 0 {
+				break
+			}
+			r := s.R
+			if r < 0 {
+				r = int((nowT-min(t, nowT))/s.D) - 1
+			}
+			for i := 0; i <= r; i++ {
+				segs = append(segs, ssaiSeg{t: t, d: s.D, nr: nr})
+				t += s.D
+				nr++
+			}
+		}
+		return segs
+	}
+	d := uint64(*st.Duration)
+	for k := (max(windowT, pto) - pto) / d; pto+(k+1)*d <= nowT; k++ {
+		segs = append(segs, ssaiSeg{t: pto + k*d, d: d, nr: nr + int(k)})
+	}
+	return segs
+}
+
+// splitTemplate changes a SegmentTemplate to a SegmentTimeline with the segments overlapping the
+// interval startMS to endMS relative to the Period start, and returns the number of segments.
+// The presentationTimeOffset is set to the start of the interval, and startNumber to the first segment.
+func splitTemplate(st *m.SegmentTemplateType, startMS, endMS, windowMS, nowMS int) int {
+	timescale := uint64(st.GetTimescale())
+	pto := uint64(0)
+	if st.PresentationTimeOffset != nil {
+		pto = *st.PresentationTimeOffset
+	}
+	segs := templateSegs(st, pto+msToTicks(windowMS, timescale), pto+msToTicks(nowMS, timescale))
+	startT, endT := pto+msToTicks(startMS, timescale), pto+msToTicks(endMS, timescale)
+	tol := max(timescale/1000, 1) // Overlaps up to 1ms are due to rounding
+	tl := &m.SegmentTimelineType{}
+	var firstNr int
+	for _, seg := range segs {
+		if seg.t+seg.d <= startT+tol || seg.t+tol >= endT {
+			continue
+		}
+		if len(tl.S) == 0 {
+			firstNr = seg.nr
+		}
+		if len(tl.S) > 0 {
+			last := tl.S[len(tl.S)-1]
+			lastEnd := *last.T + uint64(last.R+1)*last.D
+			if last.D == seg.d && lastEnd == seg.t {
+				last.R++
+				continue
+			}
+		}
+		tl.S = append(tl.S, &m.S{T: Ptr(seg.t), D: seg.d})
+	}
+	if len(tl.S) == 0 {
+		return 0
+	}
+	n := 0
+	for _, s := range tl.S {
+		n += s.R + 1
+	}
+	// Only the first S element, and those after gaps, need an explicit time
+	for i := len(tl.S) - 1; i > 0; i-- {
+		prev := tl.S[i-1]
+		if *prev.T+uint64(prev.R+1)*prev.D == *tl.S[i].T {
+			tl.S[i].T = nil
+		}
+	}
+	st.PresentationTimeOffset = Ptr(startT)
+	st.StartNumber = Ptr(uint32(firstNr))
+	st.Duration = nil
+	st.SegmentTimeline = tl
+	return n
+}
+
+// ssaiGrid is the segment grid of the reference SegmentTemplate, used to align ad break boundaries.
+type ssaiGrid struct {
+	pto, timescale uint64
+	segs           []ssaiSeg
+}
+
+func newSSAIGrid(ref *m.SegmentTemplateType, windowMS, nowMS int) (ssaiGrid, error) {
+	if ref == nil {
+		return ssaiGrid{}, fmt.Errorf("ssai: no reference SegmentTemplate")
+	}
+	g := ssaiGrid{timescale: uint64(ref.GetTimescale())}
+	if ref.PresentationTimeOffset != nil {
+		g.pto = *ref.PresentationTimeOffset
+	}
+	g.segs = templateSegs(ref, g.pto+msToTicks(windowMS, g.timescale), g.pto+msToTicks(nowMS, g.timescale))
+	return g, nil
+}
+
+// snapMS returns the start of the first segment starting at or after relMS relative to the Period start.
+// Outside the known segments, the grid is extrapolated with the first or last segment duration.
+func (g ssaiGrid) snapMS(relMS int) int {
+	if len(g.segs) == 0 {
+		return relMS
+	}
+	bt := g.pto + msToTicks(relMS, g.timescale)
+	first, last := g.segs[0], g.segs[len(g.segs)-1]
+	var t uint64
+	switch lastEnd := last.t + last.d; {
+	case bt <= first.t:
+		t = first.t - (first.t-bt)/first.d*first.d
+	case bt >= lastEnd:
+		t = lastEnd + (bt-lastEnd+last.d-1)/last.d*last.d
+	default:
+		i := sort.Search(len(g.segs), func(i int) bool { return g.segs[i].t >= bt })
+		t = lastEnd
+		if i < len(g.segs) {
+			t = g.segs[i].t
+		}
+	}
+	if t < g.pto {
+		return 0
+	}
+	return ticksToMS(t-g.pto, g.timescale)
+}
+
+func msToTicks(ms int, timescale uint64) uint64 {
+	return (uint64(ms)*timescale + 500) / 1000
+}
+
+func ticksToMS(ticks, timescale uint64) int {
+	return int((ticks*1000 + timescale/2) / timescale)
+}
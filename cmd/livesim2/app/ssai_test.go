@@ -0,0 +1,86 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Dash-Industry-Forum/livesim2/pkg/scte35"
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/stretchr/testify/require"
+)
+
+// setupProxyServers returns an upstream livesim2 server and a livesim2 server proxying it as "up".
+func setupProxyServers(t *testing.T) (upstream, ts *httptest.Server) {
+	t.Helper()
+	_, upstream = newTestServer(t, nil)
+	_, ts = newTestServer(t, func(cfg *ServerConfig) {
+		cfg.Proxies = []ProxyConfig{{Name: "up", Origin: upstream.URL + "/livesim2", AdAsset: "testpic_2s"}}
+	})
+	return upstream, ts
+}
+
+func TestSSAIProxy(t *testing.T) {
+	upstream, ts := setupProxyServers(t)
+	defer upstream.Close()
+	defer ts.Close()
+
+	// At 100s, the window starts at 40s, so it covers content [40,60), ad [60,70), content [70,90), and ad [90,100).
+	// The ad asset is 8s long, so each break is an ad pod with an 8s and a 2s ad.
+	for _, prefix := range []string{"", "segtimeline_1/"} {
+		mpdURL := "/livesim2/ssai_30_10/proxy/up/" + prefix + "testpic_2s/Manifest.mpd?nowMS=100000"
+		resp, body := testFullRequest(t, ts, "GET", mpdURL, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode, prefix)
+		mpd, err := m.ReadFromString(string(body))
+		require.NoError(t, err)
+		require.Len(t, mpd.Periods, 6, prefix)
+		wantedIDs := []string{"P0-1", "ad2-0", "ad2-1", "P0-2", "ad3-0", "ad3-1"}
+		wantedStarts := []int{40, 60, 68, 70, 90, 98}
+		for i, p := range mpd.Periods {
+			require.Equal(t, wantedIDs[i], p.Id)
+			require.Equal(t, m.Duration(int64(wantedStarts[i])*1_000_000_000), *p.Start)
+		}
+		ad := mpd.Periods[1]
+		require.Equal(t, m.Duration(8_000_000_000), *ad.Duration)
+		require.Equal(t, m.AnyURI(ts.URL+"/vod/testpic_2s/"), ad.BaseURLs[0].Value)
+		require.Equal(t, m.AnyURI(scte35.SchemeIDURI), ad.EventStreams[0].SchemeIdUri)
+		require.Equal(t, uint64(10_000), ad.EventStreams[0].Events[0].Duration)
+		require.Equal(t, m.Duration(2_000_000_000), *mpd.Periods[2].Duration)
+		require.Len(t, mpd.Periods[2].EventStreams, 0)
+
+		// The second content Period has the upstream segments 35 to 44 with their original numbers
+		for _, as := range mpd.Periods[3].AdaptationSets {
+			st := as.SegmentTemplate
+			ts := uint64(st.GetTimescale())
+			require.Equal(t, 70*ts, *st.PresentationTimeOffset)
+			if as.ContentType != "video" {
+				continue // Audio segment durations vary in the upstream SegmentTimeline
+			}
+			if prefix == "" {
+				require.Equal(t, uint32(35), *st.StartNumber)
+			}
+			require.Len(t, st.SegmentTimeline.S, 1)
+			require.Equal(t, 70*ts, *st.SegmentTimeline.S[0].T)
+			require.Equal(t, 9, st.SegmentTimeline.S[0].R)
+		}
+	}
+
+	// Upstream segments are passed through, and ad segments come from the ad asset
+	resp, _ := testFullRequest(t, ts, "GET", "/livesim2/proxy/up/testpic_2s/V300/36.m4s?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "video/mp4", resp.Header.Get("Content-Type"))
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/proxy/up/testpic_2s/V300/100.m4s?nowMS=100000", nil)
+	require.Equal(t, http.StatusTooEarly, resp.StatusCode)
+	resp, _ = testFullRequest(t, ts, "GET", "/vod/testpic_2s/V300/1.m4s", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// ssai requires a proxy, and an ad duration shorter than the interval
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/ssai_30_10/testpic_2s/Manifest.mpd", nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/ssai_10_10/proxy/up/testpic_2s/Manifest.mpd", nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
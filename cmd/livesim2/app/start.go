@@ -7,6 +7,7 @@ package app
 import (
 	"context"
 	"fmt"
+	"io/fs"
 	"log/slog"
 	"os"
 	"time"
@@ -18,21 +19,59 @@ import (
 	"github.com/Dash-Industry-Forum/livesim2/internal"
 	"github.com/Dash-Industry-Forum/livesim2/pkg/drm"
 	"github.com/Dash-Industry-Forum/livesim2/pkg/logging"
+	"github.com/Dash-Industry-Forum/livesim2/pkg/storage"
 )
 
 // SetupServer sets up router, middleware, and server, given koanf configuration.
 func SetupServer(ctx context.Context, cfg *ServerConfig) (*Server, error) {
+	return setupServer(ctx, cfg, os.DirFS(cfg.VodRoot), slog.Default())
+}
+
+// setupServer sets up the server with VoD assets in vodFS, logging to logger.
+func setupServer(ctx context.Context, cfg *ServerConfig, vodFS fs.FS, logger *slog.Logger) (*Server, error) {
 	var err error
 
-	logger := slog.Default()
+	if err := validateListeners(cfg.Listeners); err != nil {
+		return nil, err
+	}
+	if err := checkScaledConfig(cfg); err != nil {
+		return nil, err
+	}
 
 	r := chi.NewRouter()
+	r.Use(arrivalMiddleware)
 	r.Use(middleware.RequestID)
+	r.Use(traceMiddleware)
 	r.Use(logging.SlogMiddleWare(logger))
 	r.Use(middleware.Recoverer)
+	if cfg.TrustedProxies != "" || cfg.AllowBlocks != "" || cfg.DenyBlocks != "" {
+		ipf, err := newIPFilter(cfg.TrustedProxies, cfg.AllowBlocks, cfg.DenyBlocks)
+		if err != nil {
+			return nil, err
+		}
+		r.Use(ipf.middleware)
+	}
 	prometheusMiddleWare := NewPrometheusMiddleware()
 	r.Use(prometheusMiddleWare)
 	r.Use(addVersionAndCORSHeaders)
+	if len(cfg.VanityPaths) > 0 {
+		vm, err := newVanityMapper(cfg.VanityPaths)
+		if err != nil {
+			return nil, err
+		}
+		r.Use(vm.middleware)
+	}
+	hooks := &hookRegistry{}
+	if cfg.Plugins != "" {
+		pluginHooks, err := loadPlugins(cfg.Plugins)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range pluginHooks {
+			hooks.add(h)
+		}
+	}
+	r.Use(hooks.middleware)
 
 	// Set a timeout value on the request context (ctx), that will signal
 	// through ctx.Done() that the request has timed out and further
@@ -58,18 +97,76 @@ func SetupServer(ctx context.Context, cfg *ServerConfig) (*Server, error) {
 		v.Use(ltrMw)
 	}
 
+	if cfg.MirrorOrigin != "" {
+		mirr, err := newMirror(cfg.MirrorOrigin)
+		if err != nil {
+			return nil, err
+		}
+		l.Use(mirr.middleware)
+		v.Use(mirr.middleware)
+	}
+
 	// Mount livesim and vod routers
 	r.Mount("/livesim2", l)
 	r.Mount("/vod", v)
 
-	vodFS := os.DirFS(cfg.VodRoot)
 	server := Server{
 		Router:     r,
 		LiveRouter: l,
 		VodRouter:  v,
 		Cfg:        cfg,
 		assetMgr:   newAssetMgr(vodFS, cfg.RepDataRoot, cfg.WriteRepData),
+		sessions:   newSessionStore(),
+		bookmarks:  newBookmarkStore(),
+		assetStats: newAssetStats(),
+		latency:    newLatencyStats(),
+		quotas:     newQuotaStore(),
 		reqLimiter: reqLimiter,
+		logger:     logger,
+		hooks:      hooks,
+	}
+	l.Use(server.sessionRecorderMiddleware)
+
+	if cfg.Archive != "" {
+		server.archive, err = storage.New(cfg.Archive)
+		if err != nil {
+			return nil, fmt.Errorf("archive: %w", err)
+		}
+		server.sessions.onEnd = server.archiveSession
+	}
+
+	if cfg.RecordDir != "" {
+		server.recordings, err = storage.New(cfg.RecordDir)
+		if err != nil {
+			return nil, fmt.Errorf("recorddir: %w", err)
+		}
+	}
+
+	server.mpdSigner, err = newMPDSigner(cfg.MPDSignKey)
+	if err != nil {
+		return nil, err
+	}
+	server.hostAliases, err = parseHostAliases(cfg.HostAliases)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MPDHistory > 0 {
+		server.mpdHistory = newMPDHistory(cfg.MPDHistory)
+	}
+	if cfg.QoEReports > 0 {
+		server.qoe = newQoEStore(cfg.QoEReports)
+	}
+	if cfg.CMCDSessions > 0 {
+		server.cmcd = newCMCDStore(cfg.CMCDSessions)
+	}
+	if cfg.SAND > 0 {
+		server.sand = newSANDDANE(cfg.SAND, cfg.SANDThroughputKbps)
+	}
+	if cfg.WasmPlugins != "" {
+		server.wasm, err = newWasmPlugins(ctx, cfg.WasmPlugins, logger)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	r.Route("/api", createRouteAPI(&server))
@@ -105,6 +202,21 @@ func SetupServer(ctx context.Context, cfg *ServerConfig) (*Server, error) {
 		}
 	}
 
+	server.channels, err = newChannels(cfg.Channels, server.assetMgr)
+	if err != nil {
+		return nil, fmt.Errorf("channels: %w", err)
+	}
+
+	server.proxies, err = newProxies(cfg.Proxies, server.assetMgr)
+	if err != nil {
+		return nil, fmt.Errorf("proxies: %w", err)
+	}
+
+	server.experiments, err = newExperiments(cfg.Experiments)
+	if err != nil {
+		return nil, fmt.Errorf("experiments: %w", err)
+	}
+
 	if cfg.DrmCfgFile != "" {
 		drmCfg, err := drm.ReadDrmConfig(cfg.DrmCfgFile)
 		if err != nil {
@@ -114,7 +226,19 @@ func SetupServer(ctx context.Context, cfg *ServerConfig) (*Server, error) {
 		cfg.DrmCfg = drmCfg
 	}
 
+	var ingestersToStart []uint64
+	if cfg.StateFile != "" {
+		server.state = newStateStore(cfg.StateFile)
+		ingestersToStart, err = server.loadState()
+		if err != nil {
+			return nil, fmt.Errorf("loadState: %w", err)
+		}
+	}
+
 	logger.Info("livesim2 starting", "version", internal.GetVersion(), "port", cfg.Port)
 	server.cmafMgr.Start()
+	for _, nr := range ingestersToStart {
+		server.cmafMgr.startIngester(nr)
+	}
 	return &server, nil
 }
@@ -0,0 +1,236 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// persistedState is the content of the state file.
+type persistedState struct {
+	Sessions  []persistedSession  `json:"sessions"`
+	Ingesters []persistedIngester `json:"ingesters"`
+	Bookmarks []Bookmark          `json:"bookmarks,omitempty"`
+}
+
+type persistedSession struct {
+	ID      string          `json:"id"`
+	Config  json.RawMessage `json:"config"`
+	Expires time.Time       `json:"expires"`
+	Record  bool            `json:"record,omitempty"`
+	Used    bool            `json:"used,omitempty"`
+	Webhook *WebhookSetup   `json:"webhook,omitempty"`
+}
+
+type persistedIngester struct {
+	ID      uint64            `json:"id"`
+	Setup   CmafIngesterSetup `json:"setup"`
+	DelayMS int               `json:"delayMS,omitempty"`
+	Stopped bool              `json:"stopped"`
+	Report  []string          `json:"report,omitempty"`
+}
+
+// stateStore persists sessions, CMAF ingesters, and bookmarks in a JSON file,
+// so that they survive a server restart.
+type stateStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func newStateStore(path string) *stateStore {
+	return &stateStore{path: path}
+}
+
+// load reads the state file. A missing file gives an empty state.
+func (st *stateStore) load() (persistedState, error) {
+	var ps persistedState
+	data, err := os.ReadFile(st.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ps, nil
+	}
+	if err != nil {
+		return ps, err
+	}
+	if err := json.Unmarshal(data, &ps); err != nil {
+		return ps, fmt.Errorf("state file %s: %w", st.path, err)
+	}
+	return ps, nil
+}
+
+// save writes the state to a temporary file and renames it, so that the state file is never partially written.
+func (st *stateStore) save(ps persistedState) error {
+	data, err := json.MarshalIndent(ps, "", "  ")
+	if err != nil {
+		return err
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	tmp, err := os.CreateTemp(filepath.Dir(st.path), filepath.Base(st.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), st.path)
+}
+
+// snapshot returns the non-expired sessions sorted by id.
+func (ss *sessionStore) snapshot(now time.Time) []persistedSession {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	pss := make([]persistedSession, 0, len(ss.sessions))
+	for _, sess := range ss.sessions {
+		if now.After(sess.expires) {
+			continue
+		}
+		pss = append(pss, persistedSession{
+			ID:      sess.id,
+			Config:  sess.configJSON(),
+			Expires: sess.expires,
+			Record:  sess.recorder != nil,
+			Used:    sess.used.Load(),
+			Webhook: sess.webhook.setup(),
+		})
+	}
+	sort.Slice(pss, func(i, j int) bool { return pss[i].ID < pss[j].ID })
+	return pss
+}
+
+// restore adds a persisted session with its original id. Expired sessions are skipped.
+// Recordings are not persisted, so a recording session starts with an empty recording.
+func (ss *sessionStore) restore(ps persistedSession, now time.Time) error {
+	if now.After(ps.Expires) {
+		return nil
+	}
+	if err := applyConfigJSON(NewResponseConfig(), ps.Config); err != nil {
+		return err
+	}
+	wh, err := newWebhook(ps.Webhook)
+	if err != nil {
+		return err
+	}
+	sess := &configSession{
+		id:      ps.ID,
+		config:  ps.Config,
+		expires: ps.Expires,
+		webhook: wh,
+	}
+	sess.used.Store(ps.Used)
+	if ps.Record {
+		sess.recorder = newHARRecorder()
+	}
+	ss.mu.Lock()
+	ss.sessions[sess.id] = sess
+	ss.mu.Unlock()
+	return nil
+}
+
+// snapshot returns all ingesters sorted by id.
+func (cm *cmafIngesterMgr) snapshot() []persistedIngester {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	pis := make([]persistedIngester, 0, len(cm.ingesters))
+	for nr, c := range cm.ingesters {
+		c.reportMu.Lock()
+		report := append([]string(nil), c.report...)
+		c.reportMu.Unlock()
+		pis = append(pis, persistedIngester{
+			ID:      nr,
+			Setup:   c.setup,
+			DelayMS: int(c.delay / time.Millisecond),
+			Stopped: c.state == ingesterStateStopped,
+			Report:  report,
+		})
+	}
+	sort.Slice(pis, func(i, j int) bool { return pis[i].ID < pis[j].ID })
+	return pis
+}
+
+// restore recreates persisted ingesters with their original ids and reports,
+// and returns the ids of the ingesters that should be started.
+// A start time is not kept, since it has passed, and ingesters in test mode are
+// restored as stopped since they need to be stepped.
+func (cm *cmafIngesterMgr) restore(pis []persistedIngester) []uint64 {
+	var toStart []uint64
+	for _, pi := range pis {
+		setup := pi.Setup
+		setup.StartAt = ""
+		if err := cm.newCmafIngester(pi.ID, setup); err != nil {
+			slog.Warn("could not restore CMAF ingester", "ingester", pi.ID, "err", err)
+			continue
+		}
+		cm.mu.Lock()
+		c := cm.ingesters[pi.ID]
+		c.setup = pi.Setup
+		c.delay = time.Duration(pi.DelayMS) * time.Millisecond
+		c.report = pi.Report
+		if pi.Stopped || setup.TestNowMS != nil {
+			c.state = ingesterStateStopped
+			cm.cancels[pi.ID] = func() {}
+		} else {
+			toStart = append(toStart, pi.ID)
+		}
+		cm.mu.Unlock()
+		if pi.ID > cm.nr.Load() {
+			cm.nr.Store(pi.ID)
+		}
+	}
+	return toStart
+}
+
+// loadState restores sessions, ingesters, and bookmarks from the state file.
+// It returns the ids of the ingesters to start.
+func (s *Server) loadState() ([]uint64, error) {
+	ps, err := s.state.load()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, sess := range ps.Sessions {
+		if err := s.sessions.restore(sess, now); err != nil {
+			slog.Warn("could not restore session", "session", sess.ID, "err", err)
+		}
+	}
+	toStart := s.cmafMgr.restore(ps.Ingesters)
+	for _, b := range ps.Bookmarks {
+		if err := s.bookmarks.add(b); err != nil {
+			slog.Warn("could not restore bookmark", "bookmark", b.Name, "err", err)
+		}
+	}
+	slog.Info("State restored", "path", s.state.path, "sessions", len(ps.Sessions),
+		"ingesters", len(ps.Ingesters), "bookmarks", len(ps.Bookmarks))
+	return toStart, nil
+}
+
+// saveState writes sessions, ingesters, and bookmarks to the state file, if configured.
+// Failures are logged.
+func (s *Server) saveState() {
+	if s.state == nil {
+		return
+	}
+	ps := persistedState{
+		Sessions:  s.sessions.snapshot(time.Now()),
+		Ingesters: s.cmafMgr.snapshot(),
+		Bookmarks: s.bookmarks.list(),
+	}
+	if err := s.state.save(ps); err != nil {
+		slog.Error("could not save state", "path", s.state.path, "err", err)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatePersistence(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	withStateFile := func(cfg *ServerConfig) {
+		cfg.StateFile = stateFile
+	}
+	server, ts := newTestServer(t, withStateFile)
+
+	resp, body := testFullRequest(t, ts, "POST", "/api/sessions", strings.NewReader(`{"config": {"SegTimelineFlag": true}}`))
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+	var sessID string
+	for id := range server.sessions.sessions {
+		sessID = id
+	}
+	recServer := httptest.NewServer(newCmafReceiverTestServer())
+	defer recServer.Close()
+	nr, err := server.cmafMgr.NewCmafIngester(CmafIngesterSetup{
+		DestRoot:  recServer.URL,
+		DestName:  "testpic",
+		URL:       "/livesim2/testpic_2s/Manifest.mpd",
+		TestNowMS: Ptr(10000),
+	})
+	require.NoError(t, err)
+	mustGetIngester(t, server.cmafMgr, nr).addReport("segment 5 sent")
+	server.saveState()
+	ts.Close()
+
+	// Restart with the same state file
+	server2, ts2 := newTestServer(t, withStateFile)
+	resp, body = testFullRequest(t, ts2, "GET", "/api/sessions/"+sessID, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	require.Contains(t, string(body), "SegTimelineFlag")
+	resp, _ = testFullRequest(t, ts2, "GET", "/livesim2/session_"+sessID+"/testpic_2s/Manifest.mpd", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	ing, ok := server2.cmafMgr.getIngester(nr)
+	require.True(t, ok)
+	require.Equal(t, ingesterStateStopped, ing.state, "ingester in test mode is not restarted")
+	require.Equal(t, []string{"segment 5 sent"}, ing.report)
+	nr2, err := server2.cmafMgr.NewCmafIngester(ing.setup)
+	require.NoError(t, err)
+	require.Equal(t, nr+1, nr2, "ingester ids continue after restored ones")
+
+	resp, _ = testFullRequest(t, ts2, "DELETE", "/api/sessions/"+sessID, nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	ps, err := server2.state.load()
+	require.NoError(t, err)
+	require.Len(t, ps.Sessions, 0)
+	require.Len(t, ps.Ingesters, 2)
+}
+
+// TestStateSaveWhileCreatingIngesters saves the state from stopping ingesters
+// while new ingesters are created. Run with -race.
+func TestStateSaveWhileCreatingIngesters(t *testing.T) {
+	server, _ := newTestServer(t, func(cfg *ServerConfig) {
+		cfg.StateFile = filepath.Join(t.TempDir(), "state.json")
+	})
+	recServer := httptest.NewServer(newCmafReceiverTestServer())
+	defer recServer.Close()
+	setup := CmafIngesterSetup{
+		DestRoot:  recServer.URL,
+		DestName:  "testpic",
+		URL:       "/livesim2/testpic_2s/Manifest.mpd",
+		TestNowMS: Ptr(10000),
+	}
+	cm := server.cmafMgr
+	var nrs []uint64
+	for range 5 {
+		nr, err := cm.NewCmafIngester(setup)
+		require.NoError(t, err)
+		cm.startIngester(nr)
+		nrs = append(nrs, nr)
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 20 {
+			_, err := cm.NewCmafIngester(setup)
+			require.NoError(t, err)
+		}
+	}()
+	for _, nr := range nrs {
+		require.True(t, cm.stopIngester(nr))
+	}
+	wg.Wait()
+	require.Eventually(t, func() bool {
+		for _, pi := range cm.snapshot() {
+			if slices.Contains(nrs, pi.ID) && !pi.Stopped {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Len(t, cm.snapshot(), 25)
+}
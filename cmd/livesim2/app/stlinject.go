@@ -0,0 +1,131 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+)
+
+// Pathological SegmentTimeline constructs that can be injected by the stlinject URL parameter.
+const (
+	// stlOverlap moves the start of a middle segment back by half the previous segment duration
+	stlOverlap = "overlap"
+	// stlDupTime repeats a middle S entry with the same t value
+	stlDupTime = "duptime"
+	// stlFutureR increases @r of the last S entry, announcing segments that are not yet available
+	stlFutureR = "futurer"
+	// stlNegR sets @r=-1 on the first S entry although the next entry has no @t
+	stlNegR = "negr"
+)
+
+var stlFaults = []string{stlOverlap, stlDupTime, stlFutureR, stlNegR}
+
+// validateSTLInject checks that all faults are known.
+func validateSTLInject(faults []string) error {
+	for _, f := range faults {
+		if !slices.Contains(stlFaults, f) {
+			return fmt.Errorf("stlinject unknown fault %q, allowed: %s", f, strings.Join(stlFaults, ", "))
+		}
+	}
+	return nil
+}
+
+// stlFutureRExtra is the number of extra segments announced by stlFutureR.
+const stlFutureRExtra = 2
+
+type stlEntry struct {
+	t, d      uint64
+	explicitT bool
+}
+
+// injectTimelineFaults modifies all SegmentTimelines in the MPD according to faults.
+func injectTimelineFaults(mpd *m.MPD, faults []string) {
+	for _, p := range mpd.Periods {
+		for _, as := range p.AdaptationSets {
+			if as.SegmentTemplate != nil && as.SegmentTemplate.SegmentTimeline != nil {
+				injectSTLFaults(as.SegmentTemplate.SegmentTimeline, faults)
+			}
+			for _, rep := range as.Representations {
+				if rep.SegmentTemplate != nil && rep.SegmentTemplate.SegmentTimeline != nil {
+					injectSTLFaults(rep.SegmentTemplate.SegmentTimeline, faults)
+				}
+			}
+		}
+	}
+}
+
+// injectSTLFaults applies the faults to one SegmentTimeline with at least four segments.
+func injectSTLFaults(stl *m.SegmentTimelineType, faults []string) {
+	segs := expandSTL(stl)
+	if len(segs) < 4 {
+		return
+	}
+	mid := len(segs) / 2
+	for _, f := range faults {
+		switch f {
+		case stlOverlap:
+			segs[mid].t -= segs[mid-1].d / 2
+			segs[mid].explicitT = true
+			segs[mid+1].explicitT = true
+		case stlDupTime:
+			dup := segs[mid]
+			dup.explicitT = true
+			segs[mid].explicitT = true
+			segs = append(segs[:mid+1], append([]stlEntry{dup}, segs[mid+1:]...)...)
+			segs[mid+2].explicitT = true
+		}
+	}
+	stl.S = compressSTL(segs)
+	if slices.Contains(faults, stlNegR) {
+		// Split off the first segment so that it is followed by an S entry without t
+		rest := compressSTL(segs[1:])
+		rest[0].T = nil
+		stl.S = append([]*m.S{{T: Ptr(segs[0].t), D: segs[0].d, R: -1}}, rest...)
+	}
+	if slices.Contains(faults, stlFutureR) {
+		stl.S[len(stl.S)-1].R += stlFutureRExtra
+	}
+}
+
+func expandSTL(stl *m.SegmentTimelineType) []stlEntry {
+	var segs []stlEntry
+	t := uint64(0)
+	for _, s := range stl.S {
+		if s.T != nil {
+			t = *s.T
+		}
+		for i := 0; i <= s.R; i++ {
+			segs = append(segs, stlEntry{t: t, d: s.D})
+			t += s.D
+		}
+	}
+	return segs
+}
+
+// compressSTL creates S entries with @r for runs of contiguous segments with the same duration.
+// @t is set on the first entry and where explicitly requested or not contiguous.
+func compressSTL(segs []stlEntry) []*m.S {
+	var ss []*m.S
+	var cur *m.S
+	var nextT uint64
+	for i, seg := range segs {
+		needT := i == 0 || seg.explicitT || seg.t != nextT
+		if cur != nil && !needT && seg.d == cur.D {
+			cur.R++
+		} else {
+			cur = &m.S{D: seg.d}
+			if needT {
+				cur.T = Ptr(seg.t)
+			}
+			ss = append(ss, cur)
+		}
+		nextT = seg.t + seg.d
+	}
+	return ss
+}
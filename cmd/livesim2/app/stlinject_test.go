@@ -0,0 +1,46 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"testing"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInjectSTLFaults(t *testing.T) {
+	cases := []struct {
+		desc   string
+		faults []string
+		wanted []m.S
+	}{
+		{
+			desc:   "overlap",
+			faults: []string{stlOverlap},
+			wanted: []m.S{{T: Ptr(uint64(0)), D: 10, R: 1}, {T: Ptr(uint64(15)), D: 10}, {T: Ptr(uint64(30)), D: 10}},
+		},
+		{
+			desc:   "duptime",
+			faults: []string{stlDupTime},
+			wanted: []m.S{{T: Ptr(uint64(0)), D: 10, R: 1}, {T: Ptr(uint64(20)), D: 10},
+				{T: Ptr(uint64(20)), D: 10}, {T: Ptr(uint64(30)), D: 10}},
+		},
+		{
+			desc:   "futurer and negr",
+			faults: []string{stlFutureR, stlNegR},
+			wanted: []m.S{{T: Ptr(uint64(0)), D: 10, R: -1}, {D: 10, R: 4}},
+		},
+	}
+	for _, c := range cases {
+		stl := &m.SegmentTimelineType{S: []*m.S{{T: Ptr(uint64(0)), D: 10, R: 3}}}
+		injectSTLFaults(stl, c.faults)
+		got := make([]m.S, 0, len(stl.S))
+		for _, s := range stl.S {
+			got = append(got, *s)
+		}
+		require.Equal(t, c.wanted, got, c.desc)
+	}
+}
@@ -7,6 +7,7 @@ package app
 import (
 	"fmt"
 	"math"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -158,14 +159,8 @@ func (s *strConvAccErr) ParseSegStatusCodes(key, val string) []SegStatusCodes {
 				s.err = fmt.Errorf("val=%q for key %q is not a valid. Unknown key", val, key)
 			}
 		}
-		if codes[i].Cycle <= 0 {
-			s.err = fmt.Errorf("val=%q for key %q is not a valid. cycle is too small", val, key)
-		}
-		if codes[i].Rsq < 0 {
-			s.err = fmt.Errorf("val=%q for key %q is not a valid. rsq is too small", val, key)
-		}
-		if codes[i].Code < 400 || codes[i].Code > 599 {
-			s.err = fmt.Errorf("val=%q for key %q is not a valid. code is not in range 400-599", val, key)
+		if err := codes[i].validate(); err != nil {
+			s.err = fmt.Errorf("val=%q for key %q is not a valid. %w", val, key, err)
 		}
 	}
 	return codes
@@ -180,5 +175,523 @@ func (s *strConvAccErr) ParseLossItvls(key, val string) []LossItvls {
 		s.err = fmt.Errorf("key=%s, err=%w", key, err)
 		return nil
 	}
+	for _, li := range itvls {
+		if err := li.validate(); err != nil {
+			s.err = fmt.Errorf("key=%s, %w", key, err)
+			return nil
+		}
+	}
 	return itvls
 }
+
+// ParseChaos parses <seed>_<level> with level 1-10.
+func (s *strConvAccErr) ParseChaos(key, val string) *ChaosConfig {
+	if s.err != nil {
+		return nil
+	}
+	seedStr, levelStr, ok := strings.Cut(val, "_")
+	if !ok {
+		s.err = fmt.Errorf("key=%s, val=%q is not <seed>_<level>", key, val)
+		return nil
+	}
+	seed, err := strconv.ParseUint(seedStr, 10, 64)
+	if err != nil {
+		s.err = fmt.Errorf("key=%s, err=%w", key, err)
+		return nil
+	}
+	cc := ChaosConfig{Seed: seed, Level: s.Atoi(key, levelStr)}
+	if s.err != nil {
+		return nil
+	}
+	if err := cc.validate(); err != nil {
+		s.err = fmt.Errorf("key=%s, %w", key, err)
+		return nil
+	}
+	return &cc
+}
+
+// ParseMPDStall parses <cycleS>_<durS> with 0 < durS < cycleS.
+func (s *strConvAccErr) ParseMPDStall(key, val string) *MPDStall {
+	if s.err != nil {
+		return nil
+	}
+	cycleStr, durStr, ok := strings.Cut(val, "_")
+	if !ok {
+		s.err = fmt.Errorf("key=%s, val=%q is not <cycleS>_<durS>", key, val)
+		return nil
+	}
+	ms := MPDStall{CycleS: s.Atoi(key, cycleStr), DurS: s.Atoi(key, durStr)}
+	if s.err != nil {
+		return nil
+	}
+	if err := ms.validate(); err != nil {
+		s.err = fmt.Errorf("key=%s, %w", key, err)
+		return nil
+	}
+	return &ms
+}
+
+// ParsePublishTimeCadence parses seg (every segment), <n> (every n segments), or never (0).
+func (s *strConvAccErr) ParsePublishTimeCadence(key, val string) *int {
+	if s.err != nil {
+		return nil
+	}
+	switch val {
+	case "seg":
+		return Ptr(1)
+	case "never":
+		return Ptr(0)
+	}
+	n := s.Atoi(key, val)
+	if s.err == nil && n < 1 {
+		s.err = fmt.Errorf("key=%s, val=%q is not seg, never, or a positive number of segments", key, val)
+	}
+	return &n
+}
+
+// ParseZeroOne parses 0 or 1 as a bool.
+func (s *strConvAccErr) ParseZeroOne(key, val string) *bool {
+	if s.err != nil {
+		return nil
+	}
+	switch val {
+	case "0":
+		return Ptr(false)
+	case "1":
+		return Ptr(true)
+	}
+	s.err = fmt.Errorf("key=%s, value %q is not 0 or 1", key, val)
+	return nil
+}
+
+// ParseTrailers parses a hyphen-separated list of HTTP trailers for chunked segments.
+func (s *strConvAccErr) ParseTrailers(key, val string) []string {
+	if s.err != nil {
+		return nil
+	}
+	names := strings.Split(val, "-")
+	for _, n := range names {
+		if !slices.Contains(trailerNames, n) {
+			s.err = fmt.Errorf("key=%s, unknown trailer %q, allowed: %s", key, n, strings.Join(trailerNames, ", "))
+			return nil
+		}
+	}
+	return names
+}
+
+// ParseSTLInject parses a hyphen-separated list of SegmentTimeline faults.
+func (s *strConvAccErr) ParseSTLInject(key, val string) []string {
+	if s.err != nil {
+		return nil
+	}
+	faults := strings.Split(val, "-")
+	if err := validateSTLInject(faults); err != nil {
+		s.err = fmt.Errorf("key=%s, %w", key, err)
+		return nil
+	}
+	return faults
+}
+
+// ParseMPDQuirks parses a hyphen-separated list of MPD serialization quirks.
+func (s *strConvAccErr) ParseMPDQuirks(key, val string) []string {
+	if s.err != nil {
+		return nil
+	}
+	quirks := strings.Split(val, "-")
+	for _, q := range quirks {
+		if !slices.Contains(mpdQuirks, q) {
+			s.err = fmt.Errorf("key=%s, unknown quirk %q, allowed: %s", key, q, strings.Join(mpdQuirks, ", "))
+			return nil
+		}
+	}
+	return quirks
+}
+
+// ParseMPDInflate parses <kind>_<n> with kind props or as, and 0 < n <= mpdInflateMax.
+func (s *strConvAccErr) ParseMPDInflate(key, val string) *MPDInflate {
+	if s.err != nil {
+		return nil
+	}
+	kind, nStr, ok := strings.Cut(val, "_")
+	if !ok {
+		s.err = fmt.Errorf("key=%s, val=%q is not <kind>_<n>", key, val)
+		return nil
+	}
+	if kind != mpdInflateProps && kind != mpdInflateAS {
+		s.err = fmt.Errorf("key=%s, unknown kind %q, allowed: %s, %s", key, kind, mpdInflateProps, mpdInflateAS)
+		return nil
+	}
+	mi := MPDInflate{Kind: kind, N: s.Atoi(key, nStr)}
+	if s.err == nil && (mi.N <= 0 || mi.N > mpdInflateMax) {
+		s.err = fmt.Errorf("key=%s, n=%d must be in range 1-%d", key, mi.N, mpdInflateMax)
+	}
+	return &mi
+}
+
+// ParseHDR parses <kind>[_supplemental][_codecs] with kind pq, hlg, or hlgcompat.
+func (s *strConvAccErr) ParseHDR(key, val string) *HDRSignal {
+	if s.err != nil {
+		return nil
+	}
+	parts := strings.Split(val, "_")
+	h := HDRSignal{Kind: parts[0]}
+	if err := h.validate(); err != nil {
+		s.err = fmt.Errorf("key=%s, %w", key, err)
+		return nil
+	}
+	for _, opt := range parts[1:] {
+		switch opt {
+		case "supplemental":
+			h.Supplemental = true
+		case "codecs":
+			h.Codecs = true
+		default:
+			s.err = fmt.Errorf("key=%s, unknown option %q, allowed: supplemental, codecs", key, opt)
+			return nil
+		}
+	}
+	return &h
+}
+
+// ParseBandwidthDrift parses comma-separated [<repID>:]<pct>, where an entry without repID applies to all
+// other Representations, and 0 < pct <= maxBandwidthDriftPct.
+func (s *strConvAccErr) ParseBandwidthDrift(key, val string) *BandwidthDrift {
+	if s.err != nil {
+		return nil
+	}
+	bd := BandwidthDrift{}
+	for _, entry := range strings.Split(val, ",") {
+		repID, pctStr, ok := strings.Cut(entry, ":")
+		if !ok {
+			repID, pctStr = "", entry
+		}
+		pct := s.Atoi(key, pctStr)
+		if s.err != nil {
+			return nil
+		}
+		if pct == 0 {
+			s.err = fmt.Errorf("key=%s, pct=0 must be in range 1-%d", key, maxBandwidthDriftPct)
+			return nil
+		}
+		switch {
+		case ok && repID == "":
+			s.err = fmt.Errorf("key=%s, empty repID in %q", key, entry)
+			return nil
+		case !ok:
+			bd.DefaultPct = pct
+		default:
+			if bd.RepPct == nil {
+				bd.RepPct = make(map[string]int)
+			}
+			bd.RepPct[repID] = pct
+		}
+	}
+	if err := bd.validate(); err != nil {
+		s.err = fmt.Errorf("key=%s, %w", key, err)
+		return nil
+	}
+	return &bd
+}
+
+// ParseSizeVariance parses <pct>[_trim][_<repIDs>] with 0 < pct <= maxSizeVariancePct and comma-separated repIDs.
+func (s *strConvAccErr) ParseSizeVariance(key, val string) *SizeVariance {
+	if s.err != nil {
+		return nil
+	}
+	pctStr, ids, hasIDs := strings.Cut(val, "_")
+	sv := SizeVariance{Pct: s.Atoi(key, pctStr)}
+	if s.err != nil {
+		return nil
+	}
+	if sv.Pct <= 0 || sv.Pct > maxSizeVariancePct {
+		s.err = fmt.Errorf("key=%s, pct=%d must be in range 1-%d", key, sv.Pct, maxSizeVariancePct)
+		return nil
+	}
+	if hasIDs && (ids == "trim" || strings.HasPrefix(ids, "trim_")) {
+		sv.Trim = true
+		ids, hasIDs = strings.CutPrefix(strings.TrimPrefix(ids, "trim"), "_")
+	}
+	if hasIDs {
+		sv.RepIDs = strings.Split(ids, ",")
+	}
+	if err := sv.validate(); err != nil {
+		s.err = fmt.Errorf("key=%s, %w", key, err)
+		return nil
+	}
+	return &sv
+}
+
+// ParseChunkCadence parses <mode>[_<pct>] with mode front, back, or jitter, and 0 < pct <= 100.
+func (s *strConvAccErr) ParseChunkCadence(key, val string) *ChunkCadence {
+	if s.err != nil {
+		return nil
+	}
+	mode, pctStr, hasPct := strings.Cut(val, "_")
+	cc := ChunkCadence{Mode: mode, Pct: defaultChunkCadencePct}
+	if hasPct {
+		cc.Pct = s.Atoi(key, pctStr)
+		if s.err != nil {
+			return nil
+		}
+	}
+	if err := cc.validate(); err != nil {
+		s.err = fmt.Errorf("key=%s, %w", key, err)
+		return nil
+	}
+	return &cc
+}
+
+// ParseRedirect parses <depth>[_<delayMS>[_<code>]].
+func (s *strConvAccErr) ParseRedirect(key, val string) *Redirect {
+	if s.err != nil {
+		return nil
+	}
+	parts := strings.Split(val, "_")
+	if len(parts) > 3 {
+		s.err = fmt.Errorf("key=%s, val=%q is not <depth>[_<delayMS>[_<code>]]", key, val)
+		return nil
+	}
+	rd := Redirect{Depth: s.Atoi(key, parts[0])}
+	if len(parts) > 1 {
+		rd.DelayMS = s.Atoi(key, parts[1])
+	}
+	if len(parts) > 2 {
+		rd.Code = s.Atoi(key, parts[2])
+	}
+	if s.err != nil {
+		return nil
+	}
+	return &rd
+}
+
+// ParseCrossHost parses <mode>[_<n>] with mode as, rep, or alt and 0 < n <= maxCrossHosts.
+func (s *strConvAccErr) ParseCrossHost(key, val string) *CrossHost {
+	if s.err != nil {
+		return nil
+	}
+	mode, nStr, hasN := strings.Cut(val, "_")
+	ch := CrossHost{Mode: mode}
+	if hasN {
+		ch.N = s.Atoi(key, nStr)
+		if s.err != nil {
+			return nil
+		}
+		if ch.N == 0 {
+			s.err = fmt.Errorf("key=%s, n=0 must be in range 1-%d", key, maxCrossHosts)
+			return nil
+		}
+	}
+	if err := ch.validate(); err != nil {
+		s.err = fmt.Errorf("key=%s, %w", key, err)
+		return nil
+	}
+	return &ch
+}
+
+// ParseRepChange parses <mode>_<atS>_<repIDs> with mode add or remove and comma-separated repIDs.
+func (s *strConvAccErr) ParseRepChange(key, val string) *RepChange {
+	if s.err != nil {
+		return nil
+	}
+	mode, rest, ok1 := strings.Cut(val, "_")
+	atStr, ids, ok2 := strings.Cut(rest, "_")
+	if !ok1 || !ok2 || ids == "" {
+		s.err = fmt.Errorf("key=%s, val=%q is not <mode>_<atS>_<repIDs>", key, val)
+		return nil
+	}
+	rc := RepChange{Mode: mode, AtS: s.Atoi(key, atStr), RepIDs: strings.Split(ids, ",")}
+	if s.err != nil {
+		return nil
+	}
+	if err := rc.validate(); err != nil {
+		s.err = fmt.Errorf("key=%s, %w", key, err)
+		return nil
+	}
+	return &rc
+}
+
+// ParseRepIDChange parses <atS>_<suffix> with an alphanumeric suffix.
+func (s *strConvAccErr) ParseRepIDChange(key, val string) *RepIDChange {
+	if s.err != nil {
+		return nil
+	}
+	atStr, suffix, ok := strings.Cut(val, "_")
+	if !ok {
+		s.err = fmt.Errorf("key=%s, val=%q is not <atS>_<suffix> with alphanumeric suffix", key, val)
+		return nil
+	}
+	rc := RepIDChange{AtS: s.Atoi(key, atStr), Suffix: suffix}
+	if s.err != nil {
+		return nil
+	}
+	if err := rc.validate(); err != nil {
+		s.err = fmt.Errorf("key=%s, %w", key, err)
+		return nil
+	}
+	return &rc
+}
+
+// ParseLadder parses <repID>_<kbps>[,<kbps>...][_pad] with at most maxLadderRungs distinct positive bitrates.
+func (s *strConvAccErr) ParseLadder(key, val string) *Ladder {
+	if s.err != nil {
+		return nil
+	}
+	l := Ladder{}
+	if rest, ok := strings.CutSuffix(val, "_pad"); ok {
+		l.Pad = true
+		val = rest
+	}
+	idx := strings.LastIndex(val, "_")
+	if idx <= 0 {
+		s.err = fmt.Errorf("key=%s, val=%q is not <repID>_<kbps>[,<kbps>...][_pad]", key, val)
+		return nil
+	}
+	l.RepID = val[:idx]
+	for _, kbpsStr := range strings.Split(val[idx+1:], ",") {
+		kbps := s.Atoi(key, kbpsStr)
+		if s.err != nil {
+			return nil
+		}
+		l.Kbps = append(l.Kbps, kbps)
+	}
+	if err := l.validate(); err != nil {
+		s.err = fmt.Errorf("key=%s, %w", key, err)
+		return nil
+	}
+	return &l
+}
+
+// ParseQuota parses <n>_<repIDs>[_<windowS>] with n > 0, comma-separated repIDs, and windowS > 0.
+func (s *strConvAccErr) ParseQuota(key, val string) *RepQuota {
+	if s.err != nil {
+		return nil
+	}
+	parts := strings.Split(val, "_")
+	if len(parts) < 2 || len(parts) > 3 || parts[1] == "" {
+		s.err = fmt.Errorf("key=%s, val=%q is not <n>_<repIDs>[_<windowS>]", key, val)
+		return nil
+	}
+	q := RepQuota{N: s.Atoi(key, parts[0]), RepIDs: strings.Split(parts[1], ","), WindowS: defaultQuotaWindowS}
+	if len(parts) == 3 {
+		q.WindowS = s.Atoi(key, parts[2])
+	}
+	if s.err != nil {
+		return nil
+	}
+	if err := q.validate(); err != nil {
+		s.err = fmt.Errorf("key=%s, %w", key, err)
+		return nil
+	}
+	return &q
+}
+
+// ParseSSAI parses <everyS>_<durS> with 0 < durS < everyS.
+func (s *strConvAccErr) ParseSSAI(key, val string) *SSAI {
+	if s.err != nil {
+		return nil
+	}
+	parts := strings.Split(val, "_")
+	if len(parts) != 2 {
+		s.err = fmt.Errorf("key=%s, val=%q is not <everyS>_<durS>", key, val)
+		return nil
+	}
+	sa := SSAI{EveryS: s.Atoi(key, parts[0]), DurS: s.Atoi(key, parts[1])}
+	if s.err != nil {
+		return nil
+	}
+	if err := sa.validate(); err != nil {
+		s.err = fmt.Errorf("key=%s, %w", key, err)
+		return nil
+	}
+	return &sa
+}
+
+// ParseSlate parses <cycleS>_<durS>[_signal] with 0 < durS < cycleS.
+func (s *strConvAccErr) ParseSlate(key, val string) *Slate {
+	if s.err != nil {
+		return nil
+	}
+	parts := strings.Split(val, "_")
+	if len(parts) < 2 || len(parts) > 3 || (len(parts) == 3 && parts[2] != "signal") {
+		s.err = fmt.Errorf("key=%s, val=%q is not <cycleS>_<durS>[_signal]", key, val)
+		return nil
+	}
+	sl := Slate{CycleS: s.Atoi(key, parts[0]), DurS: s.Atoi(key, parts[1]), Signal: len(parts) == 3}
+	if s.err != nil {
+		return nil
+	}
+	if err := sl.validate(); err != nil {
+		s.err = fmt.Errorf("key=%s, %w", key, err)
+		return nil
+	}
+	return &sl
+}
+
+// ParseBlackout parses <startS>_<endS>[_<rep>,<rep>...] with wall-clock times in seconds.
+func (s *strConvAccErr) ParseBlackout(key, val string) *Blackout {
+	if s.err != nil {
+		return nil
+	}
+	parts := strings.SplitN(val, "_", 3)
+	if len(parts) < 2 {
+		s.err = fmt.Errorf("key=%s, val=%q is not <startS>_<endS>[_<reps>]", key, val)
+		return nil
+	}
+	bo := Blackout{StartS: s.Atoi(key, parts[0]), EndS: s.Atoi(key, parts[1])}
+	if len(parts) == 3 {
+		bo.Reps = strings.Split(parts[2], ",")
+	}
+	return &bo
+}
+
+// ParsePrograms parses <durS>[_inband].
+func (s *strConvAccErr) ParsePrograms(key, val string) *Programs {
+	if s.err != nil {
+		return nil
+	}
+	parts := strings.Split(val, "_")
+	if len(parts) > 2 || (len(parts) == 2 && parts[1] != "inband") {
+		s.err = fmt.Errorf("key=%s, val=%q is not <durS>[_inband]", key, val)
+		return nil
+	}
+	return &Programs{DurS: s.Atoi(key, parts[0]), Inband: len(parts) == 2}
+}
+
+// ParsePSSH parses <count>[_<payloadBytes>][_kidsonly].
+func (s *strConvAccErr) ParsePSSH(key, val string) *PSSHConfig {
+	if s.err != nil {
+		return nil
+	}
+	parts := strings.Split(val, "_")
+	pc := PSSHConfig{}
+	if parts[len(parts)-1] == "kidsonly" {
+		pc.KIDsOnly = true
+		parts = parts[:len(parts)-1]
+	}
+	if len(parts) < 1 || len(parts) > 2 {
+		s.err = fmt.Errorf("key=%s, val=%q is not <count>[_<payloadBytes>][_kidsonly]", key, val)
+		return nil
+	}
+	pc.Count = s.Atoi(key, parts[0])
+	if len(parts) == 2 {
+		pc.PayloadSize = s.Atoi(key, parts[1])
+	}
+	return &pc
+}
+
+// ParseLicense parses <delayMS>[_<failPercent>].
+func (s *strConvAccErr) ParseLicense(key, val string) *LicenseSim {
+	if s.err != nil {
+		return nil
+	}
+	parts := strings.Split(val, "_")
+	if len(parts) > 2 {
+		s.err = fmt.Errorf("key=%s, val=%q is not <delayMS>[_<failPercent>]", key, val)
+		return nil
+	}
+	ls := LicenseSim{DelayMS: s.Atoi(key, parts[0])}
+	if len(parts) == 2 {
+		ls.FailPercent = s.Atoi(key, parts[1])
+	}
+	return &ls
+}
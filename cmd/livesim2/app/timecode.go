@@ -0,0 +1,116 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/Eyevinn/mp4ff/avc"
+	"github.com/Eyevinn/mp4ff/mp4"
+	"github.com/Eyevinn/mp4ff/sei"
+)
+
+// Synthetic video timecodes are user data unregistered SEI messages with the wall-clock time of
+// each video sample, formatted like the time subtitles according to timesubstz and timesubslocale.
+// The pictures are not re-encoded, so a clock rendered in the video is unaffected.
+
+// timecodeUUID identifies the livesim2 timecode in the user data unregistered SEI payload.
+var timecodeUUID = [16]byte{
+	0x6c, 0x69, 0x76, 0x65, 0x73, 0x69, 0x6d, 0x32, // "livesim2"
+	0x74, 0x69, 0x6d, 0x65, 0x63, 0x6f, 0x64, 0x65, // "timecode"
+}
+
+// timecodeSEINalHeader returns the SEI NAL unit header and a check for picture data (VCL) NAL units for a video codec.
+func timecodeSEINalHeader(codecs string) (hdr []byte, isVCL func(hdr byte) bool, ok bool) {
+	hdrLen, _, ok := captionSEICodec(codecs)
+	switch {
+	case !ok:
+		return nil, nil, false
+	case hdrLen == 1:
+		return []byte{6}, func(hdr byte) bool { return hdr&0x1f >= 1 && hdr&0x1f <= 5 }, true
+	default:
+		return []byte{39 << 1, 1}, func(hdr byte) bool { return (hdr>>1)&0x3f < 32 }, true
+	}
+}
+
+// timecodeSEINalu returns an SEI NAL unit with the timecode text.
+func timecodeSEINalu(hdr []byte, text string) ([]byte, error) {
+	payload := append(timecodeUUID[:], text...)
+	buf := bytes.Buffer{}
+	buf.Write(hdr)
+	if err := sei.WriteSEIMessages(&buf, []sei.SEIMessage{sei.NewSEIData(sei.SEIUserDataUnregisteredType, payload)}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// insertTimecodeSEI returns the sample with the SEI NAL unit inserted before the first picture data NAL unit,
+// so that it comes after any access unit delimiter, parameter sets, and SEI with buffering period.
+func insertTimecodeSEI(sample, seiNalu []byte, isVCL func(byte) bool) ([]byte, error) {
+	nalus, err := avc.GetNalusFromSample(sample)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(sample)+4+len(seiNalu))
+	inserted := false
+	for _, nalu := range nalus {
+		if !inserted && len(nalu) > 0 && isVCL(nalu[0]) {
+			out = binary.BigEndian.AppendUint32(out, uint32(len(seiNalu)))
+			out = append(out, seiNalu...)
+			inserted = true
+		}
+		out = binary.BigEndian.AppendUint32(out, uint32(len(nalu)))
+		out = append(out, nalu...)
+	}
+	if !inserted {
+		out = binary.BigEndian.AppendUint32(out, uint32(len(seiNalu)))
+		out = append(out, seiNalu...)
+	}
+	return out, nil
+}
+
+// addTimecodes inserts a timecode SEI message in all samples of a video segment.
+// The sample presentation times in timescale are relative to startTimeS.
+// Encrypted fragments are left unchanged.
+func addTimecodes(seg *mp4.MediaSegment, trex *mp4.TrexBox, codecs string, timescale uint64, clock subsClock, startTimeS int) error {
+	hdr, isVCL, ok := timecodeSEINalHeader(codecs)
+	if !ok {
+		return nil
+	}
+	for _, frag := range seg.Fragments {
+		traf := frag.Moof.Traf
+		if traf.Senc != nil {
+			continue
+		}
+		samples, err := frag.GetFullSamples(trex)
+		if err != nil {
+			return err
+		}
+		mdatData := make([]byte, 0, len(frag.Mdat.Data))
+		sampleNr := 0
+		for _, trun := range traf.Truns {
+			for i := range trun.Samples {
+				s := &samples[sampleNr]
+				utcMS := startTimeS*1000 + int(s.PresentationTime()*1000/timescale)
+				seiNalu, err := timecodeSEINalu(hdr, clock.format(utcMS))
+				if err != nil {
+					return fmt.Errorf("sample %d: %w", sampleNr+1, err)
+				}
+				data, err := insertTimecodeSEI(s.Data, seiNalu, isVCL)
+				if err != nil {
+					return fmt.Errorf("sample %d: %w", sampleNr+1, err)
+				}
+				trun.Samples[i].Size = uint32(len(data))
+				mdatData = append(mdatData, data...)
+				sampleNr++
+			}
+			trun.Flags |= mp4.TrunSampleSizePresentFlag
+		}
+		frag.Mdat.SetData(mdatData)
+	}
+	return nil
+}
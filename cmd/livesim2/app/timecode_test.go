@@ -0,0 +1,90 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/Eyevinn/mp4ff/avc"
+	"github.com/Eyevinn/mp4ff/bits"
+	"github.com/Eyevinn/mp4ff/mp4"
+	"github.com/Eyevinn/mp4ff/sei"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsertTimecodeSEI(t *testing.T) {
+	hdr, isVCL, ok := timecodeSEINalHeader("avc1.64001e")
+	require.True(t, ok)
+	seiNalu, err := timecodeSEINalu(hdr, "2023-11-14T22:13:20Z")
+	require.NoError(t, err)
+	other := sei.NewSEIData(5, bytes.Repeat([]byte{0x11}, 17))
+	out, err := insertTimecodeSEI(avcSample(t, other), seiNalu, isVCL)
+	require.NoError(t, err)
+	nalus, err := avc.GetNalusFromSample(out)
+	require.NoError(t, err)
+	require.Len(t, nalus, 3)
+	require.Equal(t, seiNalu, nalus[1], "inserted after existing SEI and before the slice")
+	require.Equal(t, avc.NALU_IDR, avc.GetNaluType(nalus[2][0]))
+
+	_, _, ok = timecodeSEINalHeader("mp4a.40.2")
+	require.False(t, ok)
+}
+
+func TestTimecodeSegment(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	// timecodes returns the timecode texts of the segment samples.
+	timecodes := func(body []byte) []string {
+		t.Helper()
+		sf, err := mp4.DecodeFileSR(bits.NewFixedSliceReader(body))
+		require.NoError(t, err)
+		var texts []string
+		for _, frag := range sf.Segments[0].Fragments {
+			samples, err := frag.GetFullSamples(nil)
+			require.NoError(t, err)
+			for _, s := range samples {
+				nalus, err := avc.GetNalusFromSample(s.Data)
+				require.NoError(t, err)
+				for _, nalu := range nalus {
+					if avc.GetNaluType(nalu[0]) != avc.NALU_SEI {
+						continue
+					}
+					msgs, err := sei.ExtractSEIData(bytes.NewReader(nalu[1:]))
+					require.NoError(t, err)
+					for _, msg := range msgs {
+						pl := msg.Payload()
+						if msg.Type() == sei.SEIUserDataUnregisteredType && bytes.Equal(pl[:16], timecodeUUID[:]) {
+							texts = append(texts, string(pl[16:]))
+						}
+					}
+				}
+			}
+		}
+		return texts
+	}
+
+	resp, body := testFullRequest(t, ts, "GET", "/livesim2/testpic_2s/V300/5.m4s?nowMS=20000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, timecodes(body))
+
+	resp, body = testFullRequest(t, ts, "GET", "/livesim2/timecode_1/testpic_2s/V300/5.m4s?nowMS=20000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	texts := timecodes(body)
+	require.Len(t, texts, 60)
+	require.Equal(t, "1970-01-01T00:00:10Z", texts[0])
+
+	resp, body = testFullRequest(t, ts, "GET",
+		"/livesim2/timecode_1/timesubstz_Europe.Stockholm/timesubslocale_de-DE/testpic_2s/V300/5.m4s?nowMS=20000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	texts = timecodes(body)
+	require.Len(t, texts, 60)
+	require.Equal(t, "01.01.1970 01:00:10 CET", texts[0])
+
+	resp, body = testFullRequest(t, ts, "GET", "/livesim2/timecode_1/testpic_2s/A48/5.m4s?nowMS=20000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NotEmpty(t, body)
+}
@@ -0,0 +1,183 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/Eyevinn/mp4ff/mp4"
+)
+
+// maxTimescale is the largest value accepted by the timescale URL parameter.
+const maxTimescale = 10_000_000
+
+// rescaleTime converts t from timescale from to timescale to, rounding to nearest.
+// The intermediate product is 128 bits, so that large media times do not overflow.
+func rescaleTime(t, from, to uint64) uint64 {
+	hi, lo := bits.Mul64(t, to)
+	var carry uint64
+	lo, carry = bits.Add64(lo, from/2, 0)
+	hi += carry
+	if hi >= from {
+		return math.MaxUint64
+	}
+	q, _ := bits.Div64(hi, lo, from)
+	return q
+}
+
+// rescaleRep returns true if the timescale of the representation is changed by the timescale parameter.
+func rescaleRep(cfg *ResponseConfig, rep *RepData) bool {
+	return cfg.Timescale != nil && rep.ContentType == "video"
+}
+
+// rescaleInit sets the media timescale of the init segment to timescale.
+// The trex default sample duration and edit list media time are converted to the new timescale.
+func rescaleInit(data []byte, oldTimescale, timescale uint64) ([]byte, error) {
+	initSeg, err := getInitSeg(data)
+	if err != nil {
+		return nil, err
+	}
+	trak := initSeg.Moov.Trak
+	mdhd := trak.Mdia.Mdhd
+	mdhd.Timescale = uint32(timescale)
+	mdhd.Duration = rescaleTime(mdhd.Duration, oldTimescale, timescale)
+	if trex := getTrex(initSeg); trex != nil {
+		trex.DefaultSampleDuration = uint32(rescaleTime(uint64(trex.DefaultSampleDuration), oldTimescale, timescale))
+	}
+	if trak.Edts != nil {
+		for _, elst := range trak.Edts.Elst {
+			for i, e := range elst.Entries {
+				if e.MediaTime > 0 {
+					elst.Entries[i].MediaTime = int64(rescaleTime(uint64(e.MediaTime), oldTimescale, timescale))
+				}
+			}
+		}
+	}
+	return getInitBytes(initSeg)
+}
+
+// rescaleSegment converts all times in the media segment to timescale.
+// Sample times are rounded individually, and durations are calculated as the
+// difference between rounded times, so that no drift is accumulated.
+func rescaleSegment(seg *mp4.MediaSegment, trex *mp4.TrexBox, oldTimescale, timescale uint64) error {
+	rescale := func(t uint64) uint64 {
+		return rescaleTime(t, oldTimescale, timescale)
+	}
+	for _, frag := range seg.Fragments {
+		traf := frag.Moof.Traf
+		if traf.Senc != nil || len(traf.Truns) != 1 {
+			return fmt.Errorf("cannot rescale encrypted or multi-trun fragment")
+		}
+		tfhd := traf.Tfhd
+		trun := traf.Trun
+		trun.AddSampleDefaultValues(tfhd, trex)
+		t := traf.Tfdt.BaseMediaDecodeTime()
+		newT := rescale(t)
+		traf.Tfdt.SetBaseMediaDecodeTime(newT)
+		for i, s := range trun.Samples {
+			pt := int64(t) + int64(s.CompositionTimeOffset)
+			var newPT int64
+			if pt >= 0 {
+				newPT = int64(rescale(uint64(pt)))
+			} else {
+				newPT = -int64(rescale(uint64(-pt)))
+			}
+			t += uint64(s.Dur)
+			nextT := rescale(t)
+			trun.Samples[i].Dur = uint32(nextT - newT)
+			trun.Samples[i].CompositionTimeOffset = int32(newPT - int64(newT))
+			newT = nextT
+		}
+		trun.Flags |= mp4.TrunSampleDurationPresentFlag | mp4.TrunSampleCompositionTimeOffsetPresentFlag
+		trun.Version = 1
+		if tfhd.HasDefaultSampleDuration() {
+			tfhd.DefaultSampleDuration = uint32(rescale(uint64(tfhd.DefaultSampleDuration)))
+		}
+	}
+	for _, sidx := range seg.Sidxs {
+		sidx.Timescale = uint32(timescale)
+		ept := sidx.EarliestPresentationTime
+		sidx.EarliestPresentationTime = rescale(ept)
+		for i, ref := range sidx.SidxRefs {
+			end := ept + uint64(ref.SubSegmentDuration)
+			sidx.SidxRefs[i].SubSegmentDuration = uint32(rescale(end) - rescale(ept))
+			ept = end
+		}
+	}
+	return nil
+}
+
+// unscaleSegmentTime maps a segment start time in the rescaled timescale back to
+// the asset media time. Only times that are exact rescaled segment start times match.
+func unscaleSegmentTime(a *asset, rep *RepData, t uint64, timescale uint64) (uint64, error) {
+	repTimescale := uint64(rep.MediaTimescale)
+	approx := rescaleTime(t, timescale, repTimescale)
+	wrapDur := uint64(a.LoopDurMS) * repTimescale / 1000
+	wrapTime := approx / wrapDur * wrapDur
+	idx := rep.findSegmentIndexFromTime(approx - wrapTime)
+	for _, i := range []int{idx - 1, idx} {
+		var origTime uint64
+		switch {
+		case i < 0:
+			continue
+		case i == len(rep.Segments):
+			origTime = wrapTime + wrapDur + rep.Segments[0].StartTime
+		default:
+			origTime = wrapTime + rep.Segments[i].StartTime
+		}
+		if rescaleTime(origTime, repTimescale, timescale) == t {
+			return origTime, nil
+		}
+	}
+	return 0, errNotFound
+}
+
+// rescaleMPD sets the timescale of all video SegmentTemplates to timescale.
+// SegmentTimeline entries are rounded in the same way as the media segment times.
+func rescaleMPD(mpd *m.MPD, timescale uint64) {
+	for _, p := range mpd.Periods {
+		for _, as := range p.AdaptationSets {
+			ct := string(as.ContentType)
+			if ct == "" {
+				ct = guessContentTypeForAS(as)
+			}
+			if ct != "video" {
+				continue
+			}
+			rescaleSegmentTemplate(as.SegmentTemplate, timescale)
+			for _, rep := range as.Representations {
+				rescaleSegmentTemplate(rep.SegmentTemplate, timescale)
+			}
+		}
+	}
+}
+
+func rescaleSegmentTemplate(st *m.SegmentTemplateType, timescale uint64) {
+	if st == nil {
+		return
+	}
+	oldTimescale := uint64(st.GetTimescale())
+	rescale := func(t uint64) uint64 {
+		return rescaleTime(t, oldTimescale, timescale)
+	}
+	st.Timescale = Ptr(uint32(timescale))
+	if st.PresentationTimeOffset != nil {
+		st.PresentationTimeOffset = Ptr(rescale(*st.PresentationTimeOffset))
+	}
+	if st.Duration != nil {
+		st.Duration = Ptr(uint32(rescale(uint64(*st.Duration))))
+	}
+	if st.SegmentTimeline != nil {
+		segs := expandSTL(st.SegmentTimeline)
+		for i, seg := range segs {
+			start := rescale(seg.t)
+			segs[i] = stlEntry{t: start, d: rescale(seg.t+seg.d) - start}
+		}
+		st.SegmentTimeline.S = compressSTL(segs)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Eyevinn/mp4ff/mp4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRescaleTime(t *testing.T) {
+	cases := []struct {
+		t, from, to, want uint64
+	}{
+		{t: 180000, from: 90000, to: 1000, want: 2000},
+		{t: 3000, from: 90000, to: 1000, want: 33},
+		{t: 3000, from: 90000, to: 44100, want: 1470},
+		{t: 1, from: 3, to: 2, want: 1},
+		{t: 1 << 50, from: 90000, to: 10_000_000, want: 125099989649180444},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.want, rescaleTime(c.t, c.from, c.to), "%d %d->%d", c.t, c.from, c.to)
+	}
+}
+
+func TestTimescale(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	resp, body := testFullRequest(t, ts, "GET", "/livesim2/timescale_1000/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), `duration="2000" startNumber="0" timescale="1000"`)
+	require.Contains(t, string(body), `duration="2" startNumber="0">`, "audio SegmentTemplate unchanged")
+
+	resp, body = testFullRequest(t, ts, "GET", "/livesim2/timescale_1000/testpic_2s/V300/init.mp4", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	f, err := mp4.DecodeFile(bytes.NewBuffer(body))
+	require.NoError(t, err)
+	require.Equal(t, uint32(1000), f.Init.Moov.Trak.Mdia.Mdhd.Timescale)
+
+	getSeg := func(url string) *mp4.MediaSegment {
+		resp, body := testFullRequest(t, ts, "GET", url, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode, url)
+		f, err := mp4.DecodeFile(bytes.NewBuffer(body))
+		require.NoError(t, err)
+		require.Len(t, f.Segments, 1)
+		return f.Segments[0]
+	}
+
+	// 30 fps frames of 3000 ticks at 90kHz become 33 or 34ms
+	seg := getSeg("/livesim2/timescale_1000/testpic_2s/V300/40.m4s?nowMS=100000")
+	traf := seg.Fragments[0].Moof.Traf
+	require.Equal(t, uint64(80_000), traf.Tfdt.BaseMediaDecodeTime())
+	require.Equal(t, uint64(2000), traf.Trun.Duration(0))
+	fss, err := seg.Fragments[0].GetFullSamples(nil)
+	require.NoError(t, err)
+	require.Equal(t, uint32(33), fss[0].Dur)
+	require.Equal(t, uint32(34), fss[1].Dur)
+
+	// An audio timescale for video
+	seg = getSeg("/livesim2/timescale_44100/testpic_2s/V300/40.m4s?nowMS=100000")
+	traf = seg.Fragments[0].Moof.Traf
+	require.Equal(t, uint64(80*44100), traf.Tfdt.BaseMediaDecodeTime())
+	require.Equal(t, uint64(2*44100), traf.Trun.Duration(0))
+
+	// $Time$ addressing uses the new timescale, and times that are not segment starts are not found
+	resp, body = testFullRequest(t, ts, "GET", "/livesim2/segtimeline_1/timescale_44100/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), fmt.Sprintf(`<S t="%d" d="%d" r="30">`, 38*44100, 2*44100))
+	seg = getSeg(fmt.Sprintf("/livesim2/segtimeline_1/timescale_44100/testpic_2s/V300/%d.m4s?nowMS=100000", 80*44100))
+	require.Equal(t, uint64(80*44100), seg.Fragments[0].Moof.Traf.Tfdt.BaseMediaDecodeTime())
+	resp, _ = testFullRequest(t, ts, "GET",
+		fmt.Sprintf("/livesim2/segtimeline_1/timescale_44100/testpic_2s/V300/%d.m4s?nowMS=100000", 80*44100+1), nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/timescale_0/testpic_2s/Manifest.mpd", nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/timescale_1000/chunkdur_0.5/ato_1.5/testpic_2s/Manifest.mpd", nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
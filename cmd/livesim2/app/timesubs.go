@@ -13,7 +13,6 @@ import (
 	"strconv"
 	"strings"
 	"text/template"
-	"time"
 
 	"github.com/Eyevinn/mp4ff/mp4"
 )
@@ -126,14 +125,16 @@ type StppTimeData struct {
 	Lang   string
 	Region int
 	Cues   []StppTimeCue
+	Sample *subsSample
 }
 
 // StppTimeCue is cue information to put in template.
 type StppTimeCue struct {
-	Id    string
-	Begin string
-	End   string
-	Msg   string
+	Id     string
+	Begin  string
+	End    string
+	Msg    string
+	Sample *subsSample
 }
 
 // writeTimeStppMediaSegment return true and tries to write a stpp time subtitle segment if URL matches
@@ -190,14 +191,22 @@ func writeTimeSubsMediaSegment(w http.ResponseWriter, cfg *ResponseConfig, a *as
 	dur := uint32(rep2SubsTime(uint64(refSegMeta.newDur), int(refSegMeta.timescale)))
 
 	utcTimeMS := baseMediaDecodeTime + uint64(cfg.StartTimeS*SUBS_TIME_TIMESCALE)
+	clock, err := newSubsClock(cfg)
+	if err != nil {
+		return true, err
+	}
+	var sample *subsSample
+	if cfg.TimeSubsSampleFlag {
+		sample = getSubsSample(lang)
+	}
 	var mediaSeg *mp4.MediaSegment
 	switch prefix {
 	case SUBS_STPP_PREFIX:
 		mediaSeg, err = createSubtitlesStppMediaSegment(refSegMeta.newNr, baseMediaDecodeTime, dur, lang, utcTimeMS,
-			tt, cfg.TimeSubsDurMS, cfg.TimeSubsRegion)
+			tt, cfg.TimeSubsDurMS, cfg.TimeSubsRegion, clock, sample)
 	default: // SUBS_WVTT_PREFIX
 		mediaSeg, err = createSubtitlesWvttMediaSegment(refSegMeta.newNr, baseMediaDecodeTime, dur, lang, utcTimeMS,
-			cfg.TimeSubsDurMS, cfg.TimeSubsRegion)
+			cfg.TimeSubsDurMS, cfg.TimeSubsRegion, clock, sample)
 	}
 	if isLast {
 		mediaSeg.Styp.AddCompatibleBrands([]string{"lmsg"})
@@ -216,10 +225,8 @@ func writeTimeSubsMediaSegment(w http.ResponseWriter, cfg *ResponseConfig, a *as
 }
 
 // makeSttpMessage makes a message for an stpptime cue.
-func makeStppMessage(lang string, utcMS, segNr int) string {
-	t := time.UnixMilli(int64(utcMS))
-	utc := t.UTC().Format(time.RFC3339)
-	return fmt.Sprintf("%s<br/>%s # %d", utc, lang, segNr)
+func makeStppMessage(lang string, utcMS, segNr int, clock subsClock) string {
+	return fmt.Sprintf("%s<br/>%s # %d", clock.format(utcMS), lang, segNr)
 }
 
 // msToTTMLTime returns a time that can be used in TTML.
@@ -272,7 +279,7 @@ func calcCueItvls(segStart, segDur, utcStart, cueDur int) []cueItvl {
 }
 
 func createSubtitlesStppMediaSegment(nr uint32, baseMediaDecodeTime uint64, dur uint32, lang string, utcTimeMS uint64,
-	tt *template.Template, timeSubsDurMS, region int) (*mp4.MediaSegment, error) {
+	tt *template.Template, timeSubsDurMS, region int, clock subsClock, sample *subsSample) (*mp4.MediaSegment, error) {
 	seg := mp4.NewMediaSegment()
 	frag, err := mp4.CreateFragment(nr, 1)
 	if err != nil {
@@ -284,13 +291,15 @@ func createSubtitlesStppMediaSegment(nr uint32, baseMediaDecodeTime uint64, dur
 		Lang:   lang,
 		Region: region,
 		Cues:   make([]StppTimeCue, 0, len(cueItvls)),
+		Sample: sample,
 	}
 	for i, ci := range cueItvls {
 		cue := StppTimeCue{
-			Id:    fmt.Sprintf("%d-%d", nr, i),
-			Begin: msToTTMLTime(ci.startMS),
-			End:   msToTTMLTime(ci.endMS),
-			Msg:   makeStppMessage(lang, ci.utcS*1000, int(nr)),
+			Id:     fmt.Sprintf("%d-%d", nr, i),
+			Begin:  msToTTMLTime(ci.startMS),
+			End:    msToTTMLTime(ci.endMS),
+			Msg:    makeStppMessage(lang, ci.utcS*1000, int(nr), clock),
+			Sample: sample,
 		}
 		stppd.Cues = append(stppd.Cues, cue)
 	}
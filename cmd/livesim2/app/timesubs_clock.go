@@ -0,0 +1,81 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // time zones must be available also in minimal containers
+)
+
+// timeSubsLayouts maps lower-case timesubslocale values to the layout of the time in the cues.
+var timeSubsLayouts = map[string]string{
+	"iso":   time.RFC3339,
+	"en-us": "01/02/2006 3:04:05 PM MST",
+	"en-gb": "02/01/2006 15:04:05 MST",
+	"de-de": "02.01.2006 15:04:05 MST",
+	"fr-fr": "02/01/2006 15:04:05 MST",
+	"sv-se": "2006-01-02 15:04:05 MST",
+	"ja-jp": "2006/01/02 15:04:05 MST",
+	"zh-cn": "2006/01/02 15:04:05 MST",
+}
+
+// timeZones caches loaded locations by name.
+var timeZones sync.Map
+
+// subsClock formats the wall-clock time in time subtitle cues.
+type subsClock struct {
+	loc    *time.Location
+	layout string
+}
+
+// utcSubsClock is the default RFC3339 UTC format.
+var utcSubsClock = subsClock{loc: time.UTC, layout: time.RFC3339}
+
+// newSubsClock returns the clock for the timesubstz and timesubslocale settings of cfg.
+func newSubsClock(cfg *ResponseConfig) (subsClock, error) {
+	c := utcSubsClock
+	if cfg.TimeSubsTimeZone != "" {
+		loc, err := loadTimeZone(cfg.TimeSubsTimeZone)
+		if err != nil {
+			return c, err
+		}
+		c.loc = loc
+	}
+	if cfg.TimeSubsLocale != "" {
+		layout, ok := timeSubsLayouts[strings.ToLower(cfg.TimeSubsLocale)]
+		if !ok {
+			locales := make([]string, 0, len(timeSubsLayouts))
+			for l := range timeSubsLayouts {
+				locales = append(locales, l)
+			}
+			sort.Strings(locales)
+			return c, fmt.Errorf("timesubslocale %q not one of %s", cfg.TimeSubsLocale, strings.Join(locales, ", "))
+		}
+		c.layout = layout
+	}
+	return c, nil
+}
+
+// loadTimeZone loads an IANA time zone like Europe/Stockholm.
+func loadTimeZone(name string) (*time.Location, error) {
+	if loc, ok := timeZones.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("timesubstz: %w", err)
+	}
+	timeZones.Store(name, loc)
+	return loc, nil
+}
+
+// format returns the wall-clock time utcMS in the clock's time zone and layout.
+func (c subsClock) format(utcMS int) string {
+	return time.UnixMilli(int64(utcMS)).In(c.loc).Format(c.layout)
+}
@@ -0,0 +1,39 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"strings"
+)
+
+// subsSample is sample text in a non-Latin script, added to time subtitles
+// to test the rendering of right-to-left and CJK text.
+type subsSample struct {
+	Lang string
+	Text string
+	RTL  bool
+}
+
+// subsSamples are the sample texts by primary language subtag.
+var subsSamples = map[string]subsSample{
+	"ar": {Text: "مرحبا بالعالم", RTL: true},
+	"fa": {Text: "سلام دنیا", RTL: true},
+	"he": {Text: "שלום עולם", RTL: true},
+	"ja": {Text: "こんにちは世界"},
+	"ko": {Text: "안녕하세요 세계"},
+	"zh": {Text: "你好，世界"},
+}
+
+// getSubsSample returns the sample text for lang, or nil if there is none.
+// The language is matched by its primary subtag, so zh-TW uses the zh sample.
+func getSubsSample(lang string) *subsSample {
+	primary, _, _ := strings.Cut(lang, "-")
+	s, ok := subsSamples[strings.ToLower(primary)]
+	if !ok {
+		return nil
+	}
+	s.Lang = lang
+	return &s
+}
@@ -6,7 +6,6 @@ package app
 
 import (
 	"bytes"
-	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -14,7 +13,6 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/Dash-Industry-Forum/livesim2/pkg/logging"
 	"github.com/Eyevinn/mp4ff/bits"
 	"github.com/Eyevinn/mp4ff/mp4"
 	"github.com/stretchr/testify/assert"
@@ -27,6 +25,8 @@ func TestStppTimeMessage(t *testing.T) {
 		lang   string
 		utcMS  int
 		segNr  int
+		tz     string
+		locale string
 		wanted string
 	}{
 		{
@@ -35,12 +35,41 @@ func TestStppTimeMessage(t *testing.T) {
 			segNr:  0,
 			wanted: "1970-01-01T00:00:00Z<br/>en # 0",
 		},
+		{
+			lang:   "sv",
+			utcMS:  1_700_000_000_000,
+			segNr:  3,
+			tz:     "Europe/Stockholm",
+			wanted: "2023-11-14T23:13:20+01:00<br/>sv # 3",
+		},
+		{
+			lang:   "en",
+			utcMS:  1_700_000_000_000,
+			segNr:  3,
+			tz:     "America/New_York",
+			locale: "en-US",
+			wanted: "11/14/2023 5:13:20 PM EST<br/>en # 3",
+		},
+		{
+			lang:   "de",
+			utcMS:  1_690_000_000_000,
+			segNr:  3,
+			tz:     "Europe/Berlin",
+			locale: "de-de",
+			wanted: "22.07.2023 06:26:40 CEST<br/>de # 3",
+		},
 	}
 
 	for _, tc := range testCases {
-		got := makeStppMessage(tc.lang, tc.utcMS, tc.segNr)
+		clock, err := newSubsClock(&ResponseConfig{TimeSubsTimeZone: tc.tz, TimeSubsLocale: tc.locale})
+		require.NoError(t, err)
+		got := makeStppMessage(tc.lang, tc.utcMS, tc.segNr, clock)
 		require.Equal(t, tc.wanted, got)
 	}
+	_, err := newSubsClock(&ResponseConfig{TimeSubsTimeZone: "Mars/Olympus"})
+	require.Error(t, err)
+	_, err = newSubsClock(&ResponseConfig{TimeSubsLocale: "xx-XX"})
+	require.Error(t, err)
 }
 
 func TestMSToTTMLTime(t *testing.T) {
@@ -247,17 +276,7 @@ Sample 3, pts=3601600, dur=400
 `
 
 func TestTimeSubsInitSegment(t *testing.T) {
-	cfg := ServerConfig{
-		VodRoot:   "testdata/assets",
-		TimeoutS:  0,
-		LogFormat: logging.LogDiscard,
-	}
-	err := logging.InitSlog(cfg.LogLevel, cfg.LogFormat)
-	require.NoError(t, err)
-	server, err := SetupServer(context.Background(), &cfg)
-	require.NoError(t, err)
-	ts := httptest.NewServer(server.Router)
-	defer ts.Close()
+	_, ts := newTestServer(t, nil)
 	testCases := []struct {
 		desc               string
 		asset              string
@@ -338,17 +357,7 @@ func testFullRequest(t *testing.T, ts *httptest.Server, method, path string, req
 }
 
 func TestTimeSubsMediaSegment(t *testing.T) {
-	cfg := ServerConfig{
-		VodRoot:   "testdata/assets",
-		TimeoutS:  0,
-		LogFormat: logging.LogDiscard,
-	}
-	err := logging.InitSlog(cfg.LogLevel, cfg.LogFormat)
-	require.NoError(t, err)
-	server, err := SetupServer(context.Background(), &cfg)
-	require.NoError(t, err)
-	ts := httptest.NewServer(server.Router)
-	defer ts.Close()
+	_, ts := newTestServer(t, nil)
 	testCases := []struct {
 		desc               string
 		asset              string
@@ -443,3 +452,78 @@ func genWvttCueText(fss []mp4.FullSample) (string, error) {
 	}
 	return b.String(), nil
 }
+
+func TestTimeSubsSample(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+	testCases := []struct {
+		desc     string
+		url      string
+		contains []string
+		missing  []string
+	}{
+		{
+			desc:     "stpp rtl",
+			url:      "/livesim2/timesubsstpp_en,ar/timesubssample_1/testpic_2s/timestpp-ar/0.m4s?nowMS=10000",
+			contains: []string{`<style xml:id="s3"`, `xml:lang="ar" tts:direction="rtl" tts:unicodeBidi="embed">مرحبا بالعالم</span>`},
+		},
+		{
+			desc:     "stpp cjk with region subtag",
+			url:      "/livesim2/timesubsstpp_ja-JP/timesubssample_1/testpic_2s/timestpp-ja-JP/0.m4s?nowMS=10000",
+			contains: []string{`<span style="s3" xml:lang="ja-JP">こんにちは世界</span>`},
+			missing:  []string{"tts:direction"},
+		},
+		{
+			desc:    "stpp latin has no sample",
+			url:     "/livesim2/timesubsstpp_en,ar/timesubssample_1/testpic_2s/timestpp-en/0.m4s?nowMS=10000",
+			missing: []string{`s3`},
+		},
+		{
+			desc:    "stpp without sample parameter",
+			url:     "/livesim2/timesubsstpp_en,ar/testpic_2s/timestpp-ar/0.m4s?nowMS=10000",
+			missing: []string{`s3`},
+		},
+		{
+			desc:     "wvtt rtl",
+			url:      "/livesim2/timesubswvtt_he/timesubssample_1/testpic_2s/timewvtt-he/0.m4s?nowMS=10000",
+			contains: []string{"he # 0\n<lang he>שלום עולם</lang>"},
+		},
+		{
+			desc:     "stpp time zone",
+			url:      "/livesim2/timesubsstpp_sv/timesubstz_Europe.Stockholm/testpic_2s/timestpp-sv/0.m4s?nowMS=10000",
+			contains: []string{"1970-01-01T01:00:00+01:00<br/>sv # 0"},
+		},
+		{
+			desc:     "wvtt time zone and locale",
+			url:      "/livesim2/timesubswvtt_ja/timesubstz_Asia.Tokyo/timesubslocale_ja-JP/testpic_2s/timewvtt-ja/0.m4s?nowMS=10000",
+			contains: []string{"1970/01/01 09:00:00 JST\nja # 0"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			resp, body := testFullRequest(t, ts, "GET", tc.url, nil)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			sr := bits.NewFixedSliceReader(body)
+			mp4d, err := mp4.DecodeFileSR(sr)
+			require.NoError(t, err)
+			fss, err := mp4d.Segments[0].Fragments[0].GetFullSamples(nil)
+			require.NoError(t, err)
+			var payload string
+			for _, fs := range fss {
+				payload += string(fs.Data)
+			}
+			for _, c := range tc.contains {
+				require.Contains(t, payload, c)
+			}
+			for _, m := range tc.missing {
+				require.NotContains(t, payload, m)
+			}
+		})
+	}
+	for _, url := range []string{
+		"/livesim2/timesubsstpp_sv/timesubstz_Mars.Olympus/testpic_2s/Manifest.mpd?nowMS=10000",
+		"/livesim2/timesubsstpp_sv/timesubslocale_xx-XX/testpic_2s/Manifest.mpd?nowMS=10000",
+	} {
+		resp, _ := testFullRequest(t, ts, "GET", url, nil)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, url)
+	}
+}
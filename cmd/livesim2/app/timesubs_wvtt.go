@@ -6,7 +6,6 @@ package app
 
 import (
 	"fmt"
-	"time"
 
 	"github.com/Eyevinn/mp4ff/bits"
 	"github.com/Eyevinn/mp4ff/mp4"
@@ -36,11 +35,14 @@ type WvttTimeCue struct {
 }
 
 // makeWvttMessage makes a message for an stpptime cue.
-func makeWvttCuePayload(lang string, region, utcMS, segNr int) []byte {
-	t := time.UnixMilli(int64(utcMS))
-	utc := t.UTC().Format(time.RFC3339)
+// A sample text is added on a separate line in a lang span. Its direction is given by the Unicode
+// bidirectional algorithm, so right-to-left text needs no extra markup.
+func makeWvttCuePayload(lang string, region, utcMS, segNr int, clock subsClock, sample *subsSample) []byte {
 	pl := mp4.PaylBox{
-		CueText: fmt.Sprintf("%s\n%s # %d", utc, lang, segNr),
+		CueText: fmt.Sprintf("%s\n%s # %d", clock.format(utcMS), lang, segNr),
+	}
+	if sample != nil {
+		pl.CueText += fmt.Sprintf("\n<lang %s>%s</lang>", sample.Lang, sample.Text)
 	}
 	vttc := mp4.VttcBox{}
 	if region == 1 {
@@ -59,7 +61,7 @@ func makeWvttCuePayload(lang string, region, utcMS, segNr int) []byte {
 }
 
 func createSubtitlesWvttMediaSegment(nr uint32, baseMediaDecodeTime uint64, dur uint32, lang string, utcTimeMS uint64,
-	timeSubsDurMS, region int) (*mp4.MediaSegment, error) {
+	timeSubsDurMS, region int, clock subsClock, sample *subsSample) (*mp4.MediaSegment, error) {
 	seg := mp4.NewMediaSegment()
 	frag, err := mp4.CreateFragment(nr, 1)
 	if err != nil {
@@ -72,7 +74,7 @@ func createSubtitlesWvttMediaSegment(nr uint32, baseMediaDecodeTime uint64, dur
 	for _, ci := range cueItvls {
 		start := ci.startMS
 		end := ci.endMS
-		cuePL := makeWvttCuePayload(lang, region, ci.utcS*1000, int(nr))
+		cuePL := makeWvttCuePayload(lang, region, ci.utcS*1000, int(nr), clock, sample)
 		if start > int(currEnd) {
 			frag.AddFullSample(fullSample(int(currEnd), start, vtte))
 		}
@@ -0,0 +1,109 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/Dash-Industry-Forum/livesim2/pkg/logging"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// traceparentHeader is the W3C Trace Context header, https://www.w3.org/TR/trace-context/.
+const traceparentHeader = "traceparent"
+
+type traceKey struct{}
+
+// traceContext is the part of a W3C trace context that is kept along a chain of requests.
+// Every outgoing hop gets a new parent ID.
+type traceContext struct {
+	traceID string // 32 lowercase hex digits
+	flags   string // 2 lowercase hex digits
+}
+
+// newTraceContext returns a new sampled trace context with a random trace ID.
+func newTraceContext() traceContext {
+	return traceContext{traceID: randomHex(16), flags: "01"}
+}
+
+// parseTraceparent parses a traceparent header value like
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+func parseTraceparent(tp string) (traceContext, bool) {
+	parts := strings.Split(tp, "-")
+	if len(parts) < 4 || !isLowerHex(parts[0], 2) || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return traceContext{}, false
+	}
+	traceID, parentID, flags := parts[1], parts[2], parts[3]
+	if !isLowerHex(traceID, 32) || !isLowerHex(parentID, 16) || !isLowerHex(flags, 2) {
+		return traceContext{}, false
+	}
+	if strings.Trim(traceID, "0") == "" || strings.Trim(parentID, "0") == "" {
+		return traceContext{}, false
+	}
+	return traceContext{traceID: traceID, flags: flags}, true
+}
+
+// child returns a traceparent value with a new parent ID, and that parent ID.
+func (tc traceContext) child() (traceparent, parentID string) {
+	parentID = randomHex(8)
+	return "00-" + tc.traceID + "-" + parentID + "-" + tc.flags, parentID
+}
+
+// setTraceHeaders sets traceparent and X-Request-Id headers for an outgoing request.
+// The request ID is the new parent ID, which is returned for logging.
+func setTraceHeaders(req *http.Request, tc traceContext) string {
+	tp, parentID := tc.child()
+	req.Header.Set(traceparentHeader, tp)
+	req.Header.Set(middleware.RequestIDHeader, parentID)
+	return parentID
+}
+
+// traceFromContext returns the trace context stored by traceMiddleware.
+func traceFromContext(ctx context.Context) (traceContext, bool) {
+	tc, ok := ctx.Value(traceKey{}).(traceContext)
+	return tc, ok
+}
+
+// traceMiddleware continues the trace of an incoming traceparent header, or starts a new one.
+// The request ID and a traceparent for this hop are sent back as response headers.
+// The request header is set if missing, so that the trace ID is included in the logs.
+func traceMiddleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		tc, ok := parseTraceparent(r.Header.Get(traceparentHeader))
+		if !ok {
+			tc = newTraceContext()
+			tp, _ := tc.child()
+			r.Header.Set(traceparentHeader, tp)
+		}
+		tp, _ := tc.child()
+		w.Header().Set(traceparentHeader, tp)
+		w.Header().Set(middleware.RequestIDHeader, logging.GetRequestID(r))
+		ctx := context.WithValue(r.Context(), traceKey{}, tc)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+func isLowerHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func randomHex(nrBytes int) string {
+	b := make([]byte, nrBytes)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,65 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	cases := []struct {
+		tp      string
+		traceID string
+		ok      bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "4bf92f3577b34da6a3ce929d0e0e4736", true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra", "4bf92f3577b34da6a3ce929d0e0e4736", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", "", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", "", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", "", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", "", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "", false},
+		{"", "", false},
+	}
+	for _, c := range cases {
+		tc, ok := parseTraceparent(c.tp)
+		require.Equal(t, c.ok, ok, c.tp)
+		require.Equal(t, c.traceID, tc.traceID, c.tp)
+	}
+	tc := newTraceContext()
+	tp, parentID := tc.child()
+	tc2, ok := parseTraceparent(tp)
+	require.True(t, ok)
+	require.Equal(t, tc, tc2)
+	require.Len(t, parentID, 16)
+}
+
+func TestTraceHeaders(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	inTP := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req, err := http.NewRequest("GET", ts.URL+"/livesim2/testpic_2s/Manifest.mpd", nil)
+	require.NoError(t, err)
+	req.Header.Set(traceparentHeader, inTP)
+	req.Header.Set("X-Request-Id", "client-42")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "client-42", resp.Header.Get("X-Request-Id"))
+	outTP := resp.Header.Get(traceparentHeader)
+	tc, ok := parseTraceparent(outTP)
+	require.True(t, ok)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", tc.traceID)
+	require.NotEqual(t, inTP, outTP)
+
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/testpic_2s/Manifest.mpd", nil)
+	require.NotEmpty(t, resp.Header.Get("X-Request-Id"))
+	_, ok = parseTraceparent(resp.Header.Get(traceparentHeader))
+	require.True(t, ok)
+}
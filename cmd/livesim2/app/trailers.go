@@ -0,0 +1,81 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"net/http"
+	"strconv"
+)
+
+const (
+	trailerCount  = "count"
+	trailerDigest = "digest"
+	// chunkCountTrailer is the number of CMAF chunks in a chunked segment response
+	chunkCountTrailer = "X-Livesim-Chunk-Count"
+	// contentDigestTrailer is the SHA-256 digest of the response body as defined in RFC 9530
+	contentDigestTrailer = "Content-Digest"
+)
+
+var trailerNames = []string{trailerCount, trailerDigest}
+
+// trailerWriter sends HTTP trailers at the end of a chunked segment response.
+// The trailers are declared before the first chunk is written.
+type trailerWriter struct {
+	http.ResponseWriter
+	names []string
+	h     hash.Hash
+}
+
+func newTrailerWriter(w http.ResponseWriter, names []string) *trailerWriter {
+	tw := trailerWriter{ResponseWriter: w, names: names}
+	for _, name := range names {
+		switch name {
+		case trailerCount:
+			w.Header().Add("Trailer", chunkCountTrailer)
+		case trailerDigest:
+			w.Header().Add("Trailer", contentDigestTrailer)
+			tw.h = sha256.New()
+		}
+	}
+	return &tw
+}
+
+func (tw *trailerWriter) Write(p []byte) (int, error) {
+	if tw.h != nil {
+		tw.h.Write(p)
+	}
+	return tw.ResponseWriter.Write(p)
+}
+
+func (tw *trailerWriter) Flush() {
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// finish sets the trailer values after nrChunks chunks have been written.
+func (tw *trailerWriter) finish(nrChunks int) {
+	for _, name := range tw.names {
+		switch name {
+		case trailerCount:
+			tw.Header().Set(chunkCountTrailer, strconv.Itoa(nrChunks))
+		case trailerDigest:
+			tw.Header().Set(contentDigestTrailer, reprDigest(tw.h.Sum(nil)))
+		}
+	}
+}
+
+// errChunkAbort is returned after all chunks are written if the response should be aborted.
+var errChunkAbort = errors.New("chunked response aborted on purpose")
+
+// abortChunkedResponse ends the response without the terminating zero-length chunk.
+// The server then closes the connection (HTTP/1.1) or resets the stream (HTTP/2),
+// and trailers are not sent.
+func abortChunkedResponse() {
+	panic(http.ErrAbortHandler)
+}
@@ -0,0 +1,39 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrailers(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	segURL := "/livesim2/trailers_count-digest/chunkdur_0.5/ato_1.5/testpic_2s/V300/50.m4s?nowMS=110000"
+	resp, body := testFullRequest(t, ts, "GET", segURL, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	sum := sha256.Sum256(body)
+	require.Equal(t, reprDigest(sum[:]), resp.Trailer.Get(contentDigestTrailer))
+	require.Equal(t, "4", resp.Trailer.Get(chunkCountTrailer))
+
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/trailers_size/chunkdur_0.5/testpic_2s/V300/50.m4s?nowMS=110000", nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/chunkabort_1/testpic_2s/V300/50.m4s?nowMS=110000", nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// The chunks are received, but the response ends without the terminating chunk
+	resp, err := http.Get(ts.URL + "/livesim2/chunkabort_1/chunkdur_0.5/ato_1.5/testpic_2s/V300/50.m4s?nowMS=110000")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	aborted, err := io.ReadAll(resp.Body)
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	require.Equal(t, body, aborted)
+}
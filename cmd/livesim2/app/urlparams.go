@@ -0,0 +1,178 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// urlParamKeys lists the keys of all key_value URL parameters handled by processURLCfg.
+var urlParamKeys = []string{
+	"start", "ast", "stop", "startrel", "stoprel", "dur", "timeoffset", "init", "tsbd", "mup",
+	"modulo", "tfdt", "cont", "periods", "xlink", "etp", "etpDuration", "insertad", "continuous",
+	"segtimeline", "segtimelinenr", "segtimelineloss", "peroff", "scte35", "scte35type",
+	"utc", "snr", "enr", "ato", "ltgt", "spd", "sidx", "atc", "llbroken",
+	"chunkdur", "chunkcadence", "lookahead", "trailers", "chunkabort",
+	"timesubsstpp", "timesubswvtt", "timesubsdur", "timesubsreg", "timesubssample",
+	"timesubstz", "timesubslocale", "timecode", "ccstrip",
+	"statuscode", "traffic", "clockskew", "dateskew", "stlinject",
+	"repchange", "repidchange", "quota", "ssai", "ladder",
+	"slate", "blackout", "programs", "id3", "metrics",
+	"segdur", "loop", "timescale", "largetfdt",
+	"viewpoints", "hdr", "bwdrift", "durdrift", "sizevar",
+	"mpdinflate", "mpdmin", "mpdquirks", "mpdsign", "device", "ab",
+	"integrity", "servertiming", "latencyprobe",
+	"mpdstall", "burst", "early", "redirect", "xhost", "pubtime", "mpdevents",
+	"chaos", "wasm", "throttle",
+	"drm", "eccp", "drmmix", "pssh", "license", "session", "patch",
+}
+
+// repeatableURLParams may occur more than once in a URL.
+var repeatableURLParams = map[string]bool{
+	"dur": true,
+}
+
+// urlParamIssue describes a problematic part of a livesim2 URL.
+type urlParamIssue struct {
+	Part       string `json:"part"`
+	Problem    string `json:"problem"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+func (i urlParamIssue) String() string {
+	if i.Suggestion != "" {
+		return fmt.Sprintf("%q: %s (did you mean %q?)", i.Part, i.Problem, i.Suggestion)
+	}
+	return fmt.Sprintf("%q: %s", i.Part, i.Problem)
+}
+
+// validateURLParams checks for repeated parameters and for parameters that were not
+// recognized and therefore silently ended up in the content part of the URL.
+// It returns nil if strict checking is disabled or no problem is found.
+func (s *Server) validateURLParams(log *slog.Logger, cfg *ResponseConfig) *errorWithHttpType {
+	if errHT := s.checkScaledResponseConfig(log, cfg); errHT != nil {
+		return errHT
+	}
+	if s.Cfg.LaxURLParams {
+		return nil
+	}
+	issues, reason := findURLParamIssues(cfg, func(contentPart string) bool {
+		if _, _, ok := s.findChannel(contentPart); ok {
+			return true
+		}
+		if _, _, ok := s.findProxy(contentPart); ok {
+			return true
+		}
+		_, ok := s.assetMgr.findAsset(contentPart)
+		return ok
+	})
+	if len(issues) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(issues))
+	for i, iss := range issues {
+		msgs[i] = iss.String()
+	}
+	msg := "bad URL parameters: " + strings.Join(msgs, ", ")
+	errHT := generateAndLogHttpError(log, msg, http.StatusBadRequest, reason)
+	errHT.issues = issues
+	return errHT
+}
+
+// findURLParamIssues returns the issues found, and the corresponding reason code.
+func findURLParamIssues(cfg *ResponseConfig, isAsset func(contentPart string) bool) ([]urlParamIssue, string) {
+	var issues []urlParamIssue
+	reason := reasonBadCombination
+	seen := make(map[string]bool)
+	for _, part := range cfg.URLParts[2:cfg.URLContentIdx] {
+		key, _, _ := strings.Cut(part, "_")
+		if seen[key] && !repeatableURLParams[key] {
+			issues = append(issues, urlParamIssue{Part: part, Problem: "repeated parameter"})
+		}
+		seen[key] = true
+	}
+	contentParts := cfg.URLParts[cfg.URLContentIdx:]
+	if isAsset(strings.Join(contentParts, "/")) {
+		return issues, reason
+	}
+	// Look for an asset further in. The parts before it were not recognized.
+	for j := 1; j < len(contentParts); j++ {
+		if !isAsset(strings.Join(contentParts[j:], "/")) {
+			continue
+		}
+		for k, part := range contentParts[:j] {
+			key, _, hasVal := strings.Cut(part, "_")
+			iss := urlParamIssue{Part: part}
+			switch {
+			case k > 0 && isURLParamKey(key):
+				iss.Problem = "ignored since it follows an unknown part"
+			case isURLParamKey(key) && !hasVal:
+				iss.Problem = "parameter without value"
+				iss.Suggestion = key + "_1"
+			case hasVal:
+				iss.Problem = "unknown parameter"
+				if sugg := suggestURLParamKey(key); sugg != "" {
+					_, val, _ := strings.Cut(part, "_")
+					iss.Suggestion = sugg + "_" + val
+				}
+			default:
+				iss.Problem = "unknown path part"
+				if sugg := suggestURLParamKey(key); sugg != "" {
+					iss.Suggestion = sugg + "_1"
+				}
+			}
+			issues = append(issues, iss)
+		}
+		reason = reasonUnknownParameter
+		break
+	}
+	return issues, reason
+}
+
+func isURLParamKey(key string) bool {
+	for _, k := range urlParamKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// suggestURLParamKey returns the known key closest to key, or "" if none is close.
+func suggestURLParamKey(key string) string {
+	best, bestDist := "", 3
+	lowKey := strings.ToLower(key)
+	for _, k := range urlParamKeys {
+		d := editDistance(lowKey, strings.ToLower(k))
+		if d < bestDist {
+			best, bestDist = k, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,114 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrictURLParams(t *testing.T) {
+	testCases := []struct {
+		desc         string
+		url          string
+		lax          bool
+		wantedStatus int
+		wantedReason string
+		wantedIssues []urlParamIssue
+	}{
+		{
+			desc:         "valid",
+			url:          "/livesim2/segtimeline_1/tsbd_30/testpic_2s/Manifest.mpd",
+			wantedStatus: http.StatusOK,
+		},
+		{
+			desc:         "repeated dur is fine",
+			url:          "/livesim2/periods_60/dur_10/dur_20/testpic_2s/Manifest.mpd",
+			wantedStatus: http.StatusOK,
+		},
+		{
+			desc:         "misspelled parameter",
+			url:          "/livesim2/segtimelin_1/testpic_2s/Manifest.mpd",
+			wantedStatus: http.StatusBadRequest,
+			wantedReason: reasonUnknownParameter,
+			wantedIssues: []urlParamIssue{
+				{Part: "segtimelin_1", Problem: "unknown parameter", Suggestion: "segtimeline_1"},
+			},
+		},
+		{
+			desc:         "misspelled parameter followed by parameter",
+			url:          "/livesim2/TSBD_30/snr_0/testpic_2s/Manifest.mpd",
+			wantedStatus: http.StatusBadRequest,
+			wantedReason: reasonUnknownParameter,
+			wantedIssues: []urlParamIssue{
+				{Part: "TSBD_30", Problem: "unknown parameter", Suggestion: "tsbd_30"},
+				{Part: "snr_0", Problem: "ignored since it follows an unknown part"},
+			},
+		},
+		{
+			desc:         "parameter without value",
+			url:          "/livesim2/segtimeline/testpic_2s/Manifest.mpd",
+			wantedStatus: http.StatusBadRequest,
+			wantedReason: reasonUnknownParameter,
+			wantedIssues: []urlParamIssue{
+				{Part: "segtimeline", Problem: "parameter without value", Suggestion: "segtimeline_1"},
+			},
+		},
+		{
+			desc:         "repeated parameter",
+			url:          "/livesim2/tsbd_30/tsbd_40/testpic_2s/Manifest.mpd",
+			wantedStatus: http.StatusBadRequest,
+			wantedReason: reasonBadCombination,
+			wantedIssues: []urlParamIssue{
+				{Part: "tsbd_40", Problem: "repeated parameter"},
+			},
+		},
+		{
+			desc:         "lax mode ignores unknown parameter",
+			url:          "/livesim2/segtimelin_1/testpic_2s/Manifest.mpd",
+			lax:          true,
+			wantedStatus: http.StatusNotFound,
+			wantedReason: reasonNotFound,
+		},
+		{
+			desc:         "lax mode uses last repeated parameter",
+			url:          "/livesim2/tsbd_30/tsbd_40/testpic_2s/Manifest.mpd",
+			lax:          true,
+			wantedStatus: http.StatusOK,
+		},
+	}
+
+	for _, lax := range []bool{false, true} {
+		_, ts := newTestServer(t, func(cfg *ServerConfig) {
+			cfg.LaxURLParams = lax
+		})
+		for _, tc := range testCases {
+			if tc.lax != lax {
+				continue
+			}
+			t.Run(tc.desc, func(t *testing.T) {
+				resp, body := testFullRequest(t, ts, "GET", tc.url+"?nowMS=100000", nil)
+				require.Equal(t, tc.wantedStatus, resp.StatusCode)
+				if tc.wantedStatus == http.StatusOK {
+					return
+				}
+				var p problemDetails
+				require.NoError(t, json.Unmarshal(body, &p))
+				require.Equal(t, tc.wantedReason, p.Reason)
+				require.Equal(t, tc.wantedIssues, p.Issues)
+			})
+		}
+		ts.Close()
+	}
+}
+
+func TestSuggestURLParamKey(t *testing.T) {
+	require.Equal(t, "segtimeline", suggestURLParamKey("segtimelin"))
+	require.Equal(t, "etpDuration", suggestURLParamKey("etpduration"))
+	require.Equal(t, "", suggestURLParamKey("testpic"))
+}
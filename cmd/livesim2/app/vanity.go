@@ -0,0 +1,71 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// VanityPath maps a public path to a full livesim2 URL path.
+// If From ends with "/", it is a prefix mapping and To should also end with "/".
+// Otherwise, it is an exact mapping.
+type VanityPath struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// vanityMapper rewrites request paths according to a set of VanityPaths.
+type vanityMapper struct {
+	exact    map[string]string
+	prefixes []VanityPath // sorted with longest From first
+}
+
+func newVanityMapper(paths []VanityPath) (*vanityMapper, error) {
+	vm := vanityMapper{exact: make(map[string]string)}
+	for _, vp := range paths {
+		if !strings.HasPrefix(vp.From, "/") || !strings.HasPrefix(vp.To, "/") {
+			return nil, fmt.Errorf("vanity path %q -> %q: paths must start with /", vp.From, vp.To)
+		}
+		if strings.HasSuffix(vp.From, "/") {
+			if !strings.HasSuffix(vp.To, "/") {
+				return nil, fmt.Errorf("vanity path prefix %q -> %q: target must end with /", vp.From, vp.To)
+			}
+			vm.prefixes = append(vm.prefixes, vp)
+			continue
+		}
+		vm.exact[vp.From] = vp.To
+	}
+	sort.Slice(vm.prefixes, func(i, j int) bool {
+		return len(vm.prefixes[i].From) > len(vm.prefixes[j].From)
+	})
+	return &vm, nil
+}
+
+// mapPath returns the mapped path and true if there is a match.
+func (vm *vanityMapper) mapPath(p string) (string, bool) {
+	if to, ok := vm.exact[p]; ok {
+		return to, true
+	}
+	for _, vp := range vm.prefixes {
+		if strings.HasPrefix(p, vp.From) {
+			return vp.To + p[len(vp.From):], true
+		}
+	}
+	return p, false
+}
+
+// middleware rewrites the request path before routing.
+func (vm *vanityMapper) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p, ok := vm.mapPath(r.URL.Path); ok {
+			r.URL.Path = p
+			r.URL.RawPath = ""
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,48 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVanityPaths(t *testing.T) {
+	_, ts := newTestServer(t, func(cfg *ServerConfig) {
+		cfg.VanityPaths = []VanityPath{
+			{From: "/channels/sport1/manifest.mpd", To: "/livesim2/segtimeline_1/testpic_2s/Manifest.mpd"},
+			{From: "/channels/sport1/", To: "/livesim2/segtimeline_1/testpic_2s/"},
+			{From: "/channels/", To: "/livesim2/"},
+		}
+	})
+
+	testCases := []struct {
+		desc             string
+		url              string
+		wantedStatusCode int
+		wantedInBody     string
+	}{
+		{"exact mapping", "/channels/sport1/manifest.mpd?nowMS=100000", http.StatusOK, "<SegmentTimeline>"},
+		{"prefix mapping", "/channels/sport1/V300/init.mp4", http.StatusOK, ""},
+		{"shorter prefix", "/channels/testpic_2s/Manifest.mpd?nowMS=100000", http.StatusOK, "startNumber"},
+		{"unmapped path", "/livesim2/testpic_2s/Manifest.mpd?nowMS=100000", http.StatusOK, "startNumber"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			resp, body := testFullRequest(t, ts, "GET", tc.url, nil)
+			require.Equal(t, tc.wantedStatusCode, resp.StatusCode)
+			require.Contains(t, string(body), tc.wantedInBody)
+		})
+	}
+}
+
+func TestVanityPathValidation(t *testing.T) {
+	_, err := newVanityMapper([]VanityPath{{From: "/a/", To: "/livesim2/testpic_2s"}})
+	require.Error(t, err)
+	_, err = newVanityMapper([]VanityPath{{From: "a", To: "/livesim2/testpic_2s"}})
+	require.Error(t, err)
+}
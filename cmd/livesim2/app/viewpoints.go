@@ -0,0 +1,69 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"regexp"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+)
+
+const (
+	// viewpointScheme identifies the camera angle of an AdaptationSet
+	viewpointScheme = "urn:livesim2:viewpoint:2024"
+	// maxViewpoints is the maximal number of camera angles
+	maxViewpoints = 8
+)
+
+// viewpointRepIDRegexp matches the suffix of the Representation ids of alternate camera angles.
+var viewpointRepIDRegexp = regexp.MustCompile(`_cam[2-8](/|\.|_|$)`)
+
+// addViewpoints exposes every video AdaptationSet as n camera angles with Viewpoint descriptors cam1 to camN.
+// The original AdaptationSet is cam1, and the others are copies with Role alternate
+// and Representation ids with suffix _cam<k>, so they have separate segment URLs.
+func addViewpoints(mpd *m.MPD, n int) {
+	for _, p := range mpd.Periods {
+		maxID := uint32(0)
+		var videoASs []*m.AdaptationSetType
+		for _, as := range p.AdaptationSets {
+			if as.Id != nil {
+				maxID = max(maxID, *as.Id)
+			}
+			if as.ContentType == "video" {
+				videoASs = append(videoASs, as)
+			}
+		}
+		for _, orig := range videoASs {
+			orig.Viewpoints = append(orig.Viewpoints, m.NewDescriptor(viewpointScheme, "cam1", ""))
+			for k := 2; k <= n; k++ {
+				as := orig.Clone()
+				maxID++
+				as.Id = Ptr(maxID)
+				as.Viewpoints[len(as.Viewpoints)-1] = m.NewDescriptor(viewpointScheme, fmt.Sprintf("cam%d", k), "")
+				roles := as.Roles[:0]
+				for _, r := range as.Roles {
+					if r.SchemeIdUri != "urn:mpeg:dash:role:2011" {
+						roles = append(roles, r)
+					}
+				}
+				as.Roles = append(roles, m.NewRole("alternate"))
+				for _, rep := range as.Representations {
+					rep.Id = fmt.Sprintf("%s_cam%d", rep.Id, k)
+				}
+				p.AppendAdaptationSet(as)
+			}
+		}
+	}
+}
+
+// origViewpointSegmentPart maps a segment path of an alternate camera angle to the original one.
+func origViewpointSegmentPart(segmentPart string) string {
+	loc := viewpointRepIDRegexp.FindStringIndex(segmentPart)
+	if loc == nil {
+		return segmentPart
+	}
+	return segmentPart[:loc[0]] + segmentPart[loc[0]+len("_camN"):]
+}
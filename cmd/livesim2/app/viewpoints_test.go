@@ -0,0 +1,76 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	m "github.com/Eyevinn/dash-mpd/mpd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestViewpoints(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+
+	resp, body := testFullRequest(t, ts, "GET", "/livesim2/viewpoints_3/testpic_2s/Manifest.mpd?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	mpd, err := m.ReadFromString(string(body))
+	require.NoError(t, err)
+	var videoASs []*m.AdaptationSetType
+	ids := make(map[uint32]bool)
+	for _, as := range mpd.Periods[0].AdaptationSets {
+		require.False(t, ids[*as.Id], "unique AdaptationSet ids")
+		ids[*as.Id] = true
+		if as.ContentType == "video" {
+			videoASs = append(videoASs, as)
+		}
+	}
+	require.Len(t, videoASs, 3)
+	for i, as := range videoASs {
+		require.Len(t, as.Viewpoints, 1)
+		require.Equal(t, m.AnyURI(viewpointScheme), as.Viewpoints[0].SchemeIdUri)
+		require.Equal(t, fmt.Sprintf("cam%d", i+1), as.Viewpoints[0].Value)
+		require.Len(t, as.Roles, 1)
+		wantedRole, wantedRepID := "main", "V300"
+		if i > 0 {
+			wantedRole, wantedRepID = "alternate", fmt.Sprintf("V300_cam%d", i+1)
+		}
+		require.Equal(t, wantedRole, as.Roles[0].Value)
+		require.Equal(t, wantedRepID, as.Representations[0].Id)
+	}
+
+	_, origInit := testFullRequest(t, ts, "GET", "/livesim2/viewpoints_3/testpic_2s/V300/init.mp4", nil)
+	resp, camInit := testFullRequest(t, ts, "GET", "/livesim2/viewpoints_3/testpic_2s/V300_cam3/init.mp4", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, origInit, camInit)
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/viewpoints_3/testpic_2s/V300_cam2/40.m4s?nowMS=100000", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	for _, url := range []string{
+		"/livesim2/viewpoints_1/testpic_2s/Manifest.mpd?nowMS=100000",
+		"/livesim2/viewpoints_9/testpic_2s/Manifest.mpd?nowMS=100000",
+	} {
+		resp, _ = testFullRequest(t, ts, "GET", url, nil)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, url)
+	}
+}
+
+func TestOrigViewpointSegmentPart(t *testing.T) {
+	testCases := []struct {
+		segmentPart string
+		wanted      string
+	}{
+		{"/V300_cam2/init.mp4", "/V300/init.mp4"},
+		{"/V300_cam8_10.m4s", "/V300_10.m4s"},
+		{"/video_cam3.mp4", "/video.mp4"},
+		{"/V300/10.m4s", "/V300/10.m4s"},
+		{"/V300_cam9/10.m4s", "/V300_cam9/10.m4s"},
+	}
+	for _, tc := range testCases {
+		require.Equal(t, tc.wanted, origViewpointSegmentPart(tc.segmentPart))
+	}
+}
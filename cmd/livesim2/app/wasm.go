@@ -0,0 +1,289 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+const (
+	// wasmHostModule is the module name of the host functions imported by WASM plugins.
+	wasmHostModule = "livesim"
+	// wasmMemoryLimitPages limits plugin memory to 64MiB.
+	wasmMemoryLimitPages = 1024
+	// wasmCallTimeout limits the execution time of one plugin call.
+	// A plugin that times out is closed and is not called again.
+	wasmCallTimeout  = 100 * time.Millisecond
+	wasmTickInterval = time.Second
+)
+
+// wasmAction is the fault requested by a plugin via host function calls during on_mpd or on_segment.
+type wasmAction struct {
+	status       int
+	dropPermille int
+	capKbps      int
+}
+
+type wasmActionKey struct{}
+
+// wasmPlugin is an instantiated WASM module. Calls are serialized, so the module may keep state between calls.
+type wasmPlugin struct {
+	name      string
+	mu        sync.Mutex
+	mod       api.Module
+	onTick    api.Function
+	onMPD     api.Function
+	onSegment api.Function
+}
+
+// wasmPlugins is a sandboxed WASM runtime with plugins for custom fault injection.
+//
+// Plugins may export the functions
+//
+//	on_tick(now_ms i64)    called every second
+//	on_mpd(now_ms i64)     called before an MPD response
+//	on_segment(now_ms i64) called before a segment response
+//
+// and call the host functions of the "livesim" module during on_mpd and on_segment:
+//
+//	fail(status i32)       respond with an error status instead
+//	drop(permille i32)     drop this part (per mille) of the end of the response by closing the connection
+//	cap_rate(kbps i32)     send the response at most at this bitrate
+//	log(ptr i32, len i32)  log a message from plugin memory
+//
+// WASI is available without file system, network, or environment access.
+type wasmPlugins struct {
+	rt      wazero.Runtime
+	plugins map[string]*wasmPlugin
+	log     *slog.Logger
+	done    chan struct{}
+}
+
+// newWasmPlugins loads plugins from a comma-separated list of .wasm files.
+// A plugin is named by its file name without extension.
+func newWasmPlugins(ctx context.Context, paths string, log *slog.Logger) (*wasmPlugins, error) {
+	rtCfg := wazero.NewRuntimeConfig().WithMemoryLimitPages(wasmMemoryLimitPages).WithCloseOnContextDone(true)
+	wp := wasmPlugins{
+		rt:      wazero.NewRuntimeWithConfig(ctx, rtCfg),
+		plugins: make(map[string]*wasmPlugin),
+		log:     log,
+		done:    make(chan struct{}),
+	}
+	if err := wp.instantiateHost(ctx); err != nil {
+		_ = wp.rt.Close(ctx)
+		return nil, err
+	}
+	hasTick := false
+	for _, p := range strings.Split(paths, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		plug, err := wp.load(ctx, p)
+		if err != nil {
+			_ = wp.rt.Close(ctx)
+			return nil, fmt.Errorf("wasm plugin %s: %w", p, err)
+		}
+		hasTick = hasTick || plug.onTick != nil
+		log.Info("loaded wasm plugin", "name", plug.name, "path", p)
+	}
+	if hasTick {
+		go wp.tick(ctx)
+	}
+	return &wp, nil
+}
+
+func (wp *wasmPlugins) instantiateHost(ctx context.Context) error {
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, wp.rt); err != nil {
+		return fmt.Errorf("wasi: %w", err)
+	}
+	action := func(ctx context.Context) *wasmAction {
+		a, _ := ctx.Value(wasmActionKey{}).(*wasmAction)
+		if a == nil {
+			return &wasmAction{} // Called from on_tick. Nothing to apply.
+		}
+		return a
+	}
+	fail := func(ctx context.Context, status uint32) {
+		action(ctx).status = int(status)
+	}
+	drop := func(ctx context.Context, permille uint32) {
+		action(ctx).dropPermille = int(min(permille, 1000))
+	}
+	capRate := func(ctx context.Context, kbps uint32) {
+		action(ctx).capKbps = int(kbps)
+	}
+	logMsg := func(ctx context.Context, m api.Module, ptr, length uint32) {
+		if msg, ok := m.Memory().Read(ptr, length); ok {
+			wp.log.Info("wasm plugin", "plugin", m.Name(), "msg", string(msg))
+		}
+	}
+	_, err := wp.rt.NewHostModuleBuilder(wasmHostModule).
+		NewFunctionBuilder().WithFunc(fail).Export("fail").
+		NewFunctionBuilder().WithFunc(drop).Export("drop").
+		NewFunctionBuilder().WithFunc(capRate).Export("cap_rate").
+		NewFunctionBuilder().WithFunc(logMsg).Export("log").
+		Instantiate(ctx)
+	if err != nil {
+		return fmt.Errorf("host module: %w", err)
+	}
+	return nil
+}
+
+func (wp *wasmPlugins) load(ctx context.Context, path string) (*wasmPlugin, error) {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if _, ok := wp.plugins[name]; ok {
+		return nil, fmt.Errorf("duplicate plugin name %q", name)
+	}
+	bin, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := wp.rt.CompileModule(ctx, bin)
+	if err != nil {
+		return nil, err
+	}
+	// _initialize sets up reactor modules, like Go wasip1 c-shared builds. Missing start functions are skipped.
+	modCfg := wazero.NewModuleConfig().WithName(name).WithStartFunctions("_initialize")
+	mod, err := wp.rt.InstantiateModule(ctx, compiled, modCfg)
+	if err != nil {
+		return nil, err
+	}
+	plug := wasmPlugin{
+		name:      name,
+		mod:       mod,
+		onTick:    mod.ExportedFunction("on_tick"),
+		onMPD:     mod.ExportedFunction("on_mpd"),
+		onSegment: mod.ExportedFunction("on_segment"),
+	}
+	if plug.onTick == nil && plug.onMPD == nil && plug.onSegment == nil {
+		return nil, fmt.Errorf("exports none of on_tick, on_mpd, and on_segment")
+	}
+	wp.plugins[name] = &plug
+	return &plug, nil
+}
+
+// call calls fn of the plugin with nowMS, and returns the requested action.
+func (wp *wasmPlugins) call(ctx context.Context, plug *wasmPlugin, fn api.Function, nowMS int) (wasmAction, error) {
+	var a wasmAction
+	if fn == nil {
+		return a, nil
+	}
+	plug.mu.Lock()
+	defer plug.mu.Unlock()
+	if plug.mod.IsClosed() {
+		return a, fmt.Errorf("plugin %q is closed", plug.name)
+	}
+	// A canceled request must not close the module, so only the call timeout applies
+	ctx = context.WithValue(context.WithoutCancel(ctx), wasmActionKey{}, &a)
+	ctx, cancel := context.WithTimeout(ctx, wasmCallTimeout)
+	defer cancel()
+	_, err := fn.Call(ctx, api.EncodeI64(int64(nowMS)))
+	return a, err
+}
+
+func (wp *wasmPlugins) tick(ctx context.Context) {
+	ticker := time.NewTicker(wasmTickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-wp.done:
+			return
+		case t := <-ticker.C:
+			for _, plug := range wp.plugins {
+				if _, err := wp.call(ctx, plug, plug.onTick, int(t.UnixMilli())); err != nil {
+					wp.log.Error("wasm plugin on_tick", "plugin", plug.name, "err", err)
+				}
+			}
+		}
+	}
+}
+
+func (wp *wasmPlugins) close(ctx context.Context) error {
+	close(wp.done)
+	return wp.rt.Close(ctx)
+}
+
+// applyWasmPlugin calls the plugin named by the value of the wasm URL parameter for an MPD or segment request.
+// It returns the response writer to continue with, or done if the response has been written.
+func (s *Server) applyWasmPlugin(w http.ResponseWriter, r *http.Request, log *slog.Logger, name string,
+	isMPD bool, nowMS int) (http.ResponseWriter, bool) {
+	var plug *wasmPlugin
+	if s.wasm != nil {
+		plug = s.wasm.plugins[name]
+	}
+	if plug == nil {
+		writeProblem(w, r, http.StatusBadRequest, reasonBadValue, fmt.Sprintf("unknown wasm plugin %q", name))
+		return w, true
+	}
+	fn := plug.onSegment
+	if isMPD {
+		fn = plug.onMPD
+	}
+	a, err := s.wasm.call(r.Context(), plug, fn, nowMS)
+	if err != nil {
+		log.Error("wasm plugin", "plugin", name, "err", err)
+		writeProblem(w, r, http.StatusInternalServerError, reasonInternal, fmt.Sprintf("wasm plugin %q failed", name))
+		return w, true
+	}
+	switch {
+	case a.status != 0:
+		if a.status < 100 || a.status > 599 {
+			writeProblem(w, r, http.StatusInternalServerError, reasonInternal,
+				fmt.Sprintf("wasm plugin %q gave bad status %d", name, a.status))
+			return w, true
+		}
+		w.Header().Set(chaosHeader, "wasm-fail")
+		writeProblem(w, r, a.status, reasonTriggeredStatus, fmt.Sprintf("wasm plugin %q", name))
+		return w, true
+	case a.dropPermille > 0 || a.capKbps > 0:
+		var faults []string
+		if a.capKbps > 0 {
+			faults = append(faults, "wasm-cap")
+			w = &throttledWriter{ResponseWriter: w, ctx: r.Context(), limiter: newBwLimiter(a.capKbps)}
+		}
+		if a.dropPermille > 0 {
+			faults = append(faults, "wasm-drop")
+			w = &truncatingWriter{ResponseWriter: w, fraction: 1 - float64(a.dropPermille)/1000, remaining: -1}
+		}
+		w.Header().Set(chaosHeader, strings.Join(faults, ","))
+	}
+	return w, false
+}
+
+// throttledWriter limits the rate of writing to the response.
+type throttledWriter struct {
+	http.ResponseWriter
+	ctx     context.Context
+	limiter *bwLimiter
+}
+
+func (tw *throttledWriter) Write(p []byte) (int, error) {
+	if err := tw.limiter.wait(tw.ctx, len(p)); err != nil {
+		return 0, err
+	}
+	return tw.ResponseWriter.Write(p)
+}
+
+// Flush makes chunked low-latency segments work with rate caps.
+func (tw *throttledWriter) Flush() {
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testWasmPlugin is a WASM module with on_mpd calling fail(503) and on_segment calling drop(500).
+func testWasmPlugin() []byte {
+	name := func(s string) []byte { return append([]byte{byte(len(s))}, s...) }
+	section := func(id byte, content ...[]byte) []byte {
+		var b []byte
+		for _, c := range content {
+			b = append(b, c...)
+		}
+		return append([]byte{id, byte(len(b))}, b...)
+	}
+	var mod []byte
+	mod = append(mod, 0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00)
+	// Types: 0 = (i32) -> (), 1 = (i64) -> ()
+	mod = append(mod, section(1, []byte{2, 0x60, 1, 0x7f, 0, 0x60, 1, 0x7e, 0})...)
+	mod = append(mod, section(2, []byte{2}, name("livesim"), name("fail"), []byte{0x00, 0},
+		name("livesim"), name("drop"), []byte{0x00, 0})...)
+	mod = append(mod, section(3, []byte{2, 1, 1})...)
+	mod = append(mod, section(7, []byte{2}, name("on_mpd"), []byte{0x00, 2}, name("on_segment"), []byte{0x00, 3})...)
+	mod = append(mod, section(10, []byte{2},
+		[]byte{7, 0x00, 0x41, 0xf7, 0x03, 0x10, 0x00, 0x0b}, // i32.const 503, call fail
+		[]byte{7, 0x00, 0x41, 0xf4, 0x03, 0x10, 0x01, 0x0b}, // i32.const 500, call drop
+	)...)
+	return mod
+}
+
+func TestWasmPlugins(t *testing.T) {
+	pluginPath := filepath.Join(t.TempDir(), "halfdrop.wasm")
+	require.NoError(t, os.WriteFile(pluginPath, testWasmPlugin(), 0o644))
+	server, _ := newTestServer(t, func(cfg *ServerConfig) {
+		cfg.WasmPlugins = pluginPath
+	})
+	defer func() { require.NoError(t, server.Shutdown(context.Background())) }()
+	ts := httptest.NewServer(server.Router)
+	defer ts.Close()
+
+	resp, _ := testFullRequest(t, ts, "GET", "/livesim2/wasm_halfdrop/testpic_2s/Manifest.mpd", nil)
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Equal(t, "wasm-fail", resp.Header.Get(chaosHeader))
+
+	resp, _ = testFullRequest(t, ts, "GET", "/livesim2/wasm_unknown/testpic_2s/Manifest.mpd", nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	segPath := "/livesim2/wasm_halfdrop/testpic_2s/V300/49.m4s?nowMS=100000"
+	resp, err := http.Get(ts.URL + segPath)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "wasm-drop", resp.Header.Get(chaosHeader))
+	size, err := strconv.Atoi(resp.Header.Get("Content-Length"))
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.Error(t, err)
+	require.Equal(t, size/2, len(body))
+}
+
+func TestWasmPluginsBadModule(t *testing.T) {
+	pluginPath := filepath.Join(t.TempDir(), "bad.wasm")
+	require.NoError(t, os.WriteFile(pluginPath, []byte("not wasm"), 0o644))
+	_, err := newWasmPlugins(context.Background(), pluginPath, nil)
+	require.Error(t, err)
+}
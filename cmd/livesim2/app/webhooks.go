@@ -0,0 +1,129 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Webhook event names.
+const (
+	eventIngesterStarted       = "ingester.started"
+	eventIngesterSegmentFailed = "ingester.segment_failed"
+	eventIngesterFellBehind    = "ingester.fell_behind"
+	eventIngesterStopped       = "ingester.stopped"
+	eventSessionCreated        = "session.created"
+	eventSessionFirstRequest   = "session.first_request"
+	eventSessionDeleted        = "session.deleted"
+	eventSessionExpired        = "session.expired"
+)
+
+const (
+	webhookSignatureHeader = "X-Livesim-Signature"
+	webhookTimeout         = 5 * time.Second
+)
+
+// WebhookSetup configures a webhook that receives JSON event notifications.
+type WebhookSetup struct {
+	URL    string `json:"url" doc:"URL that events are POSTed to" example:"https://alerts.example.com/livesim2"`
+	Secret string `json:"secret,omitempty" doc:"Secret for the HMAC-SHA256 body signature in the X-Livesim-Signature header"`
+}
+
+// webhookEvent is the JSON payload sent to a webhook.
+type webhookEvent struct {
+	Event      string    `json:"event"`
+	Time       time.Time `json:"time"`
+	IngesterID string    `json:"ingesterId,omitempty"`
+	SessionID  string    `json:"sessionId,omitempty"`
+	Message    string    `json:"message,omitempty"`
+}
+
+// webhook sends events to a URL. A nil webhook ignores all events.
+type webhook struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// newWebhook returns a webhook for ws, or nil if ws is nil.
+func newWebhook(ws *WebhookSetup) (*webhook, error) {
+	if ws == nil {
+		return nil, nil
+	}
+	u, err := url.Parse(ws.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("webhook URL %q must be an absolute http(s) URL", ws.URL)
+	}
+	return &webhook{
+		url:    ws.URL,
+		secret: ws.Secret,
+		client: &http.Client{Timeout: webhookTimeout},
+	}, nil
+}
+
+// setup returns the setup of wh, or nil if wh is nil.
+func (wh *webhook) setup() *WebhookSetup {
+	if wh == nil {
+		return nil
+	}
+	return &WebhookSetup{URL: wh.url, Secret: wh.secret}
+}
+
+// fire sends the event in the background. Failures are logged.
+func (wh *webhook) fire(log *slog.Logger, ev webhookEvent) {
+	if wh == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	go func() {
+		if err := wh.send(context.Background(), ev); err != nil {
+			log.Warn("webhook", "event", ev.Event, "url", wh.url, "err", err)
+		}
+	}()
+}
+
+// send posts the event and returns an error unless the response status is 2xx.
+func (wh *webhook) send(ctx context.Context, ev webhookEvent) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if wh.secret != "" {
+		req.Header.Set(webhookSignatureHeader, "sha256="+webhookSignature(wh.secret, body))
+	}
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// webhookSignature returns the hex-encoded HMAC-SHA256 of body with secret.
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
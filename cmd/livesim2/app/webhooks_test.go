@@ -0,0 +1,128 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newWebhookReceiver returns a server that checks signatures with a non-empty secret and forwards events to the channel.
+func newWebhookReceiver(t *testing.T, secret string) (*httptest.Server, chan webhookEvent) {
+	events := make(chan webhookEvent, 16)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		if secret != "" && r.Header.Get(webhookSignatureHeader) != "sha256="+webhookSignature(secret, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var ev webhookEvent
+		require.NoError(t, json.Unmarshal(body, &ev))
+		events <- ev
+	}))
+	return ts, events
+}
+
+func nextEvent(t *testing.T, events chan webhookEvent) webhookEvent {
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("no webhook event received")
+	}
+	return webhookEvent{}
+}
+
+func TestWebhookSend(t *testing.T) {
+	ts, events := newWebhookReceiver(t, "s3cret")
+	defer ts.Close()
+
+	wh, err := newWebhook(&WebhookSetup{URL: ts.URL, Secret: "s3cret"})
+	require.NoError(t, err)
+	err = wh.send(context.Background(), webhookEvent{Event: eventIngesterStarted, IngesterID: "3"})
+	require.NoError(t, err)
+	ev := nextEvent(t, events)
+	require.Equal(t, eventIngesterStarted, ev.Event)
+	require.Equal(t, "3", ev.IngesterID)
+
+	wh, err = newWebhook(&WebhookSetup{URL: ts.URL, Secret: "wrong"})
+	require.NoError(t, err)
+	require.Error(t, wh.send(context.Background(), webhookEvent{Event: eventIngesterStarted}))
+
+	_, err = newWebhook(&WebhookSetup{URL: "/relative"})
+	require.Error(t, err)
+	wh, err = newWebhook(nil)
+	require.NoError(t, err)
+	require.Nil(t, wh)
+	wh.fire(nil, webhookEvent{Event: eventIngesterStopped}) // nil webhook is a no-op
+}
+
+func TestSessionWebhook(t *testing.T) {
+	ts, events := newWebhookReceiver(t, "")
+	defer ts.Close()
+	wh, err := newWebhook(&WebhookSetup{URL: ts.URL})
+	require.NoError(t, err)
+
+	ss := newSessionStore()
+	now := time.Now()
+	sess, err := ss.add([]byte(`{"SegTimelineFlag": true}`), time.Minute, false, wh, now)
+	require.NoError(t, err)
+	ev := nextEvent(t, events)
+	require.Equal(t, eventSessionCreated, ev.Event)
+	require.Equal(t, sess.id, ev.SessionID)
+
+	for i := 0; i < 2; i++ {
+		cfg := NewResponseConfig()
+		cfg.SessionID = sess.id
+		require.Nil(t, applyConfigOverlays(cfg, ss, "", 0))
+	}
+	require.Equal(t, eventSessionFirstRequest, nextEvent(t, events).Event)
+
+	require.True(t, ss.remove(sess.id))
+	require.Equal(t, eventSessionDeleted, nextEvent(t, events).Event)
+
+	sess, err = ss.add([]byte(`{}`), time.Second, false, wh, now)
+	require.NoError(t, err)
+	require.Equal(t, eventSessionCreated, nextEvent(t, events).Event)
+	_, err = ss.add([]byte(`{}`), time.Second, false, nil, now.Add(time.Minute))
+	require.NoError(t, err)
+	ev = nextEvent(t, events)
+	require.Equal(t, eventSessionExpired, ev.Event)
+	require.Equal(t, sess.id, ev.SessionID)
+}
+
+func TestIngesterWebhook(t *testing.T) {
+	whServer, events := newWebhookReceiver(t, "")
+	defer whServer.Close()
+	server, _ := newTestServer(t, nil)
+	cm := NewCmafIngesterMgr(server)
+	cm.Start()
+	recServer := httptest.NewServer(newCmafReceiverTestServer())
+	defer recServer.Close()
+
+	nr, err := cm.NewCmafIngester(CmafIngesterSetup{
+		DestRoot:  recServer.URL,
+		DestName:  "testpic",
+		URL:       "/livesim2/testpic_2s/Manifest.mpd",
+		TestNowMS: Ptr(10000),
+		Webhook:   &WebhookSetup{URL: whServer.URL},
+	})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	go mustGetIngester(t, cm, nr).start(ctx)
+	ev := nextEvent(t, events)
+	require.Equal(t, eventIngesterStarted, ev.Event)
+	require.Equal(t, "1", ev.IngesterID)
+	cancel()
+	require.Equal(t, eventIngesterStopped, nextEvent(t, events).Event)
+}
@@ -25,6 +25,16 @@ func main() {
 }
 
 func run() (exitCode int) {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "compare":
+			return runCompare(os.Args[2:])
+		case "replay":
+			return runReplay(os.Args[2:])
+		case "load":
+			return runLoad(os.Args[2:])
+		}
+	}
 	cwd, err := os.Getwd()
 	if err != nil {
 		cwd = "."
@@ -75,6 +85,8 @@ func run() (exitCode int) {
 		var err error
 
 		switch {
+		case len(cfg.Listeners) > 0:
+			err = server.ServeListeners(ctx)
 		case cfg.Domains != "":
 			domains := strings.Split(cfg.Domains, ",")
 			err = certmagic.HTTPS(domains, server.Router)
@@ -95,3 +107,67 @@ func run() (exitCode int) {
 
 	return exitCode
 }
+
+// runCompare runs the compare subcommand and returns 1 if there are discrepancies or errors.
+func runCompare(args []string) int {
+	o, err := app.ParseCompareArgs(args)
+	if err != nil {
+		if strings.Contains(err.Error(), "help requested") {
+			return 0
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return 1
+	}
+	nrDiffs, err := app.Compare(context.Background(), o, os.Stdout)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error comparing: %s\n", err.Error())
+		return 1
+	}
+	if nrDiffs > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runReplay runs the replay subcommand and returns 1 if any response differs or there is an error.
+func runReplay(args []string) int {
+	o, err := app.ParseReplayArgs(args)
+	if err != nil {
+		if strings.Contains(err.Error(), "help requested") {
+			return 0
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return 1
+	}
+	nrDiffs, err := app.Replay(context.Background(), o, os.Stdout)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error replaying: %s\n", err.Error())
+		return 1
+	}
+	if nrDiffs > 0 {
+		return 1
+	}
+	return 0
+}
+
+func runLoad(args []string) int {
+	o, err := app.ParseLoadArgs(args)
+	if err != nil {
+		if strings.Contains(err.Error(), "help requested") {
+			return 0
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		return 1
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	nrErrors, err := app.Load(ctx, o, os.Stdout)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error generating load: %s\n", err.Error())
+		return 1
+	}
+	if nrErrors > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,54 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+//go:build wasip1
+
+// bitratecap is a livesim2 WASM plugin that periodically caps the bitrate of segment responses.
+// During the first capS seconds of every periodS seconds, segments are sent at most at capKbps.
+// The number of capped periods is counted in on_tick and logged when a new period starts.
+//
+// Build with Go 1.24 or later:
+//
+//	GOOS=wasip1 GOARCH=wasm go build -buildmode=c-shared -o bitratecap.wasm ./examples/wasm/bitratecap
+package main
+
+import (
+	"strconv"
+	"unsafe"
+)
+
+const (
+	periodS = 60
+	capS    = 20
+	capKbps = 500
+)
+
+var nrPeriods int
+
+//go:wasmimport livesim cap_rate
+func capRate(kbps int32)
+
+//go:wasmimport livesim log
+func logMsg(ptr unsafe.Pointer, length int32)
+
+func log(msg string) {
+	logMsg(unsafe.Pointer(unsafe.StringData(msg)), int32(len(msg)))
+}
+
+//go:wasmexport on_tick
+func onTick(nowMS int64) {
+	if (nowMS/1000)%periodS == 0 {
+		nrPeriods++
+		log("capping at " + strconv.Itoa(capKbps) + " kbps, period " + strconv.Itoa(nrPeriods))
+	}
+}
+
+//go:wasmexport on_segment
+func onSegment(nowMS int64) {
+	if (nowMS/1000)%periodS < capS {
+		capRate(capKbps)
+	}
+}
+
+func main() {}
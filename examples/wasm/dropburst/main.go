@@ -0,0 +1,32 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+//go:build wasip1
+
+// dropburst is a livesim2 WASM plugin that simulates bursts of packet drops.
+// During the first burstS seconds of every periodS seconds, segment responses are cut
+// after 30% of the bytes and the connection is closed.
+//
+// Build with Go 1.24 or later:
+//
+//	GOOS=wasip1 GOARCH=wasm go build -buildmode=c-shared -o dropburst.wasm ./examples/wasm/dropburst
+package main
+
+const (
+	periodS      = 30
+	burstS       = 5
+	dropPermille = 700
+)
+
+//go:wasmimport livesim drop
+func drop(permille int32)
+
+//go:wasmexport on_segment
+func onSegment(nowMS int64) {
+	if (nowMS/1000)%periodS < burstS {
+		drop(dropPermille)
+	}
+}
+
+func main() {}
@@ -18,7 +18,7 @@ require (
 	github.com/prometheus/client_golang v1.20.5
 	github.com/spf13/pflag v1.0.5
 	github.com/stretchr/testify v1.10.0
-
+	github.com/tetratelabs/wazero v1.9.0
 )
 
 require (
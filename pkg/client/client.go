@@ -0,0 +1,438 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+// Package client provides a typed Go client for the livesim2 REST API,
+// so that test frameworks can drive livesim2 without hand-rolling HTTP calls.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultTimeout = 30 * time.Second
+
+// Client calls the livesim2 API of one server.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// New returns a client for the livesim2 server at baseURL, e.g. http://localhost:8888.
+func New(baseURL string, opts ...Option) *Client {
+	c := Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: defaultTimeout},
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return &c
+}
+
+// Error is a non-2xx response, with the problem details of the body if available.
+type Error struct {
+	StatusCode int
+	Title      string `json:"title"`
+	Detail     string `json:"detail"`
+}
+
+func (e *Error) Error() string {
+	if e.Detail != "" {
+		return fmt.Sprintf("livesim2: %d %s: %s", e.StatusCode, e.Title, e.Detail)
+	}
+	return fmt.Sprintf("livesim2: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// do sends a request to path with in as JSON body (if not nil), and decodes the JSON response into out (if not nil).
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out any) error {
+	if in == nil {
+		return c.send(ctx, method, path, query, nil, "", out)
+	}
+	data, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+	return c.send(ctx, method, path, query, bytes.NewReader(data), "application/json", out)
+}
+
+// send sends a request to path with body of contentType (if not nil), and decodes the JSON response into out (if not nil).
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body io.Reader, contentType string, out any) error {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		apiErr := Error{StatusCode: resp.StatusCode}
+		_ = json.Unmarshal(respBody, &apiErr)
+		return &apiErr
+	}
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// Version returns the livesim2 version of the server.
+func (c *Client) Version(ctx context.Context) (string, error) {
+	var v struct {
+		Version string
+	}
+	err := c.do(ctx, http.MethodGet, "/version", nil, nil, &v)
+	return v.Version, err
+}
+
+// IngesterSetup configures a CMAF ingester.
+type IngesterSetup struct {
+	User        string            `json:"user,omitempty"`
+	PassWord    string            `json:"password,omitempty"`
+	DestRoot    string            `json:"destRoot"`
+	DestName    string            `json:"destName"`
+	URL         string            `json:"livesimURL"` // Full livesim2 URL without scheme and host
+	TestNowMS   *int              `json:"testNowMS,omitempty"`
+	Duration    *int              `json:"duration,omitempty"` // Duration in seconds
+	StreamsURLs bool              `json:"streamsURLs,omitempty"`
+	Profile     string            `json:"profile,omitempty"` // dashif or aws-elemental
+	StartAt     string            `json:"startAt,omitempty"` // RFC3339 or nextMinute
+	MaxKbps     int               `json:"maxKbps,omitempty"`
+	RepMaxKbps  map[string]int    `json:"repMaxKbps,omitempty"`
+	Webhook     *WebhookSetup     `json:"webhook,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	TraceParent string            `json:"traceparent,omitempty"`
+}
+
+// WebhookSetup configures a webhook for ingester or session events.
+type WebhookSetup struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+}
+
+// IngesterPairSetup configures a main and a backup CMAF ingester.
+type IngesterPairSetup struct {
+	Main           IngesterSetup `json:"main"`
+	BackupDestRoot string        `json:"backupDestRoot"`
+	BackupDestName string        `json:"backupDestName,omitempty"`
+	OffsetMS       int           `json:"offsetMS,omitempty"`
+}
+
+// IngesterPair is the IDs and the common start time of an ingester pair.
+type IngesterPair struct {
+	MainID   string `json:"mainId"`
+	BackupID string `json:"backupId"`
+	StartAt  string `json:"startAt,omitempty"`
+}
+
+// IngesterInfo is the state and report of a CMAF ingester.
+type IngesterInfo struct {
+	ID       string `json:"id"`
+	DestRoot string `json:"destRoot"`
+	DestName string `json:"destName"`
+	URL      string `json:"livesim-url"`
+	Report   string `json:"report"`
+	TraceID  string `json:"traceId"`
+}
+
+// CreateIngester creates and starts a CMAF ingester, and returns its ID.
+func (c *Client) CreateIngester(ctx context.Context, setup IngesterSetup) (string, error) {
+	var resp IngesterInfo
+	if err := c.do(ctx, http.MethodPost, "/api/cmaf-ingests", nil, setup, &resp); err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// CreateIngesterPair creates and starts a synchronized main and backup CMAF ingester.
+func (c *Client) CreateIngesterPair(ctx context.Context, setup IngesterPairSetup) (*IngesterPair, error) {
+	var resp IngesterPair
+	if err := c.do(ctx, http.MethodPost, "/api/cmaf-ingests/pair", nil, setup, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Ingester returns the info and report of a CMAF ingester.
+func (c *Client) Ingester(ctx context.Context, id string) (*IngesterInfo, error) {
+	var resp IngesterInfo
+	if err := c.do(ctx, http.MethodGet, "/api/cmaf-ingests/"+url.PathEscape(id), nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// StepIngester sends the next segment of an ingester in test mode (with TestNowMS set).
+func (c *Client) StepIngester(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodGet, "/api/cmaf-ingests/"+url.PathEscape(id)+"/step", nil, nil, nil)
+}
+
+// DeleteIngester stops and removes a CMAF ingester.
+func (c *Client) DeleteIngester(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/cmaf-ingests/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// SessionSetup configures a session with a stored response configuration.
+type SessionSetup struct {
+	Config  map[string]any `json:"config"` // ResponseConfig fields, e.g. {"SegTimelineFlag": true}
+	TTLS    int            `json:"ttlS,omitempty"`
+	Record  bool           `json:"record,omitempty"`
+	Webhook *WebhookSetup  `json:"webhook,omitempty"`
+}
+
+// Session is a stored configuration used via URLPrefix.
+type Session struct {
+	ID        string         `json:"id"`
+	URLPrefix string         `json:"urlPrefix"`
+	Config    map[string]any `json:"config"`
+	Expires   time.Time      `json:"expires"`
+	Recording bool           `json:"recording"`
+}
+
+// CreateSession creates a session.
+func (c *Client) CreateSession(ctx context.Context, setup SessionSetup) (*Session, error) {
+	var resp Session
+	if err := c.do(ctx, http.MethodPost, "/api/sessions", nil, setup, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Session returns a session.
+func (c *Client) Session(ctx context.Context, id string) (*Session, error) {
+	var resp Session
+	if err := c.do(ctx, http.MethodGet, "/api/sessions/"+url.PathEscape(id), nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// DeleteSession removes a session.
+func (c *Client) DeleteSession(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/sessions/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// SessionHAR returns the recorded requests of a session as a HAR document.
+func (c *Client) SessionHAR(ctx context.Context, id string) (json.RawMessage, error) {
+	var resp json.RawMessage
+	if err := c.do(ctx, http.MethodGet, "/api/sessions/"+url.PathEscape(id)+"/har", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// BlackoutSetup configures a blackout injected into a session.
+type BlackoutSetup struct {
+	StartS int      `json:"startS,omitempty"` // Wall-clock start in seconds since the Epoch (default now)
+	DurS   int      `json:"durS,omitempty"`   // Duration in seconds (default until ended)
+	Reps   []string `json:"reps,omitempty"`   // Representations (default all video and audio)
+}
+
+// Blackout is a scheduled blackout.
+type Blackout struct {
+	StartS int      `json:"StartS"`
+	EndS   int      `json:"EndS,omitempty"`
+	Reps   []string `json:"Reps,omitempty"`
+}
+
+// StartBlackout injects a blackout, signaled by events, into a session.
+func (c *Client) StartBlackout(ctx context.Context, sessionID string, setup BlackoutSetup) (*Blackout, error) {
+	var resp Blackout
+	if err := c.do(ctx, http.MethodPost, "/api/sessions/"+url.PathEscape(sessionID)+"/blackout", nil, setup, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// EndBlackout ends the blackout of a session now, or cancels it if it has not started.
+func (c *Client) EndBlackout(ctx context.Context, sessionID string) (*Blackout, error) {
+	var resp Blackout
+	if err := c.do(ctx, http.MethodDelete, "/api/sessions/"+url.PathEscape(sessionID)+"/blackout", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// EventSetup configures an event injected into a session.
+type EventSetup struct {
+	SchemeIdUri string `json:"schemeIdUri"`
+	Value       string `json:"value,omitempty"`
+	ID          uint32 `json:"id,omitempty"`          // Default the start time
+	StartS      int    `json:"startS,omitempty"`      // Wall-clock start in seconds since the Epoch (default now)
+	DurS        int    `json:"durS,omitempty"`        // Duration in seconds
+	MessageData string `json:"messageData,omitempty"` // Message data of the MPD event and emsg box
+}
+
+// Event is an event injected into a session.
+type Event struct {
+	SchemeIdUri string `json:"SchemeIdUri"`
+	Value       string `json:"Value,omitempty"`
+	ID          uint32 `json:"ID"`
+	StartS      int    `json:"StartS"`
+	DurS        int    `json:"DurS,omitempty"`
+	MessageData string `json:"MessageData,omitempty"`
+}
+
+// InjectEvent adds an event, signaled in the MPD and by emsg boxes in the video segments, to a session.
+// It returns all events of the session.
+func (c *Client) InjectEvent(ctx context.Context, sessionID string, setup EventSetup) ([]Event, error) {
+	var resp []Event
+	if err := c.do(ctx, http.MethodPost, "/api/sessions/"+url.PathEscape(sessionID)+"/events", nil, setup, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ConfigParameter is one <key>_<value> part of a livesim2 URL.
+type ConfigParameter struct {
+	Part  string `json:"part"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// ConfigExplanation shows how the configuration part of a livesim2 URL is interpreted.
+type ConfigExplanation struct {
+	URL         string            `json:"url"`
+	NowMS       int               `json:"nowMS"`
+	Parameters  []ConfigParameter `json:"parameters"`
+	ContentPart string            `json:"contentPart"`
+	Config      map[string]any    `json:"config"`
+}
+
+// ExplainConfig returns the parameters and resolved configuration of a livesim2 URL (path and query).
+// A negative nowMS means now.
+func (c *Client) ExplainConfig(ctx context.Context, livesimURL string, nowMS int) (*ConfigExplanation, error) {
+	q := url.Values{"url": {livesimURL}, "nowMS": {strconv.Itoa(nowMS)}}
+	var resp ConfigExplanation
+	if err := c.do(ctx, http.MethodGet, "/api/explain-config", q, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// MPDSnapshot is a recorded MPD.
+type MPDSnapshot struct {
+	Seq   int       `json:"seq"`
+	Time  time.Time `json:"time"`
+	NowMS int       `json:"nowMS"`
+	URL   string    `json:"url"`
+	MPD   string    `json:"mpd"`
+}
+
+// MPDHistory returns the recorded MPDs for a session (session_<id>) or MPD URL path, oldest first.
+// The server must run with --mpdhistory.
+func (c *Client) MPDHistory(ctx context.Context, key string) ([]MPDSnapshot, error) {
+	var resp struct {
+		Snapshots []MPDSnapshot `json:"snapshots"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/mpd-history/snapshots", url.Values{"key": {key}}, nil, &resp)
+	return resp.Snapshots, err
+}
+
+// QoEReport is a received DASH metrics report.
+type QoEReport struct {
+	Seq         int       `json:"seq"`
+	Time        time.Time `json:"time"`
+	ClientIP    string    `json:"clientIP"`
+	ContentType string    `json:"contentType,omitempty"`
+	Report      string    `json:"report"`
+}
+
+// QoEReports returns the metrics reports received for an MPD URL path, oldest first.
+// The server must run with --qoereports.
+func (c *Client) QoEReports(ctx context.Context, mpdPath string) ([]QoEReport, error) {
+	var resp struct {
+		Reports []QoEReport `json:"reports"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/qoe-reports/reports", url.Values{"key": {mpdPath}}, nil, &resp)
+	return resp.Reports, err
+}
+
+// StatsCounts are request counts.
+type StatsCounts struct {
+	Requests int   `json:"requests"`
+	Errors   int   `json:"errors"`
+	Bytes    int64 `json:"bytes"`
+}
+
+// RepStats is the request statistics of a representation, or MPD for manifest requests.
+type RepStats struct {
+	Rep   string      `json:"rep"`
+	Total StatsCounts `json:"total"`
+}
+
+// AssetStats is the request statistics of an asset.
+type AssetStats struct {
+	Asset string      `json:"asset"`
+	Total StatsCounts `json:"total"`
+	Reps  []RepStats  `json:"reps"`
+}
+
+// AssetStats returns the request statistics for an asset, or all assets if asset is empty.
+func (c *Client) AssetStats(ctx context.Context, asset string) ([]AssetStats, error) {
+	var q url.Values
+	if asset != "" {
+		q = url.Values{"asset": {asset}}
+	}
+	var resp struct {
+		Assets []AssetStats `json:"assets"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/stats/assets", q, nil, &resp)
+	return resp.Assets, err
+}
+
+// UploadedAsset is an asset loaded from an upload.
+type UploadedAsset struct {
+	Path         string `json:"path"`
+	LoopDurMS    int    `json:"loopDurationMS"`
+	SegmentDurMS int    `json:"segmentDurMS"`
+	MPDs         []struct {
+		Name string `json:"name"`
+	} `json:"mpds"`
+}
+
+// UploadAsset uploads a zip archive with MPDs and segments to the directory name (e.g. uploads/mytest) of the
+// vodroot, and returns the loaded assets. The server must run with --assetupload.
+func (c *Client) UploadAsset(ctx context.Context, name string, zipData io.Reader) ([]UploadedAsset, error) {
+	var resp struct {
+		Assets []UploadedAsset `json:"assets"`
+	}
+	err := c.send(ctx, http.MethodPut, "/api/assets/"+url.PathEscape(name), nil, zipData, "application/zip", &resp)
+	return resp.Assets, err
+}
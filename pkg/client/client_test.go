@@ -0,0 +1,88 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Dash-Industry-Forum/livesim2/cmd/livesim2/app"
+	"github.com/Dash-Industry-Forum/livesim2/pkg/client"
+	"github.com/Dash-Industry-Forum/livesim2/pkg/logging"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	cfg := app.ServerConfig{
+		VodRoot:   "../../cmd/livesim2/app/testdata/assets",
+		TimeoutS:  0,
+		LogFormat: logging.LogDiscard,
+	}
+	server, err := app.SetupServer(context.Background(), &cfg)
+	require.NoError(t, err)
+	ts := httptest.NewServer(server.Router)
+	defer ts.Close()
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer receiver.Close()
+
+	ctx := context.Background()
+	c := client.New(ts.URL + "/")
+
+	version, err := c.Version(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, version)
+
+	sess, err := c.CreateSession(ctx, client.SessionSetup{Config: map[string]any{"SegTimelineFlag": true}})
+	require.NoError(t, err)
+	require.Equal(t, "/livesim2/session_"+sess.ID, sess.URLPrefix)
+	got, err := c.Session(ctx, sess.ID)
+	require.NoError(t, err)
+	require.Equal(t, true, got.Config["SegTimelineFlag"])
+
+	bo, err := c.StartBlackout(ctx, sess.ID, client.BlackoutSetup{StartS: 100, DurS: 20})
+	require.NoError(t, err)
+	require.Equal(t, client.Blackout{StartS: 100, EndS: 120}, *bo)
+
+	events, err := c.InjectEvent(ctx, sess.ID, client.EventSetup{SchemeIdUri: "urn:test", StartS: 110, MessageData: "ad"})
+	require.NoError(t, err)
+	require.Equal(t, []client.Event{{SchemeIdUri: "urn:test", ID: 110, StartS: 110, MessageData: "ad"}}, events)
+
+	ce, err := c.ExplainConfig(ctx, sess.URLPrefix+"/tsbd_30/testpic_2s/Manifest.mpd", 100_000)
+	require.NoError(t, err)
+	require.Equal(t, 30.0, ce.Config["TimeShiftBufferDepthS"])
+	require.Equal(t, true, ce.Config["SegTimelineFlag"])
+
+	require.NoError(t, c.DeleteSession(ctx, sess.ID))
+	_, err = c.Session(ctx, sess.ID)
+	var apiErr *client.Error
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+
+	testNowMS := 10_000
+	id, err := c.CreateIngester(ctx, client.IngesterSetup{
+		DestRoot:  receiver.URL,
+		DestName:  "testpic",
+		URL:       "/livesim2/testpic_2s/Manifest.mpd",
+		TestNowMS: &testNowMS,
+	})
+	require.NoError(t, err)
+	info, err := c.Ingester(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, "/livesim2/testpic_2s/Manifest.mpd", info.URL)
+	require.Len(t, info.TraceID, 32)
+	require.NoError(t, c.DeleteIngester(ctx, id))
+
+	_, err = c.AssetStats(ctx, "")
+	require.NoError(t, err)
+
+	// Upload is not enabled
+	_, err = c.UploadAsset(ctx, "uploads/test", bytes.NewReader([]byte("zip")))
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+}
@@ -74,8 +74,12 @@ func SlogMiddleWare(l *slog.Logger) func(next http.Handler) http.Handler {
 			defer func() {
 				endTime := time.Now()
 
-				// Recover and record stack traces in case of a panic
-				if rec := recover(); rec != nil {
+				// Recover and record stack traces in case of a panic.
+				// http.ErrAbortHandler is re-raised after logging to abort the response.
+				rec := recover()
+				if rec == http.ErrAbortHandler {
+					defer panic(rec)
+				} else if rec != nil {
 					l.Error("Runtime error (panic)",
 						"request_id", GetRequestID(r),
 						"recover_info", rec,
@@ -99,6 +103,9 @@ func SlogMiddleWare(l *slog.Logger) func(next http.Handler) http.Handler {
 					l2 = l2.With("url", r.URL.Path)
 				}
 
+				if traceID := GetTraceID(r); traceID != "" {
+					l2 = l2.With("trace_id", traceID)
+				}
 				bytesIn := r.Header.Get("Content-Length")
 				if bytesIn != "" {
 					l2 = l2.With("bytes_in", bytesIn)
@@ -121,7 +128,21 @@ func GetRequestID(r *http.Request) string {
 	return requestID
 }
 
-// SubLoggerWithRequestID creates a new sub-logger with request_id field.
+// GetTraceID returns the trace ID of the W3C traceparent request header, or "" if there is none.
+func GetTraceID(r *http.Request) string {
+	tp := r.Header.Get("traceparent")
+	if len(tp) < 36 || tp[2] != '-' || tp[35] != '-' {
+		return ""
+	}
+	return tp[3:35]
+}
+
+// SubLoggerWithRequestID creates a new sub-logger with request_id field,
+// and trace_id field if there is a traceparent header.
 func SubLoggerWithRequestID(l *slog.Logger, r *http.Request) *slog.Logger {
-	return l.With(slog.String("request_id", GetRequestID(r)))
+	l = l.With(slog.String("request_id", GetRequestID(r)))
+	if traceID := GetTraceID(r); traceID != "" {
+		l = l.With(slog.String("trace_id", traceID))
+	}
+	return l
 }
@@ -0,0 +1,200 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+// Package mpddiff provides a semantic (element and attribute level) diff of DASH MPDs.
+package mpddiff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// ChangeKind is the kind of difference.
+type ChangeKind string
+
+const (
+	Added   ChangeKind = "added"
+	Removed ChangeKind = "removed"
+	Changed ChangeKind = "changed"
+)
+
+// textAttr is used as attribute name for element text content.
+const textAttr = "#text"
+
+// Change is one difference between two MPDs.
+// Path identifies the element, e.g. MPD/Period[id=P0]/AdaptationSet[id=1].
+// Attr is empty for added or removed elements.
+type Change struct {
+	Kind ChangeKind `json:"kind"`
+	Path string     `json:"path"`
+	Attr string     `json:"attr,omitempty"`
+	Old  string     `json:"old,omitempty"`
+	New  string     `json:"new,omitempty"`
+}
+
+func (c Change) String() string {
+	target := c.Path
+	if c.Attr != "" {
+		target += "@" + c.Attr
+	}
+	switch c.Kind {
+	case Added:
+		return fmt.Sprintf("+ %s %s", target, c.New)
+	case Removed:
+		return fmt.Sprintf("- %s %s", target, c.Old)
+	default:
+		return fmt.Sprintf("~ %s: %q -> %q", target, c.Old, c.New)
+	}
+}
+
+// Options controls what is compared.
+type Options struct {
+	// IgnoreAttrs are attribute names ignored everywhere ("startNumber"),
+	// or for a specific element tag ("MPD@publishTime").
+	IgnoreAttrs []string
+	// IgnoreElements are element tags whose subtrees are not compared.
+	IgnoreElements []string
+}
+
+// DefaultOptions ignores changes that are expected between two requests of the same live MPD.
+var DefaultOptions = Options{
+	IgnoreAttrs:    []string{"MPD@publishTime"},
+	IgnoreElements: []string{"SegmentTimeline"},
+}
+
+// Diff parses two MPDs and returns their differences.
+func Diff(mpdA, mpdB []byte, opts Options) ([]Change, error) {
+	dA := etree.NewDocument()
+	if err := dA.ReadFromBytes(mpdA); err != nil {
+		return nil, fmt.Errorf("read first MPD: %w", err)
+	}
+	dB := etree.NewDocument()
+	if err := dB.ReadFromBytes(mpdB); err != nil {
+		return nil, fmt.Errorf("read second MPD: %w", err)
+	}
+	rA, rB := dA.Root(), dB.Root()
+	if rA == nil || rB == nil {
+		return nil, fmt.Errorf("missing root element")
+	}
+	return DiffElements(rA, rB, opts), nil
+}
+
+// DiffElements returns the differences between two element trees.
+func DiffElements(a, b *etree.Element, opts Options) []Change {
+	d := differ{ignoreAttrs: make(map[string]bool), ignoreElems: make(map[string]bool)}
+	for _, ia := range opts.IgnoreAttrs {
+		d.ignoreAttrs[ia] = true
+	}
+	for _, ie := range opts.IgnoreElements {
+		d.ignoreElems[ie] = true
+	}
+	path := a.FullTag()
+	if a.FullTag() != b.FullTag() {
+		return []Change{{Kind: Changed, Path: path, Attr: "tag", Old: a.FullTag(), New: b.FullTag()}}
+	}
+	d.diff(path, a, b)
+	return d.changes
+}
+
+type differ struct {
+	ignoreAttrs map[string]bool
+	ignoreElems map[string]bool
+	changes     []Change
+}
+
+func (d *differ) attrIgnored(tag, attr string) bool {
+	return d.ignoreAttrs[attr] || d.ignoreAttrs[tag+"@"+attr]
+}
+
+func (d *differ) diff(path string, a, b *etree.Element) {
+	if d.ignoreElems[a.Tag] {
+		return
+	}
+	attrsA, attrsB := attrMap(a), attrMap(b)
+	for _, name := range sortedKeys(attrsA, attrsB) {
+		if d.attrIgnored(a.Tag, name) {
+			continue
+		}
+		va, okA := attrsA[name]
+		vb, okB := attrsB[name]
+		switch {
+		case okA && !okB:
+			d.changes = append(d.changes, Change{Kind: Removed, Path: path, Attr: name, Old: va})
+		case !okA && okB:
+			d.changes = append(d.changes, Change{Kind: Added, Path: path, Attr: name, New: vb})
+		case va != vb:
+			d.changes = append(d.changes, Change{Kind: Changed, Path: path, Attr: name, Old: va, New: vb})
+		}
+	}
+	keysA, childrenA := childKeys(a)
+	keysB, childrenB := childKeys(b)
+	for _, k := range keysA {
+		cPath := path + "/" + k
+		ca := childrenA[k]
+		cb, ok := childrenB[k]
+		if !ok {
+			if !d.ignoreElems[ca.Tag] {
+				d.changes = append(d.changes, Change{Kind: Removed, Path: cPath})
+			}
+			continue
+		}
+		d.diff(cPath, ca, cb)
+	}
+	for _, k := range keysB {
+		if _, ok := childrenA[k]; !ok && !d.ignoreElems[childrenB[k].Tag] {
+			d.changes = append(d.changes, Change{Kind: Added, Path: path + "/" + k})
+		}
+	}
+}
+
+// attrMap returns attributes and non-empty text content of an element.
+func attrMap(e *etree.Element) map[string]string {
+	am := make(map[string]string, len(e.Attr)+1)
+	for _, a := range e.Attr {
+		am[a.FullKey()] = a.Value
+	}
+	if text := strings.TrimSpace(e.Text()); text != "" {
+		am[textAttr] = text
+	}
+	return am
+}
+
+func sortedKeys(a, b map[string]string) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// childKeys returns keys for child elements in document order.
+// Elements with an id attribute are keyed by id, others by their position among siblings with the same tag.
+func childKeys(e *etree.Element) ([]string, map[string]*etree.Element) {
+	children := e.ChildElements()
+	keys := make([]string, 0, len(children))
+	m := make(map[string]*etree.Element, len(children))
+	count := make(map[string]int)
+	for _, c := range children {
+		tag := c.FullTag()
+		var key string
+		if id := c.SelectAttrValue("id", ""); id != "" {
+			key = fmt.Sprintf("%s[id=%s]", tag, id)
+		} else {
+			count[tag]++
+			key = fmt.Sprintf("%s[%d]", tag, count[tag])
+		}
+		keys = append(keys, key)
+		m[key] = c
+	}
+	return keys, m
+}
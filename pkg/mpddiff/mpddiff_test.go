@@ -0,0 +1,63 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package mpddiff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const mpdA = `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" type="dynamic" publishTime="2024-01-01T00:00:00Z" minimumUpdatePeriod="PT2S">
+  <BaseURL>https://a.example.com/</BaseURL>
+  <Period id="P0" start="PT0S">
+    <AdaptationSet id="1" contentType="video">
+      <SegmentTemplate media="$RepresentationID$/$Time$.m4s" timescale="90000">
+        <SegmentTimeline><S t="0" d="180000" r="4"/></SegmentTimeline>
+      </SegmentTemplate>
+      <Representation id="V300" bandwidth="300000"/>
+      <Representation id="V600" bandwidth="600000"/>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+
+const mpdB = `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" type="dynamic" publishTime="2024-01-01T00:00:10Z" minimumUpdatePeriod="PT4S">
+  <BaseURL>https://b.example.com/</BaseURL>
+  <Period id="P0" start="PT0S">
+    <AdaptationSet id="1" contentType="video">
+      <SegmentTemplate media="$RepresentationID$/$Time$.m4s" timescale="90000">
+        <SegmentTimeline><S t="180000" d="180000" r="4"/></SegmentTimeline>
+      </SegmentTemplate>
+      <Representation id="V300" bandwidth="300000" codecs="avc1.64001e"/>
+      <Representation id="V900" bandwidth="900000"/>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+
+func TestDiff(t *testing.T) {
+	changes, err := Diff([]byte(mpdA), []byte(mpdB), DefaultOptions)
+	require.NoError(t, err)
+	wanted := []Change{
+		{Kind: Changed, Path: "MPD", Attr: "minimumUpdatePeriod", Old: "PT2S", New: "PT4S"},
+		{Kind: Changed, Path: "MPD/BaseURL[1]", Attr: "#text", Old: "https://a.example.com/", New: "https://b.example.com/"},
+		{Kind: Added, Path: "MPD/Period[id=P0]/AdaptationSet[id=1]/Representation[id=V300]", Attr: "codecs", New: "avc1.64001e"},
+		{Kind: Removed, Path: "MPD/Period[id=P0]/AdaptationSet[id=1]/Representation[id=V600]"},
+		{Kind: Added, Path: "MPD/Period[id=P0]/AdaptationSet[id=1]/Representation[id=V900]"},
+	}
+	require.Equal(t, wanted, changes)
+
+	changes, err = Diff([]byte(mpdA), []byte(mpdB), Options{})
+	require.NoError(t, err)
+	require.Len(t, changes, 7, "publishTime and S@t should be included")
+
+	changes, err = Diff([]byte(mpdA), []byte(mpdA), Options{})
+	require.NoError(t, err)
+	require.Len(t, changes, 0)
+
+	_, err = Diff([]byte(mpdA), []byte("<MPD"), DefaultOptions)
+	require.Error(t, err)
+}
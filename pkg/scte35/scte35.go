@@ -3,6 +3,8 @@ package scte35
 
 import (
 	"errors"
+	"fmt"
+	"slices"
 
 	"github.com/Comcast/gots/v2"
 	"github.com/Comcast/gots/v2/scte35"
@@ -23,68 +25,162 @@ func IsValidSCTE35Interval(adsPerMinute int) error {
 	}
 }
 
-// CreateEmsgAhead generates an emsg SCTE-35 box if the the segment covers the time 7s before the ad start.
-// Depending on perMinute parameter, the splice inserts are as follows::
+// SignalType is the kind of SCTE-35 messages signaling the ad breaks.
+type SignalType int
+
+const (
+	// SpliceInsert signals each ad break with a splice_insert with duration and auto_return.
+	SpliceInsert SignalType = iota
+	// TimeSignal signals ad break start and end with time_signal messages carrying
+	// Provider Advertisement Start and End segmentation descriptors.
+	TimeSignal
+	// TimeSignalProgram is TimeSignal with Program End and Program Start segmentation
+	// descriptors at the ad break start and end, respectively.
+	TimeSignalProgram
+)
+
+var signalTypeNames = map[SignalType]string{
+	SpliceInsert:      "insert",
+	TimeSignal:        "signal",
+	TimeSignalProgram: "program",
+}
+
+func (t SignalType) String() string {
+	return signalTypeNames[t]
+}
+
+// ParseSignalType parses insert, signal, or program.
+func ParseSignalType(name string) (SignalType, error) {
+	for t, n := range signalTypeNames {
+		if n == name {
+			return t, nil
+		}
+	}
+	return SpliceInsert, fmt.Errorf("scte35 type %q is not insert, signal, or program", name)
+}
+
+// programEventIDFlag is set in the segmentation_event_id of program descriptors,
+// to separate them from the ad descriptors of the same break.
+const programEventIDFlag = 1 << 30
+
+// adBreaks returns the start times and the duration of the ad breaks in the minute of t.
+// The breaks end before the minute ends.
+// Depending on perMinute parameter, the breaks are as follows:
 // 1: 10s after full minute (20s duration)
 // 2: 10s and 40s after full minute (10 duration)
 // 3: 10s, 36s, 46s after full minute (10s duration)
-func CreateEmsgAhead(segStart, segEnd, timescale uint64, perMinute int) (*mp4.EmsgBox, error) {
-	if err := IsValidSCTE35Interval(perMinute); err != nil {
-		return nil, err
-	}
-	modMinute := segStart % (60 * timescale)
-	minuteStart := segStart - modMinute
-	var spliceInsertTimes []uint64
-	adDuration := 10 * timescale
+func adBreaks(t, timescale uint64, perMinute int) (starts []uint64, dur uint64) {
+	minuteStart := t - t%(60*timescale)
 	switch perMinute {
 	case 1:
-		adDuration = 20 * timescale
-		spliceInsertTimes = []uint64{minuteStart + 10*timescale}
+		return []uint64{minuteStart + 10*timescale}, 20 * timescale
 	case 2:
-		spliceInsertTimes = []uint64{minuteStart + 10*timescale, minuteStart + 40*timescale}
-	case 3:
-		spliceInsertTimes = []uint64{minuteStart + 10*timescale, minuteStart + 36*timescale, minuteStart + 46*timescale}
+		return []uint64{minuteStart + 10*timescale, minuteStart + 40*timescale}, 10 * timescale
+	default:
+		return []uint64{minuteStart + 10*timescale, minuteStart + 36*timescale, minuteStart + 46*timescale}, 10 * timescale
+	}
+}
+
+// CreateEmsgAhead generates an emsg SCTE-35 splice_insert box if the the segment covers the time 7s before the ad start.
+// The ad breaks are described at adBreaks.
+func CreateEmsgAhead(segStart, segEnd, timescale uint64, perMinute int) (*mp4.EmsgBox, error) {
+	emsgs, err := CreateEmsgsAhead(segStart, segEnd, timescale, perMinute, SpliceInsert)
+	if err != nil || len(emsgs) == 0 {
+		return nil, err
 	}
-	// We do not need to look into next minute, since first start is 10s after full minute.
-	inInterval := false
-	var spliceTime uint64
-	for _, sit := range spliceInsertTimes {
-		announceTime := sit - 7*timescale
+	return emsgs[0], nil
+}
+
+// CreateEmsgsAhead generates emsg SCTE-35 boxes for the splice points announced in the segment,
+// where a splice point is announced 7s ahead. As for CreateEmsgAhead, only the breaks in the
+// minute of the segment start are considered, since the first announcement is 3s after full minute.
+// For SpliceInsert, the splice points are the ad break starts. For the time_signal types,
+// the ad break ends are splice points as well, and descriptors at the same time share one message.
+// The emsg id is the splice time in seconds.
+func CreateEmsgsAhead(segStart, segEnd, timescale uint64, perMinute int, st SignalType) ([]*mp4.EmsgBox, error) {
+	if err := IsValidSCTE35Interval(perMinute); err != nil {
+		return nil, err
+	}
+	starts, adDuration := adBreaks(segStart, timescale, perMinute)
+	var emsgs []*mp4.EmsgBox
+	for _, spliceTime := range spliceTimes(starts, adDuration, st) {
+		announceTime := spliceTime - 7*timescale
 		if segStart < announceTime && announceTime <= segEnd {
-			inInterval = true
-			spliceTime = sit
-			break
+			emsgs = append(emsgs, createEmsg(spliceTime, starts, adDuration, timescale, st))
 		}
 	}
-	if !inInterval {
-		return nil, nil
+	return emsgs, nil
+}
+
+// spliceTimes returns the sorted unique splice times of the ad breaks.
+func spliceTimes(starts []uint64, adDuration uint64, st SignalType) []uint64 {
+	times := append([]uint64{}, starts...)
+	if st != SpliceInsert {
+		for _, start := range starts {
+			times = append(times, start+adDuration)
+		}
 	}
+	slices.Sort(times)
+	return slices.Compact(times)
+}
+
+// createEmsg creates the emsg box for the splice point at spliceTime.
+func createEmsg(spliceTime uint64, starts []uint64, adDuration, timescale uint64, st SignalType) *mp4.EmsgBox {
 	emsgID := spliceTime / timescale
-	p := SpliceInsertParams{
-		PtsTime:                    uint64(spliceTime*90000/timescale) % (1 << 33),
-		Duration:                   uint64(adDuration * 90000 / timescale),
-		SpliceEventID:              uint32(emsgID),
-		Tier:                       4095,
-		UniqueProgramID:            0,
-		AvailNum:                   0,
-		AvailsExpected:             0,
-		SpliceEventCancelIndicator: false,
-		OutOfNetworkIndicator:      true,
-		SpliceImmediateFlag:        false,
-		AutoReturn:                 true,
-	}
 	e := mp4.EmsgBox{
 		Version:          1,
 		Flags:            0,
 		TimeScale:        uint32(timescale),
-		PresentationTime: uint64(spliceTime),
-		EventDuration:    uint32(adDuration),
+		PresentationTime: spliceTime,
 		ID:               uint32(emsgID),
 		SchemeIDURI:      SchemeIDURI,
 		Value:            "",
-		MessageData:      CreateSpliceInsertPayload(p),
 	}
-	return &e, nil
+	ptsTime := uint64(spliceTime*90000/timescale) % (1 << 33)
+	if st == SpliceInsert {
+		e.EventDuration = uint32(adDuration)
+		e.MessageData = CreateSpliceInsertPayload(SpliceInsertParams{
+			PtsTime:                    ptsTime,
+			Duration:                   uint64(adDuration * 90000 / timescale),
+			SpliceEventID:              uint32(emsgID),
+			Tier:                       4095,
+			UniqueProgramID:            0,
+			AvailNum:                   0,
+			AvailsExpected:             0,
+			SpliceEventCancelIndicator: false,
+			OutOfNetworkIndicator:      true,
+			SpliceImmediateFlag:        false,
+			AutoReturn:                 true,
+		})
+		return &e
+	}
+	var descs []SegmentationDescriptorParams
+	for _, start := range starts {
+		eventID := uint32(start / timescale)
+		if start+adDuration == spliceTime {
+			descs = append(descs, SegmentationDescriptorParams{EventID: eventID,
+				TypeID: scte35.SegDescProviderAdvertisementEnd, SegmentNum: 1, SegmentsExpected: 1})
+			if st == TimeSignalProgram {
+				descs = append(descs, SegmentationDescriptorParams{EventID: eventID | programEventIDFlag,
+					TypeID: scte35.SegDescProgramStart})
+			}
+		}
+	}
+	for _, start := range starts {
+		eventID := uint32(start / timescale)
+		if start == spliceTime {
+			if st == TimeSignalProgram {
+				descs = append(descs, SegmentationDescriptorParams{EventID: eventID | programEventIDFlag,
+					TypeID: scte35.SegDescProgramEnd})
+			}
+			descs = append(descs, SegmentationDescriptorParams{EventID: eventID,
+				TypeID: scte35.SegDescProviderAdvertisementStart, Duration: uint64(adDuration * 90000 / timescale),
+				SegmentNum: 1, SegmentsExpected: 1})
+			e.EventDuration = uint32(adDuration)
+		}
+	}
+	e.MessageData = CreateTimeSignalPayload(ptsTime, descs)
+	return &e
 }
 
 type SpliceInsertParams struct {
@@ -117,9 +213,47 @@ func CreateSpliceInsertPayload(p SpliceInsertParams) []byte {
 		cmd.SetIsAutoReturn(p.AutoReturn)
 	}
 	cmd.SetHasPTS(true)
-	cmd.SetPTS(gots.PTS(p.PtsTime))
 	cmd.SetIsOut(p.OutOfNetworkIndicator)
 	cmd.SetSpliceImmediate(p.SpliceImmediateFlag)
 	s.SetCommandInfo(cmd)
+	s.SetPTS(gots.PTS(p.PtsTime)) // Sets the command PTS with zero pts_adjustment
+	return s.UpdateData()
+}
+
+// SegmentationDescriptorParams are the parameters of a segmentation_descriptor.
+// Delivery is not restricted and no UPID is used.
+type SegmentationDescriptorParams struct {
+	EventID          uint32
+	TypeID           scte35.SegDescType
+	Duration         uint64 // In 90kHz ticks. 0 means no duration.
+	SegmentNum       uint8
+	SegmentsExpected uint8
+}
+
+// CreateTimeSignalPayload creates a SCTE-35 splice_info_section with a time_signal
+// at ptsTime and the segmentation descriptors, including CRC.
+func CreateTimeSignalPayload(ptsTime uint64, descs []SegmentationDescriptorParams) []byte {
+	s := scte35.CreateSCTE35()
+	cmd := scte35.CreateTimeSignalCommand()
+	cmd.SetHasPTS(true)
+	s.SetCommandInfo(cmd)
+	s.SetPTS(gots.PTS(ptsTime))
+	sds := make([]scte35.SegmentationDescriptor, 0, len(descs))
+	for _, p := range descs {
+		sd := scte35.CreateSegmentationDescriptor()
+		sd.SetEventID(p.EventID)
+		sd.SetTypeID(p.TypeID)
+		sd.SetIsDeliveryNotRestricted(true)
+		sd.SetHasProgramSegmentation(true)
+		sd.SetUPIDType(scte35.SegUPIDNotUsed)
+		if p.Duration != 0 {
+			sd.SetHasDuration(true)
+			sd.SetDuration(gots.PTS(p.Duration))
+		}
+		sd.SetSegmentNumber(p.SegmentNum)
+		sd.SetSegmentsExpected(p.SegmentsExpected)
+		sds = append(sds, sd)
+	}
+	s.SetDescriptors(sds)
 	return s.UpdateData()
 }
@@ -3,6 +3,8 @@ package scte35_test
 import (
 	"testing"
 
+	"github.com/Comcast/gots/v2"
+	gscte35 "github.com/Comcast/gots/v2/scte35"
 	"github.com/Dash-Industry-Forum/livesim2/pkg/scte35"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -69,3 +71,100 @@ func TestSCTE35Generation(t *testing.T) {
 		}
 	}
 }
+
+func TestSCTE35SignalTypes(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		st          scte35.SignalType
+		segStart    uint64
+		segEnd      uint64
+		perMinute   int
+		wantedTimes []uint64
+		wantedCmd   gscte35.SpliceCommandType
+		wantedTypes [][]gscte35.SegDescType
+	}{
+		{
+			desc:        "splice_insert at ad start",
+			st:          scte35.SpliceInsert,
+			segStart:    2,
+			segEnd:      4,
+			perMinute:   1,
+			wantedTimes: []uint64{10},
+			wantedCmd:   gscte35.SpliceInsert,
+			wantedTypes: [][]gscte35.SegDescType{nil},
+		},
+		{
+			desc:        "splice_insert not at ad end",
+			st:          scte35.SpliceInsert,
+			segStart:    22,
+			segEnd:      24,
+			perMinute:   1,
+			wantedTimes: nil,
+		},
+		{
+			desc:        "time_signal at ad end",
+			st:          scte35.TimeSignal,
+			segStart:    22,
+			segEnd:      24,
+			perMinute:   1,
+			wantedTimes: []uint64{30},
+			wantedCmd:   gscte35.TimeSignal,
+			wantedTypes: [][]gscte35.SegDescType{{gscte35.SegDescProviderAdvertisementEnd}},
+		},
+		{
+			desc:        "time_signal with end and start at same time",
+			st:          scte35.TimeSignal,
+			segStart:    38,
+			segEnd:      40,
+			perMinute:   3,
+			wantedTimes: []uint64{46},
+			wantedCmd:   gscte35.TimeSignal,
+			wantedTypes: [][]gscte35.SegDescType{{gscte35.SegDescProviderAdvertisementEnd, gscte35.SegDescProviderAdvertisementStart}},
+		},
+		{
+			desc:        "time_signal with program descriptors",
+			st:          scte35.TimeSignalProgram,
+			segStart:    0,
+			segEnd:      24,
+			perMinute:   1,
+			wantedTimes: []uint64{10, 30},
+			wantedCmd:   gscte35.TimeSignal,
+			wantedTypes: [][]gscte35.SegDescType{
+				{gscte35.SegDescProgramEnd, gscte35.SegDescProviderAdvertisementStart},
+				{gscte35.SegDescProviderAdvertisementEnd, gscte35.SegDescProgramStart},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			timescale := uint64(90000)
+			emsgs, err := scte35.CreateEmsgsAhead(tc.segStart*timescale, tc.segEnd*timescale, timescale, tc.perMinute, tc.st)
+			require.NoError(t, err)
+			require.Len(t, emsgs, len(tc.wantedTimes))
+			for i, emsg := range emsgs {
+				require.Equal(t, tc.wantedTimes[i]*timescale, emsg.PresentationTime)
+				require.Equal(t, uint32(tc.wantedTimes[i]), emsg.ID)
+				data := emsg.MessageData
+				// pts_adjustment is the 33 bits after protocol_version
+				require.Equal(t, []byte{0, 0, 0, 0, 0}, []byte{data[4] & 0x01, data[5], data[6], data[7], data[8]}, "pts_adjustment")
+				s, err := gscte35.NewSCTE35(append([]byte{0}, data...)) // Prepend a zero pointer_field
+				require.NoError(t, err)
+				require.Equal(t, tc.wantedCmd, s.Command())
+				require.Equal(t, gots.PTS(tc.wantedTimes[i]*90000), s.CommandInfo().PTS())
+				descs := s.Descriptors()
+				require.Len(t, descs, len(tc.wantedTypes[i]))
+				for j, d := range descs {
+					require.Equal(t, tc.wantedTypes[i][j], d.TypeID())
+					if d.TypeID() == gscte35.SegDescProviderAdvertisementStart {
+						require.True(t, d.HasDuration())
+					}
+				}
+			}
+		})
+	}
+	_, err := scte35.ParseSignalType("splice")
+	require.Error(t, err)
+	st, err := scte35.ParseSignalType("program")
+	require.NoError(t, err)
+	require.Equal(t, scte35.TimeSignalProgram, st)
+}
@@ -0,0 +1,104 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+// Package segtemplate handles the identifiers of DASH SegmentTemplate media and initialization strings,
+// including format tags like $Number%05d$ and the $$ escape.
+package segtemplate
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// identifierRexp matches the SegmentTemplate identifiers of ISO/IEC 23009-1 Table 16,
+// including an optional %0[width]d format tag, and the $$ escape (empty name).
+var identifierRexp = regexp.MustCompile(`\$(RepresentationID|Number|Bandwidth|Time|SubNumber|)(?:%0(\d+)d)?\$`)
+
+// Identifier is an identifier found in a template string.
+type Identifier struct {
+	Start, End int    // Position in the template string
+	Name       string // Empty for the $$ escape
+	Width      int    // 0 if no format tag
+}
+
+// Identifiers returns the identifiers of str in order.
+func Identifiers(str string) []Identifier {
+	var ids []Identifier
+	for _, match := range identifierRexp.FindAllStringSubmatchIndex(str, -1) {
+		id := Identifier{Start: match[0], End: match[1], Name: str[match[2]:match[3]]}
+		if match[4] >= 0 {
+			id.Width, _ = strconv.Atoi(str[match[4]:match[5]])
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Substitute replaces the identifiers with values in vals, and keeps the others.
+// Numeric values are zero-padded to the width of a format tag. The format tag is ignored for
+// $RepresentationID$ as it is not allowed there. If unescape is set, $$ is replaced by $.
+func Substitute(str string, vals map[string]string, unescape bool) string {
+	ids := Identifiers(str)
+	if len(ids) == 0 {
+		return str
+	}
+	var sb strings.Builder
+	pos := 0
+	for _, id := range ids {
+		sb.WriteString(str[pos:id.Start])
+		pos = id.End
+		if id.Name == "" {
+			if unescape {
+				sb.WriteString("$")
+			} else {
+				sb.WriteString("$$")
+			}
+			continue
+		}
+		val, ok := vals[id.Name]
+		if !ok {
+			sb.WriteString(str[id.Start:id.End])
+			continue
+		}
+		if id.Name != "RepresentationID" && len(val) < id.Width {
+			val = strings.Repeat("0", id.Width-len(val)) + val
+		}
+		sb.WriteString(val)
+	}
+	sb.WriteString(str[pos:])
+	return sb.String()
+}
+
+// Has tells if the template has the identifier name with or without format tag.
+func Has(str, name string) bool {
+	for _, id := range Identifiers(str) {
+		if id.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Rename changes identifiers from one name to another, keeping the format tag.
+// It is used to switch between $Number$ and $Time$ addressing.
+func Rename(str, from, to string) string {
+	var sb strings.Builder
+	pos := 0
+	for _, id := range Identifiers(str) {
+		if id.Name != from {
+			continue
+		}
+		sb.WriteString(str[pos:id.Start])
+		if id.Width > 0 {
+			fmt.Fprintf(&sb, "$%s%%0%dd$", to, id.Width)
+		} else {
+			fmt.Fprintf(&sb, "$%s$", to)
+		}
+		pos = id.End
+	}
+	sb.WriteString(str[pos:])
+	return sb.String()
+}
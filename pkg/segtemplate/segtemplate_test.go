@@ -0,0 +1,40 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package segtemplate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubstitute(t *testing.T) {
+	vals := map[string]string{"RepresentationID": "V300", "Number": "7", "SubNumber": "1"}
+	testCases := []struct {
+		tmpl     string
+		unescape bool
+		wanted   string
+	}{
+		{"$RepresentationID$/$Number$.m4s", false, "V300/7.m4s"},
+		{"$RepresentationID%03d$/$Number%05d$.m4s", false, "V300/00007.m4s"},
+		{"$RepresentationID$/$Number$_$SubNumber%02d$.m4s", false, "V300/7_01.m4s"},
+		{"$RepresentationID$/$Time$.m4s", false, "V300/$Time$.m4s"},
+		{"$RepresentationID$/$$$Number$$$.m4s", false, "V300/$$7$$.m4s"},
+		{"$RepresentationID$/$$$Number$$$.m4s", true, "V300/$7$.m4s"},
+		{"$Unknown$/$Number$.m4s", true, "$Unknown$/7.m4s"},
+	}
+	for _, tc := range testCases {
+		require.Equal(t, tc.wanted, Substitute(tc.tmpl, vals, tc.unescape), tc.tmpl)
+	}
+}
+
+func TestRename(t *testing.T) {
+	require.Equal(t, "$RepresentationID$/$Time%05d$_$Time$.m4s",
+		Rename("$RepresentationID$/$Number%05d$_$Number$.m4s", "Number", "Time"))
+	require.Equal(t, "$RepresentationID$/$Number$.m4s",
+		Rename("$RepresentationID$/$Number$.m4s", "Time", "Number"))
+	require.True(t, Has("V300/$Time%05d$.m4s", "Time"))
+	require.False(t, Has("V300/$Time%05d$.m4s", "Number"))
+}
@@ -0,0 +1,61 @@
+// Copyright 2023, DASH-Industry Forum. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+// Package storage provides storage of generated artifacts on local disk or in S3-compatible object storage.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrNotFound is returned by Get if there is no data for the key.
+var ErrNotFound = errors.New("not found")
+
+// Storage stores data by key. Keys are relative paths with "/" as separator.
+type Storage interface {
+	// Put stores data at key, replacing any previous data.
+	Put(ctx context.Context, key string, data []byte) error
+	// Get returns the data stored at key, or ErrNotFound.
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// New returns a storage given by uri, which is one of
+//
+//   - a local directory path or file:///path for local disk
+//   - s3://bucket/prefix for S3, configured by the usual AWS environment variables
+//     (AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, AWS_REGION, AWS_ENDPOINT_URL)
+func New(uri string) (Storage, error) {
+	if !strings.Contains(uri, "://") {
+		return NewLocal(uri), nil
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("storage uri: %w", err)
+	}
+	switch u.Scheme {
+	case "file":
+		return NewLocal(u.Path), nil
+	case "s3":
+		return NewS3FromEnv(u.Host, strings.Trim(u.Path, "/"))
+	default:
+		return nil, fmt.Errorf("storage uri %q: unknown scheme %q", uri, u.Scheme)
+	}
+}
+
+// checkKey returns an error if key is not a clean relative path.
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return fmt.Errorf("bad key %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("bad key %q", key)
+		}
+	}
+	return nil
+}